@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CATALOG_REST_CLIENT_TIMEOUT = 60 * time.Second
+)
+
+// Table loaded from or committed to the Iceberg REST catalog
+type CatalogRestTable struct {
+	MetadataLocation string          `json:"metadata-location"`
+	Metadata         json.RawMessage `json:"metadata"`
+}
+
+// Client of the Iceberg REST catalog API. Each schema is a single-level namespace
+type CatalogRestClient struct {
+	config     *Config
+	httpClient *http.Client
+
+	pathPrefixMutex sync.Mutex
+	pathPrefix      *string // Fetched from the catalog config before the first request
+}
+
+func NewCatalogRestClient(config *Config) *CatalogRestClient {
+	return &CatalogRestClient{
+		config:     config,
+		httpClient: &http.Client{Timeout: CATALOG_REST_CLIENT_TIMEOUT},
+	}
+}
+
+func (client *CatalogRestClient) ListNamespaces() ([]string, error) {
+	namespaces := []string{}
+	err := client.listPages("/namespaces", func(responseBody []byte) error {
+		var response struct {
+			Namespaces [][]string `json:"namespaces"`
+		}
+		err := json.Unmarshal(responseBody, &response)
+		if err != nil {
+			return err
+		}
+		for _, namespace := range response.Namespaces {
+			if len(namespace) == 1 {
+				namespaces = append(namespaces, namespace[0])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return namespaces, nil
+}
+
+// Returns no tables if the namespace doesn't exist
+func (client *CatalogRestClient) ListTables(namespace string) ([]string, error) {
+	tables := []string{}
+	err := client.listPages(client.namespacePath(namespace)+"/tables", func(responseBody []byte) error {
+		var response struct {
+			Identifiers []CatalogTableIdentifier `json:"identifiers"`
+		}
+		err := json.Unmarshal(responseBody, &response)
+		if err != nil {
+			return err
+		}
+		for _, identifier := range response.Identifiers {
+			tables = append(tables, identifier.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		if catalogErr, ok := err.(*CatalogRestError); ok && catalogErr.Code == http.StatusNotFound {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	return tables, nil
+}
+
+// Returns nil if the table doesn't exist
+func (client *CatalogRestClient) LoadTable(icebergSchemaTable IcebergSchemaTable) (*CatalogRestTable, error) {
+	var table CatalogRestTable
+	err := client.request("GET", client.tablePath(icebergSchemaTable), nil, &table)
+	if err != nil {
+		if catalogErr, ok := err.(*CatalogRestError); ok && catalogErr.Code == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &table, nil
+}
+
+// Does nothing if the namespace already exists
+func (client *CatalogRestClient) CreateNamespace(namespace string) error {
+	err := client.request("POST", "/namespaces", map[string]interface{}{
+		"namespace":  []string{namespace},
+		"properties": map[string]string{},
+	}, nil)
+	if catalogErr, ok := err.(*CatalogRestError); ok && catalogErr.Code == http.StatusConflict {
+		return nil
+	}
+	return err
+}
+
+// Registers a table with an existing metadata file. Returns nil if the table already exists
+func (client *CatalogRestClient) RegisterTable(icebergSchemaTable IcebergSchemaTable, metadataLocation string) (*CatalogRestTable, error) {
+	var table CatalogRestTable
+	err := client.request("POST", client.namespacePath(icebergSchemaTable.Schema)+"/register", map[string]interface{}{
+		"name":              icebergSchemaTable.Table,
+		"metadata-location": metadataLocation,
+	}, &table)
+	if err != nil {
+		if catalogErr, ok := err.(*CatalogRestError); ok && catalogErr.Code == http.StatusConflict {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &table, nil
+}
+
+// Applies the updates if the table meets the requirements. Returns nil if a requirement failed because of a concurrent commit
+func (client *CatalogRestClient) CommitTable(icebergSchemaTable IcebergSchemaTable, requirements []map[string]interface{}, updates []map[string]interface{}) (*CatalogRestTable, error) {
+	var table CatalogRestTable
+	err := client.request("POST", client.tablePath(icebergSchemaTable), map[string]interface{}{
+		"identifier":   CatalogTableIdentifier{Namespace: []string{icebergSchemaTable.Schema}, Name: icebergSchemaTable.Table},
+		"requirements": requirements,
+		"updates":      updates,
+	}, &table)
+	if err != nil {
+		if catalogErr, ok := err.(*CatalogRestError); ok && catalogErr.Code == http.StatusConflict {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &table, nil
+}
+
+// Removes the table from the catalog without deleting its files. Does nothing if the table doesn't exist
+func (client *CatalogRestClient) DropTable(icebergSchemaTable IcebergSchemaTable) error {
+	err := client.request("DELETE", client.tablePath(icebergSchemaTable)+"?purgeRequested=false", nil, nil)
+	if catalogErr, ok := err.(*CatalogRestError); ok && catalogErr.Code == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+// Does nothing if the namespace doesn't exist
+func (client *CatalogRestClient) DropNamespace(namespace string) error {
+	err := client.request("DELETE", client.namespacePath(namespace), nil, nil)
+	if catalogErr, ok := err.(*CatalogRestError); ok && catalogErr.Code == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+// Error response of the catalog
+type CatalogRestError struct {
+	Code    int
+	Type    string
+	Message string
+}
+
+func (catalogErr *CatalogRestError) Error() string {
+	return fmt.Sprintf("Iceberg REST catalog error %d %s: %s", catalogErr.Code, catalogErr.Type, catalogErr.Message)
+}
+
+func (client *CatalogRestClient) listPages(path string, parsePage func(responseBody []byte) error) error {
+	pageToken := ""
+	for {
+		pagePath := path
+		if pageToken != "" {
+			pagePath += "?pageToken=" + url.QueryEscape(pageToken)
+		}
+
+		var responseBody json.RawMessage
+		err := client.request("GET", pagePath, nil, &responseBody)
+		if err != nil {
+			return err
+		}
+		err = parsePage(responseBody)
+		if err != nil {
+			return err
+		}
+
+		var page struct {
+			NextPageToken string `json:"next-page-token"`
+		}
+		err = json.Unmarshal(responseBody, &page)
+		if err != nil {
+			return err
+		}
+		if page.NextPageToken == "" {
+			return nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+func (client *CatalogRestClient) request(method string, path string, requestBody interface{}, responseBody interface{}) error {
+	pathPrefix, err := client.fetchPathPrefix()
+	if err != nil {
+		return err
+	}
+	return client.requestUrl(method, strings.TrimSuffix(client.config.Catalog.Uri, "/")+"/v1"+pathPrefix+path, requestBody, responseBody)
+}
+
+func (client *CatalogRestClient) requestUrl(method string, requestUrl string, requestBody interface{}, responseBody interface{}) error {
+	var bodyReader io.Reader
+	if requestBody != nil {
+		requestJson, err := json.Marshal(requestBody)
+		if err != nil {
+			return err
+		}
+		bodyReader = bytes.NewReader(requestJson)
+	}
+
+	request, err := http.NewRequest(method, requestUrl, bodyReader)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if client.config.Catalog.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+client.config.Catalog.Token)
+	}
+	LogDebug(client.config, "Iceberg REST catalog request:", method, requestUrl)
+
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to send a request to the Iceberg REST catalog: %w", err)
+	}
+	defer response.Body.Close()
+
+	responseContent, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode >= 400 {
+		var errorResponse struct {
+			Error CatalogErrorModel `json:"error"`
+		}
+		if json.Unmarshal(responseContent, &errorResponse) != nil || errorResponse.Error.Message == "" {
+			errorResponse.Error.Message = string(responseContent)
+		}
+		return &CatalogRestError{Code: response.StatusCode, Type: errorResponse.Error.Type, Message: errorResponse.Error.Message}
+	}
+
+	if responseBody != nil && len(responseContent) > 0 {
+		return json.Unmarshal(responseContent, responseBody)
+	}
+	return nil
+}
+
+// Returns the path prefix from the catalog config overrides, e.g. "/warehouse-name"
+func (client *CatalogRestClient) fetchPathPrefix() (string, error) {
+	client.pathPrefixMutex.Lock()
+	defer client.pathPrefixMutex.Unlock()
+
+	if client.pathPrefix != nil {
+		return *client.pathPrefix, nil
+	}
+
+	configUrl := strings.TrimSuffix(client.config.Catalog.Uri, "/") + "/v1/config"
+	if client.config.Catalog.Warehouse != "" {
+		configUrl += "?warehouse=" + url.QueryEscape(client.config.Catalog.Warehouse)
+	}
+
+	var catalogConfig struct {
+		Overrides map[string]string `json:"overrides"`
+		Defaults  map[string]string `json:"defaults"`
+	}
+	err := client.requestUrl("GET", configUrl, nil, &catalogConfig)
+	if err != nil {
+		return "", err
+	}
+
+	pathPrefix := ""
+	if prefix := catalogConfig.Overrides["prefix"]; prefix != "" {
+		pathPrefix = "/" + strings.Trim(prefix, "/")
+	} else if prefix := catalogConfig.Defaults["prefix"]; prefix != "" {
+		pathPrefix = "/" + strings.Trim(prefix, "/")
+	}
+	client.pathPrefix = &pathPrefix
+	return pathPrefix, nil
+}
+
+func (client *CatalogRestClient) namespacePath(namespace string) string {
+	return "/namespaces/" + url.PathEscape(namespace)
+}
+
+func (client *CatalogRestClient) tablePath(icebergSchemaTable IcebergSchemaTable) string {
+	return client.namespacePath(icebergSchemaTable.Schema) + "/tables/" + url.PathEscape(icebergSchemaTable.Table)
+}
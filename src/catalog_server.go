@@ -0,0 +1,249 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const (
+	CATALOG_NAMESPACE_SEPARATOR = "\x1f" // Multi-level namespaces are joined with the unit separator in paths
+
+	CATALOG_ERROR_TYPE_BAD_REQUEST           = "BadRequestException"
+	CATALOG_ERROR_TYPE_NOT_AUTHORIZED        = "NotAuthorizedException"
+	CATALOG_ERROR_TYPE_NO_SUCH_NAMESPACE     = "NoSuchNamespaceException"
+	CATALOG_ERROR_TYPE_NO_SUCH_TABLE         = "NoSuchTableException"
+	CATALOG_ERROR_TYPE_UNSUPPORTED_OPERATION = "UnsupportedOperationException"
+	CATALOG_ERROR_TYPE_SERVER_ERROR          = "ServerErrorException"
+)
+
+type CatalogTableIdentifier struct {
+	Namespace []string `json:"namespace"`
+	Name      string   `json:"name"`
+}
+
+type CatalogErrorModel struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    int    `json:"code"`
+}
+
+// Serves synced tables with the read-only endpoints of the Iceberg REST catalog API.
+// Each schema is a single-level namespace, and tables are loaded from their current metadata file
+type CatalogServer struct {
+	config        *Config
+	icebergReader *IcebergReader
+}
+
+func NewCatalogServer(config *Config, icebergReader *IcebergReader) *CatalogServer {
+	return &CatalogServer{config: config, icebergReader: icebergReader}
+}
+
+func (server *CatalogServer) ListenAndServe() error {
+	address := net.JoinHostPort(server.config.Host, server.config.Catalog.Port)
+	LogInfo(server.config, "Iceberg REST catalog: Listening on", address)
+	return http.ListenAndServe(address, server.Handler())
+}
+
+func (server *CatalogServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/config", server.getConfig)
+	mux.HandleFunc("GET /v1/namespaces", server.listNamespaces)
+	mux.HandleFunc("GET /v1/namespaces/{namespace}", server.loadNamespace)
+	mux.HandleFunc("HEAD /v1/namespaces/{namespace}", server.namespaceExists)
+	mux.HandleFunc("GET /v1/namespaces/{namespace}/tables", server.listTables)
+	mux.HandleFunc("GET /v1/namespaces/{namespace}/tables/{table}", server.loadTable)
+	mux.HandleFunc("HEAD /v1/namespaces/{namespace}/tables/{table}", server.tableExists)
+	mux.HandleFunc("/", server.unsupportedOperation)
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		LogDebug(server.config, "Iceberg REST catalog:", request.Method, request.URL.Path)
+
+		if server.config.Catalog.Token != "" {
+			token, found := strings.CutPrefix(request.Header.Get("Authorization"), "Bearer ")
+			if !found || subtle.ConstantTimeCompare([]byte(token), []byte(server.config.Catalog.Token)) != 1 {
+				server.writeError(writer, http.StatusUnauthorized, CATALOG_ERROR_TYPE_NOT_AUTHORIZED, "Invalid bearer token")
+				return
+			}
+		}
+
+		mux.ServeHTTP(writer, request)
+	})
+}
+
+func (server *CatalogServer) getConfig(writer http.ResponseWriter, request *http.Request) {
+	server.writeJson(writer, http.StatusOK, map[string]interface{}{
+		"defaults":  map[string]string{},
+		"overrides": map[string]string{},
+	})
+}
+
+func (server *CatalogServer) listNamespaces(writer http.ResponseWriter, request *http.Request) {
+	namespaces := [][]string{}
+
+	// Schemas have no child namespaces
+	if request.URL.Query().Get("parent") == "" {
+		schemas, err := server.icebergReader.Schemas()
+		if err != nil {
+			server.writeServerError(writer, err)
+			return
+		}
+		slices.Sort(schemas)
+		for _, schema := range schemas {
+			namespaces = append(namespaces, []string{schema})
+		}
+	}
+
+	server.writeJson(writer, http.StatusOK, map[string]interface{}{"namespaces": namespaces})
+}
+
+func (server *CatalogServer) loadNamespace(writer http.ResponseWriter, request *http.Request) {
+	schema, ok := server.existingSchema(writer, request)
+	if !ok {
+		return
+	}
+
+	server.writeJson(writer, http.StatusOK, map[string]interface{}{
+		"namespace":  []string{schema},
+		"properties": map[string]string{},
+	})
+}
+
+func (server *CatalogServer) namespaceExists(writer http.ResponseWriter, request *http.Request) {
+	if _, ok := server.existingSchema(writer, request); ok {
+		writer.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (server *CatalogServer) listTables(writer http.ResponseWriter, request *http.Request) {
+	schema, ok := server.existingSchema(writer, request)
+	if !ok {
+		return
+	}
+
+	icebergSchemaTables, err := server.icebergReader.SchemaTables()
+	if err != nil {
+		server.writeServerError(writer, err)
+		return
+	}
+	tables := []string{}
+	for icebergSchemaTable := range icebergSchemaTables {
+		if icebergSchemaTable.Schema == schema {
+			tables = append(tables, icebergSchemaTable.Table)
+		}
+	}
+	slices.Sort(tables)
+
+	identifiers := []CatalogTableIdentifier{}
+	for _, table := range tables {
+		identifiers = append(identifiers, CatalogTableIdentifier{Namespace: []string{schema}, Name: table})
+	}
+	server.writeJson(writer, http.StatusOK, map[string]interface{}{"identifiers": identifiers})
+}
+
+func (server *CatalogServer) loadTable(writer http.ResponseWriter, request *http.Request) {
+	icebergSchemaTable, ok := server.existingSchemaTable(writer, request)
+	if !ok {
+		return
+	}
+
+	metadataFilePath, metadataContent, err := server.icebergReader.Metadata(icebergSchemaTable)
+	if err != nil {
+		server.writeServerError(writer, err)
+		return
+	}
+
+	server.writeJson(writer, http.StatusOK, map[string]interface{}{
+		"metadata-location": metadataFilePath,
+		"metadata":          json.RawMessage(metadataContent),
+		"config":            server.tableConfig(),
+	})
+}
+
+func (server *CatalogServer) tableExists(writer http.ResponseWriter, request *http.Request) {
+	if _, ok := server.existingSchemaTable(writer, request); ok {
+		writer.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (server *CatalogServer) unsupportedOperation(writer http.ResponseWriter, request *http.Request) {
+	server.writeError(writer, http.StatusNotAcceptable, CATALOG_ERROR_TYPE_UNSUPPORTED_OPERATION, "Unsupported operation: "+request.Method+" "+request.URL.Path)
+}
+
+// Storage credentials aren't vended, so clients read data files with their own credentials
+func (server *CatalogServer) tableConfig() map[string]string {
+	tableConfig := map[string]string{}
+	if server.config.StorageType == STORAGE_TYPE_S3 {
+		tableConfig["client.region"] = server.config.Aws.Region
+	}
+	return tableConfig
+}
+
+// Writes a NoSuchNamespaceException and returns false if the schema doesn't exist
+func (server *CatalogServer) existingSchema(writer http.ResponseWriter, request *http.Request) (string, bool) {
+	namespace := request.PathValue("namespace")
+	if strings.Contains(namespace, CATALOG_NAMESPACE_SEPARATOR) {
+		server.writeError(writer, http.StatusNotFound, CATALOG_ERROR_TYPE_NO_SUCH_NAMESPACE, "Namespace does not exist: "+strings.ReplaceAll(namespace, CATALOG_NAMESPACE_SEPARATOR, "."))
+		return "", false
+	}
+
+	schemas, err := server.icebergReader.Schemas()
+	if err != nil {
+		server.writeServerError(writer, err)
+		return "", false
+	}
+	if !slices.Contains(schemas, namespace) {
+		server.writeError(writer, http.StatusNotFound, CATALOG_ERROR_TYPE_NO_SUCH_NAMESPACE, "Namespace does not exist: "+namespace)
+		return "", false
+	}
+	return namespace, true
+}
+
+// Writes a NoSuchTableException and returns false if the table doesn't exist
+func (server *CatalogServer) existingSchemaTable(writer http.ResponseWriter, request *http.Request) (IcebergSchemaTable, bool) {
+	schema, ok := server.existingSchema(writer, request)
+	if !ok {
+		return IcebergSchemaTable{}, false
+	}
+
+	icebergSchemaTable := IcebergSchemaTable{Schema: schema, Table: request.PathValue("table")}
+	icebergSchemaTables, err := server.icebergReader.SchemaTables()
+	if err != nil {
+		server.writeServerError(writer, err)
+		return IcebergSchemaTable{}, false
+	}
+	if !icebergSchemaTables.Contains(icebergSchemaTable) {
+		server.writeError(writer, http.StatusNotFound, CATALOG_ERROR_TYPE_NO_SUCH_TABLE, "Table does not exist: "+icebergSchemaTable.String())
+		return IcebergSchemaTable{}, false
+	}
+	return icebergSchemaTable, true
+}
+
+func (server *CatalogServer) writeServerError(writer http.ResponseWriter, err error) {
+	LogError(server.config, "Iceberg REST catalog:", err)
+	server.writeError(writer, http.StatusInternalServerError, CATALOG_ERROR_TYPE_SERVER_ERROR, err.Error())
+}
+
+func (server *CatalogServer) writeError(writer http.ResponseWriter, code int, errorType string, message string) {
+	server.writeJson(writer, code, map[string]CatalogErrorModel{
+		"error": {Message: message, Type: errorType, Code: code},
+	})
+}
+
+func (server *CatalogServer) writeJson(writer http.ResponseWriter, code int, response interface{}) {
+	responseJson, err := json.Marshal(response)
+	if err != nil {
+		server.writeServerError(writer, err)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(code)
+	_, err = writer.Write(responseJson)
+	if err != nil {
+		LogWarn(server.config, "Iceberg REST catalog: failed to write the response:", err)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCatalogServer(t *testing.T) {
+	config := loadTestConfig()
+	icebergWriter := NewIcebergWriter(config)
+	icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+	catalogServer := NewCatalogServer(config, NewIcebergReader(config))
+
+	t.Cleanup(func() {
+		icebergWriter.storage.DeleteSchema(TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema)
+	})
+
+	t.Run("Returns the catalog config", func(t *testing.T) {
+		response := testCatalogRequest(t, catalogServer, "GET", "/v1/config", http.StatusOK)
+
+		if _, found := response["defaults"]; !found {
+			t.Errorf("Expected defaults in the config, got %v", response)
+		}
+	})
+
+	t.Run("Lists schemas as namespaces", func(t *testing.T) {
+		response := testCatalogRequest(t, catalogServer, "GET", "/v1/namespaces", http.StatusOK)
+
+		namespaces := response["namespaces"].([]interface{})
+		if !slices.ContainsFunc(namespaces, func(namespace interface{}) bool {
+			return namespace.([]interface{})[0] == TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema
+		}) {
+			t.Errorf("Expected namespaces to contain %s, got %v", TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema, namespaces)
+		}
+	})
+
+	t.Run("Lists tables of a namespace", func(t *testing.T) {
+		response := testCatalogRequest(t, catalogServer, "GET", "/v1/namespaces/"+TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema+"/tables", http.StatusOK)
+
+		identifiers, _ := json.Marshal(response["identifiers"])
+		if string(identifiers) != `[{"name":"test_table","namespace":["iceberg_writer_test"]}]` {
+			t.Errorf("Expected the test table identifier, got %s", identifiers)
+		}
+	})
+
+	t.Run("Loads a table with its current metadata", func(t *testing.T) {
+		response := testCatalogRequest(t, catalogServer, "GET", "/v1/namespaces/"+TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema+"/tables/"+TEST_ICEBERG_WRITER_SCHEMA_TABLE.Table, http.StatusOK)
+
+		metadataLocation := response["metadata-location"].(string)
+		if !strings.HasSuffix(metadataLocation, "/metadata/v1.metadata.json") {
+			t.Errorf("Expected the current metadata location, got %s", metadataLocation)
+		}
+		metadata := response["metadata"].(map[string]interface{})
+		if metadata["format-version"] != float64(2) || metadata["current-snapshot-id"] == nil {
+			t.Errorf("Expected the table metadata, got %v", metadata)
+		}
+	})
+
+	t.Run("Checks whether a namespace and a table exist", func(t *testing.T) {
+		testCatalogRequest(t, catalogServer, "HEAD", "/v1/namespaces/"+TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema, http.StatusNoContent)
+		testCatalogRequest(t, catalogServer, "HEAD", "/v1/namespaces/"+TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema+"/tables/"+TEST_ICEBERG_WRITER_SCHEMA_TABLE.Table, http.StatusNoContent)
+		testCatalogRequest(t, catalogServer, "HEAD", "/v1/namespaces/"+TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema+"/tables/non_existent", http.StatusNotFound)
+	})
+
+	t.Run("Returns errors for non-existent namespaces and tables", func(t *testing.T) {
+		response := testCatalogRequest(t, catalogServer, "GET", "/v1/namespaces/non_existent", http.StatusNotFound)
+		testCatalogError(t, response, "NoSuchNamespaceException")
+
+		response = testCatalogRequest(t, catalogServer, "GET", "/v1/namespaces/"+TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema+"/tables/non_existent", http.StatusNotFound)
+		testCatalogError(t, response, "NoSuchTableException")
+	})
+
+	t.Run("Rejects write operations", func(t *testing.T) {
+		response := testCatalogRequest(t, catalogServer, "DELETE", "/v1/namespaces/"+TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema+"/tables/"+TEST_ICEBERG_WRITER_SCHEMA_TABLE.Table, http.StatusNotAcceptable)
+		testCatalogError(t, response, "UnsupportedOperationException")
+	})
+
+	t.Run("Requires the bearer token if set", func(t *testing.T) {
+		config.Catalog.Token = "secret"
+		defer func() { config.Catalog.Token = "" }()
+
+		response := testCatalogRequest(t, catalogServer, "GET", "/v1/config", http.StatusUnauthorized)
+		testCatalogError(t, response, "NotAuthorizedException")
+
+		request := httptest.NewRequest("GET", "/v1/config", nil)
+		request.Header.Set("Authorization", "Bearer secret")
+		recorder := httptest.NewRecorder()
+		catalogServer.Handler().ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusOK {
+			t.Errorf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+		}
+	})
+}
+
+func testCatalogRequest(t *testing.T, catalogServer *CatalogServer, method string, path string, expectedStatus int) map[string]interface{} {
+	recorder := httptest.NewRecorder()
+	catalogServer.Handler().ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+
+	if recorder.Code != expectedStatus {
+		t.Fatalf("Expected status %d for %s %s, got %d: %s", expectedStatus, method, path, recorder.Code, recorder.Body.String())
+	}
+	if method == "HEAD" {
+		return nil
+	}
+
+	var response map[string]interface{}
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("Expected a JSON response for %s %s, got %s", method, path, recorder.Body.String())
+	}
+	return response
+}
+
+func testCatalogError(t *testing.T, response map[string]interface{}, expectedType string) {
+	errorModel := response["error"].(map[string]interface{})
+	if errorModel["type"] != expectedType {
+		t.Errorf("Expected error type %s, got %v", expectedType, errorModel["type"])
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"slices"
 	"strings"
+	"time"
 )
 
 const (
@@ -19,6 +20,11 @@ const (
 	ENV_STORAGE_PATH      = "BEMIDB_STORAGE_PATH"
 	ENV_LOG_LEVEL         = "BEMIDB_LOG_LEVEL"
 	ENV_STORAGE_TYPE      = "BEMIDB_STORAGE_TYPE"
+	ENV_CATALOG_TYPE      = "BEMIDB_CATALOG_TYPE"
+	ENV_CATALOG_URI       = "BEMIDB_CATALOG_URI"
+	ENV_CATALOG_WAREHOUSE = "BEMIDB_CATALOG_WAREHOUSE"
+	ENV_CATALOG_PORT      = "BEMIDB_CATALOG_PORT"
+	ENV_CATALOG_TOKEN     = "BEMIDB_CATALOG_TOKEN"
 
 	ENV_AWS_REGION            = "AWS_REGION"
 	ENV_AWS_S3_ENDPOINT       = "AWS_S3_ENDPOINT"
@@ -26,12 +32,43 @@ const (
 	ENV_AWS_ACCESS_KEY_ID     = "AWS_ACCESS_KEY_ID"
 	ENV_AWS_SECRET_ACCESS_KEY = "AWS_SECRET_ACCESS_KEY"
 
+	ENV_AWS_S3_FORCE_PATH_STYLE       = "AWS_S3_FORCE_PATH_STYLE"
+	ENV_AWS_S3_SERVER_SIDE_ENCRYPTION = "AWS_S3_SERVER_SIDE_ENCRYPTION"
+	ENV_AWS_S3_SSE_KMS_KEY_ID         = "AWS_S3_SSE_KMS_KEY_ID"
+	ENV_AWS_S3_UPLOAD_PART_SIZE_MB    = "AWS_S3_UPLOAD_PART_SIZE_MB"
+	ENV_AWS_S3_UPLOAD_CONCURRENCY     = "AWS_S3_UPLOAD_CONCURRENCY"
+	ENV_AWS_S3_MAX_ATTEMPTS           = "AWS_S3_MAX_ATTEMPTS"
+	ENV_AWS_S3_MAX_BACKOFF            = "AWS_S3_MAX_BACKOFF"
+
+	ENV_GCS_BUCKET           = "GCS_BUCKET"
+	ENV_GCS_ENDPOINT         = "GCS_ENDPOINT"
+	ENV_GCS_CREDENTIALS_FILE = "GOOGLE_APPLICATION_CREDENTIALS"
+	ENV_GCS_HMAC_ACCESS_ID   = "GCS_HMAC_ACCESS_ID"
+	ENV_GCS_HMAC_SECRET      = "GCS_HMAC_SECRET"
+	ENV_GCS_ANONYMOUS        = "GCS_ANONYMOUS"
+
+	ENV_AZURE_STORAGE_CONTAINER         = "AZURE_STORAGE_CONTAINER"
+	ENV_AZURE_STORAGE_CONNECTION_STRING = "AZURE_STORAGE_CONNECTION_STRING"
+	ENV_AZURE_STORAGE_ACCOUNT           = "AZURE_STORAGE_ACCOUNT"
+	ENV_AZURE_STORAGE_SAS_TOKEN         = "AZURE_STORAGE_SAS_TOKEN"
+
 	ENV_PG_DATABASE_URL                   = "PG_DATABASE_URL"
 	ENV_PG_SYNC_INTERVAL                  = "PG_SYNC_INTERVAL"
 	ENV_PG_SCHEMA_PREFIX                  = "PG_SCHEMA_PREFIX"
 	ENV_PG_INCLUDE_TABLES                 = "PG_INCLUDE_TABLES"
 	ENV_PG_EXCLUDE_TABLES                 = "PG_EXCLUDE_TABLES"
+	ENV_PG_INCLUDE_VIEWS                  = "PG_INCLUDE_VIEWS"
+	ENV_PG_MERGE_PARTITIONS               = "PG_MERGE_PARTITIONS"
 	ENV_PG_INCREMENTALLY_REFRESHED_TABLES = "PG_INCREMENTALLY_REFRESHED_TABLES"
+	ENV_PG_INCREMENTAL_UPDATE_MODE        = "PG_INCREMENTAL_UPDATE_MODE"
+	ENV_PG_PARALLEL_COPY_WORKERS          = "PG_PARALLEL_COPY_WORKERS"
+	ENV_PG_SYNC_CONFIG                    = "PG_SYNC_CONFIG"
+	ENV_PG_COLUMN_HASH_SALT               = "PG_COLUMN_HASH_SALT"
+	ENV_PG_SYNC_RESTORE_POINTS            = "PG_SYNC_RESTORE_POINTS"
+
+	ENV_COMPACTION_TARGET_FILE_SIZE_MB = "COMPACTION_TARGET_FILE_SIZE_MB"
+	ENV_COMPACTION_MIN_FILES           = "COMPACTION_MIN_FILES"
+	ENV_COMPACTION_MAX_DELETE_FILES    = "COMPACTION_MAX_DELETE_FILES"
 
 	ENV_DISABLE_ANONYMOUS_ANALYTICS = "DISABLE_ANONYMOUS_ANALYTICS"
 
@@ -44,28 +81,183 @@ const (
 	DEFAULT_STORAGE_PATH      = "iceberg"
 	DEFAULT_LOG_LEVEL         = "INFO"
 	DEFAULT_DB_STORAGE_TYPE   = "LOCAL"
+	DEFAULT_CATALOG_TYPE      = "FILESYSTEM"
+	DEFAULT_CATALOG_PORT      = "8181"
+
+	DEFAULT_AWS_S3_ENDPOINT            = "s3.amazonaws.com"
+	DEFAULT_AWS_S3_UPLOAD_PART_SIZE_MB = 5
+	DEFAULT_AWS_S3_UPLOAD_CONCURRENCY  = 5
+	DEFAULT_AWS_S3_MAX_ATTEMPTS        = 5
+	DEFAULT_AWS_S3_MAX_BACKOFF         = "20s"
+
+	MIN_AWS_S3_UPLOAD_PART_SIZE_MB = 5 // S3 rejects smaller parts of multipart uploads
+
+	DEFAULT_GCS_ENDPOINT = "https://storage.googleapis.com"
+
+	DEFAULT_PG_PARALLEL_COPY_WORKERS   = 1
+	DEFAULT_PG_INCREMENTAL_UPDATE_MODE = PG_INCREMENTAL_UPDATE_MODE_COPY_ON_WRITE
 
-	DEFAULT_AWS_S3_ENDPOINT = "s3.amazonaws.com"
+	DEFAULT_COMPACTION_TARGET_FILE_SIZE_MB = 128
+	DEFAULT_COMPACTION_MAX_DELETE_FILES    = 10
+
+	DEFAULT_MAINTENANCE_OLDER_THAN  = "7d"
+	DEFAULT_MAINTENANCE_RETAIN_LAST = 1
 
 	STORAGE_TYPE_LOCAL = "LOCAL"
 	STORAGE_TYPE_S3    = "S3"
+	STORAGE_TYPE_GCS   = "GCS"
+	STORAGE_TYPE_AZURE = "AZURE"
+
+	AWS_S3_SERVER_SIDE_ENCRYPTION_S3  = "AES256"
+	AWS_S3_SERVER_SIDE_ENCRYPTION_KMS = "aws:kms"
+
+	CATALOG_TYPE_FILESYSTEM = "FILESYSTEM"
+	CATALOG_TYPE_REST       = "REST"
+
+	PG_INCREMENTAL_UPDATE_MODE_COPY_ON_WRITE = "copy-on-write"
+	PG_INCREMENTAL_UPDATE_MODE_MERGE_ON_READ = "merge-on-read"
 )
 
+var AWS_S3_SERVER_SIDE_ENCRYPTIONS = []string{AWS_S3_SERVER_SIDE_ENCRYPTION_S3, AWS_S3_SERVER_SIDE_ENCRYPTION_KMS}
+
+var CATALOG_TYPES = []string{CATALOG_TYPE_FILESYSTEM, CATALOG_TYPE_REST}
+
+var PG_INCREMENTAL_UPDATE_MODES = []string{PG_INCREMENTAL_UPDATE_MODE_COPY_ON_WRITE, PG_INCREMENTAL_UPDATE_MODE_MERGE_ON_READ}
+
 type AwsConfig struct {
-	Region          string
-	S3Endpoint      string // optional
-	S3Bucket        string
-	AccessKeyId     string
-	SecretAccessKey string
+	Region                 string
+	S3Endpoint             string // optional, host without the scheme
+	S3UseSsl               bool   // false for http:// endpoints, e.g. a local MinIO
+	S3Bucket               string
+	AccessKeyId            string
+	SecretAccessKey        string
+	S3ForcePathStyle       bool   // optional, for S3-compatible storage like MinIO and Ceph
+	S3ServerSideEncryption string // optional, AES256 (SSE-S3) or aws:kms (SSE-KMS)
+	S3SseKmsKeyId          string // optional, with aws:kms
+	S3UploadPartSize       int64
+	S3UploadConcurrency    int
+	S3MaxAttempts          int
+	S3MaxBackoff           time.Duration
+}
+
+func (awsConfig AwsConfig) S3EndpointUrl() string {
+	if awsConfig.S3UseSsl {
+		return "https://" + awsConfig.S3Endpoint
+	}
+	return "http://" + awsConfig.S3Endpoint
+}
+
+type GcsConfig struct {
+	Bucket          string
+	Endpoint        string // optional, e.g. a local fake-gcs-server
+	CredentialsFile string // optional, service account key file. Default: the service account of the workload
+	HmacAccessId    string // optional, HMAC key DuckDB reads gs:// paths with. Default: the access token of the storage credentials
+	HmacSecret      string
+	Anonymous       bool // optional, sends requests without authorization, e.g. to a local fake-gcs-server
+}
+
+type AzureConfig struct {
+	Container        string
+	ConnectionString string // optional, with an account key or a SAS token
+	AccountName      string // optional, with a SAS token or the managed identity of the workload
+	SasToken         string // optional
+}
+
+// Options of the Iceberg catalog tables are committed to, and of the Iceberg REST catalog server
+type CatalogConfig struct {
+	Type      string
+	Uri       string // Required with the REST catalog type
+	Warehouse string // optional
+	Port      string
+	Token     string // optional, sent to the REST catalog and required from clients of the catalog server as a bearer token
+}
+
+type CompactionConfig struct {
+	TargetFileSize     int64 // In bytes
+	MinFileCount       int   // optional, compacts tables after incremental syncs if set
+	MaxDeleteFileCount int   // Number of delete files in a table that triggers rewriting the data files with deleted rows
+}
+
+// Options of the maintenance commands, passed after the command name
+type MaintenanceConfig struct {
+	OlderThan  time.Duration
+	RetainLast int
+	DryRun     bool
+}
+
+// Options of the table commands, passed after the command name
+type TableCommandConfig struct {
+	SchemaTable IcebergSchemaTable
+	RefName     string    // Tag or branch name
+	SnapshotId  int64     // optional
+	Timestamp   time.Time // optional
 }
 
 type PgConfig struct {
 	DatabaseUrl                  string
-	SyncInterval                 string   // optional
-	SchemaPrefix                 string   // optional
-	IncludeTables                []string // optional
-	ExcludeTables                []string // optional
-	IncrementallyRefreshedTables []string // optional
+	SyncInterval                 string            // optional
+	SchemaPrefix                 string            // optional
+	IncludeTables                []string          // optional
+	ExcludeTables                []string          // optional
+	IncludeViews                 []string          // optional
+	MergedPartitionedTables      []string          // optional
+	IncrementallyRefreshedTables []string          // optional
+	IncrementalUpdateMode        string            // optional
+	ParallelCopyWorkers          int               // optional
+	SyncTables                   []SyncTableConfig // optional
+	ColumnHashSalt               string            // optional
+	SyncRestorePoints            int               // optional, number of snapshots tagged after syncs to keep per table
+}
+
+// Returns the first table config in the sync config file matching the table, or nil
+func (pgConfig *PgConfig) SyncTableConfig(pgSchemaTable PgSchemaTable) *SyncTableConfig {
+	for i, syncTableConfig := range pgConfig.SyncTables {
+		if HasExactOrWildcardMatch([]string{syncTableConfig.Table}, pgSchemaTable.ToConfigArg()) {
+			return &pgConfig.SyncTables[i]
+		}
+	}
+	return nil
+}
+
+// Returns the row filter from the sync config file, or an empty string
+func (pgConfig *PgConfig) RowFilter(pgSchemaTable PgSchemaTable) string {
+	syncTableConfig := pgConfig.SyncTableConfig(pgSchemaTable)
+	if syncTableConfig == nil {
+		return ""
+	}
+	return syncTableConfig.Where
+}
+
+// Returns the Iceberg partition expressions from the sync config file, or nil
+func (pgConfig *PgConfig) PartitionBy(pgSchemaTable PgSchemaTable) []string {
+	syncTableConfig := pgConfig.SyncTableConfig(pgSchemaTable)
+	if syncTableConfig == nil {
+		return nil
+	}
+	return syncTableConfig.PartitionBy
+}
+
+// Returns the sort expressions from the sync config file, or nil
+func (pgConfig *PgConfig) SortBy(pgSchemaTable PgSchemaTable) []string {
+	syncTableConfig := pgConfig.SyncTableConfig(pgSchemaTable)
+	if syncTableConfig == nil {
+		return nil
+	}
+	return syncTableConfig.SortBy
+}
+
+// Leaf partitions of merged partitioned tables are synced into a single table named after the partitioned table
+func (pgConfig *PgConfig) IsMergedPartitionedTable(schemaTable string) bool {
+	return pgConfig.MergedPartitionedTables != nil && HasExactOrWildcardMatch(pgConfig.MergedPartitionedTables, schemaTable)
+}
+
+func (pgConfig *PgConfig) HasSyncSchedules() bool {
+	for _, syncTableConfig := range pgConfig.SyncTables {
+		if syncTableConfig.HasSchedule() {
+			return true
+		}
+	}
+	return false
 }
 
 type Config struct {
@@ -79,16 +271,32 @@ type Config struct {
 	StorageType               string
 	StoragePath               string
 	Aws                       AwsConfig
+	Gcs                       GcsConfig
+	Azure                     AzureConfig
 	Pg                        PgConfig
+	Compaction                CompactionConfig
+	Catalog                   CatalogConfig
 	DisableAnonymousAnalytics bool
 	Version                   string
 }
 
 type configParseValues struct {
 	password                       string
+	awsS3UploadPartSizeMb          string
+	awsS3UploadConcurrency         string
+	awsS3MaxAttempts               string
+	awsS3MaxBackoff                string
 	pgIncludeTables                string
 	pgExcludeTables                string
+	pgIncludeViews                 string
+	pgMergePartitions              string
 	pgIncrementallyRefreshedTables string
+	pgParallelCopyWorkers          string
+	pgSyncConfig                   string
+	pgSyncRestorePoints            string
+	compactionTargetFileSizeMb     string
+	compactionMinFiles             string
+	compactionMaxDeleteFiles       string
 }
 
 var _config = Config{Version: VERSION}
@@ -107,18 +315,50 @@ func registerFlags() {
 	flag.StringVar(&_config.StoragePath, "storage-path", os.Getenv(ENV_STORAGE_PATH), "Path to the storage folder. Default: \""+DEFAULT_STORAGE_PATH+"\"")
 	flag.StringVar(&_config.InitSqlFilepath, "init-sql", os.Getenv(ENV_INIT_SQL_FILEPATH), "Path to the initialization SQL file. Default: \""+DEFAULT_INIT_SQL_FILEPATH+"\"")
 	flag.StringVar(&_config.LogLevel, "log-level", os.Getenv(ENV_LOG_LEVEL), "Log level: \"ERROR\", \"WARN\", \"INFO\", \"DEBUG\", \"TRACE\". Default: \""+DEFAULT_LOG_LEVEL+"\"")
-	flag.StringVar(&_config.StorageType, "storage-type", os.Getenv(ENV_STORAGE_TYPE), "Storage type: \"LOCAL\", \"S3\". Default: \""+DEFAULT_DB_STORAGE_TYPE+"\"")
+	flag.StringVar(&_config.StorageType, "storage-type", os.Getenv(ENV_STORAGE_TYPE), "Storage type: \"LOCAL\", \"S3\", \"GCS\", \"AZURE\". Default: \""+DEFAULT_DB_STORAGE_TYPE+"\"")
 	flag.StringVar(&_config.Pg.SchemaPrefix, "pg-schema-prefix", os.Getenv(ENV_PG_SCHEMA_PREFIX), "(Optional) Prefix for PostgreSQL schema names")
 	flag.StringVar(&_config.Pg.SyncInterval, "pg-sync-interval", os.Getenv(ENV_PG_SYNC_INTERVAL), "(Optional) Interval between syncs. Valid units: \"ns\", \"us\" (or \"µs\"), \"ms\", \"s\", \"m\", \"h\"")
 	flag.StringVar(&_configParseValues.pgIncludeTables, "pg-include-tables", os.Getenv(ENV_PG_INCLUDE_TABLES), "(Optional) Comma-separated list of tables to include in sync (format: schema.table)")
 	flag.StringVar(&_configParseValues.pgExcludeTables, "pg-exclude-tables", os.Getenv(ENV_PG_EXCLUDE_TABLES), "(Optional) Comma-separated list of tables to exclude from sync (format: schema.table)")
+	flag.StringVar(&_configParseValues.pgIncludeViews, "pg-include-views", os.Getenv(ENV_PG_INCLUDE_VIEWS), "(Optional) Comma-separated list of views and materialized views to sync (format: schema.view)")
+	flag.StringVar(&_configParseValues.pgMergePartitions, "pg-merge-partitions", os.Getenv(ENV_PG_MERGE_PARTITIONS), "(Optional) Comma-separated list of partitioned tables to sync with all their partitions into a single table each (format: schema.table)")
 	flag.StringVar(&_configParseValues.pgIncrementallyRefreshedTables, "pg-incrementally-refreshed-tables", os.Getenv(ENV_PG_INCREMENTALLY_REFRESHED_TABLES), "(Optional) Comma-separated list of tables to refresh incrementally (format: schema.table)")
+	flag.StringVar(&_config.Pg.IncrementalUpdateMode, "pg-incremental-update-mode", os.Getenv(ENV_PG_INCREMENTAL_UPDATE_MODE), "(Optional) How incremental refreshes apply updated rows: \""+PG_INCREMENTAL_UPDATE_MODE_COPY_ON_WRITE+"\" rewrites data files, \""+PG_INCREMENTAL_UPDATE_MODE_MERGE_ON_READ+"\" writes delete files. Default: \""+DEFAULT_PG_INCREMENTAL_UPDATE_MODE+"\"")
+	flag.StringVar(&_configParseValues.pgParallelCopyWorkers, "pg-parallel-copy-workers", os.Getenv(ENV_PG_PARALLEL_COPY_WORKERS), "(Optional) Number of parallel COPY workers used to full-refresh a single large table. Default: \""+IntToString(DEFAULT_PG_PARALLEL_COPY_WORKERS)+"\"")
+	flag.StringVar(&_configParseValues.pgSyncConfig, "pg-sync-config", os.Getenv(ENV_PG_SYNC_CONFIG), "(Optional) Path to a JSON file with per-table sync schedules, modes, priorities, and maintenance windows")
+	flag.StringVar(&_configParseValues.pgSyncRestorePoints, "pg-sync-restore-points", os.Getenv(ENV_PG_SYNC_RESTORE_POINTS), "(Optional) Number of restore points to keep per table by tagging the snapshot of each sync, see the table rollback command")
+	flag.StringVar(&_config.Pg.ColumnHashSalt, "pg-column-hash-salt", os.Getenv(ENV_PG_COLUMN_HASH_SALT), "(Optional) Secret salt for columns hashed during sync according to the sync config file")
 	flag.StringVar(&_config.Pg.DatabaseUrl, "pg-database-url", os.Getenv(ENV_PG_DATABASE_URL), "PostgreSQL database URL to sync")
 	flag.StringVar(&_config.Aws.Region, "aws-region", os.Getenv(ENV_AWS_REGION), "AWS region")
 	flag.StringVar(&_config.Aws.S3Endpoint, "aws-s3-endpoint", os.Getenv(ENV_AWS_S3_ENDPOINT), "AWS S3 endpoint. Default: \""+DEFAULT_AWS_S3_ENDPOINT+"\"")
 	flag.StringVar(&_config.Aws.S3Bucket, "aws-s3-bucket", os.Getenv(ENV_AWS_S3_BUCKET), "AWS S3 bucket name")
 	flag.StringVar(&_config.Aws.AccessKeyId, "aws-access-key-id", os.Getenv(ENV_AWS_ACCESS_KEY_ID), "AWS access key ID")
 	flag.StringVar(&_config.Aws.SecretAccessKey, "aws-secret-access-key", os.Getenv(ENV_AWS_SECRET_ACCESS_KEY), "AWS secret access key")
+	flag.BoolVar(&_config.Aws.S3ForcePathStyle, "aws-s3-force-path-style", os.Getenv(ENV_AWS_S3_FORCE_PATH_STYLE) == "true", "(Optional) Use path-style S3 URLs (endpoint/bucket/key), e.g. for MinIO or Ceph")
+	flag.StringVar(&_config.Aws.S3ServerSideEncryption, "aws-s3-server-side-encryption", os.Getenv(ENV_AWS_S3_SERVER_SIDE_ENCRYPTION), "(Optional) Server-side encryption of written S3 objects: \""+AWS_S3_SERVER_SIDE_ENCRYPTION_S3+"\" (SSE-S3), \""+AWS_S3_SERVER_SIDE_ENCRYPTION_KMS+"\" (SSE-KMS)")
+	flag.StringVar(&_config.Aws.S3SseKmsKeyId, "aws-s3-sse-kms-key-id", os.Getenv(ENV_AWS_S3_SSE_KMS_KEY_ID), "(Optional) KMS key ID for SSE-KMS. Default: the AWS managed key")
+	flag.StringVar(&_configParseValues.awsS3UploadPartSizeMb, "aws-s3-upload-part-size-mb", os.Getenv(ENV_AWS_S3_UPLOAD_PART_SIZE_MB), "Part size of multipart S3 uploads in megabytes. Default: \""+IntToString(DEFAULT_AWS_S3_UPLOAD_PART_SIZE_MB)+"\"")
+	flag.StringVar(&_configParseValues.awsS3UploadConcurrency, "aws-s3-upload-concurrency", os.Getenv(ENV_AWS_S3_UPLOAD_CONCURRENCY), "Number of parts uploaded in parallel per multipart S3 upload. Default: \""+IntToString(DEFAULT_AWS_S3_UPLOAD_CONCURRENCY)+"\"")
+	flag.StringVar(&_configParseValues.awsS3MaxAttempts, "aws-s3-max-attempts", os.Getenv(ENV_AWS_S3_MAX_ATTEMPTS), "Max number of attempts per S3 request, including retries of throttled and failed requests. Default: \""+IntToString(DEFAULT_AWS_S3_MAX_ATTEMPTS)+"\"")
+	flag.StringVar(&_configParseValues.awsS3MaxBackoff, "aws-s3-max-backoff", os.Getenv(ENV_AWS_S3_MAX_BACKOFF), "Max delay between retries of an S3 request. Default: \""+DEFAULT_AWS_S3_MAX_BACKOFF+"\"")
+	flag.StringVar(&_config.Gcs.Bucket, "gcs-bucket", os.Getenv(ENV_GCS_BUCKET), "GCS bucket name")
+	flag.StringVar(&_config.Gcs.Endpoint, "gcs-endpoint", os.Getenv(ENV_GCS_ENDPOINT), "(Optional) GCS endpoint, e.g. a local fake-gcs-server. Default: \""+DEFAULT_GCS_ENDPOINT+"\"")
+	flag.StringVar(&_config.Gcs.CredentialsFile, "gcs-credentials-file", os.Getenv(ENV_GCS_CREDENTIALS_FILE), "(Optional) Path to a GCS service account key file. Default: the service account of the workload, e.g. with GKE workload identity")
+	flag.StringVar(&_config.Gcs.HmacAccessId, "gcs-hmac-access-id", os.Getenv(ENV_GCS_HMAC_ACCESS_ID), "(Optional) Access ID of the GCS HMAC key used by DuckDB to read data. Default: the access token of the service account")
+	flag.StringVar(&_config.Gcs.HmacSecret, "gcs-hmac-secret", os.Getenv(ENV_GCS_HMAC_SECRET), "(Optional) Secret of the GCS HMAC key used by DuckDB to read data")
+	flag.BoolVar(&_config.Gcs.Anonymous, "gcs-anonymous", os.Getenv(ENV_GCS_ANONYMOUS) == "true", "(Optional) Send GCS requests without authorization, e.g. to a local fake-gcs-server")
+	flag.StringVar(&_config.Azure.Container, "azure-storage-container", os.Getenv(ENV_AZURE_STORAGE_CONTAINER), "Azure Blob Storage container name")
+	flag.StringVar(&_config.Azure.ConnectionString, "azure-storage-connection-string", os.Getenv(ENV_AZURE_STORAGE_CONNECTION_STRING), "(Optional) Azure Storage connection string with an account key or a SAS token")
+	flag.StringVar(&_config.Azure.AccountName, "azure-storage-account", os.Getenv(ENV_AZURE_STORAGE_ACCOUNT), "(Optional) Azure Storage account name, authenticated with a SAS token or the managed identity of the workload")
+	flag.StringVar(&_config.Azure.SasToken, "azure-storage-sas-token", os.Getenv(ENV_AZURE_STORAGE_SAS_TOKEN), "(Optional) Azure Storage SAS token")
+	flag.StringVar(&_configParseValues.compactionTargetFileSizeMb, "compaction-target-file-size-mb", os.Getenv(ENV_COMPACTION_TARGET_FILE_SIZE_MB), "Target size of data files combined by compaction in megabytes. Default: \""+IntToString(DEFAULT_COMPACTION_TARGET_FILE_SIZE_MB)+"\"")
+	flag.StringVar(&_configParseValues.compactionMinFiles, "compaction-min-files", os.Getenv(ENV_COMPACTION_MIN_FILES), "(Optional) Number of small data files in a table that triggers compaction after an incremental sync")
+	flag.StringVar(&_configParseValues.compactionMaxDeleteFiles, "compaction-max-delete-files", os.Getenv(ENV_COMPACTION_MAX_DELETE_FILES), "Number of delete files in a table that triggers rewriting its data files with deleted rows by the compact command. Default: \""+IntToString(DEFAULT_COMPACTION_MAX_DELETE_FILES)+"\"")
+	flag.StringVar(&_config.Catalog.Type, "catalog-type", os.Getenv(ENV_CATALOG_TYPE), "Catalog type: \"FILESYSTEM\", \"REST\". Default: \""+DEFAULT_CATALOG_TYPE+"\"")
+	flag.StringVar(&_config.Catalog.Uri, "catalog-uri", os.Getenv(ENV_CATALOG_URI), "URI of the Iceberg REST catalog, e.g. \"http://localhost:8181\"")
+	flag.StringVar(&_config.Catalog.Warehouse, "catalog-warehouse", os.Getenv(ENV_CATALOG_WAREHOUSE), "(Optional) Warehouse of the Iceberg REST catalog")
+	flag.StringVar(&_config.Catalog.Port, "catalog-port", os.Getenv(ENV_CATALOG_PORT), "Port for the Iceberg REST catalog server to listen on. Default: \""+DEFAULT_CATALOG_PORT+"\"")
+	flag.StringVar(&_config.Catalog.Token, "catalog-token", os.Getenv(ENV_CATALOG_TOKEN), "(Optional) Bearer token sent to the Iceberg REST catalog and required by the Iceberg REST catalog server")
 	flag.BoolVar(&_config.DisableAnonymousAnalytics, "disable-anonymous-analytics", os.Getenv(ENV_DISABLE_ANONYMOUS_ANALYTICS) == "true", "Disable anonymous analytics collection")
 }
 
@@ -131,6 +371,9 @@ func parseFlags() {
 	if _config.Port == "" {
 		_config.Port = DEFAULT_PORT
 	}
+	if _config.Catalog.Port == "" {
+		_config.Catalog.Port = DEFAULT_CATALOG_PORT
+	}
 	if _config.Database == "" {
 		_config.Database = DEFAULT_DATABASE
 	}
@@ -170,6 +413,9 @@ func parseFlags() {
 		if _config.Aws.S3Endpoint == "" {
 			_config.Aws.S3Endpoint = DEFAULT_AWS_S3_ENDPOINT
 		}
+		endpoint, isHttp := strings.CutPrefix(_config.Aws.S3Endpoint, "http://")
+		_config.Aws.S3Endpoint = strings.TrimSuffix(strings.TrimPrefix(endpoint, "https://"), "/")
+		_config.Aws.S3UseSsl = !isHttp
 		if _config.Aws.S3Bucket == "" {
 			panic("AWS S3 bucket name is required")
 		}
@@ -179,6 +425,74 @@ func parseFlags() {
 		if _config.Aws.AccessKeyId == "" && _config.Aws.SecretAccessKey != "" {
 			panic("AWS access key ID is required")
 		}
+		if _config.Aws.S3ServerSideEncryption != "" && !slices.Contains(AWS_S3_SERVER_SIDE_ENCRYPTIONS, _config.Aws.S3ServerSideEncryption) {
+			panic("Invalid S3 server-side encryption " + _config.Aws.S3ServerSideEncryption + ". Must be one of " + strings.Join(AWS_S3_SERVER_SIDE_ENCRYPTIONS, ", "))
+		}
+		if _config.Aws.S3SseKmsKeyId != "" && _config.Aws.S3ServerSideEncryption != AWS_S3_SERVER_SIDE_ENCRYPTION_KMS {
+			panic("S3 SSE-KMS key ID requires the " + AWS_S3_SERVER_SIDE_ENCRYPTION_KMS + " server-side encryption")
+		}
+
+		_config.Aws.S3UploadPartSize = int64(DEFAULT_AWS_S3_UPLOAD_PART_SIZE_MB) * 1024 * 1024
+		if _configParseValues.awsS3UploadPartSizeMb != "" {
+			uploadPartSizeMb, err := StringToInt(_configParseValues.awsS3UploadPartSizeMb)
+			if err != nil || uploadPartSizeMb < MIN_AWS_S3_UPLOAD_PART_SIZE_MB {
+				panic("Invalid S3 upload part size " + _configParseValues.awsS3UploadPartSizeMb + ". Must be an integer of at least " + IntToString(MIN_AWS_S3_UPLOAD_PART_SIZE_MB))
+			}
+			_config.Aws.S3UploadPartSize = int64(uploadPartSizeMb) * 1024 * 1024
+		}
+		_config.Aws.S3UploadConcurrency = DEFAULT_AWS_S3_UPLOAD_CONCURRENCY
+		if _configParseValues.awsS3UploadConcurrency != "" {
+			uploadConcurrency, err := StringToInt(_configParseValues.awsS3UploadConcurrency)
+			if err != nil || uploadConcurrency < 1 {
+				panic("Invalid S3 upload concurrency " + _configParseValues.awsS3UploadConcurrency + ". Must be a positive integer")
+			}
+			_config.Aws.S3UploadConcurrency = uploadConcurrency
+		}
+		_config.Aws.S3MaxAttempts = DEFAULT_AWS_S3_MAX_ATTEMPTS
+		if _configParseValues.awsS3MaxAttempts != "" {
+			maxAttempts, err := StringToInt(_configParseValues.awsS3MaxAttempts)
+			if err != nil || maxAttempts < 1 {
+				panic("Invalid S3 max attempts " + _configParseValues.awsS3MaxAttempts + ". Must be a positive integer")
+			}
+			_config.Aws.S3MaxAttempts = maxAttempts
+		}
+		maxBackoff := _configParseValues.awsS3MaxBackoff
+		if maxBackoff == "" {
+			maxBackoff = DEFAULT_AWS_S3_MAX_BACKOFF
+		}
+		s3MaxBackoff, err := time.ParseDuration(maxBackoff)
+		if err != nil || s3MaxBackoff <= 0 {
+			panic("Invalid S3 max backoff " + maxBackoff + ". Must be a positive duration, e.g. 20s")
+		}
+		_config.Aws.S3MaxBackoff = s3MaxBackoff
+	}
+	if _config.StorageType == STORAGE_TYPE_GCS {
+		if _config.Gcs.Bucket == "" {
+			panic("GCS bucket name is required")
+		}
+		if (_config.Gcs.HmacAccessId == "") != (_config.Gcs.HmacSecret == "") {
+			panic("GCS HMAC access ID and secret must be set together")
+		}
+		if _config.Gcs.Anonymous && (_config.Gcs.CredentialsFile != "" || _config.Gcs.HmacAccessId != "") {
+			panic("GCS anonymous access can't be combined with a credentials file or an HMAC key")
+		}
+		_config.Gcs.Endpoint = strings.TrimSuffix(_config.Gcs.Endpoint, "/")
+	}
+	if _config.StorageType == STORAGE_TYPE_AZURE {
+		if _config.Azure.Container == "" {
+			panic("Azure Storage container name is required")
+		}
+		if _config.Azure.ConnectionString == "" && _config.Azure.AccountName == "" {
+			panic("Azure Storage connection string or account name is required")
+		}
+	}
+	if _config.Catalog.Type == "" {
+		_config.Catalog.Type = DEFAULT_CATALOG_TYPE
+	} else if !slices.Contains(CATALOG_TYPES, _config.Catalog.Type) {
+		panic("Invalid catalog type " + _config.Catalog.Type + ". Must be one of " + strings.Join(CATALOG_TYPES, ", "))
+	}
+	if _config.Catalog.Type == CATALOG_TYPE_REST && _config.Catalog.Uri == "" {
+		panic("Catalog URI is required with the REST catalog type")
 	}
 	if _configParseValues.pgIncludeTables != "" {
 		_config.Pg.IncludeTables = strings.Split(_configParseValues.pgIncludeTables, ",")
@@ -186,13 +500,182 @@ func parseFlags() {
 	if _configParseValues.pgIncrementallyRefreshedTables != "" {
 		_config.Pg.IncrementallyRefreshedTables = strings.Split(_configParseValues.pgIncrementallyRefreshedTables, ",")
 	}
+	if _config.Pg.IncrementalUpdateMode == "" {
+		_config.Pg.IncrementalUpdateMode = DEFAULT_PG_INCREMENTAL_UPDATE_MODE
+	} else if !slices.Contains(PG_INCREMENTAL_UPDATE_MODES, _config.Pg.IncrementalUpdateMode) {
+		panic("Invalid incremental update mode " + _config.Pg.IncrementalUpdateMode + ". Must be one of " + strings.Join(PG_INCREMENTAL_UPDATE_MODES, ", "))
+	}
 	if _configParseValues.pgExcludeTables != "" {
 		_config.Pg.ExcludeTables = strings.Split(_configParseValues.pgExcludeTables, ",")
 	}
+	if _configParseValues.pgIncludeViews != "" {
+		_config.Pg.IncludeViews = strings.Split(_configParseValues.pgIncludeViews, ",")
+	}
+	if _configParseValues.pgMergePartitions != "" {
+		_config.Pg.MergedPartitionedTables = strings.Split(_configParseValues.pgMergePartitions, ",")
+	}
+	if _configParseValues.pgParallelCopyWorkers == "" {
+		_config.Pg.ParallelCopyWorkers = DEFAULT_PG_PARALLEL_COPY_WORKERS
+	} else {
+		parallelCopyWorkers, err := StringToInt(_configParseValues.pgParallelCopyWorkers)
+		if err != nil || parallelCopyWorkers < 1 {
+			panic("Invalid number of parallel COPY workers " + _configParseValues.pgParallelCopyWorkers + ". Must be a positive integer")
+		}
+		_config.Pg.ParallelCopyWorkers = parallelCopyWorkers
+	}
+	if _config.Pg.SyncInterval != "" {
+		syncInterval, err := time.ParseDuration(_config.Pg.SyncInterval)
+		if err != nil || syncInterval <= 0 {
+			panic("Invalid interval format: " + _config.Pg.SyncInterval)
+		}
+	}
+	if _configParseValues.pgSyncRestorePoints != "" {
+		syncRestorePoints, err := StringToInt(_configParseValues.pgSyncRestorePoints)
+		if err != nil || syncRestorePoints < 0 {
+			panic("Invalid number of sync restore points " + _configParseValues.pgSyncRestorePoints + ". Must be a non-negative integer")
+		}
+		_config.Pg.SyncRestorePoints = syncRestorePoints
+	}
+	if _configParseValues.pgSyncConfig != "" {
+		syncTables, err := ParseSyncConfigFile(_configParseValues.pgSyncConfig)
+		if err != nil {
+			panic(err.Error())
+		}
+		_config.Pg.SyncTables = syncTables
+
+		for _, syncTableConfig := range syncTables {
+			if syncTableConfig.HasColumnAction(SYNC_COLUMN_ACTION_HASH) && _config.Pg.ColumnHashSalt == "" {
+				panic("Column hash salt is required to hash columns of " + syncTableConfig.Table)
+			}
+		}
+	}
+
+	if _configParseValues.compactionTargetFileSizeMb == "" {
+		_config.Compaction.TargetFileSize = DEFAULT_COMPACTION_TARGET_FILE_SIZE_MB * 1024 * 1024
+	} else {
+		targetFileSizeMb, err := StringToInt(_configParseValues.compactionTargetFileSizeMb)
+		if err != nil || targetFileSizeMb < 1 {
+			panic("Invalid compaction target file size " + _configParseValues.compactionTargetFileSizeMb + ". Must be a positive integer")
+		}
+		_config.Compaction.TargetFileSize = int64(targetFileSizeMb) * 1024 * 1024
+	}
+	if _configParseValues.compactionMinFiles != "" {
+		minFileCount, err := StringToInt(_configParseValues.compactionMinFiles)
+		if err != nil || minFileCount < 2 {
+			panic("Invalid compaction min files " + _configParseValues.compactionMinFiles + ". Must be an integer greater than 1")
+		}
+		_config.Compaction.MinFileCount = minFileCount
+	}
+	if _configParseValues.compactionMaxDeleteFiles == "" {
+		_config.Compaction.MaxDeleteFileCount = DEFAULT_COMPACTION_MAX_DELETE_FILES
+	} else {
+		maxDeleteFileCount, err := StringToInt(_configParseValues.compactionMaxDeleteFiles)
+		if err != nil || maxDeleteFileCount < 1 {
+			panic("Invalid compaction max delete files " + _configParseValues.compactionMaxDeleteFiles + ". Must be a positive integer")
+		}
+		_config.Compaction.MaxDeleteFileCount = maxDeleteFileCount
+	}
 
 	_configParseValues = configParseValues{}
 }
 
+// Parses the options of a maintenance command, e.g. ["--older-than", "7d", "--retain-last", "10"]
+func LoadMaintenanceConfig(args []string) *MaintenanceConfig {
+	maintenanceConfig := MaintenanceConfig{}
+	var olderThan string
+
+	flagSet := flag.NewFlagSet("maintenance", flag.ContinueOnError)
+	flagSet.StringVar(&olderThan, "older-than", DEFAULT_MAINTENANCE_OLDER_THAN, "Minimum age of expired snapshots and removed orphan files. Valid units: \"d\", \"h\", \"m\", \"s\"")
+	flagSet.IntVar(&maintenanceConfig.RetainLast, "retain-last", DEFAULT_MAINTENANCE_RETAIN_LAST, "Number of most recent snapshots to retain regardless of their age")
+	flagSet.BoolVar(&maintenanceConfig.DryRun, "dry-run", false, "Log the files to delete without changing anything")
+	err := flagSet.Parse(args)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	olderThanDuration, err := parseDurationWithDays(olderThan)
+	if err != nil || olderThanDuration < 0 {
+		panic("Invalid maintenance age " + olderThan + ". Must be a duration, e.g. \"7d\" or \"12h\"")
+	}
+	maintenanceConfig.OlderThan = olderThanDuration
+	if maintenanceConfig.RetainLast < 1 {
+		panic("Invalid number of retained snapshots " + IntToString(maintenanceConfig.RetainLast) + ". Must be a positive integer")
+	}
+
+	return &maintenanceConfig
+}
+
+// Parses the table, the tag or branch name, and the options of a table command, e.g. ["public.orders", "--to-snapshot", "123"]
+func LoadTableCommandConfig(tableCommand string, args []string) *TableCommandConfig {
+	tableCommandConfig := TableCommandConfig{}
+
+	positionalArgCount := 2
+	if tableCommand == TABLE_COMMAND_ROLLBACK {
+		positionalArgCount = 1
+	}
+	if len(args) < positionalArgCount || slices.ContainsFunc(args[:positionalArgCount], func(arg string) bool { return strings.HasPrefix(arg, "-") }) {
+		if positionalArgCount == 1 {
+			panic("Missing table name. Must be passed as schema.table before the options")
+		}
+		panic("Missing table name or tag or branch name. Must be passed as schema.table and name before the options")
+	}
+
+	schema, table, found := strings.Cut(args[0], ".")
+	if !found {
+		schema, table = PG_SCHEMA_PUBLIC, args[0]
+	}
+	tableCommandConfig.SchemaTable = IcebergSchemaTable{Schema: schema, Table: table}
+	if positionalArgCount == 2 {
+		tableCommandConfig.RefName = args[1]
+	}
+
+	var snapshotId, timestamp string
+	flagSet := flag.NewFlagSet("table", flag.ContinueOnError)
+	switch tableCommand {
+	case TABLE_COMMAND_ROLLBACK:
+		flagSet.StringVar(&snapshotId, "to-snapshot", "", "ID of the snapshot to roll back to")
+		flagSet.StringVar(&timestamp, "to-time", "", "Time to roll back to the snapshot that was current at, e.g. \"2025-01-31 12:00:00\". Without a time zone, it is treated as UTC")
+	case TABLE_COMMAND_TAG, TABLE_COMMAND_BRANCH:
+		flagSet.StringVar(&snapshotId, "snapshot", "", "ID of the referenced snapshot. Default: the current snapshot")
+	}
+	err := flagSet.Parse(args[positionalArgCount:])
+	if err != nil {
+		panic(err.Error())
+	}
+
+	if snapshotId != "" {
+		tableCommandConfig.SnapshotId, err = StringToInt64(snapshotId)
+		if err != nil {
+			panic("Invalid snapshot ID " + snapshotId + ". Must be an integer")
+		}
+	}
+	if timestamp != "" {
+		tableCommandConfig.Timestamp, err = parseTimeTravelTimestamp(timestamp)
+		if err != nil {
+			panic("Invalid time " + timestamp + ". Must be a timestamp, e.g. \"2025-01-31 12:00:00\"")
+		}
+	}
+	if tableCommand == TABLE_COMMAND_ROLLBACK && (snapshotId == "") == (timestamp == "") {
+		panic("Either --to-snapshot or --to-time is required")
+	}
+
+	return &tableCommandConfig
+}
+
+// Same as time.ParseDuration with support for days, e.g. "7d"
+func parseDurationWithDays(duration string) (time.Duration, error) {
+	days, isDays := strings.CutSuffix(duration, "d")
+	if !isDays {
+		return time.ParseDuration(duration)
+	}
+
+	dayCount, err := StringToInt(days)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(dayCount) * 24 * time.Hour, nil
+}
+
 func LoadConfig(reRegisterFlags ...bool) *Config {
 	if reRegisterFlags != nil && reRegisterFlags[0] {
 		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Example:
+//
+//	{
+//	  "tables": [
+//	    { "table": "public.events", "interval": "5m", "mode": "incremental", "priority": 10 },
+//	    { "table": "archive.*", "cron": "0 3 * * 0", "maintenance-window": "01:00-05:00" },
+//	    { "table": "public.users", "columns": [{ "column": "ssn", "action": "exclude" }, { "column": "email", "action": "email-domain" }] },
+//	    { "table": "public.logs", "where": "created_at > now() - interval '400 days'" },
+//	    { "table": "public.orders", "partition-by": ["day(created_at)", "bucket[16](customer_id)"] },
+//	    { "table": "public.invoices", "sort-by": ["tenant_id", "created_at DESC"] }
+//	  ]
+//	}
+type SyncConfigFile struct {
+	Tables []SyncTableConfig `json:"tables"`
+}
+
+type SyncTableConfig struct {
+	Table             string             `json:"table"`                        // schema.table, may contain wildcards (*)
+	Interval          string             `json:"interval,omitempty"`           // optional, e.g. "5m"
+	Cron              string             `json:"cron,omitempty"`               // optional, e.g. "0 3 * * 0"
+	Mode              string             `json:"mode,omitempty"`               // optional, "full" or "incremental" ("cdc" is not supported)
+	Priority          int                `json:"priority,omitempty"`           // optional, tables with higher priority are synced first
+	MaintenanceWindow string             `json:"maintenance-window,omitempty"` // optional, e.g. "01:00-05:00" in UTC
+	Columns           []SyncColumnConfig `json:"columns,omitempty"`            // optional
+	Where             string             `json:"where,omitempty"`              // optional, SQL predicate to sync matching rows only
+	PartitionBy       []string           `json:"partition-by,omitempty"`       // optional, Iceberg partition expressions, e.g. "day(created_at)"
+	SortBy            []string           `json:"sort-by,omitempty"`            // optional, sort expressions, e.g. "created_at DESC"
+
+	interval          time.Duration
+	cronSchedule      *CronSchedule
+	maintenanceWindow *MaintenanceWindow
+}
+
+const (
+	SYNC_COLUMN_ACTION_EXCLUDE      = "exclude"
+	SYNC_COLUMN_ACTION_HASH         = "hash"         // Salted SHA-256 hex digest
+	SYNC_COLUMN_ACTION_REDACT       = "redact"       // Replaced with REDACTED
+	SYNC_COLUMN_ACTION_TRUNCATE     = "truncate"     // First "length" characters
+	SYNC_COLUMN_ACTION_EMAIL_DOMAIN = "email-domain" // Part after @
+)
+
+var SYNC_COLUMN_ACTIONS = []string{
+	SYNC_COLUMN_ACTION_EXCLUDE,
+	SYNC_COLUMN_ACTION_HASH,
+	SYNC_COLUMN_ACTION_REDACT,
+	SYNC_COLUMN_ACTION_TRUNCATE,
+	SYNC_COLUMN_ACTION_EMAIL_DOMAIN,
+}
+
+type SyncColumnConfig struct {
+	Column string `json:"column"`
+	Action string `json:"action"`
+	Length int    `json:"length,omitempty"` // Required for truncate
+}
+
+func ParseSyncConfigFile(filePath string) ([]SyncTableConfig, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var syncConfigFile SyncConfigFile
+	err = json.Unmarshal(content, &syncConfigFile)
+	if err != nil {
+		return nil, errors.New("invalid sync config file " + filePath + ": " + err.Error())
+	}
+
+	for i := range syncConfigFile.Tables {
+		err = syncConfigFile.Tables[i].parse()
+		if err != nil {
+			return nil, errors.New("invalid sync config file " + filePath + ": " + err.Error())
+		}
+	}
+
+	return syncConfigFile.Tables, nil
+}
+
+// Returns the next time the table should be synced after the last sync attempt
+func (tableConfig *SyncTableConfig) NextSyncAt(lastAttemptAt time.Time) time.Time {
+	if tableConfig.cronSchedule != nil {
+		return tableConfig.cronSchedule.Next(lastAttemptAt)
+	}
+	return lastAttemptAt.Add(tableConfig.interval)
+}
+
+func (tableConfig *SyncTableConfig) HasSchedule() bool {
+	return tableConfig.cronSchedule != nil || tableConfig.interval > 0
+}
+
+func (tableConfig *SyncTableConfig) HasColumnAction(action string) bool {
+	for _, columnConfig := range tableConfig.Columns {
+		if columnConfig.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+func (tableConfig *SyncTableConfig) parse() (err error) {
+	if tableConfig.Table == "" {
+		return errors.New("table is required")
+	}
+
+	if tableConfig.Interval != "" && tableConfig.Cron != "" {
+		return errors.New("table " + tableConfig.Table + " must have either an interval or a cron schedule, not both")
+	}
+	if tableConfig.Interval != "" {
+		tableConfig.interval, err = time.ParseDuration(tableConfig.Interval)
+		if err != nil || tableConfig.interval <= 0 {
+			return errors.New("invalid interval \"" + tableConfig.Interval + "\" for table " + tableConfig.Table)
+		}
+	}
+	if tableConfig.Cron != "" {
+		tableConfig.cronSchedule, err = NewCronSchedule(tableConfig.Cron)
+		if err != nil {
+			return errors.New("invalid cron schedule \"" + tableConfig.Cron + "\" for table " + tableConfig.Table + ": " + err.Error())
+		}
+	}
+
+	switch tableConfig.Mode {
+	case "", SYNC_MODE_FULL_REFRESH, SYNC_MODE_INCREMENTAL:
+	case SYNC_MODE_CDC:
+		return errors.New("mode " + SYNC_MODE_CDC + " for table " + tableConfig.Table + " is not supported: change data capture with logical replication is not implemented, use " + SYNC_MODE_INCREMENTAL + " with a short interval instead")
+	default:
+		return errors.New("invalid mode \"" + tableConfig.Mode + "\" for table " + tableConfig.Table + ". Must be one of " + SYNC_MODE_FULL_REFRESH + ", " + SYNC_MODE_INCREMENTAL)
+	}
+
+	if tableConfig.MaintenanceWindow != "" {
+		tableConfig.maintenanceWindow, err = NewMaintenanceWindow(tableConfig.MaintenanceWindow)
+		if err != nil {
+			return errors.New("invalid maintenance window \"" + tableConfig.MaintenanceWindow + "\" for table " + tableConfig.Table + ": " + err.Error())
+		}
+	}
+
+	for _, expression := range tableConfig.PartitionBy {
+		_, _, err = ParseIcebergPartitionExpression(expression)
+		if err != nil {
+			return errors.New(err.Error() + " for table " + tableConfig.Table)
+		}
+	}
+	if len(tableConfig.PartitionBy) > 0 && tableConfig.Mode == SYNC_MODE_INCREMENTAL {
+		return errors.New("partitioned table " + tableConfig.Table + " can't be synced incrementally")
+	}
+
+	for _, expression := range tableConfig.SortBy {
+		_, _, _, err = ParseIcebergSortExpression(expression)
+		if err != nil {
+			return errors.New(err.Error() + " for table " + tableConfig.Table)
+		}
+	}
+
+	columns := make(Set[string])
+	for _, columnConfig := range tableConfig.Columns {
+		if columnConfig.Column == "" {
+			return errors.New("column is required for table " + tableConfig.Table)
+		}
+		if columns.Contains(columnConfig.Column) {
+			return errors.New("column " + columnConfig.Column + " is listed more than once for table " + tableConfig.Table)
+		}
+		columns.Add(columnConfig.Column)
+
+		if !slices.Contains(SYNC_COLUMN_ACTIONS, columnConfig.Action) {
+			return errors.New("invalid action \"" + columnConfig.Action + "\" for column " + columnConfig.Column + " of table " + tableConfig.Table + ". Must be one of " + strings.Join(SYNC_COLUMN_ACTIONS, ", "))
+		}
+		if columnConfig.Action == SYNC_COLUMN_ACTION_TRUNCATE && columnConfig.Length < 1 {
+			return errors.New("truncated column " + columnConfig.Column + " of table " + tableConfig.Table + " must have a positive length")
+		}
+	}
+
+	return nil
+}
+
+// Cron schedule with 5 fields in UTC: minute, hour, day of month, month, day of week
+type CronSchedule struct {
+	minutes     Set[int]
+	hours       Set[int]
+	daysOfMonth Set[int]
+	months      Set[int]
+	daysOfWeek  Set[int]
+
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+var CRON_FIELD_RANGES = [][2]int{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 6},  // day of week (0 is Sunday)
+}
+
+func NewCronSchedule(expression string) (*CronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != len(CRON_FIELD_RANGES) {
+		return nil, errors.New("expected 5 fields: minute, hour, day of month, month, day of week")
+	}
+
+	values := make([]Set[int], len(fields))
+	for i, field := range fields {
+		var err error
+		values[i], err = parseCronField(field, CRON_FIELD_RANGES[i][0], CRON_FIELD_RANGES[i][1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &CronSchedule{
+		minutes:       values[0],
+		hours:         values[1],
+		daysOfMonth:   values[2],
+		months:        values[3],
+		daysOfWeek:    values[4],
+		anyDayOfMonth: fields[2] == "*",
+		anyDayOfWeek:  fields[4] == "*",
+	}, nil
+}
+
+// Returns the first matching minute after t
+func (schedule *CronSchedule) Next(t time.Time) time.Time {
+	next := t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := next.AddDate(5, 0, 0) // E.g., "0 0 29 2 *" matches once in 4 years
+
+	for next.Before(limit) {
+		if !schedule.months.Contains(int(next.Month())) {
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !schedule.matchesDay(next) {
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !schedule.hours.Contains(next.Hour()) {
+			next = next.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if !schedule.minutes.Contains(next.Minute()) {
+			next = next.Add(time.Minute)
+			continue
+		}
+		return next
+	}
+
+	return limit
+}
+
+// Like in cron, a day matches either field if both the day of month and the day of week are restricted
+func (schedule *CronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonthMatches := schedule.daysOfMonth.Contains(t.Day())
+	dayOfWeekMatches := schedule.daysOfWeek.Contains(int(t.Weekday()))
+
+	if !schedule.anyDayOfMonth && !schedule.anyDayOfWeek {
+		return dayOfMonthMatches || dayOfWeekMatches
+	}
+	return dayOfMonthMatches && dayOfWeekMatches
+}
+
+// Supports "*", "5", "1-5", "1,3,5", "*/15", and "1-30/5"
+func parseCronField(field string, min int, max int) (Set[int], error) {
+	values := make(Set[int])
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = StringToInt(stepPart)
+			if err != nil || step < 1 {
+				return nil, errors.New("invalid step in \"" + part + "\"")
+			}
+		}
+
+		start, end := min, max
+		if rangePart != "*" {
+			startPart, endPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			start, err = StringToInt(startPart)
+			if err != nil {
+				return nil, errors.New("invalid value in \"" + part + "\"")
+			}
+			end = start
+			if isRange {
+				end, err = StringToInt(endPart)
+				if err != nil {
+					return nil, errors.New("invalid range in \"" + part + "\"")
+				}
+			} else if hasStep {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return nil, errors.New("\"" + part + "\" is out of the " + IntToString(min) + "-" + IntToString(max) + " range")
+		}
+
+		for value := start; value <= end; value += step {
+			values.Add(value)
+		}
+	}
+
+	return values, nil
+}
+
+// Daily time window in UTC, may wrap around midnight (e.g., "22:00-04:00")
+type MaintenanceWindow struct {
+	start time.Duration // Since midnight
+	end   time.Duration // Since midnight
+}
+
+func NewMaintenanceWindow(window string) (*MaintenanceWindow, error) {
+	startPart, endPart, found := strings.Cut(window, "-")
+	if !found {
+		return nil, errors.New("expected the HH:MM-HH:MM format")
+	}
+
+	start, err := parseTimeOfDay(startPart)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseTimeOfDay(endPart)
+	if err != nil {
+		return nil, err
+	}
+	if start == end {
+		return nil, errors.New("start and end must be different")
+	}
+
+	return &MaintenanceWindow{start: start, end: end}, nil
+}
+
+func (window *MaintenanceWindow) Contains(t time.Time) bool {
+	timeOfDay := sinceMidnight(t)
+	if window.start < window.end {
+		return timeOfDay >= window.start && timeOfDay < window.end
+	}
+	return timeOfDay >= window.start || timeOfDay < window.end
+}
+
+// Returns t if it's within the window, otherwise the next start of the window
+func (window *MaintenanceWindow) NextOpenAt(t time.Time) time.Time {
+	if window.Contains(t) {
+		return t
+	}
+
+	t = t.UTC()
+	windowStart := t.Add(window.start - sinceMidnight(t))
+	if windowStart.Before(t) {
+		windowStart = windowStart.AddDate(0, 0, 1)
+	}
+	return windowStart
+}
+
+func parseTimeOfDay(str string) (time.Duration, error) {
+	parsedTime, err := time.Parse("15:04", strings.TrimSpace(str))
+	if err != nil {
+		return 0, errors.New("invalid time \"" + str + "\", expected HH:MM")
+	}
+	return time.Duration(parsedTime.Hour())*time.Hour + time.Duration(parsedTime.Minute())*time.Minute, nil
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	t = t.UTC()
+	return t.Sub(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+}
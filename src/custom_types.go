@@ -126,6 +126,13 @@ type PgSchemaTable struct {
 	Schema                 string
 	Table                  string
 	ParentPartitionedTable string
+	PartitionRoot          string // Top-most partitioned table of a partition (format: schema.table)
+	Kind                   string // pg_class.relkind
+}
+
+// Views and materialized views
+func (pgSchemaTable PgSchemaTable) IsView() bool {
+	return pgSchemaTable.Kind == PG_RELKIND_VIEW || pgSchemaTable.Kind == PG_RELKIND_MATERIALIZED_VIEW
 }
 
 func (pgSchemaTable PgSchemaTable) String() string {
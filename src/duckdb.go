@@ -7,6 +7,7 @@ import (
 	"os"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,6 +17,7 @@ import (
 const (
 	DUCKDB_SCHEMA_MAIN                        = "main"
 	REFRESH_IMPLICIT_AWS_CREDENTIALS_INTERVAL = 10 * time.Minute
+	REFRESH_GCS_ACCESS_TOKEN_INTERVAL         = 10 * time.Minute
 )
 
 var DUCKDB_INIT_BOOT_QUERIES = []string{
@@ -33,9 +35,9 @@ var DUCKDB_INIT_BOOT_QUERIES = []string{
 }
 
 type Duckdb struct {
-	db                                    *sql.DB
-	config                                *Config
-	stopImplicitAwsCredentialsRefreshChan chan struct{}
+	db                         *sql.DB
+	config                     *Config
+	stopCredentialsRefreshChan chan struct{}
 }
 
 func NewDuckdb(config *Config, withPgCompatibility bool) *Duckdb {
@@ -44,9 +46,9 @@ func NewDuckdb(config *Config, withPgCompatibility bool) *Duckdb {
 	PanicIfError(err, config)
 
 	duckdb := &Duckdb{
-		db:                                    db,
-		config:                                config,
-		stopImplicitAwsCredentialsRefreshChan: make(chan struct{}),
+		db:                         db,
+		config:                     config,
+		stopCredentialsRefreshChan: make(chan struct{}),
 	}
 
 	bootQueries := []string{}
@@ -63,6 +65,9 @@ func NewDuckdb(config *Config, withPgCompatibility bool) *Duckdb {
 			CreatePgCatalogTableQueries(config),
 			CreateInformationSchemaTableQueries(config),
 
+			// Create BemiDB tables
+			CreateBemidbTableQueries(config),
+
 			// Use the public schema
 			[]string{"USE public"},
 		)
@@ -86,6 +91,16 @@ func NewDuckdb(config *Config, withPgCompatibility bool) *Duckdb {
 			_, err = duckdb.ExecContext(ctx, "SET enable_http_logging=true", nil)
 			PanicIfError(err, config)
 		}
+	case STORAGE_TYPE_GCS:
+		if config.Gcs.HmacAccessId != "" && config.Gcs.HmacSecret != "" {
+			duckdb.setGcsHmacCredentials(ctx)
+		} else if !config.Gcs.Anonymous {
+			gcsClient := NewGcsClient(config)
+			duckdb.setGcsAccessToken(ctx, gcsClient)
+			duckdb.autoRefreshGcsAccessToken(ctx, gcsClient)
+		}
+	case STORAGE_TYPE_AZURE:
+		duckdb.setAzureCredentials(ctx)
 	}
 
 	return duckdb
@@ -107,7 +122,7 @@ func (duckdb *Duckdb) PrepareContext(ctx context.Context, query string) (*sql.St
 }
 
 func (duckdb *Duckdb) Close() {
-	close(duckdb.stopImplicitAwsCredentialsRefreshChan)
+	close(duckdb.stopCredentialsRefreshChan)
 	duckdb.db.Close()
 }
 
@@ -146,12 +161,14 @@ func (duckdb *Duckdb) ExecInitFile() {
 
 func (duckdb *Duckdb) setExplicitAwsCredentials(ctx context.Context) {
 	config := duckdb.config
-	query := "CREATE OR REPLACE SECRET aws_s3_secret (TYPE S3, KEY_ID '$accessKeyId', SECRET '$secretAccessKey', REGION '$region', ENDPOINT '$endpoint', SCOPE '$s3Bucket')"
+	query := "CREATE OR REPLACE SECRET aws_s3_secret (TYPE S3, KEY_ID '$accessKeyId', SECRET '$secretAccessKey', REGION '$region', ENDPOINT '$endpoint', URL_STYLE '$urlStyle', USE_SSL $useSsl, SCOPE '$s3Bucket')"
 	_, err := duckdb.ExecContext(ctx, query, map[string]string{
 		"accessKeyId":     config.Aws.AccessKeyId,
 		"secretAccessKey": config.Aws.SecretAccessKey,
 		"region":          config.Aws.Region,
 		"endpoint":        config.Aws.S3Endpoint,
+		"urlStyle":        duckdb.s3UrlStyle(),
+		"useSsl":          strconv.FormatBool(config.Aws.S3UseSsl),
 		"s3Bucket":        "s3://" + config.Aws.S3Bucket,
 	})
 	PanicIfError(err, config)
@@ -159,10 +176,12 @@ func (duckdb *Duckdb) setExplicitAwsCredentials(ctx context.Context) {
 
 func (duckdb *Duckdb) setImplicitAwsCredentials(ctx context.Context) {
 	config := duckdb.config
-	query := "CREATE OR REPLACE SECRET aws_s3_secret (TYPE S3, PROVIDER CREDENTIAL_CHAIN, REGION '$region', ENDPOINT '$endpoint', SCOPE '$s3Bucket')"
+	query := "CREATE OR REPLACE SECRET aws_s3_secret (TYPE S3, PROVIDER CREDENTIAL_CHAIN, REGION '$region', ENDPOINT '$endpoint', URL_STYLE '$urlStyle', USE_SSL $useSsl, SCOPE '$s3Bucket')"
 	_, err := duckdb.ExecContext(ctx, query, map[string]string{
 		"region":   config.Aws.Region,
 		"endpoint": config.Aws.S3Endpoint,
+		"urlStyle": duckdb.s3UrlStyle(),
+		"useSsl":   strconv.FormatBool(config.Aws.S3UseSsl),
 		"s3Bucket": "s3://" + config.Aws.S3Bucket,
 	})
 	PanicIfError(err, config)
@@ -175,7 +194,7 @@ func (duckdb *Duckdb) autoRefreshImplicitAwsCredentials(ctx context.Context) {
 			select {
 			case <-ticker.C:
 				duckdb.setImplicitAwsCredentials(ctx)
-			case <-duckdb.stopImplicitAwsCredentialsRefreshChan:
+			case <-duckdb.stopCredentialsRefreshChan:
 				ticker.Stop()
 				return
 			}
@@ -183,6 +202,79 @@ func (duckdb *Duckdb) autoRefreshImplicitAwsCredentials(ctx context.Context) {
 	}()
 }
 
+// DuckDB reads gs:// paths through the S3-compatible XML API, which signs requests with an HMAC key
+func (duckdb *Duckdb) setGcsHmacCredentials(ctx context.Context) {
+	config := duckdb.config
+	query := "CREATE OR REPLACE SECRET gcs_secret (TYPE GCS, KEY_ID '$hmacAccessId', SECRET '$hmacSecret', SCOPE '$gcsBucket')"
+	_, err := duckdb.ExecContext(ctx, query, map[string]string{
+		"hmacAccessId": config.Gcs.HmacAccessId,
+		"hmacSecret":   config.Gcs.HmacSecret,
+		"gcsBucket":    "gs://" + config.Gcs.Bucket,
+	})
+	PanicIfError(err, config)
+}
+
+// Without an HMAC key, the XML API accepts the OAuth access token of the service account in the Authorization header
+func (duckdb *Duckdb) setGcsAccessToken(ctx context.Context, gcsClient *GcsClient) {
+	config := duckdb.config
+	accessToken, err := gcsClient.AccessToken(2 * REFRESH_GCS_ACCESS_TOKEN_INTERVAL)
+	PanicIfError(err, config)
+
+	query := "CREATE OR REPLACE SECRET gcs_secret (TYPE HTTP, EXTRA_HTTP_HEADERS MAP {'Authorization': 'Bearer $accessToken'}, SCOPE '$gcsBucket')"
+	_, err = duckdb.ExecContext(ctx, query, map[string]string{
+		"accessToken": accessToken,
+		"gcsBucket":   "gs://" + config.Gcs.Bucket,
+	})
+	PanicIfError(err, config)
+}
+
+// Access tokens expire after an hour, so the secret is replaced with a token valid until the next refresh
+func (duckdb *Duckdb) autoRefreshGcsAccessToken(ctx context.Context, gcsClient *GcsClient) {
+	ticker := time.NewTicker(REFRESH_GCS_ACCESS_TOKEN_INTERVAL)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				duckdb.setGcsAccessToken(ctx, gcsClient)
+			case <-duckdb.stopCredentialsRefreshChan:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Connection strings contain semicolons, so they're quoted instead of passed as named args
+func (duckdb *Duckdb) setAzureCredentials(ctx context.Context) {
+	config := duckdb.config
+	scope := "'az://" + strings.ReplaceAll(config.Azure.Container, "'", "''") + "'"
+
+	var query string
+	switch {
+	case config.Azure.ConnectionString != "":
+		connectionString := ParseAzureConnectionString(config.Azure.ConnectionString).String()
+		query = "CREATE OR REPLACE SECRET azure_secret (TYPE AZURE, CONNECTION_STRING '" + strings.ReplaceAll(connectionString, "'", "''") + "', SCOPE " + scope + ")"
+	case config.Azure.SasToken != "":
+		connectionString := AzureConnectionString{
+			BlobEndpoint: "https://" + config.Azure.AccountName + ".blob.core.windows.net",
+			SasToken:     strings.TrimPrefix(config.Azure.SasToken, "?"),
+		}.String()
+		query = "CREATE OR REPLACE SECRET azure_secret (TYPE AZURE, CONNECTION_STRING '" + strings.ReplaceAll(connectionString, "'", "''") + "', SCOPE " + scope + ")"
+	default:
+		query = "CREATE OR REPLACE SECRET azure_secret (TYPE AZURE, PROVIDER CREDENTIAL_CHAIN, CHAIN 'managed_identity', ACCOUNT_NAME '" + strings.ReplaceAll(config.Azure.AccountName, "'", "''") + "', SCOPE " + scope + ")"
+	}
+
+	_, err := duckdb.ExecContext(ctx, query, nil)
+	PanicIfError(err, config)
+}
+
+func (duckdb *Duckdb) s3UrlStyle() string {
+	if duckdb.config.Aws.S3ForcePathStyle {
+		return "path"
+	}
+	return "vhost"
+}
+
 func replaceNamedStringArgs(query string, args map[string]string) string {
 	re := regexp.MustCompile(`['";]`) // Escape single quotes, double quotes, and semicolons from args
 
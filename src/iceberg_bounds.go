@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// String bounds are truncated like with Iceberg's default truncate(16) metrics mode
+const ICEBERG_BOUND_TRUNCATE_LENGTH = 16
+
+// Returns the value of a Parquet min/max statistic in the domain of the column's Iceberg type.
+// Returns false if the column has no bounds, e.g. for arrays, or for values stored in a different order or precision than their Iceberg type
+func icebergBoundValue(pgSchemaColumn PgSchemaColumn, statistic []byte) (interface{}, bool) {
+	if pgSchemaColumn.DataType == PG_DATA_TYPE_ARRAY || statistic == nil {
+		return nil, false
+	}
+
+	parquetType, parquetConvertedType := pgSchemaColumn.parquetPrimitiveTypes()
+	icebergType := pgSchemaColumn.icebergPrimitiveType()
+	switch {
+	case (icebergType == "int" || icebergType == "date") && parquetType == "INT32" && parquetConvertedType != "UINT_32" && len(statistic) == 4:
+		return int32(binary.LittleEndian.Uint32(statistic)), true
+	case icebergType == "long" && parquetType == "INT64" && parquetConvertedType != "UINT_64" && len(statistic) == 8:
+		return int64(binary.LittleEndian.Uint64(statistic)), true
+	case icebergType == "float" && parquetType == "FLOAT" && len(statistic) == 4:
+		value := math.Float32frombits(binary.LittleEndian.Uint32(statistic))
+		return value, !math.IsNaN(float64(value))
+	case icebergType == "double" && parquetType == "DOUBLE" && len(statistic) == 8:
+		value := math.Float64frombits(binary.LittleEndian.Uint64(statistic))
+		return value, !math.IsNaN(value)
+	case icebergType == "boolean" && len(statistic) == 1:
+		return statistic[0]&1 == 1, true
+	case (icebergType == "time" || icebergType == "timestamp" || icebergType == "timestamp_ns") && (parquetType == "INT32" || parquetType == "INT64"):
+		var value int64
+		switch len(statistic) {
+		case 4:
+			value = int64(int32(binary.LittleEndian.Uint32(statistic)))
+		case 8:
+			value = int64(binary.LittleEndian.Uint64(statistic))
+		default:
+			return nil, false
+		}
+		if !strings.HasSuffix(parquetConvertedType, "_MICROS") {
+			value *= 1000 // Milliseconds to microseconds
+		}
+		if icebergType == "timestamp_ns" {
+			value *= 1000
+		}
+		return value, true
+	case icebergType == "string" && parquetType == "BYTE_ARRAY" && utf8.Valid(statistic):
+		return string(statistic), true
+	case icebergType == "uuid":
+		value, err := uuid.ParseBytes(statistic) // Lowercase UUID strings are ordered like their bytes
+		return value, err == nil
+	case strings.HasPrefix(icebergType, "decimal") && parquetType == "FIXED_LEN_BYTE_ARRAY" && len(statistic) > 0:
+		unscaledValue := new(big.Int).SetBytes(statistic)
+		if statistic[0]&0x80 != 0 {
+			unscaledValue.Sub(unscaledValue, new(big.Int).Lsh(big.NewInt(1), uint(8*len(statistic))))
+		}
+		return unscaledValue, true
+	}
+
+	return nil, false
+}
+
+// Compares two values returned by icebergBoundValue for the same column
+func compareIcebergBoundValues(value1 interface{}, value2 interface{}) int {
+	switch typedValue1 := value1.(type) {
+	case int32:
+		return cmp.Compare(typedValue1, value2.(int32))
+	case int64:
+		return cmp.Compare(typedValue1, value2.(int64))
+	case float32:
+		return cmp.Compare(typedValue1, value2.(float32))
+	case float64:
+		return cmp.Compare(typedValue1, value2.(float64))
+	case bool:
+		if typedValue1 == value2.(bool) {
+			return 0
+		} else if typedValue1 {
+			return 1
+		}
+		return -1
+	case string:
+		return strings.Compare(typedValue1, value2.(string)) // UTF-8 bytes are ordered like code points
+	case uuid.UUID:
+		typedValue2 := value2.(uuid.UUID)
+		return bytes.Compare(typedValue1[:], typedValue2[:])
+	case *big.Int:
+		return typedValue1.Cmp(value2.(*big.Int))
+	}
+	panic(NewRecoverableError(errors.New("Unsupported Iceberg bound value"), nil))
+}
+
+// Returns the Iceberg single-value binary serialization of a lower bound
+func icebergLowerBound(value interface{}) []byte {
+	if stringValue, ok := value.(string); ok {
+		runes := []rune(stringValue)
+		if len(runes) > ICEBERG_BOUND_TRUNCATE_LENGTH {
+			return []byte(string(runes[:ICEBERG_BOUND_TRUNCATE_LENGTH]))
+		}
+	}
+	return icebergBinaryValue(value)
+}
+
+// Returns the Iceberg single-value binary serialization of an upper bound.
+// A truncated string is incremented to stay greater than the value, and returns false if it can't be incremented
+func icebergUpperBound(value interface{}) ([]byte, bool) {
+	stringValue, ok := value.(string)
+	if !ok || utf8.RuneCountInString(stringValue) <= ICEBERG_BOUND_TRUNCATE_LENGTH {
+		return icebergBinaryValue(value), true
+	}
+
+	runes := []rune(stringValue)[:ICEBERG_BOUND_TRUNCATE_LENGTH]
+	for i := len(runes) - 1; i >= 0; i-- {
+		nextRune := runes[i] + 1
+		if nextRune >= 0xD800 && nextRune <= 0xDFFF { // Surrogates aren't valid code points
+			nextRune = 0xE000
+		}
+		if nextRune <= utf8.MaxRune {
+			return []byte(string(append(runes[:i], nextRune))), true
+		}
+	}
+	return nil, false
+}
+
+func icebergBinaryValue(value interface{}) []byte {
+	switch typedValue := value.(type) {
+	case int32:
+		return binary.LittleEndian.AppendUint32(nil, uint32(typedValue))
+	case int64:
+		return binary.LittleEndian.AppendUint64(nil, uint64(typedValue))
+	case float32:
+		return binary.LittleEndian.AppendUint32(nil, math.Float32bits(typedValue))
+	case float64:
+		return binary.LittleEndian.AppendUint64(nil, math.Float64bits(typedValue))
+	case bool:
+		if typedValue {
+			return []byte{1}
+		}
+		return []byte{0}
+	case string:
+		return []byte(typedValue)
+	case uuid.UUID:
+		return typedValue[:]
+	case *big.Int:
+		return icebergDecimalBytes(typedValue)
+	}
+	panic(NewRecoverableError(errors.New("Unsupported Iceberg bound value"), nil))
+}
+
+// Returns the unscaled value of a decimal as two's-complement big-endian bytes, using the minimum number of bytes
+func icebergDecimalBytes(unscaledValue *big.Int) []byte {
+	magnitude := unscaledValue
+	if unscaledValue.Sign() < 0 {
+		magnitude = new(big.Int).Not(unscaledValue) // -v-1, e.g. -128 fits into a single byte like 127
+	}
+
+	decimalBytes := make([]byte, magnitude.BitLen()/8+1)
+	if unscaledValue.Sign() < 0 {
+		new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), uint(8*len(decimalBytes))), unscaledValue).FillBytes(decimalBytes)
+	} else {
+		unscaledValue.FillBytes(decimalBytes)
+	}
+	return decimalBytes
+}
+
+// Returns the value of an Iceberg single-value binary serialization written by icebergLowerBound or icebergUpperBound.
+// Returns false if the bound can't be compared, e.g. if it was written for a different type before a type promotion
+func icebergBinaryBoundValue(pgSchemaColumn PgSchemaColumn, bound []byte) (interface{}, bool) {
+	if pgSchemaColumn.DataType == PG_DATA_TYPE_ARRAY || bound == nil {
+		return nil, false
+	}
+
+	icebergType := pgSchemaColumn.icebergPrimitiveType()
+	switch {
+	case (icebergType == "int" || icebergType == "date") && len(bound) == 4:
+		return int32(binary.LittleEndian.Uint32(bound)), true
+	case (icebergType == "long" || icebergType == "time" || icebergType == "timestamp" || icebergType == "timestamp_ns") && len(bound) == 8:
+		return int64(binary.LittleEndian.Uint64(bound)), true
+	case icebergType == "float" && len(bound) == 4:
+		value := math.Float32frombits(binary.LittleEndian.Uint32(bound))
+		return value, !math.IsNaN(float64(value))
+	case icebergType == "double" && len(bound) == 8:
+		value := math.Float64frombits(binary.LittleEndian.Uint64(bound))
+		return value, !math.IsNaN(value)
+	case icebergType == "boolean" && len(bound) == 1:
+		return bound[0] == 1, true
+	case icebergType == "string" && utf8.Valid(bound):
+		return string(bound), true
+	case icebergType == "uuid" && len(bound) == 16:
+		return uuid.UUID(bound), true
+	case strings.HasPrefix(icebergType, "decimal") && len(bound) > 0:
+		unscaledValue := new(big.Int).SetBytes(bound)
+		if bound[0]&0x80 != 0 {
+			unscaledValue.Sub(unscaledValue, new(big.Int).Lsh(big.NewInt(1), uint(8*len(bound))))
+		}
+		return unscaledValue, true
+	}
+
+	return nil, false
+}
+
+// Returns false only if the bounds of a key column prove that no row of one Parquet file has the same key as a row of the other one
+func parquetFileKeyRangesOverlap(keyPgSchemaColumns []PgSchemaColumn, parquetFile1 ParquetFile, parquetFile2 ParquetFile) bool {
+	for _, keyPgSchemaColumn := range keyPgSchemaColumns {
+		lowerBound1, lowerFound1 := icebergBinaryBoundValue(keyPgSchemaColumn, parquetFile1.Stats.LowerBounds[keyPgSchemaColumn.FieldId])
+		upperBound1, upperFound1 := icebergBinaryBoundValue(keyPgSchemaColumn, parquetFile1.Stats.UpperBounds[keyPgSchemaColumn.FieldId])
+		lowerBound2, lowerFound2 := icebergBinaryBoundValue(keyPgSchemaColumn, parquetFile2.Stats.LowerBounds[keyPgSchemaColumn.FieldId])
+		upperBound2, upperFound2 := icebergBinaryBoundValue(keyPgSchemaColumn, parquetFile2.Stats.UpperBounds[keyPgSchemaColumn.FieldId])
+
+		if lowerFound1 && upperFound2 && compareIcebergBoundValues(lowerBound1, upperBound2) > 0 {
+			return false
+		}
+		if lowerFound2 && upperFound1 && compareIcebergBoundValues(lowerBound2, upperBound1) > 0 {
+			return false
+		}
+	}
+	return true
+}
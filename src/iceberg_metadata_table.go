@@ -0,0 +1,379 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+const (
+	ICEBERG_METADATA_TABLE_SEPARATOR = "$"
+
+	ICEBERG_METADATA_TABLE_SNAPSHOTS  = "snapshots"
+	ICEBERG_METADATA_TABLE_HISTORY    = "history"
+	ICEBERG_METADATA_TABLE_MANIFESTS  = "manifests"
+	ICEBERG_METADATA_TABLE_FILES      = "files"
+	ICEBERG_METADATA_TABLE_PARTITIONS = "partitions"
+)
+
+type IcebergMetadataTableColumn struct {
+	Name string
+	Type string
+}
+
+var ICEBERG_METADATA_TABLE_COLUMNS = map[string][]IcebergMetadataTableColumn{
+	ICEBERG_METADATA_TABLE_SNAPSHOTS: {
+		{Name: "committed_at", Type: "timestamp"},
+		{Name: "snapshot_id", Type: "int8"},
+		{Name: "parent_id", Type: "int8"},
+		{Name: "sequence_number", Type: "int8"},
+		{Name: "operation", Type: "text"},
+		{Name: "manifest_list", Type: "text"},
+		{Name: "schema_id", Type: "int4"},
+		{Name: "added_data_files", Type: "int8"},
+		{Name: "added_records", Type: "int8"},
+		{Name: "added_files_size", Type: "int8"},
+		{Name: "deleted_data_files", Type: "int8"},
+		{Name: "deleted_records", Type: "int8"},
+		{Name: "removed_files_size", Type: "int8"},
+		{Name: "added_delete_files", Type: "int8"},
+		{Name: "added_position_deletes", Type: "int8"},
+		{Name: "total_data_files", Type: "int8"},
+		{Name: "total_delete_files", Type: "int8"},
+		{Name: "total_records", Type: "int8"},
+		{Name: "total_position_deletes", Type: "int8"},
+		{Name: "total_files_size", Type: "int8"},
+	},
+	ICEBERG_METADATA_TABLE_HISTORY: {
+		{Name: "made_current_at", Type: "timestamp"},
+		{Name: "snapshot_id", Type: "int8"},
+		{Name: "parent_id", Type: "int8"},
+		{Name: "is_current_ancestor", Type: "bool"},
+	},
+	ICEBERG_METADATA_TABLE_MANIFESTS: {
+		{Name: "content", Type: "int4"},
+		{Name: "path", Type: "text"},
+		{Name: "length", Type: "int8"},
+		{Name: "partition_spec_id", Type: "int4"},
+		{Name: "added_snapshot_id", Type: "int8"},
+		{Name: "sequence_number", Type: "int8"},
+		{Name: "record_count", Type: "int8"},
+	},
+	ICEBERG_METADATA_TABLE_FILES: {
+		{Name: "content", Type: "int4"},
+		{Name: "file_path", Type: "text"},
+		{Name: "file_format", Type: "text"},
+		{Name: "spec_id", Type: "int4"},
+		{Name: "partition", Type: "text"},
+		{Name: "record_count", Type: "int8"},
+		{Name: "file_size_in_bytes", Type: "int8"},
+		{Name: "sort_order_id", Type: "int4"},
+		{Name: "sequence_number", Type: "int8"},
+	},
+	ICEBERG_METADATA_TABLE_PARTITIONS: {
+		{Name: "partition", Type: "text"},
+		{Name: "record_count", Type: "int8"},
+		{Name: "file_count", Type: "int8"},
+		{Name: "total_size", Type: "int8"},
+		{Name: "position_delete_record_count", Type: "int8"},
+		{Name: "position_delete_file_count", Type: "int8"},
+		{Name: "last_updated_snapshot_id", Type: "int8"},
+	},
+}
+
+// Virtual tables with the snapshots, manifests and files of Iceberg tables, e.g. public."orders$snapshots"
+type IcebergMetadataTable struct {
+	config        *Config
+	icebergReader *IcebergReader
+}
+
+func NewIcebergMetadataTable(config *Config, icebergReader *IcebergReader) *IcebergMetadataTable {
+	return &IcebergMetadataTable{config: config, icebergReader: icebergReader}
+}
+
+// orders$snapshots -> orders, snapshots
+func ParseIcebergMetadataTableName(table string) (icebergTable string, metadataTable string, found bool) {
+	separatorIndex := strings.LastIndex(table, ICEBERG_METADATA_TABLE_SEPARATOR)
+	if separatorIndex <= 0 {
+		return "", "", false
+	}
+
+	icebergTable, metadataTable = table[:separatorIndex], table[separatorIndex+1:]
+	if _, found := ICEBERG_METADATA_TABLE_COLUMNS[metadataTable]; !found {
+		return "", "", false
+	}
+	return icebergTable, metadataTable, true
+}
+
+// Returns a SELECT query with the metadata table rows as literal values
+func (metadataTable *IcebergMetadataTable) Query(icebergSchemaTable IcebergSchemaTable, metadataTableName string) (string, error) {
+	var rows [][]string
+	var err error
+	switch metadataTableName {
+	case ICEBERG_METADATA_TABLE_SNAPSHOTS:
+		rows, err = metadataTable.snapshotRows(icebergSchemaTable)
+	case ICEBERG_METADATA_TABLE_HISTORY:
+		rows, err = metadataTable.historyRows(icebergSchemaTable)
+	case ICEBERG_METADATA_TABLE_MANIFESTS:
+		rows, err = metadataTable.manifestRows(icebergSchemaTable)
+	case ICEBERG_METADATA_TABLE_FILES:
+		rows, err = metadataTable.fileRows(icebergSchemaTable)
+	case ICEBERG_METADATA_TABLE_PARTITIONS:
+		rows, err = metadataTable.partitionRows(icebergSchemaTable)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	columns := ICEBERG_METADATA_TABLE_COLUMNS[metadataTableName]
+	selectList := make([]string, len(columns))
+	columnNames := make([]string, len(columns))
+	for i, column := range columns {
+		columnNames[i] = column.Name
+		if len(rows) == 0 {
+			selectList[i] = "NULL::" + column.Type + " AS " + column.Name
+		} else {
+			selectList[i] = column.Name + "::" + column.Type + " AS " + column.Name
+		}
+	}
+
+	if len(rows) == 0 {
+		return "SELECT " + strings.Join(selectList, ", ") + " WHERE FALSE", nil
+	}
+
+	values := make([]string, len(rows))
+	for i, row := range rows {
+		values[i] = "(" + strings.Join(row, ", ") + ")"
+	}
+	return "SELECT " + strings.Join(selectList, ", ") + " FROM (VALUES " + strings.Join(values, ", ") + ") metadata_table(" + strings.Join(columnNames, ", ") + ")", nil
+}
+
+func (metadataTable *IcebergMetadataTable) snapshotRows(icebergSchemaTable IcebergSchemaTable) ([][]string, error) {
+	manifestListFilesSortedAsc, err := metadataTable.icebergReader.ManifestListFiles(icebergSchemaTable)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := [][]string{}
+	for _, manifestListFile := range manifestListFilesSortedAsc {
+		rows = append(rows, []string{
+			sqlTimestampMs(manifestListFile.TimestampMs),
+			Int64ToString(manifestListFile.SnapshotId),
+			sqlSnapshotId(manifestListFile.ParentSnapshotId),
+			IntToString(manifestListFile.SequenceNumber),
+			sqlString(manifestListFile.Operation),
+			sqlString(manifestListFile.Path),
+			IntToString(manifestListFile.SchemaId),
+			Int64ToString(manifestListFile.AddedDataFiles),
+			Int64ToString(manifestListFile.AddedRecords),
+			Int64ToString(manifestListFile.AddedFilesSize),
+			Int64ToString(manifestListFile.DeletedDataFiles),
+			Int64ToString(manifestListFile.DeletedRecords),
+			Int64ToString(manifestListFile.RemovedFilesSize),
+			Int64ToString(manifestListFile.AddedDeleteFiles),
+			Int64ToString(manifestListFile.AddedPositionDeletes),
+			Int64ToString(manifestListFile.TotalDataFiles),
+			Int64ToString(manifestListFile.TotalDeleteFiles),
+			Int64ToString(manifestListFile.TotalRecords),
+			Int64ToString(manifestListFile.TotalPositionDeletes),
+			Int64ToString(manifestListFile.TotalFilesSize),
+		})
+	}
+	return rows, nil
+}
+
+// Snapshots are committed linearly, so all of them are ancestors of the current one
+func (metadataTable *IcebergMetadataTable) historyRows(icebergSchemaTable IcebergSchemaTable) ([][]string, error) {
+	manifestListFilesSortedAsc, err := metadataTable.icebergReader.ManifestListFiles(icebergSchemaTable)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := [][]string{}
+	for _, manifestListFile := range manifestListFilesSortedAsc {
+		rows = append(rows, []string{
+			sqlTimestampMs(manifestListFile.TimestampMs),
+			Int64ToString(manifestListFile.SnapshotId),
+			sqlSnapshotId(manifestListFile.ParentSnapshotId),
+			"TRUE",
+		})
+	}
+	return rows, nil
+}
+
+func (metadataTable *IcebergMetadataTable) manifestRows(icebergSchemaTable IcebergSchemaTable) ([][]string, error) {
+	manifestListItemsSortedDesc, err := metadataTable.currentManifestListItems(icebergSchemaTable)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := [][]string{}
+	for _, manifestListItem := range manifestListItemsSortedDesc {
+		manifestFile := manifestListItem.ManifestFile
+		content := ICEBERG_MANIFEST_CONTENT_DATA
+		if manifestFile.PositionDeletes {
+			content = ICEBERG_MANIFEST_CONTENT_DELETES
+		}
+
+		rows = append(rows, []string{
+			IntToString(content),
+			sqlString(manifestFile.Path),
+			Int64ToString(manifestFile.Size),
+			IntToString(manifestFile.PartitionSpecId),
+			Int64ToString(manifestFile.SnapshotId),
+			IntToString(manifestListItem.SequenceNumber),
+			Int64ToString(manifestFile.RecordCount),
+		})
+	}
+	return rows, nil
+}
+
+func (metadataTable *IcebergMetadataTable) fileRows(icebergSchemaTable IcebergSchemaTable) ([][]string, error) {
+	manifestListItemsSortedDesc, err := metadataTable.currentManifestListItems(icebergSchemaTable)
+	if err != nil {
+		return nil, err
+	}
+	partitionSpec, err := metadataTable.icebergReader.PartitionSpec(icebergSchemaTable)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := [][]string{}
+	for _, manifestListItem := range manifestListItemsSortedDesc {
+		parquetFile, err := metadataTable.icebergReader.ParquetFile(manifestListItem.ManifestFile)
+		if err != nil {
+			return nil, err
+		}
+		content := ICEBERG_DATA_FILE_CONTENT_DATA
+		if parquetFile.PositionDeletes {
+			content = ICEBERG_DATA_FILE_CONTENT_POSITION_DELETES
+		}
+
+		rows = append(rows, []string{
+			IntToString(content),
+			sqlString(parquetFile.Path),
+			"'PARQUET'",
+			IntToString(manifestListItem.ManifestFile.PartitionSpecId),
+			sqlNullableString(metadataTable.partitionPath(partitionSpec, parquetFile.PartitionValues)),
+			Int64ToString(parquetFile.RecordCount),
+			Int64ToString(parquetFile.Size),
+			IntToString(parquetFile.SortOrderId),
+			IntToString(manifestListItem.SequenceNumber),
+		})
+	}
+	return rows, nil
+}
+
+// Aggregates the data and position delete files of the current snapshot per partition, a single row for unpartitioned tables
+func (metadataTable *IcebergMetadataTable) partitionRows(icebergSchemaTable IcebergSchemaTable) ([][]string, error) {
+	manifestListItemsSortedDesc, err := metadataTable.currentManifestListItems(icebergSchemaTable)
+	if err != nil {
+		return nil, err
+	}
+	partitionSpec, err := metadataTable.icebergReader.PartitionSpec(icebergSchemaTable)
+	if err != nil {
+		return nil, err
+	}
+
+	type partitionStats struct {
+		recordCount               int64
+		fileCount                 int64
+		totalSize                 int64
+		positionDeleteRecordCount int64
+		positionDeleteFileCount   int64
+		lastUpdatedSequenceNumber int
+		lastUpdatedSnapshotId     int64
+	}
+	partitionPaths := []string{}
+	statsByPartitionPath := map[string]*partitionStats{}
+
+	for _, manifestListItem := range manifestListItemsSortedDesc {
+		parquetFile, err := metadataTable.icebergReader.ParquetFile(manifestListItem.ManifestFile)
+		if err != nil {
+			return nil, err
+		}
+
+		partitionPath := metadataTable.partitionPath(partitionSpec, parquetFile.PartitionValues)
+		stats, found := statsByPartitionPath[partitionPath]
+		if !found {
+			stats = &partitionStats{}
+			statsByPartitionPath[partitionPath] = stats
+			partitionPaths = append(partitionPaths, partitionPath)
+		}
+
+		if parquetFile.PositionDeletes {
+			stats.positionDeleteRecordCount += parquetFile.RecordCount
+			stats.positionDeleteFileCount++
+		} else {
+			stats.recordCount += parquetFile.RecordCount
+			stats.fileCount++
+			stats.totalSize += parquetFile.Size
+		}
+		if manifestListItem.SequenceNumber >= stats.lastUpdatedSequenceNumber {
+			stats.lastUpdatedSequenceNumber = manifestListItem.SequenceNumber
+			stats.lastUpdatedSnapshotId = manifestListItem.ManifestFile.SnapshotId
+		}
+	}
+
+	slices.Sort(partitionPaths)
+	rows := [][]string{}
+	for _, partitionPath := range partitionPaths {
+		stats := statsByPartitionPath[partitionPath]
+		rows = append(rows, []string{
+			sqlNullableString(partitionPath),
+			Int64ToString(stats.recordCount),
+			Int64ToString(stats.fileCount),
+			Int64ToString(stats.totalSize),
+			Int64ToString(stats.positionDeleteRecordCount),
+			Int64ToString(stats.positionDeleteFileCount),
+			Int64ToString(stats.lastUpdatedSnapshotId),
+		})
+	}
+	return rows, nil
+}
+
+// Manifests of the data and position delete files in the current snapshot
+func (metadataTable *IcebergMetadataTable) currentManifestListItems(icebergSchemaTable IcebergSchemaTable) ([]ManifestListItem, error) {
+	manifestListFilesSortedAsc, err := metadataTable.icebergReader.ManifestListFiles(icebergSchemaTable)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifestListFilesSortedAsc) == 0 {
+		return []ManifestListItem{}, nil
+	}
+
+	return metadataTable.icebergReader.ManifestListItems(manifestListFilesSortedAsc[len(manifestListFilesSortedAsc)-1])
+}
+
+// Example: created_at_month=654/customer_id=null, empty for unpartitioned tables
+func (metadataTable *IcebergMetadataTable) partitionPath(partitionSpec IcebergPartitionSpec, partitionValues []*string) string {
+	if len(partitionValues) == 0 {
+		return ""
+	}
+
+	pathParts := make([]string, len(partitionValues))
+	for i, partitionValue := range partitionValues {
+		name := IntToString(i)
+		if i < len(partitionSpec.Fields) {
+			name = partitionSpec.Fields[i].Name
+		}
+
+		if partitionValue == nil {
+			pathParts[i] = name + "=null"
+		} else {
+			pathParts[i] = name + "=" + *partitionValue
+		}
+	}
+	return strings.Join(pathParts, "/")
+}
+
+func sqlSnapshotId(snapshotId int64) string {
+	if snapshotId == 0 {
+		return "NULL"
+	}
+	return Int64ToString(snapshotId)
+}
+
+// Unix milliseconds -> timestamp
+func sqlTimestampMs(unixMilliseconds int64) string {
+	return "epoch_ms(" + Int64ToString(unixMilliseconds) + ")"
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestIcebergMetadataTable(t *testing.T) {
+	config := loadTestConfig()
+	icebergWriter := NewIcebergWriter(config)
+	icebergReader := NewIcebergReader(config)
+	metadataTable := NewIcebergMetadataTable(config, icebergReader)
+	duckdb := NewDuckdb(config, false)
+
+	t.Cleanup(func() {
+		duckdb.Close()
+		icebergWriter.storage.DeleteSchema(TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema)
+	})
+
+	t.Run("Parses metadata table names", func(t *testing.T) {
+		icebergTable, metadataTableName, found := ParseIcebergMetadataTableName("orders$snapshots")
+		if !found || icebergTable != "orders" || metadataTableName != ICEBERG_METADATA_TABLE_SNAPSHOTS {
+			t.Errorf("Expected orders and snapshots, got %s and %s", icebergTable, metadataTableName)
+		}
+
+		for _, table := range []string{"orders", "orders$unknown", "$snapshots"} {
+			if _, _, found := ParseIcebergMetadataTableName(table); found {
+				t.Errorf("Expected %s not to be a metadata table", table)
+			}
+		}
+	})
+
+	t.Run("Returns snapshots and history", func(t *testing.T) {
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"3", "Jane"}}))
+		manifestListFiles, err := icebergReader.ManifestListFiles(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		testNoError(t, err)
+
+		rows := testMetadataTableRows(t, duckdb, metadataTable, ICEBERG_METADATA_TABLE_SNAPSHOTS, "snapshot_id, parent_id, sequence_number, operation, added_records, total_records")
+		testMetadataTableValues(t, rows, [][]string{
+			{Int64ToString(manifestListFiles[0].SnapshotId), "NULL", "1", "append", "2", "2"},
+			{Int64ToString(manifestListFiles[1].SnapshotId), Int64ToString(manifestListFiles[0].SnapshotId), "2", "append", "1", "3"},
+		})
+
+		rows = testMetadataTableRows(t, duckdb, metadataTable, ICEBERG_METADATA_TABLE_HISTORY, "snapshot_id, is_current_ancestor, made_current_at IS NOT NULL")
+		testMetadataTableValues(t, rows, [][]string{
+			{Int64ToString(manifestListFiles[0].SnapshotId), "true", "true"},
+			{Int64ToString(manifestListFiles[1].SnapshotId), "true", "true"},
+		})
+	})
+
+	t.Run("Returns manifests and files of the current snapshot", func(t *testing.T) {
+		rows := testMetadataTableRows(t, duckdb, metadataTable, ICEBERG_METADATA_TABLE_MANIFESTS, "content, sequence_number, record_count, path LIKE '%.avro'")
+		testMetadataTableValues(t, rows, [][]string{
+			{"0", "2", "1", "true"},
+			{"0", "1", "2", "true"},
+		})
+
+		rows = testMetadataTableRows(t, duckdb, metadataTable, ICEBERG_METADATA_TABLE_FILES, "content, file_format, partition, record_count, sort_order_id, file_path LIKE '%.parquet'")
+		testMetadataTableValues(t, rows, [][]string{
+			{"0", "PARQUET", "NULL", "1", "0", "true"},
+			{"0", "PARQUET", "NULL", "2", "0", "true"},
+		})
+	})
+
+	t.Run("Returns a single partition for unpartitioned tables", func(t *testing.T) {
+		rows := testMetadataTableRows(t, duckdb, metadataTable, ICEBERG_METADATA_TABLE_PARTITIONS, "partition, record_count, file_count, position_delete_file_count")
+		testMetadataTableValues(t, rows, [][]string{
+			{"NULL", "3", "2", "0"},
+		})
+	})
+
+	t.Run("Returns partitions of partitioned tables", func(t *testing.T) {
+		partitionSpec, err := NewIcebergPartitionSpec([]string{"name"}, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS)
+		testNoError(t, err)
+		parquetFiles := icebergWriter.WriteParquetFiles(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, partitionSpec, IcebergSortOrder{}, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows([][]string{{"1", "John"}, {"2", "John"}, {"3", PG_NULL_STRING}}))
+		icebergWriter.CommitParquetFiles(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, partitionSpec, IcebergSortOrder{}, parquetFiles)
+
+		rows := testMetadataTableRows(t, duckdb, metadataTable, ICEBERG_METADATA_TABLE_PARTITIONS, "partition, record_count, file_count")
+		testMetadataTableValues(t, rows, [][]string{
+			{"name=John", "2", "1"},
+			{"name=null", "1", "1"},
+		})
+	})
+
+}
+
+func testMetadataTableRows(t *testing.T, duckdb *Duckdb, metadataTable *IcebergMetadataTable, metadataTableName string, selectList string) [][]string {
+	query, err := metadataTable.Query(TEST_ICEBERG_WRITER_SCHEMA_TABLE, metadataTableName)
+	testNoError(t, err)
+
+	rows, err := duckdb.QueryContext(context.Background(), "SELECT "+selectList+" FROM ("+query+")")
+	if err != nil {
+		t.Fatalf("Expected the %s query to succeed, got %v: %s", metadataTableName, err, query)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	testNoError(t, err)
+
+	result := [][]string{}
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		valuePointers := make([]interface{}, len(columns))
+		for i := range values {
+			valuePointers[i] = &values[i]
+		}
+		err = rows.Scan(valuePointers...)
+		testNoError(t, err)
+
+		row := make([]string, len(columns))
+		for i, value := range values {
+			if value.Valid {
+				row[i] = value.String
+			} else {
+				row[i] = "NULL"
+			}
+		}
+		result = append(result, row)
+	}
+	return result
+}
+
+func testMetadataTableValues(t *testing.T, rows [][]string, expectedRows [][]string) {
+	if len(rows) != len(expectedRows) {
+		t.Fatalf("Expected %d row(s), got %d: %v", len(expectedRows), len(rows), rows)
+	}
+	for i, expectedRow := range expectedRows {
+		if strings.Join(rows[i], ", ") != strings.Join(expectedRow, ", ") {
+			t.Errorf("Expected row %v, got %v", expectedRow, rows[i])
+		}
+	}
+}
@@ -0,0 +1,566 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	ICEBERG_PARTITION_TRANSFORM_IDENTITY = "identity"
+	ICEBERG_PARTITION_TRANSFORM_YEAR     = "year"
+	ICEBERG_PARTITION_TRANSFORM_MONTH    = "month"
+	ICEBERG_PARTITION_TRANSFORM_DAY      = "day"
+	ICEBERG_PARTITION_TRANSFORM_HOUR     = "hour"
+	ICEBERG_PARTITION_TRANSFORM_BUCKET   = "bucket"   // bucket[N]
+	ICEBERG_PARTITION_TRANSFORM_TRUNCATE = "truncate" // truncate[W]
+
+	ICEBERG_PARTITION_FIELD_ID_START = 1000 // Partition field IDs start at 1000, so 999 means no partition fields
+)
+
+// Finest first
+var ICEBERG_TIME_PARTITION_TRANSFORMS = []string{
+	ICEBERG_PARTITION_TRANSFORM_HOUR,
+	ICEBERG_PARTITION_TRANSFORM_DAY,
+	ICEBERG_PARTITION_TRANSFORM_MONTH,
+	ICEBERG_PARTITION_TRANSFORM_YEAR,
+}
+
+// Examples: region, day(created_at), bucket[16](user_id), truncate[4](name)
+var ICEBERG_PARTITION_EXPRESSION_REGEXP = regexp.MustCompile(`^(?:(identity|year|month|day|hour|bucket\[(\d+)\]|truncate\[(\d+)\])\((.+)\)|([^()\[\]]+))$`)
+
+type IcebergPartitionField struct {
+	SourceId   int
+	FieldId    int
+	Name       string
+	Transform  string
+	ResultType string // Iceberg type of the partition values: int, long, string, date or boolean
+}
+
+// Partition values of a data file are stored as strings in the order of the spec fields, nil for NULL.
+// Time transforms produce integers, e.g. months since 1970-01 for the month transform
+type IcebergPartitionSpec struct {
+	SpecId int
+	Fields []IcebergPartitionField
+}
+
+// Builds a spec from partition expressions, e.g. ["day(created_at)", "bucket[16](user_id)"]
+func NewIcebergPartitionSpec(expressions []string, pgSchemaColumns []PgSchemaColumn) (IcebergPartitionSpec, error) {
+	spec := IcebergPartitionSpec{}
+	fieldNames := make(Set[string])
+
+	for i, expression := range expressions {
+		transform, columnName, err := ParseIcebergPartitionExpression(expression)
+		if err != nil {
+			return IcebergPartitionSpec{}, err
+		}
+
+		columnIndex := slices.IndexFunc(pgSchemaColumns, func(pgSchemaColumn PgSchemaColumn) bool {
+			return pgSchemaColumn.ColumnName == columnName
+		})
+		if columnIndex == -1 {
+			return IcebergPartitionSpec{}, errors.New("partition column " + columnName + " is not synced")
+		}
+		pgSchemaColumn := pgSchemaColumns[columnIndex]
+
+		sourceType := icebergPartitionSourceType(pgSchemaColumn)
+		resultType := icebergPartitionResultType(transform, sourceType)
+		if resultType == "" {
+			return IcebergPartitionSpec{}, errors.New("partition transform " + transform + " is not supported for column " + columnName + " of type " + pgSchemaColumn.UdtName)
+		}
+
+		sourceId := pgSchemaColumn.FieldId
+		name := pgSchemaColumn.NormalizedColumnName()
+		transformName, _, _ := strings.Cut(transform, "[")
+		switch transformName {
+		case ICEBERG_PARTITION_TRANSFORM_IDENTITY:
+		case ICEBERG_PARTITION_TRANSFORM_TRUNCATE:
+			name += "_trunc"
+		default:
+			name += "_" + transformName
+		}
+		if fieldNames.Contains(name) {
+			return IcebergPartitionSpec{}, errors.New("partition field " + name + " is defined more than once")
+		}
+		fieldNames.Add(name)
+
+		spec.Fields = append(spec.Fields, IcebergPartitionField{
+			SourceId:   sourceId,
+			FieldId:    ICEBERG_PARTITION_FIELD_ID_START + i,
+			Name:       name,
+			Transform:  transform,
+			ResultType: resultType,
+		})
+	}
+
+	return spec, nil
+}
+
+// Returns the transform (e.g. bucket[16]) and the column name of a partition expression
+func ParseIcebergPartitionExpression(expression string) (transform string, columnName string, err error) {
+	match := ICEBERG_PARTITION_EXPRESSION_REGEXP.FindStringSubmatch(strings.TrimSpace(expression))
+	if match == nil {
+		return "", "", errors.New("invalid partition expression \"" + expression + "\". Must be a column name or one of identity(column), year(column), month(column), day(column), hour(column), bucket[N](column), truncate[W](column)")
+	}
+
+	if match[5] != "" {
+		return ICEBERG_PARTITION_TRANSFORM_IDENTITY, strings.TrimSpace(match[5]), nil
+	}
+
+	for _, width := range []string{match[2], match[3]} {
+		if width == "" {
+			continue
+		}
+		parsedWidth, err := strconv.ParseInt(width, 10, 32)
+		if err != nil || parsedWidth < 1 {
+			return "", "", errors.New("invalid partition expression \"" + expression + "\". Width must be a positive integer")
+		}
+	}
+
+	return match[1], strings.TrimSpace(match[4]), nil
+}
+
+// Returns the spec with the ID of an existing spec with the same fields, or with the next ID if the partitioning changed.
+// Fields of previous specs keep their field IDs, new fields get IDs after the last one, so manifests of previous snapshots stay readable
+func EvolveIcebergPartitionSpec(existingPartitionSpecsSortedAsc []IcebergPartitionSpec, partitionSpec IcebergPartitionSpec) IcebergPartitionSpec {
+	if len(existingPartitionSpecsSortedAsc) == 0 {
+		return partitionSpec
+	}
+
+	lastPartitionId := ICEBERG_PARTITION_FIELD_ID_START - 1
+	existingFieldIds := make(map[string]int)
+	for _, existingPartitionSpec := range existingPartitionSpecsSortedAsc {
+		lastPartitionId = max(lastPartitionId, existingPartitionSpec.LastPartitionId())
+		for _, field := range existingPartitionSpec.Fields {
+			existingFieldIds[IntToString(field.SourceId)+":"+field.Transform] = field.FieldId
+		}
+	}
+
+	evolvedPartitionSpec := IcebergPartitionSpec{SpecId: existingPartitionSpecsSortedAsc[len(existingPartitionSpecsSortedAsc)-1].SpecId + 1}
+	for _, field := range partitionSpec.Fields {
+		if fieldId, ok := existingFieldIds[IntToString(field.SourceId)+":"+field.Transform]; ok {
+			field.FieldId = fieldId
+		} else {
+			lastPartitionId++
+			field.FieldId = lastPartitionId
+		}
+		evolvedPartitionSpec.Fields = append(evolvedPartitionSpec.Fields, field)
+	}
+
+	for _, existingPartitionSpec := range existingPartitionSpecsSortedAsc {
+		if existingPartitionSpec.equalFields(evolvedPartitionSpec) {
+			evolvedPartitionSpec.SpecId = existingPartitionSpec.SpecId
+			return evolvedPartitionSpec
+		}
+	}
+	return evolvedPartitionSpec
+}
+
+func (spec IcebergPartitionSpec) equalFields(otherSpec IcebergPartitionSpec) bool {
+	return slices.EqualFunc(spec.Fields, otherSpec.Fields, func(field IcebergPartitionField, otherField IcebergPartitionField) bool {
+		return field.SourceId == otherField.SourceId && field.FieldId == otherField.FieldId && field.Name == otherField.Name && field.Transform == otherField.Transform
+	})
+}
+
+func (spec IcebergPartitionSpec) IsPartitioned() bool {
+	return len(spec.Fields) > 0
+}
+
+func (spec IcebergPartitionSpec) LastPartitionId() int {
+	lastPartitionId := ICEBERG_PARTITION_FIELD_ID_START - 1
+	for _, field := range spec.Fields {
+		lastPartitionId = max(lastPartitionId, field.FieldId)
+	}
+	return lastPartitionId
+}
+
+// Example: created_at_month=month(1)
+func (spec IcebergPartitionSpec) String() string {
+	fields := make([]string, len(spec.Fields))
+	for i, field := range spec.Fields {
+		fields[i] = field.Name + "=" + field.Transform + "(" + IntToString(field.SourceId) + ")"
+	}
+	return strings.Join(fields, ", ")
+}
+
+// Spec in the partition-specs list of the table metadata and in the manifest file metadata
+func (spec IcebergPartitionSpec) ToMetadataMap() map[string]interface{} {
+	return map[string]interface{}{
+		"spec-id": spec.SpecId,
+		"fields":  spec.metadataFields(),
+	}
+}
+
+func (spec IcebergPartitionSpec) metadataFields() []interface{} {
+	fields := []interface{}{}
+	for _, field := range spec.Fields {
+		fields = append(fields, map[string]interface{}{
+			"source-id": field.SourceId,
+			"field-id":  field.FieldId,
+			"name":      field.Name,
+			"transform": field.Transform,
+		})
+	}
+	return fields
+}
+
+// The "partition" field of data files in manifest entries
+func (spec IcebergPartitionSpec) ToAvroSchemaField() map[string]interface{} {
+	fields := []interface{}{}
+	for _, field := range spec.Fields {
+		fields = append(fields, map[string]interface{}{
+			"name":     field.Name,
+			"type":     []interface{}{"null", field.avroType()},
+			"default":  nil,
+			"field-id": field.FieldId,
+		})
+	}
+
+	return map[string]interface{}{
+		"name": "partition",
+		"type": map[string]interface{}{
+			"type":   "record",
+			"name":   "r102",
+			"fields": fields,
+		},
+		"doc":      "Partition data tuple, schema based on the partition spec",
+		"field-id": 102,
+	}
+}
+
+func (spec IcebergPartitionSpec) ToAvroValue(partitionValues []*string) (map[string]interface{}, error) {
+	if len(partitionValues) != len(spec.Fields) {
+		return nil, errors.New("expected " + IntToString(len(spec.Fields)) + " partition value(s), got " + IntToString(len(partitionValues)))
+	}
+
+	avroValue := map[string]interface{}{}
+	for i, field := range spec.Fields {
+		if partitionValues[i] == nil {
+			avroValue[field.Name] = nil
+			continue
+		}
+
+		value, err := field.parseValue(*partitionValues[i])
+		if err != nil {
+			return nil, err
+		}
+		avroValue[field.Name] = map[string]interface{}{field.avroType(): value}
+	}
+
+	return avroValue, nil
+}
+
+// Iceberg single-value binary serialization, used for partition bounds in manifest lists
+func (field IcebergPartitionField) BinaryValue(partitionValue string) ([]byte, error) {
+	value, err := field.parseValue(partitionValue)
+	if err != nil {
+		return nil, err
+	}
+
+	switch typedValue := value.(type) {
+	case int32:
+		return binary.LittleEndian.AppendUint32(nil, uint32(typedValue)), nil
+	case int64:
+		return binary.LittleEndian.AppendUint64(nil, uint64(typedValue)), nil
+	case bool:
+		if typedValue {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	default:
+		return []byte(partitionValue), nil
+	}
+}
+
+func (field IcebergPartitionField) avroType() string {
+	switch field.ResultType {
+	case "date":
+		return "int"
+	default:
+		return field.ResultType
+	}
+}
+
+func (field IcebergPartitionField) parseValue(partitionValue string) (interface{}, error) {
+	switch field.ResultType {
+	case "int", "date":
+		value, err := strconv.ParseInt(partitionValue, 10, 32)
+		return int32(value), err
+	case "long":
+		return strconv.ParseInt(partitionValue, 10, 64)
+	case "boolean":
+		return strconv.ParseBool(partitionValue)
+	case "string":
+		return partitionValue, nil
+	}
+
+	return nil, errors.New("unsupported partition value type: " + field.ResultType)
+}
+
+// Computes partition values of PG rows for a spec
+type IcebergPartitioner struct {
+	spec          IcebergPartitionSpec
+	columnIndexes []int
+	sourceColumns []PgSchemaColumn
+}
+
+func NewIcebergPartitioner(spec IcebergPartitionSpec, pgSchemaColumns []PgSchemaColumn) (*IcebergPartitioner, error) {
+	partitioner := &IcebergPartitioner{spec: spec}
+
+	for _, field := range spec.Fields {
+		columnIndex := slices.IndexFunc(pgSchemaColumns, func(pgSchemaColumn PgSchemaColumn) bool {
+			return pgSchemaColumn.FieldId == field.SourceId
+		})
+		if columnIndex == -1 {
+			return nil, errors.New("partition field " + field.Name + " has no source column")
+		}
+		partitioner.columnIndexes = append(partitioner.columnIndexes, columnIndex)
+		partitioner.sourceColumns = append(partitioner.sourceColumns, pgSchemaColumns[columnIndex])
+	}
+
+	return partitioner, nil
+}
+
+// Partition values of a row with PG text values, in the order of the spec fields
+func (partitioner *IcebergPartitioner) PartitionValues(row []string) ([]*string, error) {
+	partitionValues := make([]*string, len(partitioner.spec.Fields))
+	for i, field := range partitioner.spec.Fields {
+		value := row[partitioner.columnIndexes[i]]
+		if value == PG_NULL_STRING {
+			continue
+		}
+
+		partitionValue, err := field.transformValue(icebergPartitionSourceType(partitioner.sourceColumns[i]), value)
+		if err != nil {
+			return nil, errors.New("failed to partition by " + field.Name + ": " + err.Error())
+		}
+		partitionValues[i] = &partitionValue
+	}
+	return partitionValues, nil
+}
+
+func (field IcebergPartitionField) transformValue(sourceType string, value string) (string, error) {
+	transform, widthString, _ := strings.Cut(strings.TrimSuffix(field.Transform, "]"), "[")
+	var width int64
+	if widthString != "" {
+		var err error
+		width, err = strconv.ParseInt(widthString, 10, 32)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	switch sourceType {
+	case "int", "long":
+		intValue, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", err
+		}
+		switch transform {
+		case ICEBERG_PARTITION_TRANSFORM_IDENTITY:
+			return Int64ToString(intValue), nil
+		case ICEBERG_PARTITION_TRANSFORM_BUCKET:
+			return Int64ToString(icebergBucket(binary.LittleEndian.AppendUint64(nil, uint64(intValue)), width)), nil
+		case ICEBERG_PARTITION_TRANSFORM_TRUNCATE:
+			return Int64ToString(intValue - (((intValue % width) + width) % width)), nil
+		}
+	case "string":
+		switch transform {
+		case ICEBERG_PARTITION_TRANSFORM_IDENTITY:
+			return value, nil
+		case ICEBERG_PARTITION_TRANSFORM_BUCKET:
+			return Int64ToString(icebergBucket([]byte(value), width)), nil
+		case ICEBERG_PARTITION_TRANSFORM_TRUNCATE:
+			if utf8.RuneCountInString(value) <= int(width) {
+				return value, nil
+			}
+			return string([]rune(value)[:width]), nil
+		}
+	case "boolean":
+		boolValue, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(boolValue), nil
+	case "date", "timestamp":
+		timeValue, err := parseIcebergPartitionTime(value)
+		if err != nil {
+			return "", err
+		}
+		switch transform {
+		case ICEBERG_PARTITION_TRANSFORM_IDENTITY:
+			return Int64ToString(IcebergTimePartitionValue(ICEBERG_PARTITION_TRANSFORM_DAY, timeValue)), nil
+		case ICEBERG_PARTITION_TRANSFORM_BUCKET:
+			// Dates are hashed as days and timestamps as microseconds since the epoch
+			hashedValue := IcebergTimePartitionValue(ICEBERG_PARTITION_TRANSFORM_DAY, timeValue)
+			if sourceType == "timestamp" {
+				hashedValue = timeValue.UnixMicro()
+			}
+			return Int64ToString(icebergBucket(binary.LittleEndian.AppendUint64(nil, uint64(hashedValue)), width)), nil
+		default:
+			return Int64ToString(IcebergTimePartitionValue(transform, timeValue)), nil
+		}
+	}
+
+	return "", errors.New("unsupported partition transform " + field.Transform + " for type " + sourceType)
+}
+
+// Iceberg type of a partition source column, or "" if it can't be partitioned by
+func icebergPartitionSourceType(pgSchemaColumn PgSchemaColumn) string {
+	if pgSchemaColumn.DataType == PG_DATA_TYPE_ARRAY {
+		return ""
+	}
+
+	switch pgSchemaColumn.UdtName {
+	case "int2", "int4":
+		return "int"
+	case "int8":
+		return "long"
+	case "varchar", "text": // Other string types are synced in a different format
+		return "string"
+	case "bool":
+		return "boolean"
+	case "date":
+		return "date"
+	case "timestamp", "timestamptz":
+		if pgSchemaColumn.DatetimePrecision != "9" {
+			return "timestamp"
+		}
+	}
+	return ""
+}
+
+// Same as icebergPartitionSourceType for a field of the table metadata
+func icebergPartitionSourceTypeOfField(icebergSchemaField IcebergSchemaField) string {
+	switch icebergSchemaField.Type {
+	case "int", "long", "string", "boolean", "date", "timestamp":
+		return icebergSchemaField.Type.(string)
+	}
+	return ""
+}
+
+// Iceberg type of the partition values, or "" if the transform doesn't support the source type
+func icebergPartitionResultType(transform string, sourceType string) string {
+	transformName, _, _ := strings.Cut(transform, "[")
+
+	switch transformName {
+	case ICEBERG_PARTITION_TRANSFORM_IDENTITY:
+		switch sourceType {
+		case "int", "long", "string", "boolean", "date":
+			return sourceType
+		}
+	case ICEBERG_PARTITION_TRANSFORM_BUCKET:
+		switch sourceType {
+		case "int", "long", "string", "date", "timestamp":
+			return "int"
+		}
+	case ICEBERG_PARTITION_TRANSFORM_TRUNCATE:
+		switch sourceType {
+		case "int", "long", "string":
+			return sourceType
+		}
+	case ICEBERG_PARTITION_TRANSFORM_YEAR, ICEBERG_PARTITION_TRANSFORM_MONTH, ICEBERG_PARTITION_TRANSFORM_DAY:
+		if sourceType == "date" || sourceType == "timestamp" {
+			return "int"
+		}
+	case ICEBERG_PARTITION_TRANSFORM_HOUR:
+		if sourceType == "timestamp" {
+			return "int"
+		}
+	}
+	return ""
+}
+
+// Timestamps without time zone are treated as UTC
+func parseIcebergPartitionTime(value string) (time.Time, error) {
+	var err error
+	for _, layout := range []string{"2006-01-02 15:04:05.999999-07:00", "2006-01-02 15:04:05.999999-07", "2006-01-02 15:04:05.999999", "2006-01-02"} {
+		var parsedTime time.Time
+		parsedTime, err = time.Parse(layout, value)
+		if err == nil {
+			return parsedTime, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// Iceberg bucket transform: (murmur3_x86_32(value) & Integer.MAX_VALUE) % N
+func icebergBucket(value []byte, bucketCount int64) int64 {
+	return int64(murmur3Hash32(value)&math.MaxInt32) % bucketCount
+}
+
+// MurmurHash3 x86 32-bit with seed 0
+func murmur3Hash32(data []byte) uint32 {
+	const c1, c2 = 0xcc9e2d51, 0x1b873593
+
+	var hash uint32
+	blockCount := len(data) / 4
+	for i := 0; i < blockCount; i++ {
+		k := binary.LittleEndian.Uint32(data[i*4:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+
+		hash ^= k
+		hash = bits.RotateLeft32(hash, 13)
+		hash = hash*5 + 0xe6546b64
+	}
+
+	tail := data[blockCount*4:]
+	var k uint32
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		hash ^= k
+	}
+
+	hash ^= uint32(len(data))
+	hash ^= hash >> 16
+	hash *= 0x85ebca6b
+	hash ^= hash >> 13
+	hash *= 0xc2b2ae35
+	hash ^= hash >> 16
+	return hash
+}
+
+// Returns the value of a time transform for the UTC time, e.g. months since 1970-01 for the month transform
+func IcebergTimePartitionValue(transform string, value time.Time) int64 {
+	value = value.UTC()
+
+	switch transform {
+	case ICEBERG_PARTITION_TRANSFORM_YEAR:
+		return int64(value.Year() - 1970)
+	case ICEBERG_PARTITION_TRANSFORM_MONTH:
+		return int64((value.Year()-1970)*12 + int(value.Month()) - 1)
+	case ICEBERG_PARTITION_TRANSFORM_DAY:
+		return floorDiv(value.Unix(), 24*60*60)
+	case ICEBERG_PARTITION_TRANSFORM_HOUR:
+		return floorDiv(value.Unix(), 60*60)
+	}
+
+	panic(NewRecoverableError(errors.New("Unsupported time partition transform: "+transform), nil))
+}
+
+func floorDiv(dividend int64, divisor int64) int64 {
+	quotient := dividend / divisor
+	if dividend%divisor != 0 && dividend < 0 {
+		quotient--
+	}
+	return quotient
+}
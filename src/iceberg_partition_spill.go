@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+)
+
+const (
+	MAX_OPEN_PARTITION_SPILL_FILES = 64
+	SPILL_BATCH_SIZE               = 64 * 1024 * 1024 // 64 MB of spilled rows loaded at once
+)
+
+// Rows spilled to a temporary file per partition, so each partition can be written into its own Parquet files
+// without keeping all rows in memory. Files are closed and reopened when too many partitions are open at once
+type IcebergPartitionSpill struct {
+	config          *Config
+	partitions      []*IcebergSpilledPartition // In the order the partitions were first seen
+	partitionsByKey map[string]*IcebergSpilledPartition
+	openPartitions  []*IcebergSpilledPartition
+}
+
+type IcebergSpilledPartition struct {
+	PartitionValues []*string
+
+	config *Config
+	path   string
+	file   *os.File
+	writer *bufio.Writer
+}
+
+func NewIcebergPartitionSpill(config *Config) *IcebergPartitionSpill {
+	return &IcebergPartitionSpill{
+		config:          config,
+		partitionsByKey: make(map[string]*IcebergSpilledPartition),
+	}
+}
+
+func (spill *IcebergPartitionSpill) Write(partitionValues []*string, row []string) error {
+	key, err := json.Marshal(partitionValues)
+	if err != nil {
+		return err
+	}
+
+	partition, found := spill.partitionsByKey[string(key)]
+	if !found {
+		file, err := os.CreateTemp("", "bemidb-partition-*.jsonl")
+		if err != nil {
+			return err
+		}
+		file.Close()
+
+		partition = &IcebergSpilledPartition{PartitionValues: partitionValues, config: spill.config, path: file.Name()}
+		spill.partitions = append(spill.partitions, partition)
+		spill.partitionsByKey[string(key)] = partition
+	}
+
+	if partition.file == nil {
+		if len(spill.openPartitions) >= MAX_OPEN_PARTITION_SPILL_FILES {
+			err = spill.closeFiles()
+			if err != nil {
+				return err
+			}
+		}
+
+		partition.file, err = os.OpenFile(partition.path, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		partition.writer = bufio.NewWriter(partition.file)
+		spill.openPartitions = append(spill.openPartitions, partition)
+	}
+
+	rowJson, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	_, err = partition.writer.Write(append(rowJson, '\n'))
+	return err
+}
+
+// Flushes the spilled rows and returns the partitions to read them from
+func (spill *IcebergPartitionSpill) Partitions() ([]*IcebergSpilledPartition, error) {
+	err := spill.closeFiles()
+	if err != nil {
+		return nil, err
+	}
+	return spill.partitions, nil
+}
+
+// Deletes the temporary files
+func (spill *IcebergPartitionSpill) Close() error {
+	err := spill.closeFiles()
+	for _, partition := range spill.partitions {
+		err = errors.Join(err, os.Remove(partition.path))
+	}
+	spill.partitions = nil
+	spill.partitionsByKey = make(map[string]*IcebergSpilledPartition)
+	return err
+}
+
+func (spill *IcebergPartitionSpill) closeFiles() error {
+	var err error
+	for _, partition := range spill.openPartitions {
+		err = errors.Join(err, partition.writer.Flush(), partition.file.Close())
+		partition.file = nil
+		partition.writer = nil
+	}
+	spill.openPartitions = nil
+	return err
+}
+
+// Returns a loadRows function reading the spilled rows in batches, or an error if the file can't be opened
+func (partition *IcebergSpilledPartition) LoadRows() (loadRows func() [][]string, closeFile func(), err error) {
+	file, err := os.Open(partition.path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	reachedEnd := false
+
+	loadRows = func() [][]string {
+		rows := [][]string{}
+		loadedSize := 0
+		for !reachedEnd && loadedSize < SPILL_BATCH_SIZE {
+			line, err := reader.ReadBytes('\n')
+			if err == io.EOF {
+				reachedEnd = true
+				break
+			}
+			PanicIfError(err, partition.config)
+
+			var row []string
+			err = json.Unmarshal(line, &row)
+			PanicIfError(err, partition.config)
+
+			rows = append(rows, row)
+			loadedSize += len(line)
+		}
+		return rows
+	}
+
+	return loadRows, func() { file.Close() }, nil
+}
@@ -1,5 +1,10 @@
 package main
 
+import (
+	"fmt"
+	"time"
+)
+
 type IcebergReader struct {
 	config  *Config
 	storage StorageInterface
@@ -25,6 +30,57 @@ func (reader *IcebergReader) TableFields(icebergSchemaTable IcebergSchemaTable)
 	return reader.storage.IcebergTableFields(icebergSchemaTable)
 }
 
-func (reader *IcebergReader) MetadataFilePath(icebergSchemaTable IcebergSchemaTable) string {
+func (reader *IcebergReader) MetadataFilePath(icebergSchemaTable IcebergSchemaTable) (metadataFilePath string, err error) {
 	return reader.storage.IcebergMetadataFilePath(icebergSchemaTable)
 }
+
+func (reader *IcebergReader) Metadata(icebergSchemaTable IcebergSchemaTable) (metadataFilePath string, metadataContent []byte, err error) {
+	LogDebug(reader.config, "Reading Iceberg table "+icebergSchemaTable.String()+" metadata...")
+	return reader.storage.IcebergMetadata(icebergSchemaTable)
+}
+
+func (reader *IcebergReader) ManifestListFiles(icebergSchemaTable IcebergSchemaTable) (manifestListFilesSortedAsc []ManifestListFile, err error) {
+	LogDebug(reader.config, "Reading Iceberg table "+icebergSchemaTable.String()+" snapshots...")
+	return reader.storage.ExistingManifestListFiles(reader.storage.IcebergMetadataDirPath(icebergSchemaTable))
+}
+
+// Returns the snapshot that was current at the timestamp, or the snapshot with the ID if timestamp is zero
+func (reader *IcebergReader) Snapshot(icebergSchemaTable IcebergSchemaTable, snapshotId int64, timestamp time.Time) (manifestListFile ManifestListFile, err error) {
+	manifestListFilesSortedAsc, err := reader.ManifestListFiles(icebergSchemaTable)
+	if err != nil {
+		return ManifestListFile{}, err
+	}
+
+	if timestamp.IsZero() {
+		for _, manifestListFile := range manifestListFilesSortedAsc {
+			if manifestListFile.SnapshotId == snapshotId {
+				return manifestListFile, nil
+			}
+		}
+		return ManifestListFile{}, fmt.Errorf("snapshot %d of %s doesn't exist or has expired", snapshotId, icebergSchemaTable.String())
+	}
+
+	for i := len(manifestListFilesSortedAsc) - 1; i >= 0; i-- {
+		if manifestListFilesSortedAsc[i].TimestampMs <= timestamp.UnixMilli() {
+			return manifestListFilesSortedAsc[i], nil
+		}
+	}
+	return ManifestListFile{}, fmt.Errorf("no snapshot of %s was committed at or before %s", icebergSchemaTable.String(), timestamp.UTC().Format(time.RFC3339))
+}
+
+func (reader *IcebergReader) PartitionSpec(icebergSchemaTable IcebergSchemaTable) (partitionSpec IcebergPartitionSpec, err error) {
+	return reader.storage.ExistingPartitionSpec(reader.storage.IcebergMetadataDirPath(icebergSchemaTable))
+}
+
+func (reader *IcebergReader) ManifestListItems(manifestListFile ManifestListFile) (manifestListItemsSortedDesc []ManifestListItem, err error) {
+	return reader.storage.ExistingManifestListItems(manifestListFile)
+}
+
+func (reader *IcebergReader) ParquetFile(manifestFile ManifestFile) (parquetFile ParquetFile, err error) {
+	return reader.storage.ExistingParquetFile(manifestFile)
+}
+
+func (reader *IcebergReader) SyncStatuses() (syncStatuses []SyncStatus, err error) {
+	LogDebug(reader.config, "Reading sync statuses...")
+	return reader.storage.SyncStatuses()
+}
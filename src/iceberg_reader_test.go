@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReadSnapshot(t *testing.T) {
+	config := loadTestConfig()
+	icebergWriter := NewIcebergWriter(config)
+	icebergReader := NewIcebergReader(config)
+
+	icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+	icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+	time.Sleep(10 * time.Millisecond)
+	icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"3", "Jane"}}))
+	manifestListFiles, err := icebergReader.ManifestListFiles(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+	testNoError(t, err)
+
+	t.Cleanup(func() {
+		icebergWriter.storage.DeleteSchema(TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema)
+	})
+
+	t.Run("Returns a snapshot by ID", func(t *testing.T) {
+		snapshot, err := icebergReader.Snapshot(TEST_ICEBERG_WRITER_SCHEMA_TABLE, manifestListFiles[0].SnapshotId, time.Time{})
+
+		testNoError(t, err)
+		if snapshot.SnapshotId != manifestListFiles[0].SnapshotId || snapshot.TotalRecords != 2 {
+			t.Errorf("Expected the first snapshot, got %v", snapshot)
+		}
+	})
+
+	t.Run("Returns the snapshot that was current at a timestamp", func(t *testing.T) {
+		snapshot, err := icebergReader.Snapshot(TEST_ICEBERG_WRITER_SCHEMA_TABLE, 0, time.UnixMilli(manifestListFiles[1].TimestampMs-1))
+		testNoError(t, err)
+		if snapshot.SnapshotId != manifestListFiles[0].SnapshotId {
+			t.Errorf("Expected the first snapshot, got %v", snapshot)
+		}
+
+		snapshot, err = icebergReader.Snapshot(TEST_ICEBERG_WRITER_SCHEMA_TABLE, 0, time.Now())
+		testNoError(t, err)
+		if snapshot.SnapshotId != manifestListFiles[1].SnapshotId {
+			t.Errorf("Expected the last snapshot, got %v", snapshot)
+		}
+	})
+
+	t.Run("Returns an error for unknown snapshots", func(t *testing.T) {
+		_, err := icebergReader.Snapshot(TEST_ICEBERG_WRITER_SCHEMA_TABLE, 1, time.Time{})
+		if err == nil || err.Error() != "snapshot 1 of \"iceberg_writer_test\".\"test_table\" doesn't exist or has expired" {
+			t.Errorf("Expected a non-existent snapshot error, got %v", err)
+		}
+
+		_, err = icebergReader.Snapshot(TEST_ICEBERG_WRITER_SCHEMA_TABLE, 0, time.UnixMilli(manifestListFiles[0].TimestampMs-1))
+		if err == nil {
+			t.Errorf("Expected an error for a timestamp before the first snapshot")
+		}
+	})
+
+	t.Run("Returns a snapshot replaced by a full refresh", func(t *testing.T) {
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows([][]string{{"1", "John"}}))
+
+		snapshot, err := icebergReader.Snapshot(TEST_ICEBERG_WRITER_SCHEMA_TABLE, 0, time.UnixMilli(manifestListFiles[1].TimestampMs))
+
+		testNoError(t, err)
+		if snapshot.SnapshotId != manifestListFiles[1].SnapshotId || snapshot.TotalRecords != 3 {
+			t.Fatalf("Expected the snapshot before the full refresh, got %v", snapshot)
+		}
+		manifestListItems, err := icebergReader.ManifestListItems(snapshot)
+		testNoError(t, err)
+		for _, manifestListItem := range manifestListItems {
+			parquetFile, err := icebergWriter.storage.ExistingParquetFile(manifestListItem.ManifestFile)
+			testNoError(t, err)
+			if _, err := os.Stat(parquetFile.Path); err != nil {
+				t.Errorf("Expected data file %v of the snapshot to be kept, got %v", parquetFile.Path, err)
+			}
+		}
+	})
+}
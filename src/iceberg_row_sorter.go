@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const SORT_RUN_SIZE = 256 * 1024 * 1024 // 256 MB of rows sorted in memory before spilling them to disk
+
+// Sorts rows with PG text values by a sort order. Rows that don't fit in memory are sorted in runs
+// spilled to temporary files, which are then merged
+type IcebergRowSorter struct {
+	config        *Config
+	sortOrder     IcebergSortOrder
+	columnIndexes []int
+	sourceTypes   []string
+
+	runPaths []string
+	runFiles []*os.File
+}
+
+type icebergSortRow struct {
+	row  []string
+	keys []interface{} // nil for NULL
+}
+
+func NewIcebergRowSorter(config *Config, sortOrder IcebergSortOrder, pgSchemaColumns []PgSchemaColumn) (*IcebergRowSorter, error) {
+	sorter := &IcebergRowSorter{config: config, sortOrder: sortOrder}
+
+	for _, field := range sortOrder.Fields {
+		columnIndex := slices.IndexFunc(pgSchemaColumns, func(pgSchemaColumn PgSchemaColumn) bool {
+			return pgSchemaColumn.FieldId == field.SourceId
+		})
+		if columnIndex == -1 {
+			return nil, errors.New("sort field " + IntToString(field.SourceId) + " has no source column")
+		}
+		sorter.columnIndexes = append(sorter.columnIndexes, columnIndex)
+		sorter.sourceTypes = append(sorter.sourceTypes, pgSchemaColumns[columnIndex].icebergPrimitiveType())
+	}
+
+	return sorter, nil
+}
+
+// Consumes all rows from loadRows and returns a function loading them back sorted in batches
+func (sorter *IcebergRowSorter) Sort(loadRows func() [][]string) (func() [][]string, error) {
+	sortRows := []icebergSortRow{}
+	sortRowsSize := 0
+
+	for rows := loadRows(); len(rows) > 0; rows = loadRows() {
+		for _, row := range rows {
+			sortRows = append(sortRows, sorter.sortRow(row))
+			for _, value := range row {
+				sortRowsSize += len(value)
+			}
+		}
+
+		if sortRowsSize >= SORT_RUN_SIZE {
+			err := sorter.spillRun(sortRows)
+			if err != nil {
+				return nil, err
+			}
+			sortRows = []icebergSortRow{}
+			sortRowsSize = 0
+		}
+	}
+
+	// All rows fit in memory
+	if len(sorter.runPaths) == 0 {
+		slices.SortStableFunc(sortRows, sorter.compare)
+		return func() [][]string {
+			rows := [][]string{}
+			loadedSize := 0
+			for len(sortRows) > 0 && loadedSize < SPILL_BATCH_SIZE {
+				rows = append(rows, sortRows[0].row)
+				for _, value := range sortRows[0].row {
+					loadedSize += len(value)
+				}
+				sortRows = sortRows[1:]
+			}
+			return rows
+		}, nil
+	}
+
+	if len(sortRows) > 0 {
+		err := sorter.spillRun(sortRows)
+		if err != nil {
+			return nil, err
+		}
+	}
+	LogDebug(sorter.config, "Merging", len(sorter.runPaths), "sorted run(s)...")
+	return sorter.mergeRuns()
+}
+
+// Deletes the temporary files
+func (sorter *IcebergRowSorter) Close() error {
+	var err error
+	for _, runFile := range sorter.runFiles {
+		err = errors.Join(err, runFile.Close())
+	}
+	for _, runPath := range sorter.runPaths {
+		err = errors.Join(err, os.Remove(runPath))
+	}
+	sorter.runFiles = nil
+	sorter.runPaths = nil
+	return err
+}
+
+func (sorter *IcebergRowSorter) spillRun(sortRows []icebergSortRow) error {
+	slices.SortStableFunc(sortRows, sorter.compare)
+
+	file, err := os.CreateTemp("", "bemidb-sort-*.jsonl")
+	if err != nil {
+		return err
+	}
+	sorter.runPaths = append(sorter.runPaths, file.Name())
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, sortRow := range sortRows {
+		rowJson, err := json.Marshal(sortRow.row)
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(append(rowJson, '\n'))
+		if err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+func (sorter *IcebergRowSorter) mergeRuns() (func() [][]string, error) {
+	runHeap := &icebergSortRunHeap{sorter: sorter}
+
+	for i, runPath := range sorter.runPaths {
+		file, err := os.Open(runPath)
+		if err != nil {
+			return nil, err
+		}
+		sorter.runFiles = append(sorter.runFiles, file)
+
+		run := &icebergSortRun{reader: bufio.NewReader(file), index: i}
+		found, err := sorter.readRunRow(run)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			heap.Push(runHeap, run)
+		}
+	}
+
+	return func() [][]string {
+		rows := [][]string{}
+		loadedSize := 0
+		for runHeap.Len() > 0 && loadedSize < SPILL_BATCH_SIZE {
+			run := runHeap.runs[0] // Run with the smallest next row
+			rows = append(rows, run.sortRow.row)
+			for _, value := range run.sortRow.row {
+				loadedSize += len(value)
+			}
+
+			found, err := sorter.readRunRow(run)
+			PanicIfError(err, sorter.config)
+			if found {
+				heap.Fix(runHeap, 0)
+			} else {
+				heap.Pop(runHeap)
+			}
+		}
+		return rows
+	}, nil
+}
+
+func (sorter *IcebergRowSorter) readRunRow(run *icebergSortRun) (bool, error) {
+	line, err := run.reader.ReadBytes('\n')
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var row []string
+	err = json.Unmarshal(line, &row)
+	if err != nil {
+		return false, err
+	}
+	run.sortRow = sorter.sortRow(row)
+	return true, nil
+}
+
+func (sorter *IcebergRowSorter) sortRow(row []string) icebergSortRow {
+	keys := make([]interface{}, len(sorter.columnIndexes))
+	for i, columnIndex := range sorter.columnIndexes {
+		keys[i] = sorter.sortKey(sorter.sourceTypes[i], row[columnIndex])
+	}
+	return icebergSortRow{row: row, keys: keys}
+}
+
+// Parses a value to compare it by its type. Values that can't be parsed, e.g. infinity, are compared as strings
+func (sorter *IcebergRowSorter) sortKey(sourceType string, value string) interface{} {
+	if value == PG_NULL_STRING {
+		return nil
+	}
+
+	switch {
+	case sourceType == "int" || sourceType == "long":
+		if intValue, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return intValue
+		}
+	case sourceType == "float":
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	case strings.HasPrefix(sourceType, "decimal"):
+		if ratValue, ok := new(big.Rat).SetString(value); ok {
+			return ratValue
+		}
+	case sourceType == "boolean":
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	case sourceType == "date" || sourceType == "timestamp" || sourceType == "timestamp_ns":
+		if timeValue, err := parseIcebergPartitionTime(value); err == nil {
+			return timeValue
+		}
+	}
+	return value
+}
+
+func (sorter *IcebergRowSorter) compare(sortRow1 icebergSortRow, sortRow2 icebergSortRow) int {
+	for i, field := range sorter.sortOrder.Fields {
+		key1, key2 := sortRow1.keys[i], sortRow2.keys[i]
+
+		if key1 == nil || key2 == nil {
+			if key1 == nil && key2 == nil {
+				continue
+			}
+			if (key1 == nil) == (field.NullOrder == ICEBERG_SORT_NULLS_FIRST) {
+				return -1
+			}
+			return 1
+		}
+
+		result := compareSortKeys(key1, key2)
+		if field.Direction == ICEBERG_SORT_DIRECTION_DESC {
+			result = -result
+		}
+		if result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+func compareSortKeys(key1 interface{}, key2 interface{}) int {
+	switch typedKey1 := key1.(type) {
+	case int64:
+		if typedKey2, ok := key2.(int64); ok {
+			return cmp.Compare(typedKey1, typedKey2)
+		}
+	case float64:
+		if typedKey2, ok := key2.(float64); ok {
+			return cmp.Compare(typedKey1, typedKey2)
+		}
+	case *big.Rat:
+		if typedKey2, ok := key2.(*big.Rat); ok {
+			return typedKey1.Cmp(typedKey2)
+		}
+	case bool:
+		if typedKey2, ok := key2.(bool); ok {
+			if typedKey1 == typedKey2 {
+				return 0
+			}
+			if !typedKey1 {
+				return -1
+			}
+			return 1
+		}
+	case time.Time:
+		if typedKey2, ok := key2.(time.Time); ok {
+			return typedKey1.Compare(typedKey2)
+		}
+	case string:
+		if typedKey2, ok := key2.(string); ok {
+			return strings.Compare(typedKey1, typedKey2)
+		}
+	}
+
+	// Parsed values are sorted before values that couldn't be parsed, e.g. -infinity and infinity
+	_, isString1 := key1.(string)
+	_, isString2 := key2.(string)
+	return cmp.Compare(boolToInt(isString1), boolToInt(isString2))
+}
+
+func boolToInt(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+type icebergSortRun struct {
+	reader  *bufio.Reader
+	index   int
+	sortRow icebergSortRow
+}
+
+// Min-heap of sorted runs by their next row, keeping the order of runs for equal rows
+type icebergSortRunHeap struct {
+	sorter *IcebergRowSorter
+	runs   []*icebergSortRun
+}
+
+func (runHeap icebergSortRunHeap) Len() int { return len(runHeap.runs) }
+
+func (runHeap icebergSortRunHeap) Less(i, j int) bool {
+	result := runHeap.sorter.compare(runHeap.runs[i].sortRow, runHeap.runs[j].sortRow)
+	if result == 0 {
+		return runHeap.runs[i].index < runHeap.runs[j].index
+	}
+	return result < 0
+}
+
+func (runHeap icebergSortRunHeap) Swap(i, j int) {
+	runHeap.runs[i], runHeap.runs[j] = runHeap.runs[j], runHeap.runs[i]
+}
+
+func (runHeap *icebergSortRunHeap) Push(run any) {
+	runHeap.runs = append(runHeap.runs, run.(*icebergSortRun))
+}
+
+func (runHeap *icebergSortRunHeap) Pop() any {
+	run := runHeap.runs[len(runHeap.runs)-1]
+	runHeap.runs = runHeap.runs[:len(runHeap.runs)-1]
+	return run
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+)
+
+var ICEBERG_DECIMAL_TYPE_REGEXP = regexp.MustCompile(`^decimal\((\d+), ?(\d+)\)$`)
+
+// Schema of an Iceberg table. Field IDs are assigned by AssignIcebergFieldIds, so they stay the same across writes
+// and aren't reused when columns are dropped
+type IcebergSchema struct {
+	SchemaId           int                  `json:"schema-id"`
+	Fields             []IcebergSchemaField `json:"fields"`
+	IdentifierFieldIds []int                `json:"identifier-field-ids"`
+	FieldIdsByAttnum   map[int]int          `json:"-"` // Postgres attnums of the columns, stored in the table properties for the current schema
+}
+
+func NewIcebergSchemaFields(pgSchemaColumns []PgSchemaColumn) []IcebergSchemaField {
+	icebergSchemaFields := make([]IcebergSchemaField, len(pgSchemaColumns))
+	for i, pgSchemaColumn := range pgSchemaColumns {
+		icebergSchemaFields[i] = pgSchemaColumn.ToIcebergSchemaFieldMap()
+	}
+	return icebergSchemaFields
+}
+
+// Returns the columns with Iceberg field IDs, matched with the fields of the current schema by Postgres attnums:
+// - A column with the same attnum and name keeps the field ID
+// - A renamed column keeps the field ID of its attnum if no column has the previous name and no field has the new name
+// - A column with a changed attnum keeps the field ID of the same-named field, e.g. after a dump and restore
+// New columns and array elements get IDs after the last column ID of all schemas, like Iceberg assigns them
+func AssignIcebergFieldIds(schemasSortedAsc []IcebergSchema, pgSchemaColumns []PgSchemaColumn) []PgSchemaColumn {
+	var currentSchema IcebergSchema
+	if len(schemasSortedAsc) > 0 {
+		currentSchema = schemasSortedAsc[len(schemasSortedAsc)-1]
+	}
+	currentFieldsById := make(map[int]IcebergSchemaField)
+	currentFieldsByName := make(map[string]IcebergSchemaField)
+	for _, field := range currentSchema.Fields {
+		currentFieldsById[field.Id] = field
+		currentFieldsByName[field.Name] = field
+	}
+	columnNames := make(Set[string])
+	for _, pgSchemaColumn := range pgSchemaColumns {
+		columnNames.Add(pgSchemaColumn.NormalizedColumnName())
+	}
+	attnumField := func(pgSchemaColumn PgSchemaColumn) (IcebergSchemaField, bool) {
+		field, found := currentFieldsById[currentSchema.FieldIdsByAttnum[pgSchemaColumn.Attnum()]]
+		return field, found
+	}
+
+	assignedPgSchemaColumns := slices.Clone(pgSchemaColumns)
+	assignedFieldIds := make(Set[int])
+	assignField := func(i int, field IcebergSchemaField, found bool) {
+		if found && assignedPgSchemaColumns[i].FieldId == 0 && !assignedFieldIds.Contains(field.Id) {
+			assignedPgSchemaColumns[i].FieldId = field.Id
+			assignedPgSchemaColumns[i].ElementFieldId = icebergListElementId(field)
+			assignedFieldIds.Add(field.Id)
+		}
+	}
+	for i := range assignedPgSchemaColumns {
+		assignedPgSchemaColumns[i].FieldId = 0
+	}
+	for i, pgSchemaColumn := range pgSchemaColumns {
+		field, found := attnumField(pgSchemaColumn)
+		assignField(i, field, found && field.Name == pgSchemaColumn.NormalizedColumnName())
+	}
+	for i, pgSchemaColumn := range pgSchemaColumns {
+		field, found := attnumField(pgSchemaColumn)
+		_, nameFound := currentFieldsByName[pgSchemaColumn.NormalizedColumnName()]
+		assignField(i, field, found && !columnNames.Contains(field.Name) && !nameFound)
+	}
+	for i, pgSchemaColumn := range pgSchemaColumns {
+		field, found := currentFieldsByName[pgSchemaColumn.NormalizedColumnName()]
+		assignField(i, field, found)
+	}
+
+	lastColumnId := LastIcebergColumnId(schemasSortedAsc)
+	for i, pgSchemaColumn := range assignedPgSchemaColumns {
+		if pgSchemaColumn.FieldId == 0 {
+			lastColumnId++
+			assignedPgSchemaColumns[i].FieldId = lastColumnId
+			assignedPgSchemaColumns[i].ElementFieldId = 0
+		}
+	}
+
+	// Array elements are assigned after the columns
+	for i, pgSchemaColumn := range assignedPgSchemaColumns {
+		if pgSchemaColumn.DataType != PG_DATA_TYPE_ARRAY {
+			assignedPgSchemaColumns[i].ElementFieldId = 0
+		} else if pgSchemaColumn.ElementFieldId == 0 {
+			lastColumnId++
+			assignedPgSchemaColumns[i].ElementFieldId = lastColumnId
+		}
+	}
+	return assignedPgSchemaColumns
+}
+
+// Returns the field IDs of the columns by their Postgres attnums, which stay the same when columns are renamed
+func NewIcebergFieldIdsByAttnum(pgSchemaColumns []PgSchemaColumn) map[int]int {
+	fieldIdsByAttnum := make(map[int]int)
+	for _, pgSchemaColumn := range pgSchemaColumns {
+		if pgSchemaColumn.Attnum() > 0 {
+			fieldIdsByAttnum[pgSchemaColumn.Attnum()] = pgSchemaColumn.FieldId
+		}
+	}
+	return fieldIdsByAttnum
+}
+
+// Returns the highest field ID of all schemas, including IDs of array elements
+func LastIcebergColumnId(schemasSortedAsc []IcebergSchema) int {
+	lastColumnId := 0
+	for _, schema := range schemasSortedAsc {
+		for _, field := range schema.Fields {
+			lastColumnId = max(lastColumnId, field.Id, icebergListElementId(field))
+		}
+	}
+	return lastColumnId
+}
+
+// Returns the IDs of required primitive fields of primary key columns, which identify rows in the table
+func NewIcebergIdentifierFieldIds(pgSchemaColumns []PgSchemaColumn, icebergSchemaFields []IcebergSchemaField) []int {
+	requiredFieldIds := make(Set[int])
+	for _, field := range icebergSchemaFields {
+		if _, isPrimitive := field.Type.(string); isPrimitive && field.Required {
+			requiredFieldIds.Add(field.Id)
+		}
+	}
+
+	identifierFieldIds := []int{}
+	for _, pgSchemaColumn := range pgSchemaColumns {
+		if pgSchemaColumn.PartOfPrimaryKey && requiredFieldIds.Contains(pgSchemaColumn.FieldId) {
+			identifierFieldIds = append(identifierFieldIds, pgSchemaColumn.FieldId)
+		}
+	}
+	return identifierFieldIds
+}
+
+// Returns the schemas with a new current schema if the columns changed since the current schema.
+// If existing data files are kept, the changes must follow the Iceberg schema evolution rules:
+// - Added columns are optional since existing rows don't have them
+// - Optional columns can't become required
+// - Column types can only be widened: int to long, float to double, and decimal(P, S) to decimal(P2, S) with P2 > P
+func EvolveIcebergSchemas(schemasSortedAsc []IcebergSchema, pgSchemaColumns []PgSchemaColumn, keepDataFiles bool) ([]IcebergSchema, error) {
+	icebergSchemaFields := NewIcebergSchemaFields(pgSchemaColumns)
+	fieldIdsByAttnum := NewIcebergFieldIdsByAttnum(pgSchemaColumns)
+	if len(schemasSortedAsc) == 0 {
+		identifierFieldIds := NewIcebergIdentifierFieldIds(pgSchemaColumns, icebergSchemaFields)
+		return []IcebergSchema{{SchemaId: 0, Fields: icebergSchemaFields, IdentifierFieldIds: identifierFieldIds, FieldIdsByAttnum: fieldIdsByAttnum}}, nil
+	}
+
+	currentSchema := schemasSortedAsc[len(schemasSortedAsc)-1]
+	if keepDataFiles {
+		currentFields := make(map[int]IcebergSchemaField)
+		for _, field := range currentSchema.Fields {
+			currentFields[field.Id] = field
+		}
+
+		// Attnums aren't reused by Postgres, so a column with the attnum of another field was dropped and added again,
+		// e.g. when a table is recreated, or its name was taken by a renamed column
+		for _, pgSchemaColumn := range pgSchemaColumns {
+			fieldId, found := currentSchema.FieldIdsByAttnum[pgSchemaColumn.Attnum()]
+			if currentField, fieldFound := currentFields[fieldId]; found && fieldFound && fieldId != pgSchemaColumn.FieldId {
+				return nil, fmt.Errorf("column %s has the attnum of column %s and can't be matched with its data without rewriting existing data", pgSchemaColumn.NormalizedColumnName(), currentField.Name)
+			}
+		}
+
+		for i, field := range icebergSchemaFields {
+			currentField, found := currentFields[field.Id]
+			if !found {
+				icebergSchemaFields[i].Required = false
+				continue
+			}
+
+			if !canPromoteIcebergType(currentField.Type, field.Type) {
+				return nil, fmt.Errorf("column %s can't change its type from %v to %v without rewriting existing data", field.Name, currentField.Type, field.Type)
+			}
+			if !currentField.Required {
+				icebergSchemaFields[i].Required = false
+			}
+		}
+	}
+
+	identifierFieldIds := NewIcebergIdentifierFieldIds(pgSchemaColumns, icebergSchemaFields)
+	if equalIcebergSchemaFields(currentSchema.Fields, icebergSchemaFields) && slices.Equal(currentSchema.IdentifierFieldIds, identifierFieldIds) {
+		if maps.Equal(currentSchema.FieldIdsByAttnum, fieldIdsByAttnum) {
+			return schemasSortedAsc, nil
+		}
+
+		// Attnums change without changing the schema, e.g. after a dump and restore
+		evolvedSchemasSortedAsc := slices.Clone(schemasSortedAsc)
+		evolvedSchemasSortedAsc[len(evolvedSchemasSortedAsc)-1].FieldIdsByAttnum = fieldIdsByAttnum
+		return evolvedSchemasSortedAsc, nil
+	}
+
+	lastSchemaId := 0
+	for _, schema := range schemasSortedAsc {
+		lastSchemaId = max(lastSchemaId, schema.SchemaId)
+	}
+	return append(slices.Clone(schemasSortedAsc), IcebergSchema{SchemaId: lastSchemaId + 1, Fields: icebergSchemaFields, IdentifierFieldIds: identifierFieldIds, FieldIdsByAttnum: fieldIdsByAttnum}), nil
+}
+
+// Returns the schemas with a new current schema if the current schema can't read data files written with the previous schema,
+// e.g. after rolling back to a snapshot with data files missing columns added since then
+func RollBackIcebergSchemas(schemasSortedAsc []IcebergSchema, previousSchemaId int) ([]IcebergSchema, error) {
+	currentSchema := schemasSortedAsc[len(schemasSortedAsc)-1]
+	previousFields := make(map[int]IcebergSchemaField)
+	for _, schema := range schemasSortedAsc {
+		if schema.SchemaId == previousSchemaId {
+			for _, field := range schema.Fields {
+				previousFields[field.Id] = field
+			}
+		}
+	}
+
+	icebergSchemaFields := slices.Clone(currentSchema.Fields)
+	for i, field := range icebergSchemaFields {
+		previousField, found := previousFields[field.Id]
+		if !found || !previousField.Required {
+			icebergSchemaFields[i].Required = false
+			continue
+		}
+
+		if !canPromoteIcebergType(previousField.Type, field.Type) {
+			return nil, fmt.Errorf("column %s changed its type from %v to %v", field.Name, previousField.Type, field.Type)
+		}
+	}
+	if equalIcebergSchemaFields(currentSchema.Fields, icebergSchemaFields) {
+		return schemasSortedAsc, nil
+	}
+
+	// Identifier fields must be required
+	identifierFieldIds := []int{}
+	for _, field := range icebergSchemaFields {
+		if field.Required && slices.Contains(currentSchema.IdentifierFieldIds, field.Id) {
+			identifierFieldIds = append(identifierFieldIds, field.Id)
+		}
+	}
+
+	lastSchemaId := 0
+	for _, schema := range schemasSortedAsc {
+		lastSchemaId = max(lastSchemaId, schema.SchemaId)
+	}
+	return append(slices.Clone(schemasSortedAsc), IcebergSchema{SchemaId: lastSchemaId + 1, Fields: icebergSchemaFields, IdentifierFieldIds: identifierFieldIds, FieldIdsByAttnum: currentSchema.FieldIdsByAttnum}), nil
+}
+
+// Makes columns nullable if they are optional in the schema, e.g. added after the table had rows
+func (schema IcebergSchema) ApplyNullability(pgSchemaColumns []PgSchemaColumn) []PgSchemaColumn {
+	optionalFieldIds := make(Set[int])
+	for _, field := range schema.Fields {
+		if !field.Required {
+			optionalFieldIds.Add(field.Id)
+		}
+	}
+
+	nullablePgSchemaColumns := slices.Clone(pgSchemaColumns)
+	for i, pgSchemaColumn := range nullablePgSchemaColumns {
+		if optionalFieldIds.Contains(pgSchemaColumn.FieldId) {
+			nullablePgSchemaColumns[i].IsNullable = PG_TRUE
+		}
+	}
+	return nullablePgSchemaColumns
+}
+
+func canPromoteIcebergType(fromType interface{}, toType interface{}) bool {
+	if equalIcebergTypes(fromType, toType) {
+		return true
+	}
+
+	// Elements of lists can be widened like columns
+	fromListType, fromListOk := fromType.(map[string]interface{})
+	toListType, toListOk := toType.(map[string]interface{})
+	if fromListOk && toListOk {
+		return fromListType["type"] == "list" && toListType["type"] == "list" && canPromoteIcebergType(fromListType["element"], toListType["element"])
+	}
+
+	fromPrimitiveType, fromOk := fromType.(string)
+	toPrimitiveType, toOk := toType.(string)
+	if !fromOk || !toOk {
+		return false
+	}
+
+	switch {
+	case fromPrimitiveType == "int" && toPrimitiveType == "long":
+		return true
+	case fromPrimitiveType == "float" && toPrimitiveType == "double":
+		return true
+	}
+
+	fromDecimal := ICEBERG_DECIMAL_TYPE_REGEXP.FindStringSubmatch(fromPrimitiveType)
+	toDecimal := ICEBERG_DECIMAL_TYPE_REGEXP.FindStringSubmatch(toPrimitiveType)
+	if fromDecimal == nil || toDecimal == nil || fromDecimal[2] != toDecimal[2] {
+		return false
+	}
+	fromPrecision, err := StringToInt(fromDecimal[1])
+	if err != nil {
+		return false
+	}
+	toPrecision, err := StringToInt(toDecimal[1])
+	if err != nil {
+		return false
+	}
+	return toPrecision >= fromPrecision
+}
+
+// Returns the element ID of a list field, or 0 for other fields. Tables written by older versions have the element ID
+// as a string equal to the field ID, which is treated as missing to assign a unique ID
+func icebergListElementId(field IcebergSchemaField) int {
+	listType, ok := field.Type.(map[string]interface{})
+	if !ok || listType["type"] != "list" {
+		return 0
+	}
+
+	var elementId int
+	switch value := listType["element-id"].(type) {
+	case int:
+		elementId = value
+	case float64:
+		elementId = int(value)
+	}
+	if elementId == field.Id {
+		return 0
+	}
+	return elementId
+}
+
+// Types read from metadata files are compared by their JSON representation
+func equalIcebergTypes(type1 interface{}, type2 interface{}) bool {
+	type1Json, err1 := json.Marshal(type1)
+	type2Json, err2 := json.Marshal(type2)
+	return err1 == nil && err2 == nil && string(type1Json) == string(type2Json)
+}
+
+func equalIcebergSchemaFields(fields1 []IcebergSchemaField, fields2 []IcebergSchemaField) bool {
+	fields1Json, err1 := json.Marshal(fields1)
+	fields2Json, err2 := json.Marshal(fields2)
+	return err1 == nil && err2 == nil && string(fields1Json) == string(fields2Json)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	ICEBERG_SORT_DIRECTION_ASC  = "asc"
+	ICEBERG_SORT_DIRECTION_DESC = "desc"
+
+	ICEBERG_SORT_NULLS_FIRST = "nulls-first"
+	ICEBERG_SORT_NULLS_LAST  = "nulls-last"
+
+	ICEBERG_SORT_ORDER_ID_UNSORTED = 0
+	ICEBERG_SORT_ORDER_ID_SORTED   = 1
+)
+
+// Examples: tenant_id, created_at DESC, "Created At" ASC NULLS FIRST
+var ICEBERG_SORT_EXPRESSION_REGEXP = regexp.MustCompile(`(?i)^("(?:[^"]|"")+"|[^"\s]+)(?:\s+(ASC|DESC))?(?:\s+NULLS\s+(FIRST|LAST))?$`)
+
+type IcebergSortField struct {
+	SourceId  int
+	Transform string
+	Direction string
+	NullOrder string
+}
+
+// Order of rows within data files. Order ID 0 is reserved for unsorted data files
+type IcebergSortOrder struct {
+	OrderId int
+	Fields  []IcebergSortField
+}
+
+// Builds a sort order from sort expressions, e.g. ["tenant_id", "created_at DESC"].
+// Like in Postgres, NULLs are last in ascending order and first in descending order by default
+func NewIcebergSortOrder(expressions []string, pgSchemaColumns []PgSchemaColumn) (IcebergSortOrder, error) {
+	if len(expressions) == 0 {
+		return IcebergSortOrder{OrderId: ICEBERG_SORT_ORDER_ID_UNSORTED}, nil
+	}
+
+	sortOrder := IcebergSortOrder{OrderId: ICEBERG_SORT_ORDER_ID_SORTED}
+	for _, expression := range expressions {
+		columnName, direction, nullOrder, err := ParseIcebergSortExpression(expression)
+		if err != nil {
+			return IcebergSortOrder{}, err
+		}
+
+		columnIndex := slices.IndexFunc(pgSchemaColumns, func(pgSchemaColumn PgSchemaColumn) bool {
+			return pgSchemaColumn.ColumnName == columnName
+		})
+		if columnIndex == -1 {
+			return IcebergSortOrder{}, errors.New("sort column " + columnName + " is not synced")
+		}
+		if pgSchemaColumns[columnIndex].DataType == PG_DATA_TYPE_ARRAY {
+			return IcebergSortOrder{}, errors.New("sort column " + columnName + " can't be an array")
+		}
+
+		sortOrder.Fields = append(sortOrder.Fields, IcebergSortField{
+			SourceId:  pgSchemaColumns[columnIndex].FieldId,
+			Transform: ICEBERG_PARTITION_TRANSFORM_IDENTITY,
+			Direction: direction,
+			NullOrder: nullOrder,
+		})
+	}
+
+	return sortOrder, nil
+}
+
+// Returns the column name, the direction, and the null order of a sort expression
+func ParseIcebergSortExpression(expression string) (columnName string, direction string, nullOrder string, err error) {
+	match := ICEBERG_SORT_EXPRESSION_REGEXP.FindStringSubmatch(strings.TrimSpace(expression))
+	if match == nil {
+		return "", "", "", errors.New("invalid sort expression \"" + expression + "\". Must be a column name optionally followed by ASC or DESC and NULLS FIRST or NULLS LAST")
+	}
+
+	columnName = match[1]
+	if strings.HasPrefix(columnName, `"`) {
+		columnName = strings.ReplaceAll(columnName[1:len(columnName)-1], `""`, `"`)
+	}
+
+	direction = ICEBERG_SORT_DIRECTION_ASC
+	nullOrder = ICEBERG_SORT_NULLS_LAST
+	if strings.EqualFold(match[2], "DESC") {
+		direction = ICEBERG_SORT_DIRECTION_DESC
+		nullOrder = ICEBERG_SORT_NULLS_FIRST
+	}
+
+	switch strings.ToUpper(match[3]) {
+	case "FIRST":
+		nullOrder = ICEBERG_SORT_NULLS_FIRST
+	case "LAST":
+		nullOrder = ICEBERG_SORT_NULLS_LAST
+	}
+
+	return columnName, direction, nullOrder, nil
+}
+
+func (sortOrder IcebergSortOrder) IsSorted() bool {
+	return len(sortOrder.Fields) > 0
+}
+
+// Example: identity(1) asc nulls-last, identity(3) desc nulls-first
+func (sortOrder IcebergSortOrder) String() string {
+	fields := make([]string, len(sortOrder.Fields))
+	for i, field := range sortOrder.Fields {
+		fields[i] = field.Transform + "(" + IntToString(field.SourceId) + ") " + field.Direction + " " + field.NullOrder
+	}
+	return strings.Join(fields, ", ")
+}
+
+// The sort-orders list of the table metadata, always including the unsorted order
+func (sortOrder IcebergSortOrder) ToMetadataMaps() []interface{} {
+	sortOrders := []interface{}{
+		map[string]interface{}{
+			"order-id": ICEBERG_SORT_ORDER_ID_UNSORTED,
+			"fields":   []interface{}{},
+		},
+	}
+	if !sortOrder.IsSorted() {
+		return sortOrders
+	}
+
+	fields := []interface{}{}
+	for _, field := range sortOrder.Fields {
+		fields = append(fields, map[string]interface{}{
+			"transform":  field.Transform,
+			"source-id":  field.SourceId,
+			"direction":  field.Direction,
+			"null-order": field.NullOrder,
+		})
+	}
+	return append(sortOrders, map[string]interface{}{
+		"order-id": sortOrder.OrderId,
+		"fields":   fields,
+	})
+}
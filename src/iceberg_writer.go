@@ -1,7 +1,16 @@
 package main
 
 import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type IcebergWriter struct {
@@ -333,61 +342,166 @@ const (
 	}`
 )
 
-func (icebergWriter *IcebergWriter) Write(schemaTable IcebergSchemaTable, pgSchemaColumns []PgSchemaColumn, maxWriteParquetPayloadSize int, loadRows func() [][]string) {
+// Writes all rows returned by loadRowsFuncs into a single snapshot. Each loadRows function is consumed in parallel into its own Parquet files
+func (icebergWriter *IcebergWriter) Write(schemaTable IcebergSchemaTable, pgSchemaColumns []PgSchemaColumn, maxWriteParquetPayloadSize int, loadRowsFuncs ...func() [][]string) {
+	parquetFiles := icebergWriter.WriteParquetFiles(schemaTable, pgSchemaColumns, IcebergPartitionSpec{}, IcebergSortOrder{}, maxWriteParquetPayloadSize, loadRowsFuncs...)
+	icebergWriter.CommitParquetFiles(schemaTable, pgSchemaColumns, IcebergPartitionSpec{}, IcebergSortOrder{}, parquetFiles)
+}
+
+// Writes Parquet files without making them visible to readers until they are passed to CommitParquetFiles.
+// Rows of a partitioned table are split into Parquet files per partition, and rows within Parquet files are sorted by the sort order
+func (icebergWriter *IcebergWriter) WriteParquetFiles(schemaTable IcebergSchemaTable, pgSchemaColumns []PgSchemaColumn, partitionSpec IcebergPartitionSpec, sortOrder IcebergSortOrder, maxWriteParquetPayloadSize int, loadRowsFuncs ...func() [][]string) []ParquetFile {
 	dataDirPath := icebergWriter.storage.CreateDataDir(schemaTable)
-	manifestListItemsSortedDesc := []ManifestListItem{}
 
-	loadMoreRows := true
+	parquetFilesPerLoader := make([][]ParquetFile, len(loadRowsFuncs))
+	loaderErrs := make([]error, len(loadRowsFuncs))
+	var waitGroup sync.WaitGroup
+	for i, loadRows := range loadRowsFuncs {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			defer RecoverError(&loaderErrs[i]) // A panic in a goroutine can't be recovered by the caller
+			if partitionSpec.IsPartitioned() {
+				parquetFilesPerLoader[i] = icebergWriter.createPartitionedParquetFiles(dataDirPath, pgSchemaColumns, partitionSpec, sortOrder, maxWriteParquetPayloadSize, loadRows)
+			} else {
+				parquetFilesPerLoader[i] = icebergWriter.createSortedParquetFiles(dataDirPath, pgSchemaColumns, sortOrder, maxWriteParquetPayloadSize, loadRows)
+			}
+		}()
+	}
+	waitGroup.Wait()
+	PanicIfError(errors.Join(loaderErrs...), icebergWriter.config)
+
+	return slices.Concat(parquetFilesPerLoader...)
+}
+
+// Commits previously written Parquet files as a single full-refresh snapshot. Returns the committed Parquet files without deleted empty ones
+func (icebergWriter *IcebergWriter) CommitParquetFiles(schemaTable IcebergSchemaTable, pgSchemaColumns []PgSchemaColumn, partitionSpec IcebergPartitionSpec, sortOrder IcebergSortOrder, writtenParquetFiles []ParquetFile) []ParquetFile {
 	metadataDirPath := icebergWriter.storage.CreateMetadataDir(schemaTable)
-	var parquetFileUuid string
 
-	for loadMoreRows {
-		parquetFile, loadedAllRows, err := icebergWriter.storage.CreateParquet(dataDirPath, pgSchemaColumns, loadRows, maxWriteParquetPayloadSize)
-		PanicIfError(err, icebergWriter.config)
-		if parquetFile.RecordCount == 0 && len(manifestListItemsSortedDesc) > 0 { // Parquet is empty and already written at least one Parquet previously
-			err = icebergWriter.storage.DeleteParquet(parquetFile)
-			PanicIfError(err, icebergWriter.config)
-			break
+	parquetFiles := []ParquetFile{}
+	emptyParquetFiles := []ParquetFile{}
+	for _, parquetFile := range writtenParquetFiles {
+		if parquetFile.RecordCount == 0 {
+			emptyParquetFiles = append(emptyParquetFiles, parquetFile)
+		} else {
+			parquetFiles = append(parquetFiles, parquetFile)
 		}
+	}
 
-		manifestFile, err := icebergWriter.storage.CreateManifest(metadataDirPath, parquetFile)
+	// Keep a single empty Parquet file only if the table has no rows at all
+	if len(parquetFiles) == 0 && len(emptyParquetFiles) > 0 {
+		parquetFiles = append(parquetFiles, emptyParquetFiles[0])
+		emptyParquetFiles = emptyParquetFiles[1:]
+	}
+	for _, emptyParquetFile := range emptyParquetFiles {
+		err := icebergWriter.storage.DeleteParquet(emptyParquetFile)
 		PanicIfError(err, icebergWriter.config)
+	}
 
-		manifestListItem := ManifestListItem{SequenceNumber: 1, ManifestFile: manifestFile}
-		manifestListItemsSortedDesc = append([]ManifestListItem{manifestListItem}, manifestListItemsSortedDesc...)
+	partitionSpec = icebergWriter.evolvePartitionSpec(schemaTable, metadataDirPath, partitionSpec)
+	manifestFiles := []ManifestFile{}
+	for _, parquetFile := range parquetFiles {
+		manifestFile, err := icebergWriter.storage.CreateManifest(metadataDirPath, partitionSpec, parquetFile)
+		PanicIfError(err, icebergWriter.config)
+		manifestFiles = append(manifestFiles, manifestFile)
+	}
 
-		parquetFileUuid = parquetFile.Uuid
-		loadMoreRows = !loadedAllRows
+	// All data files are rewritten, so the columns can change in any way
+	schemasSortedAsc, err := icebergWriter.evolveSchemas(metadataDirPath, pgSchemaColumns, false)
+	PanicIfError(err, icebergWriter.config)
 
-		if loadMoreRows {
-			LogDebug(icebergWriter.config, "Written", len(manifestListItemsSortedDesc), "Parquet files, continuing...")
+	// The new snapshot is chained to the previous ones, which stay in the history until they are expired
+	lastParquetFileUuid := parquetFiles[len(parquetFiles)-1].Uuid
+	for attempt := 1; ; attempt++ {
+		existingManifestListFilesSortedAsc := []ManifestListFile{}
+		if len(icebergWriter.existingSchemas(metadataDirPath)) > 0 {
+			existingManifestListFilesSortedAsc, err = icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+			PanicIfError(err, icebergWriter.config)
+		}
+		sequenceNumber := 1
+		if len(existingManifestListFilesSortedAsc) > 0 {
+			sequenceNumber = existingManifestListFilesSortedAsc[len(existingManifestListFilesSortedAsc)-1].SequenceNumber + 1
 		}
-	}
 
-	manifestListFile, err := icebergWriter.storage.CreateManifestList(metadataDirPath, parquetFileUuid, manifestListItemsSortedDesc)
-	PanicIfError(err, icebergWriter.config)
+		manifestListItemsSortedDesc := []ManifestListItem{}
+		for _, manifestFile := range manifestFiles {
+			manifestListItem := ManifestListItem{SequenceNumber: sequenceNumber, ManifestFile: manifestFile}
+			manifestListItemsSortedDesc = append([]ManifestListItem{manifestListItem}, manifestListItemsSortedDesc...)
+		}
 
-	_, err = icebergWriter.storage.CreateMetadata(metadataDirPath, pgSchemaColumns, []ManifestListFile{manifestListFile})
-	PanicIfError(err, icebergWriter.config)
+		manifestListFile, err := icebergWriter.storage.CreateManifestList(metadataDirPath, lastParquetFileUuid, manifestListItemsSortedDesc)
+		PanicIfError(err, icebergWriter.config)
+		manifestListFile.SchemaId = schemasSortedAsc[len(schemasSortedAsc)-1].SchemaId
+
+		// Replaces all data and delete files of the previous snapshot
+		if len(existingManifestListFilesSortedAsc) > 0 {
+			lastExistingManifestListFile := existingManifestListFilesSortedAsc[len(existingManifestListFilesSortedAsc)-1]
+			manifestListFile.Operation = ICEBERG_MANIFEST_LIST_OPERATION_OVERWRITE
+			manifestListFile.DeletedDataFiles = lastExistingManifestListFile.TotalDataFiles
+			manifestListFile.DeletedRecords = lastExistingManifestListFile.TotalRecords
+			manifestListFile.RemovedFilesSize = lastExistingManifestListFile.TotalFilesSize
+			manifestListFile.RemovedDeleteFiles = lastExistingManifestListFile.TotalDeleteFiles
+			manifestListFile.RemovedPositionDeletes = lastExistingManifestListFile.TotalPositionDeletes
+		}
+
+		// A compaction may have committed a new snapshot in the meantime, the manifest list is then written again with the same path
+		_, err = icebergWriter.storage.CreateMetadata(metadataDirPath, schemasSortedAsc, partitionSpec, sortOrder, append(existingManifestListFilesSortedAsc, manifestListFile))
+		var conflictErr *MetadataCommitConflictError
+		if errors.As(err, &conflictErr) && len(existingManifestListFilesSortedAsc) > 0 && attempt < ICEBERG_METADATA_COMMIT_ATTEMPTS {
+			continue
+		}
+		PanicIfError(err, icebergWriter.config)
+
+		return parquetFiles
+	}
 }
 
-func (icebergWriter *IcebergWriter) WriteIncrementally(schemaTable IcebergSchemaTable, pgSchemaColumns []PgSchemaColumn, rowCountPerBatch int, loadRows func() [][]string) {
+// Returns the new Parquet files followed by Parquet files rewritten to overwrite UPDATEd records, or by position delete files with merge-on-read.
+// New rows of a partitioned table are split into Parquet files per partition, so rows of a partition stay in its data files.
+// Rows of the new Parquet files are sorted by the sort order, while rewritten Parquet files are marked as unsorted
+func (icebergWriter *IcebergWriter) WriteIncrementally(schemaTable IcebergSchemaTable, pgSchemaColumns []PgSchemaColumn, sortOrder IcebergSortOrder, rowCountPerBatch int, loadRows func() [][]string) []ParquetFile {
 	dataDirPath := icebergWriter.storage.CreateDataDir(schemaTable)
+	metadataDirPath := icebergWriter.storage.CreateMetadataDir(schemaTable)
 
-	// Build new parquet file
-	newParquetFile, _, err := icebergWriter.storage.CreateParquet(dataDirPath, pgSchemaColumns, loadRows, 0)
+	// Existing Parquet files are kept, so the columns can change only as allowed by the Iceberg schema evolution
+	schemasSortedAsc, err := icebergWriter.evolveSchemas(metadataDirPath, pgSchemaColumns, true)
 	PanicIfError(err, icebergWriter.config)
-	if newParquetFile.RecordCount == 0 {
-		err = icebergWriter.storage.DeleteParquet(newParquetFile)
+	currentSchema := schemasSortedAsc[len(schemasSortedAsc)-1]
+	pgSchemaColumns = currentSchema.ApplyNullability(pgSchemaColumns)
+
+	// Build new parquet files
+	partitionSpec, err := icebergWriter.storage.ExistingPartitionSpec(metadataDirPath)
+	PanicIfError(err, icebergWriter.config)
+	var newParquetFiles []ParquetFile
+	if partitionSpec.IsPartitioned() {
+		newParquetFiles = icebergWriter.createPartitionedParquetFiles(dataDirPath, pgSchemaColumns, partitionSpec, sortOrder, 0, loadRows)
+	} else {
+		newParquetFiles = icebergWriter.createSortedParquetFiles(dataDirPath, pgSchemaColumns, sortOrder, 0, loadRows)
+	}
+	newRecordCount := int64(0)
+	for _, newParquetFile := range newParquetFiles {
+		newRecordCount += newParquetFile.RecordCount
+	}
+	if newRecordCount == 0 {
+		for _, newParquetFile := range newParquetFiles {
+			err = icebergWriter.storage.DeleteParquet(newParquetFile)
+			PanicIfError(err, icebergWriter.config)
+		}
+		return []ParquetFile{}
+	}
+	writtenParquetFiles := slices.Clone(newParquetFiles)
+	lastNewParquetFile := newParquetFiles[len(newParquetFiles)-1]
+
+	newParquetFilePaths := []string{}
+	newManifestFiles := []ManifestFile{}
+	for _, newParquetFile := range newParquetFiles {
+		newManifestFile, err := icebergWriter.storage.CreateManifest(metadataDirPath, partitionSpec, newParquetFile)
 		PanicIfError(err, icebergWriter.config)
-		return
+		newParquetFilePaths = append(newParquetFilePaths, newParquetFile.Path)
+		newManifestFiles = append(newManifestFiles, newManifestFile)
 	}
 
 	// Read existing metadata
-	metadataDirPath := icebergWriter.storage.CreateMetadataDir(schemaTable)
-	newManifestFile, err := icebergWriter.storage.CreateManifest(metadataDirPath, newParquetFile)
-	PanicIfError(err, icebergWriter.config)
-
 	existingManifestListFilesSortedAsc, err := icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
 	PanicIfError(err, icebergWriter.config)
 
@@ -397,17 +511,27 @@ func (icebergWriter *IcebergWriter) WriteIncrementally(schemaTable IcebergSchema
 	slices.Reverse(existingManifestListItems)
 	existingManifestListItemsSortedAsc := existingManifestListItems
 
-	lastSequenceNumber := existingManifestListItemsSortedAsc[len(existingManifestListItemsSortedAsc)-1].SequenceNumber
+	// Higher than the sequence numbers of the manifests after a rollback to a previous snapshot
+	lastSequenceNumber := lastExistingManifestListFile.SequenceNumber
+
+	// Rewriting data files of a table with position delete files would bring back their deleted rows
+	hasPositionDeletes := slices.ContainsFunc(existingManifestListItemsSortedAsc, func(manifestListItem ManifestListItem) bool {
+		return manifestListItem.ManifestFile.PositionDeletes
+	})
+	if icebergWriter.config.Pg.IncrementalUpdateMode == PG_INCREMENTAL_UPDATE_MODE_MERGE_ON_READ || hasPositionDeletes {
+		return icebergWriter.writeIncrementallyWithPositionDeletes(dataDirPath, metadataDirPath, schemasSortedAsc, pgSchemaColumns, partitionSpec, sortOrder, newParquetFiles, newManifestFiles, existingManifestListFilesSortedAsc, existingManifestListItemsSortedAsc)
+	}
+
 	finalManifestListItemsSortedAsc := []ManifestListItem{}
 	allManifestListFilesSortedAsc := existingManifestListFilesSortedAsc
 
 	// Overwrite UPDATEd records by creating new parquet files
 	for i, existingManifestListItem := range existingManifestListItemsSortedAsc {
 		existingManifestFile := existingManifestListItem.ManifestFile
-		existingParquetFilePath, err := icebergWriter.storage.ExistingParquetFilePath(existingManifestFile)
+		existingParquetFile, err := icebergWriter.storage.ExistingParquetFile(existingManifestFile)
 		PanicIfError(err, icebergWriter.config)
 
-		overwrittenParquetFile, err := icebergWriter.storage.CreateOverwrittenParquet(dataDirPath, existingParquetFilePath, newParquetFile.Path, pgSchemaColumns, rowCountPerBatch)
+		overwrittenParquetFile, err := icebergWriter.storage.CreateOverwrittenParquet(dataDirPath, existingParquetFile.Path, newParquetFilePaths, pgSchemaColumns, rowCountPerBatch)
 		PanicIfError(err, icebergWriter.config)
 
 		// Keeping the manifest list item as is if no overlapping records found
@@ -416,6 +540,9 @@ func (icebergWriter *IcebergWriter) WriteIncrementally(schemaTable IcebergSchema
 			finalManifestListItemsSortedAsc = append(finalManifestListItemsSortedAsc, existingManifestListItem)
 			continue
 		}
+		// Rows UPDATEd into another partition are removed from the data file of their previous partition
+		overwrittenParquetFile.PartitionValues = existingParquetFile.PartitionValues
+		writtenParquetFiles = append(writtenParquetFiles, overwrittenParquetFile)
 
 		// Deleting existing manifest list file if all records are overwritten
 		if overwrittenParquetFile.RecordCount == 0 {
@@ -438,7 +565,7 @@ func (icebergWriter *IcebergWriter) WriteIncrementally(schemaTable IcebergSchema
 			slices.Reverse(overwrittenManifestListItemsSortedAsc)
 			overwrittenManifestListItemsSortedDesc := overwrittenManifestListItemsSortedAsc
 
-			overwrittenManifestList, err := icebergWriter.storage.CreateManifestList(metadataDirPath, newParquetFile.Uuid, overwrittenManifestListItemsSortedDesc)
+			overwrittenManifestList, err := icebergWriter.storage.CreateManifestList(metadataDirPath, lastNewParquetFile.Uuid, overwrittenManifestListItemsSortedDesc)
 			PanicIfError(err, icebergWriter.config)
 
 			allManifestListFilesSortedAsc = append(allManifestListFilesSortedAsc, overwrittenManifestList)
@@ -451,31 +578,331 @@ func (icebergWriter *IcebergWriter) WriteIncrementally(schemaTable IcebergSchema
 		deletedRecsManifestFile, err := icebergWriter.storage.CreateDeletedRecordsManifest(metadataDirPath, overwrittenParquetFile.Uuid, existingManifestFile)
 		PanicIfError(err, icebergWriter.config)
 
-		overwrittenManifestFile, err := icebergWriter.storage.CreateManifest(metadataDirPath, overwrittenParquetFile)
+		overwrittenManifestFile, err := icebergWriter.storage.CreateManifest(metadataDirPath, partitionSpec, overwrittenParquetFile)
 		PanicIfError(err, icebergWriter.config)
 
 		lastSequenceNumber++
 		overwrittenManifestListItem := ManifestListItem{SequenceNumber: lastSequenceNumber, ManifestFile: overwrittenManifestFile}
 		deletedRecsManifestListItem := ManifestListItem{SequenceNumber: lastSequenceNumber, ManifestFile: deletedRecsManifestFile}
-		overwrittenManifestList, err := icebergWriter.storage.CreateManifestList(metadataDirPath, newParquetFile.Uuid, []ManifestListItem{overwrittenManifestListItem, deletedRecsManifestListItem})
+		overwrittenManifestList, err := icebergWriter.storage.CreateManifestList(metadataDirPath, lastNewParquetFile.Uuid, []ManifestListItem{overwrittenManifestListItem, deletedRecsManifestListItem})
 		PanicIfError(err, icebergWriter.config)
 
 		finalManifestListItemsSortedAsc = append(finalManifestListItemsSortedAsc, overwrittenManifestListItem)
 		allManifestListFilesSortedAsc = append(allManifestListFilesSortedAsc, overwrittenManifestList)
 	}
 
-	// Stitch new parquet file with overwritten parquet files
+	// Stitch new parquet files with overwritten parquet files
 	lastSequenceNumber++
-	finalManifestListItemsSortedAsc = append(finalManifestListItemsSortedAsc, ManifestListItem{SequenceNumber: lastSequenceNumber, ManifestFile: newManifestFile})
+	for _, newManifestFile := range newManifestFiles {
+		finalManifestListItemsSortedAsc = append(finalManifestListItemsSortedAsc, ManifestListItem{SequenceNumber: lastSequenceNumber, ManifestFile: newManifestFile})
+	}
 	slices.Reverse(finalManifestListItemsSortedAsc)
 	finalManifestListItemsSortedDesc := finalManifestListItemsSortedAsc
 
-	newManifestListFile, err := icebergWriter.storage.CreateManifestList(metadataDirPath, newParquetFile.Uuid, finalManifestListItemsSortedDesc)
+	newManifestListFile, err := icebergWriter.storage.CreateManifestList(metadataDirPath, lastNewParquetFile.Uuid, finalManifestListItemsSortedDesc)
 	PanicIfError(err, icebergWriter.config)
 
 	allManifestListFilesSortedAsc = append(allManifestListFilesSortedAsc, newManifestListFile)
-	_, err = icebergWriter.storage.CreateMetadata(metadataDirPath, pgSchemaColumns, allManifestListFilesSortedAsc)
+	for i := len(existingManifestListFilesSortedAsc); i < len(allManifestListFilesSortedAsc); i++ {
+		allManifestListFilesSortedAsc[i].SchemaId = currentSchema.SchemaId
+	}
+	_, err = icebergWriter.storage.CreateMetadata(metadataDirPath, schemasSortedAsc, partitionSpec, sortOrder, allManifestListFilesSortedAsc)
 	PanicIfError(err, icebergWriter.config)
+
+	return writtenParquetFiles
+}
+
+// Keeps the existing data files and writes the positions of their rows UPDATEd by the new Parquet files into position delete files,
+// one per partition of the existing data files. Only data files with key column bounds overlapping the new Parquet files are read,
+// so the cost scales with the number of changed rows
+func (icebergWriter *IcebergWriter) writeIncrementallyWithPositionDeletes(dataDirPath string, metadataDirPath string, schemasSortedAsc []IcebergSchema, pgSchemaColumns []PgSchemaColumn, partitionSpec IcebergPartitionSpec, sortOrder IcebergSortOrder, newParquetFiles []ParquetFile, newManifestFiles []ManifestFile, existingManifestListFilesSortedAsc []ManifestListFile, existingManifestListItemsSortedAsc []ManifestListItem) []ParquetFile {
+	currentSchema := schemasSortedAsc[len(schemasSortedAsc)-1]
+	writtenParquetFiles := slices.Clone(newParquetFiles)
+
+	// Records are matched by all columns without a primary key, like with copy-on-write
+	keyPgSchemaColumns := []PgSchemaColumn{}
+	for _, pgSchemaColumn := range pgSchemaColumns {
+		if pgSchemaColumn.PartOfPrimaryKey {
+			keyPgSchemaColumns = append(keyPgSchemaColumns, pgSchemaColumn)
+		}
+	}
+	if len(keyPgSchemaColumns) == 0 {
+		keyPgSchemaColumns = pgSchemaColumns
+	}
+
+	newParquetFilePaths := []string{}
+	for _, newParquetFile := range newParquetFiles {
+		newParquetFilePaths = append(newParquetFilePaths, newParquetFile.Path)
+	}
+
+	// Delete files apply to data files of the same partition
+	overlappingParquetFilesByPartition := [][]ParquetFile{}
+	partitionIndexes := make(map[string]int)
+	for _, existingManifestListItem := range existingManifestListItemsSortedAsc {
+		if existingManifestListItem.ManifestFile.PositionDeletes {
+			continue
+		}
+		existingParquetFile, err := icebergWriter.storage.ExistingParquetFile(existingManifestListItem.ManifestFile)
+		PanicIfError(err, icebergWriter.config)
+		overlapping := slices.ContainsFunc(newParquetFiles, func(newParquetFile ParquetFile) bool {
+			return parquetFileKeyRangesOverlap(keyPgSchemaColumns, existingParquetFile, newParquetFile)
+		})
+		if !overlapping {
+			continue
+		}
+
+		partitionKey, err := json.Marshal(existingParquetFile.PartitionValues)
+		PanicIfError(err, icebergWriter.config)
+		i, found := partitionIndexes[string(partitionKey)]
+		if !found {
+			i = len(overlappingParquetFilesByPartition)
+			partitionIndexes[string(partitionKey)] = i
+			overlappingParquetFilesByPartition = append(overlappingParquetFilesByPartition, []ParquetFile{})
+		}
+		overlappingParquetFilesByPartition[i] = append(overlappingParquetFilesByPartition[i], existingParquetFile)
+	}
+
+	positionDeletesFiles := []ParquetFile{}
+	for _, overlappingParquetFiles := range overlappingParquetFilesByPartition {
+		overlappingParquetFilePaths := []string{}
+		for _, overlappingParquetFile := range overlappingParquetFiles {
+			overlappingParquetFilePaths = append(overlappingParquetFilePaths, overlappingParquetFile.Path)
+		}
+		LogDebug(icebergWriter.config, "Deleting UPDATEd records from", len(overlappingParquetFilePaths), "data file(s)...")
+
+		positionDeletesFile, err := icebergWriter.storage.CreatePositionDeletesParquet(dataDirPath, overlappingParquetFilePaths, newParquetFilePaths, keyPgSchemaColumns)
+		PanicIfError(err, icebergWriter.config)
+		if positionDeletesFile.RecordCount == 0 {
+			err = icebergWriter.storage.DeleteParquet(positionDeletesFile)
+			PanicIfError(err, icebergWriter.config)
+			continue
+		}
+		positionDeletesFile.PartitionValues = overlappingParquetFiles[0].PartitionValues
+		positionDeletesFiles = append(positionDeletesFiles, positionDeletesFile)
+	}
+
+	// Commit the new data files and the position delete files in a single snapshot
+	sequenceNumber := existingManifestListFilesSortedAsc[len(existingManifestListFilesSortedAsc)-1].SequenceNumber + 1
+	manifestListItemsSortedDesc := []ManifestListItem{}
+	for _, newManifestFile := range newManifestFiles {
+		manifestListItemsSortedDesc = append(manifestListItemsSortedDesc, ManifestListItem{SequenceNumber: sequenceNumber, ManifestFile: newManifestFile})
+	}
+	for _, positionDeletesFile := range positionDeletesFiles {
+		writtenParquetFiles = append(writtenParquetFiles, positionDeletesFile)
+
+		positionDeletesManifestFile, err := icebergWriter.storage.CreateManifest(metadataDirPath, partitionSpec, positionDeletesFile)
+		PanicIfError(err, icebergWriter.config)
+		manifestListItemsSortedDesc = append(manifestListItemsSortedDesc, ManifestListItem{SequenceNumber: sequenceNumber, ManifestFile: positionDeletesManifestFile})
+	}
+	for i := len(existingManifestListItemsSortedAsc) - 1; i >= 0; i-- {
+		manifestListItemsSortedDesc = append(manifestListItemsSortedDesc, existingManifestListItemsSortedAsc[i])
+	}
+
+	manifestListFile, err := icebergWriter.storage.CreateManifestList(metadataDirPath, newParquetFiles[len(newParquetFiles)-1].Uuid, manifestListItemsSortedDesc)
+	PanicIfError(err, icebergWriter.config)
+	manifestListFile.SchemaId = currentSchema.SchemaId
+	manifestListFile.Operation = ICEBERG_MANIFEST_LIST_OPERATION_APPEND
+	manifestListFile.AddedDataFiles = int64(len(newParquetFiles))
+	manifestListFile.AddedFilesSize = 0
+	manifestListFile.AddedRecords = 0
+	for _, newParquetFile := range newParquetFiles {
+		manifestListFile.AddedFilesSize += newParquetFile.Size
+		manifestListFile.AddedRecords += newParquetFile.RecordCount
+	}
+	if len(positionDeletesFiles) > 0 {
+		manifestListFile.Operation = ICEBERG_MANIFEST_LIST_OPERATION_OVERWRITE
+		manifestListFile.AddedDeleteFiles = int64(len(positionDeletesFiles))
+		for _, positionDeletesFile := range positionDeletesFiles {
+			manifestListFile.AddedPositionDeletes += positionDeletesFile.RecordCount
+			manifestListFile.AddedFilesSize += positionDeletesFile.Size
+		}
+	}
+
+	_, err = icebergWriter.storage.CreateMetadata(metadataDirPath, schemasSortedAsc, partitionSpec, sortOrder, append(existingManifestListFilesSortedAsc, manifestListFile))
+	PanicIfError(err, icebergWriter.config)
+
+	return writtenParquetFiles
+}
+
+// Rewrites small data files of the current snapshot into data files of up to targetFileSize and commits them as a replace snapshot.
+// Once there are maxDeleteFileCount position delete files, the data files with deleted rows are rewritten without them and the delete files are removed.
+// Replaced data files stay referenced by previous snapshots, so queries reading the previous snapshot aren't affected.
+// Returns the compacted Parquet files, or none if there are fewer than minFileCount small data files and fewer than maxDeleteFileCount delete files
+func (icebergWriter *IcebergWriter) Compact(schemaTable IcebergSchemaTable, pgSchemaColumns []PgSchemaColumn, sortOrder IcebergSortOrder, targetFileSize int64, minFileCount int, maxDeleteFileCount int) []ParquetFile {
+	dataDirPath := icebergWriter.storage.CreateDataDir(schemaTable)
+	metadataDirPath := icebergWriter.storage.CreateMetadataDir(schemaTable)
+
+	// Compacted data files are written with the current schema, which must match the columns
+	existingSchemasSortedAsc := icebergWriter.existingSchemas(metadataDirPath)
+	if len(existingSchemasSortedAsc) == 0 {
+		return []ParquetFile{}
+	}
+	schemasSortedAsc, err := EvolveIcebergSchemas(existingSchemasSortedAsc, pgSchemaColumns, true)
+	if err != nil || len(schemasSortedAsc) > len(existingSchemasSortedAsc) {
+		LogInfo(icebergWriter.config, "Skipping compaction of", schemaTable.String(), "with columns changed since the last sync")
+		return []ParquetFile{}
+	}
+	currentSchema := schemasSortedAsc[len(schemasSortedAsc)-1]
+	pgSchemaColumns = currentSchema.ApplyNullability(pgSchemaColumns)
+	// Data files of partitioned tables can't be combined across partitions
+	partitionSpec, err := icebergWriter.storage.ExistingPartitionSpec(metadataDirPath)
+	PanicIfError(err, icebergWriter.config)
+	if partitionSpec.IsPartitioned() {
+		LogInfo(icebergWriter.config, "Skipping compaction of partitioned", schemaTable.String())
+		return []ParquetFile{}
+	}
+
+	existingManifestListFilesSortedAsc, err := icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+	PanicIfError(err, icebergWriter.config)
+	lastExistingManifestListFile := existingManifestListFilesSortedAsc[len(existingManifestListFilesSortedAsc)-1]
+	existingManifestListItemsSortedDesc, err := icebergWriter.storage.ExistingManifestListItems(lastExistingManifestListFile)
+	PanicIfError(err, icebergWriter.config)
+
+	dataParquetFiles := []ParquetFile{}
+	dataManifestListItems := []ManifestListItem{}
+	positionDeletesFiles := []ParquetFile{}
+	positionDeletesFilePaths := []string{}
+	for _, existingManifestListItem := range existingManifestListItemsSortedDesc {
+		existingParquetFile, err := icebergWriter.storage.ExistingParquetFile(existingManifestListItem.ManifestFile)
+		PanicIfError(err, icebergWriter.config)
+		if existingManifestListItem.ManifestFile.PositionDeletes {
+			positionDeletesFiles = append(positionDeletesFiles, existingParquetFile)
+			positionDeletesFilePaths = append(positionDeletesFilePaths, existingParquetFile.Path)
+		} else {
+			dataParquetFiles = append(dataParquetFiles, existingParquetFile)
+			dataManifestListItems = append(dataManifestListItems, existingManifestListItem)
+		}
+	}
+
+	// Data files with deleted rows can't be combined without applying all delete files to them
+	applyDeletes := maxDeleteFileCount > 0 && len(positionDeletesFiles) >= maxDeleteFileCount
+	deletedParquetFilePaths, err := icebergWriter.storage.ExistingDeletedParquetFilePaths(positionDeletesFilePaths)
+	PanicIfError(err, icebergWriter.config)
+	deletedParquetFilePathSet := NewSet(deletedParquetFilePaths)
+
+	smallParquetFiles := []ParquetFile{}
+	smallManifestListItems := []ManifestListItem{}
+	deletedParquetFiles := []ParquetFile{}
+	deletedManifestListItems := []ManifestListItem{}
+	for i, dataParquetFile := range dataParquetFiles {
+		if deletedParquetFilePathSet.Contains(dataParquetFile.Path) {
+			if applyDeletes {
+				deletedParquetFiles = append(deletedParquetFiles, dataParquetFile)
+				deletedManifestListItems = append(deletedManifestListItems, dataManifestListItems[i])
+			}
+		} else if dataParquetFile.Size < targetFileSize {
+			smallParquetFiles = append(smallParquetFiles, dataParquetFile)
+			smallManifestListItems = append(smallManifestListItems, dataManifestListItems[i])
+		}
+	}
+	compactSmallFiles := minFileCount > 0 && len(smallParquetFiles) >= max(minFileCount, 2)
+	if !compactSmallFiles && !applyDeletes {
+		LogDebug(icebergWriter.config, "Skipping compaction of", schemaTable.String(), "with", len(smallParquetFiles), "small data file(s) and", len(positionDeletesFiles), "delete file(s)")
+		return []ParquetFile{}
+	}
+
+	// Compact each bin with multiple small data files, or with data files with deleted rows, into a single data file
+	replacedManifestPaths := make(Set[string])
+	compactedParquetFiles := []ParquetFile{}
+	compactedManifestFiles := []ManifestFile{}
+	var replacedDataFiles, replacedFilesSize, replacedRecords int64
+	compactBins := func(parquetFiles []ParquetFile, manifestListItems []ManifestListItem, minBinFileCount int, binPositionDeletesFilePaths []string) {
+		for _, bin := range binPackParquetFiles(parquetFiles, targetFileSize) {
+			if len(bin) < minBinFileCount {
+				continue
+			}
+
+			parquetFilePaths := []string{}
+			for _, i := range bin {
+				parquetFilePaths = append(parquetFilePaths, parquetFiles[i].Path)
+				replacedManifestPaths.Add(manifestListItems[i].ManifestFile.Path)
+				replacedDataFiles++
+				replacedFilesSize += parquetFiles[i].Size
+				replacedRecords += parquetFiles[i].RecordCount
+			}
+			LogDebug(icebergWriter.config, "Compacting", len(parquetFilePaths), "data file(s) of", schemaTable.String(), "...")
+
+			// Kept even without rows, so that the new snapshot has a new data file
+			compactedParquetFile, err := icebergWriter.storage.CreateCompactedParquet(dataDirPath, parquetFilePaths, binPositionDeletesFilePaths, pgSchemaColumns, sortOrder)
+			PanicIfError(err, icebergWriter.config)
+			compactedParquetFiles = append(compactedParquetFiles, compactedParquetFile)
+
+			compactedManifestFile, err := icebergWriter.storage.CreateManifest(metadataDirPath, partitionSpec, compactedParquetFile)
+			PanicIfError(err, icebergWriter.config)
+			compactedManifestFiles = append(compactedManifestFiles, compactedManifestFile)
+		}
+	}
+	if compactSmallFiles {
+		compactBins(smallParquetFiles, smallManifestListItems, 2, nil)
+	}
+	if applyDeletes {
+		compactBins(deletedParquetFiles, deletedManifestListItems, 1, positionDeletesFilePaths)
+	}
+	if len(compactedParquetFiles) == 0 {
+		LogDebug(icebergWriter.config, "Skipping compaction of", schemaTable.String(), "without data files to rewrite")
+		return []ParquetFile{}
+	}
+
+	// Replace the compacted data files with a new snapshot, keeping the other data files as is
+	compactedSequenceNumber := lastExistingManifestListFile.SequenceNumber + 1
+	manifestListItemsSortedDesc := []ManifestListItem{}
+	for _, compactedManifestFile := range compactedManifestFiles {
+		manifestListItemsSortedDesc = append(manifestListItemsSortedDesc, ManifestListItem{SequenceNumber: compactedSequenceNumber, ManifestFile: compactedManifestFile})
+	}
+	for _, existingManifestListItem := range existingManifestListItemsSortedDesc {
+		if applyDeletes && existingManifestListItem.ManifestFile.PositionDeletes {
+			continue
+		}
+		if !replacedManifestPaths.Contains(existingManifestListItem.ManifestFile.Path) {
+			manifestListItemsSortedDesc = append(manifestListItemsSortedDesc, existingManifestListItem)
+		}
+	}
+
+	manifestListFile, err := icebergWriter.storage.CreateManifestList(metadataDirPath, compactedParquetFiles[0].Uuid, manifestListItemsSortedDesc)
+	PanicIfError(err, icebergWriter.config)
+	manifestListFile.SchemaId = currentSchema.SchemaId
+	manifestListFile.Operation = ICEBERG_MANIFEST_LIST_OPERATION_REPLACE
+	manifestListFile.AddedDataFiles = int64(len(compactedParquetFiles))
+	manifestListFile.AddedFilesSize = 0
+	manifestListFile.AddedRecords = 0
+	for _, compactedParquetFile := range compactedParquetFiles {
+		manifestListFile.AddedFilesSize += compactedParquetFile.Size
+		manifestListFile.AddedRecords += compactedParquetFile.RecordCount
+	}
+	manifestListFile.DeletedDataFiles = replacedDataFiles
+	manifestListFile.RemovedFilesSize = replacedFilesSize
+	manifestListFile.DeletedRecords = replacedRecords
+	if applyDeletes {
+		manifestListFile.RemovedDeleteFiles = int64(len(positionDeletesFiles))
+		for _, positionDeletesFile := range positionDeletesFiles {
+			manifestListFile.RemovedFilesSize += positionDeletesFile.Size
+			manifestListFile.RemovedPositionDeletes += positionDeletesFile.RecordCount
+		}
+	}
+
+	// A sync may have committed a new snapshot in the meantime
+	_, err = icebergWriter.storage.CreateMetadata(metadataDirPath, schemasSortedAsc, partitionSpec, sortOrder, append(existingManifestListFilesSortedAsc, manifestListFile))
+	var conflictErr *MetadataCommitConflictError
+	if errors.As(err, &conflictErr) {
+		LogWarn(icebergWriter.config, "Skipping compaction of", schemaTable.String(), "changed during the compaction")
+		filePaths := []string{manifestListFile.Path}
+		for i := range compactedParquetFiles {
+			filePaths = append(filePaths, compactedParquetFiles[i].Path, compactedManifestFiles[i].Path)
+		}
+		for _, filePath := range filePaths {
+			err = icebergWriter.storage.DeleteFile(filePath)
+			PanicIfError(err, icebergWriter.config)
+		}
+		return []ParquetFile{}
+	}
+	PanicIfError(err, icebergWriter.config)
+
+	if applyDeletes {
+		LogInfo(icebergWriter.config, "Compacted", replacedDataFiles, "data file(s) of", schemaTable.String(), "into", len(compactedParquetFiles), "applying", len(positionDeletesFiles), "delete file(s)")
+	} else {
+		LogInfo(icebergWriter.config, "Compacted", replacedDataFiles, "data file(s) of", schemaTable.String(), "into", len(compactedParquetFiles))
+	}
+	return compactedParquetFiles
 }
 
 func (icebergWriter *IcebergWriter) DeleteSchemaTable(schemaTable IcebergSchemaTable) {
@@ -487,3 +914,573 @@ func (icebergWriter *IcebergWriter) DeleteSchema(schema string) {
 	err := icebergWriter.storage.DeleteSchema(schema)
 	PanicIfError(err, icebergWriter.config)
 }
+
+// Returns the columns with field IDs of the table's current schema, see AssignIcebergFieldIds
+func (icebergWriter *IcebergWriter) AssignFieldIds(schemaTable IcebergSchemaTable, pgSchemaColumns []PgSchemaColumn) []PgSchemaColumn {
+	metadataDirPath := icebergWriter.storage.CreateMetadataDir(schemaTable)
+	return AssignIcebergFieldIds(icebergWriter.existingSchemas(metadataDirPath), pgSchemaColumns)
+}
+
+// Returns an error if the columns changed in a way that requires rewriting existing data files, e.g. from text to integer
+func (icebergWriter *IcebergWriter) ValidateSchemaEvolution(schemaTable IcebergSchemaTable, pgSchemaColumns []PgSchemaColumn) error {
+	metadataDirPath := icebergWriter.storage.CreateMetadataDir(schemaTable)
+	_, err := EvolveIcebergSchemas(icebergWriter.existingSchemas(metadataDirPath), pgSchemaColumns, true)
+	return err
+}
+
+// Deletes data and metadata files which are referenced neither by the table metadata nor by keptFilePaths, e.g. left after a crash
+func (icebergWriter *IcebergWriter) DeleteUnreferencedFiles(schemaTable IcebergSchemaTable, keptFilePaths []string) {
+	dataDirPath := icebergWriter.storage.CreateDataDir(schemaTable)
+	metadataDirPath := icebergWriter.storage.CreateMetadataDir(schemaTable)
+
+	metadataFilePaths, err := icebergWriter.storage.ExistingFilePaths(metadataDirPath)
+	PanicIfError(err, icebergWriter.config)
+	dataFilePaths, err := icebergWriter.storage.ExistingFilePaths(dataDirPath)
+	PanicIfError(err, icebergWriter.config)
+
+	referencedFilePaths := icebergWriter.tableReferencedFilePaths(metadataDirPath)
+	for _, keptFilePath := range keptFilePaths {
+		referencedFilePaths.Add(keptFilePath)
+	}
+
+	for _, filePath := range slices.Concat(metadataFilePaths, dataFilePaths) {
+		if (strings.HasSuffix(filePath, ".parquet") || strings.HasSuffix(filePath, ".avro")) && !referencedFilePaths.Contains(filePath) {
+			LogDebug(icebergWriter.config, "Deleting unreferenced file:", filePath)
+			err = icebergWriter.storage.DeleteFile(filePath)
+			PanicIfError(err, icebergWriter.config)
+		}
+	}
+}
+
+// Removes snapshots created before expireBefore from the table metadata, keeping at least the last retainLast snapshots
+// and the snapshots since the oldest one referenced by a tag or branch, and deletes the data files, manifests and manifest lists referenced only by the removed snapshots.
+// Returns the number of expired snapshots and the deleted file paths. With dryRun, nothing is changed
+func (icebergWriter *IcebergWriter) ExpireSnapshots(schemaTable IcebergSchemaTable, expireBefore time.Time, retainLast int, dryRun bool) (expiredSnapshotCount int, deletedFilePaths []string) {
+	metadataDirPath := icebergWriter.storage.CreateMetadataDir(schemaTable)
+	schemasSortedAsc := icebergWriter.existingSchemas(metadataDirPath)
+	if len(schemasSortedAsc) == 0 {
+		return 0, []string{}
+	}
+
+	manifestListFilesSortedAsc, err := icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+	PanicIfError(err, icebergWriter.config)
+
+	// The current snapshot is always retained
+	retainedSnapshotCount := max(retainLast, 1)
+	for expiredSnapshotCount < len(manifestListFilesSortedAsc)-retainedSnapshotCount && manifestListFilesSortedAsc[expiredSnapshotCount].TimestampMs < expireBefore.UnixMilli() && len(manifestListFilesSortedAsc[expiredSnapshotCount].Refs) == 0 {
+		expiredSnapshotCount++
+	}
+	if expiredSnapshotCount == 0 {
+		return 0, []string{}
+	}
+	expiredManifestListFilesSortedAsc := manifestListFilesSortedAsc[:expiredSnapshotCount]
+	retainedManifestListFilesSortedAsc := manifestListFilesSortedAsc[expiredSnapshotCount:]
+
+	retainedFilePaths := icebergWriter.referencedFilePaths(retainedManifestListFilesSortedAsc)
+	deletedFilePaths = []string{}
+	for _, filePath := range icebergWriter.referencedFilePaths(expiredManifestListFilesSortedAsc).Values() {
+		if !retainedFilePaths.Contains(filePath) {
+			deletedFilePaths = append(deletedFilePaths, filePath)
+		}
+	}
+	slices.Sort(deletedFilePaths)
+
+	if dryRun {
+		return expiredSnapshotCount, deletedFilePaths
+	}
+
+	partitionSpec, err := icebergWriter.storage.ExistingPartitionSpec(metadataDirPath)
+	PanicIfError(err, icebergWriter.config)
+	sortOrder, err := icebergWriter.storage.ExistingSortOrder(metadataDirPath)
+	PanicIfError(err, icebergWriter.config)
+
+	// A sync may have committed a new snapshot in the meantime
+	_, err = icebergWriter.storage.CreateMetadata(metadataDirPath, schemasSortedAsc, partitionSpec, sortOrder, retainedManifestListFilesSortedAsc)
+	var conflictErr *MetadataCommitConflictError
+	if errors.As(err, &conflictErr) {
+		LogWarn(icebergWriter.config, "Skipping snapshot expiration of", schemaTable.String(), "changed during the expiration")
+		return 0, []string{}
+	}
+	PanicIfError(err, icebergWriter.config)
+
+	for _, filePath := range deletedFilePaths {
+		LogDebug(icebergWriter.config, "Deleting expired file:", filePath)
+		err = icebergWriter.storage.DeleteFile(filePath)
+		PanicIfError(err, icebergWriter.config)
+	}
+
+	return expiredSnapshotCount, deletedFilePaths
+}
+
+// Deletes data and metadata files modified before modifiedBefore which are referenced neither by any snapshot nor by keptFilePaths,
+// e.g. written by a failed sync. Recent files are kept since they may belong to a sync in progress.
+// Returns the deleted file paths. With dryRun, nothing is deleted
+func (icebergWriter *IcebergWriter) RemoveOrphanFiles(schemaTable IcebergSchemaTable, keptFilePaths []string, modifiedBefore time.Time, dryRun bool) (deletedFilePaths []string) {
+	dataDirPath := icebergWriter.storage.CreateDataDir(schemaTable)
+	metadataDirPath := icebergWriter.storage.CreateMetadataDir(schemaTable)
+	if len(icebergWriter.existingSchemas(metadataDirPath)) == 0 {
+		return []string{}
+	}
+
+	referencedFilePaths := icebergWriter.tableReferencedFilePaths(metadataDirPath)
+	for _, keptFilePath := range keptFilePaths {
+		referencedFilePaths.Add(keptFilePath)
+	}
+
+	// Listed after reading the snapshots, so files committed in the meantime aren't deleted
+	metadataFilePaths, err := icebergWriter.storage.ExistingFilePathsModifiedBefore(metadataDirPath, modifiedBefore)
+	PanicIfError(err, icebergWriter.config)
+	dataFilePaths, err := icebergWriter.storage.ExistingFilePathsModifiedBefore(dataDirPath, modifiedBefore)
+	PanicIfError(err, icebergWriter.config)
+
+	deletedFilePaths = []string{}
+	for _, filePath := range slices.Concat(metadataFilePaths, dataFilePaths) {
+		if (strings.HasSuffix(filePath, ".parquet") || strings.HasSuffix(filePath, ".avro")) && !referencedFilePaths.Contains(filePath) {
+			deletedFilePaths = append(deletedFilePaths, filePath)
+		}
+	}
+
+	if dryRun {
+		return deletedFilePaths
+	}
+
+	for _, filePath := range deletedFilePaths {
+		LogDebug(icebergWriter.config, "Deleting orphan file:", filePath)
+		err = icebergWriter.storage.DeleteFile(filePath)
+		PanicIfError(err, icebergWriter.config)
+	}
+
+	return deletedFilePaths
+}
+
+// Commits a new snapshot with the data and delete files of a previous snapshot, restoring the table to its state at that snapshot.
+// Snapshots in between stay in the history, so a rollback can be undone by rolling back to the snapshot before it. Returns the new snapshot
+func (icebergWriter *IcebergWriter) Rollback(schemaTable IcebergSchemaTable, snapshotId int64) ManifestListFile {
+	metadataDirPath := icebergWriter.storage.CreateMetadataDir(schemaTable)
+	existingSchemasSortedAsc := icebergWriter.existingSchemas(metadataDirPath)
+	if len(existingSchemasSortedAsc) == 0 {
+		panic(NewRecoverableError(fmt.Errorf("table %s doesn't exist", schemaTable.String()), nil))
+	}
+	partitionSpec, err := icebergWriter.storage.ExistingPartitionSpec(metadataDirPath)
+	PanicIfError(err, icebergWriter.config)
+	sortOrder, err := icebergWriter.storage.ExistingSortOrder(metadataDirPath)
+	PanicIfError(err, icebergWriter.config)
+
+	existingManifestListFilesSortedAsc, err := icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+	PanicIfError(err, icebergWriter.config)
+	lastExistingManifestListFile := existingManifestListFilesSortedAsc[len(existingManifestListFilesSortedAsc)-1]
+	targetIndex := slices.IndexFunc(existingManifestListFilesSortedAsc, func(manifestListFile ManifestListFile) bool {
+		return manifestListFile.SnapshotId == snapshotId
+	})
+	if targetIndex == -1 {
+		panic(NewRecoverableError(fmt.Errorf("snapshot %d of %s doesn't exist or has expired", snapshotId, schemaTable.String()), nil))
+	}
+	if snapshotId == lastExistingManifestListFile.SnapshotId {
+		panic(NewRecoverableError(fmt.Errorf("snapshot %d is already the current snapshot of %s", snapshotId, schemaTable.String()), nil))
+	}
+	targetManifestListFile := existingManifestListFilesSortedAsc[targetIndex]
+
+	schemasSortedAsc, err := RollBackIcebergSchemas(existingSchemasSortedAsc, targetManifestListFile.SchemaId)
+	if err != nil {
+		panic(NewRecoverableError(fmt.Errorf("can't roll back %s to snapshot %d: %w", schemaTable.String(), snapshotId, err), nil))
+	}
+
+	currentManifestListItems, err := icebergWriter.storage.ExistingManifestListItems(lastExistingManifestListFile)
+	PanicIfError(err, icebergWriter.config)
+	targetManifestListItems, err := icebergWriter.storage.ExistingManifestListItems(targetManifestListFile)
+	PanicIfError(err, icebergWriter.config)
+
+	// Manifests of deleted records don't belong to the table state, and the other manifests keep their sequence numbers
+	currentManifestPaths := make(Set[string])
+	for _, currentManifestListItem := range currentManifestListItems {
+		currentManifestPaths.Add(currentManifestListItem.ManifestFile.Path)
+	}
+	targetManifestPaths := make(Set[string])
+	manifestListItemsSortedDesc := []ManifestListItem{}
+	for _, targetManifestListItem := range targetManifestListItems {
+		if !targetManifestListItem.ManifestFile.RecordsDeleted {
+			targetManifestPaths.Add(targetManifestListItem.ManifestFile.Path)
+			manifestListItemsSortedDesc = append(manifestListItemsSortedDesc, targetManifestListItem)
+		}
+	}
+	if len(manifestListItemsSortedDesc) == 0 {
+		panic(NewRecoverableError(fmt.Errorf("snapshot %d of %s has no data files", snapshotId, schemaTable.String()), nil))
+	}
+
+	// The snapshot may have been committed before the partitioning changed
+	if targetPartitionSpecId := manifestListItemsSortedDesc[0].ManifestFile.PartitionSpecId; targetPartitionSpecId != partitionSpec.SpecId {
+		partitionSpecsSortedAsc, err := icebergWriter.storage.ExistingPartitionSpecs(metadataDirPath)
+		PanicIfError(err, icebergWriter.config)
+		i := slices.IndexFunc(partitionSpecsSortedAsc, func(existingPartitionSpec IcebergPartitionSpec) bool {
+			return existingPartitionSpec.SpecId == targetPartitionSpecId
+		})
+		if i == -1 {
+			panic(NewRecoverableError(fmt.Errorf("partition spec %d of snapshot %d of %s doesn't exist", targetPartitionSpecId, snapshotId, schemaTable.String()), nil))
+		}
+		partitionSpec = partitionSpecsSortedAsc[i]
+	}
+
+	manifestListFile, err := icebergWriter.storage.CreateManifestList(metadataDirPath, uuid.New().String(), manifestListItemsSortedDesc)
+	PanicIfError(err, icebergWriter.config)
+	manifestListFile = ManifestListFile{
+		SequenceNumber: lastExistingManifestListFile.SequenceNumber + 1,
+		SnapshotId:     time.Now().UnixNano(), // The manifests were added by previous snapshots
+		SchemaId:       schemasSortedAsc[len(schemasSortedAsc)-1].SchemaId,
+		TimestampMs:    manifestListFile.TimestampMs,
+		Path:           manifestListFile.Path,
+		Operation:      ICEBERG_MANIFEST_LIST_OPERATION_OVERWRITE,
+	}
+
+	// Files of the previous snapshot are added back, while files committed since then are removed
+	for _, targetManifestListItem := range manifestListItemsSortedDesc {
+		if currentManifestPaths.Contains(targetManifestListItem.ManifestFile.Path) {
+			continue
+		}
+		parquetFile, err := icebergWriter.storage.ExistingParquetFile(targetManifestListItem.ManifestFile)
+		PanicIfError(err, icebergWriter.config)
+		manifestListFile.AddedFilesSize += parquetFile.Size
+		if parquetFile.PositionDeletes {
+			manifestListFile.AddedDeleteFiles++
+			manifestListFile.AddedPositionDeletes += parquetFile.RecordCount
+		} else {
+			manifestListFile.AddedDataFiles++
+			manifestListFile.AddedRecords += parquetFile.RecordCount
+		}
+	}
+	for _, currentManifestListItem := range currentManifestListItems {
+		if targetManifestPaths.Contains(currentManifestListItem.ManifestFile.Path) {
+			continue
+		}
+		parquetFile, err := icebergWriter.storage.ExistingParquetFile(currentManifestListItem.ManifestFile)
+		PanicIfError(err, icebergWriter.config)
+		manifestListFile.RemovedFilesSize += parquetFile.Size
+		if parquetFile.PositionDeletes {
+			manifestListFile.RemovedDeleteFiles++
+			manifestListFile.RemovedPositionDeletes += parquetFile.RecordCount
+		} else {
+			manifestListFile.DeletedDataFiles++
+			manifestListFile.DeletedRecords += parquetFile.RecordCount
+		}
+	}
+
+	// A sync may have committed a new snapshot in the meantime
+	_, err = icebergWriter.storage.CreateMetadata(metadataDirPath, schemasSortedAsc, partitionSpec, sortOrder, append(existingManifestListFilesSortedAsc, manifestListFile))
+	var conflictErr *MetadataCommitConflictError
+	if errors.As(err, &conflictErr) {
+		deleteErr := icebergWriter.storage.DeleteFile(manifestListFile.Path)
+		PanicIfError(deleteErr, icebergWriter.config)
+	}
+	PanicIfError(err, icebergWriter.config)
+
+	return manifestListFile
+}
+
+// Points a tag or branch to the snapshot, moving it if the table already has a ref with the same name
+func (icebergWriter *IcebergWriter) SetSnapshotRef(schemaTable IcebergSchemaTable, snapshotId int64, snapshotRef SnapshotRef) {
+	icebergWriter.commitSnapshotRefs(schemaTable, func(manifestListFilesSortedAsc []ManifestListFile) bool {
+		i := slices.IndexFunc(manifestListFilesSortedAsc, func(manifestListFile ManifestListFile) bool {
+			return manifestListFile.SnapshotId == snapshotId
+		})
+		if i == -1 {
+			panic(NewRecoverableError(fmt.Errorf("snapshot %d of %s doesn't exist or has expired", snapshotId, schemaTable.String()), nil))
+		}
+
+		removeSnapshotRef(manifestListFilesSortedAsc, snapshotRef.Name)
+		manifestListFilesSortedAsc[i].Refs = append(manifestListFilesSortedAsc[i].Refs, snapshotRef)
+		return true
+	})
+}
+
+// Removes a tag or branch. Returns false if the table has no ref with the name
+func (icebergWriter *IcebergWriter) RemoveSnapshotRef(schemaTable IcebergSchemaTable, name string) (removed bool) {
+	icebergWriter.commitSnapshotRefs(schemaTable, func(manifestListFilesSortedAsc []ManifestListFile) bool {
+		removed = removeSnapshotRef(manifestListFilesSortedAsc, name)
+		return removed
+	})
+	return removed
+}
+
+// Tags the current snapshot as a restore point unless it already is one, and removes the oldest restore points beyond retainCount.
+// Restore points are the tags named with ICEBERG_RESTORE_POINT_TAG_PREFIX, other tags and branches are kept
+func (icebergWriter *IcebergWriter) TagRestorePoint(schemaTable IcebergSchemaTable, name string, retainCount int) {
+	icebergWriter.commitSnapshotRefs(schemaTable, func(manifestListFilesSortedAsc []ManifestListFile) bool {
+		if len(manifestListFilesSortedAsc) == 0 {
+			return false
+		}
+		lastManifestListFile := &manifestListFilesSortedAsc[len(manifestListFilesSortedAsc)-1]
+		if slices.ContainsFunc(lastManifestListFile.Refs, isRestorePointRef) {
+			return false
+		}
+		lastManifestListFile.Refs = append(lastManifestListFile.Refs, SnapshotRef{Name: name, Type: ICEBERG_SNAPSHOT_REF_TAG})
+
+		restorePointCount := 0
+		for i := len(manifestListFilesSortedAsc) - 1; i >= 0; i-- {
+			manifestListFilesSortedAsc[i].Refs = slices.DeleteFunc(manifestListFilesSortedAsc[i].Refs, func(snapshotRef SnapshotRef) bool {
+				if !isRestorePointRef(snapshotRef) {
+					return false
+				}
+				restorePointCount++
+				return restorePointCount > retainCount
+			})
+		}
+		return true
+	})
+}
+
+// Commits the refs changed by updateRefs, which returns false if nothing changed. Retries if a sync committed a new snapshot in the meantime
+func (icebergWriter *IcebergWriter) commitSnapshotRefs(schemaTable IcebergSchemaTable, updateRefs func(manifestListFilesSortedAsc []ManifestListFile) bool) {
+	metadataDirPath := icebergWriter.storage.CreateMetadataDir(schemaTable)
+
+	for attempt := 1; ; attempt++ {
+		schemasSortedAsc := icebergWriter.existingSchemas(metadataDirPath)
+		if len(schemasSortedAsc) == 0 {
+			panic(NewRecoverableError(fmt.Errorf("table %s doesn't exist", schemaTable.String()), nil))
+		}
+		partitionSpec, err := icebergWriter.storage.ExistingPartitionSpec(metadataDirPath)
+		PanicIfError(err, icebergWriter.config)
+		sortOrder, err := icebergWriter.storage.ExistingSortOrder(metadataDirPath)
+		PanicIfError(err, icebergWriter.config)
+		manifestListFilesSortedAsc, err := icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+		PanicIfError(err, icebergWriter.config)
+
+		if !updateRefs(manifestListFilesSortedAsc) {
+			return
+		}
+
+		_, err = icebergWriter.storage.CreateMetadata(metadataDirPath, schemasSortedAsc, partitionSpec, sortOrder, manifestListFilesSortedAsc)
+		var conflictErr *MetadataCommitConflictError
+		if errors.As(err, &conflictErr) && attempt < ICEBERG_METADATA_COMMIT_ATTEMPTS {
+			continue
+		}
+		PanicIfError(err, icebergWriter.config)
+		return
+	}
+}
+
+// Returns true if a ref with the name was removed
+func removeSnapshotRef(manifestListFilesSortedAsc []ManifestListFile, name string) bool {
+	removed := false
+	for i := range manifestListFilesSortedAsc {
+		manifestListFilesSortedAsc[i].Refs = slices.DeleteFunc(manifestListFilesSortedAsc[i].Refs, func(snapshotRef SnapshotRef) bool {
+			if snapshotRef.Name == name {
+				removed = true
+				return true
+			}
+			return false
+		})
+	}
+	return removed
+}
+
+func isRestorePointRef(snapshotRef SnapshotRef) bool {
+	return snapshotRef.Type == ICEBERG_SNAPSHOT_REF_TAG && strings.HasPrefix(snapshotRef.Name, ICEBERG_RESTORE_POINT_TAG_PREFIX)
+}
+
+// Returns the manifest lists, manifests and data files referenced by the snapshots in the table metadata, if any
+func (icebergWriter *IcebergWriter) tableReferencedFilePaths(metadataDirPath string) Set[string] {
+	if len(icebergWriter.existingSchemas(metadataDirPath)) == 0 {
+		return make(Set[string])
+	}
+
+	manifestListFilesSortedAsc, err := icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+	PanicIfError(err, icebergWriter.config)
+	return icebergWriter.referencedFilePaths(manifestListFilesSortedAsc)
+}
+
+// Returns the manifest lists, manifests and data files referenced by the snapshots
+func (icebergWriter *IcebergWriter) referencedFilePaths(manifestListFilesSortedAsc []ManifestListFile) Set[string] {
+	referencedFilePaths := make(Set[string])
+
+	for _, manifestListFile := range manifestListFilesSortedAsc {
+		referencedFilePaths.Add(manifestListFile.Path)
+
+		manifestListItems, err := icebergWriter.storage.ExistingManifestListItems(manifestListFile)
+		PanicIfError(err, icebergWriter.config)
+
+		for _, manifestListItem := range manifestListItems {
+			if referencedFilePaths.Contains(manifestListItem.ManifestFile.Path) {
+				continue // Shared with a previous snapshot
+			}
+			referencedFilePaths.Add(manifestListItem.ManifestFile.Path)
+
+			parquetFilePath, err := icebergWriter.storage.ExistingParquetFilePath(manifestListItem.ManifestFile)
+			PanicIfError(err, icebergWriter.config)
+			referencedFilePaths.Add(parquetFilePath)
+		}
+	}
+
+	return referencedFilePaths
+}
+
+// Returns the existing schemas with a new current schema if the columns changed
+func (icebergWriter *IcebergWriter) evolveSchemas(metadataDirPath string, pgSchemaColumns []PgSchemaColumn, keepDataFiles bool) ([]IcebergSchema, error) {
+	existingSchemasSortedAsc := icebergWriter.existingSchemas(metadataDirPath)
+	schemasSortedAsc, err := EvolveIcebergSchemas(existingSchemasSortedAsc, pgSchemaColumns, keepDataFiles)
+	if err != nil {
+		return nil, err
+	}
+	if len(schemasSortedAsc) > len(existingSchemasSortedAsc) && len(existingSchemasSortedAsc) > 0 {
+		LogInfo(icebergWriter.config, "Columns changed, evolving the Iceberg schema to schema ID", schemasSortedAsc[len(schemasSortedAsc)-1].SchemaId)
+	}
+	return schemasSortedAsc, nil
+}
+
+// Returns the partition spec with the ID of an existing spec with the same fields, or with a new ID if the partitioning changed
+func (icebergWriter *IcebergWriter) evolvePartitionSpec(schemaTable IcebergSchemaTable, metadataDirPath string, partitionSpec IcebergPartitionSpec) IcebergPartitionSpec {
+	if len(icebergWriter.existingSchemas(metadataDirPath)) == 0 {
+		return partitionSpec
+	}
+
+	existingPartitionSpecsSortedAsc, err := icebergWriter.storage.ExistingPartitionSpecs(metadataDirPath)
+	PanicIfError(err, icebergWriter.config)
+	evolvedPartitionSpec := EvolveIcebergPartitionSpec(existingPartitionSpecsSortedAsc, partitionSpec)
+	if !slices.ContainsFunc(existingPartitionSpecsSortedAsc, func(existingPartitionSpec IcebergPartitionSpec) bool {
+		return existingPartitionSpec.SpecId == evolvedPartitionSpec.SpecId
+	}) {
+		LogInfo(icebergWriter.config, "Partitioning of", schemaTable.String(), "changed, evolving the Iceberg partition spec to spec ID", evolvedPartitionSpec.SpecId)
+	}
+	return evolvedPartitionSpec
+}
+
+// Returns no schemas for a new table
+func (icebergWriter *IcebergWriter) existingSchemas(metadataDirPath string) []IcebergSchema {
+	metadataFile, err := icebergWriter.storage.ExistingMetadataFile(metadataDirPath)
+	PanicIfError(err, icebergWriter.config)
+	if metadataFile.Version == 0 {
+		return []IcebergSchema{}
+	}
+
+	schemasSortedAsc, err := icebergWriter.storage.ExistingSchemas(metadataDirPath)
+	PanicIfError(err, icebergWriter.config)
+	return schemasSortedAsc
+}
+
+// Sorts rows first if the sort order has fields, spilling them to disk if they don't fit in memory
+func (icebergWriter *IcebergWriter) createSortedParquetFiles(dataDirPath string, pgSchemaColumns []PgSchemaColumn, sortOrder IcebergSortOrder, maxWriteParquetPayloadSize int, loadRows func() [][]string) []ParquetFile {
+	if !sortOrder.IsSorted() {
+		return icebergWriter.createParquetFiles(dataDirPath, pgSchemaColumns, maxWriteParquetPayloadSize, loadRows)
+	}
+
+	sorter, err := NewIcebergRowSorter(icebergWriter.config, sortOrder, pgSchemaColumns)
+	PanicIfError(err, icebergWriter.config)
+	defer icebergWriter.closeRowSorter(sorter)
+
+	loadSortedRows, err := sorter.Sort(loadRows)
+	PanicIfError(err, icebergWriter.config)
+
+	parquetFiles := icebergWriter.createParquetFiles(dataDirPath, pgSchemaColumns, maxWriteParquetPayloadSize, loadSortedRows)
+	for i := range parquetFiles {
+		parquetFiles[i].SortOrderId = sortOrder.OrderId
+	}
+	return parquetFiles
+}
+
+func (icebergWriter *IcebergWriter) closeRowSorter(sorter *IcebergRowSorter) {
+	err := sorter.Close()
+	if err != nil {
+		LogWarn(icebergWriter.config, "Failed to delete sorted rows:", err)
+	}
+}
+
+func (icebergWriter *IcebergWriter) createParquetFiles(dataDirPath string, pgSchemaColumns []PgSchemaColumn, maxWriteParquetPayloadSize int, loadRows func() [][]string) []ParquetFile {
+	parquetFiles := []ParquetFile{}
+	loadMoreRows := true
+
+	for loadMoreRows {
+		parquetFile, loadedAllRows, err := icebergWriter.storage.CreateParquet(dataDirPath, pgSchemaColumns, loadRows, maxWriteParquetPayloadSize)
+		PanicIfError(err, icebergWriter.config)
+		if parquetFile.RecordCount == 0 && len(parquetFiles) > 0 { // Parquet is empty and already written at least one Parquet previously
+			err = icebergWriter.storage.DeleteParquet(parquetFile)
+			PanicIfError(err, icebergWriter.config)
+			break
+		}
+
+		parquetFiles = append(parquetFiles, parquetFile)
+		loadMoreRows = !loadedAllRows
+
+		if loadMoreRows {
+			LogDebug(icebergWriter.config, "Written", len(parquetFiles), "Parquet files, continuing...")
+		}
+	}
+
+	return parquetFiles
+}
+
+// Spills rows to a temporary file per partition first, then writes each partition into its own Parquet files
+func (icebergWriter *IcebergWriter) createPartitionedParquetFiles(dataDirPath string, pgSchemaColumns []PgSchemaColumn, partitionSpec IcebergPartitionSpec, sortOrder IcebergSortOrder, maxWriteParquetPayloadSize int, loadRows func() [][]string) []ParquetFile {
+	partitioner, err := NewIcebergPartitioner(partitionSpec, pgSchemaColumns)
+	PanicIfError(err, icebergWriter.config)
+
+	spill := NewIcebergPartitionSpill(icebergWriter.config)
+	defer func() {
+		err := spill.Close()
+		if err != nil {
+			LogWarn(icebergWriter.config, "Failed to delete spilled partition rows:", err)
+		}
+	}()
+
+	for rows := loadRows(); len(rows) > 0; rows = loadRows() {
+		for _, row := range rows {
+			partitionValues, err := partitioner.PartitionValues(row)
+			PanicIfError(err, icebergWriter.config)
+			err = spill.Write(partitionValues, row)
+			PanicIfError(err, icebergWriter.config)
+		}
+	}
+
+	partitions, err := spill.Partitions()
+	PanicIfError(err, icebergWriter.config)
+
+	// Write an empty Parquet file without partition values if there are no rows
+	if len(partitions) == 0 {
+		parquetFiles := icebergWriter.createParquetFiles(dataDirPath, pgSchemaColumns, maxWriteParquetPayloadSize, func() [][]string { return [][]string{} })
+		for i := range parquetFiles {
+			parquetFiles[i].PartitionValues = make([]*string, len(partitionSpec.Fields))
+		}
+		return parquetFiles
+	}
+
+	LogDebug(icebergWriter.config, "Writing", len(partitions), "partition(s) to Parquet...")
+	parquetFiles := []ParquetFile{}
+	for _, partition := range partitions {
+		loadPartitionRows, closeFile, err := partition.LoadRows()
+		PanicIfError(err, icebergWriter.config)
+
+		partitionParquetFiles := icebergWriter.createSortedParquetFiles(dataDirPath, pgSchemaColumns, sortOrder, maxWriteParquetPayloadSize, loadPartitionRows)
+		closeFile()
+		for i := range partitionParquetFiles {
+			partitionParquetFiles[i].PartitionValues = partition.PartitionValues
+		}
+		parquetFiles = append(parquetFiles, partitionParquetFiles...)
+	}
+
+	return parquetFiles
+}
+
+// Groups Parquet files into bins of up to targetFileSize in total with the first-fit decreasing algorithm.
+// Returns the indexes of the Parquet files in each bin
+func binPackParquetFiles(parquetFiles []ParquetFile, targetFileSize int64) [][]int {
+	indexesSortedBySizeDesc := make([]int, len(parquetFiles))
+	for i := range parquetFiles {
+		indexesSortedBySizeDesc[i] = i
+	}
+	slices.SortStableFunc(indexesSortedBySizeDesc, func(i, j int) int {
+		return cmp.Compare(parquetFiles[j].Size, parquetFiles[i].Size)
+	})
+
+	bins := [][]int{}
+	binSizes := []int64{}
+	for _, i := range indexesSortedBySizeDesc {
+		binIndex := slices.IndexFunc(binSizes, func(binSize int64) bool {
+			return binSize+parquetFiles[i].Size <= targetFileSize
+		})
+		if binIndex == -1 {
+			bins = append(bins, []int{})
+			binSizes = append(binSizes, 0)
+			binIndex = len(bins) - 1
+		}
+		bins[binIndex] = append(bins[binIndex], i)
+		binSizes[binIndex] += parquetFiles[i].Size
+	}
+	return bins
+}
@@ -2,7 +2,11 @@ package main
 
 import (
 	"context"
+	"maps"
+	"os"
+	"slices"
 	"testing"
+	"time"
 )
 
 var TEST_ICEBERG_WRITER_SCHEMA_TABLE = IcebergSchemaTable{
@@ -10,8 +14,8 @@ var TEST_ICEBERG_WRITER_SCHEMA_TABLE = IcebergSchemaTable{
 	Table:  "test_table",
 }
 var TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS = []PgSchemaColumn{
-	{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", NumericPrecision: "32", OrdinalPosition: "1", Namespace: "pg_catalog", PartOfPrimaryKey: true},
-	{ColumnName: "name", DataType: "character varying", UdtName: "varchar", IsNullable: "YES", CharacterMaximumLength: "255", OrdinalPosition: "2", Namespace: "pg_catalog"},
+	{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", NumericPrecision: "32", OrdinalPosition: "1", FieldId: 1, Namespace: "pg_catalog", PartOfPrimaryKey: true},
+	{ColumnName: "name", DataType: "character varying", UdtName: "varchar", IsNullable: "YES", CharacterMaximumLength: "255", OrdinalPosition: "2", FieldId: 2, Namespace: "pg_catalog"},
 }
 var TEST_ICEBERG_WRITER_INITIAL_ROWS = [][]string{
 	{"1", "John"},
@@ -29,6 +33,7 @@ func TestWrite(t *testing.T) {
 	})
 
 	t.Run("Processes a full sync with a single Parquet file", func(t *testing.T) {
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
 		testManifestListFiles(t, icebergWriter,
@@ -42,6 +47,7 @@ func TestWrite(t *testing.T) {
 
 	t.Run("Processes a full sync with two Parquet files", func(t *testing.T) {
 		loadedRowIndex := -1
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 		icebergWriter.Write(
 			TEST_ICEBERG_WRITER_SCHEMA_TABLE,
 			TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS,
@@ -65,6 +71,51 @@ func TestWrite(t *testing.T) {
 			{"2", PG_NULL_STRING},
 		})
 	})
+
+	t.Run("Filters records by column bounds of Parquet files", func(t *testing.T) {
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		icebergWriter.Write(
+			TEST_ICEBERG_WRITER_SCHEMA_TABLE,
+			TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS,
+			MAX_WRITE_PARQUET_PAYLOAD_SIZE,
+			createTestLoadRows([][]string{{"-1", "Alice"}, {"1", "John"}}),
+			createTestLoadRows([][]string{{"300", "Bob"}, {"1000", PG_NULL_STRING}}),
+		)
+
+		testManifestListFiles(t, icebergWriter,
+			ManifestListFile{SequenceNumber: 1, Operation: "append", AddedDataFiles: 2, AddedRecords: 4},
+		)
+		testFilteredRecords(t, duckdb, "id > 2", [][]string{
+			{"300", "Bob"},
+			{"1000", PG_NULL_STRING},
+		})
+		testFilteredRecords(t, duckdb, "id < 0", [][]string{
+			{"-1", "Alice"},
+		})
+		testFilteredRecords(t, duckdb, "name = 'John'", [][]string{
+			{"1", "John"},
+		})
+	})
+
+	t.Run("Processes a full sync with parallel loaders in a single snapshot", func(t *testing.T) {
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		icebergWriter.Write(
+			TEST_ICEBERG_WRITER_SCHEMA_TABLE,
+			TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS,
+			MAX_WRITE_PARQUET_PAYLOAD_SIZE,
+			createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS[:1]),
+			createTestLoadRows([][]string{}),
+			createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS[1:]),
+		)
+
+		testManifestListFiles(t, icebergWriter,
+			ManifestListFile{SequenceNumber: 1, Operation: "append", AddedDataFiles: 2, AddedRecords: 2},
+		)
+		testRecords(t, duckdb, [][]string{
+			{"1", "John"},
+			{"2", PG_NULL_STRING},
+		})
+	})
 }
 
 func TestWriteIncrementally(t *testing.T) {
@@ -79,9 +130,10 @@ func TestWriteIncrementally(t *testing.T) {
 
 	t.Run("Single incremental sync", func(t *testing.T) {
 		t.Run("Processes an incremental INSERT", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Jane"},
 			}))
 
@@ -97,9 +149,10 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes an incremental UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "John Doe"},
 			}))
 
@@ -115,9 +168,10 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes an incremental full UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "John Doe"},
 				{"2", "Jane"},
 			}))
@@ -134,9 +188,10 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes an incremental INSERT & UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "John Doe"},
 				{"3", "Jane"},
 			}))
@@ -154,9 +209,10 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes an incremental INSERT & full UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "John Doe"},
 				{"2", "Jane"},
 				{"3", "Alice"},
@@ -177,12 +233,13 @@ func TestWriteIncrementally(t *testing.T) {
 
 	t.Run("Two incremental syncs", func(t *testing.T) {
 		t.Run("Processes incremental INSERT -> INSERT", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"4", "Alice"},
 			}))
 
@@ -200,12 +257,13 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental INSERT -> same-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Alice"},
 			}))
 
@@ -223,12 +281,13 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental INSERT -> same-record UPDATE & INSERT", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Alice"},
 				{"4", "Bob"},
 			}))
@@ -248,13 +307,14 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental INSERT & UPDATE -> same-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Jane"},
 				{"3", "Alice"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Bob"},
 			}))
 
@@ -273,12 +333,13 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental INSERT -> initial-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "Alice"},
 			}))
 
@@ -296,12 +357,13 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental INSERT -> initial-record UPDATE & INSERT", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "Alice"},
 				{"4", "Bob"},
 			}))
@@ -321,12 +383,13 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental INSERT -> initial & inserted-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "Alice"},
 				{"3", "Bob"},
 			}))
@@ -346,12 +409,13 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental INSERT -> full UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "John Doe"},
 				{"2", "Alice"},
 				{"3", "Bob"},
@@ -372,13 +436,14 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental INSERT & UPDATE -> initial-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Jane"},
 				{"3", "Alice"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "Bob"},
 			}))
 
@@ -397,12 +462,13 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental UPDATE -> INSERT", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Alice"},
 			}))
 
@@ -420,13 +486,14 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental full UPDATE -> INSERT", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "John Doe"},
 				{"2", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Alice"},
 			}))
 
@@ -444,13 +511,14 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental full UPDATE -> UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "John Doe"},
 				{"2", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "Alice"},
 			}))
 
@@ -468,12 +536,13 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental UPDATE -> full UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "John Doe"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "Jane"},
 				{"2", "Alice"},
 			}))
@@ -493,12 +562,13 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental UPDATE -> same-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Alice"},
 			}))
 
@@ -516,12 +586,13 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental UPDATE -> same-record UPDATE & INSERT", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Alice"},
 				{"3", "Bob"},
 			}))
@@ -541,12 +612,13 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental UPDATE -> initial-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "Alice"},
 			}))
 
@@ -565,12 +637,13 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental UPDATE -> initial-record UPDATE & INSERT", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "Alice"},
 				{"3", "Bob"},
 			}))
@@ -593,15 +666,16 @@ func TestWriteIncrementally(t *testing.T) {
 
 	t.Run("Three incremental syncs", func(t *testing.T) {
 		t.Run("Processes incremental INSERT -> INSERT -> last-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"4", "Alice"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"4", "Bob"},
 			}))
 
@@ -621,15 +695,16 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental INSERT -> INSERT -> previous-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"4", "Alice"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Bob"},
 			}))
 
@@ -649,15 +724,16 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental INSERT -> INSERT -> initial-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"4", "Alice"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Bob"},
 			}))
 
@@ -677,15 +753,16 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental INSERT -> same-record UPDATE -> INSERT", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Alice"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"4", "Bob"},
 			}))
 
@@ -705,15 +782,16 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental INSERT -> initial-record UPDATE -> INSERT", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Alice"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"4", "Bob"},
 			}))
 
@@ -733,15 +811,16 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental INSERT -> same-record UPDATE -> same-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Alice"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Bob"},
 			}))
 
@@ -761,15 +840,16 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental INSERT -> same-record UPDATE -> initial-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Alice"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Bob"},
 			}))
 
@@ -789,15 +869,16 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental INSERT -> initial-record UPDATE -> initial-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Alice"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "Bob"},
 			}))
 
@@ -817,15 +898,16 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental INSERT -> initial-record UPDATE -> inserted-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Alice"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Bob"},
 			}))
 
@@ -845,15 +927,16 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental INSERT -> initial-record UPDATE -> updated-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Alice"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Bob"},
 			}))
 
@@ -873,15 +956,16 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental UPDATE -> INSERT -> INSERT", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Alice"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"4", "Bob"},
 			}))
 
@@ -901,15 +985,16 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental UPDATE -> INSERT -> inserted-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Alice"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Bob"},
 			}))
 
@@ -929,15 +1014,16 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental UPDATE -> INSERT -> updated-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Alice"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Bob"},
 			}))
 
@@ -957,15 +1043,16 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental UPDATE -> INSERT -> initial-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Alice"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "Bob"},
 			}))
 
@@ -985,15 +1072,16 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental UPDATE -> INSERT -> full UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Alice"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"1", "John Doe"},
 				{"2", "Bob"},
 				{"3", "Alice Smith"},
@@ -1017,15 +1105,16 @@ func TestWriteIncrementally(t *testing.T) {
 		})
 
 		t.Run("Processes incremental UPDATE -> INSERT -> updated and inserted-record UPDATE", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
 			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
 
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Jane"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"3", "Alice"},
 			}))
-			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, 10, createTestLoadRows([][]string{
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
 				{"2", "Bob"},
 				{"3", "Alice Smith"},
 			}))
@@ -1046,6 +1135,39 @@ func TestWriteIncrementally(t *testing.T) {
 			})
 		})
 	})
+
+	t.Run("Merge-on-read incremental syncs", func(t *testing.T) {
+		config.Pg.IncrementalUpdateMode = PG_INCREMENTAL_UPDATE_MODE_MERGE_ON_READ
+		defer func() { config.Pg.IncrementalUpdateMode = PG_INCREMENTAL_UPDATE_MODE_COPY_ON_WRITE }()
+
+		t.Run("Processes incremental UPDATE -> INSERT -> updated and inserted-record UPDATE with position delete files", func(t *testing.T) {
+			icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+			icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
+				{"2", "Jane"},
+			}))
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
+				{"3", "Alice"},
+			}))
+			icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
+				{"2", "Bob"},
+				{"3", "Alice Smith"},
+			}))
+
+			testManifestListFiles(t, icebergWriter,
+				ManifestListFile{SequenceNumber: 1, Operation: "append", AddedDataFiles: 1, AddedRecords: 2},
+				ManifestListFile{SequenceNumber: 2, Operation: "overwrite", AddedDataFiles: 1, AddedRecords: 1, AddedDeleteFiles: 1, AddedPositionDeletes: 1},
+				ManifestListFile{SequenceNumber: 3, Operation: "append", AddedDataFiles: 1, AddedRecords: 1},
+				ManifestListFile{SequenceNumber: 4, Operation: "overwrite", AddedDataFiles: 1, AddedRecords: 2, AddedDeleteFiles: 1, AddedPositionDeletes: 3},
+			)
+			testRecords(t, duckdb, [][]string{
+				{"1", "John"},
+				{"2", "Bob"},
+				{"3", "Alice Smith"},
+			})
+		})
+	})
 }
 
 // ---------------------------------------------------------------------------------------------------------------------
@@ -1092,14 +1214,27 @@ func testManifestListFiles(t *testing.T, icebergWriter *IcebergWriter, expectedM
 		if actualManifestListFile.DeletedRecords != expectedManifestListFile.DeletedRecords {
 			t.Fatalf("Expected %d deleted records, got %d (sequence number %d)", expectedManifestListFile.DeletedRecords, actualManifestListFile.DeletedRecords, actualManifestListFile.SequenceNumber)
 		}
+		if actualManifestListFile.AddedDeleteFiles != expectedManifestListFile.AddedDeleteFiles || actualManifestListFile.AddedPositionDeletes != expectedManifestListFile.AddedPositionDeletes {
+			t.Fatalf("Expected %d added delete files with %d position deletes, got %d with %d (sequence number %d)", expectedManifestListFile.AddedDeleteFiles, expectedManifestListFile.AddedPositionDeletes, actualManifestListFile.AddedDeleteFiles, actualManifestListFile.AddedPositionDeletes, actualManifestListFile.SequenceNumber)
+		}
+		if actualManifestListFile.RemovedDeleteFiles != expectedManifestListFile.RemovedDeleteFiles || actualManifestListFile.RemovedPositionDeletes != expectedManifestListFile.RemovedPositionDeletes {
+			t.Fatalf("Expected %d removed delete files with %d position deletes, got %d with %d (sequence number %d)", expectedManifestListFile.RemovedDeleteFiles, expectedManifestListFile.RemovedPositionDeletes, actualManifestListFile.RemovedDeleteFiles, actualManifestListFile.RemovedPositionDeletes, actualManifestListFile.SequenceNumber)
+		}
 	}
 }
 
 func testRecords(t *testing.T, duckdb *Duckdb, expectedRecords [][]string) {
+	testFilteredRecords(t, duckdb, "TRUE", expectedRecords)
+}
+
+func testFilteredRecords(t *testing.T, duckdb *Duckdb, whereClause string, expectedRecords [][]string) {
 	icebergReader := NewIcebergReader(duckdb.config)
-	metadataFilePath := icebergReader.MetadataFilePath(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+	metadataFilePath, err := icebergReader.MetadataFilePath(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+	if err != nil {
+		t.Fatalf("Error resolving the metadata file: %v", err)
+	}
 
-	rows, err := duckdb.QueryContext(context.Background(), "SELECT id::text, COALESCE(name, '"+PG_NULL_STRING+"') FROM iceberg_scan('"+metadataFilePath+"', skip_schema_inference = true) ORDER BY id")
+	rows, err := duckdb.QueryContext(context.Background(), "SELECT id::text, COALESCE(name, '"+PG_NULL_STRING+"') FROM iceberg_scan('"+metadataFilePath+"', skip_schema_inference = true) WHERE "+whereClause+" ORDER BY id")
 	if err != nil {
 		t.Fatalf("Error querying DuckDB: %v", err)
 	}
@@ -1128,3 +1263,1165 @@ func testRecords(t *testing.T, duckdb *Duckdb, expectedRecords [][]string) {
 		}
 	}
 }
+
+func TestEvolveIcebergSchemas(t *testing.T) {
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", FieldId: 1, Namespace: "pg_catalog"},
+		{ColumnName: "name", DataType: "character varying", UdtName: "varchar", IsNullable: "NO", OrdinalPosition: "2", FieldId: 2, Namespace: "pg_catalog"},
+		{ColumnName: "email", DataType: "character varying", UdtName: "varchar", IsNullable: "YES", OrdinalPosition: "3", FieldId: 3, Namespace: "pg_catalog"},
+	}
+	schemas := []IcebergSchema{{SchemaId: 0, Fields: NewIcebergSchemaFields(pgSchemaColumns)}}
+
+	t.Run("Keeps the current schema if the columns didn't change", func(t *testing.T) {
+		evolvedSchemas, err := EvolveIcebergSchemas(schemas, pgSchemaColumns, true)
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if len(evolvedSchemas) != 1 {
+			t.Errorf("Expected 1 schema, got %v", len(evolvedSchemas))
+		}
+	})
+
+	t.Run("Adds a schema with stable field IDs for added, dropped, renamed, and widened columns", func(t *testing.T) {
+		evolvedPgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "bigint", UdtName: "int8", IsNullable: "NO", OrdinalPosition: "1", FieldId: 1, Namespace: "pg_catalog"},
+			{ColumnName: "full_name", DataType: "character varying", UdtName: "varchar", IsNullable: "NO", OrdinalPosition: "2", FieldId: 2, Namespace: "pg_catalog"},
+			{ColumnName: "age", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "4", FieldId: 4, Namespace: "pg_catalog"},
+		}
+
+		evolvedSchemas, err := EvolveIcebergSchemas(schemas, evolvedPgSchemaColumns, true)
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if len(evolvedSchemas) != 2 || evolvedSchemas[1].SchemaId != 1 {
+			t.Fatalf("Expected a new schema with ID 1, got %v", evolvedSchemas)
+		}
+		expectedFields := []IcebergSchemaField{
+			{Id: 1, Name: "id", Type: "long", Required: true},
+			{Id: 2, Name: "full_name", Type: "string", Required: true},
+			{Id: 4, Name: "age", Type: "int", Required: false},
+		}
+		if !equalIcebergSchemaFields(evolvedSchemas[1].Fields, expectedFields) {
+			t.Errorf("Expected fields %v, got %v", expectedFields, evolvedSchemas[1].Fields)
+		}
+		if evolvedSchemas[1].ApplyNullability(evolvedPgSchemaColumns)[2].IsNullable != PG_TRUE {
+			t.Errorf("Expected the added column to become nullable")
+		}
+	})
+
+	t.Run("Sets identifier field IDs of required primary key columns", func(t *testing.T) {
+		evolvedPgSchemaColumns := slices.Clone(pgSchemaColumns)
+		evolvedPgSchemaColumns[0].PartOfPrimaryKey = true
+		evolvedPgSchemaColumns[2].PartOfPrimaryKey = true // Optional columns can't identify rows
+
+		evolvedSchemas, err := EvolveIcebergSchemas(schemas, evolvedPgSchemaColumns, true)
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if len(evolvedSchemas) != 2 || !slices.Equal(evolvedSchemas[1].IdentifierFieldIds, []int{1}) {
+			t.Errorf("Expected a new schema with identifier field IDs [1], got %v", evolvedSchemas)
+		}
+	})
+
+	t.Run("Keeps the attnums of the columns in the current schema", func(t *testing.T) {
+		evolvedPgSchemaColumns := slices.Clone(pgSchemaColumns)
+		evolvedPgSchemaColumns[2].OrdinalPosition = "4"
+
+		evolvedSchemas, err := EvolveIcebergSchemas(schemas, evolvedPgSchemaColumns, true)
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		expectedFieldIdsByAttnum := map[int]int{1: 1, 2: 2, 4: 3}
+		if len(evolvedSchemas) != 1 || !maps.Equal(evolvedSchemas[0].FieldIdsByAttnum, expectedFieldIdsByAttnum) {
+			t.Errorf("Expected the current schema with field IDs by attnum %v, got %v", expectedFieldIdsByAttnum, evolvedSchemas)
+		}
+		if schemas[0].FieldIdsByAttnum != nil {
+			t.Errorf("Expected the existing schemas to stay unchanged")
+		}
+	})
+
+	t.Run("Returns an error if a column has the attnum of another column and data files are kept", func(t *testing.T) {
+		schemasWithAttnums := []IcebergSchema{{SchemaId: 0, Fields: NewIcebergSchemaFields(pgSchemaColumns), FieldIdsByAttnum: map[int]int{1: 1, 2: 2, 3: 3}}}
+		evolvedPgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", FieldId: 1, Namespace: "pg_catalog"},
+			{ColumnName: "full_name", DataType: "character varying", UdtName: "varchar", IsNullable: "NO", OrdinalPosition: "2", FieldId: 4, Namespace: "pg_catalog"},
+			{ColumnName: "name", DataType: "character varying", UdtName: "varchar", IsNullable: "NO", OrdinalPosition: "5", FieldId: 2, Namespace: "pg_catalog"},
+		}
+
+		_, err := EvolveIcebergSchemas(schemasWithAttnums, evolvedPgSchemaColumns, true)
+		if err == nil {
+			t.Errorf("Expected an error")
+		}
+
+		_, err = EvolveIcebergSchemas(schemasWithAttnums, evolvedPgSchemaColumns, false)
+		if err != nil {
+			t.Errorf("Expected no error when data files are rewritten, got %v", err)
+		}
+	})
+
+	t.Run("Returns an error for unsafe type changes if data files are kept", func(t *testing.T) {
+		evolvedPgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "character varying", UdtName: "varchar", IsNullable: "NO", OrdinalPosition: "1", FieldId: 1, Namespace: "pg_catalog"},
+		}
+
+		_, err := EvolveIcebergSchemas(schemas, evolvedPgSchemaColumns, true)
+		if err == nil {
+			t.Errorf("Expected an error")
+		}
+
+		evolvedSchemas, err := EvolveIcebergSchemas(schemas, evolvedPgSchemaColumns, false)
+		if err != nil {
+			t.Errorf("Expected no error when data files are rewritten, got %v", err)
+		}
+		if len(evolvedSchemas) != 2 {
+			t.Errorf("Expected 2 schemas, got %v", len(evolvedSchemas))
+		}
+	})
+}
+
+func TestRollBackIcebergSchemas(t *testing.T) {
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", FieldId: 1, Namespace: "pg_catalog"},
+	}
+	schemas := []IcebergSchema{{SchemaId: 0, Fields: NewIcebergSchemaFields(pgSchemaColumns)}}
+
+	t.Run("Makes columns added since the previous schema optional", func(t *testing.T) {
+		evolvedPgSchemaColumns := append(slices.Clone(pgSchemaColumns), PgSchemaColumn{ColumnName: "age", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "2", FieldId: 2, Namespace: "pg_catalog"})
+		evolvedSchemas, err := EvolveIcebergSchemas(schemas, evolvedPgSchemaColumns, false)
+		PanicIfError(err, nil)
+
+		rolledBackSchemas, err := RollBackIcebergSchemas(evolvedSchemas, 0)
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if len(rolledBackSchemas) != 3 || rolledBackSchemas[2].SchemaId != 2 {
+			t.Fatalf("Expected a new schema with ID 2, got %v", rolledBackSchemas)
+		}
+		expectedFields := []IcebergSchemaField{
+			{Id: 1, Name: "id", Type: "int", Required: true},
+			{Id: 2, Name: "age", Type: "int", Required: false},
+		}
+		if !equalIcebergSchemaFields(rolledBackSchemas[2].Fields, expectedFields) {
+			t.Errorf("Expected fields %v, got %v", expectedFields, rolledBackSchemas[2].Fields)
+		}
+	})
+
+	t.Run("Returns an error for unsafe type changes since the previous schema", func(t *testing.T) {
+		evolvedPgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "character varying", UdtName: "varchar", IsNullable: "NO", OrdinalPosition: "1", FieldId: 1, Namespace: "pg_catalog"},
+		}
+		evolvedSchemas, err := EvolveIcebergSchemas(schemas, evolvedPgSchemaColumns, false)
+		PanicIfError(err, nil)
+
+		_, err = RollBackIcebergSchemas(evolvedSchemas, 0)
+
+		if err == nil {
+			t.Errorf("Expected an error")
+		}
+	})
+}
+
+func TestAssignIcebergFieldIds(t *testing.T) {
+	t.Run("Assigns IDs in column order with array elements after the columns to a new table", func(t *testing.T) {
+		pgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1"},
+			{ColumnName: "tags", DataType: "ARRAY", UdtName: "_text", IsNullable: "YES", OrdinalPosition: "3"},
+			{ColumnName: "name", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "5"},
+		}
+
+		assignedPgSchemaColumns := AssignIcebergFieldIds(nil, pgSchemaColumns)
+
+		expectedIds := [][2]int{{1, 0}, {2, 4}, {3, 0}}
+		for i, expectedId := range expectedIds {
+			if assignedPgSchemaColumns[i].FieldId != expectedId[0] || assignedPgSchemaColumns[i].ElementFieldId != expectedId[1] {
+				t.Errorf("Expected IDs %v for %v, got %v and %v", expectedId, pgSchemaColumns[i].ColumnName, assignedPgSchemaColumns[i].FieldId, assignedPgSchemaColumns[i].ElementFieldId)
+			}
+		}
+		if LastIcebergColumnId([]IcebergSchema{{Fields: NewIcebergSchemaFields(assignedPgSchemaColumns)}}) != 4 {
+			t.Errorf("Expected a last column ID of 4")
+		}
+	})
+
+	t.Run("Keeps IDs of existing columns and doesn't reuse IDs of dropped columns", func(t *testing.T) {
+		schemas := []IcebergSchema{
+			{SchemaId: 0, Fields: []IcebergSchemaField{
+				{Id: 1, Name: "id", Type: "int", Required: true},
+				{Id: 2, Name: "email", Type: "string", Required: false},
+			}},
+			{SchemaId: 1, Fields: []IcebergSchemaField{
+				{Id: 1, Name: "id", Type: "int", Required: true},
+				{Id: 3, Name: "tags", Type: map[string]interface{}{"type": "list", "element": "string", "element-id": float64(4), "element-required": false}, Required: false},
+				{Id: 5, Name: "scores", Type: map[string]interface{}{"type": "list", "element": "int", "element-id": "5", "element-required": false}, Required: false},
+			}},
+		}
+		pgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1"},
+			{ColumnName: "tags", DataType: "ARRAY", UdtName: "_text", IsNullable: "YES", OrdinalPosition: "2"},
+			{ColumnName: "scores", DataType: "ARRAY", UdtName: "_int4", IsNullable: "YES", OrdinalPosition: "3"},
+			{ColumnName: "email", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "4"},
+		}
+
+		assignedPgSchemaColumns := AssignIcebergFieldIds(schemas, pgSchemaColumns)
+
+		expectedIds := [][2]int{{1, 0}, {3, 4}, {5, 7}, {6, 0}}
+		for i, expectedId := range expectedIds {
+			if assignedPgSchemaColumns[i].FieldId != expectedId[0] || assignedPgSchemaColumns[i].ElementFieldId != expectedId[1] {
+				t.Errorf("Expected IDs %v for %v, got %v and %v", expectedId, pgSchemaColumns[i].ColumnName, assignedPgSchemaColumns[i].FieldId, assignedPgSchemaColumns[i].ElementFieldId)
+			}
+		}
+	})
+
+	schemas := []IcebergSchema{
+		{SchemaId: 0, Fields: []IcebergSchemaField{
+			{Id: 1, Name: "id", Type: "int", Required: true},
+			{Id: 2, Name: "name", Type: "string", Required: false},
+			{Id: 3, Name: "email", Type: "string", Required: false},
+		}, FieldIdsByAttnum: map[int]int{1: 1, 2: 2, 3: 3}},
+	}
+
+	t.Run("Keeps the ID of a renamed column by its attnum", func(t *testing.T) {
+		pgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1"},
+			{ColumnName: "full_name", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "2"},
+			{ColumnName: "email", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "3"},
+		}
+
+		assignedPgSchemaColumns := AssignIcebergFieldIds(schemas, pgSchemaColumns)
+
+		for i, expectedId := range []int{1, 2, 3} {
+			if assignedPgSchemaColumns[i].FieldId != expectedId {
+				t.Errorf("Expected ID %v for %v, got %v", expectedId, pgSchemaColumns[i].ColumnName, assignedPgSchemaColumns[i].FieldId)
+			}
+		}
+		_, err := EvolveIcebergSchemas(schemas, assignedPgSchemaColumns, true)
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	})
+
+	t.Run("Keeps IDs by name if attnums change without renames", func(t *testing.T) {
+		pgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1"},
+			{ColumnName: "email", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "2"},
+		}
+
+		assignedPgSchemaColumns := AssignIcebergFieldIds(schemas, pgSchemaColumns)
+
+		for i, expectedId := range []int{1, 3} {
+			if assignedPgSchemaColumns[i].FieldId != expectedId {
+				t.Errorf("Expected ID %v for %v, got %v", expectedId, pgSchemaColumns[i].ColumnName, assignedPgSchemaColumns[i].FieldId)
+			}
+		}
+		_, err := EvolveIcebergSchemas(schemas, assignedPgSchemaColumns, true)
+		if err == nil {
+			t.Errorf("Expected an error for the column with the attnum of a dropped column")
+		}
+	})
+
+	t.Run("Doesn't reuse the ID of a renamed column for a column added with its previous name", func(t *testing.T) {
+		pgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1"},
+			{ColumnName: "full_name", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "2"},
+			{ColumnName: "email", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "3"},
+			{ColumnName: "name", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "4"},
+		}
+
+		assignedPgSchemaColumns := AssignIcebergFieldIds(schemas, pgSchemaColumns)
+
+		_, err := EvolveIcebergSchemas(schemas, assignedPgSchemaColumns, true)
+		if err == nil {
+			t.Errorf("Expected an error for the ambiguous column, got IDs %v", assignedPgSchemaColumns)
+		}
+	})
+}
+
+func TestIcebergPartitionSpec(t *testing.T) {
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "bigint", UdtName: "int8", IsNullable: "NO", OrdinalPosition: "1", FieldId: 1, Namespace: "pg_catalog"},
+		{ColumnName: "name", DataType: "character varying", UdtName: "varchar", IsNullable: "YES", OrdinalPosition: "2", FieldId: 2, Namespace: "pg_catalog"},
+		{ColumnName: "created_at", DataType: "timestamp with time zone", UdtName: "timestamptz", IsNullable: "NO", DatetimePrecision: "6", OrdinalPosition: "3", FieldId: 3, Namespace: "pg_catalog"},
+		{ColumnName: "born_on", DataType: "date", UdtName: "date", IsNullable: "YES", OrdinalPosition: "4", FieldId: 4, Namespace: "pg_catalog"},
+	}
+
+	t.Run("Builds a spec from partition expressions", func(t *testing.T) {
+		spec, err := NewIcebergPartitionSpec([]string{"day(created_at)", "bucket[16](id)", "truncate[2](name)", "born_on"}, pgSchemaColumns)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		expectedSpec := "created_at_day=day(3), id_bucket=bucket[16](1), name_trunc=truncate[2](2), born_on=identity(4)"
+		if spec.String() != expectedSpec {
+			t.Errorf("Expected spec %v, got %v", expectedSpec, spec.String())
+		}
+		if spec.LastPartitionId() != 1003 {
+			t.Errorf("Expected last partition ID 1003, got %v", spec.LastPartitionId())
+		}
+	})
+
+	t.Run("Rejects invalid partition expressions", func(t *testing.T) {
+		for _, expression := range []string{"unknown", "hour(born_on)", "bucket[0](id)", "truncate[2](created_at)", "week(created_at)"} {
+			_, err := NewIcebergPartitionSpec([]string{expression}, pgSchemaColumns)
+
+			if err == nil {
+				t.Errorf("Expected an error for %v", expression)
+			}
+		}
+	})
+
+	t.Run("Evolves a spec with stable field IDs", func(t *testing.T) {
+		existingSpec, err := NewIcebergPartitionSpec([]string{"day(created_at)"}, pgSchemaColumns)
+		PanicIfError(err, nil)
+		spec, err := NewIcebergPartitionSpec([]string{"bucket[16](id)", "day(created_at)"}, pgSchemaColumns)
+		PanicIfError(err, nil)
+
+		evolvedSpec := EvolveIcebergPartitionSpec([]IcebergPartitionSpec{existingSpec}, spec)
+
+		if evolvedSpec.SpecId != 1 || evolvedSpec.Fields[0].FieldId != 1001 || evolvedSpec.Fields[1].FieldId != 1000 {
+			t.Errorf("Expected spec 1 with field IDs 1001 and 1000, got %v", evolvedSpec)
+		}
+		if sameSpec := EvolveIcebergPartitionSpec([]IcebergPartitionSpec{existingSpec, evolvedSpec}, spec); sameSpec.SpecId != 1 {
+			t.Errorf("Expected the existing spec 1, got %v", sameSpec.SpecId)
+		}
+	})
+
+	t.Run("Computes partition values of rows", func(t *testing.T) {
+		spec, err := NewIcebergPartitionSpec([]string{"month(created_at)", "hour(created_at)", "bucket[100](id)", "truncate[10](id)", "truncate[3](name)", "bucket[100](name)", "born_on", "bucket[100](born_on)"}, pgSchemaColumns)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		partitioner, err := NewIcebergPartitioner(spec, pgSchemaColumns)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		partitionValues, err := partitioner.PartitionValues([]string{"-3", "iceberg", "2017-11-16 23:31:08.000000+01", "2017-11-16"})
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		// Bucket hashes from the Iceberg spec: 34 -> 2017239379, "iceberg" -> 1210000089, 2017-11-16 -> -653330422
+		expectedValues := []string{"574", "419686", "61", "-10", "ice", "89", "17486", "26"}
+		for i, expectedValue := range expectedValues {
+			if partitionValues[i] == nil || *partitionValues[i] != expectedValue {
+				t.Errorf("Expected %v for %v, got %v", expectedValue, spec.Fields[i].Name, partitionValues[i])
+			}
+		}
+
+		partitionValues, err = partitioner.PartitionValues([]string{"34", PG_NULL_STRING, "2017-11-16 22:31:08", PG_NULL_STRING})
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if *partitionValues[2] != "79" || partitionValues[4] != nil || partitionValues[6] != nil {
+			t.Errorf("Expected bucket 79 and NULL partition values, got %v", partitionValues)
+		}
+	})
+}
+
+func TestWriteParquetFilesPartitioned(t *testing.T) {
+	config := loadTestConfig()
+	icebergWriter := NewIcebergWriter(config)
+
+	t.Cleanup(func() {
+		icebergWriter.storage.DeleteSchema(TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema)
+	})
+
+	t.Run("Writes Parquet files per partition", func(t *testing.T) {
+		spec, err := NewIcebergPartitionSpec([]string{"name"}, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		parquetFiles := icebergWriter.WriteParquetFiles(
+			TEST_ICEBERG_WRITER_SCHEMA_TABLE,
+			TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS,
+			spec,
+			IcebergSortOrder{},
+			MAX_WRITE_PARQUET_PAYLOAD_SIZE,
+			createTestLoadRows([][]string{{"1", "John"}, {"2", PG_NULL_STRING}, {"3", "John"}}),
+		)
+
+		if len(parquetFiles) != 2 {
+			t.Fatalf("Expected 2 Parquet files, got %v", len(parquetFiles))
+		}
+		if *parquetFiles[0].PartitionValues[0] != "John" || parquetFiles[0].RecordCount != 2 {
+			t.Errorf("Expected 2 records in partition John, got %v in %v", parquetFiles[0].RecordCount, parquetFiles[0].PartitionValues)
+		}
+		if parquetFiles[1].PartitionValues[0] != nil || parquetFiles[1].RecordCount != 1 {
+			t.Errorf("Expected 1 record in the NULL partition, got %v in %v", parquetFiles[1].RecordCount, parquetFiles[1].PartitionValues)
+		}
+	})
+
+	t.Run("Writes an empty Parquet file without rows", func(t *testing.T) {
+		spec, err := NewIcebergPartitionSpec([]string{"bucket[4](id)"}, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		parquetFiles := icebergWriter.WriteParquetFiles(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, spec, IcebergSortOrder{}, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows([][]string{}))
+
+		if len(parquetFiles) != 1 || parquetFiles[0].RecordCount != 0 || len(parquetFiles[0].PartitionValues) != 1 {
+			t.Errorf("Expected a single empty Parquet file, got %v", parquetFiles)
+		}
+	})
+}
+
+func TestWriteIncrementallyPartitioned(t *testing.T) {
+	config := loadTestConfig()
+	icebergWriter := NewIcebergWriter(config)
+	metadataDirPath := icebergWriter.storage.CreateMetadataDir(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+	partitionSpec, err := NewIcebergPartitionSpec([]string{"name"}, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS)
+	PanicIfError(err, config)
+
+	t.Cleanup(func() {
+		icebergWriter.storage.DeleteSchema(TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema)
+	})
+
+	writePartitioned := func() {
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		parquetFiles := icebergWriter.WriteParquetFiles(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, partitionSpec, IcebergSortOrder{}, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+		icebergWriter.CommitParquetFiles(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, partitionSpec, IcebergSortOrder{}, parquetFiles)
+	}
+	// Record counts of the data and delete files of the current snapshot per partition value
+	recordCountsByPartition := func() (dataRecordCounts map[string]int64, positionDeletesRecordCounts map[string]int64) {
+		dataRecordCounts = make(map[string]int64)
+		positionDeletesRecordCounts = make(map[string]int64)
+		manifestListFiles, err := icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+		PanicIfError(err, config)
+		manifestListItems, err := icebergWriter.storage.ExistingManifestListItems(manifestListFiles[len(manifestListFiles)-1])
+		PanicIfError(err, config)
+		for _, manifestListItem := range manifestListItems {
+			parquetFile, err := icebergWriter.storage.ExistingParquetFile(manifestListItem.ManifestFile)
+			PanicIfError(err, config)
+			partitionValue := PG_NULL_STRING
+			if parquetFile.PartitionValues[0] != nil {
+				partitionValue = *parquetFile.PartitionValues[0]
+			}
+			if parquetFile.PositionDeletes {
+				positionDeletesRecordCounts[partitionValue] += parquetFile.RecordCount
+			} else {
+				dataRecordCounts[partitionValue] += parquetFile.RecordCount
+			}
+		}
+		return dataRecordCounts, positionDeletesRecordCounts
+	}
+
+	t.Run("Writes new rows per partition and rewrites data files of UPDATEd rows in their previous partition", func(t *testing.T) {
+		writePartitioned()
+
+		writtenParquetFiles := icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
+			{"2", "Jane"},
+			{"3", "John"},
+		}))
+
+		if len(writtenParquetFiles) != 3 {
+			t.Errorf("Expected 2 new Parquet files and 1 rewritten Parquet file, got %v", writtenParquetFiles)
+		}
+		dataRecordCounts, positionDeletesRecordCounts := recordCountsByPartition()
+		expectedDataRecordCounts := map[string]int64{"John": 2, "Jane": 1}
+		if !maps.Equal(dataRecordCounts, expectedDataRecordCounts) || len(positionDeletesRecordCounts) != 0 {
+			t.Errorf("Expected records per partition %v, got %v and deletes %v", expectedDataRecordCounts, dataRecordCounts, positionDeletesRecordCounts)
+		}
+	})
+
+	t.Run("Writes position delete files per partition of the data files with UPDATEd rows", func(t *testing.T) {
+		config.Pg.IncrementalUpdateMode = PG_INCREMENTAL_UPDATE_MODE_MERGE_ON_READ
+		defer func() { config.Pg.IncrementalUpdateMode = PG_INCREMENTAL_UPDATE_MODE_COPY_ON_WRITE }()
+		writePartitioned()
+
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
+			{"2", "Jane"},
+			{"3", "John"},
+		}))
+
+		dataRecordCounts, positionDeletesRecordCounts := recordCountsByPartition()
+		expectedDataRecordCounts := map[string]int64{"John": 2, "Jane": 1, PG_NULL_STRING: 1}
+		if !maps.Equal(dataRecordCounts, expectedDataRecordCounts) {
+			t.Errorf("Expected records per partition %v, got %v", expectedDataRecordCounts, dataRecordCounts)
+		}
+		expectedPositionDeletesRecordCounts := map[string]int64{PG_NULL_STRING: 1}
+		if !maps.Equal(positionDeletesRecordCounts, expectedPositionDeletesRecordCounts) {
+			t.Errorf("Expected deletes per partition %v, got %v", expectedPositionDeletesRecordCounts, positionDeletesRecordCounts)
+		}
+	})
+}
+
+func TestCommitParquetFiles(t *testing.T) {
+	config := loadTestConfig()
+	icebergWriter := NewIcebergWriter(config)
+	metadataDirPath := icebergWriter.storage.CreateMetadataDir(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+
+	t.Cleanup(func() {
+		icebergWriter.storage.DeleteSchema(TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema)
+	})
+
+	t.Run("Chains a full refresh to the previous snapshots", func(t *testing.T) {
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows([][]string{{"1", "John"}}))
+
+		testManifestListFiles(t, icebergWriter,
+			ManifestListFile{SequenceNumber: 1, Operation: "append", AddedDataFiles: 1, AddedRecords: 2},
+			ManifestListFile{SequenceNumber: 2, Operation: "overwrite", DeletedDataFiles: 1, DeletedRecords: 2, AddedDataFiles: 1, AddedRecords: 1},
+		)
+		manifestListFiles, err := icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+		PanicIfError(err, config)
+		if manifestListFiles[1].ParentSnapshotId != manifestListFiles[0].SnapshotId {
+			t.Errorf("Expected parent snapshot %v, got %v", manifestListFiles[0].SnapshotId, manifestListFiles[1].ParentSnapshotId)
+		}
+	})
+
+	t.Run("Evolves the partition spec if the partitioning changed", func(t *testing.T) {
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		partitionSpec, err := NewIcebergPartitionSpec([]string{"name"}, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS)
+		PanicIfError(err, config)
+		writePartitioned := func(partitionSpec IcebergPartitionSpec) {
+			parquetFiles := icebergWriter.WriteParquetFiles(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, partitionSpec, IcebergSortOrder{}, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+			icebergWriter.CommitParquetFiles(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, partitionSpec, IcebergSortOrder{}, parquetFiles)
+		}
+
+		writePartitioned(partitionSpec)
+		writePartitioned(IcebergPartitionSpec{})
+		writePartitioned(partitionSpec)
+
+		partitionSpecs, err := icebergWriter.storage.ExistingPartitionSpecs(metadataDirPath)
+		PanicIfError(err, config)
+		if len(partitionSpecs) != 2 || partitionSpecs[0].String() != "name=identity(2)" || partitionSpecs[1].SpecId != 1 || partitionSpecs[1].IsPartitioned() {
+			t.Fatalf("Expected the partitioned spec 0 and the unpartitioned spec 1, got %v", partitionSpecs)
+		}
+		currentPartitionSpec, err := icebergWriter.storage.ExistingPartitionSpec(metadataDirPath)
+		PanicIfError(err, config)
+		if currentPartitionSpec.SpecId != 0 {
+			t.Errorf("Expected the default spec 0, got %v", currentPartitionSpec.SpecId)
+		}
+
+		manifestListFiles, err := icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+		PanicIfError(err, config)
+		if len(manifestListFiles) != 3 {
+			t.Fatalf("Expected 3 snapshots, got %v", len(manifestListFiles))
+		}
+		for i, expectedPartitionSpecId := range []int{0, 1, 0} {
+			manifestListItems, err := icebergWriter.storage.ExistingManifestListItems(manifestListFiles[i])
+			PanicIfError(err, config)
+			for _, manifestListItem := range manifestListItems {
+				if manifestListItem.ManifestFile.PartitionSpecId != expectedPartitionSpecId {
+					t.Errorf("Expected manifests of snapshot %v with spec %v, got %v", i, expectedPartitionSpecId, manifestListItem.ManifestFile.PartitionSpecId)
+				}
+			}
+		}
+	})
+}
+
+func TestIcebergRowSorter(t *testing.T) {
+	config := loadTestConfig()
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "tenant_id", DataType: "integer", UdtName: "int4", IsNullable: "YES", OrdinalPosition: "1", FieldId: 1, Namespace: "pg_catalog"},
+		{ColumnName: "created_at", DataType: "timestamp without time zone", UdtName: "timestamp", IsNullable: "NO", DatetimePrecision: "6", OrdinalPosition: "2", FieldId: 2, Namespace: "pg_catalog"},
+	}
+	rows := [][]string{
+		{"10", "2024-01-01 00:00:00"},
+		{PG_NULL_STRING, "2024-01-03 00:00:00"},
+		{"9", "2024-01-02 00:00:00"},
+		{"10", "2024-01-05 00:00:00"},
+	}
+	expectedRows := [][]string{
+		{"9", "2024-01-02 00:00:00"},
+		{"10", "2024-01-05 00:00:00"},
+		{"10", "2024-01-01 00:00:00"},
+		{PG_NULL_STRING, "2024-01-03 00:00:00"},
+	}
+
+	t.Run("Parses sort expressions", func(t *testing.T) {
+		sortOrder, err := NewIcebergSortOrder([]string{"tenant_id", `"created_at" desc nulls last`}, pgSchemaColumns)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		expectedSortOrder := "identity(1) asc nulls-last, identity(2) desc nulls-last"
+		if sortOrder.String() != expectedSortOrder || sortOrder.OrderId != ICEBERG_SORT_ORDER_ID_SORTED {
+			t.Errorf("Expected sort order %v, got %v", expectedSortOrder, sortOrder.String())
+		}
+
+		for _, expression := range []string{"unknown", "tenant_id sideways", "created_at DESC NULLS"} {
+			_, err = NewIcebergSortOrder([]string{expression}, pgSchemaColumns)
+			if err == nil {
+				t.Errorf("Expected an error for %v", expression)
+			}
+		}
+	})
+
+	t.Run("Sorts rows in memory", func(t *testing.T) {
+		sortOrder, err := NewIcebergSortOrder([]string{"tenant_id", "created_at DESC"}, pgSchemaColumns)
+		PanicIfError(err, config)
+		sorter, err := NewIcebergRowSorter(config, sortOrder, pgSchemaColumns)
+		PanicIfError(err, config)
+		defer sorter.Close()
+
+		loadSortedRows, err := sorter.Sort(createTestLoadRows(rows))
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		testSortedRows(t, loadSortedRows, expectedRows)
+	})
+
+	t.Run("Merges sorted runs spilled to disk", func(t *testing.T) {
+		sortOrder, err := NewIcebergSortOrder([]string{"tenant_id", "created_at DESC"}, pgSchemaColumns)
+		PanicIfError(err, config)
+		sorter, err := NewIcebergRowSorter(config, sortOrder, pgSchemaColumns)
+		PanicIfError(err, config)
+		defer sorter.Close()
+
+		for _, runRows := range [][][]string{rows[:2], rows[2:]} {
+			sortRows := []icebergSortRow{}
+			for _, row := range runRows {
+				sortRows = append(sortRows, sorter.sortRow(row))
+			}
+			PanicIfError(sorter.spillRun(sortRows), config)
+		}
+		loadSortedRows, err := sorter.mergeRuns()
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		testSortedRows(t, loadSortedRows, expectedRows)
+	})
+}
+
+func testSortedRows(t *testing.T, loadSortedRows func() [][]string, expectedRows [][]string) {
+	sortedRows := [][]string{}
+	for rows := loadSortedRows(); len(rows) > 0; rows = loadSortedRows() {
+		sortedRows = append(sortedRows, rows...)
+	}
+
+	if len(sortedRows) != len(expectedRows) {
+		t.Fatalf("Expected %v rows, got %v", len(expectedRows), len(sortedRows))
+	}
+	for i, expectedRow := range expectedRows {
+		if !slices.Equal(sortedRows[i], expectedRow) {
+			t.Errorf("Expected row %v to be %v, got %v", i, expectedRow, sortedRows[i])
+		}
+	}
+}
+
+func TestCompact(t *testing.T) {
+	config := loadTestConfig()
+	icebergWriter := NewIcebergWriter(config)
+	duckdb := NewDuckdb(config, false)
+	defer duckdb.Close()
+
+	t.Cleanup(func() {
+		icebergWriter.storage.DeleteSchema(TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema)
+	})
+
+	t.Run("Compacts small data files into a replace snapshot", func(t *testing.T) {
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"3", "Jane"}}))
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"1", "John Doe"}}))
+
+		compactedParquetFiles := icebergWriter.Compact(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 1024*1024, 2, 0)
+
+		if len(compactedParquetFiles) != 1 {
+			t.Fatalf("Expected 1 compacted Parquet file, got %v", len(compactedParquetFiles))
+		}
+		testManifestListFiles(t, icebergWriter,
+			ManifestListFile{SequenceNumber: 1, Operation: "append", AddedDataFiles: 1, AddedRecords: 2},
+			ManifestListFile{SequenceNumber: 2, Operation: "append", AddedDataFiles: 1, AddedRecords: 1},
+			ManifestListFile{SequenceNumber: 3, Operation: "overwrite", DeletedDataFiles: 1, DeletedRecords: 2, AddedDataFiles: 1, AddedRecords: 1},
+			ManifestListFile{SequenceNumber: 4, Operation: "append", AddedDataFiles: 1, AddedRecords: 1},
+			ManifestListFile{SequenceNumber: 5, Operation: "replace", DeletedDataFiles: 3, DeletedRecords: 3, AddedDataFiles: 1, AddedRecords: 3},
+		)
+		testCompactedRecords(t, duckdb, compactedParquetFiles[0], "name", "id", [][]string{
+			{"1", "John Doe"},
+			{"2", PG_NULL_STRING},
+			{"3", "Jane"},
+		})
+
+		// The next incremental sync overwrites records in the compacted data file
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"3", "Jane Doe"}}))
+
+		testManifestListFiles(t, icebergWriter,
+			ManifestListFile{SequenceNumber: 1, Operation: "append", AddedDataFiles: 1, AddedRecords: 2},
+			ManifestListFile{SequenceNumber: 2, Operation: "append", AddedDataFiles: 1, AddedRecords: 1},
+			ManifestListFile{SequenceNumber: 3, Operation: "overwrite", DeletedDataFiles: 1, DeletedRecords: 2, AddedDataFiles: 1, AddedRecords: 1},
+			ManifestListFile{SequenceNumber: 4, Operation: "append", AddedDataFiles: 1, AddedRecords: 1},
+			ManifestListFile{SequenceNumber: 5, Operation: "replace", DeletedDataFiles: 3, DeletedRecords: 3, AddedDataFiles: 1, AddedRecords: 3},
+			ManifestListFile{SequenceNumber: 6, Operation: "overwrite", DeletedDataFiles: 1, DeletedRecords: 3, AddedDataFiles: 1, AddedRecords: 2},
+			ManifestListFile{SequenceNumber: 7, Operation: "append", AddedDataFiles: 1, AddedRecords: 1},
+		)
+	})
+
+	t.Run("Sorts compacted rows by the sort order", func(t *testing.T) {
+		sortOrder, err := NewIcebergSortOrder([]string{"name DESC NULLS LAST"}, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, sortOrder, 10, createTestLoadRows([][]string{{"3", "Jane"}}))
+
+		compactedParquetFiles := icebergWriter.Compact(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, sortOrder, 1024*1024, 2, 0)
+
+		if len(compactedParquetFiles) != 1 || compactedParquetFiles[0].SortOrderId != ICEBERG_SORT_ORDER_ID_SORTED {
+			t.Fatalf("Expected 1 sorted compacted Parquet file, got %v", compactedParquetFiles)
+		}
+		testCompactedRecords(t, duckdb, compactedParquetFiles[0], "name", "", [][]string{
+			{"1", "John"},
+			{"3", "Jane"},
+			{"2", PG_NULL_STRING},
+		})
+	})
+
+	t.Run("Keeps dates and timestamps of compacted rows", func(t *testing.T) {
+		pgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", NumericPrecision: "32", OrdinalPosition: "1", FieldId: 1, Namespace: "pg_catalog", PartOfPrimaryKey: true},
+			{ColumnName: "name", DataType: "timestamp with time zone", UdtName: "timestamptz", IsNullable: "YES", DatetimePrecision: "6", OrdinalPosition: "2", FieldId: 2, Namespace: "pg_catalog"},
+			{ColumnName: "born_on", DataType: "date", UdtName: "date", IsNullable: "YES", OrdinalPosition: "3", FieldId: 3, Namespace: "pg_catalog"},
+		}
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, pgSchemaColumns, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows([][]string{{"1", "2024-01-02 03:04:05.123456+00", "1990-05-06"}}))
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, pgSchemaColumns, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"2", PG_NULL_STRING, PG_NULL_STRING}}))
+
+		compactedParquetFiles := icebergWriter.Compact(TEST_ICEBERG_WRITER_SCHEMA_TABLE, pgSchemaColumns, IcebergSortOrder{}, 1024*1024, 2, 0)
+
+		if len(compactedParquetFiles) != 1 {
+			t.Fatalf("Expected 1 compacted Parquet file, got %v", len(compactedParquetFiles))
+		}
+		testCompactedRecords(t, duckdb, compactedParquetFiles[0], "name::text || ' ' || born_on::text", "id", [][]string{
+			{"1", "2024-01-02 03:04:05.123456 1990-05-06"},
+			{"2", PG_NULL_STRING},
+		})
+	})
+
+	t.Run("Rewrites data files with deleted rows once there are enough position delete files", func(t *testing.T) {
+		config.Pg.IncrementalUpdateMode = PG_INCREMENTAL_UPDATE_MODE_MERGE_ON_READ
+		defer func() { config.Pg.IncrementalUpdateMode = PG_INCREMENTAL_UPDATE_MODE_COPY_ON_WRITE }()
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"1", "John Doe"}}))
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"3", "Jane"}}))
+
+		compactedParquetFiles := icebergWriter.Compact(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 1024*1024, 0, 2)
+
+		if len(compactedParquetFiles) != 0 {
+			t.Fatalf("Expected no compacted Parquet files, got %v", len(compactedParquetFiles))
+		}
+
+		compactedParquetFiles = icebergWriter.Compact(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 1024*1024, 0, 1)
+
+		if len(compactedParquetFiles) != 1 {
+			t.Fatalf("Expected 1 compacted Parquet file, got %v", len(compactedParquetFiles))
+		}
+		testManifestListFiles(t, icebergWriter,
+			ManifestListFile{SequenceNumber: 1, Operation: "append", AddedDataFiles: 1, AddedRecords: 2},
+			ManifestListFile{SequenceNumber: 2, Operation: "overwrite", AddedDataFiles: 1, AddedRecords: 1, AddedDeleteFiles: 1, AddedPositionDeletes: 1},
+			ManifestListFile{SequenceNumber: 3, Operation: "append", AddedDataFiles: 1, AddedRecords: 1},
+			ManifestListFile{SequenceNumber: 4, Operation: "replace", DeletedDataFiles: 1, DeletedRecords: 2, AddedDataFiles: 1, AddedRecords: 1, RemovedDeleteFiles: 1, RemovedPositionDeletes: 1},
+		)
+		testCompactedRecords(t, duckdb, compactedParquetFiles[0], "name", "id", [][]string{
+			{"2", PG_NULL_STRING},
+		})
+	})
+
+	t.Run("Combines small data files without applying position delete files after a sync", func(t *testing.T) {
+		config.Pg.IncrementalUpdateMode = PG_INCREMENTAL_UPDATE_MODE_MERGE_ON_READ
+		defer func() { config.Pg.IncrementalUpdateMode = PG_INCREMENTAL_UPDATE_MODE_COPY_ON_WRITE }()
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"1", "John Doe"}}))
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"3", "Jane"}}))
+
+		compactedParquetFiles := icebergWriter.Compact(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 1024*1024, 2, 0)
+
+		if len(compactedParquetFiles) != 1 {
+			t.Fatalf("Expected 1 compacted Parquet file, got %v", len(compactedParquetFiles))
+		}
+		testManifestListFiles(t, icebergWriter,
+			ManifestListFile{SequenceNumber: 1, Operation: "append", AddedDataFiles: 1, AddedRecords: 2},
+			ManifestListFile{SequenceNumber: 2, Operation: "overwrite", AddedDataFiles: 1, AddedRecords: 1, AddedDeleteFiles: 1, AddedPositionDeletes: 1},
+			ManifestListFile{SequenceNumber: 3, Operation: "append", AddedDataFiles: 1, AddedRecords: 1},
+			ManifestListFile{SequenceNumber: 4, Operation: "replace", DeletedDataFiles: 2, DeletedRecords: 2, AddedDataFiles: 1, AddedRecords: 2},
+		)
+		testCompactedRecords(t, duckdb, compactedParquetFiles[0], "name", "id", [][]string{
+			{"1", "John Doe"},
+			{"3", "Jane"},
+		})
+	})
+
+	t.Run("Skips compaction with fewer small data files than the minimum", func(t *testing.T) {
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"3", "Jane"}}))
+
+		compactedParquetFiles := icebergWriter.Compact(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 1024*1024, 3, 0)
+
+		if len(compactedParquetFiles) != 0 {
+			t.Fatalf("Expected no compacted Parquet files, got %v", len(compactedParquetFiles))
+		}
+		testManifestListFiles(t, icebergWriter,
+			ManifestListFile{SequenceNumber: 1, Operation: "append", AddedDataFiles: 1, AddedRecords: 2},
+			ManifestListFile{SequenceNumber: 2, Operation: "append", AddedDataFiles: 1, AddedRecords: 1},
+		)
+	})
+}
+
+func TestBinPackParquetFiles(t *testing.T) {
+	t.Run("Groups Parquet files into bins of up to the target size", func(t *testing.T) {
+		parquetFiles := []ParquetFile{{Size: 30}, {Size: 60}, {Size: 50}, {Size: 20}, {Size: 40}}
+
+		bins := binPackParquetFiles(parquetFiles, 100)
+
+		expectedBins := [][]int{{1, 4}, {2, 0, 3}}
+		if len(bins) != len(expectedBins) {
+			t.Fatalf("Expected bins %v, got %v", expectedBins, bins)
+		}
+		for i := range expectedBins {
+			if !slices.Equal(bins[i], expectedBins[i]) {
+				t.Errorf("Expected bins %v, got %v", expectedBins, bins)
+			}
+		}
+	})
+}
+
+func testCompactedRecords(t *testing.T, duckdb *Duckdb, compactedParquetFile ParquetFile, valueSql string, orderBy string, expectedRecords [][]string) {
+	sql := "SELECT id::text, COALESCE(" + valueSql + ", '" + PG_NULL_STRING + "') FROM '" + compactedParquetFile.Path + "'"
+	if orderBy != "" {
+		sql += " ORDER BY " + orderBy
+	}
+	rows, err := duckdb.QueryContext(context.Background(), sql)
+	if err != nil {
+		t.Fatalf("Error querying DuckDB: %v", err)
+	}
+	defer rows.Close()
+
+	actualRecords := [][]string{}
+	for rows.Next() {
+		row := make([]string, 2)
+		err := rows.Scan(&row[0], &row[1])
+		if err != nil {
+			t.Fatalf("Error scanning row: %v", err)
+		}
+		actualRecords = append(actualRecords, row)
+	}
+
+	if !slices.EqualFunc(actualRecords, expectedRecords, slices.Equal) {
+		t.Fatalf("Expected records %v, got %v", expectedRecords, actualRecords)
+	}
+}
+
+func TestExpireSnapshots(t *testing.T) {
+	config := loadTestConfig()
+	icebergWriter := NewIcebergWriter(config)
+	metadataDirPath := icebergWriter.storage.CreateMetadataDir(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+
+	t.Cleanup(func() {
+		icebergWriter.storage.DeleteSchema(TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema)
+	})
+
+	t.Run("Expires old snapshots and deletes the files only they reference", func(t *testing.T) {
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"3", "Jane"}}))
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"1", "John Doe"}}))
+
+		expiredSnapshotCount, deletedFilePaths := icebergWriter.ExpireSnapshots(TEST_ICEBERG_WRITER_SCHEMA_TABLE, time.Now().Add(time.Hour), 2, false)
+
+		if expiredSnapshotCount != 2 {
+			t.Fatalf("Expected 2 expired snapshots, got %v", expiredSnapshotCount)
+		}
+		testManifestListFiles(t, icebergWriter,
+			ManifestListFile{SequenceNumber: 3, Operation: "overwrite", DeletedDataFiles: 1, DeletedRecords: 2, AddedDataFiles: 1, AddedRecords: 1},
+			ManifestListFile{SequenceNumber: 4, Operation: "append", AddedDataFiles: 1, AddedRecords: 1},
+		)
+		// 2 manifest lists and the manifest of the initial data file, which is still referenced as deleted by the overwrite
+		if len(deletedFilePaths) != 3 {
+			t.Fatalf("Expected 3 deleted files, got %v", deletedFilePaths)
+		}
+		for _, filePath := range deletedFilePaths {
+			if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+				t.Errorf("Expected %v to be deleted", filePath)
+			}
+		}
+		for _, filePath := range icebergWriter.tableReferencedFilePaths(metadataDirPath).Values() {
+			if _, err := os.Stat(filePath); err != nil {
+				t.Errorf("Expected %v to be kept, got %v", filePath, err)
+			}
+		}
+
+		manifestListFiles, err := icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+		PanicIfError(err, config)
+		if manifestListFiles[0].ParentSnapshotId == 0 {
+			t.Errorf("Expected the first retained snapshot to keep its expired parent")
+		}
+		lastManifestListFile := manifestListFiles[len(manifestListFiles)-1]
+		if lastManifestListFile.TotalDataFiles != 3 || lastManifestListFile.TotalRecords != 3 {
+			t.Errorf("Expected 3 total data files and records, got %v and %v", lastManifestListFile.TotalDataFiles, lastManifestListFile.TotalRecords)
+		}
+	})
+
+	t.Run("Keeps snapshots newer than the expiration time", func(t *testing.T) {
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"3", "Jane"}}))
+
+		expiredSnapshotCount, deletedFilePaths := icebergWriter.ExpireSnapshots(TEST_ICEBERG_WRITER_SCHEMA_TABLE, time.Now().Add(-time.Hour), 1, false)
+
+		if expiredSnapshotCount != 0 || len(deletedFilePaths) != 0 {
+			t.Fatalf("Expected no expired snapshots, got %v with deleted files %v", expiredSnapshotCount, deletedFilePaths)
+		}
+		testManifestListFiles(t, icebergWriter,
+			ManifestListFile{SequenceNumber: 1, Operation: "append", AddedDataFiles: 1, AddedRecords: 2},
+			ManifestListFile{SequenceNumber: 2, Operation: "append", AddedDataFiles: 1, AddedRecords: 1},
+		)
+	})
+
+	t.Run("Doesn't change anything in a dry run", func(t *testing.T) {
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"3", "Jane"}}))
+
+		expiredSnapshotCount, deletedFilePaths := icebergWriter.ExpireSnapshots(TEST_ICEBERG_WRITER_SCHEMA_TABLE, time.Now().Add(time.Hour), 1, true)
+
+		if expiredSnapshotCount != 1 || len(deletedFilePaths) != 1 {
+			t.Fatalf("Expected 1 snapshot to expire with its manifest list, got %v with deleted files %v", expiredSnapshotCount, deletedFilePaths)
+		}
+		if _, err := os.Stat(deletedFilePaths[0]); err != nil {
+			t.Errorf("Expected %v to be kept, got %v", deletedFilePaths[0], err)
+		}
+		testManifestListFiles(t, icebergWriter,
+			ManifestListFile{SequenceNumber: 1, Operation: "append", AddedDataFiles: 1, AddedRecords: 2},
+			ManifestListFile{SequenceNumber: 2, Operation: "append", AddedDataFiles: 1, AddedRecords: 1},
+		)
+	})
+}
+
+func TestRemoveOrphanFiles(t *testing.T) {
+	config := loadTestConfig()
+	icebergWriter := NewIcebergWriter(config)
+	dataDirPath := icebergWriter.storage.CreateDataDir(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+
+	t.Cleanup(func() {
+		icebergWriter.storage.DeleteSchema(TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema)
+	})
+
+	t.Run("Deletes old files referenced by no snapshot", func(t *testing.T) {
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+		orphanParquetFile, _, err := icebergWriter.storage.CreateParquet(dataDirPath, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS), MAX_WRITE_PARQUET_PAYLOAD_SIZE)
+		PanicIfError(err, config)
+		keptParquetFile, _, err := icebergWriter.storage.CreateParquet(dataDirPath, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS), MAX_WRITE_PARQUET_PAYLOAD_SIZE)
+		PanicIfError(err, config)
+
+		deletedFilePaths := icebergWriter.RemoveOrphanFiles(TEST_ICEBERG_WRITER_SCHEMA_TABLE, []string{keptParquetFile.Path}, time.Now().Add(time.Hour), false)
+
+		if !slices.Equal(deletedFilePaths, []string{orphanParquetFile.Path}) {
+			t.Fatalf("Expected the orphan file to be deleted, got %v", deletedFilePaths)
+		}
+		if _, err := os.Stat(keptParquetFile.Path); err != nil {
+			t.Errorf("Expected %v to be kept, got %v", keptParquetFile.Path, err)
+		}
+		testManifestListFiles(t, icebergWriter,
+			ManifestListFile{SequenceNumber: 1, Operation: "append", AddedDataFiles: 1, AddedRecords: 2},
+		)
+	})
+
+	t.Run("Keeps recent files referenced by no snapshot", func(t *testing.T) {
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+		orphanParquetFile, _, err := icebergWriter.storage.CreateParquet(dataDirPath, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS), MAX_WRITE_PARQUET_PAYLOAD_SIZE)
+		PanicIfError(err, config)
+
+		deletedFilePaths := icebergWriter.RemoveOrphanFiles(TEST_ICEBERG_WRITER_SCHEMA_TABLE, []string{}, time.Now().Add(-time.Hour), false)
+
+		if len(deletedFilePaths) != 0 {
+			t.Fatalf("Expected no deleted files, got %v", deletedFilePaths)
+		}
+		if _, err := os.Stat(orphanParquetFile.Path); err != nil {
+			t.Errorf("Expected %v to be kept, got %v", orphanParquetFile.Path, err)
+		}
+	})
+}
+
+func TestRollback(t *testing.T) {
+	config := loadTestConfig()
+	icebergWriter := NewIcebergWriter(config)
+	duckdb := NewDuckdb(config, false)
+	defer duckdb.Close()
+	metadataDirPath := icebergWriter.storage.CreateMetadataDir(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+
+	t.Cleanup(func() {
+		icebergWriter.storage.DeleteSchema(TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema)
+	})
+
+	t.Run("Commits a snapshot with the data files of a previous snapshot", func(t *testing.T) {
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"3", "Jane"}}))
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"1", "John Doe"}}))
+		manifestListFiles, err := icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+		PanicIfError(err, config)
+
+		rollbackManifestListFile := icebergWriter.Rollback(TEST_ICEBERG_WRITER_SCHEMA_TABLE, manifestListFiles[0].SnapshotId)
+
+		testManifestListFiles(t, icebergWriter,
+			ManifestListFile{SequenceNumber: 1, Operation: "append", AddedDataFiles: 1, AddedRecords: 2},
+			ManifestListFile{SequenceNumber: 2, Operation: "append", AddedDataFiles: 1, AddedRecords: 1},
+			ManifestListFile{SequenceNumber: 3, Operation: "overwrite", DeletedDataFiles: 1, DeletedRecords: 2, AddedDataFiles: 1, AddedRecords: 1},
+			ManifestListFile{SequenceNumber: 4, Operation: "append", AddedDataFiles: 1, AddedRecords: 1},
+			ManifestListFile{SequenceNumber: 5, Operation: "overwrite", DeletedDataFiles: 3, DeletedRecords: 3, AddedDataFiles: 1, AddedRecords: 2},
+		)
+		manifestListFiles, err = icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+		PanicIfError(err, config)
+		lastManifestListFile := manifestListFiles[len(manifestListFiles)-1]
+		if lastManifestListFile.SnapshotId != rollbackManifestListFile.SnapshotId || lastManifestListFile.SnapshotId == manifestListFiles[0].SnapshotId {
+			t.Errorf("Expected a new current snapshot, got %v", lastManifestListFile.SnapshotId)
+		}
+		if lastManifestListFile.TotalDataFiles != 1 || lastManifestListFile.TotalRecords != 2 {
+			t.Errorf("Expected 1 total data file and 2 total records, got %v and %v", lastManifestListFile.TotalDataFiles, lastManifestListFile.TotalRecords)
+		}
+		manifestListItems, err := icebergWriter.storage.ExistingManifestListItems(lastManifestListFile)
+		PanicIfError(err, config)
+		parquetFile, err := icebergWriter.storage.ExistingParquetFile(manifestListItems[0].ManifestFile)
+		PanicIfError(err, config)
+		testCompactedRecords(t, duckdb, parquetFile, "name", "id", [][]string{
+			{"1", "John"},
+			{"2", PG_NULL_STRING},
+		})
+	})
+
+	t.Run("Continues the sequence numbers after a rollback", func(t *testing.T) {
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"4", "Alice"}}))
+
+		manifestListFiles, err := icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+		PanicIfError(err, config)
+		lastManifestListFile := manifestListFiles[len(manifestListFiles)-1]
+		if lastManifestListFile.SequenceNumber != 6 || lastManifestListFile.TotalRecords != 3 {
+			t.Errorf("Expected sequence number 6 with 3 total records, got %v with %v", lastManifestListFile.SequenceNumber, lastManifestListFile.TotalRecords)
+		}
+	})
+
+	t.Run("Fails to roll back to the current snapshot", func(t *testing.T) {
+		manifestListFiles, err := icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+		PanicIfError(err, config)
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic")
+			}
+		}()
+
+		icebergWriter.Rollback(TEST_ICEBERG_WRITER_SCHEMA_TABLE, manifestListFiles[len(manifestListFiles)-1].SnapshotId)
+	})
+}
+
+func TestSnapshotRefs(t *testing.T) {
+	config := loadTestConfig()
+	icebergWriter := NewIcebergWriter(config)
+	metadataDirPath := icebergWriter.storage.CreateMetadataDir(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+
+	t.Cleanup(func() {
+		icebergWriter.storage.DeleteSchema(TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema)
+	})
+
+	t.Run("Points tags and branches to snapshots", func(t *testing.T) {
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"3", "Jane"}}))
+		manifestListFiles, err := icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+		PanicIfError(err, config)
+
+		icebergWriter.SetSnapshotRef(TEST_ICEBERG_WRITER_SCHEMA_TABLE, manifestListFiles[0].SnapshotId, SnapshotRef{Name: "initial", Type: ICEBERG_SNAPSHOT_REF_TAG})
+		icebergWriter.SetSnapshotRef(TEST_ICEBERG_WRITER_SCHEMA_TABLE, manifestListFiles[1].SnapshotId, SnapshotRef{Name: "audit", Type: ICEBERG_SNAPSHOT_REF_BRANCH})
+
+		testSnapshotRefs(t, icebergWriter, [][]SnapshotRef{
+			{{Name: "initial", Type: ICEBERG_SNAPSHOT_REF_TAG}},
+			{{Name: "audit", Type: ICEBERG_SNAPSHOT_REF_BRANCH}},
+		})
+
+		// Moves an existing tag
+		icebergWriter.SetSnapshotRef(TEST_ICEBERG_WRITER_SCHEMA_TABLE, manifestListFiles[1].SnapshotId, SnapshotRef{Name: "initial", Type: ICEBERG_SNAPSHOT_REF_TAG})
+		icebergWriter.SetSnapshotRef(TEST_ICEBERG_WRITER_SCHEMA_TABLE, manifestListFiles[0].SnapshotId, SnapshotRef{Name: "initial", Type: ICEBERG_SNAPSHOT_REF_TAG})
+
+		testSnapshotRefs(t, icebergWriter, [][]SnapshotRef{
+			{{Name: "initial", Type: ICEBERG_SNAPSHOT_REF_TAG}},
+			{{Name: "audit", Type: ICEBERG_SNAPSHOT_REF_BRANCH}},
+		})
+	})
+
+	t.Run("Keeps the references of snapshots replaced by a full refresh", func(t *testing.T) {
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows([][]string{{"1", "John"}}))
+
+		testManifestListFiles(t, icebergWriter,
+			ManifestListFile{SequenceNumber: 1, Operation: "append", AddedDataFiles: 1, AddedRecords: 2},
+			ManifestListFile{SequenceNumber: 2, Operation: "append", AddedDataFiles: 1, AddedRecords: 1},
+			ManifestListFile{SequenceNumber: 3, Operation: "overwrite", DeletedDataFiles: 2, DeletedRecords: 3, AddedDataFiles: 1, AddedRecords: 1},
+		)
+		testSnapshotRefs(t, icebergWriter, [][]SnapshotRef{
+			{{Name: "initial", Type: ICEBERG_SNAPSHOT_REF_TAG}},
+			{{Name: "audit", Type: ICEBERG_SNAPSHOT_REF_BRANCH}},
+			nil,
+		})
+	})
+
+	t.Run("Doesn't expire referenced snapshots and the snapshots after them", func(t *testing.T) {
+		expiredSnapshotCount, _ := icebergWriter.ExpireSnapshots(TEST_ICEBERG_WRITER_SCHEMA_TABLE, time.Now().Add(time.Hour), 1, false)
+
+		if expiredSnapshotCount != 0 {
+			t.Fatalf("Expected no expired snapshots, got %v", expiredSnapshotCount)
+		}
+
+		removed := icebergWriter.RemoveSnapshotRef(TEST_ICEBERG_WRITER_SCHEMA_TABLE, "initial")
+		if !removed || icebergWriter.RemoveSnapshotRef(TEST_ICEBERG_WRITER_SCHEMA_TABLE, "initial") {
+			t.Fatalf("Expected the tag to be removed once")
+		}
+		expiredSnapshotCount, _ = icebergWriter.ExpireSnapshots(TEST_ICEBERG_WRITER_SCHEMA_TABLE, time.Now().Add(time.Hour), 1, false)
+
+		if expiredSnapshotCount != 1 {
+			t.Fatalf("Expected 1 expired snapshot, got %v", expiredSnapshotCount)
+		}
+		testSnapshotRefs(t, icebergWriter, [][]SnapshotRef{
+			{{Name: "audit", Type: ICEBERG_SNAPSHOT_REF_BRANCH}},
+			nil,
+		})
+	})
+
+	t.Run("Tags restore points and keeps the most recent ones", func(t *testing.T) {
+		icebergWriter.RemoveSnapshotRef(TEST_ICEBERG_WRITER_SCHEMA_TABLE, "audit")
+
+		icebergWriter.TagRestorePoint(TEST_ICEBERG_WRITER_SCHEMA_TABLE, ICEBERG_RESTORE_POINT_TAG_PREFIX+"1", 2)
+		icebergWriter.TagRestorePoint(TEST_ICEBERG_WRITER_SCHEMA_TABLE, ICEBERG_RESTORE_POINT_TAG_PREFIX+"2", 2) // The snapshot didn't change
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"3", "Jane"}}))
+		icebergWriter.TagRestorePoint(TEST_ICEBERG_WRITER_SCHEMA_TABLE, ICEBERG_RESTORE_POINT_TAG_PREFIX+"3", 2)
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{{"4", "Alice"}}))
+		icebergWriter.TagRestorePoint(TEST_ICEBERG_WRITER_SCHEMA_TABLE, ICEBERG_RESTORE_POINT_TAG_PREFIX+"4", 2)
+
+		testSnapshotRefs(t, icebergWriter, [][]SnapshotRef{
+			nil,
+			nil,
+			{{Name: ICEBERG_RESTORE_POINT_TAG_PREFIX + "3", Type: ICEBERG_SNAPSHOT_REF_TAG}},
+			{{Name: ICEBERG_RESTORE_POINT_TAG_PREFIX + "4", Type: ICEBERG_SNAPSHOT_REF_TAG}},
+		})
+	})
+}
+
+func testSnapshotRefs(t *testing.T, icebergWriter *IcebergWriter, expectedRefs [][]SnapshotRef) {
+	metadataDirPath := icebergWriter.storage.CreateMetadataDir(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+	manifestListFiles, err := icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+	if err != nil {
+		t.Fatalf("Error reading manifest list files: %v", err)
+	}
+
+	if len(manifestListFiles) != len(expectedRefs) {
+		t.Fatalf("Expected %d manifest list files, got %d", len(expectedRefs), len(manifestListFiles))
+	}
+	for i, manifestListFile := range manifestListFiles {
+		if !slices.Equal(manifestListFile.Refs, expectedRefs[i]) {
+			t.Errorf("Expected refs %v, got %v (sequence number %d)", expectedRefs[i], manifestListFile.Refs, manifestListFile.SequenceNumber)
+		}
+	}
+}
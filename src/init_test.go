@@ -380,6 +380,7 @@ var TEST_SCHEMA_SIMPLE_TABLE_PG_SCHEMA_COLUMNS = []PgSchemaColumn{
 		UdtName:                "int4",
 		IsNullable:             "NO",
 		OrdinalPosition:        "1",
+		FieldId:                1,
 		CharacterMaximumLength: "0",
 		NumericPrecision:       "32",
 		NumericScale:           "0",
@@ -400,6 +401,11 @@ func init() {
 			PUBLIC_TEST_TABLE_PG_SCHEMA_COLUMNS[i].IsNullable = "YES"
 		}
 	}
+	PUBLIC_TEST_TABLE_PG_SCHEMA_COLUMNS = AssignIcebergFieldIds(nil, PUBLIC_TEST_TABLE_PG_SCHEMA_COLUMNS)
+
+	// Full refreshes keep the previous snapshots, so the tables are written without the history of previous test runs
+	icebergWriter.DeleteSchemaTable(IcebergSchemaTable{Schema: "public", Table: "test_table"})
+	icebergWriter.DeleteSchemaTable(IcebergSchemaTable{Schema: "test_schema", Table: "simple_table"})
 
 	i := 0
 	icebergWriter.Write(
@@ -1,15 +1,20 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	_ "net/http/pprof"
+	"os"
+	"slices"
+	"strings"
 	"time"
 )
 
 func main() {
+	defer ReportPanic()
 	config := LoadConfig()
 
 	if len(flag.Args()) == 0 {
@@ -28,19 +33,93 @@ func main() {
 	case "start":
 		start(config)
 	case "sync":
-		if config.Pg.SyncInterval != "" {
-			duration, err := time.ParseDuration(config.Pg.SyncInterval)
-			if err != nil {
-				panic("Invalid interval format: " + config.Pg.SyncInterval)
-			}
-			LogInfo(config, "Starting sync loop with interval:", config.Pg.SyncInterval)
+		syncer := NewSyncer(config)
+		if config.Pg.SyncInterval != "" || config.Pg.HasSyncSchedules() {
+			LogInfo(config, "Starting sync scheduler")
 			for {
-				syncFromPg(config)
-				LogInfo(config, "Sleeping for", config.Pg.SyncInterval)
-				time.Sleep(duration)
+				err := syncFromPg(config, syncer)
+				if err != nil {
+					LogError(config, "Sync from PostgreSQL failed:", err)
+
+					// Retrying can't succeed until the configuration is changed
+					var reservedErr *ReservedPgSchemaError
+					if errors.As(err, &reservedErr) {
+						os.Exit(1)
+					}
+				}
+
+				nextSyncAt, scheduled := syncer.NextSyncAt()
+				if !scheduled {
+					if err == nil {
+						LogInfo(config, "No tables are scheduled to sync again")
+						return
+					}
+					nextSyncAt = time.Now().Add(SYNC_RETRY_INTERVAL) // The sync failed before scheduling tables
+				}
+				LogInfo(config, "Sleeping until", nextSyncAt.UTC().Format(time.RFC3339))
+				time.Sleep(time.Until(nextSyncAt))
 			}
 		} else {
-			syncFromPg(config)
+			err := syncFromPg(config, syncer)
+			if err != nil {
+				LogError(config, "Sync from PostgreSQL failed:", err)
+				os.Exit(1)
+			}
+		}
+	case "compact":
+		syncer := NewSyncer(config)
+		err := syncer.CompactIcebergTables()
+		if err != nil {
+			LogError(config, "Compaction failed:", err)
+			os.Exit(1)
+		}
+	case "maintenance":
+		maintenanceCommand := flag.Arg(1)
+		maintenanceConfig := LoadMaintenanceConfig(flag.Args()[min(2, len(flag.Args())):])
+		maintenance := NewMaintenance(config)
+
+		var err error
+		switch maintenanceCommand {
+		case MAINTENANCE_COMMAND_EXPIRE_SNAPSHOTS:
+			err = maintenance.ExpireSnapshots(maintenanceConfig)
+		case MAINTENANCE_COMMAND_REMOVE_ORPHAN_FILES:
+			err = maintenance.RemoveOrphanFiles(maintenanceConfig)
+		default:
+			panic("Unknown maintenance command: \"" + maintenanceCommand + "\". Must be one of " + MAINTENANCE_COMMAND_EXPIRE_SNAPSHOTS + ", " + MAINTENANCE_COMMAND_REMOVE_ORPHAN_FILES)
+		}
+		if err != nil {
+			LogError(config, "Maintenance failed:", err)
+			os.Exit(1)
+		}
+	case "table":
+		tableCommand := flag.Arg(1)
+		if !slices.Contains(TABLE_COMMANDS, tableCommand) {
+			panic("Unknown table command: \"" + tableCommand + "\". Must be one of " + strings.Join(TABLE_COMMANDS, ", "))
+		}
+		tableCommandConfig := LoadTableCommandConfig(tableCommand, flag.Args()[min(2, len(flag.Args())):])
+		tableHistory := NewTableHistory(config)
+
+		var err error
+		switch tableCommand {
+		case TABLE_COMMAND_ROLLBACK:
+			err = tableHistory.Rollback(tableCommandConfig)
+		case TABLE_COMMAND_TAG:
+			err = tableHistory.Tag(tableCommandConfig)
+		case TABLE_COMMAND_BRANCH:
+			err = tableHistory.Branch(tableCommandConfig)
+		case TABLE_COMMAND_REMOVE_REF:
+			err = tableHistory.RemoveRef(tableCommandConfig)
+		}
+		if err != nil {
+			LogError(config, "Table command failed:", err)
+			os.Exit(1)
+		}
+	case "catalog":
+		catalogServer := NewCatalogServer(config, NewIcebergReader(config))
+		err := catalogServer.ListenAndServe()
+		if err != nil {
+			LogError(config, "Iceberg REST catalog failed:", err)
+			os.Exit(1)
 		}
 	case "version":
 		fmt.Println("BemiDB version:", VERSION)
@@ -66,6 +145,7 @@ func start(config *Config) {
 		postgres := NewPostgres(config, &conn)
 
 		go func() {
+			defer ReportPanic()
 			postgres.Run(queryHandler)
 			defer postgres.Close()
 			LogInfo(config, "BemiDB: Closed connection from", conn.RemoteAddr())
@@ -73,8 +153,12 @@ func start(config *Config) {
 	}
 }
 
-func syncFromPg(config *Config) {
-	syncer := NewSyncer(config)
-	syncer.SyncFromPostgres()
+func syncFromPg(config *Config, syncer *Syncer) error {
+	err := syncer.SyncFromPostgres()
+	if err != nil {
+		return err
+	}
+
 	LogInfo(config, "Sync from PostgreSQL completed successfully.")
+	return nil
 }
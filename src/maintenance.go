@@ -0,0 +1,120 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+const (
+	MAINTENANCE_COMMAND_EXPIRE_SNAPSHOTS    = "expire-snapshots"
+	MAINTENANCE_COMMAND_REMOVE_ORPHAN_FILES = "remove-orphan-files"
+)
+
+// Limits the storage growth of synced tables by expiring old snapshots and removing files no snapshot references
+type Maintenance struct {
+	config        *Config
+	icebergWriter *IcebergWriter
+}
+
+func NewMaintenance(config *Config) *Maintenance {
+	return &Maintenance{
+		config:        config,
+		icebergWriter: NewIcebergWriter(config),
+	}
+}
+
+func (maintenance *Maintenance) ExpireSnapshots(maintenanceConfig *MaintenanceConfig) (err error) {
+	defer RecoverError(&err)
+
+	schemaTables, err := maintenance.schemaTables()
+	if err != nil {
+		return err
+	}
+
+	expireBefore := time.Now().Add(-maintenanceConfig.OlderThan)
+	totalExpiredSnapshotCount, totalDeletedFileCount := 0, 0
+	for _, schemaTable := range schemaTables {
+		expiredSnapshotCount, deletedFilePaths := maintenance.icebergWriter.ExpireSnapshots(schemaTable, expireBefore, maintenanceConfig.RetainLast, maintenanceConfig.DryRun)
+		if expiredSnapshotCount == 0 {
+			continue
+		}
+
+		maintenance.logDryRun(maintenanceConfig, deletedFilePaths)
+		LogInfo(maintenance.config, maintenance.actionPrefix(maintenanceConfig)+"Expired", expiredSnapshotCount, "snapshot(s) of", schemaTable.String(), "and deleted", len(deletedFilePaths), "file(s)")
+		totalExpiredSnapshotCount += expiredSnapshotCount
+		totalDeletedFileCount += len(deletedFilePaths)
+	}
+
+	LogInfo(maintenance.config, maintenance.actionPrefix(maintenanceConfig)+"Expired", totalExpiredSnapshotCount, "snapshot(s) and deleted", totalDeletedFileCount, "file(s)")
+	return nil
+}
+
+func (maintenance *Maintenance) RemoveOrphanFiles(maintenanceConfig *MaintenanceConfig) (err error) {
+	defer RecoverError(&err)
+
+	schemaTables, err := maintenance.schemaTables()
+	if err != nil {
+		return err
+	}
+
+	// Parquet files of an interrupted sync are reused when it resumes
+	syncJournal, err := maintenance.icebergWriter.storage.SyncJournal()
+	if err != nil {
+		return err
+	}
+
+	modifiedBefore := time.Now().Add(-maintenanceConfig.OlderThan)
+	totalDeletedFileCount := 0
+	for _, schemaTable := range schemaTables {
+		keptFilePaths := []string{}
+		if syncJournal != nil && syncJournal.InProgressTable != nil && syncJournal.InProgressTable.PgSchemaTable().ToIcebergSchemaTable() == schemaTable {
+			for _, parquetFile := range syncJournal.InProgressTable.ParquetFiles {
+				keptFilePaths = append(keptFilePaths, parquetFile.Path)
+			}
+		}
+
+		deletedFilePaths := maintenance.icebergWriter.RemoveOrphanFiles(schemaTable, keptFilePaths, modifiedBefore, maintenanceConfig.DryRun)
+		if len(deletedFilePaths) == 0 {
+			continue
+		}
+
+		maintenance.logDryRun(maintenanceConfig, deletedFilePaths)
+		LogInfo(maintenance.config, maintenance.actionPrefix(maintenanceConfig)+"Deleted", len(deletedFilePaths), "orphan file(s) of", schemaTable.String())
+		totalDeletedFileCount += len(deletedFilePaths)
+	}
+
+	LogInfo(maintenance.config, maintenance.actionPrefix(maintenanceConfig)+"Deleted", totalDeletedFileCount, "orphan file(s)")
+	return nil
+}
+
+// Returns the tables in the storage sorted by name
+func (maintenance *Maintenance) schemaTables() ([]IcebergSchemaTable, error) {
+	icebergSchemaTables, err := maintenance.icebergWriter.storage.IcebergSchemaTables()
+	if err != nil {
+		return nil, err
+	}
+
+	schemaTables := icebergSchemaTables.Values()
+	slices.SortFunc(schemaTables, func(a, b IcebergSchemaTable) int {
+		return cmp.Compare(a.String(), b.String())
+	})
+	return schemaTables, nil
+}
+
+func (maintenance *Maintenance) logDryRun(maintenanceConfig *MaintenanceConfig, deletedFilePaths []string) {
+	if !maintenanceConfig.DryRun {
+		return
+	}
+
+	for _, filePath := range deletedFilePaths {
+		LogInfo(maintenance.config, "[Dry run] Would delete:", filePath)
+	}
+}
+
+func (maintenance *Maintenance) actionPrefix(maintenanceConfig *MaintenanceConfig) string {
+	if maintenanceConfig.DryRun {
+		return "[Dry run] "
+	}
+	return ""
+}
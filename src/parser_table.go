@@ -1,6 +1,10 @@
 package main
 
 import (
+	"errors"
+	"strings"
+	"time"
+
 	pgQuery "github.com/pganalyze/pg_query_go/v5"
 )
 
@@ -40,17 +44,81 @@ func (parser *ParserTable) IsTableFromInformationSchema(qSchemaTable QuerySchema
 // public.table -> FROM iceberg_scan('path', skip_schema_inference = true) table
 // schema.table -> FROM iceberg_scan('path', skip_schema_inference = true) schema_table
 func (parser *ParserTable) MakeIcebergTableNode(tablePath string, qSchemaTable QuerySchemaTable) *pgQuery.Node {
+	return parser.makeIcebergScanNode(qSchemaTable, pgQuery.MakeAConstStrNode(tablePath, 0))
+}
+
+// bemidb_at(table, snapshot_id) -> FROM iceberg_scan('path', snapshot_id::ubigint, skip_schema_inference = true) table
+func (parser *ParserTable) MakeIcebergSnapshotTableNode(tablePath string, snapshotId int64, qSchemaTable QuerySchemaTable) *pgQuery.Node {
+	snapshotIdNode := &pgQuery.Node{
+		Node: &pgQuery.Node_TypeCast{
+			TypeCast: &pgQuery.TypeCast{
+				Arg:      &pgQuery.Node{Node: &pgQuery.Node_AConst{AConst: &pgQuery.A_Const{Val: &pgQuery.A_Const_Fval{Fval: &pgQuery.Float{Fval: Int64ToString(snapshotId)}}}}}, // Snapshot IDs are out of the int4 range
+				TypeName: &pgQuery.TypeName{Names: []*pgQuery.Node{pgQuery.MakeStrNode("ubigint")}, Location: -1},
+			},
+		},
+	}
+	return parser.makeIcebergScanNode(qSchemaTable, pgQuery.MakeAConstStrNode(tablePath, 0), snapshotIdNode)
+}
+
+// bemidb_at(orders, 123), bemidb_at(public.orders, '2024-05-01') or bemidb_at('public.orders', TIMESTAMP '2024-05-01 00:00:00')
+// Returns the table with either a snapshot ID or a timestamp, timestamps without time zone are treated as UTC
+func (parser *ParserTable) TimeTravelArguments(rangeFunction *pgQuery.RangeFunction) (qSchemaTable QuerySchemaTable, snapshotId int64, timestamp time.Time, err error) {
+	functionCall := rangeFunction.Functions[0].GetList().Items[0].GetFuncCall()
+	if len(functionCall.Args) != 2 {
+		return QuerySchemaTable{}, 0, time.Time{}, errors.New(BEMIDB_FUNCTION_AT + "() expects a table and a snapshot ID or timestamp")
+	}
+
+	tableNode := functionCall.Args[0]
+	switch {
+	case tableNode.GetColumnRef() != nil:
+		fields := tableNode.GetColumnRef().Fields
+		qSchemaTable.Table = fields[len(fields)-1].GetString_().Sval
+		if len(fields) > 1 {
+			qSchemaTable.Schema = fields[len(fields)-2].GetString_().Sval
+		}
+	case tableNode.GetAConst() != nil && tableNode.GetAConst().GetSval() != nil:
+		qSchemaTable = NewQuerySchemaTableFromString(tableNode.GetAConst().GetSval().Sval)
+	default:
+		return QuerySchemaTable{}, 0, time.Time{}, errors.New(BEMIDB_FUNCTION_AT + "() expects a table name as the first argument")
+	}
+	if rangeFunction.Alias != nil {
+		qSchemaTable.Alias = rangeFunction.Alias.Aliasname
+	}
+
+	valueNode := functionCall.Args[1]
+	if typeCast := valueNode.GetTypeCast(); typeCast != nil {
+		valueNode = typeCast.Arg
+	}
+	aConst := valueNode.GetAConst()
+	switch {
+	case aConst != nil && aConst.GetIval() != nil:
+		return qSchemaTable, int64(aConst.GetIval().Ival), time.Time{}, nil
+	case aConst != nil && aConst.GetFval() != nil: // Integers out of the int4 range
+		snapshotId, err = StringToInt64(aConst.GetFval().Fval)
+		if err != nil {
+			return QuerySchemaTable{}, 0, time.Time{}, errors.New(BEMIDB_FUNCTION_AT + "() expects an integer snapshot ID, got " + aConst.GetFval().Fval)
+		}
+		return qSchemaTable, snapshotId, time.Time{}, nil
+	case aConst != nil && aConst.GetSval() != nil:
+		timestamp, err = parseTimeTravelTimestamp(aConst.GetSval().Sval)
+		if err != nil {
+			return QuerySchemaTable{}, 0, time.Time{}, errors.New(BEMIDB_FUNCTION_AT + "() expects a timestamp like '2024-05-01 00:00:00', got " + aConst.GetSval().Sval)
+		}
+		return qSchemaTable, 0, timestamp, nil
+	}
+
+	return QuerySchemaTable{}, 0, time.Time{}, errors.New(BEMIDB_FUNCTION_AT + "() expects a snapshot ID or timestamp as the second argument")
+}
+
+func (parser *ParserTable) makeIcebergScanNode(qSchemaTable QuerySchemaTable, argumentNodes ...*pgQuery.Node) *pgQuery.Node {
 	node := pgQuery.MakeSimpleRangeFunctionNode([]*pgQuery.Node{
 		pgQuery.MakeListNode([]*pgQuery.Node{
 			pgQuery.MakeFuncCallNode(
 				[]*pgQuery.Node{
 					pgQuery.MakeStrNode("iceberg_scan"),
 				},
-				[]*pgQuery.Node{
-					pgQuery.MakeAConstStrNode(
-						tablePath,
-						0,
-					),
+				append(
+					argumentNodes,
 					pgQuery.MakeAExprNode(
 						pgQuery.A_Expr_Kind_AEXPR_OP,
 						[]*pgQuery.Node{pgQuery.MakeStrNode("=")},
@@ -58,7 +126,7 @@ func (parser *ParserTable) MakeIcebergTableNode(tablePath string, qSchemaTable Q
 						parser.utils.MakeAConstBoolNode(true),
 						0,
 					),
-				},
+				),
 				0,
 			),
 		}),
@@ -75,6 +143,14 @@ func (parser *ParserTable) MakeIcebergTableNode(tablePath string, qSchemaTable Q
 	return parser.utils.MakeSubselectFromNode(qSchemaTable, []*pgQuery.Node{selectStarNode}, node)
 }
 
+// public.table$snapshots -> FROM (SELECT ... FROM (VALUES ...) metadata_table(...)) "table$snapshots"
+func (parser *ParserTable) MakeIcebergMetadataTableNode(query string, qSchemaTable QuerySchemaTable) *pgQuery.Node {
+	queryTree, err := pgQuery.Parse(query)
+	PanicIfError(err, parser.config)
+
+	return parser.utils.MakeSubselectFromSelectStmt(qSchemaTable, queryTree.Stmts[0].Stmt.GetSelectStmt())
+}
+
 func (parser *ParserTable) TopLevelSchemaFunction(rangeFunction *pgQuery.RangeFunction) *QuerySchemaFunction {
 	if len(rangeFunction.Functions) == 0 || len(rangeFunction.Functions[0].GetList().Items) == 0 {
 		return nil
@@ -105,3 +181,16 @@ func (parser *ParserTable) SetAliasIfNotExists(rangeFunction *pgQuery.RangeFunct
 
 	rangeFunction.Alias = &pgQuery.Alias{Aliasname: alias}
 }
+
+func parseTimeTravelTimestamp(value string) (time.Time, error) {
+	var err error
+	value = strings.Replace(value, "T", " ", 1)
+	for _, layout := range []string{"2006-01-02 15:04:05Z07:00", "2006-01-02 15:04:05-07", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		var parsedTime time.Time
+		parsedTime, err = time.Parse(layout, value)
+		if err == nil {
+			return parsedTime, nil
+		}
+	}
+	return time.Time{}, err
+}
@@ -30,6 +30,13 @@ func (utils *ParserUtils) SchemaFunction(functionCall *pgQuery.FuncCall) *QueryS
 }
 
 func (utils *ParserUtils) MakeSubselectFromNode(qSchemaTable QuerySchemaTable, targetList []*pgQuery.Node, fromNode *pgQuery.Node) *pgQuery.Node {
+	return utils.MakeSubselectFromSelectStmt(qSchemaTable, &pgQuery.SelectStmt{
+		TargetList: targetList,
+		FromClause: []*pgQuery.Node{fromNode},
+	})
+}
+
+func (utils *ParserUtils) MakeSubselectFromSelectStmt(qSchemaTable QuerySchemaTable, selectStmt *pgQuery.SelectStmt) *pgQuery.Node {
 	alias := qSchemaTable.Alias
 	if alias == "" {
 		if qSchemaTable.Schema == PG_SCHEMA_PUBLIC || qSchemaTable.Schema == "" {
@@ -44,10 +51,7 @@ func (utils *ParserUtils) MakeSubselectFromNode(qSchemaTable QuerySchemaTable, t
 			RangeSubselect: &pgQuery.RangeSubselect{
 				Subquery: &pgQuery.Node{
 					Node: &pgQuery.Node_SelectStmt{
-						SelectStmt: &pgQuery.SelectStmt{
-							TargetList: targetList,
-							FromClause: []*pgQuery.Node{fromNode},
-						},
+						SelectStmt: selectStmt,
 					},
 				},
 				Alias: &pgQuery.Alias{
@@ -13,6 +13,17 @@ const (
 	PG_TABLE_TABLES              = "tables"
 
 	PG_VAR_SEARCH_PATH = "search_path"
+
+	PG_RELKIND_TABLE             = "r"
+	PG_RELKIND_VIEW              = "v"
+	PG_RELKIND_MATERIALIZED_VIEW = "m"
+	PG_RELKIND_PARTITIONED_TABLE = "p"
+
+	BEMIDB_SCHEMA            = "bemidb"
+	BEMIDB_TABLE_SYNC_STATUS = "sync_status"
+	BEMIDB_TABLE_SYNC_RUNS   = "sync_runs"
+
+	BEMIDB_FUNCTION_AT = "bemidb_at"
 )
 
 var PG_SYSTEM_TABLES = NewSet([]string{
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/csv"
+	"errors"
 	"math"
 	"strconv"
 	"strings"
@@ -37,6 +38,8 @@ type PgSchemaColumn struct {
 	DatetimePrecision      string
 	Namespace              string
 	PartOfPrimaryKey       bool
+	FieldId                int // Iceberg field ID, see AssignIcebergFieldIds
+	ElementFieldId         int // Iceberg field ID of array elements
 	config                 *Config
 }
 
@@ -45,6 +48,15 @@ func (pgSchemaColumn PgSchemaColumn) NormalizedColumnName() string {
 	return strings.ReplaceAll(pgSchemaColumn.ColumnName, ",", "_")
 }
 
+// Postgres attnum of the column, or 0 if it's unknown
+func (pgSchemaColumn PgSchemaColumn) Attnum() int {
+	attnum, err := StringToInt(pgSchemaColumn.OrdinalPosition)
+	if err != nil {
+		return 0
+	}
+	return attnum
+}
+
 func NewPgSchemaColumn(config *Config) *PgSchemaColumn {
 	return &PgSchemaColumn{
 		config: config,
@@ -62,6 +74,7 @@ type ParquetSchemaField struct {
 	Precision           string
 	NestedType          string
 	NestedConvertedType string
+	NestedFieldId       string
 }
 
 type IcebergSchemaField struct {
@@ -102,6 +115,7 @@ func (pgSchemaColumn PgSchemaColumn) ToParquetSchemaFieldMap() map[string]interf
 		nestedTagKeyVals := []string{
 			"name=element",
 			"type=" + field.NestedType,
+			"fieldid=" + field.NestedFieldId,
 		}
 
 		if field.NestedConvertedType != "" {
@@ -119,12 +133,7 @@ func (pgSchemaColumn PgSchemaColumn) ToParquetSchemaFieldMap() map[string]interf
 func (pgSchemaColumn PgSchemaColumn) ToIcebergSchemaFieldMap() IcebergSchemaField {
 	icebergSchemaField := IcebergSchemaField{}
 
-	id, err := StringToInt(pgSchemaColumn.OrdinalPosition)
-	if err != nil {
-		panic(err)
-	}
-
-	icebergSchemaField.Id = id
+	icebergSchemaField.Id = pgSchemaColumn.FieldId
 	icebergSchemaField.Name = pgSchemaColumn.NormalizedColumnName()
 
 	if pgSchemaColumn.IsNullable == PG_TRUE {
@@ -138,7 +147,7 @@ func (pgSchemaColumn PgSchemaColumn) ToIcebergSchemaFieldMap() IcebergSchemaFiel
 		icebergSchemaField.Type = map[string]interface{}{
 			"type":             "list",
 			"element":          primitiveType,
-			"element-id":       pgSchemaColumn.OrdinalPosition,
+			"element-id":       pgSchemaColumn.ElementFieldId,
 			"element-required": false,
 		}
 	} else {
@@ -183,7 +192,7 @@ func (pgSchemaColumn *PgSchemaColumn) toParquetSchemaField() ParquetSchemaField
 
 	parquetSchemaField := ParquetSchemaField{
 		Name:          pgSchemaColumn.NormalizedColumnName(),
-		FieldId:       pgSchemaColumn.OrdinalPosition,
+		FieldId:       IntToString(pgSchemaColumn.FieldId),
 		Type:          primitiveType,
 		ConvertedType: primitiveConvertedType,
 	}
@@ -216,6 +225,7 @@ func (pgSchemaColumn *PgSchemaColumn) toParquetSchemaField() ParquetSchemaField
 		if pgSchemaColumn.DataType == PG_DATA_TYPE_ARRAY {
 			parquetSchemaField.NestedType = parquetSchemaField.Type
 			parquetSchemaField.NestedConvertedType = parquetSchemaField.ConvertedType
+			parquetSchemaField.NestedFieldId = IntToString(pgSchemaColumn.ElementFieldId)
 			parquetSchemaField.Type = "LIST"
 			parquetSchemaField.ConvertedType = ""
 		}
@@ -325,7 +335,7 @@ func (pgSchemaColumn *PgSchemaColumn) parquetPrimitiveValue(value string) interf
 		}
 	}
 
-	panic("Unsupported PostgreSQL value: " + value)
+	panic(NewRecoverableError(errors.New("Unsupported PostgreSQL value: "+value), nil))
 }
 
 func (pgSchemaColumn *PgSchemaColumn) parquetPrimitiveTypes() (primitiveType string, primitiveConvertedType string) {
@@ -373,7 +383,7 @@ func (pgSchemaColumn *PgSchemaColumn) parquetPrimitiveTypes() (primitiveType str
 		}
 	}
 
-	panic("Unsupported PostgreSQL type: " + pgSchemaColumn.UdtName)
+	panic(NewRecoverableError(errors.New("Unsupported PostgreSQL type: "+pgSchemaColumn.UdtName), nil))
 }
 
 func (pgSchemaColumn *PgSchemaColumn) icebergPrimitiveType() string {
@@ -423,5 +433,5 @@ func (pgSchemaColumn *PgSchemaColumn) icebergPrimitiveType() string {
 		}
 	}
 
-	panic("Unsupported PostgreSQL type: " + pgSchemaColumn.UdtName)
+	panic(NewRecoverableError(errors.New("Unsupported PostgreSQL type: "+pgSchemaColumn.UdtName), nil))
 }
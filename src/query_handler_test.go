@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgproto3"
@@ -396,6 +397,43 @@ func TestHandleQuery(t *testing.T) {
 			"types":       {Uint32ToString(pgtype.Int4OID)},
 			"values":      {"1"},
 		},
+		// Iceberg metadata tables
+		"SELECT operation, total_records FROM public.\"test_table$snapshots\"": {
+			"description": {"operation", "total_records"},
+			"types":       {Uint32ToString(pgtype.TextOID), Uint32ToString(pgtype.Int8OID)},
+			"values":      {"append", "2"},
+		},
+		"SELECT COUNT(*) AS count FROM \"test_table$history\" h JOIN \"test_table$snapshots\" s ON s.snapshot_id = h.snapshot_id": {
+			"description": {"count"},
+			"types":       {Uint32ToString(pgtype.Int8OID)},
+			"values":      {"1"},
+		},
+		"SELECT content, file_format, record_count FROM public.\"test_table$files\"": {
+			"description": {"content", "file_format", "record_count"},
+			"types":       {Uint32ToString(pgtype.Int4OID), Uint32ToString(pgtype.TextOID), Uint32ToString(pgtype.Int8OID)},
+			"values":      {"0", "PARQUET", "2"},
+		},
+		"SELECT record_count, file_count FROM public.\"test_table$partitions\"": {
+			"description": {"record_count", "file_count"},
+			"types":       {Uint32ToString(pgtype.Int8OID), Uint32ToString(pgtype.Int8OID)},
+			"values":      {"2", "1"},
+		},
+		"SELECT COUNT(*) AS count FROM test_schema.\"simple_table$manifests\"": {
+			"description": {"count"},
+			"types":       {Uint32ToString(pgtype.Int8OID)},
+			"values":      {"1"},
+		},
+		// Time travel
+		"SELECT COUNT(*) AS count FROM bemidb_at(test_table, '2100-01-01')": {
+			"description": {"count"},
+			"types":       {Uint32ToString(pgtype.Int8OID)},
+			"values":      {"2"},
+		},
+		"SELECT x.id FROM bemidb_at('public.test_table', TIMESTAMP '2100-01-01 00:00:00') x WHERE x.id = 1": {
+			"description": {"id"},
+			"types":       {Uint32ToString(pgtype.Int4OID)},
+			"values":      {"1"},
+		},
 		// Column types
 		"SELECT bit_column FROM public.test_table WHERE bit_column IS NOT NULL": {
 			"description": {"bit_column"},
@@ -1166,6 +1204,50 @@ func TestHandleQuery(t *testing.T) {
 		}
 	})
 
+	t.Run("Returns an error if a time-travel snapshot does not exist", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		_, err := queryHandler.HandleSimpleQuery("SELECT * FROM bemidb_at(test_table, 1)")
+
+		expectedErrorMessage := "snapshot 1 of \"public\".\"test_table\" doesn't exist or has expired"
+		if err == nil || !strings.HasSuffix(err.Error(), expectedErrorMessage) {
+			t.Errorf("Expected the error to end with '"+expectedErrorMessage+"', got %v", err)
+		}
+	})
+
+	t.Run("Returns the rows of a snapshot replaced by a full refresh", func(t *testing.T) {
+		config := loadTestConfig()
+		icebergWriter := NewIcebergWriter(config)
+		schemaTable := IcebergSchemaTable{Schema: "test_schema", Table: "time_travel_table"}
+		t.Cleanup(func() {
+			icebergWriter.DeleteSchemaTable(schemaTable)
+		})
+		icebergWriter.DeleteSchemaTable(schemaTable)
+		icebergWriter.Write(schemaTable, TEST_SCHEMA_SIMPLE_TABLE_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows([][]string{{"1"}, {"2"}}))
+		time.Sleep(10 * time.Millisecond)
+		icebergWriter.Write(schemaTable, TEST_SCHEMA_SIMPLE_TABLE_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows([][]string{{"3"}}))
+		manifestListFiles, err := NewIcebergReader(config).ManifestListFiles(schemaTable)
+		testNoError(t, err)
+		firstSnapshotTime := time.UnixMilli(manifestListFiles[0].TimestampMs).UTC().Format("2006-01-02 15:04:05.000")
+		queryHandler := initQueryHandler()
+
+		for query, expectedValues := range map[string][]string{
+			"SELECT COUNT(*) AS count FROM test_schema.time_travel_table":                                                                      {"1"},
+			"SELECT COUNT(*) AS count FROM bemidb_at('test_schema.time_travel_table', " + Int64ToString(manifestListFiles[0].SnapshotId) + ")": {"2"},
+			"SELECT MAX(id) AS max FROM bemidb_at('test_schema.time_travel_table', '" + firstSnapshotTime + "')":                               {"2"},
+		} {
+			messages, err := queryHandler.HandleSimpleQuery(query)
+
+			testNoError(t, err)
+			testMessageTypes(t, messages, []pgproto3.Message{
+				&pgproto3.RowDescription{},
+				&pgproto3.DataRow{},
+				&pgproto3.CommandComplete{},
+			})
+			testDataRowValues(t, messages[1], expectedValues)
+		}
+	})
+
 	t.Run("Returns a result without a row description for SET queries", func(t *testing.T) {
 		queryHandler := initQueryHandler()
 
@@ -50,7 +50,9 @@ func NewQueryRemapper(config *Config, icebergReader *IcebergReader, duckdb *Duck
 	}
 }
 
-func (remapper *QueryRemapper) RemapStatements(statements []*pgQuery.RawStmt) ([]*pgQuery.RawStmt, error) {
+func (remapper *QueryRemapper) RemapStatements(statements []*pgQuery.RawStmt) (remappedStatements []*pgQuery.RawStmt, err error) {
+	defer RecoverError(&err) // Invalid bemidb_at() arguments and other query errors
+
 	// Empty query
 	if len(statements) == 0 {
 		return statements, nil
@@ -169,7 +171,7 @@ func (remapper *QueryRemapper) remapSelectStatement(selectStatement *pgQuery.Sel
 			} else if fromNode.GetRangeFunction() != nil {
 				// FROM PG_FUNCTION()
 				remapper.traceTreeTraversal("FROM function()", indentLevel)
-				remapper.remapperTable.RemapTableFunctionCall(fromNode.GetRangeFunction()) // recursion
+				selectStatement.FromClause[i] = remapper.remapperTable.RemapTableFunction(fromNode) // recursion
 			}
 		}
 	}
@@ -203,6 +205,10 @@ func (remapper *QueryRemapper) remapJoinExpressions(selectStatement *pgQuery.Sel
 		// TABLE
 		remapper.traceTreeTraversal("TABLE left", indentLevel+1)
 		leftJoinNode = remapper.remapperTable.RemapTable(leftJoinNode)
+	} else if leftJoinNode.GetRangeFunction() != nil {
+		// FUNCTION()
+		remapper.traceTreeTraversal("FUNCTION() left", indentLevel+1)
+		leftJoinNode = remapper.remapperTable.RemapTableFunction(leftJoinNode)
 	} else if leftJoinNode.GetRangeSubselect() != nil {
 		leftSelectStatement := leftJoinNode.GetRangeSubselect().Subquery.GetSelectStmt()
 		remapper.remapSelectStatement(leftSelectStatement, indentLevel+1) // parent-recursion
@@ -217,6 +223,10 @@ func (remapper *QueryRemapper) remapJoinExpressions(selectStatement *pgQuery.Sel
 		// TABLE
 		remapper.traceTreeTraversal("TABLE right", indentLevel+1)
 		rightJoinNode = remapper.remapperTable.RemapTable(rightJoinNode)
+	} else if rightJoinNode.GetRangeFunction() != nil {
+		// FUNCTION()
+		remapper.traceTreeTraversal("FUNCTION() right", indentLevel+1)
+		rightJoinNode = remapper.remapperTable.RemapTableFunction(rightJoinNode)
 	} else if rightJoinNode.GetRangeSubselect() != nil {
 		rightSelectStatement := rightJoinNode.GetRangeSubselect().Subquery.GetSelectStmt()
 		remapper.remapSelectStatement(rightSelectStatement, indentLevel+1) // parent-recursion
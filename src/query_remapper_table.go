@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"regexp"
 	"strings"
 
@@ -96,24 +97,36 @@ func CreateInformationSchemaTableQueries(config *Config) []string {
 	return result
 }
 
+func CreateBemidbTableQueries(config *Config) []string {
+	return []string{
+		"CREATE SCHEMA " + BEMIDB_SCHEMA,
+
+		// Dynamic tables
+		"CREATE TABLE " + BEMIDB_SCHEMA + "." + BEMIDB_TABLE_SYNC_STATUS + "(schema_name text, table_name text, mode text, last_attempt_at timestamp, last_success_at timestamp, rows_copied int8, bytes_written int8, duration_ms int8, error text)",
+		"CREATE TABLE " + BEMIDB_SCHEMA + "." + BEMIDB_TABLE_SYNC_RUNS + "(started_at timestamp, finished_at timestamp, duration_ms int8, tables_synced int4, tables_failed int4, rows_copied int8, bytes_written int8, error text)",
+	}
+}
+
 type QueryRemapperTable struct {
-	parserTable         *ParserTable
-	parserFunction      *ParserFunction
-	remapperFunction    *QueryRemapperFunction
-	icebergSchemaTables Set[IcebergSchemaTable]
-	icebergReader       *IcebergReader
-	duckdb              *Duckdb
-	config              *Config
+	parserTable          *ParserTable
+	parserFunction       *ParserFunction
+	remapperFunction     *QueryRemapperFunction
+	icebergSchemaTables  Set[IcebergSchemaTable]
+	icebergReader        *IcebergReader
+	icebergMetadataTable *IcebergMetadataTable
+	duckdb               *Duckdb
+	config               *Config
 }
 
 func NewQueryRemapperTable(config *Config, icebergReader *IcebergReader, duckdb *Duckdb) *QueryRemapperTable {
 	remapper := &QueryRemapperTable{
-		parserTable:      NewParserTable(config),
-		parserFunction:   NewParserFunction(config),
-		remapperFunction: NewQueryRemapperFunction(config),
-		icebergReader:    icebergReader,
-		duckdb:           duckdb,
-		config:           config,
+		parserTable:          NewParserTable(config),
+		parserFunction:       NewParserFunction(config),
+		remapperFunction:     NewQueryRemapperFunction(config),
+		icebergReader:        icebergReader,
+		icebergMetadataTable: NewIcebergMetadataTable(config, icebergReader),
+		duckdb:               duckdb,
+		config:               config,
 	}
 	remapper.reloadIceberSchemaTables()
 	duckdb.ExecInitFile()
@@ -167,20 +180,60 @@ func (remapper *QueryRemapperTable) RemapTable(node *pgQuery.Node) *pgQuery.Node
 		return node
 	}
 
+	// bemidb.* tables
+	if qSchemaTable.Schema == BEMIDB_SCHEMA {
+		switch qSchemaTable.Table {
+
+		// bemidb.sync_status -> return the latest sync outcome per table
+		case BEMIDB_TABLE_SYNC_STATUS:
+			remapper.upsertSyncStatus()
+			return node
+
+		// bemidb.sync_runs -> return the history of sync runs
+		case BEMIDB_TABLE_SYNC_RUNS:
+			remapper.upsertSyncRuns()
+			return node
+		}
+	}
+
 	// public.table -> FROM iceberg_scan('path', skip_schema_inference = true) table
 	// schema.table -> FROM iceberg_scan('path', skip_schema_inference = true) schema_table
 	schemaTable := qSchemaTable.ToIcebergSchemaTable()
 	if !remapper.icebergSchemaTables.Contains(schemaTable) { // Reload Iceberg tables if not found
 		remapper.reloadIceberSchemaTables()
 		if !remapper.icebergSchemaTables.Contains(schemaTable) {
+			// public.table$snapshots -> FROM (SELECT ... FROM (VALUES ...) metadata_table(...)) "table$snapshots"
+			if icebergTable, metadataTableName, found := ParseIcebergMetadataTableName(schemaTable.Table); found {
+				metadataSchemaTable := IcebergSchemaTable{Schema: schemaTable.Schema, Table: icebergTable}
+				if remapper.icebergSchemaTables.Contains(metadataSchemaTable) {
+					query, err := remapper.icebergMetadataTable.Query(metadataSchemaTable, metadataTableName)
+					PanicIfError(err, remapper.config)
+					return parser.MakeIcebergMetadataTableNode(query, qSchemaTable)
+				}
+			}
+
 			return node // Let it return "Catalog Error: Table with name _ does not exist!"
 		}
 	}
-	icebergPath := remapper.icebergReader.MetadataFilePath(schemaTable) // iceberg/schema/table/metadata/v1.metadata.json
+	icebergPath, err := remapper.icebergReader.MetadataFilePath(schemaTable) // iceberg/schema/table/metadata/v{N}.metadata.json
+	PanicIfError(err, remapper.config)
 	return parser.MakeIcebergTableNode(icebergPath, qSchemaTable)
 }
 
 // FROM FUNCTION()
+func (remapper *QueryRemapperTable) RemapTableFunction(node *pgQuery.Node) *pgQuery.Node {
+	rangeFunction := node.GetRangeFunction()
+	schemaFunction := remapper.parserTable.TopLevelSchemaFunction(rangeFunction)
+
+	// bemidb_at(table, snapshot_id | timestamp) -> FROM iceberg_scan('path', snapshot_id, skip_schema_inference = true) table
+	if schemaFunction != nil && schemaFunction.Function == BEMIDB_FUNCTION_AT && (schemaFunction.Schema == "" || schemaFunction.Schema == BEMIDB_SCHEMA) {
+		return remapper.remapTimeTravelTable(rangeFunction)
+	}
+
+	remapper.RemapTableFunctionCall(rangeFunction)
+	return node
+}
+
 func (remapper *QueryRemapperTable) RemapTableFunctionCall(rangeFunction *pgQuery.RangeFunction) {
 	schemaFunction := remapper.parserTable.TopLevelSchemaFunction(rangeFunction)
 	remapper.parserTable.SetAliasIfNotExists(rangeFunction, schemaFunction.Function)
@@ -191,6 +244,25 @@ func (remapper *QueryRemapperTable) RemapTableFunctionCall(rangeFunction *pgQuer
 	}
 }
 
+func (remapper *QueryRemapperTable) remapTimeTravelTable(rangeFunction *pgQuery.RangeFunction) *pgQuery.Node {
+	qSchemaTable, snapshotId, timestamp, err := remapper.parserTable.TimeTravelArguments(rangeFunction)
+	PanicIfError(err, nil)
+
+	schemaTable := qSchemaTable.ToIcebergSchemaTable()
+	if !remapper.icebergSchemaTables.Contains(schemaTable) {
+		remapper.reloadIceberSchemaTables()
+		if !remapper.icebergSchemaTables.Contains(schemaTable) {
+			PanicIfError(errors.New("table "+schemaTable.String()+" does not exist"), nil)
+		}
+	}
+
+	snapshot, err := remapper.icebergReader.Snapshot(schemaTable, snapshotId, timestamp)
+	PanicIfError(err, nil)
+	icebergPath, err := remapper.icebergReader.MetadataFilePath(schemaTable)
+	PanicIfError(err, remapper.config)
+	return remapper.parserTable.MakeIcebergSnapshotTableNode(icebergPath, snapshot.SnapshotId, qSchemaTable)
+}
+
 func (remapper *QueryRemapperTable) reloadIceberSchemaTables() {
 	newIcebergSchemaTables, err := remapper.icebergReader.SchemaTables()
 	PanicIfError(err, remapper.config)
@@ -235,6 +307,53 @@ func (remapper *QueryRemapperTable) upsertPgStatUserTables(icebergSchemaTables S
 	PanicIfError(err, remapper.config)
 }
 
+func (remapper *QueryRemapperTable) upsertSyncStatus() {
+	syncStatuses, err := remapper.icebergReader.SyncStatuses()
+	PanicIfError(err, remapper.config)
+
+	queries := []string{"DELETE FROM " + BEMIDB_SCHEMA + "." + BEMIDB_TABLE_SYNC_STATUS}
+	for _, syncStatus := range syncStatuses {
+		for _, syncTableStatus := range syncStatus.Tables {
+			queries = append(queries, "INSERT INTO "+BEMIDB_SCHEMA+"."+BEMIDB_TABLE_SYNC_STATUS+" VALUES ("+
+				sqlString(syncTableStatus.Schema)+", "+
+				sqlString(syncTableStatus.Table)+", "+
+				sqlString(syncTableStatus.Mode)+", "+
+				sqlTimestamp(syncTableStatus.LastAttemptAt)+", "+
+				sqlTimestamp(syncTableStatus.LastSuccessAt)+", "+
+				Int64ToString(syncTableStatus.RowCount)+", "+
+				Int64ToString(syncTableStatus.BytesWritten)+", "+
+				Int64ToString(syncTableStatus.DurationMs)+", "+
+				sqlNullableString(syncTableStatus.Error)+")")
+		}
+	}
+
+	err = remapper.duckdb.ExecTransactionContext(context.Background(), queries)
+	PanicIfError(err, remapper.config)
+}
+
+func (remapper *QueryRemapperTable) upsertSyncRuns() {
+	syncStatuses, err := remapper.icebergReader.SyncStatuses()
+	PanicIfError(err, remapper.config)
+
+	queries := []string{"DELETE FROM " + BEMIDB_SCHEMA + "." + BEMIDB_TABLE_SYNC_RUNS}
+	for _, syncStatus := range syncStatuses {
+		for _, syncRun := range syncStatus.Runs {
+			queries = append(queries, "INSERT INTO "+BEMIDB_SCHEMA+"."+BEMIDB_TABLE_SYNC_RUNS+" VALUES ("+
+				sqlTimestamp(syncRun.StartedAt)+", "+
+				sqlTimestamp(syncRun.FinishedAt)+", "+
+				Int64ToString(syncRun.DurationMs)+", "+
+				IntToString(syncRun.SyncedTableCount)+", "+
+				IntToString(syncRun.FailedTableCount)+", "+
+				Int64ToString(syncRun.RowCount)+", "+
+				Int64ToString(syncRun.BytesWritten)+", "+
+				sqlNullableString(syncRun.Error)+")")
+		}
+	}
+
+	err = remapper.duckdb.ExecTransactionContext(context.Background(), queries)
+	PanicIfError(err, remapper.config)
+}
+
 // System pg_* tables
 func (remapper *QueryRemapperTable) isTableFromPgCatalog(qSchemaTable QuerySchemaTable) bool {
 	return qSchemaTable.Schema == PG_SCHEMA_PG_CATALOG ||
@@ -256,3 +375,22 @@ func extractTableNames(tables []string) Set[string] {
 
 	return names
 }
+
+func sqlString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func sqlNullableString(value string) string {
+	if value == "" {
+		return "NULL"
+	}
+	return sqlString(value)
+}
+
+// Unix seconds -> timestamp, 0 -> NULL
+func sqlTimestamp(unixSeconds int64) string {
+	if unixSeconds == 0 {
+		return "NULL"
+	}
+	return "make_timestamp(" + Int64ToString(unixSeconds*1000000) + ")"
+}
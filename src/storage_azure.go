@@ -0,0 +1,405 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	AZURE_STORAGE_API_VERSION         = "2021-08-06"
+	AZURE_STORAGE_OAUTH_RESOURCE      = "https://storage.azure.com/"
+	AZURE_MANAGED_IDENTITY_TOKEN_URL  = "http://169.254.169.254/metadata/identity/oauth2/token"
+	AZURE_DEVELOPMENT_STORAGE_ACCOUNT = "devstoreaccount1"
+	// Well-known key of the Azurite storage emulator, see https://learn.microsoft.com/en-us/azure/storage/common/storage-use-azurite#well-known-storage-account-and-key
+	AZURE_DEVELOPMENT_STORAGE_KEY = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+// Azure Blob Storage REST API client, authenticated with the account key or SAS token of a connection string, a SAS
+// token, or the managed identity of the workload through the instance metadata service
+type AzureClient struct {
+	config          *Config
+	httpClient      *ObjectStoreHttpClient
+	accountName     string
+	accountKey      []byte // optional, for Shared Key authorization
+	sasToken        string // optional, without the leading "?"
+	blobEndpoint    string // Without the trailing "/"
+	accessToken     *ObjectStoreAccessToken
+	managedIdentity string // Token URL of the instance metadata service
+}
+
+type azureListBlobsResponse struct {
+	Blobs struct {
+		Blob []struct {
+			Name       string `xml:"Name"`
+			Properties struct {
+				LastModified string `xml:"Last-Modified"`
+			} `xml:"Properties"`
+		} `xml:"Blob"`
+		BlobPrefix []struct {
+			Name string `xml:"Name"`
+		} `xml:"BlobPrefix"`
+	} `xml:"Blobs"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+type azureAccessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   string `json:"expires_in"`
+}
+
+func NewAzureStorage(config *Config) *StorageObjectStore {
+	return NewObjectStoreStorage(config, NewAzureClient(config))
+}
+
+func NewAzureClient(config *Config) *AzureClient {
+	client := &AzureClient{
+		config:          config,
+		accountName:     config.Azure.AccountName,
+		sasToken:        strings.TrimPrefix(config.Azure.SasToken, "?"),
+		managedIdentity: AZURE_MANAGED_IDENTITY_TOKEN_URL,
+	}
+
+	if config.Azure.ConnectionString != "" {
+		connectionString := ParseAzureConnectionString(config.Azure.ConnectionString)
+		client.accountName = connectionString.AccountName
+		client.blobEndpoint = connectionString.BlobEndpoint
+		client.sasToken = connectionString.SasToken
+		if connectionString.AccountKey != "" {
+			accountKey, err := base64.StdEncoding.DecodeString(connectionString.AccountKey)
+			PanicIfError(err, config)
+			client.accountKey = accountKey
+		}
+	}
+	if client.blobEndpoint == "" {
+		client.blobEndpoint = "https://" + client.accountName + ".blob.core.windows.net"
+	}
+	client.accessToken = &ObjectStoreAccessToken{fetch: client.fetchManagedIdentityToken}
+	client.httpClient = NewObjectStoreHttpClient(client.authorize)
+
+	return client
+}
+
+func (client *AzureClient) FileSystemPrefix() string {
+	return "az://" + client.config.Azure.Container + "/"
+}
+
+func (client *AzureClient) ReadObject(key string) ([]byte, error) {
+	response, err := client.httpClient.Do(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, client.blobUrl(key), nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	return io.ReadAll(response.Body)
+}
+
+func (client *AzureClient) ObjectExists(key string) (bool, error) {
+	response, err := client.httpClient.Do(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodHead, client.blobUrl(key), nil)
+	})
+	if err != nil {
+		if IsObjectStoreNotFoundError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	response.Body.Close()
+
+	return true, nil
+}
+
+func (client *AzureClient) WriteObject(key string, file *os.File) error {
+	return client.putBlob(key, file, nil)
+}
+
+// A blob that already exists fails the If-None-Match condition with 409 BlobAlreadyExists
+func (client *AzureClient) WriteObjectIfNotExists(key string, file *os.File) (written bool, err error) {
+	err = client.putBlob(key, file, map[string]string{"If-None-Match": "*"})
+	if err != nil {
+		var objectStoreErr *ObjectStoreError
+		if errors.As(err, &objectStoreErr) && (objectStoreErr.StatusCode == http.StatusConflict || objectStoreErr.StatusCode == http.StatusPreconditionFailed) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (client *AzureClient) ListObjects(prefix string, delimiter string) (objects []ObjectStoreObject, commonPrefixes []string, err error) {
+	query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+	if delimiter != "" {
+		query.Set("delimiter", delimiter)
+	}
+
+	for {
+		response, err := client.httpClient.Do(func() (*http.Request, error) {
+			return http.NewRequest(http.MethodGet, client.containerUrl()+"?"+query.Encode(), nil)
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		var listResponse azureListBlobsResponse
+		err = xml.NewDecoder(response.Body).Decode(&listResponse)
+		response.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse listed objects: %w", err)
+		}
+
+		for _, blob := range listResponse.Blobs.Blob {
+			lastModified, err := time.Parse(time.RFC1123, blob.Properties.LastModified)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to parse last modified time of %s: %w", blob.Name, err)
+			}
+			objects = append(objects, ObjectStoreObject{Key: blob.Name, LastModified: lastModified})
+		}
+		for _, blobPrefix := range listResponse.Blobs.BlobPrefix {
+			commonPrefixes = append(commonPrefixes, blobPrefix.Name)
+		}
+
+		if listResponse.NextMarker == "" {
+			return objects, commonPrefixes, nil
+		}
+		query.Set("marker", listResponse.NextMarker)
+	}
+}
+
+func (client *AzureClient) DeleteObject(key string) error {
+	response, err := client.httpClient.Do(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodDelete, client.blobUrl(key), nil)
+	})
+	if err != nil {
+		if IsObjectStoreNotFoundError(err) {
+			return nil
+		}
+		return err
+	}
+	response.Body.Close()
+
+	return nil
+}
+
+// Uploads a block blob in a single request, up to 5000 MiB
+func (client *AzureClient) putBlob(key string, file *os.File, headers map[string]string) error {
+	response, err := client.httpClient.Do(func() (*http.Request, error) {
+		body, contentLength, err := client.httpClient.FileBody(file)
+		if err != nil {
+			return nil, err
+		}
+		request, err := http.NewRequest(http.MethodPut, client.blobUrl(key), body)
+		if err != nil {
+			return nil, err
+		}
+		request.ContentLength = contentLength
+		request.Header.Set("Content-Type", "application/octet-stream")
+		request.Header.Set("x-ms-blob-type", "BlockBlob")
+		for name, value := range headers {
+			request.Header.Set(name, value)
+		}
+		return request, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	response.Body.Close()
+
+	return nil
+}
+
+func (client *AzureClient) authorize(request *http.Request) error {
+	request.Header.Set("x-ms-version", AZURE_STORAGE_API_VERSION)
+	request.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+
+	switch {
+	case client.accountKey != nil:
+		request.Header.Set("Authorization", "SharedKey "+client.accountName+":"+client.sharedKeySignature(request))
+	case client.sasToken != "":
+		if request.URL.RawQuery == "" {
+			request.URL.RawQuery = client.sasToken
+		} else {
+			request.URL.RawQuery += "&" + client.sasToken
+		}
+	default:
+		accessToken, err := client.accessToken.Value()
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return nil
+}
+
+// See https://learn.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key#blob-queue-and-file-services-shared-key-authorization
+func (client *AzureClient) sharedKeySignature(request *http.Request) string {
+	contentLength := ""
+	if request.ContentLength > 0 {
+		contentLength = IntToString(int(request.ContentLength))
+	}
+
+	msHeaderNames := []string{}
+	for name := range request.Header {
+		if strings.HasPrefix(strings.ToLower(name), "x-ms-") {
+			msHeaderNames = append(msHeaderNames, strings.ToLower(name))
+		}
+	}
+	slices.Sort(msHeaderNames)
+	canonicalizedHeaders := ""
+	for _, name := range msHeaderNames {
+		canonicalizedHeaders += name + ":" + strings.TrimSpace(request.Header.Get(name)) + "\n"
+	}
+
+	canonicalizedResource := "/" + client.accountName + request.URL.EscapedPath()
+	query := request.URL.Query()
+	queryNames := []string{}
+	for name := range query {
+		queryNames = append(queryNames, name)
+	}
+	slices.Sort(queryNames)
+	for _, name := range queryNames {
+		values := query[name]
+		slices.Sort(values)
+		canonicalizedResource += "\n" + strings.ToLower(name) + ":" + strings.Join(values, ",")
+	}
+
+	stringToSign := strings.Join([]string{
+		request.Method,
+		request.Header.Get("Content-Encoding"),
+		request.Header.Get("Content-Language"),
+		contentLength,
+		request.Header.Get("Content-MD5"),
+		request.Header.Get("Content-Type"),
+		"", // Date, x-ms-date is used instead
+		request.Header.Get("If-Modified-Since"),
+		request.Header.Get("If-Match"),
+		request.Header.Get("If-None-Match"),
+		request.Header.Get("If-Unmodified-Since"),
+		request.Header.Get("Range"),
+		canonicalizedHeaders + canonicalizedResource,
+	}, "\n")
+
+	mac := hmac.New(sha256.New, client.accountKey)
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// See https://learn.microsoft.com/en-us/entra/identity/managed-identities-azure-resources/how-to-use-vm-token#get-a-token-using-http
+func (client *AzureClient) fetchManagedIdentityToken() (string, time.Duration, error) {
+	query := url.Values{"api-version": {"2018-02-01"}, "resource": {AZURE_STORAGE_OAUTH_RESOURCE}}
+	request, err := http.NewRequest(http.MethodGet, client.managedIdentity+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", 0, err
+	}
+	request.Header.Set("Metadata", "true")
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", 0, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return "", 0, fmt.Errorf("token request failed with status %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tokenResponse azureAccessTokenResponse
+	err = json.NewDecoder(response.Body).Decode(&tokenResponse)
+	if err != nil {
+		return "", 0, err
+	}
+	expiresIn, err := StringToInt(tokenResponse.ExpiresIn)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid token expiration %s", tokenResponse.ExpiresIn)
+	}
+
+	return tokenResponse.AccessToken, time.Duration(expiresIn) * time.Second, nil
+}
+
+func (client *AzureClient) blobUrl(key string) string {
+	escapedKeyParts := []string{}
+	for _, keyPart := range strings.Split(key, "/") {
+		escapedKeyParts = append(escapedKeyParts, url.PathEscape(keyPart))
+	}
+	return client.containerUrl() + "/" + strings.Join(escapedKeyParts, "/")
+}
+
+func (client *AzureClient) containerUrl() string {
+	return client.blobEndpoint + "/" + url.PathEscape(client.config.Azure.Container)
+}
+
+type AzureConnectionString struct {
+	AccountName  string
+	AccountKey   string
+	SasToken     string
+	BlobEndpoint string // Without the trailing "/"
+}
+
+func (azureConnectionString AzureConnectionString) String() string {
+	pairs := []string{}
+	if azureConnectionString.AccountName != "" {
+		pairs = append(pairs, "AccountName="+azureConnectionString.AccountName)
+	}
+	if azureConnectionString.AccountKey != "" {
+		pairs = append(pairs, "AccountKey="+azureConnectionString.AccountKey)
+	}
+	if azureConnectionString.SasToken != "" {
+		pairs = append(pairs, "SharedAccessSignature="+azureConnectionString.SasToken)
+	}
+	if azureConnectionString.BlobEndpoint != "" {
+		pairs = append(pairs, "BlobEndpoint="+azureConnectionString.BlobEndpoint)
+	}
+	return strings.Join(pairs, ";")
+}
+
+// Parses "Key=Value;..." pairs, see https://learn.microsoft.com/en-us/azure/storage/common/storage-configure-connection-string
+func ParseAzureConnectionString(connectionString string) AzureConnectionString {
+	values := map[string]string{}
+	for _, pair := range strings.Split(connectionString, ";") {
+		key, value, found := strings.Cut(pair, "=")
+		if found {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	if values["UseDevelopmentStorage"] == "true" {
+		return AzureConnectionString{
+			AccountName:  AZURE_DEVELOPMENT_STORAGE_ACCOUNT,
+			AccountKey:   AZURE_DEVELOPMENT_STORAGE_KEY,
+			BlobEndpoint: "http://127.0.0.1:10000/" + AZURE_DEVELOPMENT_STORAGE_ACCOUNT,
+		}
+	}
+
+	azureConnectionString := AzureConnectionString{
+		AccountName:  values["AccountName"],
+		AccountKey:   values["AccountKey"],
+		SasToken:     strings.TrimPrefix(values["SharedAccessSignature"], "?"),
+		BlobEndpoint: strings.TrimSuffix(values["BlobEndpoint"], "/"),
+	}
+	if azureConnectionString.BlobEndpoint == "" && azureConnectionString.AccountName != "" {
+		protocol := values["DefaultEndpointsProtocol"]
+		if protocol == "" {
+			protocol = "https"
+		}
+		endpointSuffix := values["EndpointSuffix"]
+		if endpointSuffix == "" {
+			endpointSuffix = "core.windows.net"
+		}
+		azureConnectionString.BlobEndpoint = protocol + "://" + azureConnectionString.AccountName + ".blob." + endpointSuffix
+	}
+
+	return azureConnectionString
+}
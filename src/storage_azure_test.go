@@ -0,0 +1,439 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TEST_AZURE_CONTAINER    = "test-container"
+	TEST_AZURE_PAGE_SIZE    = 100
+	TEST_AZURE_ACCESS_TOKEN = "test-access-token"
+)
+
+func TestStorageAzure(t *testing.T) {
+	fakeAzure := newTestAzure(t)
+	config := loadTestConfig()
+	config.StorageType = STORAGE_TYPE_AZURE
+	config.StoragePath = "iceberg"
+	config.Azure = AzureConfig{
+		Container:        TEST_AZURE_CONTAINER,
+		ConnectionString: "AccountName=" + AZURE_DEVELOPMENT_STORAGE_ACCOUNT + ";AccountKey=" + AZURE_DEVELOPMENT_STORAGE_KEY + ";BlobEndpoint=" + fakeAzure.server.URL + "/" + AZURE_DEVELOPMENT_STORAGE_ACCOUNT,
+	}
+	storage := NewAzureStorage(config)
+
+	t.Run("Lists all objects across pages", func(t *testing.T) {
+		for i := range TEST_AZURE_PAGE_SIZE + 50 {
+			fakeAzure.putBlob(fmt.Sprintf("iceberg/test_schema/test_table/data/%03d.parquet", i))
+			fakeAzure.putBlob(fmt.Sprintf("iceberg/test_schema/test_table_%03d/metadata/v1.metadata.json", i))
+		}
+
+		filePaths, err := storage.ExistingFilePaths("iceberg/test_schema/test_table/data")
+
+		testNoError(t, err)
+		if len(filePaths) != TEST_AZURE_PAGE_SIZE+50 {
+			t.Errorf("Expected %d file paths, got %d", TEST_AZURE_PAGE_SIZE+50, len(filePaths))
+		}
+
+		icebergSchemaTables, err := storage.IcebergSchemaTables()
+
+		testNoError(t, err)
+		if len(icebergSchemaTables) != TEST_AZURE_PAGE_SIZE+51 {
+			t.Errorf("Expected %d tables, got %d", TEST_AZURE_PAGE_SIZE+51, len(icebergSchemaTables))
+		}
+	})
+
+	t.Run("Deletes all objects of a schema", func(t *testing.T) {
+		err := storage.DeleteSchema("test_schema")
+
+		testNoError(t, err)
+		if fakeAzure.blobCount() != 0 {
+			t.Errorf("Expected all objects to be deleted, got %d left", fakeAzure.blobCount())
+		}
+	})
+
+	t.Run("Uploads and reads objects", func(t *testing.T) {
+		err := storage.WriteSyncStatus(SyncStatus{Tables: []SyncTableStatus{{Schema: "test_schema", Table: "test_table"}}})
+		testNoError(t, err)
+
+		syncStatus, err := storage.SyncStatus()
+
+		testNoError(t, err)
+		if len(syncStatus.Tables) != 1 || syncStatus.Tables[0].Table != "test_table" {
+			t.Errorf("Expected the uploaded sync status, got %v", syncStatus)
+		}
+	})
+
+	t.Run("Returns no sync journal if it doesn't exist", func(t *testing.T) {
+		syncJournal, err := storage.SyncJournal()
+
+		testNoError(t, err)
+		if syncJournal != nil {
+			t.Errorf("Expected no sync journal, got %v", syncJournal)
+		}
+	})
+
+	t.Run("Publishes a metadata version only once", func(t *testing.T) {
+		metadataDirPath := "iceberg/test_schema/test_table/metadata"
+		metadataFile := MetadataFile{Version: 1, Path: metadataDirPath + "/" + storage.storageUtils.MetadataFileName(1)}
+		manifestListFile := ManifestListFile{SequenceNumber: 1, SnapshotId: 1, TimestampMs: time.Now().UnixMilli(), Path: metadataDirPath + "/snap-1.avro", Operation: "append"}
+
+		published, err := storage.uploadMetadataFile(metadataFile, "test-uuid", nil, TEST_STORAGE_ICEBERG_SCHEMAS, []IcebergPartitionSpec{{}}, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{manifestListFile})
+		testNoError(t, err)
+		if !published {
+			t.Errorf("Expected the first metadata version to be published")
+		}
+
+		published, err = storage.uploadMetadataFile(metadataFile, "test-uuid", nil, TEST_STORAGE_ICEBERG_SCHEMAS, []IcebergPartitionSpec{{}}, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{manifestListFile})
+		testNoError(t, err)
+		if published {
+			t.Errorf("Expected the conditional write of an existing metadata version to be rejected")
+		}
+
+		existingMetadataFile, err := storage.ExistingMetadataFile(metadataDirPath)
+		testNoError(t, err)
+		if existingMetadataFile.Version != 1 {
+			t.Errorf("Expected version 1, got %d", existingMetadataFile.Version)
+		}
+	})
+
+	t.Run("Signs requests with the account key", func(t *testing.T) {
+		client := NewAzureClient(config)
+		request, _ := http.NewRequest(http.MethodGet, client.containerUrl()+"?restype=container&comp=list&prefix=iceberg%2F", nil)
+		request.Header.Set("x-ms-version", AZURE_STORAGE_API_VERSION)
+		request.Header.Set("x-ms-date", "Wed, 01 Jan 2025 00:00:00 GMT")
+
+		signature := client.sharedKeySignature(request)
+
+		stringToSign := "GET\n\n\n\n\n\n\n\n\n\n\n\n" +
+			"x-ms-date:Wed, 01 Jan 2025 00:00:00 GMT\nx-ms-version:" + AZURE_STORAGE_API_VERSION + "\n" +
+			"/devstoreaccount1/devstoreaccount1/test-container\ncomp:list\nprefix:iceberg/\nrestype:container"
+		accountKey, _ := base64.StdEncoding.DecodeString(AZURE_DEVELOPMENT_STORAGE_KEY)
+		mac := hmac.New(sha256.New, accountKey)
+		mac.Write([]byte(stringToSign))
+		expectedSignature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		if signature != expectedSignature {
+			t.Errorf("Expected signature %s, got %s", expectedSignature, signature)
+		}
+		if !strings.HasPrefix(fakeAzure.lastAuthorization(), "SharedKey "+AZURE_DEVELOPMENT_STORAGE_ACCOUNT+":") {
+			t.Errorf("Expected a Shared Key authorization, got %s", fakeAzure.lastAuthorization())
+		}
+	})
+
+	t.Run("Authorizes requests with a SAS token", func(t *testing.T) {
+		sasConfig := *config
+		sasConfig.Azure = AzureConfig{
+			Container:        TEST_AZURE_CONTAINER,
+			ConnectionString: "BlobEndpoint=" + fakeAzure.server.URL + "/" + AZURE_DEVELOPMENT_STORAGE_ACCOUNT + ";SharedAccessSignature=?sv=2021-08-06&sig=test-signature",
+		}
+		storage := NewAzureStorage(&sasConfig)
+
+		_, err := storage.SyncStatus()
+
+		testNoError(t, err)
+		if fakeAzure.lastQuery().Get("sig") != "test-signature" || fakeAzure.lastAuthorization() != "" {
+			t.Errorf("Expected the SAS token in the query, got %s", fakeAzure.lastQuery().Encode())
+		}
+	})
+
+	t.Run("Fetches an access token from the managed identity", func(t *testing.T) {
+		client := NewAzureClient(config)
+		client.managedIdentity = fakeAzure.server.URL + "/metadata/identity/oauth2/token"
+
+		accessToken, expiresIn, err := client.fetchManagedIdentityToken()
+
+		testNoError(t, err)
+		if accessToken != TEST_AZURE_ACCESS_TOKEN || expiresIn != time.Hour {
+			t.Errorf("Expected the access token of the workload, got %s expiring in %v", accessToken, expiresIn)
+		}
+	})
+}
+
+// Runs against a real Azurite, e.g. AZURITE_ENDPOINT=http://127.0.0.1:10000/devstoreaccount1 with "azurite-blob" running
+func TestStorageAzureAzurite(t *testing.T) {
+	azuriteEndpoint := os.Getenv("AZURITE_ENDPOINT")
+	if azuriteEndpoint == "" {
+		t.Skip("Set AZURITE_ENDPOINT to run the Azurite integration test")
+	}
+
+	config := loadTestConfig()
+	config.StorageType = STORAGE_TYPE_AZURE
+	config.StoragePath = "iceberg-test-" + uuid.New().String()
+	config.Azure = AzureConfig{
+		Container:        testEnvOrDefault("AZURITE_CONTAINER", "bemidb-test"),
+		ConnectionString: "AccountName=" + AZURE_DEVELOPMENT_STORAGE_ACCOUNT + ";AccountKey=" + AZURE_DEVELOPMENT_STORAGE_KEY + ";BlobEndpoint=" + azuriteEndpoint,
+	}
+	client := NewAzureClient(config)
+	storage := NewObjectStoreStorage(config, client)
+
+	response, err := client.httpClient.Do(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodPut, client.containerUrl()+"?restype=container", nil)
+	})
+	var objectStoreErr *ObjectStoreError
+	if err != nil && !(errors.As(err, &objectStoreErr) && objectStoreErr.StatusCode == http.StatusConflict) {
+		t.Fatalf("Failed to create the container: %v", err)
+	}
+	if err == nil {
+		response.Body.Close()
+	}
+	t.Cleanup(func() {
+		testDeleteObjectStorePrefix(t, client, config.StoragePath+"/")
+	})
+
+	t.Run("Uploads and reads objects", func(t *testing.T) {
+		err := storage.WriteSyncStatus(SyncStatus{Tables: []SyncTableStatus{{Schema: "test_schema", Table: "test_table"}}})
+		testNoError(t, err)
+
+		syncStatus, err := storage.SyncStatus()
+
+		testNoError(t, err)
+		if len(syncStatus.Tables) != 1 || syncStatus.Tables[0].Table != "test_table" {
+			t.Errorf("Expected the uploaded sync status, got %v", syncStatus)
+		}
+	})
+
+	t.Run("Publishes a metadata version only once", func(t *testing.T) {
+		metadataDirPath := config.StoragePath + "/test_schema/test_table/metadata"
+		metadataFile := MetadataFile{Version: 1, Path: metadataDirPath + "/" + storage.storageUtils.MetadataFileName(1)}
+		manifestListFile := ManifestListFile{SequenceNumber: 1, SnapshotId: 1, TimestampMs: time.Now().UnixMilli(), Path: metadataDirPath + "/snap-1.avro", Operation: "append"}
+
+		published, err := storage.uploadMetadataFile(metadataFile, uuid.New().String(), nil, TEST_STORAGE_ICEBERG_SCHEMAS, []IcebergPartitionSpec{{}}, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{manifestListFile})
+		testNoError(t, err)
+		if !published {
+			t.Errorf("Expected the first metadata version to be published")
+		}
+
+		published, err = storage.uploadMetadataFile(metadataFile, uuid.New().String(), nil, TEST_STORAGE_ICEBERG_SCHEMAS, []IcebergPartitionSpec{{}}, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{manifestListFile})
+		testNoError(t, err)
+		if published {
+			t.Errorf("Expected the conditional write of an existing metadata version to be rejected")
+		}
+	})
+
+	t.Run("Lists and deletes a schema with more objects than a listing page", func(t *testing.T) {
+		testWriteEmptyObjects(t, client, config.StoragePath+"/test_schema/test_table/data", TEST_OBJECT_STORE_LISTING_PAGE_SIZE+50)
+
+		filePaths, err := storage.ExistingFilePaths(config.StoragePath + "/test_schema/test_table/data")
+		testNoError(t, err)
+		if len(filePaths) != TEST_OBJECT_STORE_LISTING_PAGE_SIZE+50 {
+			t.Errorf("Expected %d file paths, got %d", TEST_OBJECT_STORE_LISTING_PAGE_SIZE+50, len(filePaths))
+		}
+
+		err = storage.DeleteSchema("test_schema")
+
+		testNoError(t, err)
+		filePaths, err = storage.ExistingFilePaths(config.StoragePath + "/test_schema")
+		testNoError(t, err)
+		if len(filePaths) != 0 {
+			t.Errorf("Expected all objects to be deleted, got %d left", len(filePaths))
+		}
+	})
+}
+
+func TestParseAzureConnectionString(t *testing.T) {
+	t.Run("Parses the storage emulator connection string", func(t *testing.T) {
+		connectionString := ParseAzureConnectionString("UseDevelopmentStorage=true")
+
+		if connectionString.AccountName != AZURE_DEVELOPMENT_STORAGE_ACCOUNT || connectionString.AccountKey != AZURE_DEVELOPMENT_STORAGE_KEY || connectionString.BlobEndpoint != "http://127.0.0.1:10000/devstoreaccount1" {
+			t.Errorf("Unexpected connection string %v", connectionString)
+		}
+	})
+
+	t.Run("Builds the blob endpoint from the account name", func(t *testing.T) {
+		connectionString := ParseAzureConnectionString("DefaultEndpointsProtocol=https;AccountName=bemidb;AccountKey=a2V5;EndpointSuffix=core.windows.net")
+
+		if connectionString.AccountName != "bemidb" || connectionString.AccountKey != "a2V5" || connectionString.BlobEndpoint != "https://bemidb.blob.core.windows.net" {
+			t.Errorf("Unexpected connection string %v", connectionString)
+		}
+	})
+
+	t.Run("Keeps an explicit blob endpoint and SAS token", func(t *testing.T) {
+		connectionString := ParseAzureConnectionString("BlobEndpoint=https://bemidb.blob.core.windows.net/;SharedAccessSignature=sv=2021-08-06&sig=abc")
+
+		if connectionString.BlobEndpoint != "https://bemidb.blob.core.windows.net" || connectionString.SasToken != "sv=2021-08-06&sig=abc" {
+			t.Errorf("Unexpected connection string %v", connectionString)
+		}
+		if connectionString.String() != "SharedAccessSignature=sv=2021-08-06&sig=abc;BlobEndpoint=https://bemidb.blob.core.windows.net" {
+			t.Errorf("Unexpected connection string %s", connectionString.String())
+		}
+	})
+}
+
+// In-memory Azure Blob Storage container served with the REST API, and a managed identity token endpoint
+type testAzure struct {
+	server         *httptest.Server
+	mutex          sync.Mutex
+	blobs          map[string][]byte
+	authorizations []string
+	queries        []url.Values
+}
+
+func newTestAzure(t *testing.T) *testAzure {
+	fakeAzure := &testAzure{blobs: map[string][]byte{}}
+
+	fakeAzure.server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		fakeAzure.mutex.Lock()
+		defer fakeAzure.mutex.Unlock()
+
+		containerPath := "/" + AZURE_DEVELOPMENT_STORAGE_ACCOUNT + "/" + TEST_AZURE_CONTAINER
+		query := request.URL.Query()
+
+		if request.URL.Path == "/metadata/identity/oauth2/token" {
+			if request.Header.Get("Metadata") != "true" || query.Get("resource") != AZURE_STORAGE_OAUTH_RESOURCE {
+				testWriteAzureError(writer, http.StatusBadRequest, "InvalidRequest")
+				return
+			}
+			testWriteJson(writer, http.StatusOK, map[string]string{"access_token": TEST_AZURE_ACCESS_TOKEN, "expires_in": "3600"})
+			return
+		}
+
+		fakeAzure.authorizations = append(fakeAzure.authorizations, request.Header.Get("Authorization"))
+		fakeAzure.queries = append(fakeAzure.queries, query)
+		if request.Header.Get("x-ms-version") != AZURE_STORAGE_API_VERSION {
+			testWriteAzureError(writer, http.StatusBadRequest, "MissingRequiredHeader")
+			return
+		}
+
+		switch {
+		case request.Method == http.MethodGet && request.URL.Path == containerPath && query.Get("comp") == "list":
+			fakeAzure.listBlobs(writer, query)
+		case strings.HasPrefix(request.URL.Path, containerPath+"/"):
+			name := strings.TrimPrefix(request.URL.Path, containerPath+"/")
+			body, exists := fakeAzure.blobs[name]
+			switch request.Method {
+			case http.MethodPut:
+				if exists && request.Header.Get("If-None-Match") == "*" {
+					testWriteAzureError(writer, http.StatusConflict, "BlobAlreadyExists")
+					return
+				}
+				body, _ := io.ReadAll(request.Body)
+				fakeAzure.blobs[name] = body
+				writer.WriteHeader(http.StatusCreated)
+			case http.MethodGet, http.MethodHead, http.MethodDelete:
+				if !exists {
+					testWriteAzureError(writer, http.StatusNotFound, "BlobNotFound")
+					return
+				}
+				if request.Method == http.MethodDelete {
+					delete(fakeAzure.blobs, name)
+					writer.WriteHeader(http.StatusAccepted)
+					return
+				}
+				writer.WriteHeader(http.StatusOK)
+				writer.Write(body)
+			default:
+				testWriteAzureError(writer, http.StatusNotImplemented, "UnsupportedHttpVerb")
+			}
+		default:
+			testWriteAzureError(writer, http.StatusNotImplemented, "UnsupportedHttpVerb")
+		}
+	}))
+	t.Cleanup(fakeAzure.server.Close)
+
+	return fakeAzure
+}
+
+func (fakeAzure *testAzure) putBlob(name string) {
+	fakeAzure.mutex.Lock()
+	defer fakeAzure.mutex.Unlock()
+	fakeAzure.blobs[name] = []byte{}
+}
+
+func (fakeAzure *testAzure) blobCount() int {
+	fakeAzure.mutex.Lock()
+	defer fakeAzure.mutex.Unlock()
+	return len(fakeAzure.blobs)
+}
+
+func (fakeAzure *testAzure) lastAuthorization() string {
+	fakeAzure.mutex.Lock()
+	defer fakeAzure.mutex.Unlock()
+	return fakeAzure.authorizations[len(fakeAzure.authorizations)-1]
+}
+
+func (fakeAzure *testAzure) lastQuery() url.Values {
+	fakeAzure.mutex.Lock()
+	defer fakeAzure.mutex.Unlock()
+	return fakeAzure.queries[len(fakeAzure.queries)-1]
+}
+
+// Returns pages of TEST_AZURE_PAGE_SIZE blobs and prefixes, with the name to continue after as the marker
+func (fakeAzure *testAzure) listBlobs(writer http.ResponseWriter, query url.Values) {
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	marker := query.Get("marker")
+
+	names := []string{}
+	for name := range fakeAzure.blobs {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(name[len(prefix):], delimiter); i != -1 {
+				name = name[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if name > marker && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	type blob struct {
+		Name         string `xml:"Name"`
+		LastModified string `xml:"Properties>Last-Modified"`
+	}
+	type blobPrefix struct {
+		Name string `xml:"Name"`
+	}
+	result := struct {
+		XMLName    xml.Name     `xml:"EnumerationResults"`
+		Blobs      []blob       `xml:"Blobs>Blob"`
+		BlobPrefix []blobPrefix `xml:"Blobs>BlobPrefix"`
+		NextMarker string       `xml:"NextMarker"`
+	}{}
+	if len(names) > TEST_AZURE_PAGE_SIZE {
+		names = names[:TEST_AZURE_PAGE_SIZE]
+		result.NextMarker = names[len(names)-1]
+	}
+	for _, name := range names {
+		if delimiter != "" && strings.HasSuffix(name, delimiter) {
+			result.BlobPrefix = append(result.BlobPrefix, blobPrefix{Name: name})
+		} else {
+			result.Blobs = append(result.Blobs, blob{Name: name, LastModified: "Wed, 01 Jan 2025 00:00:00 GMT"})
+		}
+	}
+
+	testWriteXml(writer, http.StatusOK, result)
+}
+
+func testWriteAzureError(writer http.ResponseWriter, code int, errorCode string) {
+	testWriteXml(writer, code, struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+		Message string   `xml:"Message"`
+	}{Code: errorCode, Message: http.StatusText(code)})
+}
+
+func testWriteXml(writer http.ResponseWriter, code int, response interface{}) {
+	writer.Header().Set("Content-Type", "application/xml")
+	writer.WriteHeader(code)
+	writer.Write([]byte(xml.Header))
+	xml.NewEncoder(writer).Encode(response)
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	GCS_OAUTH_SCOPE               = "https://www.googleapis.com/auth/devstorage.read_write"
+	GCS_DEFAULT_TOKEN_URI         = "https://oauth2.googleapis.com/token"
+	GCS_METADATA_SERVER_TOKEN_URL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	GCS_SERVICE_ACCOUNT_TOKEN_TTL = time.Hour
+	GCS_IF_GENERATION_NOT_EXISTS  = "0" // ifGenerationMatch value that only matches missing objects
+)
+
+// Google Cloud Storage JSON API client, authenticated with a service account key file or, without one, with the
+// service account of the workload (e.g. GKE workload identity) through the metadata server
+type GcsClient struct {
+	config         *Config
+	httpClient     *ObjectStoreHttpClient
+	accessToken    *ObjectStoreAccessToken
+	metadataServer string // Token URL of the metadata server
+}
+
+type gcsServiceAccountKey struct {
+	Type        string `json:"type"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenUri    string `json:"token_uri"`
+}
+
+type gcsAccessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type gcsListObjectsResponse struct {
+	Items []struct {
+		Name    string    `json:"name"`
+		Updated time.Time `json:"updated"`
+	} `json:"items"`
+	Prefixes      []string `json:"prefixes"`
+	NextPageToken string   `json:"nextPageToken"`
+}
+
+func NewGcsStorage(config *Config) *StorageObjectStore {
+	return NewObjectStoreStorage(config, NewGcsClient(config))
+}
+
+func NewGcsClient(config *Config) *GcsClient {
+	client := &GcsClient{config: config, metadataServer: GCS_METADATA_SERVER_TOKEN_URL}
+
+	switch {
+	case config.Gcs.Anonymous:
+		// Requests are sent without authorization, e.g. to a local fake-gcs-server
+	case config.Gcs.CredentialsFile != "":
+		serviceAccountKey, err := readGcsServiceAccountKey(config.Gcs.CredentialsFile)
+		PanicIfError(err, config)
+		client.accessToken = &ObjectStoreAccessToken{fetch: func() (string, time.Duration, error) {
+			return client.fetchServiceAccountToken(serviceAccountKey)
+		}}
+	default:
+		client.accessToken = &ObjectStoreAccessToken{fetch: client.fetchMetadataServerToken}
+	}
+	client.httpClient = NewObjectStoreHttpClient(client.authorize)
+
+	return client
+}
+
+func (client *GcsClient) FileSystemPrefix() string {
+	return "gs://" + client.config.Gcs.Bucket + "/"
+}
+
+func (client *GcsClient) ReadObject(key string) ([]byte, error) {
+	response, err := client.httpClient.Do(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, client.objectUrl(key)+"?alt=media", nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	return io.ReadAll(response.Body)
+}
+
+func (client *GcsClient) ObjectExists(key string) (bool, error) {
+	response, err := client.httpClient.Do(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, client.objectUrl(key)+"?fields=name", nil)
+	})
+	if err != nil {
+		if IsObjectStoreNotFoundError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	response.Body.Close()
+
+	return true, nil
+}
+
+func (client *GcsClient) WriteObject(key string, file *os.File) error {
+	return client.uploadObject(key, file, url.Values{})
+}
+
+func (client *GcsClient) WriteObjectIfNotExists(key string, file *os.File) (written bool, err error) {
+	err = client.uploadObject(key, file, url.Values{"ifGenerationMatch": {GCS_IF_GENERATION_NOT_EXISTS}})
+	if err != nil {
+		var objectStoreErr *ObjectStoreError
+		if errors.As(err, &objectStoreErr) && objectStoreErr.StatusCode == http.StatusPreconditionFailed {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (client *GcsClient) ListObjects(prefix string, delimiter string) (objects []ObjectStoreObject, commonPrefixes []string, err error) {
+	query := url.Values{"prefix": {prefix}, "fields": {"items(name,updated),prefixes,nextPageToken"}}
+	if delimiter != "" {
+		query.Set("delimiter", delimiter)
+	}
+
+	for {
+		response, err := client.httpClient.Do(func() (*http.Request, error) {
+			return http.NewRequest(http.MethodGet, client.bucketUrl()+"/o?"+query.Encode(), nil)
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		var listResponse gcsListObjectsResponse
+		err = json.NewDecoder(response.Body).Decode(&listResponse)
+		response.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse listed objects: %w", err)
+		}
+
+		for _, item := range listResponse.Items {
+			objects = append(objects, ObjectStoreObject{Key: item.Name, LastModified: item.Updated})
+		}
+		commonPrefixes = append(commonPrefixes, listResponse.Prefixes...)
+
+		if listResponse.NextPageToken == "" {
+			return objects, commonPrefixes, nil
+		}
+		query.Set("pageToken", listResponse.NextPageToken)
+	}
+}
+
+func (client *GcsClient) DeleteObject(key string) error {
+	response, err := client.httpClient.Do(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodDelete, client.objectUrl(key), nil)
+	})
+	if err != nil {
+		if IsObjectStoreNotFoundError(err) {
+			return nil
+		}
+		return err
+	}
+	response.Body.Close()
+
+	return nil
+}
+
+// Single-request media upload, see https://cloud.google.com/storage/docs/uploading-objects
+func (client *GcsClient) uploadObject(key string, file *os.File, query url.Values) error {
+	query.Set("uploadType", "media")
+	query.Set("name", key)
+
+	response, err := client.httpClient.Do(func() (*http.Request, error) {
+		body, contentLength, err := client.httpClient.FileBody(file)
+		if err != nil {
+			return nil, err
+		}
+		request, err := http.NewRequest(http.MethodPost, client.endpoint()+"/upload/storage/v1/b/"+url.PathEscape(client.config.Gcs.Bucket)+"/o?"+query.Encode(), body)
+		if err != nil {
+			return nil, err
+		}
+		request.ContentLength = contentLength
+		request.Header.Set("Content-Type", "application/octet-stream")
+		return request, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	response.Body.Close()
+
+	return nil
+}
+
+// Returns an access token valid for at least validFor, e.g. for DuckDB without an HMAC key, or an empty string with anonymous access
+func (client *GcsClient) AccessToken(validFor time.Duration) (string, error) {
+	if client.accessToken == nil {
+		return "", nil
+	}
+	return client.accessToken.ValueValidFor(validFor)
+}
+
+func (client *GcsClient) authorize(request *http.Request) error {
+	if client.accessToken == nil {
+		return nil
+	}
+
+	accessToken, err := client.accessToken.Value()
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	return nil
+}
+
+// Exchanges a JWT signed with the service account key for an access token, see https://developers.google.com/identity/protocols/oauth2/service-account#httprest
+func (client *GcsClient) fetchServiceAccountToken(serviceAccountKey gcsServiceAccountKey) (string, time.Duration, error) {
+	privateKey, err := parseGcsPrivateKey(serviceAccountKey.PrivateKey)
+	if err != nil {
+		return "", 0, err
+	}
+
+	now := time.Now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   serviceAccountKey.ClientEmail,
+		"scope": GCS_OAUTH_SCOPE,
+		"aud":   serviceAccountKey.TokenUri,
+		"iat":   now.Unix(),
+		"exp":   now.Add(GCS_SERVICE_ACCOUNT_TOKEN_TTL).Unix(),
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", 0, err
+	}
+	assertion := signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+
+	response, err := http.PostForm(serviceAccountKey.TokenUri, url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {assertion}})
+	if err != nil {
+		return "", 0, err
+	}
+	return parseGcsAccessTokenResponse(response)
+}
+
+func (client *GcsClient) fetchMetadataServerToken() (string, time.Duration, error) {
+	request, err := http.NewRequest(http.MethodGet, client.metadataServer, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	request.Header.Set("Metadata-Flavor", "Google")
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", 0, err
+	}
+	return parseGcsAccessTokenResponse(response)
+}
+
+func (client *GcsClient) objectUrl(key string) string {
+	return client.bucketUrl() + "/o/" + url.PathEscape(key)
+}
+
+func (client *GcsClient) bucketUrl() string {
+	return client.endpoint() + "/storage/v1/b/" + url.PathEscape(client.config.Gcs.Bucket)
+}
+
+func (client *GcsClient) endpoint() string {
+	if client.config.Gcs.Endpoint != "" {
+		return client.config.Gcs.Endpoint
+	}
+	return DEFAULT_GCS_ENDPOINT
+}
+
+func readGcsServiceAccountKey(filePath string) (gcsServiceAccountKey, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return gcsServiceAccountKey{}, fmt.Errorf("failed to read GCS credentials file: %w", err)
+	}
+
+	var serviceAccountKey gcsServiceAccountKey
+	err = json.Unmarshal(content, &serviceAccountKey)
+	if err != nil {
+		return gcsServiceAccountKey{}, fmt.Errorf("failed to parse GCS credentials file: %w", err)
+	}
+	if serviceAccountKey.Type != "service_account" || serviceAccountKey.ClientEmail == "" || serviceAccountKey.PrivateKey == "" {
+		return gcsServiceAccountKey{}, errors.New("GCS credentials file must be a service account key")
+	}
+	if serviceAccountKey.TokenUri == "" {
+		serviceAccountKey.TokenUri = GCS_DEFAULT_TOKEN_URI
+	}
+
+	return serviceAccountKey, nil
+}
+
+// Service account keys are PKCS #8 PEM blocks, older ones PKCS #1
+func parseGcsPrivateKey(privateKeyPem string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privateKeyPem))
+	if block == nil {
+		return nil, errors.New("invalid GCS service account private key")
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	rsaPrivateKey, isRsa := privateKey.(*rsa.PrivateKey)
+	if !isRsa {
+		return nil, errors.New("GCS service account private key must be an RSA key")
+	}
+
+	return rsaPrivateKey, nil
+}
+
+func parseGcsAccessTokenResponse(response *http.Response) (string, time.Duration, error) {
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return "", 0, fmt.Errorf("token request failed with status %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tokenResponse gcsAccessTokenResponse
+	err := json.NewDecoder(response.Body).Decode(&tokenResponse)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return tokenResponse.AccessToken, time.Duration(tokenResponse.ExpiresIn) * time.Second, nil
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TEST_OBJECT_STORE_LISTING_PAGE_SIZE = 1000 // Default page size of GCS object and Azure blob listings
+
+	TEST_GCS_BUCKET       = "test-bucket"
+	TEST_GCS_PAGE_SIZE    = 100
+	TEST_GCS_ACCESS_TOKEN = "test-access-token"
+)
+
+func TestStorageGcs(t *testing.T) {
+	fakeGcs := newTestGcs(t)
+	config := loadTestConfig()
+	config.StorageType = STORAGE_TYPE_GCS
+	config.StoragePath = "iceberg"
+	config.Gcs = GcsConfig{Bucket: TEST_GCS_BUCKET, Endpoint: fakeGcs.server.URL, Anonymous: true}
+	storage := NewGcsStorage(config)
+
+	t.Run("Lists all objects across pages", func(t *testing.T) {
+		for i := range TEST_GCS_PAGE_SIZE + 50 {
+			fakeGcs.putObject(fmt.Sprintf("iceberg/test_schema/test_table/data/%03d.parquet", i))
+			fakeGcs.putObject(fmt.Sprintf("iceberg/test_schema/test_table_%03d/metadata/v1.metadata.json", i))
+		}
+
+		filePaths, err := storage.ExistingFilePaths("iceberg/test_schema/test_table/data")
+
+		testNoError(t, err)
+		if len(filePaths) != TEST_GCS_PAGE_SIZE+50 {
+			t.Errorf("Expected %d file paths, got %d", TEST_GCS_PAGE_SIZE+50, len(filePaths))
+		}
+
+		icebergSchemaTables, err := storage.IcebergSchemaTables()
+
+		testNoError(t, err)
+		if len(icebergSchemaTables) != TEST_GCS_PAGE_SIZE+51 {
+			t.Errorf("Expected %d tables, got %d", TEST_GCS_PAGE_SIZE+51, len(icebergSchemaTables))
+		}
+	})
+
+	t.Run("Deletes all objects of a schema", func(t *testing.T) {
+		err := storage.DeleteSchema("test_schema")
+
+		testNoError(t, err)
+		if fakeGcs.objectCount() != 0 {
+			t.Errorf("Expected all objects to be deleted, got %d left", fakeGcs.objectCount())
+		}
+	})
+
+	t.Run("Uploads and reads objects", func(t *testing.T) {
+		err := storage.WriteSyncStatus(SyncStatus{Tables: []SyncTableStatus{{Schema: "test_schema", Table: "test_table"}}})
+		testNoError(t, err)
+
+		syncStatus, err := storage.SyncStatus()
+
+		testNoError(t, err)
+		if len(syncStatus.Tables) != 1 || syncStatus.Tables[0].Table != "test_table" {
+			t.Errorf("Expected the uploaded sync status, got %v", syncStatus)
+		}
+	})
+
+	t.Run("Returns no sync journal if it doesn't exist", func(t *testing.T) {
+		syncJournal, err := storage.SyncJournal()
+
+		testNoError(t, err)
+		if syncJournal != nil {
+			t.Errorf("Expected no sync journal, got %v", syncJournal)
+		}
+	})
+
+	t.Run("Publishes a metadata version only once", func(t *testing.T) {
+		metadataDirPath := "iceberg/test_schema/test_table/metadata"
+		metadataFile := MetadataFile{Version: 1, Path: metadataDirPath + "/" + storage.storageUtils.MetadataFileName(1)}
+		manifestListFile := ManifestListFile{SequenceNumber: 1, SnapshotId: 1, TimestampMs: time.Now().UnixMilli(), Path: metadataDirPath + "/snap-1.avro", Operation: "append"}
+
+		published, err := storage.uploadMetadataFile(metadataFile, "test-uuid", nil, TEST_STORAGE_ICEBERG_SCHEMAS, []IcebergPartitionSpec{{}}, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{manifestListFile})
+		testNoError(t, err)
+		if !published {
+			t.Errorf("Expected the first metadata version to be published")
+		}
+
+		published, err = storage.uploadMetadataFile(metadataFile, "test-uuid", nil, TEST_STORAGE_ICEBERG_SCHEMAS, []IcebergPartitionSpec{{}}, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{manifestListFile})
+		testNoError(t, err)
+		if published {
+			t.Errorf("Expected the conditional write of an existing metadata version to be rejected")
+		}
+
+		existingMetadataFile, err := storage.ExistingMetadataFile(metadataDirPath)
+		testNoError(t, err)
+		if existingMetadataFile.Version != 1 {
+			t.Errorf("Expected version 1, got %d", existingMetadataFile.Version)
+		}
+	})
+
+	t.Run("Retries throttled requests", func(t *testing.T) {
+		fakeGcs.throttleRequests(2)
+
+		_, err := storage.SyncStatus()
+
+		testNoError(t, err)
+	})
+
+	t.Run("Authorizes requests with a service account key", func(t *testing.T) {
+		serviceAccountConfig := *config
+		serviceAccountConfig.Gcs.Anonymous = false
+		serviceAccountConfig.Gcs.CredentialsFile = fakeGcs.createServiceAccountKeyFile(t)
+		storage := NewGcsStorage(&serviceAccountConfig)
+
+		_, err := storage.SyncStatus()
+
+		testNoError(t, err)
+		if fakeGcs.lastAuthorization() != "Bearer "+TEST_GCS_ACCESS_TOKEN {
+			t.Errorf("Expected the access token of the service account, got %s", fakeGcs.lastAuthorization())
+		}
+	})
+
+	t.Run("Sends anonymous requests without authorization", func(t *testing.T) {
+		_, err := storage.SyncStatus()
+
+		testNoError(t, err)
+		if fakeGcs.lastAuthorization() != "" {
+			t.Errorf("Expected no authorization, got %s", fakeGcs.lastAuthorization())
+		}
+	})
+
+	t.Run("Authorizes requests to a custom endpoint with the service account of the workload", func(t *testing.T) {
+		workloadConfig := *config
+		workloadConfig.Gcs.Anonymous = false
+		client := NewGcsClient(&workloadConfig)
+		client.metadataServer = fakeGcs.server.URL + "/metadata/token"
+		storage := NewObjectStoreStorage(&workloadConfig, client)
+
+		_, err := storage.SyncStatus()
+
+		testNoError(t, err)
+		if fakeGcs.lastAuthorization() != "Bearer "+TEST_GCS_ACCESS_TOKEN {
+			t.Errorf("Expected the access token of the workload, got %s", fakeGcs.lastAuthorization())
+		}
+	})
+
+	t.Run("Fetches an access token from the metadata server", func(t *testing.T) {
+		client := NewGcsClient(config)
+		client.metadataServer = fakeGcs.server.URL + "/metadata/token"
+
+		accessToken, expiresIn, err := client.fetchMetadataServerToken()
+
+		testNoError(t, err)
+		if accessToken != TEST_GCS_ACCESS_TOKEN || expiresIn != time.Hour {
+			t.Errorf("Expected the access token of the workload, got %s expiring in %v", accessToken, expiresIn)
+		}
+	})
+}
+
+// Runs against a real fake-gcs-server, e.g. FAKE_GCS_ENDPOINT=http://localhost:4443 with "fake-gcs-server -scheme http" running
+func TestStorageGcsFakeServer(t *testing.T) {
+	fakeGcsEndpoint := os.Getenv("FAKE_GCS_ENDPOINT")
+	if fakeGcsEndpoint == "" {
+		t.Skip("Set FAKE_GCS_ENDPOINT to run the fake-gcs-server integration test")
+	}
+
+	config := loadTestConfig()
+	config.StorageType = STORAGE_TYPE_GCS
+	config.StoragePath = "iceberg-test-" + uuid.New().String()
+	config.Gcs = GcsConfig{
+		Bucket:    testEnvOrDefault("FAKE_GCS_BUCKET", "bemidb-test"),
+		Endpoint:  strings.TrimSuffix(fakeGcsEndpoint, "/"),
+		Anonymous: true,
+	}
+	client := NewGcsClient(config)
+	storage := NewObjectStoreStorage(config, client)
+
+	response, err := client.httpClient.Do(func() (*http.Request, error) {
+		request, err := http.NewRequest(http.MethodPost, config.Gcs.Endpoint+"/storage/v1/b?project=bemidb-test", strings.NewReader(`{"name":"`+config.Gcs.Bucket+`"}`))
+		if err == nil {
+			request.Header.Set("Content-Type", "application/json")
+		}
+		return request, err
+	})
+	var objectStoreErr *ObjectStoreError
+	if err != nil && !(errors.As(err, &objectStoreErr) && objectStoreErr.StatusCode == http.StatusConflict) {
+		t.Fatalf("Failed to create the bucket: %v", err)
+	}
+	if err == nil {
+		response.Body.Close()
+	}
+	t.Cleanup(func() {
+		testDeleteObjectStorePrefix(t, client, config.StoragePath+"/")
+	})
+
+	t.Run("Uploads and reads objects", func(t *testing.T) {
+		err := storage.WriteSyncStatus(SyncStatus{Tables: []SyncTableStatus{{Schema: "test_schema", Table: "test_table"}}})
+		testNoError(t, err)
+
+		syncStatus, err := storage.SyncStatus()
+
+		testNoError(t, err)
+		if len(syncStatus.Tables) != 1 || syncStatus.Tables[0].Table != "test_table" {
+			t.Errorf("Expected the uploaded sync status, got %v", syncStatus)
+		}
+	})
+
+	t.Run("Publishes a metadata version only once", func(t *testing.T) {
+		metadataDirPath := config.StoragePath + "/test_schema/test_table/metadata"
+		metadataFile := MetadataFile{Version: 1, Path: metadataDirPath + "/" + storage.storageUtils.MetadataFileName(1)}
+		manifestListFile := ManifestListFile{SequenceNumber: 1, SnapshotId: 1, TimestampMs: time.Now().UnixMilli(), Path: metadataDirPath + "/snap-1.avro", Operation: "append"}
+
+		published, err := storage.uploadMetadataFile(metadataFile, uuid.New().String(), nil, TEST_STORAGE_ICEBERG_SCHEMAS, []IcebergPartitionSpec{{}}, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{manifestListFile})
+		testNoError(t, err)
+		if !published {
+			t.Errorf("Expected the first metadata version to be published")
+		}
+
+		published, err = storage.uploadMetadataFile(metadataFile, uuid.New().String(), nil, TEST_STORAGE_ICEBERG_SCHEMAS, []IcebergPartitionSpec{{}}, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{manifestListFile})
+		testNoError(t, err)
+		if published {
+			t.Errorf("Expected the conditional write of an existing metadata version to be rejected")
+		}
+	})
+
+	t.Run("Lists and deletes a schema with more objects than a listing page", func(t *testing.T) {
+		testWriteEmptyObjects(t, client, config.StoragePath+"/test_schema/test_table/data", TEST_OBJECT_STORE_LISTING_PAGE_SIZE+50)
+
+		filePaths, err := storage.ExistingFilePaths(config.StoragePath + "/test_schema/test_table/data")
+		testNoError(t, err)
+		if len(filePaths) != TEST_OBJECT_STORE_LISTING_PAGE_SIZE+50 {
+			t.Errorf("Expected %d file paths, got %d", TEST_OBJECT_STORE_LISTING_PAGE_SIZE+50, len(filePaths))
+		}
+
+		err = storage.DeleteSchema("test_schema")
+
+		testNoError(t, err)
+		filePaths, err = storage.ExistingFilePaths(config.StoragePath + "/test_schema")
+		testNoError(t, err)
+		if len(filePaths) != 0 {
+			t.Errorf("Expected all objects to be deleted, got %d left", len(filePaths))
+		}
+	})
+}
+
+// Writes objectCount empty objects into dirPath
+func testWriteEmptyObjects(t *testing.T, client ObjectStoreClient, dirPath string, objectCount int) {
+	file, err := os.CreateTemp(t.TempDir(), "empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	for i := range objectCount {
+		err := client.WriteObject(fmt.Sprintf("%s/%04d.parquet", dirPath, i), file)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testDeleteObjectStorePrefix(t *testing.T, client ObjectStoreClient, prefix string) {
+	objects, _, err := client.ListObjects(prefix, "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, object := range objects {
+		err := client.DeleteObject(object.Key)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// In-memory GCS bucket served with the JSON API, and OAuth token endpoints
+type testGcs struct {
+	server                *httptest.Server
+	mutex                 sync.Mutex
+	objects               map[string][]byte
+	authorizations        []string
+	privateKey            *rsa.PrivateKey
+	throttledRequestCount int
+}
+
+func newTestGcs(t *testing.T) *testGcs {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeGcs := &testGcs{objects: map[string][]byte{}, privateKey: privateKey}
+
+	fakeGcs.server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		fakeGcs.mutex.Lock()
+		defer fakeGcs.mutex.Unlock()
+
+		if fakeGcs.throttledRequestCount > 0 {
+			fakeGcs.throttledRequestCount--
+			testWriteGcsError(writer, http.StatusTooManyRequests)
+			return
+		}
+
+		bucketPath := "/storage/v1/b/" + TEST_GCS_BUCKET + "/o"
+		uploadPath := "/upload/storage/v1/b/" + TEST_GCS_BUCKET + "/o"
+		escapedPath := request.URL.EscapedPath()
+		query := request.URL.Query()
+
+		switch {
+		case request.URL.Path == "/token":
+			fakeGcs.serviceAccountToken(writer, request)
+		case request.URL.Path == "/metadata/token":
+			if request.Header.Get("Metadata-Flavor") != "Google" {
+				testWriteGcsError(writer, http.StatusForbidden)
+				return
+			}
+			testWriteJson(writer, http.StatusOK, map[string]interface{}{"access_token": TEST_GCS_ACCESS_TOKEN, "expires_in": 3600})
+		case request.Method == http.MethodGet && escapedPath == bucketPath:
+			fakeGcs.authorizations = append(fakeGcs.authorizations, request.Header.Get("Authorization"))
+			fakeGcs.listObjects(writer, query)
+		case request.Method == http.MethodPost && escapedPath == uploadPath:
+			fakeGcs.authorizations = append(fakeGcs.authorizations, request.Header.Get("Authorization"))
+			name := query.Get("name")
+			if _, exists := fakeGcs.objects[name]; exists && query.Get("ifGenerationMatch") == "0" {
+				testWriteGcsError(writer, http.StatusPreconditionFailed)
+				return
+			}
+			body, _ := io.ReadAll(request.Body)
+			fakeGcs.objects[name] = body
+			testWriteJson(writer, http.StatusOK, map[string]string{"name": name})
+		case strings.HasPrefix(escapedPath, bucketPath+"/"):
+			fakeGcs.authorizations = append(fakeGcs.authorizations, request.Header.Get("Authorization"))
+			name, _ := url.PathUnescape(strings.TrimPrefix(escapedPath, bucketPath+"/"))
+			body, exists := fakeGcs.objects[name]
+			if !exists {
+				testWriteGcsError(writer, http.StatusNotFound)
+				return
+			}
+			switch {
+			case request.Method == http.MethodDelete:
+				delete(fakeGcs.objects, name)
+				writer.WriteHeader(http.StatusNoContent)
+			case request.Method == http.MethodGet && query.Get("alt") == "media":
+				writer.WriteHeader(http.StatusOK)
+				writer.Write(body)
+			case request.Method == http.MethodGet:
+				testWriteJson(writer, http.StatusOK, map[string]string{"name": name})
+			default:
+				testWriteGcsError(writer, http.StatusNotImplemented)
+			}
+		default:
+			testWriteGcsError(writer, http.StatusNotImplemented)
+		}
+	}))
+	t.Cleanup(fakeGcs.server.Close)
+
+	return fakeGcs
+}
+
+func (fakeGcs *testGcs) putObject(name string) {
+	fakeGcs.mutex.Lock()
+	defer fakeGcs.mutex.Unlock()
+	fakeGcs.objects[name] = []byte{}
+}
+
+func (fakeGcs *testGcs) throttleRequests(count int) {
+	fakeGcs.mutex.Lock()
+	defer fakeGcs.mutex.Unlock()
+	fakeGcs.throttledRequestCount = count
+}
+
+func (fakeGcs *testGcs) objectCount() int {
+	fakeGcs.mutex.Lock()
+	defer fakeGcs.mutex.Unlock()
+	return len(fakeGcs.objects)
+}
+
+func (fakeGcs *testGcs) lastAuthorization() string {
+	fakeGcs.mutex.Lock()
+	defer fakeGcs.mutex.Unlock()
+	return fakeGcs.authorizations[len(fakeGcs.authorizations)-1]
+}
+
+func (fakeGcs *testGcs) createServiceAccountKeyFile(t *testing.T) string {
+	privateKeyDer, err := x509.MarshalPKCS8PrivateKey(fakeGcs.privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serviceAccountKey, err := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "bemidb@test-project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyDer})),
+		"token_uri":    fakeGcs.server.URL + "/token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filePath := filepath.Join(t.TempDir(), "service-account.json")
+	err = os.WriteFile(filePath, serviceAccountKey, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+// Returns pages of TEST_GCS_PAGE_SIZE objects and prefixes, with the name to continue after as the page token
+func (fakeGcs *testGcs) listObjects(writer http.ResponseWriter, query url.Values) {
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	pageToken := query.Get("pageToken")
+
+	names := []string{}
+	for name := range fakeGcs.objects {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(name[len(prefix):], delimiter); i != -1 {
+				name = name[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if name > pageToken && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	type item struct {
+		Name    string `json:"name"`
+		Updated string `json:"updated"`
+	}
+	result := struct {
+		Items         []item   `json:"items,omitempty"`
+		Prefixes      []string `json:"prefixes,omitempty"`
+		NextPageToken string   `json:"nextPageToken,omitempty"`
+	}{}
+	if len(names) > TEST_GCS_PAGE_SIZE {
+		names = names[:TEST_GCS_PAGE_SIZE]
+		result.NextPageToken = names[len(names)-1]
+	}
+	for _, name := range names {
+		if delimiter != "" && strings.HasSuffix(name, delimiter) {
+			result.Prefixes = append(result.Prefixes, name)
+		} else {
+			result.Items = append(result.Items, item{Name: name, Updated: "2025-01-01T00:00:00.000Z"})
+		}
+	}
+
+	testWriteJson(writer, http.StatusOK, result)
+}
+
+// Verifies the JWT signed with the service account key
+func (fakeGcs *testGcs) serviceAccountToken(writer http.ResponseWriter, request *http.Request) {
+	request.ParseForm()
+	jwtParts := strings.Split(request.PostForm.Get("assertion"), ".")
+	if request.PostForm.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || len(jwtParts) != 3 {
+		testWriteGcsError(writer, http.StatusBadRequest)
+		return
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(jwtParts[2])
+	digest := sha256.Sum256([]byte(jwtParts[0] + "." + jwtParts[1]))
+	err := rsa.VerifyPKCS1v15(&fakeGcs.privateKey.PublicKey, crypto.SHA256, digest[:], signature)
+	if err != nil {
+		testWriteGcsError(writer, http.StatusUnauthorized)
+		return
+	}
+
+	testWriteJson(writer, http.StatusOK, map[string]interface{}{"access_token": TEST_GCS_ACCESS_TOKEN, "expires_in": 3600})
+}
+
+func testWriteGcsError(writer http.ResponseWriter, code int) {
+	testWriteJson(writer, code, map[string]interface{}{"error": map[string]interface{}{"code": code, "message": http.StatusText(code)}})
+}
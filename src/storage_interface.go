@@ -2,9 +2,10 @@ package main
 
 import (
 	"fmt"
+	"time"
 )
 
-var STORAGE_TYPES = []string{STORAGE_TYPE_LOCAL, STORAGE_TYPE_S3}
+var STORAGE_TYPES = []string{STORAGE_TYPE_LOCAL, STORAGE_TYPE_S3, STORAGE_TYPE_GCS, STORAGE_TYPE_AZURE}
 
 type ParquetFileStats struct {
 	ColumnSizes     map[int]int64
@@ -16,20 +17,33 @@ type ParquetFileStats struct {
 }
 
 type ParquetFile struct {
-	Uuid        string
-	Path        string
-	Size        int64
-	RecordCount int64
-	Stats       ParquetFileStats
+	Uuid            string
+	Path            string
+	Size            int64
+	RecordCount     int64
+	Stats           ParquetFileStats
+	PartitionValues []*string // In the order of the partition spec fields, nil for unpartitioned tables
+	SortOrderId     int       // 0 if the rows aren't sorted
+	PositionDeletes bool      // Position delete file with the file_path and pos of deleted rows instead of a data file
 }
 
 type ManifestFile struct {
-	RecordsDeleted bool
-	SnapshotId     int64
-	Path           string
-	Size           int64
-	RecordCount    int64
-	DataFileSize   int64
+	RecordsDeleted          bool
+	PositionDeletes         bool // Manifest of a position delete file
+	SnapshotId              int64
+	Path                    string
+	Size                    int64
+	RecordCount             int64
+	DataFileSize            int64
+	PartitionSpecId         int
+	PartitionFieldSummaries []ManifestPartitionFieldSummary
+}
+
+// Partition value range of the data files in a manifest, per partition spec field
+type ManifestPartitionFieldSummary struct {
+	ContainsNull bool
+	LowerBound   []byte
+	UpperBound   []byte
 }
 
 type ManifestListItem struct {
@@ -40,6 +54,8 @@ type ManifestListItem struct {
 type ManifestListFile struct {
 	SequenceNumber   int
 	SnapshotId       int64
+	SchemaId         int   // Current schema when the snapshot was created
+	ParentSnapshotId int64 // 0 for the first snapshot of a table
 	TimestampMs      int64
 	Path             string
 	Operation        string
@@ -49,6 +65,24 @@ type ManifestListFile struct {
 	RemovedFilesSize int64
 	DeletedDataFiles int64
 	DeletedRecords   int64
+	TotalDataFiles   int64 // Parsed from the existing metadata, recalculated when writing it
+	TotalFilesSize   int64
+	TotalRecords     int64
+
+	AddedDeleteFiles       int64
+	AddedPositionDeletes   int64
+	RemovedDeleteFiles     int64
+	RemovedPositionDeletes int64
+	TotalDeleteFiles       int64 // Parsed from the existing metadata, recalculated when writing it
+	TotalPositionDeletes   int64
+
+	Refs []SnapshotRef // Tags and branches other than main referencing the snapshot
+}
+
+// Named reference to a snapshot in the table metadata, e.g. a restore point tagged after a sync
+type SnapshotRef struct {
+	Name string
+	Type string // "tag" or "branch"
 }
 
 type MetadataFile struct {
@@ -56,10 +90,41 @@ type MetadataFile struct {
 	Path    string
 }
 
+// Returned when the table metadata was changed by a concurrent commit since it was read
+type MetadataCommitConflictError struct {
+	MetadataDirPath string
+}
+
+func (conflictErr *MetadataCommitConflictError) Error() string {
+	return "concurrent commit to the table metadata in " + conflictErr.MetadataDirPath
+}
+
 type InternalTableMetadata struct {
-	LastSyncedAt int64   `json:"last-synced-at"`
-	XminMax      *uint32 `json:"xmin-max"`
-	XminMin      *uint32 `json:"xmin-min"`
+	LastSyncedAt      int64                       `json:"last-synced-at"`
+	XminMax           *uint32                     `json:"xmin-max"`
+	XminMin           *uint32                     `json:"xmin-min"`
+	RowFilter         string                      `json:"row-filter,omitempty"`         // Changing it forces a full refresh
+	SortOrder         string                      `json:"sort-order,omitempty"`         // Changing it forces a full refresh
+	PartitionSpec     string                      `json:"partition-spec,omitempty"`     // Changing it forces a full refresh
+	ColumnRules       string                      `json:"column-rules,omitempty"`       // Hash of the column rules. Changing it forces a full refresh
+	PartitionedSchema string                      `json:"partitioned-schema,omitempty"` // Changing it rewrites all partitions
+	SnapshotId        int64                       `json:"snapshot-id,omitempty"`        // Iceberg snapshot the partitions were committed in
+	Partitions        []InternalPartitionMetadata `json:"partitions,omitempty"`
+}
+
+// Leaf partition synced into the table of its merged partitioned table
+type InternalPartitionMetadata struct {
+	Schema           string    `json:"schema"`
+	Table            string    `json:"table"`
+	Bound            string    `json:"bound"`
+	RowCount         int64     `json:"row-count"`
+	XminMax          *uint32   `json:"xmin-max"`
+	PartitionValues  []*string `json:"partition-values"`
+	ParquetFilePaths []string  `json:"parquet-file-paths"` // Stats of the Parquet files are read from the manifests
+}
+
+func (syncJournalTable SyncJournalTable) PgSchemaTable() PgSchemaTable {
+	return PgSchemaTable{Schema: syncJournalTable.Schema, Table: syncJournalTable.Table}
 }
 
 func (internalTableMetadata InternalTableMetadata) XminMaxString() string {
@@ -77,18 +142,76 @@ func (internalTableMetadata InternalTableMetadata) XminMinString() string {
 }
 
 func (internalTableMetadata InternalTableMetadata) String() string {
-	return fmt.Sprintf("LastSyncedAt: %d, XminMax: %s, XminMin: %s", internalTableMetadata.LastSyncedAt, internalTableMetadata.XminMaxString(), internalTableMetadata.XminMinString())
+	return fmt.Sprintf("LastSyncedAt: %d, XminMax: %s, XminMin: %s, RowFilter: %s", internalTableMetadata.LastSyncedAt, internalTableMetadata.XminMaxString(), internalTableMetadata.XminMinString(), internalTableMetadata.RowFilter)
+}
+
+// Progress of a sync run, persisted to resume it after a crash
+type SyncJournal struct {
+	StartedAt       int64             `json:"started-at"`
+	CompletedTables []string          `json:"completed-tables"`
+	InProgressTable *SyncJournalTable `json:"in-progress-table"`
+}
+
+type SyncJournalTable struct {
+	Schema                    string            `json:"schema"`
+	Table                     string            `json:"table"`
+	Fingerprint               string            `json:"fingerprint,omitempty"`        // Hash of the columns and sync options, the table is restarted if it changes
+	XminMax                   *uint32           `json:"xmin-max,omitempty"`           // Before the table started syncing, so rows updated after the interruption are synced incrementally
+	XminMin                   *uint32           `json:"xmin-min,omitempty"`           // Before the table started syncing
+	PrimaryKeyRanges          []PrimaryKeyRange `json:"primary-key-ranges,omitempty"` // Empty if the table can't be resumed
+	CompletedPrimaryKeyRanges int               `json:"completed-primary-key-ranges,omitempty"`
+	ParquetFiles              []ParquetFile     `json:"parquet-files,omitempty"` // Written for the completed primary key ranges
+}
+
+// Latest sync outcome per table and the history of sync runs, exposed as bemidb.sync_status and bemidb.sync_runs
+type SyncStatus struct {
+	Tables []SyncTableStatus `json:"tables"`
+	Runs   []SyncRun         `json:"runs"`
+}
+
+type SyncTableStatus struct {
+	Schema        string `json:"schema"` // Iceberg schema with the schema prefix
+	Table         string `json:"table"`
+	Mode          string `json:"mode"`
+	LastAttemptAt int64  `json:"last-attempt-at"`
+	LastSuccessAt int64  `json:"last-success-at"` // 0 if the table has never been synced successfully
+	RowCount      int64  `json:"row-count"`
+	BytesWritten  int64  `json:"bytes-written"`
+	DurationMs    int64  `json:"duration-ms"`
+	Error         string `json:"error"`
+}
+
+type SyncRun struct {
+	StartedAt        int64  `json:"started-at"`
+	FinishedAt       int64  `json:"finished-at"`
+	DurationMs       int64  `json:"duration-ms"`
+	SyncedTableCount int    `json:"synced-table-count"`
+	FailedTableCount int    `json:"failed-table-count"`
+	RowCount         int64  `json:"row-count"`
+	BytesWritten     int64  `json:"bytes-written"`
+	Error            string `json:"error"`
 }
 
 type StorageInterface interface {
 	// Read
 	IcebergSchemas() (icebergSchemas []string, err error)
 	IcebergSchemaTables() (icebersSchemaTables Set[IcebergSchemaTable], err error)
-	IcebergMetadataFilePath(icebergSchemaTable IcebergSchemaTable) (path string)
+	IcebergMetadataDirPath(icebergSchemaTable IcebergSchemaTable) (metadataDirPath string)
+	IcebergMetadataFilePath(icebergSchemaTable IcebergSchemaTable) (path string, err error)
+	IcebergMetadata(icebergSchemaTable IcebergSchemaTable) (path string, metadataContent []byte, err error)
 	IcebergTableFields(icebergSchemaTable IcebergSchemaTable) (icebergTableFields []IcebergTableField, err error)
+	ExistingMetadataFile(metadataDirPath string) (metadataFile MetadataFile, err error) // Version 0 if the table has no metadata
 	ExistingManifestListFiles(metadataDirPath string) (manifestListFilesSortedAsc []ManifestListFile, err error)
+	ExistingSchemas(metadataDirPath string) (schemasSortedAsc []IcebergSchema, err error)
+	ExistingPartitionSpec(metadataDirPath string) (partitionSpec IcebergPartitionSpec, err error)
+	ExistingPartitionSpecs(metadataDirPath string) (partitionSpecsSortedAsc []IcebergPartitionSpec, err error)
+	ExistingSortOrder(metadataDirPath string) (sortOrder IcebergSortOrder, err error)
 	ExistingManifestListItems(manifestListFile ManifestListFile) (manifestListItemsSortedDesc []ManifestListItem, err error)
 	ExistingParquetFilePath(manifestFile ManifestFile) (parquetFilePath string, err error)
+	ExistingParquetFile(manifestFile ManifestFile) (parquetFile ParquetFile, err error)
+	ExistingFilePaths(dirPath string) (filePaths []string, err error)
+	ExistingFilePathsModifiedBefore(dirPath string, modifiedBefore time.Time) (filePaths []string, err error)
+	ExistingDeletedParquetFilePaths(positionDeletesFilePaths []string) (parquetFilePaths []string, err error) // Data files with rows deleted by position delete files
 
 	// Write
 	DeleteSchema(schema string) (err error)
@@ -96,26 +219,45 @@ type StorageInterface interface {
 	CreateDataDir(schemaTable IcebergSchemaTable) (dataDirPath string)
 	CreateMetadataDir(schemaTable IcebergSchemaTable) (metadataDirPath string)
 	CreateParquet(dataDirPath string, pgSchemaColumns []PgSchemaColumn, loadRows func() [][]string, maxWritePayloadSize int) (parquetFile ParquetFile, loadedAllRows bool, err error)
-	CreateOverwrittenParquet(dataDirPath string, existingParquetFilePath string, newParquetFilePath string, pgSchemaColumns []PgSchemaColumn, rowCountPerBatch int) (overwrittenParquetFile ParquetFile, err error)
+	CreateOverwrittenParquet(dataDirPath string, existingParquetFilePath string, newParquetFilePaths []string, pgSchemaColumns []PgSchemaColumn, rowCountPerBatch int) (overwrittenParquetFile ParquetFile, err error)
+	CreatePositionDeletesParquet(dataDirPath string, existingParquetFilePaths []string, newParquetFilePaths []string, keyPgSchemaColumns []PgSchemaColumn) (positionDeletesFile ParquetFile, err error)
+	CreateCompactedParquet(dataDirPath string, parquetFilePaths []string, positionDeletesFilePaths []string, pgSchemaColumns []PgSchemaColumn, sortOrder IcebergSortOrder) (compactedParquetFile ParquetFile, err error)
 	DeleteParquet(parquetFile ParquetFile) (err error)
-	CreateManifest(metadataDirPath string, parquetFile ParquetFile) (manifestFile ManifestFile, err error)
+	DeleteFile(filePath string) (err error)
+	CreateManifest(metadataDirPath string, partitionSpec IcebergPartitionSpec, parquetFile ParquetFile) (manifestFile ManifestFile, err error)
 	CreateDeletedRecordsManifest(metadataDirPath string, uuid string, existingManifestFile ManifestFile) (deletedRecsManifestFile ManifestFile, err error)
 	CreateManifestList(metadataDirPath string, parquetFileUuid string, manifestListItemsSortedDesc []ManifestListItem) (manifestListFile ManifestListFile, err error)
-	CreateMetadata(metadataDirPath string, pgSchemaColumns []PgSchemaColumn, manifestListFilesSortedAsc []ManifestListFile) (metadataFile MetadataFile, err error)
+	CreateMetadata(metadataDirPath string, schemasSortedAsc []IcebergSchema, partitionSpec IcebergPartitionSpec, sortOrder IcebergSortOrder, manifestListFilesSortedAsc []ManifestListFile) (metadataFile MetadataFile, err error)
 
 	// Read (internal)
 	InternalTableMetadata(pgSchemaTable PgSchemaTable) (internalTableMetadata InternalTableMetadata, err error)
+	SyncJournal() (syncJournal *SyncJournal, err error)
+	SyncStatus() (syncStatus SyncStatus, err error)
+	SyncStatuses() (syncStatuses []SyncStatus, err error) // Across all schema prefixes
 	// Write (internal)
 	WriteInternalTableMetadata(pgSchemaTable PgSchemaTable, internalTableMetadata InternalTableMetadata) (err error)
+	WriteSyncJournal(syncJournal SyncJournal) (err error)
+	DeleteSyncJournal() (err error)
+	WriteSyncStatus(syncStatus SyncStatus) (err error)
 }
 
 func NewStorage(config *Config) StorageInterface {
+	var storage StorageInterface
 	switch config.StorageType {
 	case STORAGE_TYPE_LOCAL:
-		return NewLocalStorage(config)
+		storage = NewLocalStorage(config)
 	case STORAGE_TYPE_S3:
-		return NewS3Storage(config)
+		storage = NewS3Storage(config)
+	case STORAGE_TYPE_GCS:
+		storage = NewGcsStorage(config)
+	case STORAGE_TYPE_AZURE:
+		storage = NewAzureStorage(config)
+	default:
+		return nil
 	}
 
-	return nil
+	if config.Catalog.Type == CATALOG_TYPE_REST {
+		return NewRestCatalogStorage(config, storage)
+	}
+	return storage
 }
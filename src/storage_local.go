@@ -5,6 +5,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/xitongsys/parquet-go-source/local"
@@ -21,9 +23,34 @@ func NewLocalStorage(config *Config) *StorageLocal {
 
 // Read ----------------------------------------------------------------------------------------------------------------
 
-func (storage *StorageLocal) IcebergMetadataFilePath(icebergSchemaTable IcebergSchemaTable) string {
-	tablePath := storage.tablePath(icebergSchemaTable, true)
-	return filepath.Join(tablePath, "metadata", ICEBERG_METADATA_FILE_NAME)
+func (storage *StorageLocal) IcebergMetadataDirPath(icebergSchemaTable IcebergSchemaTable) string {
+	return filepath.Join(storage.tablePath(icebergSchemaTable, true), "metadata")
+}
+
+// Resolves the current metadata version through the version hint
+func (storage *StorageLocal) IcebergMetadataFilePath(icebergSchemaTable IcebergSchemaTable) (string, error) {
+	metadataFile, err := storage.ExistingMetadataFile(storage.IcebergMetadataDirPath(icebergSchemaTable))
+	if err != nil {
+		return "", err
+	}
+	if metadataFile.Version == 0 {
+		return "", fmt.Errorf("no Iceberg metadata found for %s", icebergSchemaTable.String())
+	}
+
+	return metadataFile.Path, nil
+}
+
+func (storage *StorageLocal) IcebergMetadata(icebergSchemaTable IcebergSchemaTable) (string, []byte, error) {
+	metadataPath, err := storage.IcebergMetadataFilePath(icebergSchemaTable)
+	if err != nil {
+		return "", nil, err
+	}
+	metadataContent, err := storage.readFileContent(metadataPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return metadataPath, metadataContent, nil
 }
 
 func (storage *StorageLocal) IcebergSchemas() (icebergSchemas []string, err error) {
@@ -60,7 +87,10 @@ func (storage *StorageLocal) IcebergSchemaTables() (Set[IcebergSchemaTable], err
 }
 
 func (storage *StorageLocal) IcebergTableFields(icebergSchemaTable IcebergSchemaTable) ([]IcebergTableField, error) {
-	metadataPath := storage.IcebergMetadataFilePath(icebergSchemaTable)
+	metadataPath, err := storage.IcebergMetadataFilePath(icebergSchemaTable)
+	if err != nil {
+		return nil, err
+	}
 	metadataContent, err := storage.readFileContent(metadataPath)
 	if err != nil {
 		return nil, err
@@ -69,9 +99,38 @@ func (storage *StorageLocal) IcebergTableFields(icebergSchemaTable IcebergSchema
 	return storage.storageUtils.ParseIcebergTableFields(metadataContent)
 }
 
+// The version hint can be behind if a concurrent commit hasn't updated it yet, so newer versions are looked up too
+func (storage *StorageLocal) ExistingMetadataFile(metadataDirPath string) (MetadataFile, error) {
+	var version int64
+	versionHintContent, err := storage.readFileContent(filepath.Join(metadataDirPath, ICEBERG_VERSION_HINT_FILE_NAME))
+	if err == nil {
+		version, err = storage.storageUtils.ParseVersionHint(versionHintContent)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+	} else if !os.IsNotExist(err) {
+		return MetadataFile{}, err
+	}
+
+	for {
+		_, err := os.Stat(filepath.Join(metadataDirPath, storage.storageUtils.MetadataFileName(version+1)))
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		version++
+	}
+	if version == 0 {
+		return MetadataFile{}, nil
+	}
+
+	return MetadataFile{Version: version, Path: filepath.Join(metadataDirPath, storage.storageUtils.MetadataFileName(version))}, nil
+}
+
 func (storage *StorageLocal) ExistingManifestListFiles(metadataDirPath string) ([]ManifestListFile, error) {
-	metadataPath := filepath.Join(metadataDirPath, ICEBERG_METADATA_FILE_NAME)
-	metadataContent, err := storage.readFileContent(metadataPath)
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
 	if err != nil {
 		return nil, err
 	}
@@ -79,6 +138,42 @@ func (storage *StorageLocal) ExistingManifestListFiles(metadataDirPath string) (
 	return storage.storageUtils.ParseManifestListFiles(storage.fileSystemPrefix(), metadataContent)
 }
 
+func (storage *StorageLocal) ExistingSchemas(metadataDirPath string) ([]IcebergSchema, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageUtils.ParseIcebergSchemas(metadataContent)
+}
+
+func (storage *StorageLocal) ExistingPartitionSpec(metadataDirPath string) (IcebergPartitionSpec, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
+	if err != nil {
+		return IcebergPartitionSpec{}, err
+	}
+
+	return storage.storageUtils.ParseIcebergPartitionSpec(metadataContent)
+}
+
+func (storage *StorageLocal) ExistingPartitionSpecs(metadataDirPath string) ([]IcebergPartitionSpec, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageUtils.ParseIcebergPartitionSpecs(metadataContent)
+}
+
+func (storage *StorageLocal) ExistingSortOrder(metadataDirPath string) (IcebergSortOrder, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
+	if err != nil {
+		return IcebergSortOrder{}, err
+	}
+
+	return storage.storageUtils.ParseIcebergSortOrder(metadataContent)
+}
+
 func (storage *StorageLocal) ExistingManifestListItems(manifestListFile ManifestListFile) ([]ManifestListItem, error) {
 	manifestListContent, err := storage.readFileContent(manifestListFile.Path)
 	if err != nil {
@@ -97,6 +192,65 @@ func (storage *StorageLocal) ExistingParquetFilePath(manifestFile ManifestFile)
 	return storage.storageUtils.ParseParquetFilePath(storage.fileSystemPrefix(), manifestContent)
 }
 
+func (storage *StorageLocal) ExistingParquetFile(manifestFile ManifestFile) (ParquetFile, error) {
+	manifestContent, err := storage.readFileContent(manifestFile.Path)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+
+	return storage.storageUtils.ParseParquetFile(storage.fileSystemPrefix(), manifestContent)
+}
+
+func (storage *StorageLocal) ExistingDeletedParquetFilePaths(positionDeletesFilePaths []string) ([]string, error) {
+	return storage.storageUtils.ReadDeletedParquetFilePaths(storage.fileSystemPrefix(), positionDeletesFilePaths)
+}
+
+func (storage *StorageLocal) ExistingFilePaths(dirPath string) ([]string, error) {
+	files, err := os.ReadDir(dirPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	filePaths := []string{}
+	for _, file := range files {
+		if !file.IsDir() {
+			filePaths = append(filePaths, filepath.Join(dirPath, file.Name()))
+		}
+	}
+
+	return filePaths, nil
+}
+
+func (storage *StorageLocal) ExistingFilePathsModifiedBefore(dirPath string, modifiedBefore time.Time) ([]string, error) {
+	files, err := os.ReadDir(dirPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	filePaths := []string{}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		fileInfo, err := file.Info()
+		if err != nil {
+			return nil, err
+		}
+		if fileInfo.ModTime().Before(modifiedBefore) {
+			filePaths = append(filePaths, filepath.Join(dirPath, file.Name()))
+		}
+	}
+
+	return filePaths, nil
+}
+
 // Write ---------------------------------------------------------------------------------------------------------------
 
 func (storage *StorageLocal) DeleteSchema(schema string) error {
@@ -165,7 +319,7 @@ func (storage *StorageLocal) CreateParquet(dataDirPath string, pgSchemaColumns [
 	if err != nil {
 		return ParquetFile{}, false, fmt.Errorf("failed to open Parquet file for reading: %v", err)
 	}
-	parquetStats, err := storage.storageUtils.ReadParquetStats(fileReader)
+	parquetStats, err := storage.storageUtils.ReadParquetStats(fileReader, pgSchemaColumns)
 	if err != nil {
 		return ParquetFile{}, false, err
 	}
@@ -179,7 +333,7 @@ func (storage *StorageLocal) CreateParquet(dataDirPath string, pgSchemaColumns [
 	}, loadedAllRows, nil
 }
 
-func (storage *StorageLocal) CreateOverwrittenParquet(dataDirPath string, existingParquetFilePath string, newParquetFilePath string, pgSchemaColumns []PgSchemaColumn, rowCountPerBatch int) (overwrittenParquetFile ParquetFile, err error) {
+func (storage *StorageLocal) CreateOverwrittenParquet(dataDirPath string, existingParquetFilePath string, newParquetFilePaths []string, pgSchemaColumns []PgSchemaColumn, rowCountPerBatch int) (overwrittenParquetFile ParquetFile, err error) {
 	uuid := uuid.New().String()
 	fileName := fmt.Sprintf("00000-0-%s.parquet", uuid)
 	filePath := filepath.Join(dataDirPath, fileName)
@@ -189,7 +343,7 @@ func (storage *StorageLocal) CreateOverwrittenParquet(dataDirPath string, existi
 		return ParquetFile{}, fmt.Errorf("failed to open Parquet file for writing: %v", err)
 	}
 
-	duckdb, err := storage.storageUtils.NewDuckDBIfHasOverlappingRows(storage.fileSystemPrefix(), existingParquetFilePath, newParquetFilePath, pgSchemaColumns)
+	duckdb, err := storage.storageUtils.NewDuckDBIfHasOverlappingRows(storage.fileSystemPrefix(), existingParquetFilePath, newParquetFilePaths, pgSchemaColumns)
 	if err != nil {
 		return ParquetFile{}, err
 	}
@@ -216,7 +370,47 @@ func (storage *StorageLocal) CreateOverwrittenParquet(dataDirPath string, existi
 	if err != nil {
 		return ParquetFile{}, fmt.Errorf("failed to open Parquet file for reading: %v", err)
 	}
-	parquetStats, err := storage.storageUtils.ReadParquetStats(fileReader)
+	parquetStats, err := storage.storageUtils.ReadParquetStats(fileReader, pgSchemaColumns)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+
+	return ParquetFile{
+		Uuid:        uuid,
+		Path:        filePath,
+		Size:        fileSize,
+		RecordCount: recordCount,
+		Stats:       parquetStats,
+	}, nil
+}
+
+func (storage *StorageLocal) CreateCompactedParquet(dataDirPath string, parquetFilePaths []string, positionDeletesFilePaths []string, pgSchemaColumns []PgSchemaColumn, sortOrder IcebergSortOrder) (compactedParquetFile ParquetFile, err error) {
+	uuid := uuid.New().String()
+	fileName := fmt.Sprintf("00000-0-%s.parquet", uuid)
+	filePath := filepath.Join(dataDirPath, fileName)
+
+	fileWriter, err := local.NewLocalFileWriter(filePath)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("failed to open Parquet file for writing: %v", err)
+	}
+
+	recordCount, err := storage.storageUtils.WriteCompactedParquetFile(storage.fileSystemPrefix(), parquetFilePaths, positionDeletesFilePaths, fileWriter, pgSchemaColumns, sortOrder)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	LogDebug(storage.config, "Parquet file with", recordCount, "record(s) created at:", filePath)
+
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("failed to get Parquet file info: %v", err)
+	}
+	fileSize := fileInfo.Size()
+
+	fileReader, err := local.NewLocalFileReader(filePath)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("failed to open Parquet file for reading: %v", err)
+	}
+	parquetStats, err := storage.storageUtils.ReadParquetStats(fileReader, pgSchemaColumns)
 	if err != nil {
 		return ParquetFile{}, err
 	}
@@ -227,6 +421,48 @@ func (storage *StorageLocal) CreateOverwrittenParquet(dataDirPath string, existi
 		Size:        fileSize,
 		RecordCount: recordCount,
 		Stats:       parquetStats,
+		SortOrderId: sortOrder.OrderId,
+	}, nil
+}
+
+func (storage *StorageLocal) CreatePositionDeletesParquet(dataDirPath string, existingParquetFilePaths []string, newParquetFilePaths []string, keyPgSchemaColumns []PgSchemaColumn) (positionDeletesFile ParquetFile, err error) {
+	uuid := uuid.New().String()
+	fileName := fmt.Sprintf("00000-0-%s.parquet", uuid)
+	filePath := filepath.Join(dataDirPath, fileName)
+
+	fileWriter, err := local.NewLocalFileWriter(filePath)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("failed to open Parquet file for writing: %v", err)
+	}
+
+	recordCount, err := storage.storageUtils.WritePositionDeletesParquetFile(storage.fileSystemPrefix(), existingParquetFilePaths, newParquetFilePaths, keyPgSchemaColumns, fileWriter)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	LogDebug(storage.config, "Position delete file with", recordCount, "record(s) created at:", filePath)
+
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("failed to get Parquet file info: %v", err)
+	}
+	fileSize := fileInfo.Size()
+
+	fileReader, err := local.NewLocalFileReader(filePath)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("failed to open Parquet file for reading: %v", err)
+	}
+	parquetStats, err := storage.storageUtils.ReadParquetStats(fileReader, storage.storageUtils.PositionDeletesPgSchemaColumns())
+	if err != nil {
+		return ParquetFile{}, err
+	}
+
+	return ParquetFile{
+		Uuid:            uuid,
+		Path:            filePath,
+		Size:            fileSize,
+		RecordCount:     recordCount,
+		Stats:           parquetStats,
+		PositionDeletes: true,
 	}, nil
 }
 
@@ -235,11 +471,19 @@ func (storage *StorageLocal) DeleteParquet(parquetFile ParquetFile) error {
 	return err
 }
 
-func (storage *StorageLocal) CreateManifest(metadataDirPath string, parquetFile ParquetFile) (manifestFile ManifestFile, err error) {
+func (storage *StorageLocal) DeleteFile(filePath string) error {
+	err := os.Remove(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (storage *StorageLocal) CreateManifest(metadataDirPath string, partitionSpec IcebergPartitionSpec, parquetFile ParquetFile) (manifestFile ManifestFile, err error) {
 	fileName := fmt.Sprintf("%s-m0.avro", parquetFile.Uuid)
 	filePath := filepath.Join(metadataDirPath, fileName)
 
-	manifestFile, err = storage.storageUtils.WriteManifestFile(storage.fileSystemPrefix(), filePath, parquetFile)
+	manifestFile, err = storage.storageUtils.WriteManifestFile(storage.fileSystemPrefix(), filePath, partitionSpec, parquetFile)
 	if err != nil {
 		return ManifestFile{}, err
 	}
@@ -279,16 +523,75 @@ func (storage *StorageLocal) CreateManifestList(metadataDirPath string, parquetF
 	return manifestListFile, nil
 }
 
-func (storage *StorageLocal) CreateMetadata(metadataDirPath string, pgSchemaColumns []PgSchemaColumn, manifestListFilesSortedAsc []ManifestListFile) (metadataFile MetadataFile, err error) {
-	filePath := filepath.Join(metadataDirPath, ICEBERG_METADATA_FILE_NAME)
+// Publishes the next metadata version by hard-linking a fully written temporary file, which fails if a concurrent commit published it first
+func (storage *StorageLocal) CreateMetadata(metadataDirPath string, schemasSortedAsc []IcebergSchema, partitionSpec IcebergPartitionSpec, sortOrder IcebergSortOrder, manifestListFilesSortedAsc []ManifestListFile) (metadataFile MetadataFile, err error) {
+	for attempt := 1; ; attempt++ {
+		currentMetadataFile, err := storage.ExistingMetadataFile(metadataDirPath)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		var currentMetadataContent []byte
+		if currentMetadataFile.Version > 0 {
+			currentMetadataContent, err = storage.readFileContent(currentMetadataFile.Path)
+			if err != nil {
+				return MetadataFile{}, err
+			}
+		}
 
-	err = storage.storageUtils.WriteMetadataFile(storage.fileSystemPrefix(), filePath, pgSchemaColumns, manifestListFilesSortedAsc)
-	if err != nil {
-		return MetadataFile{}, err
-	}
-	LogDebug(storage.config, "Metadata file created at:", filePath)
+		err = storage.storageUtils.ValidateMetadataCommit(metadataDirPath, currentMetadataContent, manifestListFilesSortedAsc)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		tableUuid, err := storage.storageUtils.TableUuid(currentMetadataContent)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		metadataLog, expiredMetadataFilePaths, err := storage.storageUtils.NextMetadataLog(storage.fileSystemPrefix(), currentMetadataFile, currentMetadataContent)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		partitionSpecsSortedAsc, err := storage.storageUtils.MetadataPartitionSpecs(currentMetadataContent, partitionSpec)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+
+		tempFilePath := filepath.Join(metadataDirPath, uuid.New().String()+".metadata.json.tmp")
+		err = storage.storageUtils.WriteMetadataFile(storage.fileSystemPrefix(), tempFilePath, metadataDirPath, tableUuid, metadataLog, schemasSortedAsc, partitionSpecsSortedAsc, partitionSpec, sortOrder, manifestListFilesSortedAsc)
+		if err != nil {
+			os.Remove(tempFilePath)
+			return MetadataFile{}, err
+		}
+
+		metadataFile = MetadataFile{
+			Version: currentMetadataFile.Version + 1,
+			Path:    filepath.Join(metadataDirPath, storage.storageUtils.MetadataFileName(currentMetadataFile.Version+1)),
+		}
+		err = os.Link(tempFilePath, metadataFile.Path)
+		os.Remove(tempFilePath)
+		if os.IsExist(err) {
+			if attempt < ICEBERG_METADATA_COMMIT_ATTEMPTS {
+				continue
+			}
+			return MetadataFile{}, &MetadataCommitConflictError{MetadataDirPath: metadataDirPath}
+		}
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		LogDebug(storage.config, "Metadata file created at:", metadataFile.Path)
+
+		err = storage.writeVersionHint(metadataDirPath, metadataFile)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		for _, expiredMetadataFilePath := range expiredMetadataFilePaths {
+			err = storage.DeleteFile(expiredMetadataFilePath)
+			if err != nil {
+				LogWarn(storage.config, "Failed to delete expired metadata file", expiredMetadataFilePath+":", err)
+			}
+		}
 
-	return MetadataFile{Version: 1, Path: filePath}, nil
+		return metadataFile, nil
+	}
 }
 
 // Read (internal) -----------------------------------------------------------------------------------------------------
@@ -303,6 +606,56 @@ func (storage *StorageLocal) InternalTableMetadata(pgSchemaTable PgSchemaTable)
 	return storage.storageUtils.ParseInternalTableMetadata(internalMetadataContent)
 }
 
+func (storage *StorageLocal) SyncJournal() (*SyncJournal, error) {
+	syncJournalContent, err := storage.readFileContent(storage.syncJournalFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return storage.storageUtils.ParseSyncJournal(syncJournalContent)
+}
+
+func (storage *StorageLocal) SyncStatus() (SyncStatus, error) {
+	syncStatusContent, err := storage.readFileContent(storage.syncStatusFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return SyncStatus{}, nil
+		}
+		return SyncStatus{}, err
+	}
+
+	return storage.storageUtils.ParseSyncStatus(syncStatusContent)
+}
+
+func (storage *StorageLocal) SyncStatuses() ([]SyncStatus, error) {
+	filePaths, err := storage.ExistingFilePaths(storage.absoluteIcebergPath())
+	if err != nil {
+		return nil, err
+	}
+
+	syncStatuses := []SyncStatus{}
+	for _, filePath := range filePaths {
+		if !strings.HasSuffix(filePath, SYNC_STATUS_FILE_NAME) {
+			continue
+		}
+
+		syncStatusContent, err := storage.readFileContent(filePath)
+		if err != nil {
+			return nil, err
+		}
+		syncStatus, err := storage.storageUtils.ParseSyncStatus(syncStatusContent)
+		if err != nil {
+			return nil, err
+		}
+		syncStatuses = append(syncStatuses, syncStatus)
+	}
+
+	return syncStatuses, nil
+}
+
 // Write (internal) ----------------------------------------------------------------------------------------------------
 
 func (storage *StorageLocal) WriteInternalTableMetadata(pgSchemaTable PgSchemaTable, internalTableMetadata InternalTableMetadata) error {
@@ -317,6 +670,56 @@ func (storage *StorageLocal) WriteInternalTableMetadata(pgSchemaTable PgSchemaTa
 	return nil
 }
 
+func (storage *StorageLocal) WriteSyncJournal(syncJournal SyncJournal) error {
+	filePath := storage.syncJournalFilePath()
+	err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first and rename it to avoid leaving a half-written journal after a crash
+	tempFilePath := filePath + ".tmp"
+	err = storage.storageUtils.WriteSyncJournalFile(tempFilePath, syncJournal)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tempFilePath, filePath)
+	if err != nil {
+		return err
+	}
+	LogDebug(storage.config, "Sync journal file written at:", filePath)
+
+	return nil
+}
+
+func (storage *StorageLocal) DeleteSyncJournal() error {
+	return storage.DeleteFile(storage.syncJournalFilePath())
+}
+
+func (storage *StorageLocal) WriteSyncStatus(syncStatus SyncStatus) error {
+	filePath := storage.syncStatusFilePath()
+	err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first and rename it to avoid exposing a half-written file to readers
+	tempFilePath := filePath + ".tmp"
+	err = storage.storageUtils.WriteSyncStatusFile(tempFilePath, syncStatus)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tempFilePath, filePath)
+	if err != nil {
+		return err
+	}
+	LogDebug(storage.config, "Sync status file written at:", filePath)
+
+	return nil
+}
+
 // ---------------------------------------------------------------------------------------------------------------------
 
 func (storage *StorageLocal) readFileContent(filePath string) ([]byte, error) {
@@ -329,10 +732,48 @@ func (storage *StorageLocal) readFileContent(filePath string) ([]byte, error) {
 	return io.ReadAll(file)
 }
 
+// Replaces the version hint atomically with a rename
+func (storage *StorageLocal) writeVersionHint(metadataDirPath string, metadataFile MetadataFile) error {
+	tempFilePath := filepath.Join(metadataDirPath, uuid.New().String()+".version-hint.tmp")
+	err := storage.storageUtils.WriteVersionHintFile(tempFilePath, metadataFile)
+	if err != nil {
+		os.Remove(tempFilePath)
+		return err
+	}
+
+	err = os.Rename(tempFilePath, filepath.Join(metadataDirPath, ICEBERG_VERSION_HINT_FILE_NAME))
+	if err != nil {
+		os.Remove(tempFilePath)
+		return err
+	}
+	return nil
+}
+
+// Fails if the table has no metadata
+func (storage *StorageLocal) readCurrentMetadataContent(metadataDirPath string) ([]byte, error) {
+	metadataFile, err := storage.ExistingMetadataFile(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+	if metadataFile.Version == 0 {
+		return nil, fmt.Errorf("no Iceberg metadata found in %s", metadataDirPath)
+	}
+
+	return storage.readFileContent(metadataFile.Path)
+}
+
 func (storage *StorageLocal) internalTableMetadataFilePath(pgSchemaTable PgSchemaTable) string {
 	return filepath.Join(storage.tablePath(pgSchemaTable.ToIcebergSchemaTable()), "metadata", INTERNAL_METADATA_FILE_NAME)
 }
 
+func (storage *StorageLocal) syncJournalFilePath() string {
+	return storage.absoluteIcebergPath(storage.config.Pg.SchemaPrefix + SYNC_JOURNAL_FILE_NAME)
+}
+
+func (storage *StorageLocal) syncStatusFilePath() string {
+	return storage.absoluteIcebergPath(storage.config.Pg.SchemaPrefix + SYNC_STATUS_FILE_NAME)
+}
+
 func (storage *StorageLocal) tablePath(schemaTable IcebergSchemaTable, readWithoutSchemaPrefix ...bool) string {
 	if len(readWithoutSchemaPrefix) > 0 && readWithoutSchemaPrefix[0] {
 		return storage.absoluteIcebergPath(schemaTable.Schema, schemaTable.Table)
@@ -2,13 +2,22 @@ package main
 
 import (
 	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+
+	"github.com/linkedin/goavro"
 )
 
 var TEST_STORAGE_PG_SCHEMA_COLUMNS = []PgSchemaColumn{
-	{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", NumericPrecision: "32", OrdinalPosition: "1", Namespace: "pg_catalog"},
-	{ColumnName: "name", DataType: "character varying", UdtName: "varchar", IsNullable: "YES", CharacterMaximumLength: "255", OrdinalPosition: "2", Namespace: "pg_catalog"},
+	{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", NumericPrecision: "32", OrdinalPosition: "1", FieldId: 1, Namespace: "pg_catalog"},
+	{ColumnName: "name", DataType: "character varying", UdtName: "varchar", IsNullable: "YES", CharacterMaximumLength: "255", OrdinalPosition: "2", FieldId: 2, Namespace: "pg_catalog"},
+}
+var TEST_STORAGE_ICEBERG_SCHEMAS = []IcebergSchema{
+	{SchemaId: 0, Fields: NewIcebergSchemaFields(TEST_STORAGE_PG_SCHEMA_COLUMNS)},
 }
 var TEST_STORAGE_ROWS = [][]string{
 	{"1", "John"},
@@ -83,6 +92,56 @@ func TestCreateParquet(t *testing.T) {
 			t.Errorf("Expected 0 split offsets, got %v", len(parquetFile.Stats.SplitOffsets))
 		}
 	})
+
+	t.Run("Encodes lower and upper bounds per Iceberg type", func(t *testing.T) {
+		config := loadTestConfig()
+		storage := NewLocalStorage(config)
+		pgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "amount", DataType: "bigint", UdtName: "int8", IsNullable: "NO", NumericPrecision: "64", OrdinalPosition: "1", FieldId: 1, Namespace: "pg_catalog"},
+			{ColumnName: "price", DataType: "numeric", UdtName: "numeric", IsNullable: "NO", NumericPrecision: "10", NumericScale: "2", OrdinalPosition: "2", FieldId: 2, Namespace: "pg_catalog"},
+			{ColumnName: "description", DataType: "text", UdtName: "text", IsNullable: "NO", OrdinalPosition: "3", FieldId: 3, Namespace: "pg_catalog"},
+			{ColumnName: "created_at", DataType: "timestamp without time zone", UdtName: "timestamp", IsNullable: "NO", DatetimePrecision: "3", OrdinalPosition: "4", FieldId: 4, Namespace: "pg_catalog"},
+			{ColumnName: "external_id", DataType: "uuid", UdtName: "uuid", IsNullable: "NO", OrdinalPosition: "5", FieldId: 5, Namespace: "pg_catalog"},
+			{ColumnName: "tags", DataType: "ARRAY", UdtName: "_text", IsNullable: "YES", OrdinalPosition: "6", FieldId: 6, ElementFieldId: 7, Namespace: "pg_catalog"},
+		}
+		loadedRows := false
+		loadRows := func() [][]string {
+			if loadedRows {
+				return [][]string{}
+			}
+			loadedRows = true
+			return [][]string{
+				{"-300", "-1.28", "Lorem ipsum dolor sit amet", "2024-01-01 00:00:00.001", "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", "{a,b}"},
+				{"2", "10.50", "Lorem ipsum", "2024-01-02 00:00:00", "0b1e5c8a-5e6b-4f6c-9c1a-2f3d4e5f6a7b", "{c}"},
+			}
+		}
+
+		parquetFile, _, err := storage.CreateParquet(t.TempDir(), pgSchemaColumns, loadRows, 0)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		expectedLowerBounds := map[int][]byte{
+			1: binary.LittleEndian.AppendUint64(nil, 0xfffffffffffffed4), // -300
+			2: {0x80},                                                    // -128
+			3: []byte("Lorem ipsum"),
+			4: binary.LittleEndian.AppendUint64(nil, uint64(1704067200001000)),
+			5: {0x0b, 0x1e, 0x5c, 0x8a, 0x5e, 0x6b, 0x4f, 0x6c, 0x9c, 0x1a, 0x2f, 0x3d, 0x4e, 0x5f, 0x6a, 0x7b},
+		}
+		if !reflect.DeepEqual(parquetFile.Stats.LowerBounds, expectedLowerBounds) {
+			t.Errorf("Expected lower bounds %v, got %v", expectedLowerBounds, parquetFile.Stats.LowerBounds)
+		}
+		expectedUpperBounds := map[int][]byte{
+			1: binary.LittleEndian.AppendUint64(nil, 2),
+			2: {0x04, 0x1a},               // 1050
+			3: []byte("Lorem ipsum dolp"), // Truncated to 16 characters and incremented
+			4: binary.LittleEndian.AppendUint64(nil, uint64(1704153600000000)),
+			5: {0xa0, 0xee, 0xbc, 0x99, 0x9c, 0x0b, 0x4e, 0xf8, 0xbb, 0x6d, 0x6b, 0xb9, 0xbd, 0x38, 0x0a, 0x11},
+		}
+		if !reflect.DeepEqual(parquetFile.Stats.UpperBounds, expectedUpperBounds) {
+			t.Errorf("Expected upper bounds %v, got %v", expectedUpperBounds, parquetFile.Stats.UpperBounds)
+		}
+	})
 }
 
 func TestCreateManifest(t *testing.T) {
@@ -92,7 +151,7 @@ func TestCreateManifest(t *testing.T) {
 		storage := NewLocalStorage(config)
 		parquetFile := createTestParquetFile(storage, tempDir)
 
-		manifestFile, err := storage.CreateManifest(tempDir, parquetFile)
+		manifestFile, err := storage.CreateManifest(tempDir, IcebergPartitionSpec{}, parquetFile)
 
 		if err != nil {
 			t.Errorf("Expected no error, got %v", err)
@@ -113,6 +172,47 @@ func TestCreateManifest(t *testing.T) {
 			t.Errorf("Expected a data file size of %v, got %v", parquetFile.Size, manifestFile.DataFileSize)
 		}
 	})
+
+	t.Run("Creates a manifest file with a partition", func(t *testing.T) {
+		tempDir := os.TempDir()
+		config := loadTestConfig()
+		storage := NewLocalStorage(config)
+		parquetFile := createTestParquetFile(storage, tempDir)
+		partitionValue := "John"
+		parquetFile.PartitionValues = []*string{&partitionValue}
+		partitionSpec := IcebergPartitionSpec{Fields: []IcebergPartitionField{
+			{SourceId: 2, FieldId: ICEBERG_PARTITION_FIELD_ID_START, Name: "name", Transform: ICEBERG_PARTITION_TRANSFORM_IDENTITY, ResultType: "string"},
+		}}
+
+		manifestFile, err := storage.CreateManifest(tempDir, partitionSpec, parquetFile)
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if len(manifestFile.PartitionFieldSummaries) != 1 {
+			t.Fatalf("Expected 1 partition field summary, got %v", len(manifestFile.PartitionFieldSummaries))
+		}
+		summary := manifestFile.PartitionFieldSummaries[0]
+		if summary.ContainsNull || string(summary.LowerBound) != "John" || string(summary.UpperBound) != "John" {
+			t.Errorf("Expected a partition field summary with John bounds, got %v", summary)
+		}
+
+		file, err := os.Open(manifestFile.Path)
+		PanicIfError(err, config)
+		defer file.Close()
+		ocfReader, err := goavro.NewOCFReader(file)
+		PanicIfError(err, config)
+		if string(ocfReader.MetaData()["partition-spec"]) != `[{"field-id":1000,"name":"name","source-id":2,"transform":"identity"}]` {
+			t.Errorf("Expected a partition spec in the metadata, got %v", string(ocfReader.MetaData()["partition-spec"]))
+		}
+		ocfReader.Scan()
+		record, err := ocfReader.Read()
+		PanicIfError(err, config)
+		partition := record.(map[string]interface{})["data_file"].(map[string]interface{})["partition"].(map[string]interface{})
+		if partition["name"].(map[string]interface{})["string"] != "John" {
+			t.Errorf("Expected a partition value of John, got %v", partition["name"])
+		}
+	})
 }
 
 func TestCreateManifestList(t *testing.T) {
@@ -121,7 +221,7 @@ func TestCreateManifestList(t *testing.T) {
 		config := loadTestConfig()
 		storage := NewLocalStorage(config)
 		parquetFile := createTestParquetFile(storage, tempDir)
-		manifestFile, err := storage.CreateManifest(tempDir, parquetFile)
+		manifestFile, err := storage.CreateManifest(tempDir, IcebergPartitionSpec{}, parquetFile)
 		PanicIfError(err, config)
 		manifestListItem := ManifestListItem{SequenceNumber: 1, ManifestFile: manifestFile}
 
@@ -156,17 +256,17 @@ func TestCreateManifestList(t *testing.T) {
 
 func TestCreateMetadata(t *testing.T) {
 	t.Run("Creates a metadata file", func(t *testing.T) {
-		tempDir := os.TempDir()
+		tempDir := t.TempDir()
 		config := loadTestConfig()
 		storage := NewLocalStorage(config)
 		parquetFile := createTestParquetFile(storage, tempDir)
-		manifestFile, err := storage.CreateManifest(tempDir, parquetFile)
+		manifestFile, err := storage.CreateManifest(tempDir, IcebergPartitionSpec{}, parquetFile)
 		PanicIfError(err, config)
 		manifestListItem := ManifestListItem{SequenceNumber: 1, ManifestFile: manifestFile}
 		manifestListFile, err := storage.CreateManifestList(tempDir, parquetFile.Uuid, []ManifestListItem{manifestListItem})
 		PanicIfError(err, config)
 
-		metadataFile, err := storage.CreateMetadata(tempDir, TEST_STORAGE_PG_SCHEMA_COLUMNS, []ManifestListFile{manifestListFile})
+		metadataFile, err := storage.CreateMetadata(tempDir, TEST_STORAGE_ICEBERG_SCHEMAS, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{manifestListFile})
 
 		if err != nil {
 			t.Errorf("Expected no error, got %v", err)
@@ -178,20 +278,195 @@ func TestCreateMetadata(t *testing.T) {
 			t.Errorf("Expected a non-empty path, got %v", metadataFile.Path)
 		}
 	})
+
+	t.Run("Creates a metadata file with a partition spec", func(t *testing.T) {
+		tempDir := t.TempDir()
+		config := loadTestConfig()
+		storage := NewLocalStorage(config)
+		parquetFile := createTestParquetFile(storage, tempDir)
+		partitionValue := "1"
+		parquetFile.PartitionValues = []*string{&partitionValue}
+		partitionSpec := IcebergPartitionSpec{Fields: []IcebergPartitionField{
+			{SourceId: 1, FieldId: ICEBERG_PARTITION_FIELD_ID_START, Name: "id", Transform: ICEBERG_PARTITION_TRANSFORM_IDENTITY, ResultType: "int"},
+		}}
+		manifestFile, err := storage.CreateManifest(tempDir, partitionSpec, parquetFile)
+		PanicIfError(err, config)
+		manifestListItem := ManifestListItem{SequenceNumber: 1, ManifestFile: manifestFile}
+		manifestListFile, err := storage.CreateManifestList(tempDir, parquetFile.Uuid, []ManifestListItem{manifestListItem})
+		PanicIfError(err, config)
+
+		metadataFile, err := storage.CreateMetadata(tempDir, TEST_STORAGE_ICEBERG_SCHEMAS, partitionSpec, IcebergSortOrder{}, []ManifestListFile{manifestListFile})
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		metadataContent, err := os.ReadFile(metadataFile.Path)
+		PanicIfError(err, config)
+		var metadata map[string]interface{}
+		PanicIfError(json.Unmarshal(metadataContent, &metadata), config)
+		if metadata["last-partition-id"] != float64(ICEBERG_PARTITION_FIELD_ID_START) {
+			t.Errorf("Expected a last partition ID of %v, got %v", ICEBERG_PARTITION_FIELD_ID_START, metadata["last-partition-id"])
+		}
+		partitionFields := metadata["partition-specs"].([]interface{})[0].(map[string]interface{})["fields"].([]interface{})
+		if len(partitionFields) != 1 || partitionFields[0].(map[string]interface{})["name"] != "id" {
+			t.Errorf("Expected a partition field id, got %v", partitionFields)
+		}
+	})
+	t.Run("Creates a metadata file with a sort order", func(t *testing.T) {
+		tempDir := t.TempDir()
+		config := loadTestConfig()
+		storage := NewLocalStorage(config)
+		parquetFile := createTestParquetFile(storage, tempDir)
+		manifestFile, err := storage.CreateManifest(tempDir, IcebergPartitionSpec{}, parquetFile)
+		PanicIfError(err, config)
+		manifestListItem := ManifestListItem{SequenceNumber: 1, ManifestFile: manifestFile}
+		manifestListFile, err := storage.CreateManifestList(tempDir, parquetFile.Uuid, []ManifestListItem{manifestListItem})
+		PanicIfError(err, config)
+		sortOrder, err := NewIcebergSortOrder([]string{"name DESC"}, TEST_STORAGE_PG_SCHEMA_COLUMNS)
+		PanicIfError(err, config)
+
+		metadataFile, err := storage.CreateMetadata(tempDir, TEST_STORAGE_ICEBERG_SCHEMAS, IcebergPartitionSpec{}, sortOrder, []ManifestListFile{manifestListFile})
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		metadataContent, err := os.ReadFile(metadataFile.Path)
+		PanicIfError(err, config)
+		var metadata map[string]interface{}
+		PanicIfError(json.Unmarshal(metadataContent, &metadata), config)
+		if metadata["default-sort-order-id"] != float64(ICEBERG_SORT_ORDER_ID_SORTED) {
+			t.Errorf("Expected a default sort order ID of %v, got %v", ICEBERG_SORT_ORDER_ID_SORTED, metadata["default-sort-order-id"])
+		}
+		sortOrders := metadata["sort-orders"].([]interface{})
+		if len(sortOrders) != 2 {
+			t.Fatalf("Expected the unsorted and the sorted order, got %v", sortOrders)
+		}
+		sortField := sortOrders[1].(map[string]interface{})["fields"].([]interface{})[0].(map[string]interface{})
+		if sortField["source-id"] != float64(2) || sortField["direction"] != "desc" || sortField["null-order"] != "nulls-first" {
+			t.Errorf("Expected a descending sort field on name with NULLs first, got %v", sortField)
+		}
+	})
+
+	t.Run("Creates the next metadata version with a metadata log and a version hint", func(t *testing.T) {
+		tempDir := t.TempDir()
+		config := loadTestConfig()
+		storage := NewLocalStorage(config)
+		firstManifestListFile := createTestManifestListFile(storage, tempDir, 1)
+		firstMetadataFile, err := storage.CreateMetadata(tempDir, TEST_STORAGE_ICEBERG_SCHEMAS, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{firstManifestListFile})
+		PanicIfError(err, config)
+		secondManifestListFile := createTestManifestListFile(storage, tempDir, 2)
+
+		metadataFile, err := storage.CreateMetadata(tempDir, TEST_STORAGE_ICEBERG_SCHEMAS, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{firstManifestListFile, secondManifestListFile})
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if metadataFile.Version != 2 || filepath.Base(metadataFile.Path) != "v2.metadata.json" {
+			t.Errorf("Expected v2.metadata.json, got %v", metadataFile.Path)
+		}
+		versionHintContent, err := os.ReadFile(filepath.Join(tempDir, ICEBERG_VERSION_HINT_FILE_NAME))
+		PanicIfError(err, config)
+		if string(versionHintContent) != "2" {
+			t.Errorf("Expected a version hint of 2, got %v", string(versionHintContent))
+		}
+		metadataContent, err := os.ReadFile(metadataFile.Path)
+		PanicIfError(err, config)
+		var metadata map[string]interface{}
+		PanicIfError(json.Unmarshal(metadataContent, &metadata), config)
+		metadataLog := metadata["metadata-log"].([]interface{})
+		if len(metadataLog) != 1 || metadataLog[0].(map[string]interface{})["metadata-file"] != firstMetadataFile.Path {
+			t.Errorf("Expected a metadata log with %v, got %v", firstMetadataFile.Path, metadataLog)
+		}
+	})
+
+	t.Run("Keeps the table UUID and writes the last column ID and identifier field IDs", func(t *testing.T) {
+		tempDir := t.TempDir()
+		config := loadTestConfig()
+		storage := NewLocalStorage(config)
+		schemas := []IcebergSchema{{SchemaId: 0, Fields: TEST_STORAGE_ICEBERG_SCHEMAS[0].Fields, IdentifierFieldIds: []int{1}}}
+		firstManifestListFile := createTestManifestListFile(storage, tempDir, 1)
+		firstMetadataFile, err := storage.CreateMetadata(tempDir, schemas, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{firstManifestListFile})
+		PanicIfError(err, config)
+
+		metadataFile, err := storage.CreateMetadata(tempDir, schemas, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{firstManifestListFile, createTestManifestListFile(storage, tempDir, 2)})
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		var firstMetadata, metadata map[string]interface{}
+		firstMetadataContent, err := os.ReadFile(firstMetadataFile.Path)
+		PanicIfError(err, config)
+		PanicIfError(json.Unmarshal(firstMetadataContent, &firstMetadata), config)
+		metadataContent, err := os.ReadFile(metadataFile.Path)
+		PanicIfError(err, config)
+		PanicIfError(json.Unmarshal(metadataContent, &metadata), config)
+		if metadata["table-uuid"] == "" || metadata["table-uuid"] != firstMetadata["table-uuid"] {
+			t.Errorf("Expected the table UUID %v, got %v", firstMetadata["table-uuid"], metadata["table-uuid"])
+		}
+		if metadata["last-column-id"] != float64(2) {
+			t.Errorf("Expected a last column ID of 2, got %v", metadata["last-column-id"])
+		}
+		identifierFieldIds := metadata["schemas"].([]interface{})[0].(map[string]interface{})["identifier-field-ids"].([]interface{})
+		if len(identifierFieldIds) != 1 || identifierFieldIds[0] != float64(1) {
+			t.Errorf("Expected identifier field IDs [1], got %v", identifierFieldIds)
+		}
+	})
+
+	t.Run("Returns a conflict error if the current snapshot was committed concurrently", func(t *testing.T) {
+		tempDir := t.TempDir()
+		config := loadTestConfig()
+		storage := NewLocalStorage(config)
+		firstManifestListFile := createTestManifestListFile(storage, tempDir, 1)
+		_, err := storage.CreateMetadata(tempDir, TEST_STORAGE_ICEBERG_SCHEMAS, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{firstManifestListFile})
+		PanicIfError(err, config)
+		concurrentManifestListFile := createTestManifestListFile(storage, tempDir, 2)
+		_, err = storage.CreateMetadata(tempDir, TEST_STORAGE_ICEBERG_SCHEMAS, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{firstManifestListFile, concurrentManifestListFile})
+		PanicIfError(err, config)
+		staleManifestListFile := createTestManifestListFile(storage, tempDir, 2)
+
+		_, err = storage.CreateMetadata(tempDir, TEST_STORAGE_ICEBERG_SCHEMAS, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{firstManifestListFile, staleManifestListFile})
+
+		var conflictErr *MetadataCommitConflictError
+		if !errors.As(err, &conflictErr) {
+			t.Errorf("Expected a metadata commit conflict error, got %v", err)
+		}
+		metadataFile, err := storage.ExistingMetadataFile(tempDir)
+		PanicIfError(err, config)
+		if metadataFile.Version != 2 {
+			t.Errorf("Expected the current version to stay 2, got %v", metadataFile.Version)
+		}
+	})
+
+	t.Run("Returns a conflict error if the new metadata drops committed snapshots", func(t *testing.T) {
+		tempDir := t.TempDir()
+		config := loadTestConfig()
+		storage := NewLocalStorage(config)
+		firstManifestListFile := createTestManifestListFile(storage, tempDir, 1)
+		_, err := storage.CreateMetadata(tempDir, TEST_STORAGE_ICEBERG_SCHEMAS, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{firstManifestListFile})
+		PanicIfError(err, config)
+		newHistoryManifestListFile := createTestManifestListFile(storage, tempDir, 1)
+
+		_, err = storage.CreateMetadata(tempDir, TEST_STORAGE_ICEBERG_SCHEMAS, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{newHistoryManifestListFile})
+
+		var conflictErr *MetadataCommitConflictError
+		if !errors.As(err, &conflictErr) {
+			t.Errorf("Expected a metadata commit conflict error, got %v", err)
+		}
+	})
 }
 
 func TestExistingManifestListFiles(t *testing.T) {
 	t.Run("Returns existing manifest list files", func(t *testing.T) {
-		tempDir := os.TempDir()
+		tempDir := t.TempDir()
 		config := loadTestConfig()
 		storage := NewLocalStorage(config)
 		parquetFile := createTestParquetFile(storage, tempDir)
-		manifestFile, err := storage.CreateManifest(tempDir, parquetFile)
+		manifestFile, err := storage.CreateManifest(tempDir, IcebergPartitionSpec{}, parquetFile)
 		PanicIfError(err, config)
 		manifestListItem := ManifestListItem{SequenceNumber: 1, ManifestFile: manifestFile}
 		manifestListFile, err := storage.CreateManifestList(tempDir, parquetFile.Uuid, []ManifestListItem{manifestListItem})
 		PanicIfError(err, config)
-		_, err = storage.CreateMetadata(tempDir, TEST_STORAGE_PG_SCHEMA_COLUMNS, []ManifestListFile{manifestListFile})
+		_, err = storage.CreateMetadata(tempDir, TEST_STORAGE_ICEBERG_SCHEMAS, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{manifestListFile})
 		PanicIfError(err, config)
 
 		existingManifestListFiles, err := storage.ExistingManifestListFiles(tempDir)
@@ -226,13 +501,79 @@ func TestExistingManifestListFiles(t *testing.T) {
 	})
 }
 
+func TestExistingMetadataFile(t *testing.T) {
+	t.Run("Returns no metadata file for a new table", func(t *testing.T) {
+		config := loadTestConfig()
+		storage := NewLocalStorage(config)
+
+		metadataFile, err := storage.ExistingMetadataFile(t.TempDir())
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if metadataFile.Version != 0 {
+			t.Errorf("Expected a version of 0, got %v", metadataFile.Version)
+		}
+	})
+
+	t.Run("Returns a metadata file written without a version hint", func(t *testing.T) {
+		tempDir := t.TempDir()
+		config := loadTestConfig()
+		storage := NewLocalStorage(config)
+		_, err := storage.CreateMetadata(tempDir, TEST_STORAGE_ICEBERG_SCHEMAS, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{createTestManifestListFile(storage, tempDir, 1)})
+		PanicIfError(err, config)
+		PanicIfError(os.Remove(filepath.Join(tempDir, ICEBERG_VERSION_HINT_FILE_NAME)), config)
+
+		metadataFile, err := storage.ExistingMetadataFile(tempDir)
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if metadataFile.Version != 1 || metadataFile.Path != filepath.Join(tempDir, "v1.metadata.json") {
+			t.Errorf("Expected v1.metadata.json, got %v", metadataFile.Path)
+		}
+	})
+}
+
+func TestExistingPartitionSpecAndSortOrder(t *testing.T) {
+	t.Run("Returns the partition spec and the sort order of existing metadata", func(t *testing.T) {
+		tempDir := t.TempDir()
+		config := loadTestConfig()
+		storage := NewLocalStorage(config)
+		parquetFile := createTestParquetFile(storage, tempDir)
+		manifestFile, err := storage.CreateManifest(tempDir, IcebergPartitionSpec{}, parquetFile)
+		PanicIfError(err, config)
+		manifestListItem := ManifestListItem{SequenceNumber: 1, ManifestFile: manifestFile}
+		manifestListFile, err := storage.CreateManifestList(tempDir, parquetFile.Uuid, []ManifestListItem{manifestListItem})
+		PanicIfError(err, config)
+		partitionSpec, err := NewIcebergPartitionSpec([]string{"bucket[16](id)"}, TEST_STORAGE_PG_SCHEMA_COLUMNS)
+		PanicIfError(err, config)
+		sortOrder, err := NewIcebergSortOrder([]string{"name DESC"}, TEST_STORAGE_PG_SCHEMA_COLUMNS)
+		PanicIfError(err, config)
+		_, err = storage.CreateMetadata(tempDir, TEST_STORAGE_ICEBERG_SCHEMAS, partitionSpec, sortOrder, []ManifestListFile{manifestListFile})
+		PanicIfError(err, config)
+
+		existingPartitionSpec, err := storage.ExistingPartitionSpec(tempDir)
+		PanicIfError(err, config)
+		existingSortOrder, err := storage.ExistingSortOrder(tempDir)
+		PanicIfError(err, config)
+
+		if !reflect.DeepEqual(existingPartitionSpec, partitionSpec) {
+			t.Errorf("Expected a partition spec of %v, got %v", partitionSpec, existingPartitionSpec)
+		}
+		if !reflect.DeepEqual(existingSortOrder, sortOrder) {
+			t.Errorf("Expected a sort order of %v, got %v", sortOrder, existingSortOrder)
+		}
+	})
+}
+
 func TestExistingManifestFiles(t *testing.T) {
 	t.Run("Returns existing manifest files", func(t *testing.T) {
 		tempDir := os.TempDir()
 		config := loadTestConfig()
 		storage := NewLocalStorage(config)
 		parquetFile := createTestParquetFile(storage, tempDir)
-		manifestFile, err := storage.CreateManifest(tempDir, parquetFile)
+		manifestFile, err := storage.CreateManifest(tempDir, IcebergPartitionSpec{}, parquetFile)
 		PanicIfError(err, config)
 		manifestListItem := ManifestListItem{SequenceNumber: 1, ManifestFile: manifestFile}
 		manifestListFile, err := storage.CreateManifestList(tempDir, parquetFile.Uuid, []ManifestListItem{manifestListItem})
@@ -264,6 +605,97 @@ func TestExistingManifestFiles(t *testing.T) {
 	})
 }
 
+func TestExistingParquetFile(t *testing.T) {
+	t.Run("Returns a data file with its stats from the manifest", func(t *testing.T) {
+		tempDir := os.TempDir()
+		config := loadTestConfig()
+		storage := NewLocalStorage(config)
+		parquetFile := createTestParquetFile(storage, tempDir)
+		manifestFile, err := storage.CreateManifest(tempDir, IcebergPartitionSpec{}, parquetFile)
+		PanicIfError(err, config)
+
+		existingParquetFile, err := storage.ExistingParquetFile(manifestFile)
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if existingParquetFile.Path != parquetFile.Path || existingParquetFile.Size != parquetFile.Size || existingParquetFile.RecordCount != parquetFile.RecordCount {
+			t.Errorf("Expected the path, size and record count of %v, got %v", parquetFile, existingParquetFile)
+		}
+		if !reflect.DeepEqual(existingParquetFile.Stats, parquetFile.Stats) {
+			t.Errorf("Expected stats of %v, got %v", parquetFile.Stats, existingParquetFile.Stats)
+		}
+	})
+}
+
+func TestSyncJournal(t *testing.T) {
+	t.Run("Returns nil if there is no sync journal", func(t *testing.T) {
+		config := loadTestConfig()
+		storage := NewLocalStorage(config)
+		PanicIfError(storage.DeleteSyncJournal(), config)
+
+		syncJournal, err := storage.SyncJournal()
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if syncJournal != nil {
+			t.Errorf("Expected no sync journal, got %v", syncJournal)
+		}
+	})
+
+	t.Run("Returns a written sync journal", func(t *testing.T) {
+		config := loadTestConfig()
+		storage := NewLocalStorage(config)
+		t.Cleanup(func() {
+			storage.DeleteSyncJournal()
+		})
+		parquetFile := createTestParquetFile(storage, os.TempDir())
+		err := storage.WriteSyncJournal(SyncJournal{
+			StartedAt:       1,
+			CompletedTables: []string{"public.users"},
+			InProgressTable: &SyncJournalTable{
+				Schema: "public",
+				Table:  "orders",
+				PrimaryKeyRanges: []PrimaryKeyRange{
+					{Columns: []PgPrimaryKeyColumn{{Name: "id", Type: "bigint"}}, End: []string{"1000"}},
+					{Columns: []PgPrimaryKeyColumn{{Name: "id", Type: "bigint"}}, Start: []string{"1000"}},
+				},
+				CompletedPrimaryKeyRanges: 1,
+				ParquetFiles:              []ParquetFile{parquetFile},
+			},
+		})
+		PanicIfError(err, config)
+
+		syncJournal, err := storage.SyncJournal()
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if syncJournal.StartedAt != 1 {
+			t.Errorf("Expected a start time of 1, got %v", syncJournal.StartedAt)
+		}
+		if len(syncJournal.CompletedTables) != 1 || syncJournal.CompletedTables[0] != "public.users" {
+			t.Errorf("Expected completed tables of [public.users], got %v", syncJournal.CompletedTables)
+		}
+		if syncJournal.InProgressTable.PgSchemaTable().String() != `"public"."orders"` {
+			t.Errorf("Expected an in-progress table of \"public\".\"orders\", got %v", syncJournal.InProgressTable.PgSchemaTable().String())
+		}
+		if len(syncJournal.InProgressTable.PrimaryKeyRanges) != 2 || syncJournal.InProgressTable.PrimaryKeyRanges[1].WhereCondition() != `("id") >= ('1000'::bigint)` {
+			t.Errorf("Expected primary key ranges split at 1000, got %v", syncJournal.InProgressTable.PrimaryKeyRanges)
+		}
+		if syncJournal.InProgressTable.CompletedPrimaryKeyRanges != 1 {
+			t.Errorf("Expected 1 completed primary key range, got %v", syncJournal.InProgressTable.CompletedPrimaryKeyRanges)
+		}
+		if syncJournal.InProgressTable.ParquetFiles[0].Path != parquetFile.Path {
+			t.Errorf("Expected a Parquet file path of %v, got %v", parquetFile.Path, syncJournal.InProgressTable.ParquetFiles[0].Path)
+		}
+		if syncJournal.InProgressTable.ParquetFiles[0].RecordCount != parquetFile.RecordCount {
+			t.Errorf("Expected a record count of %v, got %v", parquetFile.RecordCount, syncJournal.InProgressTable.ParquetFiles[0].RecordCount)
+		}
+	})
+}
+
 func createTestParquetFile(storage *StorageLocal, dir string) ParquetFile {
 	loadedRows := false
 	loadRows := func() [][]string {
@@ -281,3 +713,19 @@ func createTestParquetFile(storage *StorageLocal, dir string) ParquetFile {
 
 	return parquetFile
 }
+
+func createTestManifestListFile(storage *StorageLocal, dir string, sequenceNumber int) ManifestListFile {
+	parquetFile := createTestParquetFile(storage, dir)
+	manifestFile, err := storage.CreateManifest(dir, IcebergPartitionSpec{}, parquetFile)
+	if err != nil {
+		panic(err)
+	}
+
+	manifestListItem := ManifestListItem{SequenceNumber: sequenceNumber, ManifestFile: manifestFile}
+	manifestListFile, err := storage.CreateManifestList(dir, parquetFile.Uuid, []ManifestListItem{manifestListItem})
+	if err != nil {
+		panic(err)
+	}
+
+	return manifestListFile
+}
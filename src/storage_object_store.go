@@ -0,0 +1,995 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xitongsys/parquet-go-source/local"
+)
+
+const (
+	OBJECT_STORE_MAX_ATTEMPTS       = 5
+	OBJECT_STORE_BASE_BACKOFF       = 100 * time.Millisecond
+	OBJECT_STORE_MAX_BACKOFF        = 20 * time.Second
+	OBJECT_STORE_DELETE_CONCURRENCY = 10
+	OBJECT_STORE_TOKEN_EXPIRY_SKEW  = time.Minute // Refresh access tokens before they expire
+)
+
+type ObjectStoreObject struct {
+	Key          string
+	LastModified time.Time
+}
+
+// Non-2xx response of an object store API. Implements HTTPStatusCode, so 5xx responses are retried by the syncer
+type ObjectStoreError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+}
+
+func (err *ObjectStoreError) Error() string {
+	return fmt.Sprintf("%s %s failed with status %d: %s", err.Method, err.Path, err.StatusCode, err.Message)
+}
+
+func (err *ObjectStoreError) HTTPStatusCode() int {
+	return err.StatusCode
+}
+
+func IsObjectStoreNotFoundError(err error) bool {
+	var objectStoreErr *ObjectStoreError
+	return errors.As(err, &objectStoreErr) && objectStoreErr.StatusCode == http.StatusNotFound
+}
+
+// REST API of a cloud object store other than S3, see GcsClient and AzureClient
+type ObjectStoreClient interface {
+	FileSystemPrefix() string // Prefix of the paths DuckDB reads, e.g. gs://bucket/
+	ReadObject(key string) ([]byte, error)
+	ObjectExists(key string) (bool, error)
+	WriteObject(key string, file *os.File) error
+	WriteObjectIfNotExists(key string, file *os.File) (written bool, err error)
+	ListObjects(prefix string, delimiter string) (objects []ObjectStoreObject, commonPrefixes []string, err error) // Across all pages
+	DeleteObject(key string) error                                                                                 // No error if the object doesn't exist
+}
+
+// Storage on top of an object store client, with files written to temporary local files first and uploaded in a single request
+type StorageObjectStore struct {
+	client       ObjectStoreClient
+	config       *Config
+	storageUtils *StorageUtils
+}
+
+func NewObjectStoreStorage(config *Config, client ObjectStoreClient) *StorageObjectStore {
+	return &StorageObjectStore{client: client, config: config, storageUtils: &StorageUtils{config: config}}
+}
+
+// Read ----------------------------------------------------------------------------------------------------------------
+
+func (storage *StorageObjectStore) IcebergMetadataDirPath(icebergSchemaTable IcebergSchemaTable) string {
+	return storage.tablePrefix(icebergSchemaTable, true) + "metadata"
+}
+
+// Resolves the current metadata version through the version hint
+func (storage *StorageObjectStore) IcebergMetadataFilePath(icebergSchemaTable IcebergSchemaTable) (string, error) {
+	metadataFile, err := storage.ExistingMetadataFile(storage.IcebergMetadataDirPath(icebergSchemaTable))
+	if err != nil {
+		return "", err
+	}
+	if metadataFile.Version == 0 {
+		return "", fmt.Errorf("no Iceberg metadata found for %s", icebergSchemaTable.String())
+	}
+
+	return storage.client.FileSystemPrefix() + metadataFile.Path, nil
+}
+
+func (storage *StorageObjectStore) IcebergMetadata(icebergSchemaTable IcebergSchemaTable) (string, []byte, error) {
+	metadataPath, err := storage.IcebergMetadataFilePath(icebergSchemaTable)
+	if err != nil {
+		return "", nil, err
+	}
+	metadataContent, err := storage.client.ReadObject(strings.TrimPrefix(metadataPath, storage.client.FileSystemPrefix()))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return metadataPath, metadataContent, nil
+}
+
+func (storage *StorageObjectStore) IcebergSchemas() (icebergSchemas []string, err error) {
+	_, icebergSchemas, err = storage.client.ListObjects(storage.config.StoragePath+"/", "/")
+	if err != nil {
+		return nil, err
+	}
+
+	for i, schema := range icebergSchemas {
+		schemaParts := strings.Split(schema, "/")
+		icebergSchemas[i] = schemaParts[len(schemaParts)-2]
+	}
+
+	return icebergSchemas, nil
+}
+
+func (storage *StorageObjectStore) IcebergSchemaTables() (Set[IcebergSchemaTable], error) {
+	icebergSchemaTables := make(Set[IcebergSchemaTable])
+	icebergSchemas, err := storage.IcebergSchemas()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, icebergSchema := range icebergSchemas {
+		_, tables, err := storage.client.ListObjects(storage.config.StoragePath+"/"+icebergSchema+"/", "/")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tablePrefix := range tables {
+			tableParts := strings.Split(tablePrefix, "/")
+			table := tableParts[len(tableParts)-2]
+
+			icebergSchemaTables.Add(IcebergSchemaTable{Schema: icebergSchema, Table: table})
+		}
+	}
+
+	return icebergSchemaTables, nil
+}
+
+func (storage *StorageObjectStore) IcebergTableFields(icebergSchemaTable IcebergSchemaTable) ([]IcebergTableField, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(storage.IcebergMetadataDirPath(icebergSchemaTable))
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageUtils.ParseIcebergTableFields(metadataContent)
+}
+
+// The version hint can be behind if a concurrent commit hasn't updated it yet, so newer versions are looked up too
+func (storage *StorageObjectStore) ExistingMetadataFile(metadataDirPath string) (MetadataFile, error) {
+	var version int64
+	versionHintContent, err := storage.client.ReadObject(metadataDirPath + "/" + ICEBERG_VERSION_HINT_FILE_NAME)
+	if err == nil {
+		version, err = storage.storageUtils.ParseVersionHint(versionHintContent)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+	} else if !IsObjectStoreNotFoundError(err) {
+		return MetadataFile{}, err
+	}
+
+	for {
+		exists, err := storage.client.ObjectExists(metadataDirPath + "/" + storage.storageUtils.MetadataFileName(version+1))
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		if !exists {
+			break
+		}
+		version++
+	}
+	if version == 0 {
+		return MetadataFile{}, nil
+	}
+
+	return MetadataFile{Version: version, Path: metadataDirPath + "/" + storage.storageUtils.MetadataFileName(version)}, nil
+}
+
+func (storage *StorageObjectStore) ExistingManifestListFiles(metadataDirPath string) ([]ManifestListFile, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageUtils.ParseManifestListFiles(storage.client.FileSystemPrefix(), metadataContent)
+}
+
+func (storage *StorageObjectStore) ExistingSchemas(metadataDirPath string) ([]IcebergSchema, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageUtils.ParseIcebergSchemas(metadataContent)
+}
+
+func (storage *StorageObjectStore) ExistingPartitionSpec(metadataDirPath string) (IcebergPartitionSpec, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
+	if err != nil {
+		return IcebergPartitionSpec{}, err
+	}
+
+	return storage.storageUtils.ParseIcebergPartitionSpec(metadataContent)
+}
+
+func (storage *StorageObjectStore) ExistingPartitionSpecs(metadataDirPath string) ([]IcebergPartitionSpec, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageUtils.ParseIcebergPartitionSpecs(metadataContent)
+}
+
+func (storage *StorageObjectStore) ExistingSortOrder(metadataDirPath string) (IcebergSortOrder, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
+	if err != nil {
+		return IcebergSortOrder{}, err
+	}
+
+	return storage.storageUtils.ParseIcebergSortOrder(metadataContent)
+}
+
+func (storage *StorageObjectStore) ExistingManifestListItems(manifestListFile ManifestListFile) ([]ManifestListItem, error) {
+	manifestListContent, err := storage.client.ReadObject(manifestListFile.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageUtils.ParseManifestFiles(storage.client.FileSystemPrefix(), manifestListContent)
+}
+
+func (storage *StorageObjectStore) ExistingParquetFilePath(manifestFile ManifestFile) (string, error) {
+	manifestContent, err := storage.client.ReadObject(manifestFile.Path)
+	if err != nil {
+		return "", err
+	}
+
+	return storage.storageUtils.ParseParquetFilePath(storage.client.FileSystemPrefix(), manifestContent)
+}
+
+func (storage *StorageObjectStore) ExistingParquetFile(manifestFile ManifestFile) (ParquetFile, error) {
+	manifestContent, err := storage.client.ReadObject(manifestFile.Path)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+
+	return storage.storageUtils.ParseParquetFile(storage.client.FileSystemPrefix(), manifestContent)
+}
+
+func (storage *StorageObjectStore) ExistingDeletedParquetFilePaths(positionDeletesFilePaths []string) ([]string, error) {
+	return storage.storageUtils.ReadDeletedParquetFilePaths(storage.client.FileSystemPrefix(), positionDeletesFilePaths)
+}
+
+func (storage *StorageObjectStore) ExistingFilePaths(dirPath string) ([]string, error) {
+	objects, _, err := storage.client.ListObjects(dirPath+"/", "/")
+	if err != nil {
+		return nil, err
+	}
+
+	filePaths := []string{}
+	for _, object := range objects {
+		filePaths = append(filePaths, object.Key)
+	}
+
+	return filePaths, nil
+}
+
+func (storage *StorageObjectStore) ExistingFilePathsModifiedBefore(dirPath string, modifiedBefore time.Time) ([]string, error) {
+	objects, _, err := storage.client.ListObjects(dirPath+"/", "/")
+	if err != nil {
+		return nil, err
+	}
+
+	filePaths := []string{}
+	for _, object := range objects {
+		if object.LastModified.Before(modifiedBefore) {
+			filePaths = append(filePaths, object.Key)
+		}
+	}
+
+	return filePaths, nil
+}
+
+// Write ---------------------------------------------------------------------------------------------------------------
+
+func (storage *StorageObjectStore) DeleteSchema(schema string) (err error) {
+	return storage.deleteNestedObjects(storage.config.StoragePath + "/" + schema + "/")
+}
+
+func (storage *StorageObjectStore) DeleteSchemaTable(schemaTable IcebergSchemaTable) (err error) {
+	return storage.deleteNestedObjects(storage.tablePrefix(schemaTable))
+}
+
+func (storage *StorageObjectStore) CreateDataDir(schemaTable IcebergSchemaTable) (dataDirPath string) {
+	return storage.tablePrefix(schemaTable) + "data"
+}
+
+func (storage *StorageObjectStore) CreateMetadataDir(schemaTable IcebergSchemaTable) (metadataDirPath string) {
+	return storage.tablePrefix(schemaTable) + "metadata"
+}
+
+func (storage *StorageObjectStore) CreateParquet(dataDirPath string, pgSchemaColumns []PgSchemaColumn, loadRows func() [][]string, maxWritePayloadSize int) (parquetFile ParquetFile, loadedAllRows bool, err error) {
+	uuid := uuid.New().String()
+	fileKey := dataDirPath + "/" + fmt.Sprintf("00000-0-%s.parquet", uuid)
+
+	tempFilePath := storage.temporaryParquetFilePath(uuid)
+	defer os.Remove(tempFilePath)
+	fileWriter, err := local.NewLocalFileWriter(tempFilePath)
+	if err != nil {
+		return ParquetFile{}, false, fmt.Errorf("failed to open Parquet file for writing: %w", err)
+	}
+
+	recordCount, loadedAllRows, err := storage.storageUtils.WriteParquetFile(fileWriter, pgSchemaColumns, loadRows, maxWritePayloadSize)
+	if err != nil {
+		return ParquetFile{}, false, err
+	}
+
+	fileSize, parquetStats, err := storage.uploadParquetFile(tempFilePath, fileKey, pgSchemaColumns)
+	if err != nil {
+		return ParquetFile{}, false, err
+	}
+	LogDebug(storage.config, "Parquet file with", recordCount, "record(s) created at:", fileKey)
+
+	return ParquetFile{
+		Uuid:        uuid,
+		Path:        fileKey,
+		Size:        fileSize,
+		RecordCount: recordCount,
+		Stats:       parquetStats,
+	}, loadedAllRows, nil
+}
+
+func (storage *StorageObjectStore) CreateOverwrittenParquet(dataDirPath string, existingParquetFilePath string, newParquetFilePaths []string, pgSchemaColumns []PgSchemaColumn, rowCountPerBatch int) (overwrittenParquetFile ParquetFile, err error) {
+	uuid := uuid.New().String()
+	fileKey := dataDirPath + "/" + fmt.Sprintf("00000-0-%s.parquet", uuid)
+
+	duckdb, err := storage.storageUtils.NewDuckDBIfHasOverlappingRows(storage.client.FileSystemPrefix(), existingParquetFilePath, newParquetFilePaths, pgSchemaColumns)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	if duckdb == nil {
+		return ParquetFile{}, nil
+	}
+	defer duckdb.Close()
+
+	tempFilePath := storage.temporaryParquetFilePath(uuid)
+	defer os.Remove(tempFilePath)
+	fileWriter, err := local.NewLocalFileWriter(tempFilePath)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("failed to open Parquet file for writing: %w", err)
+	}
+
+	recordCount, err := storage.storageUtils.WriteOverwrittenParquetFile(duckdb, fileWriter, pgSchemaColumns, rowCountPerBatch)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+
+	fileSize, parquetStats, err := storage.uploadParquetFile(tempFilePath, fileKey, pgSchemaColumns)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	LogDebug(storage.config, "Parquet file with", recordCount, "record(s) created at:", fileKey)
+
+	return ParquetFile{
+		Uuid:        uuid,
+		Path:        fileKey,
+		Size:        fileSize,
+		RecordCount: recordCount,
+		Stats:       parquetStats,
+	}, nil
+}
+
+func (storage *StorageObjectStore) CreateCompactedParquet(dataDirPath string, parquetFilePaths []string, positionDeletesFilePaths []string, pgSchemaColumns []PgSchemaColumn, sortOrder IcebergSortOrder) (compactedParquetFile ParquetFile, err error) {
+	uuid := uuid.New().String()
+	fileKey := dataDirPath + "/" + fmt.Sprintf("00000-0-%s.parquet", uuid)
+
+	tempFilePath := storage.temporaryParquetFilePath(uuid)
+	defer os.Remove(tempFilePath)
+	fileWriter, err := local.NewLocalFileWriter(tempFilePath)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("failed to open Parquet file for writing: %w", err)
+	}
+
+	recordCount, err := storage.storageUtils.WriteCompactedParquetFile(storage.client.FileSystemPrefix(), parquetFilePaths, positionDeletesFilePaths, fileWriter, pgSchemaColumns, sortOrder)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+
+	fileSize, parquetStats, err := storage.uploadParquetFile(tempFilePath, fileKey, pgSchemaColumns)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	LogDebug(storage.config, "Parquet file with", recordCount, "record(s) created at:", fileKey)
+
+	return ParquetFile{
+		Uuid:        uuid,
+		Path:        fileKey,
+		Size:        fileSize,
+		RecordCount: recordCount,
+		Stats:       parquetStats,
+		SortOrderId: sortOrder.OrderId,
+	}, nil
+}
+
+func (storage *StorageObjectStore) CreatePositionDeletesParquet(dataDirPath string, existingParquetFilePaths []string, newParquetFilePaths []string, keyPgSchemaColumns []PgSchemaColumn) (positionDeletesFile ParquetFile, err error) {
+	uuid := uuid.New().String()
+	fileKey := dataDirPath + "/" + fmt.Sprintf("00000-0-%s.parquet", uuid)
+
+	tempFilePath := storage.temporaryParquetFilePath(uuid)
+	defer os.Remove(tempFilePath)
+	fileWriter, err := local.NewLocalFileWriter(tempFilePath)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("failed to open Parquet file for writing: %w", err)
+	}
+
+	recordCount, err := storage.storageUtils.WritePositionDeletesParquetFile(storage.client.FileSystemPrefix(), existingParquetFilePaths, newParquetFilePaths, keyPgSchemaColumns, fileWriter)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+
+	fileSize, parquetStats, err := storage.uploadParquetFile(tempFilePath, fileKey, storage.storageUtils.PositionDeletesPgSchemaColumns())
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	LogDebug(storage.config, "Position delete file with", recordCount, "record(s) created at:", fileKey)
+
+	return ParquetFile{
+		Uuid:            uuid,
+		Path:            fileKey,
+		Size:            fileSize,
+		RecordCount:     recordCount,
+		Stats:           parquetStats,
+		PositionDeletes: true,
+	}, nil
+}
+
+func (storage *StorageObjectStore) DeleteParquet(parquetFile ParquetFile) (err error) {
+	return storage.client.DeleteObject(parquetFile.Path)
+}
+
+func (storage *StorageObjectStore) DeleteFile(filePath string) (err error) {
+	return storage.client.DeleteObject(filePath)
+}
+
+func (storage *StorageObjectStore) CreateManifest(metadataDirPath string, partitionSpec IcebergPartitionSpec, parquetFile ParquetFile) (manifestFile ManifestFile, err error) {
+	filePath := metadataDirPath + "/" + fmt.Sprintf("%s-m0.avro", parquetFile.Uuid)
+
+	tempFile, err := storage.createTemporaryFile("manifest")
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	defer storage.deleteTemporaryFile(tempFile)
+
+	manifestFile, err = storage.storageUtils.WriteManifestFile(storage.client.FileSystemPrefix(), tempFile.Name(), partitionSpec, parquetFile)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+
+	err = storage.client.WriteObject(filePath, tempFile)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	LogDebug(storage.config, "Manifest file created at:", filePath)
+
+	manifestFile.Path = filePath
+	return manifestFile, nil
+}
+
+func (storage *StorageObjectStore) CreateDeletedRecordsManifest(metadataDirPath string, uuid string, existingManifestFile ManifestFile) (deletedRecsManifestFile ManifestFile, err error) {
+	filePath := metadataDirPath + "/" + fmt.Sprintf("%s-m1.avro", uuid)
+
+	existingManifestContent, err := storage.client.ReadObject(existingManifestFile.Path)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+
+	tempFile, err := storage.createTemporaryFile("manifest")
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	defer storage.deleteTemporaryFile(tempFile)
+
+	deletedRecsManifestFile, err = storage.storageUtils.WriteDeletedRecordsManifestFile(storage.client.FileSystemPrefix(), tempFile.Name(), existingManifestContent)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+
+	err = storage.client.WriteObject(filePath, tempFile)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	LogDebug(storage.config, "Manifest file created at:", filePath)
+
+	return deletedRecsManifestFile, nil
+}
+
+func (storage *StorageObjectStore) CreateManifestList(metadataDirPath string, parquetFileUuid string, manifestListItemsSortedDesc []ManifestListItem) (manifestListFile ManifestListFile, err error) {
+	filePath := metadataDirPath + "/" + fmt.Sprintf("snap-%d-0-%s.avro", manifestListItemsSortedDesc[0].ManifestFile.SnapshotId, parquetFileUuid)
+
+	tempFile, err := storage.createTemporaryFile("manifest")
+	if err != nil {
+		return ManifestListFile{}, err
+	}
+	defer storage.deleteTemporaryFile(tempFile)
+
+	manifestListFile, err = storage.storageUtils.WriteManifestListFile(storage.client.FileSystemPrefix(), tempFile.Name(), manifestListItemsSortedDesc)
+	if err != nil {
+		return ManifestListFile{}, err
+	}
+
+	err = storage.client.WriteObject(filePath, tempFile)
+	if err != nil {
+		return ManifestListFile{}, err
+	}
+	LogDebug(storage.config, "Manifest list file created at:", filePath)
+
+	manifestListFile.Path = filePath
+	return manifestListFile, nil
+}
+
+// Publishes the next metadata version with a conditional write, which fails if a concurrent commit published it first
+func (storage *StorageObjectStore) CreateMetadata(metadataDirPath string, schemasSortedAsc []IcebergSchema, partitionSpec IcebergPartitionSpec, sortOrder IcebergSortOrder, manifestListFilesSortedAsc []ManifestListFile) (metadataFile MetadataFile, err error) {
+	for attempt := 1; ; attempt++ {
+		currentMetadataFile, err := storage.ExistingMetadataFile(metadataDirPath)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		var currentMetadataContent []byte
+		if currentMetadataFile.Version > 0 {
+			currentMetadataContent, err = storage.client.ReadObject(currentMetadataFile.Path)
+			if err != nil {
+				return MetadataFile{}, err
+			}
+		}
+
+		err = storage.storageUtils.ValidateMetadataCommit(metadataDirPath, currentMetadataContent, manifestListFilesSortedAsc)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		tableUuid, err := storage.storageUtils.TableUuid(currentMetadataContent)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		metadataLog, expiredMetadataFilePaths, err := storage.storageUtils.NextMetadataLog(storage.client.FileSystemPrefix(), currentMetadataFile, currentMetadataContent)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		partitionSpecsSortedAsc, err := storage.storageUtils.MetadataPartitionSpecs(currentMetadataContent, partitionSpec)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+
+		metadataFile = MetadataFile{
+			Version: currentMetadataFile.Version + 1,
+			Path:    metadataDirPath + "/" + storage.storageUtils.MetadataFileName(currentMetadataFile.Version+1),
+		}
+		published, err := storage.uploadMetadataFile(metadataFile, tableUuid, metadataLog, schemasSortedAsc, partitionSpecsSortedAsc, partitionSpec, sortOrder, manifestListFilesSortedAsc)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		if !published {
+			if attempt < ICEBERG_METADATA_COMMIT_ATTEMPTS {
+				continue
+			}
+			return MetadataFile{}, &MetadataCommitConflictError{MetadataDirPath: metadataDirPath}
+		}
+		LogDebug(storage.config, "Metadata file created at:", metadataFile.Path)
+
+		err = storage.writeVersionHint(metadataDirPath, metadataFile)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		for _, expiredMetadataFilePath := range expiredMetadataFilePaths {
+			err = storage.DeleteFile(expiredMetadataFilePath)
+			if err != nil {
+				LogWarn(storage.config, "Failed to delete expired metadata file", expiredMetadataFilePath+":", err)
+			}
+		}
+
+		return metadataFile, nil
+	}
+}
+
+// Read (internal) -----------------------------------------------------------------------------------------------------
+
+func (storage *StorageObjectStore) InternalTableMetadata(pgSchemaTable PgSchemaTable) (InternalTableMetadata, error) {
+	internalMetadataContent, err := storage.client.ReadObject(storage.internalTableMetadataFilePath(pgSchemaTable))
+	if err != nil {
+		return InternalTableMetadata{}, err
+	}
+
+	return storage.storageUtils.ParseInternalTableMetadata(internalMetadataContent)
+}
+
+func (storage *StorageObjectStore) SyncJournal() (*SyncJournal, error) {
+	syncJournalContent, err := storage.client.ReadObject(storage.syncJournalFilePath())
+	if err != nil {
+		if IsObjectStoreNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return storage.storageUtils.ParseSyncJournal(syncJournalContent)
+}
+
+func (storage *StorageObjectStore) SyncStatus() (SyncStatus, error) {
+	syncStatusContent, err := storage.client.ReadObject(storage.syncStatusFilePath())
+	if err != nil {
+		if IsObjectStoreNotFoundError(err) {
+			return SyncStatus{}, nil
+		}
+		return SyncStatus{}, err
+	}
+
+	return storage.storageUtils.ParseSyncStatus(syncStatusContent)
+}
+
+func (storage *StorageObjectStore) SyncStatuses() ([]SyncStatus, error) {
+	filePaths, err := storage.ExistingFilePaths(storage.config.StoragePath)
+	if err != nil {
+		return nil, err
+	}
+
+	syncStatuses := []SyncStatus{}
+	for _, filePath := range filePaths {
+		if !strings.HasSuffix(filePath, SYNC_STATUS_FILE_NAME) {
+			continue
+		}
+
+		syncStatusContent, err := storage.client.ReadObject(filePath)
+		if err != nil {
+			return nil, err
+		}
+		syncStatus, err := storage.storageUtils.ParseSyncStatus(syncStatusContent)
+		if err != nil {
+			return nil, err
+		}
+		syncStatuses = append(syncStatuses, syncStatus)
+	}
+
+	return syncStatuses, nil
+}
+
+// Write (internal) ----------------------------------------------------------------------------------------------------
+
+func (storage *StorageObjectStore) WriteInternalTableMetadata(pgSchemaTable PgSchemaTable, internalTableMetadata InternalTableMetadata) error {
+	filePath := storage.internalTableMetadataFilePath(pgSchemaTable)
+
+	tempFile, err := storage.createTemporaryFile("internal-metadata")
+	if err != nil {
+		return err
+	}
+	defer storage.deleteTemporaryFile(tempFile)
+
+	err = storage.storageUtils.WriteInternalTableMetadataFile(tempFile.Name(), internalTableMetadata)
+	if err != nil {
+		return err
+	}
+
+	err = storage.client.WriteObject(filePath, tempFile)
+	if err != nil {
+		return err
+	}
+	LogDebug(storage.config, "Internal metadata file created at:", filePath)
+
+	return nil
+}
+
+func (storage *StorageObjectStore) WriteSyncJournal(syncJournal SyncJournal) error {
+	filePath := storage.syncJournalFilePath()
+
+	tempFile, err := storage.createTemporaryFile("sync-journal")
+	if err != nil {
+		return err
+	}
+	defer storage.deleteTemporaryFile(tempFile)
+
+	err = storage.storageUtils.WriteSyncJournalFile(tempFile.Name(), syncJournal)
+	if err != nil {
+		return err
+	}
+
+	err = storage.client.WriteObject(filePath, tempFile)
+	if err != nil {
+		return err
+	}
+	LogDebug(storage.config, "Sync journal file written at:", filePath)
+
+	return nil
+}
+
+func (storage *StorageObjectStore) DeleteSyncJournal() error {
+	return storage.DeleteFile(storage.syncJournalFilePath())
+}
+
+func (storage *StorageObjectStore) WriteSyncStatus(syncStatus SyncStatus) error {
+	filePath := storage.syncStatusFilePath()
+
+	tempFile, err := storage.createTemporaryFile("sync-status")
+	if err != nil {
+		return err
+	}
+	defer storage.deleteTemporaryFile(tempFile)
+
+	err = storage.storageUtils.WriteSyncStatusFile(tempFile.Name(), syncStatus)
+	if err != nil {
+		return err
+	}
+
+	err = storage.client.WriteObject(filePath, tempFile)
+	if err != nil {
+		return err
+	}
+	LogDebug(storage.config, "Sync status file written at:", filePath)
+
+	return nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Returns false if the metadata version was already published by a concurrent commit
+func (storage *StorageObjectStore) uploadMetadataFile(metadataFile MetadataFile, tableUuid string, metadataLog []MetadataLogEntry, schemasSortedAsc []IcebergSchema, partitionSpecsSortedAsc []IcebergPartitionSpec, partitionSpec IcebergPartitionSpec, sortOrder IcebergSortOrder, manifestListFilesSortedAsc []ManifestListFile) (published bool, err error) {
+	tempFile, err := storage.createTemporaryFile("metadata")
+	if err != nil {
+		return false, err
+	}
+	defer storage.deleteTemporaryFile(tempFile)
+
+	metadataDirPath := strings.TrimSuffix(metadataFile.Path, "/"+storage.storageUtils.MetadataFileName(metadataFile.Version))
+	err = storage.storageUtils.WriteMetadataFile(storage.client.FileSystemPrefix(), tempFile.Name(), metadataDirPath, tableUuid, metadataLog, schemasSortedAsc, partitionSpecsSortedAsc, partitionSpec, sortOrder, manifestListFilesSortedAsc)
+	if err != nil {
+		return false, err
+	}
+
+	published, err = storage.client.WriteObjectIfNotExists(metadataFile.Path, tempFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to upload metadata file: %w", err)
+	}
+
+	return published, nil
+}
+
+func (storage *StorageObjectStore) writeVersionHint(metadataDirPath string, metadataFile MetadataFile) error {
+	tempFile, err := storage.createTemporaryFile("version-hint")
+	if err != nil {
+		return err
+	}
+	defer storage.deleteTemporaryFile(tempFile)
+
+	err = storage.storageUtils.WriteVersionHintFile(tempFile.Name(), metadataFile)
+	if err != nil {
+		return err
+	}
+
+	return storage.client.WriteObject(metadataDirPath+"/"+ICEBERG_VERSION_HINT_FILE_NAME, tempFile)
+}
+
+// Reads the stats from the local file before uploading it, so it isn't downloaded again
+func (storage *StorageObjectStore) uploadParquetFile(tempFilePath string, fileKey string, pgSchemaColumns []PgSchemaColumn) (fileSize int64, parquetStats ParquetFileStats, err error) {
+	fileReader, err := local.NewLocalFileReader(tempFilePath)
+	if err != nil {
+		return 0, ParquetFileStats{}, fmt.Errorf("failed to open Parquet file for reading: %w", err)
+	}
+	parquetStats, err = storage.storageUtils.ReadParquetStats(fileReader, pgSchemaColumns)
+	if err != nil {
+		return 0, ParquetFileStats{}, err
+	}
+
+	file, err := os.Open(tempFilePath)
+	if err != nil {
+		return 0, ParquetFileStats{}, err
+	}
+	defer file.Close()
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return 0, ParquetFileStats{}, fmt.Errorf("failed to get Parquet file info: %w", err)
+	}
+
+	err = storage.client.WriteObject(fileKey, file)
+	if err != nil {
+		return 0, ParquetFileStats{}, err
+	}
+
+	return fileInfo.Size(), parquetStats, nil
+}
+
+// Fails if the table has no metadata
+func (storage *StorageObjectStore) readCurrentMetadataContent(metadataDirPath string) ([]byte, error) {
+	metadataFile, err := storage.ExistingMetadataFile(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+	if metadataFile.Version == 0 {
+		return nil, fmt.Errorf("no Iceberg metadata found in %s", metadataDirPath)
+	}
+
+	return storage.client.ReadObject(metadataFile.Path)
+}
+
+// Object stores without batch deletes delete each object with a separate request, so they're sent in parallel
+func (storage *StorageObjectStore) deleteNestedObjects(prefix string) (err error) {
+	objects, _, err := storage.client.ListObjects(prefix, "")
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		LogDebug(storage.config, "No objects to delete.")
+		return nil
+	}
+
+	objectChan := make(chan ObjectStoreObject)
+	errorChan := make(chan error, len(objects))
+	var waitGroup sync.WaitGroup
+	for range min(OBJECT_STORE_DELETE_CONCURRENCY, len(objects)) {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for object := range objectChan {
+				LogDebug(storage.config, "Object to delete:", object.Key)
+				err := storage.client.DeleteObject(object.Key)
+				if err != nil {
+					errorChan <- err
+				}
+			}
+		}()
+	}
+	for _, object := range objects {
+		objectChan <- object
+	}
+	close(objectChan)
+	waitGroup.Wait()
+	close(errorChan)
+
+	if len(errorChan) > 0 {
+		return fmt.Errorf("failed to delete %d object(s), e.g. %w", len(errorChan), <-errorChan)
+	}
+	LogDebug(storage.config, "Deleted", len(objects), "object(s).")
+
+	return nil
+}
+
+func (storage *StorageObjectStore) internalTableMetadataFilePath(pgSchemaTable PgSchemaTable) string {
+	return storage.tablePrefix(pgSchemaTable.ToIcebergSchemaTable()) + "metadata/" + INTERNAL_METADATA_FILE_NAME
+}
+
+func (storage *StorageObjectStore) syncJournalFilePath() string {
+	return storage.config.StoragePath + "/" + storage.config.Pg.SchemaPrefix + SYNC_JOURNAL_FILE_NAME
+}
+
+func (storage *StorageObjectStore) syncStatusFilePath() string {
+	return storage.config.StoragePath + "/" + storage.config.Pg.SchemaPrefix + SYNC_STATUS_FILE_NAME
+}
+
+func (storage *StorageObjectStore) tablePrefix(schemaTable IcebergSchemaTable, isIcebergSchemaTable ...bool) string {
+	if len(isIcebergSchemaTable) > 0 && isIcebergSchemaTable[0] {
+		return storage.config.StoragePath + "/" + schemaTable.Schema + "/" + schemaTable.Table + "/"
+	}
+
+	return storage.config.StoragePath + "/" + storage.config.Pg.SchemaPrefix + schemaTable.Schema + "/" + schemaTable.Table + "/"
+}
+
+func (storage *StorageObjectStore) temporaryParquetFilePath(uuid string) string {
+	return os.TempDir() + "/bemidb-" + uuid + ".parquet"
+}
+
+func (storage *StorageObjectStore) createTemporaryFile(prefix string) (file *os.File, err error) {
+	tempFile, err := os.CreateTemp("", prefix)
+	PanicIfError(err, nil)
+
+	return tempFile, nil
+}
+
+func (storage *StorageObjectStore) deleteTemporaryFile(file *os.File) {
+	file.Close()
+	os.Remove(file.Name())
+}
+
+// HTTP ----------------------------------------------------------------------------------------------------------------
+
+// Sends object store requests, retrying throttled (429) and failed (5xx) requests with exponential backoff
+type ObjectStoreHttpClient struct {
+	httpClient  *http.Client
+	authorize   func(request *http.Request) error
+	maxAttempts int
+	baseBackoff time.Duration
+}
+
+func NewObjectStoreHttpClient(authorize func(request *http.Request) error) *ObjectStoreHttpClient {
+	return &ObjectStoreHttpClient{
+		httpClient:  &http.Client{},
+		authorize:   authorize,
+		maxAttempts: OBJECT_STORE_MAX_ATTEMPTS,
+		baseBackoff: OBJECT_STORE_BASE_BACKOFF,
+	}
+}
+
+// Creates a new request per attempt, since request bodies can't be re-read. Returns an ObjectStoreError for non-2xx responses
+func (client *ObjectStoreHttpClient) Do(newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		request, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		err = client.authorize(request)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := client.httpClient.Do(request)
+		if err == nil && response.StatusCode < 300 {
+			return response, nil
+		}
+		if err == nil {
+			err = client.responseError(request, response)
+		}
+		if attempt >= client.maxAttempts || !client.isRetryable(err) {
+			return nil, err
+		}
+		time.Sleep(client.backoff(attempt))
+	}
+}
+
+// Request body with the file content from the start, since the HTTP client closes request bodies
+func (client *ObjectStoreHttpClient) FileBody(file *os.File) (body io.ReadCloser, contentLength int64, err error) {
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	if fileInfo.Size() == 0 {
+		return http.NoBody, 0, nil
+	}
+	return io.NopCloser(io.NewSectionReader(file, 0, fileInfo.Size())), fileInfo.Size(), nil
+}
+
+func (client *ObjectStoreHttpClient) responseError(request *http.Request, response *http.Response) error {
+	defer response.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+	return &ObjectStoreError{
+		Method:     request.Method,
+		Path:       request.URL.Path,
+		StatusCode: response.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+	}
+}
+
+func (client *ObjectStoreHttpClient) isRetryable(err error) bool {
+	var objectStoreErr *ObjectStoreError
+	if errors.As(err, &objectStoreErr) {
+		return objectStoreErr.StatusCode == http.StatusTooManyRequests || objectStoreErr.StatusCode >= 500
+	}
+	return true // Network errors
+}
+
+// Full jitter, see https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+func (client *ObjectStoreHttpClient) backoff(attempt int) time.Duration {
+	maxBackoff := min(client.baseBackoff*time.Duration(1<<(attempt-1)), OBJECT_STORE_MAX_BACKOFF)
+	return time.Duration(rand.Int64N(int64(maxBackoff) + 1))
+}
+
+// OAuth access token fetched on first use and refreshed shortly before it expires
+type ObjectStoreAccessToken struct {
+	mutex     sync.Mutex
+	value     string
+	expiresAt time.Time
+	fetch     func() (value string, expiresIn time.Duration, err error)
+}
+
+func (token *ObjectStoreAccessToken) Value() (string, error) {
+	return token.ValueValidFor(OBJECT_STORE_TOKEN_EXPIRY_SKEW)
+}
+
+// Returns the cached access token if it doesn't expire within validFor, otherwise fetches a new one
+func (token *ObjectStoreAccessToken) ValueValidFor(validFor time.Duration) (string, error) {
+	token.mutex.Lock()
+	defer token.mutex.Unlock()
+
+	if token.value != "" && time.Now().Add(validFor).Before(token.expiresAt) {
+		return token.value, nil
+	}
+
+	value, expiresIn, err := token.fetch()
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch access token: %w", err)
+	}
+	token.value = value
+	token.expiresAt = time.Now().Add(expiresIn)
+
+	return token.value, nil
+}
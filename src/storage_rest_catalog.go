@@ -0,0 +1,422 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Resolves tables through an Iceberg REST catalog and commits table metadata to it with optimistic concurrency.
+// Data files, manifests, and internal metadata are still written to the wrapped storage
+type StorageRestCatalog struct {
+	StorageInterface
+	config        *Config
+	storageUtils  *StorageUtils
+	catalogClient *CatalogRestClient
+}
+
+func NewRestCatalogStorage(config *Config, storage StorageInterface) *StorageRestCatalog {
+	return &StorageRestCatalog{
+		StorageInterface: storage,
+		config:           config,
+		storageUtils:     &StorageUtils{config: config},
+		catalogClient:    NewCatalogRestClient(config),
+	}
+}
+
+// Read ----------------------------------------------------------------------------------------------------------------
+
+func (storage *StorageRestCatalog) IcebergSchemas() ([]string, error) {
+	return storage.catalogClient.ListNamespaces()
+}
+
+func (storage *StorageRestCatalog) IcebergSchemaTables() (Set[IcebergSchemaTable], error) {
+	icebergSchemaTables := make(Set[IcebergSchemaTable])
+	icebergSchemas, err := storage.IcebergSchemas()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, icebergSchema := range icebergSchemas {
+		tables, err := storage.catalogClient.ListTables(icebergSchema)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, table := range tables {
+			icebergSchemaTables.Add(IcebergSchemaTable{Schema: icebergSchema, Table: table})
+		}
+	}
+
+	return icebergSchemaTables, nil
+}
+
+func (storage *StorageRestCatalog) IcebergMetadataFilePath(icebergSchemaTable IcebergSchemaTable) (string, error) {
+	metadataPath, _, err := storage.IcebergMetadata(icebergSchemaTable)
+	return metadataPath, err
+}
+
+func (storage *StorageRestCatalog) IcebergMetadata(icebergSchemaTable IcebergSchemaTable) (string, []byte, error) {
+	table, err := storage.catalogClient.LoadTable(icebergSchemaTable)
+	if err != nil {
+		return "", nil, err
+	}
+	if table == nil {
+		return "", nil, fmt.Errorf("no Iceberg metadata found for %s", icebergSchemaTable.String())
+	}
+
+	return storage.readableLocation(table.MetadataLocation), table.Metadata, nil
+}
+
+func (storage *StorageRestCatalog) IcebergTableFields(icebergSchemaTable IcebergSchemaTable) ([]IcebergTableField, error) {
+	_, metadataContent, err := storage.IcebergMetadata(icebergSchemaTable)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageUtils.ParseIcebergTableFields(metadataContent)
+}
+
+func (storage *StorageRestCatalog) ExistingMetadataFile(metadataDirPath string) (MetadataFile, error) {
+	table, err := storage.catalogClient.LoadTable(storage.icebergSchemaTable(metadataDirPath))
+	if err != nil {
+		return MetadataFile{}, err
+	}
+	if table == nil {
+		return MetadataFile{}, nil
+	}
+
+	return storage.metadataFile(table)
+}
+
+func (storage *StorageRestCatalog) ExistingManifestListFiles(metadataDirPath string) ([]ManifestListFile, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageUtils.ParseManifestListFiles(storage.fileSystemPrefix(), metadataContent)
+}
+
+func (storage *StorageRestCatalog) ExistingSchemas(metadataDirPath string) ([]IcebergSchema, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageUtils.ParseIcebergSchemas(metadataContent)
+}
+
+func (storage *StorageRestCatalog) ExistingPartitionSpec(metadataDirPath string) (IcebergPartitionSpec, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
+	if err != nil {
+		return IcebergPartitionSpec{}, err
+	}
+
+	return storage.storageUtils.ParseIcebergPartitionSpec(metadataContent)
+}
+
+func (storage *StorageRestCatalog) ExistingPartitionSpecs(metadataDirPath string) ([]IcebergPartitionSpec, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageUtils.ParseIcebergPartitionSpecs(metadataContent)
+}
+
+func (storage *StorageRestCatalog) ExistingSortOrder(metadataDirPath string) (IcebergSortOrder, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
+	if err != nil {
+		return IcebergSortOrder{}, err
+	}
+
+	return storage.storageUtils.ParseIcebergSortOrder(metadataContent)
+}
+
+// Write ---------------------------------------------------------------------------------------------------------------
+
+func (storage *StorageRestCatalog) DeleteSchema(schema string) error {
+	tables, err := storage.catalogClient.ListTables(schema)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		err = storage.catalogClient.DropTable(IcebergSchemaTable{Schema: schema, Table: table})
+		if err != nil {
+			return err
+		}
+	}
+	err = storage.catalogClient.DropNamespace(schema)
+	if err != nil {
+		return err
+	}
+
+	return storage.StorageInterface.DeleteSchema(schema)
+}
+
+func (storage *StorageRestCatalog) DeleteSchemaTable(schemaTable IcebergSchemaTable) error {
+	err := storage.catalogClient.DropTable(IcebergSchemaTable{Schema: storage.config.Pg.SchemaPrefix + schemaTable.Schema, Table: schemaTable.Table})
+	if err != nil {
+		return err
+	}
+
+	return storage.StorageInterface.DeleteSchemaTable(schemaTable)
+}
+
+// Commits the new snapshots, schemas, and sort order as metadata updates that require the current snapshot to be unchanged,
+// and retries if a concurrent commit changed it but kept the snapshots the new metadata is based on
+func (storage *StorageRestCatalog) CreateMetadata(metadataDirPath string, schemasSortedAsc []IcebergSchema, partitionSpec IcebergPartitionSpec, sortOrder IcebergSortOrder, manifestListFilesSortedAsc []ManifestListFile) (MetadataFile, error) {
+	icebergSchemaTable := storage.icebergSchemaTable(metadataDirPath)
+
+	for attempt := 1; ; attempt++ {
+		table, err := storage.catalogClient.LoadTable(icebergSchemaTable)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		if table == nil {
+			return storage.registerTable(icebergSchemaTable, metadataDirPath, schemasSortedAsc, partitionSpec, sortOrder, manifestListFilesSortedAsc)
+		}
+
+		err = storage.storageUtils.ValidateMetadataCommit(metadataDirPath, table.Metadata, manifestListFilesSortedAsc)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+
+		// Partition spec IDs are assigned by the catalog, while manifests reference the spec IDs assigned by BemiDB, so the table is registered again
+		currentPartitionSpec, err := storage.storageUtils.ParseIcebergPartitionSpec(table.Metadata)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		if currentPartitionSpec.SpecId != partitionSpec.SpecId || currentPartitionSpec.String() != partitionSpec.String() {
+			err = storage.catalogClient.DropTable(icebergSchemaTable)
+			if err != nil {
+				return MetadataFile{}, err
+			}
+			return storage.registerTable(icebergSchemaTable, metadataDirPath, schemasSortedAsc, partitionSpec, sortOrder, manifestListFilesSortedAsc)
+		}
+
+		requirements, updates, err := storage.metadataUpdates(table.Metadata, schemasSortedAsc, sortOrder, manifestListFilesSortedAsc)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		committedTable, err := storage.catalogClient.CommitTable(icebergSchemaTable, requirements, updates)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		if committedTable == nil {
+			if attempt < ICEBERG_METADATA_COMMIT_ATTEMPTS {
+				continue
+			}
+			return MetadataFile{}, &MetadataCommitConflictError{MetadataDirPath: metadataDirPath}
+		}
+		LogDebug(storage.config, "Metadata committed to the Iceberg REST catalog at:", committedTable.MetadataLocation)
+
+		return storage.metadataFile(committedTable)
+	}
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Registers a new table with a metadata file written to the storage, which keeps the assigned field IDs unlike creating the table in the catalog
+func (storage *StorageRestCatalog) registerTable(icebergSchemaTable IcebergSchemaTable, metadataDirPath string, schemasSortedAsc []IcebergSchema, partitionSpec IcebergPartitionSpec, sortOrder IcebergSortOrder, manifestListFilesSortedAsc []ManifestListFile) (MetadataFile, error) {
+	metadataFile, err := storage.StorageInterface.CreateMetadata(metadataDirPath, schemasSortedAsc, partitionSpec, sortOrder, manifestListFilesSortedAsc)
+	if err != nil {
+		return MetadataFile{}, err
+	}
+
+	err = storage.catalogClient.CreateNamespace(icebergSchemaTable.Schema)
+	if err != nil {
+		return MetadataFile{}, err
+	}
+	table, err := storage.catalogClient.RegisterTable(icebergSchemaTable, storage.fileSystemPrefix()+metadataFile.Path)
+	if err != nil {
+		return MetadataFile{}, err
+	}
+	if table == nil {
+		return MetadataFile{}, &MetadataCommitConflictError{MetadataDirPath: metadataDirPath} // Registered by a concurrent commit
+	}
+	LogDebug(storage.config, "Table registered in the Iceberg REST catalog:", icebergSchemaTable.String())
+
+	return storage.metadataFile(table)
+}
+
+// Returns the requirements and the updates changing the current table metadata in the catalog to the new one
+func (storage *StorageRestCatalog) metadataUpdates(currentMetadataContent []byte, schemasSortedAsc []IcebergSchema, sortOrder IcebergSortOrder, manifestListFilesSortedAsc []ManifestListFile) (requirements []map[string]interface{}, updates []map[string]interface{}, err error) {
+	var currentMetadata struct {
+		TableUuid       string `json:"table-uuid"`
+		CurrentSchemaId int    `json:"current-schema-id"`
+		Schemas         []struct {
+			SchemaId int `json:"schema-id"`
+		} `json:"schemas"`
+		Properties map[string]string `json:"properties"`
+		Snapshots  []struct {
+			SnapshotId int64 `json:"snapshot-id"`
+		} `json:"snapshots"`
+		Refs map[string]struct {
+			SnapshotId int64  `json:"snapshot-id"`
+			Type       string `json:"type"`
+		} `json:"refs"`
+	}
+	err = json.Unmarshal(currentMetadataContent, &currentMetadata)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var currentSnapshotId *int64
+	if mainRef, found := currentMetadata.Refs[ICEBERG_SNAPSHOT_REF_MAIN]; found {
+		currentSnapshotId = &mainRef.SnapshotId
+	}
+	requirements = []map[string]interface{}{
+		{"type": "assert-table-uuid", "uuid": currentMetadata.TableUuid},
+		{"type": "assert-ref-snapshot-id", "ref": ICEBERG_SNAPSHOT_REF_MAIN, "snapshot-id": currentSnapshotId},
+	}
+	updates = []map[string]interface{}{}
+
+	currentSchemaIds := make(Set[int])
+	for _, schema := range currentMetadata.Schemas {
+		currentSchemaIds.Add(schema.SchemaId)
+	}
+	addedSchema := false
+	for i, schema := range storage.storageUtils.MetadataSchemas(schemasSortedAsc) {
+		if !currentSchemaIds.Contains(schemasSortedAsc[i].SchemaId) {
+			updates = append(updates, map[string]interface{}{"action": "add-schema", "schema": schema, "last-column-id": LastIcebergColumnId(schemasSortedAsc)})
+			addedSchema = true
+		}
+	}
+	if addedSchema {
+		updates = append(updates, map[string]interface{}{"action": "set-current-schema", "schema-id": -1}) // The last added schema
+	} else if currentSchemaId := schemasSortedAsc[len(schemasSortedAsc)-1].SchemaId; currentSchemaId != currentMetadata.CurrentSchemaId {
+		updates = append(updates, map[string]interface{}{"action": "set-current-schema", "schema-id": currentSchemaId})
+	}
+
+	propertyUpdates := map[string]string{}
+	for key, value := range storage.storageUtils.MetadataProperties(schemasSortedAsc) {
+		if currentMetadata.Properties[key] != value {
+			propertyUpdates[key] = value
+		}
+	}
+	if len(propertyUpdates) > 0 {
+		updates = append(updates, map[string]interface{}{"action": "set-properties", "updates": propertyUpdates})
+	}
+
+	currentSortOrder, err := storage.storageUtils.ParseIcebergSortOrder(currentMetadataContent)
+	if err != nil {
+		return nil, nil, err
+	}
+	if currentSortOrder.String() != sortOrder.String() {
+		if sortOrder.IsSorted() {
+			sortOrderMaps := sortOrder.ToMetadataMaps()
+			updates = append(updates,
+				map[string]interface{}{"action": "add-sort-order", "sort-order": sortOrderMaps[len(sortOrderMaps)-1]},
+				map[string]interface{}{"action": "set-default-sort-order", "sort-order-id": -1}, // The last added sort order
+			)
+		} else {
+			updates = append(updates, map[string]interface{}{"action": "set-default-sort-order", "sort-order-id": ICEBERG_SORT_ORDER_ID_UNSORTED})
+		}
+	}
+
+	currentSnapshotIds := make(Set[int64])
+	for _, snapshot := range currentMetadata.Snapshots {
+		currentSnapshotIds.Add(snapshot.SnapshotId)
+	}
+	snapshots, _ := storage.storageUtils.MetadataSnapshots(storage.fileSystemPrefix(), manifestListFilesSortedAsc)
+	newSnapshotIds := make(Set[int64])
+	for i, snapshot := range snapshots {
+		snapshotId := manifestListFilesSortedAsc[i].SnapshotId
+		newSnapshotIds.Add(snapshotId)
+		if !currentSnapshotIds.Contains(snapshotId) {
+			updates = append(updates, map[string]interface{}{"action": "add-snapshot", "snapshot": snapshot})
+		}
+	}
+	lastSnapshotId := manifestListFilesSortedAsc[len(manifestListFilesSortedAsc)-1].SnapshotId
+	if currentSnapshotId == nil || *currentSnapshotId != lastSnapshotId {
+		updates = append(updates, map[string]interface{}{"action": "set-snapshot-ref", "ref-name": ICEBERG_SNAPSHOT_REF_MAIN, "type": ICEBERG_SNAPSHOT_REF_BRANCH, "snapshot-id": lastSnapshotId})
+	}
+
+	// Tags and branches other than main
+	newRefs := make(Set[string])
+	for _, manifestListFile := range manifestListFilesSortedAsc {
+		for _, ref := range manifestListFile.Refs {
+			newRefs.Add(ref.Name)
+			if currentRef, found := currentMetadata.Refs[ref.Name]; !found || currentRef.SnapshotId != manifestListFile.SnapshotId || currentRef.Type != ref.Type {
+				updates = append(updates, map[string]interface{}{"action": "set-snapshot-ref", "ref-name": ref.Name, "type": ref.Type, "snapshot-id": manifestListFile.SnapshotId})
+			}
+		}
+	}
+	for refName := range currentMetadata.Refs {
+		if refName != ICEBERG_SNAPSHOT_REF_MAIN && !newRefs.Contains(refName) {
+			updates = append(updates, map[string]interface{}{"action": "remove-snapshot-ref", "ref-name": refName})
+		}
+	}
+
+	// Removed after moving the refs, so they never reference a removed snapshot
+	removedSnapshotIds := []int64{}
+	for _, snapshot := range currentMetadata.Snapshots {
+		if !newSnapshotIds.Contains(snapshot.SnapshotId) {
+			removedSnapshotIds = append(removedSnapshotIds, snapshot.SnapshotId)
+		}
+	}
+	if len(removedSnapshotIds) > 0 {
+		updates = append(updates, map[string]interface{}{"action": "remove-snapshots", "snapshot-ids": removedSnapshotIds})
+	}
+
+	return requirements, updates, nil
+}
+
+// Fails if the table doesn't exist in the catalog
+func (storage *StorageRestCatalog) readCurrentMetadataContent(metadataDirPath string) ([]byte, error) {
+	icebergSchemaTable := storage.icebergSchemaTable(metadataDirPath)
+	table, err := storage.catalogClient.LoadTable(icebergSchemaTable)
+	if err != nil {
+		return nil, err
+	}
+	if table == nil {
+		return nil, fmt.Errorf("no Iceberg metadata found for %s in the Iceberg REST catalog", icebergSchemaTable.String())
+	}
+
+	return table.Metadata, nil
+}
+
+// The catalog names metadata files itself, so the version is derived from the metadata log
+func (storage *StorageRestCatalog) metadataFile(table *CatalogRestTable) (MetadataFile, error) {
+	var metadataJson struct {
+		MetadataLog []MetadataLogEntry `json:"metadata-log"`
+	}
+	err := json.Unmarshal(table.Metadata, &metadataJson)
+	if err != nil {
+		return MetadataFile{}, err
+	}
+
+	return MetadataFile{
+		Version: int64(len(metadataJson.MetadataLog) + 1),
+		Path:    strings.TrimPrefix(storage.readableLocation(table.MetadataLocation), storage.fileSystemPrefix()),
+	}, nil
+}
+
+// Metadata directories are named <schema>/<table>/metadata, see CreateMetadataDir
+func (storage *StorageRestCatalog) icebergSchemaTable(metadataDirPath string) IcebergSchemaTable {
+	tableDirPath := path.Dir(metadataDirPath)
+	return IcebergSchemaTable{Schema: path.Base(path.Dir(tableDirPath)), Table: path.Base(tableDirPath)}
+}
+
+// Local metadata locations can be file URIs, which DuckDB doesn't support
+func (storage *StorageRestCatalog) readableLocation(location string) string {
+	if storage.config.StorageType == STORAGE_TYPE_LOCAL {
+		return strings.TrimPrefix(strings.TrimPrefix(location, "file://"), "file:")
+	}
+	return location
+}
+
+func (storage *StorageRestCatalog) fileSystemPrefix() string {
+	switch storage.config.StorageType {
+	case STORAGE_TYPE_S3:
+		return "s3://" + storage.config.Aws.S3Bucket + "/"
+	case STORAGE_TYPE_GCS:
+		return "gs://" + storage.config.Gcs.Bucket + "/"
+	case STORAGE_TYPE_AZURE:
+		return "az://" + storage.config.Azure.Container + "/"
+	}
+	return ""
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestStorageRestCatalog(t *testing.T) {
+	restCatalog := newTestRestCatalog(t)
+	config := loadTestConfig()
+	config.Catalog.Type = CATALOG_TYPE_REST
+	config.Catalog.Uri = restCatalog.server.URL
+	config.Catalog.Token = "secret"
+	icebergWriter := NewIcebergWriter(config)
+	icebergReader := NewIcebergReader(config)
+
+	t.Cleanup(func() {
+		icebergWriter.storage.DeleteSchema(TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema)
+	})
+
+	t.Run("Registers a new table in the catalog", func(t *testing.T) {
+		icebergWriter.DeleteSchemaTable(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		icebergWriter.Write(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, MAX_WRITE_PARQUET_PAYLOAD_SIZE, createTestLoadRows(TEST_ICEBERG_WRITER_INITIAL_ROWS))
+
+		testManifestListFiles(t, icebergWriter,
+			ManifestListFile{SequenceNumber: 1, Operation: "append", AddedDataFiles: 1, AddedRecords: 2},
+		)
+		icebergSchemaTables, err := icebergReader.SchemaTables()
+		if err != nil {
+			t.Fatalf("Error reading tables: %v", err)
+		}
+		if !icebergSchemaTables.Contains(TEST_ICEBERG_WRITER_SCHEMA_TABLE) {
+			t.Errorf("Expected the catalog to have table %s, got %v", TEST_ICEBERG_WRITER_SCHEMA_TABLE.String(), icebergSchemaTables.Values())
+		}
+		metadataFilePath, err := icebergReader.MetadataFilePath(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		if err != nil {
+			t.Fatalf("Error reading the metadata file path: %v", err)
+		}
+		if metadataFilePath != restCatalog.table(TEST_ICEBERG_WRITER_SCHEMA_TABLE).MetadataLocation {
+			t.Errorf("Expected the metadata file path from the catalog, got %s", metadataFilePath)
+		}
+	})
+
+	t.Run("Commits an incremental sync as metadata updates", func(t *testing.T) {
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
+			{"3", "Jane"},
+		}))
+
+		testManifestListFiles(t, icebergWriter,
+			ManifestListFile{SequenceNumber: 1, Operation: "append", AddedDataFiles: 1, AddedRecords: 2},
+			ManifestListFile{SequenceNumber: 2, Operation: "append", AddedDataFiles: 1, AddedRecords: 1},
+		)
+		committedUpdateActions := restCatalog.committedUpdateActions()
+		if !slices.Equal(committedUpdateActions, []string{"add-snapshot", "set-snapshot-ref"}) {
+			t.Errorf("Expected the snapshot to be added and set as the main branch, got %v", committedUpdateActions)
+		}
+	})
+
+	t.Run("Retries a commit rejected because of a concurrent commit", func(t *testing.T) {
+		restCatalog.rejectCommits(1)
+
+		icebergWriter.WriteIncrementally(TEST_ICEBERG_WRITER_SCHEMA_TABLE, TEST_ICEBERG_WRITER_PG_SCHEMA_COLUMNS, IcebergSortOrder{}, 10, createTestLoadRows([][]string{
+			{"4", "Alice"},
+		}))
+
+		metadataDirPath := icebergWriter.storage.CreateMetadataDir(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		manifestListFiles, err := icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+		if err != nil {
+			t.Fatalf("Error reading manifest list files: %v", err)
+		}
+		if len(manifestListFiles) != 3 {
+			t.Errorf("Expected 3 snapshots, got %d", len(manifestListFiles))
+		}
+	})
+
+	t.Run("Keeps the attnums of the columns in the table properties", func(t *testing.T) {
+		metadataDirPath := icebergWriter.storage.CreateMetadataDir(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+
+		schemas, err := icebergWriter.storage.ExistingSchemas(metadataDirPath)
+
+		if err != nil {
+			t.Fatalf("Error reading schemas: %v", err)
+		}
+		expectedFieldIdsByAttnum := map[int]int{1: 1, 2: 2}
+		if !maps.Equal(schemas[len(schemas)-1].FieldIdsByAttnum, expectedFieldIdsByAttnum) {
+			t.Errorf("Expected field IDs by attnum %v, got %v", expectedFieldIdsByAttnum, schemas[len(schemas)-1].FieldIdsByAttnum)
+		}
+	})
+
+	t.Run("Returns a conflict error if the catalog keeps rejecting a commit", func(t *testing.T) {
+		metadataDirPath := icebergWriter.storage.CreateMetadataDir(TEST_ICEBERG_WRITER_SCHEMA_TABLE)
+		schemas, _ := icebergWriter.storage.ExistingSchemas(metadataDirPath)
+		manifestListFiles, _ := icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+		restCatalog.rejectCommits(ICEBERG_METADATA_COMMIT_ATTEMPTS)
+
+		_, err := icebergWriter.storage.CreateMetadata(metadataDirPath, schemas, IcebergPartitionSpec{}, IcebergSortOrder{}, manifestListFiles[1:])
+
+		var conflictErr *MetadataCommitConflictError
+		if !errors.As(err, &conflictErr) {
+			t.Errorf("Expected a commit conflict error, got %v", err)
+		}
+	})
+
+	t.Run("Drops the tables and the namespace from the catalog", func(t *testing.T) {
+		err := icebergWriter.storage.DeleteSchema(TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema)
+		if err != nil {
+			t.Fatalf("Error deleting the schema: %v", err)
+		}
+
+		icebergSchemas, err := icebergReader.Schemas()
+		if err != nil {
+			t.Fatalf("Error reading schemas: %v", err)
+		}
+		if slices.Contains(icebergSchemas, TEST_ICEBERG_WRITER_SCHEMA_TABLE.Schema) {
+			t.Errorf("Expected the namespace to be dropped, got %v", icebergSchemas)
+		}
+	})
+}
+
+// In-memory stand-in of an Iceberg REST catalog which writes the committed metadata files to a temporary directory
+type testRestCatalog struct {
+	server          *httptest.Server
+	metadataDirPath string
+
+	mutex                   sync.Mutex
+	namespaces              Set[string]
+	tables                  map[IcebergSchemaTable]*CatalogRestTable
+	rejectedCommitCount     int
+	lastCommitUpdateActions []string
+}
+
+func newTestRestCatalog(t *testing.T) *testRestCatalog {
+	restCatalog := &testRestCatalog{
+		metadataDirPath: t.TempDir(),
+		namespaces:      make(Set[string]),
+		tables:          map[IcebergSchemaTable]*CatalogRestTable{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/config", func(writer http.ResponseWriter, request *http.Request) {
+		testWriteJson(writer, http.StatusOK, map[string]interface{}{"defaults": map[string]string{}, "overrides": map[string]string{"prefix": "warehouse"}})
+	})
+	mux.HandleFunc("GET /v1/warehouse/namespaces", restCatalog.listNamespaces)
+	mux.HandleFunc("POST /v1/warehouse/namespaces", restCatalog.createNamespace)
+	mux.HandleFunc("DELETE /v1/warehouse/namespaces/{namespace}", restCatalog.dropNamespace)
+	mux.HandleFunc("GET /v1/warehouse/namespaces/{namespace}/tables", restCatalog.listTables)
+	mux.HandleFunc("POST /v1/warehouse/namespaces/{namespace}/register", restCatalog.registerTable)
+	mux.HandleFunc("GET /v1/warehouse/namespaces/{namespace}/tables/{table}", restCatalog.loadTable)
+	mux.HandleFunc("POST /v1/warehouse/namespaces/{namespace}/tables/{table}", restCatalog.commitTable)
+	mux.HandleFunc("DELETE /v1/warehouse/namespaces/{namespace}/tables/{table}", restCatalog.dropTable)
+
+	restCatalog.server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Header.Get("Authorization") != "Bearer secret" {
+			testWriteJson(writer, http.StatusUnauthorized, map[string]CatalogErrorModel{"error": {Message: "Invalid token", Type: CATALOG_ERROR_TYPE_NOT_AUTHORIZED, Code: http.StatusUnauthorized}})
+			return
+		}
+		restCatalog.mutex.Lock()
+		defer restCatalog.mutex.Unlock()
+		mux.ServeHTTP(writer, request)
+	}))
+	t.Cleanup(restCatalog.server.Close)
+
+	return restCatalog
+}
+
+func (restCatalog *testRestCatalog) table(icebergSchemaTable IcebergSchemaTable) *CatalogRestTable {
+	restCatalog.mutex.Lock()
+	defer restCatalog.mutex.Unlock()
+	return restCatalog.tables[icebergSchemaTable]
+}
+
+func (restCatalog *testRestCatalog) rejectCommits(count int) {
+	restCatalog.mutex.Lock()
+	defer restCatalog.mutex.Unlock()
+	restCatalog.rejectedCommitCount = count
+}
+
+func (restCatalog *testRestCatalog) committedUpdateActions() []string {
+	restCatalog.mutex.Lock()
+	defer restCatalog.mutex.Unlock()
+	return restCatalog.lastCommitUpdateActions
+}
+
+func (restCatalog *testRestCatalog) listNamespaces(writer http.ResponseWriter, request *http.Request) {
+	namespaces := [][]string{}
+	for _, namespace := range restCatalog.namespaces.Values() {
+		namespaces = append(namespaces, []string{namespace})
+	}
+	testWriteJson(writer, http.StatusOK, map[string]interface{}{"namespaces": namespaces})
+}
+
+func (restCatalog *testRestCatalog) createNamespace(writer http.ResponseWriter, request *http.Request) {
+	var requestBody struct {
+		Namespace []string `json:"namespace"`
+	}
+	json.NewDecoder(request.Body).Decode(&requestBody)
+	if restCatalog.namespaces.Contains(requestBody.Namespace[0]) {
+		testWriteCatalogError(writer, http.StatusConflict, "AlreadyExistsException")
+		return
+	}
+	restCatalog.namespaces.Add(requestBody.Namespace[0])
+	testWriteJson(writer, http.StatusOK, map[string]interface{}{"namespace": requestBody.Namespace})
+}
+
+func (restCatalog *testRestCatalog) dropNamespace(writer http.ResponseWriter, request *http.Request) {
+	if !restCatalog.namespaces.Contains(request.PathValue("namespace")) {
+		testWriteCatalogError(writer, http.StatusNotFound, CATALOG_ERROR_TYPE_NO_SUCH_NAMESPACE)
+		return
+	}
+	delete(restCatalog.namespaces, request.PathValue("namespace"))
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (restCatalog *testRestCatalog) listTables(writer http.ResponseWriter, request *http.Request) {
+	identifiers := []CatalogTableIdentifier{}
+	for icebergSchemaTable := range restCatalog.tables {
+		if icebergSchemaTable.Schema == request.PathValue("namespace") {
+			identifiers = append(identifiers, CatalogTableIdentifier{Namespace: []string{icebergSchemaTable.Schema}, Name: icebergSchemaTable.Table})
+		}
+	}
+	testWriteJson(writer, http.StatusOK, map[string]interface{}{"identifiers": identifiers})
+}
+
+func (restCatalog *testRestCatalog) registerTable(writer http.ResponseWriter, request *http.Request) {
+	var requestBody struct {
+		Name             string `json:"name"`
+		MetadataLocation string `json:"metadata-location"`
+	}
+	json.NewDecoder(request.Body).Decode(&requestBody)
+	icebergSchemaTable := IcebergSchemaTable{Schema: request.PathValue("namespace"), Table: requestBody.Name}
+	if restCatalog.tables[icebergSchemaTable] != nil {
+		testWriteCatalogError(writer, http.StatusConflict, "AlreadyExistsException")
+		return
+	}
+
+	metadataContent, err := os.ReadFile(requestBody.MetadataLocation)
+	if err != nil {
+		testWriteCatalogError(writer, http.StatusBadRequest, CATALOG_ERROR_TYPE_BAD_REQUEST)
+		return
+	}
+	restCatalog.tables[icebergSchemaTable] = &CatalogRestTable{MetadataLocation: requestBody.MetadataLocation, Metadata: metadataContent}
+	testWriteJson(writer, http.StatusOK, restCatalog.tables[icebergSchemaTable])
+}
+
+func (restCatalog *testRestCatalog) loadTable(writer http.ResponseWriter, request *http.Request) {
+	table := restCatalog.tables[IcebergSchemaTable{Schema: request.PathValue("namespace"), Table: request.PathValue("table")}]
+	if table == nil {
+		testWriteCatalogError(writer, http.StatusNotFound, CATALOG_ERROR_TYPE_NO_SUCH_TABLE)
+		return
+	}
+	testWriteJson(writer, http.StatusOK, table)
+}
+
+func (restCatalog *testRestCatalog) dropTable(writer http.ResponseWriter, request *http.Request) {
+	icebergSchemaTable := IcebergSchemaTable{Schema: request.PathValue("namespace"), Table: request.PathValue("table")}
+	if restCatalog.tables[icebergSchemaTable] == nil {
+		testWriteCatalogError(writer, http.StatusNotFound, CATALOG_ERROR_TYPE_NO_SUCH_TABLE)
+		return
+	}
+	delete(restCatalog.tables, icebergSchemaTable)
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// Checks the requirements and applies the updates sent by StorageRestCatalog
+func (restCatalog *testRestCatalog) commitTable(writer http.ResponseWriter, request *http.Request) {
+	icebergSchemaTable := IcebergSchemaTable{Schema: request.PathValue("namespace"), Table: request.PathValue("table")}
+	table := restCatalog.tables[icebergSchemaTable]
+	if table == nil {
+		testWriteCatalogError(writer, http.StatusNotFound, CATALOG_ERROR_TYPE_NO_SUCH_TABLE)
+		return
+	}
+	if restCatalog.rejectedCommitCount > 0 {
+		restCatalog.rejectedCommitCount--
+		testWriteCatalogError(writer, http.StatusConflict, "CommitFailedException")
+		return
+	}
+
+	var requestBody struct {
+		Requirements []map[string]interface{} `json:"requirements"`
+		Updates      []map[string]interface{} `json:"updates"`
+	}
+	// Keeps snapshot IDs as exact numbers
+	requestDecoder := json.NewDecoder(request.Body)
+	requestDecoder.UseNumber()
+	requestDecoder.Decode(&requestBody)
+	var metadata map[string]interface{}
+	metadataDecoder := json.NewDecoder(bytes.NewReader(table.Metadata))
+	metadataDecoder.UseNumber()
+	metadataDecoder.Decode(&metadata)
+
+	refs := metadata["refs"].(map[string]interface{})
+	for _, requirement := range requestBody.Requirements {
+		switch requirement["type"] {
+		case "assert-table-uuid":
+			if requirement["uuid"] != metadata["table-uuid"] {
+				testWriteCatalogError(writer, http.StatusConflict, "CommitFailedException")
+				return
+			}
+		case "assert-ref-snapshot-id":
+			mainRef, found := refs["main"].(map[string]interface{})
+			if !found && requirement["snapshot-id"] != nil || found && mainRef["snapshot-id"] != requirement["snapshot-id"] {
+				testWriteCatalogError(writer, http.StatusConflict, "CommitFailedException")
+				return
+			}
+		}
+	}
+
+	restCatalog.lastCommitUpdateActions = []string{}
+	for _, update := range requestBody.Updates {
+		restCatalog.lastCommitUpdateActions = append(restCatalog.lastCommitUpdateActions, update["action"].(string))
+		switch update["action"] {
+		case "add-schema":
+			metadata["schemas"] = append(metadata["schemas"].([]interface{}), update["schema"])
+		case "set-current-schema":
+			schemas := metadata["schemas"].([]interface{})
+			metadata["current-schema-id"] = schemas[len(schemas)-1].(map[string]interface{})["schema-id"]
+		case "set-properties":
+			properties, _ := metadata["properties"].(map[string]interface{})
+			if properties == nil {
+				properties = map[string]interface{}{}
+			}
+			for key, value := range update["updates"].(map[string]interface{}) {
+				properties[key] = value
+			}
+			metadata["properties"] = properties
+		case "add-snapshot":
+			snapshot := update["snapshot"].(map[string]interface{})
+			metadata["snapshots"] = append(metadata["snapshots"].([]interface{}), snapshot)
+			metadata["last-sequence-number"] = snapshot["sequence-number"]
+		case "set-snapshot-ref":
+			refs[update["ref-name"].(string)] = map[string]interface{}{"snapshot-id": update["snapshot-id"], "type": update["type"]}
+			if update["ref-name"] == "main" {
+				metadata["current-snapshot-id"] = update["snapshot-id"]
+			}
+		case "remove-snapshot-ref":
+			delete(refs, update["ref-name"].(string))
+		case "remove-snapshots":
+			metadata["snapshots"] = slices.DeleteFunc(metadata["snapshots"].([]interface{}), func(snapshot interface{}) bool {
+				return slices.Contains(update["snapshot-ids"].([]interface{}), snapshot.(map[string]interface{})["snapshot-id"])
+			})
+		}
+	}
+	metadata["metadata-log"] = append(metadata["metadata-log"].([]interface{}), map[string]interface{}{"metadata-file": table.MetadataLocation})
+
+	metadataContent, _ := json.Marshal(metadata)
+	metadataLocation := filepath.Join(restCatalog.metadataDirPath, uuid.New().String()+".metadata.json")
+	os.WriteFile(metadataLocation, metadataContent, 0644)
+	restCatalog.tables[icebergSchemaTable] = &CatalogRestTable{MetadataLocation: "file://" + metadataLocation, Metadata: metadataContent}
+	testWriteJson(writer, http.StatusOK, restCatalog.tables[icebergSchemaTable])
+}
+
+func testWriteCatalogError(writer http.ResponseWriter, code int, errorType string) {
+	testWriteJson(writer, code, map[string]CatalogErrorModel{"error": {Message: strings.TrimSuffix(errorType, "Exception"), Type: errorType, Code: code}})
+}
+
+func testWriteJson(writer http.ResponseWriter, code int, response interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(code)
+	json.NewEncoder(writer).Encode(response)
+}
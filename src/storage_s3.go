@@ -2,21 +2,29 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	awsConfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"github.com/google/uuid"
 	"github.com/xitongsys/parquet-go-source/s3v2"
 )
 
+const MAX_S3_DELETE_OBJECTS = 1000 // Max number of keys in a DeleteObjects request
+
 type StorageS3 struct {
 	s3Client     *s3.Client
 	config       *Config
@@ -26,6 +34,13 @@ type StorageS3 struct {
 func NewS3Storage(config *Config) *StorageS3 {
 	var awsConfigOptions = []func(*awsConfig.LoadOptions) error{
 		awsConfig.WithRegion(config.Aws.Region),
+		awsConfig.WithRetryer(func() aws.Retryer {
+			return retry.NewStandard(func(options *retry.StandardOptions) {
+				options.MaxAttempts = config.Aws.S3MaxAttempts
+				options.MaxBackoff = config.Aws.S3MaxBackoff
+				options.RateLimiter = ratelimit.None // Don't fail requests when many of them were retried, e.g. while throttled
+			})
+		}),
 	}
 
 	if config.Aws.AccessKeyId != "" && config.Aws.SecretAccessKey != "" {
@@ -40,8 +55,15 @@ func NewS3Storage(config *Config) *StorageS3 {
 	loadedAwsConfig, err := awsConfig.LoadDefaultConfig(context.Background(), awsConfigOptions...)
 	PanicIfError(err, config)
 
+	s3Client := s3.NewFromConfig(loadedAwsConfig, func(options *s3.Options) {
+		if config.Aws.S3Endpoint != DEFAULT_AWS_S3_ENDPOINT {
+			options.BaseEndpoint = aws.String(config.Aws.S3EndpointUrl())
+		}
+		options.UsePathStyle = config.Aws.S3ForcePathStyle
+	})
+
 	return &StorageS3{
-		s3Client:     s3.NewFromConfig(loadedAwsConfig),
+		s3Client:     s3Client,
 		config:       config,
 		storageUtils: &StorageUtils{config: config},
 	}
@@ -49,8 +71,34 @@ func NewS3Storage(config *Config) *StorageS3 {
 
 // Read ----------------------------------------------------------------------------------------------------------------
 
-func (storage *StorageS3) IcebergMetadataFilePath(icebergSchemaTable IcebergSchemaTable) string {
-	return storage.fullBucketPath() + storage.tablePrefix(icebergSchemaTable, true) + "metadata/" + ICEBERG_METADATA_FILE_NAME
+func (storage *StorageS3) IcebergMetadataDirPath(icebergSchemaTable IcebergSchemaTable) string {
+	return storage.tablePrefix(icebergSchemaTable, true) + "metadata"
+}
+
+// Resolves the current metadata version through the version hint
+func (storage *StorageS3) IcebergMetadataFilePath(icebergSchemaTable IcebergSchemaTable) (string, error) {
+	metadataFile, err := storage.ExistingMetadataFile(storage.IcebergMetadataDirPath(icebergSchemaTable))
+	if err != nil {
+		return "", err
+	}
+	if metadataFile.Version == 0 {
+		return "", fmt.Errorf("no Iceberg metadata found for %s", icebergSchemaTable.String())
+	}
+
+	return storage.fullBucketPath() + metadataFile.Path, nil
+}
+
+func (storage *StorageS3) IcebergMetadata(icebergSchemaTable IcebergSchemaTable) (string, []byte, error) {
+	metadataPath, err := storage.IcebergMetadataFilePath(icebergSchemaTable)
+	if err != nil {
+		return "", nil, err
+	}
+	metadataContent, err := storage.readFileContent(strings.TrimPrefix(metadataPath, storage.fullBucketPath()))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return metadataPath, metadataContent, nil
 }
 
 func (storage *StorageS3) IcebergSchemas() (icebergSchemas []string, err error) {
@@ -93,8 +141,7 @@ func (storage *StorageS3) IcebergSchemaTables() (Set[IcebergSchemaTable], error)
 }
 
 func (storage *StorageS3) IcebergTableFields(icebergSchemaTable IcebergSchemaTable) ([]IcebergTableField, error) {
-	metadataPath := storage.tablePrefix(icebergSchemaTable, true) + "metadata/" + ICEBERG_METADATA_FILE_NAME
-	metadataContent, err := storage.readFileContent(metadataPath)
+	metadataContent, err := storage.readCurrentMetadataContent(storage.IcebergMetadataDirPath(icebergSchemaTable))
 	if err != nil {
 		return nil, err
 	}
@@ -102,9 +149,41 @@ func (storage *StorageS3) IcebergTableFields(icebergSchemaTable IcebergSchemaTab
 	return storage.storageUtils.ParseIcebergTableFields(metadataContent)
 }
 
+// The version hint can be behind if a concurrent commit hasn't updated it yet, so newer versions are looked up too
+func (storage *StorageS3) ExistingMetadataFile(metadataDirPath string) (MetadataFile, error) {
+	var version int64
+	versionHintContent, err := storage.readFileContent(metadataDirPath + "/" + ICEBERG_VERSION_HINT_FILE_NAME)
+	if err == nil {
+		version, err = storage.storageUtils.ParseVersionHint(versionHintContent)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+	} else {
+		var noSuchKeyErr *types.NoSuchKey
+		if !errors.As(err, &noSuchKeyErr) {
+			return MetadataFile{}, err
+		}
+	}
+
+	for {
+		exists, err := storage.fileExists(metadataDirPath + "/" + storage.storageUtils.MetadataFileName(version+1))
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		if !exists {
+			break
+		}
+		version++
+	}
+	if version == 0 {
+		return MetadataFile{}, nil
+	}
+
+	return MetadataFile{Version: version, Path: metadataDirPath + "/" + storage.storageUtils.MetadataFileName(version)}, nil
+}
+
 func (storage *StorageS3) ExistingManifestListFiles(metadataDirPath string) ([]ManifestListFile, error) {
-	metadataPath := metadataDirPath + "/" + ICEBERG_METADATA_FILE_NAME
-	metadataContent, err := storage.readFileContent(metadataPath)
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
 	if err != nil {
 		return nil, err
 	}
@@ -112,6 +191,42 @@ func (storage *StorageS3) ExistingManifestListFiles(metadataDirPath string) ([]M
 	return storage.storageUtils.ParseManifestListFiles(storage.fullBucketPath(), metadataContent)
 }
 
+func (storage *StorageS3) ExistingSchemas(metadataDirPath string) ([]IcebergSchema, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageUtils.ParseIcebergSchemas(metadataContent)
+}
+
+func (storage *StorageS3) ExistingPartitionSpec(metadataDirPath string) (IcebergPartitionSpec, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
+	if err != nil {
+		return IcebergPartitionSpec{}, err
+	}
+
+	return storage.storageUtils.ParseIcebergPartitionSpec(metadataContent)
+}
+
+func (storage *StorageS3) ExistingPartitionSpecs(metadataDirPath string) ([]IcebergPartitionSpec, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageUtils.ParseIcebergPartitionSpecs(metadataContent)
+}
+
+func (storage *StorageS3) ExistingSortOrder(metadataDirPath string) (IcebergSortOrder, error) {
+	metadataContent, err := storage.readCurrentMetadataContent(metadataDirPath)
+	if err != nil {
+		return IcebergSortOrder{}, err
+	}
+
+	return storage.storageUtils.ParseIcebergSortOrder(metadataContent)
+}
+
 func (storage *StorageS3) ExistingManifestListItems(manifestListFile ManifestListFile) ([]ManifestListItem, error) {
 	manifestListContent, err := storage.readFileContent(manifestListFile.Path)
 	if err != nil {
@@ -130,6 +245,49 @@ func (storage *StorageS3) ExistingParquetFilePath(manifestFile ManifestFile) (st
 	return storage.storageUtils.ParseParquetFilePath(storage.fullBucketPath(), manifestListContent)
 }
 
+func (storage *StorageS3) ExistingParquetFile(manifestFile ManifestFile) (ParquetFile, error) {
+	manifestContent, err := storage.readFileContent(manifestFile.Path)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+
+	return storage.storageUtils.ParseParquetFile(storage.fullBucketPath(), manifestContent)
+}
+
+func (storage *StorageS3) ExistingDeletedParquetFilePaths(positionDeletesFilePaths []string) ([]string, error) {
+	return storage.storageUtils.ReadDeletedParquetFilePaths(storage.fullBucketPath(), positionDeletesFilePaths)
+}
+
+func (storage *StorageS3) ExistingFilePaths(dirPath string) ([]string, error) {
+	objects, _, err := storage.listObjects(dirPath+"/", "/")
+	if err != nil {
+		return nil, err
+	}
+
+	filePaths := []string{}
+	for _, obj := range objects {
+		filePaths = append(filePaths, *obj.Key)
+	}
+
+	return filePaths, nil
+}
+
+func (storage *StorageS3) ExistingFilePathsModifiedBefore(dirPath string, modifiedBefore time.Time) ([]string, error) {
+	objects, _, err := storage.listObjects(dirPath+"/", "/")
+	if err != nil {
+		return nil, err
+	}
+
+	filePaths := []string{}
+	for _, obj := range objects {
+		if obj.LastModified != nil && obj.LastModified.Before(modifiedBefore) {
+			filePaths = append(filePaths, *obj.Key)
+		}
+	}
+
+	return filePaths, nil
+}
+
 // Write ---------------------------------------------------------------------------------------------------------------
 
 func (storage *StorageS3) DeleteSchema(schema string) (err error) {
@@ -157,9 +315,9 @@ func (storage *StorageS3) CreateParquet(dataDirPath string, pgSchemaColumns []Pg
 	fileName := fmt.Sprintf("00000-0-%s.parquet", uuid)
 	fileKey := dataDirPath + "/" + fileName
 
-	fileWriter, err := s3v2.NewS3FileWriterWithClient(ctx, storage.s3Client, storage.config.Aws.S3Bucket, fileKey, nil)
+	fileWriter, err := s3v2.NewS3FileWriterWithClient(ctx, storage.s3Client, storage.config.Aws.S3Bucket, fileKey, storage.uploaderOptions(), storage.encryptObject)
 	if err != nil {
-		return ParquetFile{}, false, fmt.Errorf("failed to open Parquet file for writing: %v", err)
+		return ParquetFile{}, false, fmt.Errorf("failed to open Parquet file for writing: %w", err)
 	}
 
 	recordCount, loadedAllRows, err := storage.storageUtils.WriteParquetFile(fileWriter, pgSchemaColumns, loadRows, maxWritePayloadSize)
@@ -173,15 +331,15 @@ func (storage *StorageS3) CreateParquet(dataDirPath string, pgSchemaColumns []Pg
 		Key:    aws.String(fileKey),
 	})
 	if err != nil {
-		return ParquetFile{}, false, fmt.Errorf("failed to get Parquet file info: %v", err)
+		return ParquetFile{}, false, fmt.Errorf("failed to get Parquet file info: %w", err)
 	}
 	fileSize := *headObjectResponse.ContentLength
 
 	fileReader, err := s3v2.NewS3FileReaderWithClient(ctx, storage.s3Client, storage.config.Aws.S3Bucket, fileKey)
 	if err != nil {
-		return ParquetFile{}, false, fmt.Errorf("failed to open Parquet file for reading: %v", err)
+		return ParquetFile{}, false, fmt.Errorf("failed to open Parquet file for reading: %w", err)
 	}
-	parquetStats, err := storage.storageUtils.ReadParquetStats(fileReader)
+	parquetStats, err := storage.storageUtils.ReadParquetStats(fileReader, pgSchemaColumns)
 	if err != nil {
 		return ParquetFile{}, false, err
 	}
@@ -195,18 +353,18 @@ func (storage *StorageS3) CreateParquet(dataDirPath string, pgSchemaColumns []Pg
 	}, loadedAllRows, nil
 }
 
-func (storage *StorageS3) CreateOverwrittenParquet(dataDirPath string, existingParquetFilePath string, newParquetFilePath string, pgSchemaColumns []PgSchemaColumn, rowCountPerBatch int) (overwrittenParquetFile ParquetFile, err error) {
+func (storage *StorageS3) CreateOverwrittenParquet(dataDirPath string, existingParquetFilePath string, newParquetFilePaths []string, pgSchemaColumns []PgSchemaColumn, rowCountPerBatch int) (overwrittenParquetFile ParquetFile, err error) {
 	ctx := context.Background()
 	uuid := uuid.New().String()
 	fileName := fmt.Sprintf("00000-0-%s.parquet", uuid)
 	fileKey := dataDirPath + "/" + fileName
 
-	fileWriter, err := s3v2.NewS3FileWriterWithClient(ctx, storage.s3Client, storage.config.Aws.S3Bucket, fileKey, nil)
+	fileWriter, err := s3v2.NewS3FileWriterWithClient(ctx, storage.s3Client, storage.config.Aws.S3Bucket, fileKey, storage.uploaderOptions(), storage.encryptObject)
 	if err != nil {
-		return ParquetFile{}, fmt.Errorf("failed to open Parquet file for writing: %v", err)
+		return ParquetFile{}, fmt.Errorf("failed to open Parquet file for writing: %w", err)
 	}
 
-	duckdb, err := storage.storageUtils.NewDuckDBIfHasOverlappingRows(storage.fullBucketPath(), existingParquetFilePath, newParquetFilePath, pgSchemaColumns)
+	duckdb, err := storage.storageUtils.NewDuckDBIfHasOverlappingRows(storage.fullBucketPath(), existingParquetFilePath, newParquetFilePaths, pgSchemaColumns)
 	if err != nil {
 		return ParquetFile{}, err
 	}
@@ -228,15 +386,59 @@ func (storage *StorageS3) CreateOverwrittenParquet(dataDirPath string, existingP
 		Key:    aws.String(fileKey),
 	})
 	if err != nil {
-		return ParquetFile{}, fmt.Errorf("failed to get Parquet file info: %v", err)
+		return ParquetFile{}, fmt.Errorf("failed to get Parquet file info: %w", err)
+	}
+	fileSize := *headObjectResponse.ContentLength
+
+	fileReader, err := s3v2.NewS3FileReaderWithClient(ctx, storage.s3Client, storage.config.Aws.S3Bucket, fileKey)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("failed to open Parquet file for reading: %w", err)
+	}
+	parquetStats, err := storage.storageUtils.ReadParquetStats(fileReader, pgSchemaColumns)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+
+	return ParquetFile{
+		Uuid:        uuid,
+		Path:        fileKey,
+		Size:        fileSize,
+		RecordCount: recordCount,
+		Stats:       parquetStats,
+	}, nil
+}
+
+func (storage *StorageS3) CreateCompactedParquet(dataDirPath string, parquetFilePaths []string, positionDeletesFilePaths []string, pgSchemaColumns []PgSchemaColumn, sortOrder IcebergSortOrder) (compactedParquetFile ParquetFile, err error) {
+	ctx := context.Background()
+	uuid := uuid.New().String()
+	fileName := fmt.Sprintf("00000-0-%s.parquet", uuid)
+	fileKey := dataDirPath + "/" + fileName
+
+	fileWriter, err := s3v2.NewS3FileWriterWithClient(ctx, storage.s3Client, storage.config.Aws.S3Bucket, fileKey, storage.uploaderOptions(), storage.encryptObject)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("failed to open Parquet file for writing: %w", err)
+	}
+
+	recordCount, err := storage.storageUtils.WriteCompactedParquetFile(storage.fullBucketPath(), parquetFilePaths, positionDeletesFilePaths, fileWriter, pgSchemaColumns, sortOrder)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	LogDebug(storage.config, "Parquet file with", recordCount, "record(s) created at:", fileKey)
+
+	headObjectResponse, err := storage.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(storage.config.Aws.S3Bucket),
+		Key:    aws.String(fileKey),
+	})
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("failed to get Parquet file info: %w", err)
 	}
 	fileSize := *headObjectResponse.ContentLength
 
 	fileReader, err := s3v2.NewS3FileReaderWithClient(ctx, storage.s3Client, storage.config.Aws.S3Bucket, fileKey)
 	if err != nil {
-		return ParquetFile{}, fmt.Errorf("failed to open Parquet file for reading: %v", err)
+		return ParquetFile{}, fmt.Errorf("failed to open Parquet file for reading: %w", err)
 	}
-	parquetStats, err := storage.storageUtils.ReadParquetStats(fileReader)
+	parquetStats, err := storage.storageUtils.ReadParquetStats(fileReader, pgSchemaColumns)
 	if err != nil {
 		return ParquetFile{}, err
 	}
@@ -247,6 +449,52 @@ func (storage *StorageS3) CreateOverwrittenParquet(dataDirPath string, existingP
 		Size:        fileSize,
 		RecordCount: recordCount,
 		Stats:       parquetStats,
+		SortOrderId: sortOrder.OrderId,
+	}, nil
+}
+
+func (storage *StorageS3) CreatePositionDeletesParquet(dataDirPath string, existingParquetFilePaths []string, newParquetFilePaths []string, keyPgSchemaColumns []PgSchemaColumn) (positionDeletesFile ParquetFile, err error) {
+	ctx := context.Background()
+	uuid := uuid.New().String()
+	fileName := fmt.Sprintf("00000-0-%s.parquet", uuid)
+	fileKey := dataDirPath + "/" + fileName
+
+	fileWriter, err := s3v2.NewS3FileWriterWithClient(ctx, storage.s3Client, storage.config.Aws.S3Bucket, fileKey, storage.uploaderOptions(), storage.encryptObject)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("failed to open Parquet file for writing: %w", err)
+	}
+
+	recordCount, err := storage.storageUtils.WritePositionDeletesParquetFile(storage.fullBucketPath(), existingParquetFilePaths, newParquetFilePaths, keyPgSchemaColumns, fileWriter)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	LogDebug(storage.config, "Position delete file with", recordCount, "record(s) created at:", fileKey)
+
+	headObjectResponse, err := storage.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(storage.config.Aws.S3Bucket),
+		Key:    aws.String(fileKey),
+	})
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("failed to get Parquet file info: %w", err)
+	}
+	fileSize := *headObjectResponse.ContentLength
+
+	fileReader, err := s3v2.NewS3FileReaderWithClient(ctx, storage.s3Client, storage.config.Aws.S3Bucket, fileKey)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("failed to open Parquet file for reading: %w", err)
+	}
+	parquetStats, err := storage.storageUtils.ReadParquetStats(fileReader, storage.storageUtils.PositionDeletesPgSchemaColumns())
+	if err != nil {
+		return ParquetFile{}, err
+	}
+
+	return ParquetFile{
+		Uuid:            uuid,
+		Path:            fileKey,
+		Size:            fileSize,
+		RecordCount:     recordCount,
+		Stats:           parquetStats,
+		PositionDeletes: true,
 	}, nil
 }
 
@@ -259,7 +507,16 @@ func (storage *StorageS3) DeleteParquet(parquetFile ParquetFile) (err error) {
 	return err
 }
 
-func (storage *StorageS3) CreateManifest(metadataDirPath string, parquetFile ParquetFile) (manifestFile ManifestFile, err error) {
+func (storage *StorageS3) DeleteFile(filePath string) (err error) {
+	ctx := context.Background()
+	_, err = storage.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(storage.config.Aws.S3Bucket),
+		Key:    aws.String(filePath),
+	})
+	return err
+}
+
+func (storage *StorageS3) CreateManifest(metadataDirPath string, partitionSpec IcebergPartitionSpec, parquetFile ParquetFile) (manifestFile ManifestFile, err error) {
 	fileName := fmt.Sprintf("%s-m0.avro", parquetFile.Uuid)
 	filePath := metadataDirPath + "/" + fileName
 
@@ -269,7 +526,7 @@ func (storage *StorageS3) CreateManifest(metadataDirPath string, parquetFile Par
 	}
 	defer storage.deleteTemporaryFile(tempFile)
 
-	manifestFile, err = storage.storageUtils.WriteManifestFile(storage.fullBucketPath(), tempFile.Name(), parquetFile)
+	manifestFile, err = storage.storageUtils.WriteManifestFile(storage.fullBucketPath(), tempFile.Name(), partitionSpec, parquetFile)
 	if err != nil {
 		return ManifestFile{}, err
 	}
@@ -338,27 +595,67 @@ func (storage *StorageS3) CreateManifestList(metadataDirPath string, parquetFile
 	return manifestListFile, nil
 }
 
-func (storage *StorageS3) CreateMetadata(metadataDirPath string, pgSchemaColumns []PgSchemaColumn, manifestListFilesSortedAsc []ManifestListFile) (metadataFile MetadataFile, err error) {
-	filePath := metadataDirPath + "/" + ICEBERG_METADATA_FILE_NAME
+// Publishes the next metadata version with a conditional write, which fails if a concurrent commit published it first
+func (storage *StorageS3) CreateMetadata(metadataDirPath string, schemasSortedAsc []IcebergSchema, partitionSpec IcebergPartitionSpec, sortOrder IcebergSortOrder, manifestListFilesSortedAsc []ManifestListFile) (metadataFile MetadataFile, err error) {
+	for attempt := 1; ; attempt++ {
+		currentMetadataFile, err := storage.ExistingMetadataFile(metadataDirPath)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		var currentMetadataContent []byte
+		if currentMetadataFile.Version > 0 {
+			currentMetadataContent, err = storage.readFileContent(currentMetadataFile.Path)
+			if err != nil {
+				return MetadataFile{}, err
+			}
+		}
 
-	tempFile, err := storage.createTemporaryFile("manifest")
-	if err != nil {
-		return MetadataFile{}, err
-	}
-	defer storage.deleteTemporaryFile(tempFile)
+		err = storage.storageUtils.ValidateMetadataCommit(metadataDirPath, currentMetadataContent, manifestListFilesSortedAsc)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		tableUuid, err := storage.storageUtils.TableUuid(currentMetadataContent)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		metadataLog, expiredMetadataFilePaths, err := storage.storageUtils.NextMetadataLog(storage.fullBucketPath(), currentMetadataFile, currentMetadataContent)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		partitionSpecsSortedAsc, err := storage.storageUtils.MetadataPartitionSpecs(currentMetadataContent, partitionSpec)
+		if err != nil {
+			return MetadataFile{}, err
+		}
 
-	err = storage.storageUtils.WriteMetadataFile(storage.fullBucketPath(), tempFile.Name(), pgSchemaColumns, manifestListFilesSortedAsc)
-	if err != nil {
-		return MetadataFile{}, err
-	}
+		metadataFile = MetadataFile{
+			Version: currentMetadataFile.Version + 1,
+			Path:    metadataDirPath + "/" + storage.storageUtils.MetadataFileName(currentMetadataFile.Version+1),
+		}
+		published, err := storage.uploadMetadataFile(metadataFile, tableUuid, metadataLog, schemasSortedAsc, partitionSpecsSortedAsc, partitionSpec, sortOrder, manifestListFilesSortedAsc)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		if !published {
+			if attempt < ICEBERG_METADATA_COMMIT_ATTEMPTS {
+				continue
+			}
+			return MetadataFile{}, &MetadataCommitConflictError{MetadataDirPath: metadataDirPath}
+		}
+		LogDebug(storage.config, "Metadata file created at:", metadataFile.Path)
 
-	err = storage.uploadFile(filePath, tempFile)
-	if err != nil {
-		return MetadataFile{}, err
-	}
-	LogDebug(storage.config, "Metadata file created at:", filePath)
+		err = storage.writeVersionHint(metadataDirPath, metadataFile)
+		if err != nil {
+			return MetadataFile{}, err
+		}
+		for _, expiredMetadataFilePath := range expiredMetadataFilePaths {
+			err = storage.DeleteFile(expiredMetadataFilePath)
+			if err != nil {
+				LogWarn(storage.config, "Failed to delete expired metadata file", expiredMetadataFilePath+":", err)
+			}
+		}
 
-	return MetadataFile{Version: 1, Path: filePath}, nil
+		return metadataFile, nil
+	}
 }
 
 // Read (internal) -----------------------------------------------------------------------------------------------------
@@ -373,6 +670,58 @@ func (storage *StorageS3) InternalTableMetadata(pgSchemaTable PgSchemaTable) (In
 	return storage.storageUtils.ParseInternalTableMetadata(internalMetadataContent)
 }
 
+func (storage *StorageS3) SyncJournal() (*SyncJournal, error) {
+	syncJournalContent, err := storage.readFileContent(storage.syncJournalFilePath())
+	if err != nil {
+		var noSuchKeyErr *types.NoSuchKey
+		if errors.As(err, &noSuchKeyErr) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return storage.storageUtils.ParseSyncJournal(syncJournalContent)
+}
+
+func (storage *StorageS3) SyncStatus() (SyncStatus, error) {
+	syncStatusContent, err := storage.readFileContent(storage.syncStatusFilePath())
+	if err != nil {
+		var noSuchKeyErr *types.NoSuchKey
+		if errors.As(err, &noSuchKeyErr) {
+			return SyncStatus{}, nil
+		}
+		return SyncStatus{}, err
+	}
+
+	return storage.storageUtils.ParseSyncStatus(syncStatusContent)
+}
+
+func (storage *StorageS3) SyncStatuses() ([]SyncStatus, error) {
+	filePaths, err := storage.ExistingFilePaths(storage.config.StoragePath)
+	if err != nil {
+		return nil, err
+	}
+
+	syncStatuses := []SyncStatus{}
+	for _, filePath := range filePaths {
+		if !strings.HasSuffix(filePath, SYNC_STATUS_FILE_NAME) {
+			continue
+		}
+
+		syncStatusContent, err := storage.readFileContent(filePath)
+		if err != nil {
+			return nil, err
+		}
+		syncStatus, err := storage.storageUtils.ParseSyncStatus(syncStatusContent)
+		if err != nil {
+			return nil, err
+		}
+		syncStatuses = append(syncStatuses, syncStatus)
+	}
+
+	return syncStatuses, nil
+}
+
 // Write (internal) ----------------------------------------------------------------------------------------------------
 
 func (storage *StorageS3) WriteInternalTableMetadata(pgSchemaTable PgSchemaTable, internalTableMetadata InternalTableMetadata) error {
@@ -398,6 +747,56 @@ func (storage *StorageS3) WriteInternalTableMetadata(pgSchemaTable PgSchemaTable
 	return nil
 }
 
+func (storage *StorageS3) WriteSyncJournal(syncJournal SyncJournal) error {
+	filePath := storage.syncJournalFilePath()
+
+	tempFile, err := storage.createTemporaryFile("sync-journal")
+	if err != nil {
+		return err
+	}
+	defer storage.deleteTemporaryFile(tempFile)
+
+	err = storage.storageUtils.WriteSyncJournalFile(tempFile.Name(), syncJournal)
+	if err != nil {
+		return err
+	}
+
+	err = storage.uploadFile(filePath, tempFile)
+	if err != nil {
+		return err
+	}
+	LogDebug(storage.config, "Sync journal file written at:", filePath)
+
+	return nil
+}
+
+func (storage *StorageS3) DeleteSyncJournal() error {
+	return storage.DeleteFile(storage.syncJournalFilePath())
+}
+
+func (storage *StorageS3) WriteSyncStatus(syncStatus SyncStatus) error {
+	filePath := storage.syncStatusFilePath()
+
+	tempFile, err := storage.createTemporaryFile("sync-status")
+	if err != nil {
+		return err
+	}
+	defer storage.deleteTemporaryFile(tempFile)
+
+	err = storage.storageUtils.WriteSyncStatusFile(tempFile.Name(), syncStatus)
+	if err != nil {
+		return err
+	}
+
+	err = storage.uploadFile(filePath, tempFile)
+	if err != nil {
+		return err
+	}
+	LogDebug(storage.config, "Sync status file written at:", filePath)
+
+	return nil
+}
+
 // ---------------------------------------------------------------------------------------------------------------------
 
 func (storage *StorageS3) readFileContent(filePath string) ([]byte, error) {
@@ -419,24 +818,111 @@ func (storage *StorageS3) readFileContent(filePath string) ([]byte, error) {
 }
 
 func (storage *StorageS3) uploadFile(filePath string, file *os.File) (err error) {
-	uploader := manager.NewUploader(storage.s3Client)
+	uploader := manager.NewUploader(storage.s3Client, storage.uploaderOptions()...)
 
-	_, err = uploader.Upload(context.Background(), &s3.PutObjectInput{
+	putObjectInput := &s3.PutObjectInput{
 		Bucket: aws.String(storage.config.Aws.S3Bucket),
 		Key:    aws.String(filePath),
 		Body:   file,
-	})
+	}
+	storage.encryptObject(putObjectInput)
+	_, err = uploader.Upload(context.Background(), putObjectInput)
 	if err != nil {
-		return fmt.Errorf("failed to upload file: %v", err)
+		return fmt.Errorf("failed to upload file: %w", err)
 	}
 
 	return nil
 }
 
+// Returns false if the metadata version was already published by a concurrent commit
+func (storage *StorageS3) uploadMetadataFile(metadataFile MetadataFile, tableUuid string, metadataLog []MetadataLogEntry, schemasSortedAsc []IcebergSchema, partitionSpecsSortedAsc []IcebergPartitionSpec, partitionSpec IcebergPartitionSpec, sortOrder IcebergSortOrder, manifestListFilesSortedAsc []ManifestListFile) (published bool, err error) {
+	tempFile, err := storage.createTemporaryFile("metadata")
+	if err != nil {
+		return false, err
+	}
+	defer storage.deleteTemporaryFile(tempFile)
+
+	metadataDirPath := strings.TrimSuffix(metadataFile.Path, "/"+storage.storageUtils.MetadataFileName(metadataFile.Version))
+	err = storage.storageUtils.WriteMetadataFile(storage.fullBucketPath(), tempFile.Name(), metadataDirPath, tableUuid, metadataLog, schemasSortedAsc, partitionSpecsSortedAsc, partitionSpec, sortOrder, manifestListFilesSortedAsc)
+	if err != nil {
+		return false, err
+	}
+
+	// A single PUT request, so readers never see a partially uploaded object
+	putObjectInput := &s3.PutObjectInput{
+		Bucket:      aws.String(storage.config.Aws.S3Bucket),
+		Key:         aws.String(metadataFile.Path),
+		Body:        tempFile,
+		IfNoneMatch: aws.String("*"),
+	}
+	storage.encryptObject(putObjectInput)
+	_, err = storage.s3Client.PutObject(context.Background(), putObjectInput)
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "PreconditionFailed" || apiErr.ErrorCode() == "ConditionalRequestConflict") {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to upload metadata file: %w", err)
+	}
+
+	return true, nil
+}
+
+func (storage *StorageS3) writeVersionHint(metadataDirPath string, metadataFile MetadataFile) error {
+	tempFile, err := storage.createTemporaryFile("version-hint")
+	if err != nil {
+		return err
+	}
+	defer storage.deleteTemporaryFile(tempFile)
+
+	err = storage.storageUtils.WriteVersionHintFile(tempFile.Name(), metadataFile)
+	if err != nil {
+		return err
+	}
+
+	return storage.uploadFile(metadataDirPath+"/"+ICEBERG_VERSION_HINT_FILE_NAME, tempFile)
+}
+
+// Fails if the table has no metadata
+func (storage *StorageS3) readCurrentMetadataContent(metadataDirPath string) ([]byte, error) {
+	metadataFile, err := storage.ExistingMetadataFile(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+	if metadataFile.Version == 0 {
+		return nil, fmt.Errorf("no Iceberg metadata found in %s", metadataDirPath)
+	}
+
+	return storage.readFileContent(metadataFile.Path)
+}
+
+func (storage *StorageS3) fileExists(filePath string) (bool, error) {
+	_, err := storage.s3Client.HeadObject(context.Background(), &s3.HeadObjectInput{
+		Bucket: aws.String(storage.config.Aws.S3Bucket),
+		Key:    aws.String(filePath),
+	})
+	if err != nil {
+		var notFoundErr *types.NotFound
+		if errors.As(err, &notFoundErr) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
 func (storage *StorageS3) internalTableMetadataFilePath(pgSchemaTable PgSchemaTable) string {
 	return storage.tablePrefix(pgSchemaTable.ToIcebergSchemaTable()) + "metadata/" + INTERNAL_METADATA_FILE_NAME
 }
 
+func (storage *StorageS3) syncJournalFilePath() string {
+	return storage.config.StoragePath + "/" + storage.config.Pg.SchemaPrefix + SYNC_JOURNAL_FILE_NAME
+}
+
+func (storage *StorageS3) syncStatusFilePath() string {
+	return storage.config.StoragePath + "/" + storage.config.Pg.SchemaPrefix + SYNC_STATUS_FILE_NAME
+}
+
 func (storage *StorageS3) tablePrefix(schemaTable IcebergSchemaTable, isIcebergSchemaTable ...bool) string {
 	if len(isIcebergSchemaTable) > 0 && isIcebergSchemaTable[0] {
 		return storage.config.StoragePath + "/" + schemaTable.Schema + "/" + schemaTable.Table + "/"
@@ -450,42 +936,30 @@ func (storage *StorageS3) fullBucketPath() string {
 }
 
 func (storage *StorageS3) nestedDirectoryPrefixes(prefix string) (dirs []string, err error) {
-	ctx := context.Background()
-	listResponse, err := storage.s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
-		Bucket:    aws.String(storage.config.Aws.S3Bucket),
-		Prefix:    aws.String(prefix),
-		Delimiter: aws.String("/"),
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list objects: %v", err)
-	}
-
-	for _, prefix := range listResponse.CommonPrefixes {
-		dirs = append(dirs, *prefix.Prefix)
-	}
-
-	return dirs, nil
+	_, dirs, err = storage.listObjects(prefix, "/")
+	return dirs, err
 }
 
 func (storage *StorageS3) deleteNestedObjects(prefix string) (err error) {
 	ctx := context.Background()
 
-	listResponse, err := storage.s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
-		Bucket: aws.String(storage.config.Aws.S3Bucket),
-		Prefix: aws.String(prefix),
-	})
+	objects, _, err := storage.listObjects(prefix, "")
 	if err != nil {
-		return fmt.Errorf("failed to list objects: %v", err)
+		return err
 	}
-
-	var objectsToDelete []types.ObjectIdentifier
-	for _, obj := range listResponse.Contents {
-		LogDebug(storage.config, "Object to delete:", *obj.Key)
-		objectsToDelete = append(objectsToDelete, types.ObjectIdentifier{Key: obj.Key})
+	if len(objects) == 0 {
+		LogDebug(storage.config, "No objects to delete.")
+		return nil
 	}
 
-	if len(objectsToDelete) > 0 {
-		_, err = storage.s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+	for batch := range slices.Chunk(objects, MAX_S3_DELETE_OBJECTS) {
+		var objectsToDelete []types.ObjectIdentifier
+		for _, obj := range batch {
+			LogDebug(storage.config, "Object to delete:", *obj.Key)
+			objectsToDelete = append(objectsToDelete, types.ObjectIdentifier{Key: obj.Key})
+		}
+
+		deleteResponse, err := storage.s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
 			Bucket: aws.String(storage.config.Aws.S3Bucket),
 			Delete: &types.Delete{
 				Objects: objectsToDelete,
@@ -493,16 +967,66 @@ func (storage *StorageS3) deleteNestedObjects(prefix string) (err error) {
 			},
 		})
 		if err != nil {
-			return fmt.Errorf("failed to delete objects: %v", err)
+			return fmt.Errorf("failed to delete objects: %w", err)
+		}
+		// Quiet responses only list the objects that failed to be deleted
+		if len(deleteResponse.Errors) > 0 {
+			deleteErr := deleteResponse.Errors[0]
+			return fmt.Errorf("failed to delete %d object(s), e.g. %s: %s", len(deleteResponse.Errors), aws.ToString(deleteErr.Key), aws.ToString(deleteErr.Message))
 		}
-		LogDebug(storage.config, "Deleted", len(objectsToDelete), "object(s).")
-	} else {
-		LogDebug(storage.config, "No objects to delete.")
 	}
+	LogDebug(storage.config, "Deleted", len(objects), "object(s).")
 
 	return nil
 }
 
+// Lists all objects under the prefix, across pages of up to 1000 keys. With a delimiter, also returns the common prefixes of nested objects
+func (storage *StorageS3) listObjects(prefix string, delimiter string) (objects []types.Object, commonPrefixes []string, err error) {
+	listObjectsInput := &s3.ListObjectsV2Input{
+		Bucket: aws.String(storage.config.Aws.S3Bucket),
+		Prefix: aws.String(prefix),
+	}
+	if delimiter != "" {
+		listObjectsInput.Delimiter = aws.String(delimiter)
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(storage.s3Client, listObjectsInput)
+	for paginator.HasMorePages() {
+		listResponse, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		objects = append(objects, listResponse.Contents...)
+		for _, commonPrefix := range listResponse.CommonPrefixes {
+			commonPrefixes = append(commonPrefixes, *commonPrefix.Prefix)
+		}
+	}
+
+	return objects, commonPrefixes, nil
+}
+
+func (storage *StorageS3) uploaderOptions() []func(*manager.Uploader) {
+	return []func(*manager.Uploader){
+		func(uploader *manager.Uploader) {
+			uploader.PartSize = storage.config.Aws.S3UploadPartSize
+			uploader.Concurrency = storage.config.Aws.S3UploadConcurrency
+		},
+	}
+}
+
+// Sets the configured server-side encryption of an uploaded object
+func (storage *StorageS3) encryptObject(putObjectInput *s3.PutObjectInput) {
+	if storage.config.Aws.S3ServerSideEncryption == "" {
+		return
+	}
+
+	putObjectInput.ServerSideEncryption = types.ServerSideEncryption(storage.config.Aws.S3ServerSideEncryption)
+	if storage.config.Aws.S3SseKmsKeyId != "" {
+		putObjectInput.SSEKMSKeyId = aws.String(storage.config.Aws.S3SseKmsKeyId)
+	}
+}
+
 func (storage *StorageS3) createTemporaryFile(prefix string) (file *os.File, err error) {
 	tempFile, err := os.CreateTemp("", prefix)
 	PanicIfError(err, nil)
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/uuid"
+)
+
+const (
+	TEST_S3_BUCKET    = "test-bucket"
+	TEST_S3_PAGE_SIZE = 100
+)
+
+func TestStorageS3(t *testing.T) {
+	fakeS3 := newTestS3(t)
+	config := loadTestConfig()
+	config.StorageType = STORAGE_TYPE_S3
+	config.StoragePath = "iceberg"
+	config.Aws = AwsConfig{
+		Region:                 "us-east-1",
+		S3Endpoint:             strings.TrimPrefix(fakeS3.server.URL, "http://"),
+		S3UseSsl:               false,
+		S3Bucket:               TEST_S3_BUCKET,
+		AccessKeyId:            "test-access-key-id",
+		SecretAccessKey:        "test-secret-access-key",
+		S3ForcePathStyle:       true,
+		S3ServerSideEncryption: AWS_S3_SERVER_SIDE_ENCRYPTION_KMS,
+		S3SseKmsKeyId:          "test-kms-key",
+		S3UploadPartSize:       int64(DEFAULT_AWS_S3_UPLOAD_PART_SIZE_MB) * 1024 * 1024,
+		S3UploadConcurrency:    DEFAULT_AWS_S3_UPLOAD_CONCURRENCY,
+		S3MaxAttempts:          3,
+		S3MaxBackoff:           10 * time.Millisecond,
+	}
+	storage := NewS3Storage(config)
+
+	t.Run("Lists all objects across pages", func(t *testing.T) {
+		for i := range TEST_S3_PAGE_SIZE + 50 {
+			fakeS3.putObject(fmt.Sprintf("iceberg/test_schema/test_table/data/%03d.parquet", i))
+			fakeS3.putObject(fmt.Sprintf("iceberg/test_schema/test_table_%03d/metadata/v1.metadata.json", i))
+		}
+
+		filePaths, err := storage.ExistingFilePaths("iceberg/test_schema/test_table/data")
+
+		testNoError(t, err)
+		if len(filePaths) != TEST_S3_PAGE_SIZE+50 {
+			t.Errorf("Expected %d file paths, got %d", TEST_S3_PAGE_SIZE+50, len(filePaths))
+		}
+
+		icebergSchemaTables, err := storage.IcebergSchemaTables()
+
+		testNoError(t, err)
+		if len(icebergSchemaTables) != TEST_S3_PAGE_SIZE+51 {
+			t.Errorf("Expected %d tables, got %d", TEST_S3_PAGE_SIZE+51, len(icebergSchemaTables))
+		}
+	})
+
+	t.Run("Deletes objects in batches of at most 1000 keys", func(t *testing.T) {
+		for i := range MAX_S3_DELETE_OBJECTS {
+			fakeS3.putObject(fmt.Sprintf("iceberg/test_schema/test_table/metadata/%04d.avro", i))
+		}
+
+		err := storage.DeleteSchema("test_schema")
+
+		testNoError(t, err)
+		if fakeS3.objectCount() != 0 {
+			t.Errorf("Expected all objects to be deleted, got %d left", fakeS3.objectCount())
+		}
+		deleteBatchSizes := fakeS3.deleteBatchSizes()
+		if !slices.Equal(deleteBatchSizes, []int{MAX_S3_DELETE_OBJECTS, 2 * (TEST_S3_PAGE_SIZE + 50)}) {
+			t.Errorf("Expected 2 delete batches, got %v", deleteBatchSizes)
+		}
+	})
+
+	t.Run("Returns an error for objects that failed to be deleted", func(t *testing.T) {
+		fakeS3.putObject("iceberg/test_schema/test_table/data/locked.parquet")
+		fakeS3.lockObject("iceberg/test_schema/test_table/data/locked.parquet")
+
+		err := storage.DeleteSchema("test_schema")
+
+		if err == nil || !strings.Contains(err.Error(), "locked.parquet") {
+			t.Errorf("Expected an error for the locked object, got %v", err)
+		}
+	})
+
+	t.Run("Uploads objects with path-style requests and server-side encryption", func(t *testing.T) {
+		err := storage.WriteSyncStatus(SyncStatus{})
+		testNoError(t, err)
+
+		request := fakeS3.lastPutRequest(storage.syncStatusFilePath())
+		if request == nil {
+			t.Fatalf("Expected the sync status to be uploaded with a path-style request")
+		}
+		if request.Header.Get("X-Amz-Server-Side-Encryption") != AWS_S3_SERVER_SIDE_ENCRYPTION_KMS {
+			t.Errorf("Expected SSE-KMS, got %s", request.Header.Get("X-Amz-Server-Side-Encryption"))
+		}
+		if request.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id") != "test-kms-key" {
+			t.Errorf("Expected the KMS key ID, got %s", request.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+		}
+	})
+
+	t.Run("Retries throttled requests", func(t *testing.T) {
+		fakeS3.throttleRequests(2)
+
+		_, err := storage.SyncStatus()
+
+		testNoError(t, err)
+	})
+
+	t.Run("Fails after the max number of attempts", func(t *testing.T) {
+		fakeS3.throttleRequests(3)
+
+		_, err := storage.SyncStatus()
+
+		if err == nil {
+			t.Errorf("Expected an error after %d throttled attempts", config.Aws.S3MaxAttempts)
+		}
+		fakeS3.throttleRequests(0)
+	})
+}
+
+// Runs against a real MinIO server, e.g. MINIO_ENDPOINT=localhost:9000 with "minio server /tmp/minio" running
+func TestStorageS3MinIO(t *testing.T) {
+	minioEndpoint := os.Getenv("MINIO_ENDPOINT")
+	if minioEndpoint == "" {
+		t.Skip("Set MINIO_ENDPOINT to run the MinIO integration test")
+	}
+
+	config := loadTestConfig()
+	config.StorageType = STORAGE_TYPE_S3
+	config.StoragePath = "iceberg-test-" + uuid.New().String()
+	config.Aws = AwsConfig{
+		Region:              "us-east-1",
+		S3Endpoint:          minioEndpoint,
+		S3UseSsl:            false,
+		S3Bucket:            testEnvOrDefault("MINIO_BUCKET", "bemidb-test"),
+		AccessKeyId:         testEnvOrDefault("MINIO_ACCESS_KEY_ID", "minioadmin"),
+		SecretAccessKey:     testEnvOrDefault("MINIO_SECRET_ACCESS_KEY", "minioadmin"),
+		S3ForcePathStyle:    true,
+		S3UploadPartSize:    int64(DEFAULT_AWS_S3_UPLOAD_PART_SIZE_MB) * 1024 * 1024,
+		S3UploadConcurrency: DEFAULT_AWS_S3_UPLOAD_CONCURRENCY,
+		S3MaxAttempts:       3,
+		S3MaxBackoff:        100 * time.Millisecond,
+	}
+	storage := NewS3Storage(config)
+
+	_, err := storage.s3Client.CreateBucket(context.Background(), &s3.CreateBucketInput{Bucket: aws.String(config.Aws.S3Bucket)})
+	var bucketAlreadyOwnedByYou *types.BucketAlreadyOwnedByYou
+	if err != nil && !errors.As(err, &bucketAlreadyOwnedByYou) {
+		t.Fatalf("Failed to create the bucket: %v", err)
+	}
+	t.Cleanup(func() {
+		storage.deleteNestedObjects(config.StoragePath + "/")
+	})
+
+	t.Run("Uploads and reads objects with path-style requests", func(t *testing.T) {
+		err := storage.WriteSyncStatus(SyncStatus{Tables: []SyncTableStatus{{Schema: "test_schema", Table: "test_table"}}})
+		testNoError(t, err)
+
+		syncStatus, err := storage.SyncStatus()
+
+		testNoError(t, err)
+		if len(syncStatus.Tables) != 1 || syncStatus.Tables[0].Table != "test_table" {
+			t.Errorf("Expected the uploaded sync status, got %v", syncStatus)
+		}
+	})
+
+	t.Run("Publishes a metadata version only once", func(t *testing.T) {
+		metadataDirPath := config.StoragePath + "/test_schema/test_table/metadata"
+		metadataFile := MetadataFile{Version: 1, Path: metadataDirPath + "/" + storage.storageUtils.MetadataFileName(1)}
+		manifestListFile := ManifestListFile{SequenceNumber: 1, SnapshotId: 1, TimestampMs: time.Now().UnixMilli(), Path: metadataDirPath + "/snap-1.avro", Operation: "append"}
+
+		published, err := storage.uploadMetadataFile(metadataFile, uuid.New().String(), nil, TEST_STORAGE_ICEBERG_SCHEMAS, []IcebergPartitionSpec{{}}, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{manifestListFile})
+		testNoError(t, err)
+		if !published {
+			t.Errorf("Expected the first metadata version to be published")
+		}
+
+		published, err = storage.uploadMetadataFile(metadataFile, uuid.New().String(), nil, TEST_STORAGE_ICEBERG_SCHEMAS, []IcebergPartitionSpec{{}}, IcebergPartitionSpec{}, IcebergSortOrder{}, []ManifestListFile{manifestListFile})
+		testNoError(t, err)
+		if published {
+			t.Errorf("Expected the conditional write of an existing metadata version to be rejected")
+		}
+	})
+
+	t.Run("Deletes a schema with more than 1000 objects", func(t *testing.T) {
+		for i := range MAX_S3_DELETE_OBJECTS + 50 {
+			_, err := storage.s3Client.PutObject(context.Background(), &s3.PutObjectInput{
+				Bucket: aws.String(config.Aws.S3Bucket),
+				Key:    aws.String(fmt.Sprintf("%s/test_schema/test_table/data/%04d.parquet", config.StoragePath, i)),
+				Body:   strings.NewReader(""),
+			})
+			PanicIfError(err, config)
+		}
+
+		err := storage.DeleteSchema("test_schema")
+
+		testNoError(t, err)
+		filePaths, err := storage.ExistingFilePaths(config.StoragePath + "/test_schema")
+		testNoError(t, err)
+		if len(filePaths) != 0 {
+			t.Errorf("Expected all objects to be deleted, got %d left", len(filePaths))
+		}
+	})
+}
+
+func testEnvOrDefault(name string, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// In-memory S3 bucket served with path-style URLs
+type testS3 struct {
+	server                *httptest.Server
+	mutex                 sync.Mutex
+	objects               map[string][]byte
+	lockedKeys            Set[string]
+	putRequests           map[string]*http.Request
+	deletedBatchSizes     []int
+	throttledRequestCount int
+}
+
+type testS3ListBucketResult struct {
+	XMLName               xml.Name             `xml:"ListBucketResult"`
+	Contents              []testS3Object       `xml:"Contents"`
+	CommonPrefixes        []testS3CommonPrefix `xml:"CommonPrefixes"`
+	KeyCount              int                  `xml:"KeyCount"`
+	IsTruncated           bool                 `xml:"IsTruncated"`
+	NextContinuationToken string               `xml:"NextContinuationToken,omitempty"`
+}
+
+type testS3Object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	Size         int    `xml:"Size"`
+}
+
+type testS3CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type testS3Delete struct {
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+type testS3DeleteError struct {
+	Key     string `xml:"Key"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+type testS3DeleteResult struct {
+	XMLName xml.Name            `xml:"DeleteResult"`
+	Errors  []testS3DeleteError `xml:"Error"`
+}
+
+func newTestS3(t *testing.T) *testS3 {
+	fakeS3 := &testS3{
+		objects:     map[string][]byte{},
+		lockedKeys:  make(Set[string]),
+		putRequests: map[string]*http.Request{},
+	}
+
+	fakeS3.server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		fakeS3.mutex.Lock()
+		defer fakeS3.mutex.Unlock()
+
+		if fakeS3.throttledRequestCount > 0 {
+			fakeS3.throttledRequestCount--
+			testWriteS3Error(writer, http.StatusServiceUnavailable, "SlowDown")
+			return
+		}
+
+		bucketPath := "/" + TEST_S3_BUCKET
+		if request.URL.Path != bucketPath && !strings.HasPrefix(request.URL.Path, bucketPath+"/") {
+			testWriteS3Error(writer, http.StatusNotFound, "NoSuchBucket")
+			return
+		}
+		key := strings.TrimPrefix(strings.TrimPrefix(request.URL.Path, bucketPath), "/")
+
+		switch {
+		case request.Method == http.MethodGet && key == "" && request.URL.Query().Get("list-type") == "2":
+			fakeS3.listObjects(writer, request)
+		case request.Method == http.MethodPost && key == "" && request.URL.Query().Has("delete"):
+			fakeS3.deleteObjects(writer, request)
+		case request.Method == http.MethodPut && key != "":
+			body, _ := io.ReadAll(request.Body)
+			fakeS3.objects[key] = body
+			fakeS3.putRequests[key] = request
+			writer.WriteHeader(http.StatusOK)
+		case (request.Method == http.MethodGet || request.Method == http.MethodHead) && key != "":
+			body, found := fakeS3.objects[key]
+			if !found {
+				testWriteS3Error(writer, http.StatusNotFound, "NoSuchKey")
+				return
+			}
+			writer.Header().Set("Content-Length", IntToString(len(body)))
+			writer.WriteHeader(http.StatusOK)
+			if request.Method == http.MethodGet {
+				writer.Write(body)
+			}
+		default:
+			testWriteS3Error(writer, http.StatusNotImplemented, "NotImplemented")
+		}
+	}))
+	t.Cleanup(fakeS3.server.Close)
+
+	return fakeS3
+}
+
+func (fakeS3 *testS3) putObject(key string) {
+	fakeS3.mutex.Lock()
+	defer fakeS3.mutex.Unlock()
+	fakeS3.objects[key] = []byte{}
+}
+
+func (fakeS3 *testS3) lockObject(key string) {
+	fakeS3.mutex.Lock()
+	defer fakeS3.mutex.Unlock()
+	fakeS3.lockedKeys.Add(key)
+}
+
+func (fakeS3 *testS3) throttleRequests(count int) {
+	fakeS3.mutex.Lock()
+	defer fakeS3.mutex.Unlock()
+	fakeS3.throttledRequestCount = count
+}
+
+func (fakeS3 *testS3) objectCount() int {
+	fakeS3.mutex.Lock()
+	defer fakeS3.mutex.Unlock()
+	return len(fakeS3.objects)
+}
+
+func (fakeS3 *testS3) deleteBatchSizes() []int {
+	fakeS3.mutex.Lock()
+	defer fakeS3.mutex.Unlock()
+	return fakeS3.deletedBatchSizes
+}
+
+func (fakeS3 *testS3) lastPutRequest(key string) *http.Request {
+	fakeS3.mutex.Lock()
+	defer fakeS3.mutex.Unlock()
+	return fakeS3.putRequests[key]
+}
+
+// Returns pages of TEST_S3_PAGE_SIZE objects and common prefixes, with the key to continue after as the continuation token
+func (fakeS3 *testS3) listObjects(writer http.ResponseWriter, request *http.Request) {
+	prefix := request.URL.Query().Get("prefix")
+	delimiter := request.URL.Query().Get("delimiter")
+	continuationToken := request.URL.Query().Get("continuation-token")
+
+	keys := []string{}
+	for key := range fakeS3.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i != -1 {
+				key = key[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if key > continuationToken && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	result := testS3ListBucketResult{}
+	if len(keys) > TEST_S3_PAGE_SIZE {
+		keys = keys[:TEST_S3_PAGE_SIZE]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, key := range keys {
+		if delimiter != "" && strings.HasSuffix(key, delimiter) {
+			result.CommonPrefixes = append(result.CommonPrefixes, testS3CommonPrefix{Prefix: key})
+		} else {
+			result.Contents = append(result.Contents, testS3Object{Key: key, LastModified: "2025-01-01T00:00:00.000Z", Size: len(fakeS3.objects[key])})
+		}
+	}
+	result.KeyCount = len(keys)
+
+	testWriteXml(writer, http.StatusOK, result)
+}
+
+func (fakeS3 *testS3) deleteObjects(writer http.ResponseWriter, request *http.Request) {
+	var deleteRequest testS3Delete
+	err := xml.NewDecoder(request.Body).Decode(&deleteRequest)
+	if err != nil || len(deleteRequest.Objects) > MAX_S3_DELETE_OBJECTS {
+		testWriteS3Error(writer, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+
+	result := testS3DeleteResult{}
+	for _, object := range deleteRequest.Objects {
+		if fakeS3.lockedKeys.Contains(object.Key) {
+			result.Errors = append(result.Errors, testS3DeleteError{Key: object.Key, Code: "AccessDenied", Message: "Access Denied"})
+			continue
+		}
+		delete(fakeS3.objects, object.Key)
+	}
+	fakeS3.deletedBatchSizes = append(fakeS3.deletedBatchSizes, len(deleteRequest.Objects))
+
+	testWriteXml(writer, http.StatusOK, result)
+}
+
+func testWriteS3Error(writer http.ResponseWriter, code int, errorCode string) {
+	testWriteXml(writer, code, struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+		Message string   `xml:"Message"`
+	}{Code: errorCode, Message: errorCode})
+}
@@ -1,12 +1,13 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"reflect"
+	"slices"
 	"strings"
 	"time"
 
@@ -25,20 +26,48 @@ const (
 	PARQUET_PAGE_SIZE        = 8 * 1024          // 8 KB
 	PARQUET_COMPRESSION_TYPE = parquet.CompressionCodec_ZSTD
 
+	POSITION_DELETES_ROWS_PER_BATCH = 10000
+
 	ICEBERG_MANIFEST_STATUS_ADDED   = 1
 	ICEBERG_MANIFEST_STATUS_DELETED = 2
 
+	ICEBERG_MANIFEST_CONTENT_DATA    = 0
+	ICEBERG_MANIFEST_CONTENT_DELETES = 1
+
+	ICEBERG_DATA_FILE_CONTENT_DATA             = 0
+	ICEBERG_DATA_FILE_CONTENT_POSITION_DELETES = 1
+
 	ICEBERG_MANIFEST_LIST_OPERATION_APPEND    = "append"
 	ICEBERG_MANIFEST_LIST_OPERATION_OVERWRITE = "overwrite"
 	ICEBERG_MANIFEST_LIST_OPERATION_DELETE    = "delete"
+	ICEBERG_MANIFEST_LIST_OPERATION_REPLACE   = "replace"
+
+	ICEBERG_SNAPSHOT_REF_MAIN   = "main"
+	ICEBERG_SNAPSHOT_REF_TAG    = "tag"
+	ICEBERG_SNAPSHOT_REF_BRANCH = "branch"
+
+	ICEBERG_RESTORE_POINT_TAG_PREFIX = "bemidb-sync-"
+
+	ICEBERG_PROPERTY_FIELD_IDS_BY_ATTNUM = "bemidb.field-ids-by-attnum" // JSON object of Postgres attnums and field IDs
+
+	// Reserved field IDs of position delete file columns
+	ICEBERG_POSITION_DELETES_FILE_PATH_FIELD_ID = 2147483546
+	ICEBERG_POSITION_DELETES_POS_FIELD_ID       = 2147483545
+
+	ICEBERG_VERSION_HINT_FILE_NAME         = "version-hint.text"
+	ICEBERG_METADATA_PREVIOUS_VERSIONS_MAX = 100 // Older metadata files are deleted after a commit
+	ICEBERG_METADATA_COMMIT_ATTEMPTS       = 5
 
-	ICEBERG_METADATA_FILE_NAME  = "v1.metadata.json"
 	INTERNAL_METADATA_FILE_NAME = "bemidb.json"
+	SYNC_JOURNAL_FILE_NAME      = "bemidb-sync-journal.json"
+	SYNC_STATUS_FILE_NAME       = "bemidb-sync-status.json"
 )
 
 type MetadataJson struct {
-	Schemas []struct {
-		Fields []struct {
+	CurrentSchemaId int `json:"current-schema-id"`
+	Schemas         []struct {
+		SchemaId int `json:"schema-id"`
+		Fields   []struct {
 			ID       int         `json:"id"`
 			Name     string      `json:"name"`
 			Type     interface{} `json:"type"`
@@ -47,10 +76,18 @@ type MetadataJson struct {
 	} `json:"schemas"`
 }
 
+// Previous metadata file of the table in the metadata-log
+type MetadataLogEntry struct {
+	TimestampMs  int64  `json:"timestamp-ms"`
+	MetadataFile string `json:"metadata-file"`
+}
+
 type ManifestListsJson struct {
 	Snapshots []struct {
 		SequenceNumber int    `json:"sequence-number"`
 		SnapshotId     int64  `json:"snapshot-id"`
+		SchemaId       int    `json:"schema-id"`
+		ParentId       int64  `json:"parent-snapshot-id"`
 		TimestampMs    int64  `json:"timestamp-ms"`
 		Path           string `json:"manifest-list"`
 		Summary        struct {
@@ -61,8 +98,22 @@ type ManifestListsJson struct {
 			RemovedFilesSize string `json:"removed-files-size"`
 			DeletedDataFiles string `json:"deleted-data-files"`
 			DeletedRecords   string `json:"deleted-records"`
+			TotalDataFiles   string `json:"total-data-files"`
+			TotalFilesSize   string `json:"total-files-size"`
+			TotalRecords     string `json:"total-records"`
+
+			AddedDeleteFiles       string `json:"added-delete-files"`
+			AddedPositionDeletes   string `json:"added-position-deletes"`
+			RemovedDeleteFiles     string `json:"removed-delete-files"`
+			RemovedPositionDeletes string `json:"removed-position-deletes"`
+			TotalDeleteFiles       string `json:"total-delete-files"`
+			TotalPositionDeletes   string `json:"total-position-deletes"`
 		} `json:"summary"`
 	} `json:"snapshots"`
+	Refs map[string]struct {
+		SnapshotId int64  `json:"snapshot-id"`
+		Type       string `json:"type"`
+	} `json:"refs"`
 }
 
 type StorageUtils struct {
@@ -80,7 +131,7 @@ func (storage *StorageUtils) ParseIcebergTableFields(metadataContent []byte) ([]
 
 	var icebergTableFields []IcebergTableField
 	for _, schema := range metadataJson.Schemas {
-		if schema.Fields != nil {
+		if schema.SchemaId == metadataJson.CurrentSchemaId && schema.Fields != nil {
 			for _, field := range schema.Fields {
 				icebergTableField := IcebergTableField{
 					Name: field.Name,
@@ -104,6 +155,255 @@ func (storage *StorageUtils) ParseIcebergTableFields(metadataContent []byte) ([]
 	return icebergTableFields, nil
 }
 
+func (storage *StorageUtils) ParseIcebergSchemas(metadataContent []byte) ([]IcebergSchema, error) {
+	var metadataJson struct {
+		CurrentSchemaId int               `json:"current-schema-id"`
+		Schemas         []IcebergSchema   `json:"schemas"`
+		Properties      map[string]string `json:"properties"`
+	}
+	err := json.Unmarshal(metadataContent, &metadataJson)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tables written by older versions don't have the attnums
+	fieldIdsByAttnumJson, found := metadataJson.Properties[ICEBERG_PROPERTY_FIELD_IDS_BY_ATTNUM]
+	if found {
+		var fieldIdsByAttnum map[int]int
+		err = json.Unmarshal([]byte(fieldIdsByAttnumJson), &fieldIdsByAttnum)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the %s property: %v", ICEBERG_PROPERTY_FIELD_IDS_BY_ATTNUM, err)
+		}
+		for i, schema := range metadataJson.Schemas {
+			if schema.SchemaId == metadataJson.CurrentSchemaId {
+				metadataJson.Schemas[i].FieldIdsByAttnum = fieldIdsByAttnum
+			}
+		}
+	}
+
+	return metadataJson.Schemas, nil
+}
+
+// Returns the default partition spec of the table
+func (storage *StorageUtils) ParseIcebergPartitionSpec(metadataContent []byte) (IcebergPartitionSpec, error) {
+	partitionSpecsSortedAsc, defaultSpecId, err := storage.parseIcebergPartitionSpecs(metadataContent)
+	if err != nil {
+		return IcebergPartitionSpec{}, err
+	}
+
+	for _, partitionSpec := range partitionSpecsSortedAsc {
+		if partitionSpec.SpecId == defaultSpecId {
+			return partitionSpec, nil
+		}
+	}
+	return IcebergPartitionSpec{SpecId: defaultSpecId}, nil
+}
+
+// Returns all partition specs of the table, including the ones of data files written before the partitioning changed
+func (storage *StorageUtils) ParseIcebergPartitionSpecs(metadataContent []byte) ([]IcebergPartitionSpec, error) {
+	partitionSpecsSortedAsc, _, err := storage.parseIcebergPartitionSpecs(metadataContent)
+	return partitionSpecsSortedAsc, err
+}
+
+func (storage *StorageUtils) parseIcebergPartitionSpecs(metadataContent []byte) (partitionSpecsSortedAsc []IcebergPartitionSpec, defaultSpecId int, err error) {
+	var metadataJson struct {
+		CurrentSchemaId int             `json:"current-schema-id"`
+		Schemas         []IcebergSchema `json:"schemas"`
+		DefaultSpecId   int             `json:"default-spec-id"`
+		PartitionSpecs  []struct {
+			SpecId int `json:"spec-id"`
+			Fields []struct {
+				SourceId  int    `json:"source-id"`
+				FieldId   int    `json:"field-id"`
+				Name      string `json:"name"`
+				Transform string `json:"transform"`
+			} `json:"fields"`
+		} `json:"partition-specs"`
+	}
+	err = json.Unmarshal(metadataContent, &metadataJson)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var currentSchemaFields []IcebergSchemaField
+	for _, schema := range metadataJson.Schemas {
+		if schema.SchemaId == metadataJson.CurrentSchemaId {
+			currentSchemaFields = schema.Fields
+		}
+	}
+
+	partitionSpecsSortedAsc = []IcebergPartitionSpec{}
+	for _, spec := range metadataJson.PartitionSpecs {
+		partitionSpec := IcebergPartitionSpec{SpecId: spec.SpecId}
+		for _, field := range spec.Fields {
+			// Empty for columns removed from the current schema, which only previous specs can reference
+			sourceType := ""
+			for _, schemaField := range currentSchemaFields {
+				if schemaField.Id == field.SourceId {
+					sourceType = icebergPartitionSourceTypeOfField(schemaField)
+				}
+			}
+
+			partitionSpec.Fields = append(partitionSpec.Fields, IcebergPartitionField{
+				SourceId:   field.SourceId,
+				FieldId:    field.FieldId,
+				Name:       field.Name,
+				Transform:  field.Transform,
+				ResultType: icebergPartitionResultType(field.Transform, sourceType),
+			})
+		}
+		partitionSpecsSortedAsc = append(partitionSpecsSortedAsc, partitionSpec)
+	}
+	slices.SortFunc(partitionSpecsSortedAsc, func(a, b IcebergPartitionSpec) int {
+		return a.SpecId - b.SpecId
+	})
+
+	return partitionSpecsSortedAsc, metadataJson.DefaultSpecId, nil
+}
+
+// Returns the partition specs of the new table metadata: the existing ones and the new default spec if it was added
+func (storage *StorageUtils) MetadataPartitionSpecs(currentMetadataContent []byte, partitionSpec IcebergPartitionSpec) ([]IcebergPartitionSpec, error) {
+	partitionSpecsSortedAsc := []IcebergPartitionSpec{}
+	if currentMetadataContent != nil {
+		existingPartitionSpecsSortedAsc, err := storage.ParseIcebergPartitionSpecs(currentMetadataContent)
+		if err != nil {
+			return nil, err
+		}
+		for _, existingPartitionSpec := range existingPartitionSpecsSortedAsc {
+			if existingPartitionSpec.SpecId != partitionSpec.SpecId {
+				partitionSpecsSortedAsc = append(partitionSpecsSortedAsc, existingPartitionSpec)
+			}
+		}
+	}
+
+	partitionSpecsSortedAsc = append(partitionSpecsSortedAsc, partitionSpec)
+	slices.SortFunc(partitionSpecsSortedAsc, func(a, b IcebergPartitionSpec) int {
+		return a.SpecId - b.SpecId
+	})
+	return partitionSpecsSortedAsc, nil
+}
+
+func (storage *StorageUtils) ParseIcebergSortOrder(metadataContent []byte) (IcebergSortOrder, error) {
+	var metadataJson struct {
+		DefaultSortOrderId int `json:"default-sort-order-id"`
+		SortOrders         []struct {
+			OrderId int `json:"order-id"`
+			Fields  []struct {
+				SourceId  int    `json:"source-id"`
+				Transform string `json:"transform"`
+				Direction string `json:"direction"`
+				NullOrder string `json:"null-order"`
+			} `json:"fields"`
+		} `json:"sort-orders"`
+	}
+	err := json.Unmarshal(metadataContent, &metadataJson)
+	if err != nil {
+		return IcebergSortOrder{}, err
+	}
+
+	sortOrder := IcebergSortOrder{OrderId: metadataJson.DefaultSortOrderId}
+	for _, order := range metadataJson.SortOrders {
+		if order.OrderId != metadataJson.DefaultSortOrderId {
+			continue
+		}
+
+		for _, field := range order.Fields {
+			sortOrder.Fields = append(sortOrder.Fields, IcebergSortField{
+				SourceId:  field.SourceId,
+				Transform: field.Transform,
+				Direction: field.Direction,
+				NullOrder: field.NullOrder,
+			})
+		}
+	}
+
+	return sortOrder, nil
+}
+
+// Example: v3.metadata.json
+func (storage *StorageUtils) MetadataFileName(version int64) string {
+	return "v" + Int64ToString(version) + ".metadata.json"
+}
+
+func (storage *StorageUtils) ParseVersionHint(versionHintContent []byte) (int64, error) {
+	version, err := StringToInt64(strings.TrimSpace(string(versionHintContent)))
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid version hint: %s", string(versionHintContent))
+	}
+	return version, nil
+}
+
+// A commit must keep the current snapshot of the table, otherwise it was based on metadata changed by a concurrent commit in the meantime
+func (storage *StorageUtils) ValidateMetadataCommit(metadataDirPath string, currentMetadataContent []byte, manifestListFilesSortedAsc []ManifestListFile) error {
+	if currentMetadataContent == nil {
+		return nil
+	}
+
+	currentManifestListFilesSortedAsc, err := storage.ParseManifestListFiles("", currentMetadataContent)
+	if err != nil {
+		return err
+	}
+	if len(currentManifestListFilesSortedAsc) == 0 {
+		return nil
+	}
+
+	currentSnapshotId := currentManifestListFilesSortedAsc[len(currentManifestListFilesSortedAsc)-1].SnapshotId
+	for _, manifestListFile := range manifestListFilesSortedAsc {
+		if manifestListFile.SnapshotId == currentSnapshotId {
+			return nil
+		}
+	}
+	return &MetadataCommitConflictError{MetadataDirPath: metadataDirPath}
+}
+
+// Returns the UUID of the table, which stays the same across metadata versions, or a new UUID for a new table
+func (storage *StorageUtils) TableUuid(currentMetadataContent []byte) (string, error) {
+	if currentMetadataContent == nil {
+		return uuid.New().String(), nil
+	}
+
+	var metadataJson struct {
+		TableUuid string `json:"table-uuid"`
+	}
+	err := json.Unmarshal(currentMetadataContent, &metadataJson)
+	if err != nil {
+		return "", err
+	}
+	if metadataJson.TableUuid == "" {
+		return uuid.New().String(), nil
+	}
+	return metadataJson.TableUuid, nil
+}
+
+// Returns the metadata log of the next metadata version ending with the current metadata file,
+// and the paths of the oldest metadata files no longer in the log
+func (storage *StorageUtils) NextMetadataLog(fileSystemPrefix string, currentMetadataFile MetadataFile, currentMetadataContent []byte) (metadataLog []MetadataLogEntry, expiredMetadataFilePaths []string, err error) {
+	if currentMetadataContent == nil {
+		return []MetadataLogEntry{}, []string{}, nil
+	}
+
+	var metadataJson struct {
+		LastUpdatedMs int64              `json:"last-updated-ms"`
+		MetadataLog   []MetadataLogEntry `json:"metadata-log"`
+	}
+	err = json.Unmarshal(currentMetadataContent, &metadataJson)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metadataLog = append(metadataJson.MetadataLog, MetadataLogEntry{
+		TimestampMs:  metadataJson.LastUpdatedMs,
+		MetadataFile: fileSystemPrefix + currentMetadataFile.Path,
+	})
+	expiredMetadataFilePaths = []string{}
+	for len(metadataLog) > ICEBERG_METADATA_PREVIOUS_VERSIONS_MAX {
+		expiredMetadataFilePaths = append(expiredMetadataFilePaths, strings.TrimPrefix(metadataLog[0].MetadataFile, fileSystemPrefix))
+		metadataLog = metadataLog[1:]
+	}
+
+	return metadataLog, expiredMetadataFilePaths, nil
+}
+
 func (storage *StorageUtils) ParseInternalTableMetadata(internalMetadataContent []byte) (InternalTableMetadata, error) {
 	var internalTableMetadata InternalTableMetadata
 	err := json.Unmarshal(internalMetadataContent, &internalTableMetadata)
@@ -113,6 +413,24 @@ func (storage *StorageUtils) ParseInternalTableMetadata(internalMetadataContent
 	return internalTableMetadata, nil
 }
 
+func (storage *StorageUtils) ParseSyncJournal(syncJournalContent []byte) (*SyncJournal, error) {
+	var syncJournal SyncJournal
+	err := json.Unmarshal(syncJournalContent, &syncJournal)
+	if err != nil {
+		return nil, err
+	}
+	return &syncJournal, nil
+}
+
+func (storage *StorageUtils) ParseSyncStatus(syncStatusContent []byte) (SyncStatus, error) {
+	var syncStatus SyncStatus
+	err := json.Unmarshal(syncStatusContent, &syncStatus)
+	if err != nil {
+		return SyncStatus{}, err
+	}
+	return syncStatus, nil
+}
+
 func (storage *StorageUtils) ParseManifestListFiles(fileSystemPrefix string, metadataContent []byte) ([]ManifestListFile, error) {
 	var manifestListsJson ManifestListsJson
 	err := json.Unmarshal(metadataContent, &manifestListsJson)
@@ -146,10 +464,48 @@ func (storage *StorageUtils) ParseManifestListFiles(fileSystemPrefix string, met
 		if err != nil {
 			return nil, err
 		}
+		totalDataFiles, err := StringToInt64(snapshot.Summary.TotalDataFiles)
+		if err != nil {
+			return nil, err
+		}
+		totalFilesSize, err := StringToInt64(snapshot.Summary.TotalFilesSize)
+		if err != nil {
+			return nil, err
+		}
+		totalRecords, err := StringToInt64(snapshot.Summary.TotalRecords)
+		if err != nil {
+			return nil, err
+		}
+		addedDeleteFiles, err := storage.parseSnapshotDeleteCount(snapshot.Summary.AddedDeleteFiles)
+		if err != nil {
+			return nil, err
+		}
+		addedPositionDeletes, err := storage.parseSnapshotDeleteCount(snapshot.Summary.AddedPositionDeletes)
+		if err != nil {
+			return nil, err
+		}
+		removedDeleteFiles, err := storage.parseSnapshotDeleteCount(snapshot.Summary.RemovedDeleteFiles)
+		if err != nil {
+			return nil, err
+		}
+		removedPositionDeletes, err := storage.parseSnapshotDeleteCount(snapshot.Summary.RemovedPositionDeletes)
+		if err != nil {
+			return nil, err
+		}
+		totalDeleteFiles, err := storage.parseSnapshotDeleteCount(snapshot.Summary.TotalDeleteFiles)
+		if err != nil {
+			return nil, err
+		}
+		totalPositionDeletes, err := storage.parseSnapshotDeleteCount(snapshot.Summary.TotalPositionDeletes)
+		if err != nil {
+			return nil, err
+		}
 
 		manifestListFile := ManifestListFile{
 			SequenceNumber:   snapshot.SequenceNumber,
 			SnapshotId:       snapshot.SnapshotId,
+			SchemaId:         snapshot.SchemaId,
+			ParentSnapshotId: snapshot.ParentId,
 			TimestampMs:      snapshot.TimestampMs,
 			Path:             strings.TrimPrefix(snapshot.Path, fileSystemPrefix),
 			Operation:        snapshot.Summary.Operation,
@@ -159,14 +515,48 @@ func (storage *StorageUtils) ParseManifestListFiles(fileSystemPrefix string, met
 			RemovedFilesSize: removedFilesSize,
 			DeletedDataFiles: deletedDataFiles,
 			DeletedRecords:   deletedRecords,
+			TotalDataFiles:   totalDataFiles,
+			TotalFilesSize:   totalFilesSize,
+			TotalRecords:     totalRecords,
+
+			AddedDeleteFiles:       addedDeleteFiles,
+			AddedPositionDeletes:   addedPositionDeletes,
+			RemovedDeleteFiles:     removedDeleteFiles,
+			RemovedPositionDeletes: removedPositionDeletes,
+			TotalDeleteFiles:       totalDeleteFiles,
+			TotalPositionDeletes:   totalPositionDeletes,
 		}
 
 		manifestListFilesSortedAsc = append(manifestListFilesSortedAsc, manifestListFile)
 	}
 
+	// Refs of expired snapshots are dropped
+	refNames := []string{}
+	for refName := range manifestListsJson.Refs {
+		refNames = append(refNames, refName)
+	}
+	slices.Sort(refNames)
+	for _, refName := range refNames {
+		ref := manifestListsJson.Refs[refName]
+		i := slices.IndexFunc(manifestListFilesSortedAsc, func(manifestListFile ManifestListFile) bool {
+			return manifestListFile.SnapshotId == ref.SnapshotId
+		})
+		if refName != ICEBERG_SNAPSHOT_REF_MAIN && i != -1 {
+			manifestListFilesSortedAsc[i].Refs = append(manifestListFilesSortedAsc[i].Refs, SnapshotRef{Name: refName, Type: ref.Type})
+		}
+	}
+
 	return manifestListFilesSortedAsc, nil
 }
 
+// Delete file counts are missing in the metadata written before merge-on-read
+func (storage *StorageUtils) parseSnapshotDeleteCount(deleteCount string) (int64, error) {
+	if deleteCount == "" {
+		return 0, nil
+	}
+	return StringToInt64(deleteCount)
+}
+
 func (storage *StorageUtils) ParseManifestFiles(fileSystemPrefix string, manifestListContent []byte) ([]ManifestListItem, error) {
 	ocfReader, err := goavro.NewOCFReader(strings.NewReader(string(manifestListContent)))
 	if err != nil {
@@ -185,10 +575,13 @@ func (storage *StorageUtils) ParseManifestFiles(fileSystemPrefix string, manifes
 
 		manifestListItemsSortedDesc = append(manifestListItemsSortedDesc, ManifestListItem{
 			ManifestFile: ManifestFile{
-				SnapshotId:  recordMap["added_snapshot_id"].(int64),
-				Path:        strings.TrimPrefix(recordMap["manifest_path"].(string), fileSystemPrefix),
-				Size:        recordMap["manifest_length"].(int64),
-				RecordCount: recordMap["added_rows_count"].(int64),
+				PositionDeletes: recordMap["content"].(int32) == ICEBERG_MANIFEST_CONTENT_DELETES,
+				SnapshotId:      recordMap["added_snapshot_id"].(int64),
+				Path:            strings.TrimPrefix(recordMap["manifest_path"].(string), fileSystemPrefix),
+				Size:            recordMap["manifest_length"].(int64),
+				RecordCount:     recordMap["added_rows_count"].(int64),
+				RecordsDeleted:  recordMap["deleted_files_count"].(int32) > 0,
+				PartitionSpecId: int(recordMap["partition_spec_id"].(int32)),
 			},
 			SequenceNumber: int(recordMap["sequence_number"].(int64)),
 		})
@@ -198,21 +591,154 @@ func (storage *StorageUtils) ParseManifestFiles(fileSystemPrefix string, manifes
 }
 
 func (storage *StorageUtils) ParseParquetFilePath(fileSystemPrefix string, manifestContent []byte) (string, error) {
-	ocfReader, err := goavro.NewOCFReader(strings.NewReader(string(manifestContent)))
+	parquetFile, err := storage.ParseParquetFile(fileSystemPrefix, manifestContent)
 	if err != nil {
 		return "", err
 	}
 
+	return parquetFile.Path, nil
+}
+
+// Returns the data file of a manifest with its stats, partition values and sort order
+func (storage *StorageUtils) ParseParquetFile(fileSystemPrefix string, manifestContent []byte) (ParquetFile, error) {
+	ocfReader, err := goavro.NewOCFReader(strings.NewReader(string(manifestContent)))
+	if err != nil {
+		return ParquetFile{}, err
+	}
+
 	ocfReader.Scan()
 	record, err := ocfReader.Read()
 	if err != nil {
-		return "", err
+		return ParquetFile{}, err
 	}
 
 	recordMap := record.(map[string]interface{})
 	dataFile := recordMap["data_file"].(map[string]interface{})
+	filePath := strings.TrimPrefix(dataFile["file_path"].(string), fileSystemPrefix)
 
-	return strings.TrimPrefix(dataFile["file_path"].(string), fileSystemPrefix), nil
+	partitionValues, err := storage.parseManifestPartitionValues(ocfReader.MetaData()["partition-spec"], dataFile["partition"])
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	sortOrderId := ICEBERG_SORT_ORDER_ID_UNSORTED
+	if avroSortOrderId, ok := dataFile["sort_order_id"].(map[string]interface{}); ok {
+		sortOrderId = int(avroSortOrderId["int"].(int32))
+	}
+
+	fileName := filePath[strings.LastIndex(filePath, "/")+1:]
+	return ParquetFile{
+		Uuid:        strings.TrimSuffix(strings.TrimPrefix(fileName, "00000-0-"), ".parquet"),
+		Path:        filePath,
+		Size:        dataFile["file_size_in_bytes"].(int64),
+		RecordCount: dataFile["record_count"].(int64),
+		Stats: ParquetFileStats{
+			ColumnSizes:     storage.parseManifestCounts(dataFile["column_sizes"]),
+			ValueCounts:     storage.parseManifestCounts(dataFile["value_counts"]),
+			NullValueCounts: storage.parseManifestCounts(dataFile["null_value_counts"]),
+			LowerBounds:     storage.parseManifestBounds(dataFile["lower_bounds"]),
+			UpperBounds:     storage.parseManifestBounds(dataFile["upper_bounds"]),
+			SplitOffsets:    storage.parseManifestSplitOffsets(dataFile["split_offsets"]),
+		},
+		PartitionValues: partitionValues,
+		SortOrderId:     sortOrderId,
+		PositionDeletes: dataFile["content"].(int32) == ICEBERG_DATA_FILE_CONTENT_POSITION_DELETES,
+	}, nil
+}
+
+// Partition values in the order of the partition spec stored in the manifest metadata, nil for unpartitioned tables
+func (storage *StorageUtils) parseManifestPartitionValues(partitionSpecJson []byte, avroPartition interface{}) ([]*string, error) {
+	if len(partitionSpecJson) == 0 {
+		return nil, nil
+	}
+
+	var partitionFields []struct {
+		Name string `json:"name"`
+	}
+	err := json.Unmarshal(partitionSpecJson, &partitionFields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest partition spec: %v", err)
+	}
+	if len(partitionFields) == 0 {
+		return nil, nil
+	}
+
+	avroPartitionMap, _ := avroPartition.(map[string]interface{})
+	partitionValues := make([]*string, len(partitionFields))
+	for i, partitionField := range partitionFields {
+		avroValueUnion, ok := avroPartitionMap[partitionField.Name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, avroValue := range avroValueUnion {
+			value := fmt.Sprint(avroValue)
+			partitionValues[i] = &value
+		}
+	}
+	return partitionValues, nil
+}
+
+func (storage *StorageUtils) parseManifestCounts(avroCounts interface{}) map[int]int64 {
+	counts := make(map[int]int64)
+	avroCountsUnion, ok := avroCounts.(map[string]interface{})
+	if !ok {
+		return counts
+	}
+	for _, avroCount := range avroCountsUnion["array"].([]interface{}) {
+		avroCountMap := avroCount.(map[string]interface{})
+		counts[int(avroCountMap["key"].(int32))] = avroCountMap["value"].(int64)
+	}
+	return counts
+}
+
+func (storage *StorageUtils) parseManifestSplitOffsets(avroSplitOffsets interface{}) []int64 {
+	splitOffsets := []int64{}
+	avroSplitOffsetsUnion, ok := avroSplitOffsets.(map[string]interface{})
+	if !ok {
+		return splitOffsets
+	}
+	for _, avroSplitOffset := range avroSplitOffsetsUnion["array"].([]interface{}) {
+		splitOffsets = append(splitOffsets, avroSplitOffset.(int64))
+	}
+	return splitOffsets
+}
+
+func (storage *StorageUtils) parseManifestBounds(avroBounds interface{}) map[int][]byte {
+	bounds := make(map[int][]byte)
+	avroBoundsUnion, ok := avroBounds.(map[string]interface{})
+	if !ok {
+		return bounds
+	}
+	for _, avroBound := range avroBoundsUnion["array"].([]interface{}) {
+		avroBoundMap := avroBound.(map[string]interface{})
+		bounds[int(avroBoundMap["key"].(int32))] = avroBoundMap["value"].([]byte)
+	}
+	return bounds
+}
+
+// Returns the distinct data files referenced by position delete files
+func (storage *StorageUtils) ReadDeletedParquetFilePaths(fileSystemPrefix string, positionDeletesFilePaths []string) ([]string, error) {
+	if len(positionDeletesFilePaths) == 0 {
+		return []string{}, nil
+	}
+
+	duckdb := NewDuckdb(storage.config, false)
+	defer duckdb.Close()
+
+	rows, err := duckdb.QueryContext(context.Background(), "SELECT DISTINCT file_path FROM read_parquet("+storage.parquetPathsSql(fileSystemPrefix, positionDeletesFilePaths)+") ORDER BY file_path")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read position delete files: %v", err)
+	}
+	defer rows.Close()
+
+	parquetFilePaths := []string{}
+	for rows.Next() {
+		var parquetFilePath string
+		if err = rows.Scan(&parquetFilePath); err != nil {
+			return nil, fmt.Errorf("failed to scan position delete file: %v", err)
+		}
+		parquetFilePaths = append(parquetFilePaths, strings.TrimPrefix(parquetFilePath, fileSystemPrefix))
+	}
+	return parquetFilePaths, rows.Err()
 }
 
 // Write ---------------------------------------------------------------------------------------------------------------
@@ -265,18 +791,22 @@ func (storage *StorageUtils) WriteParquetFile(fileWriter source.ParquetFile, pgS
 	return recordCount, loadedAllRows, nil
 }
 
-func (storage *StorageUtils) NewDuckDBIfHasOverlappingRows(fileSystemPrefix string, existingParquetFilePath string, newParquetFilePath string, pgSchemaColumns []PgSchemaColumn) (*Duckdb, error) {
+func (storage *StorageUtils) NewDuckDBIfHasOverlappingRows(fileSystemPrefix string, existingParquetFilePath string, newParquetFilePaths []string, pgSchemaColumns []PgSchemaColumn) (*Duckdb, error) {
 	duckdb := NewDuckdb(storage.config, false)
 
 	ctx := context.Background()
-	_, err := duckdb.ExecContext(ctx, "CREATE TABLE existing_parquet AS SELECT * FROM '$parquetPath'", map[string]string{
-		"parquetPath": fileSystemPrefix + existingParquetFilePath,
-	})
+	_, err := duckdb.ExecContext(ctx, "CREATE TABLE new_parquet AS SELECT * FROM read_parquet("+storage.parquetPathsSql(fileSystemPrefix, newParquetFilePaths)+")", nil)
 	if err != nil {
 		return nil, err
 	}
-	_, err = duckdb.ExecContext(ctx, "CREATE TABLE new_parquet AS SELECT * FROM '$parquetPath'", map[string]string{
-		"parquetPath": fileSystemPrefix + newParquetFilePath,
+	// Columns added since the existing Parquet file was written are NULL with the types of the new Parquet file
+	existingSelectList, err := storage.existingParquetSelectList(duckdb, fileSystemPrefix+existingParquetFilePath, pgSchemaColumns)
+	if err != nil {
+		return nil, err
+	}
+	_, err = duckdb.ExecContext(ctx, "CREATE TABLE existing_parquet AS SELECT $selectList FROM '$parquetPath' UNION ALL BY NAME (SELECT * FROM new_parquet LIMIT 0)", map[string]string{
+		"selectList":  existingSelectList,
+		"parquetPath": fileSystemPrefix + existingParquetFilePath,
 	})
 	if err != nil {
 		return nil, err
@@ -361,7 +891,174 @@ func (storage *StorageUtils) WriteOverwrittenParquetFile(duckdb *Duckdb, fileWri
 	return recordCount, nil
 }
 
-func (storage *StorageUtils) ReadParquetStats(fileReader source.ParquetFile) (parquetFileStats ParquetFileStats, err error) {
+// Writes the positions of rows in existing Parquet files with the same key column values as rows in the new Parquet files
+func (storage *StorageUtils) WritePositionDeletesParquetFile(fileSystemPrefix string, existingParquetFilePaths []string, newParquetFilePaths []string, keyPgSchemaColumns []PgSchemaColumn, fileWriter source.ParquetFile) (recordCount int64, err error) {
+	duckdb := NewDuckdb(storage.config, false)
+	defer duckdb.Close()
+
+	ctx := context.Background()
+	newSelectExpressions := []string{}
+	for _, keyPgSchemaColumn := range keyPgSchemaColumns {
+		newSelectExpressions = append(newSelectExpressions, storage.quoteIdentifier(keyPgSchemaColumn.NormalizedColumnName()))
+	}
+	_, err = duckdb.ExecContext(ctx, "CREATE TABLE new_parquet AS SELECT "+strings.Join(newSelectExpressions, ", ")+" FROM read_parquet("+storage.parquetPathsSql(fileSystemPrefix, newParquetFilePaths)+")", nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read new Parquet file: %v", err)
+	}
+
+	// Existing Parquet files can be written with older schemas, so their key columns are matched by field ID
+	selectSqls := []string{}
+	for _, existingParquetFilePath := range existingParquetFilePaths {
+		parquetPath := fileSystemPrefix + existingParquetFilePath
+		existingColumnNames, err := storage.existingParquetColumnNames(duckdb, parquetPath)
+		if err != nil {
+			return 0, err
+		}
+
+		whereConditions := []string{}
+		for _, keyPgSchemaColumn := range keyPgSchemaColumns {
+			existingColumnName, found := existingColumnNames[keyPgSchemaColumn.FieldId]
+			if !found {
+				break // NULLs of a column added later don't match any row
+			}
+			whereConditions = append(whereConditions, "existing_parquet."+storage.quoteIdentifier(existingColumnName)+" = new_parquet."+storage.quoteIdentifier(keyPgSchemaColumn.NormalizedColumnName()))
+		}
+		if len(whereConditions) < len(keyPgSchemaColumns) {
+			continue
+		}
+
+		quotedParquetPath := "'" + strings.ReplaceAll(parquetPath, "'", "''") + "'"
+		selectSqls = append(selectSqls, "SELECT "+quotedParquetPath+" AS file_path, file_row_number AS pos FROM read_parquet("+quotedParquetPath+", file_row_number = true) existing_parquet WHERE EXISTS (SELECT 1 FROM new_parquet WHERE "+strings.Join(whereConditions, " AND ")+")")
+	}
+
+	loadRows := func() [][]string { return [][]string{} }
+	if len(selectSqls) > 0 {
+		rows, err := duckdb.QueryContext(ctx, "SELECT file_path, pos FROM ("+strings.Join(selectSqls, " UNION ALL ")+") ORDER BY file_path, pos")
+		if err != nil {
+			return 0, fmt.Errorf("failed to query deleted positions: %v", err)
+		}
+		defer rows.Close()
+
+		loadRows = func() [][]string {
+			positionDeletesRows := [][]string{}
+			for len(positionDeletesRows) < POSITION_DELETES_ROWS_PER_BATCH && rows.Next() {
+				var filePath string
+				var pos int64
+				err := rows.Scan(&filePath, &pos)
+				PanicIfError(err, storage.config)
+				positionDeletesRows = append(positionDeletesRows, []string{filePath, Int64ToString(pos)})
+			}
+			PanicIfError(rows.Err(), storage.config)
+			return positionDeletesRows
+		}
+	}
+
+	recordCount, _, err = storage.WriteParquetFile(fileWriter, storage.PositionDeletesPgSchemaColumns(), loadRows, 0)
+	return recordCount, err
+}
+
+// Writes the rows of multiple Parquet files into a single Parquet file, sorted by the sort order if it has fields.
+// Rows deleted by position delete files are skipped
+func (storage *StorageUtils) WriteCompactedParquetFile(fileSystemPrefix string, parquetFilePaths []string, positionDeletesFilePaths []string, fileWriter source.ParquetFile, pgSchemaColumns []PgSchemaColumn, sortOrder IcebergSortOrder) (recordCount int64, err error) {
+	defer fileWriter.Close()
+
+	duckdb := NewDuckdb(storage.config, false)
+	defer duckdb.Close()
+
+	if len(positionDeletesFilePaths) > 0 {
+		_, err = duckdb.ExecContext(context.Background(), "CREATE TABLE position_deletes AS SELECT file_path, pos FROM read_parquet("+storage.parquetPathsSql(fileSystemPrefix, positionDeletesFilePaths)+")", nil)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read position delete files: %v", err)
+		}
+	}
+
+	// Parquet files can be written with older schemas, so their columns are matched by field ID
+	selectSqls := []string{}
+	existingFieldIds := make(Set[int])
+	for _, parquetFilePath := range parquetFilePaths {
+		parquetPath := fileSystemPrefix + parquetFilePath
+		existingColumnNames, err := storage.existingParquetColumnNames(duckdb, parquetPath)
+		if err != nil {
+			return 0, err
+		}
+		for fieldId := range existingColumnNames {
+			existingFieldIds.Add(fieldId)
+		}
+
+		selectList, err := storage.existingParquetSelectList(duckdb, parquetPath, pgSchemaColumns)
+		if err != nil {
+			return 0, err
+		}
+		quotedParquetPath := "'" + strings.ReplaceAll(parquetPath, "'", "''") + "'"
+		if len(positionDeletesFilePaths) > 0 {
+			selectSqls = append(selectSqls, "SELECT "+selectList+" FROM read_parquet("+quotedParquetPath+", file_row_number = true) WHERE file_row_number NOT IN (SELECT pos FROM position_deletes WHERE file_path = "+quotedParquetPath+")")
+		} else {
+			selectSqls = append(selectSqls, "SELECT "+selectList+" FROM "+quotedParquetPath)
+		}
+	}
+
+	// Columns missing in all Parquet files are written as NULLs
+	structPackExpressions := []string{}
+	for _, pgSchemaColumn := range pgSchemaColumns {
+		if existingFieldIds.Contains(pgSchemaColumn.FieldId) {
+			columnName := storage.quoteIdentifier(pgSchemaColumn.NormalizedColumnName())
+			structPackExpressions = append(structPackExpressions, columnName+" := "+storage.parquetValueSql(pgSchemaColumn, "compacted_parquet."+columnName))
+		}
+	}
+	sql := "SELECT to_json(struct_pack(" + strings.Join(structPackExpressions, ", ") + ")) FROM (" + strings.Join(selectSqls, " UNION ALL BY NAME ") + ") compacted_parquet"
+
+	orderByExpressions := []string{}
+	for _, field := range sortOrder.Fields {
+		for _, pgSchemaColumn := range pgSchemaColumns {
+			if pgSchemaColumn.FieldId == field.SourceId && existingFieldIds.Contains(field.SourceId) {
+				orderByExpressions = append(orderByExpressions, "compacted_parquet."+storage.quoteIdentifier(pgSchemaColumn.NormalizedColumnName())+" "+strings.ToUpper(field.Direction)+" "+strings.ToUpper(strings.ReplaceAll(field.NullOrder, "-", " ")))
+			}
+		}
+	}
+	if len(orderByExpressions) > 0 {
+		sql += " ORDER BY " + strings.Join(orderByExpressions, ", ")
+	}
+
+	schemaJson := storage.buildSchemaJson(pgSchemaColumns)
+	LogDebug(storage.config, "Parquet schema:", schemaJson)
+	parquetWriter, err := writer.NewJSONWriter(schemaJson, fileWriter, PARQUET_PARALLEL_NUMBER)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create Parquet writer: %v", err)
+	}
+	parquetWriter.RowGroupSize = PARQUET_ROW_GROUP_SIZE
+	parquetWriter.PageSize = PARQUET_PAGE_SIZE
+	parquetWriter.CompressionType = PARQUET_COMPRESSION_TYPE
+
+	rows, err := duckdb.QueryContext(context.Background(), sql)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query compacted rows: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rowJson string
+		if err = rows.Scan(&rowJson); err != nil {
+			return 0, fmt.Errorf("failed to scan row: %v", err)
+		}
+
+		if err = parquetWriter.Write(rowJson); err != nil {
+			return 0, fmt.Errorf("Write error: %v", err)
+		}
+		recordCount++
+	}
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read compacted rows: %v", err)
+	}
+
+	LogDebug(storage.config, "Stopping Parquet writer...")
+	if err := parquetWriter.WriteStop(); err != nil {
+		return 0, fmt.Errorf("failed to stop Parquet writer: %v", err)
+	}
+
+	return recordCount, nil
+}
+
+func (storage *StorageUtils) ReadParquetStats(fileReader source.ParquetFile, pgSchemaColumns []PgSchemaColumn) (parquetFileStats ParquetFileStats, err error) {
 	defer fileReader.Close()
 
 	pr, err := reader.NewParquetReader(fileReader, nil, 1)
@@ -380,6 +1077,13 @@ func (storage *StorageUtils) ReadParquetStats(fileReader source.ParquetFile) (pa
 	}
 
 	fieldIDMap := storage.buildFieldIDMap(pr.SchemaHandler)
+	pgSchemaColumnsByFieldId := make(map[int]PgSchemaColumn)
+	for _, pgSchemaColumn := range pgSchemaColumns {
+		pgSchemaColumnsByFieldId[pgSchemaColumn.FieldId] = pgSchemaColumn
+	}
+	lowerBoundValues := make(map[int]interface{})
+	upperBoundValues := make(map[int]interface{})
+	fieldIdsWithoutBounds := make(map[int]bool)
 
 	for _, rowGroup := range pr.Footer.RowGroups {
 		if rowGroup.FileOffset != nil {
@@ -401,28 +1105,52 @@ func (storage *StorageUtils) ReadParquetStats(fileReader source.ParquetFile) (pa
 				if columnMetaData.Statistics.NullCount != nil {
 					parquetStats.NullValueCounts[fieldID] += *columnMetaData.Statistics.NullCount
 				}
+			}
 
-				minValue := columnMetaData.Statistics.Min
-				maxValue := columnMetaData.Statistics.Max
-
-				if parquetStats.LowerBounds[fieldID] == nil || bytes.Compare(parquetStats.LowerBounds[fieldID], minValue) > 0 {
-					parquetStats.LowerBounds[fieldID] = minValue
-				}
-				if parquetStats.UpperBounds[fieldID] == nil || bytes.Compare(parquetStats.UpperBounds[fieldID], maxValue) < 0 {
-					parquetStats.UpperBounds[fieldID] = maxValue
+			// A file has bounds for a column only if all its row groups have min/max values, e.g. not if one of them has only nulls
+			pgSchemaColumn, ok := pgSchemaColumnsByFieldId[fieldID]
+			if !ok || columnMetaData.Statistics == nil {
+				fieldIdsWithoutBounds[fieldID] = true
+				continue
+			}
+			minValue, minOk := icebergBoundValue(pgSchemaColumn, columnMetaData.Statistics.MinValue)
+			maxValue, maxOk := icebergBoundValue(pgSchemaColumn, columnMetaData.Statistics.MaxValue)
+			if !minOk || !maxOk {
+				if columnMetaData.Statistics.NullCount == nil || *columnMetaData.Statistics.NullCount != columnMetaData.NumValues {
+					fieldIdsWithoutBounds[fieldID] = true
 				}
+				continue
+			}
+
+			if lowerBoundValues[fieldID] == nil || compareIcebergBoundValues(lowerBoundValues[fieldID], minValue) > 0 {
+				lowerBoundValues[fieldID] = minValue
+			}
+			if upperBoundValues[fieldID] == nil || compareIcebergBoundValues(upperBoundValues[fieldID], maxValue) < 0 {
+				upperBoundValues[fieldID] = maxValue
 			}
 		}
 	}
 
-	// Todo: convert lower/upper bytes to BigEndianBytes?
+	for fieldID, lowerBoundValue := range lowerBoundValues {
+		if fieldIdsWithoutBounds[fieldID] {
+			continue
+		}
+		parquetStats.LowerBounds[fieldID] = icebergLowerBound(lowerBoundValue)
+		if upperBound, ok := icebergUpperBound(upperBoundValues[fieldID]); ok {
+			parquetStats.UpperBounds[fieldID] = upperBound
+		}
+	}
 
 	return parquetStats, nil
 }
 
-func (storage *StorageUtils) WriteManifestFile(fileSystemPrefix string, filePath string, parquetFile ParquetFile) (manifestFile ManifestFile, err error) {
+func (storage *StorageUtils) WriteManifestFile(fileSystemPrefix string, filePath string, partitionSpec IcebergPartitionSpec, parquetFile ParquetFile) (manifestFile ManifestFile, err error) {
 	snapshotId := time.Now().UnixNano()
-	codec, err := goavro.NewCodec(MANIFEST_SCHEMA)
+	manifestSchema, err := storage.manifestSchema(partitionSpec)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	codec, err := goavro.NewCodec(manifestSchema)
 	if err != nil {
 		return ManifestFile{}, fmt.Errorf("failed to create Avro codec: %v", err)
 	}
@@ -468,7 +1196,7 @@ func (storage *StorageUtils) WriteManifestFile(fileSystemPrefix string, filePath
 	}
 
 	dataFile := map[string]interface{}{
-		"content":            0, // 0: DATA, 1: POSITION DELETES, 2: EQUALITY DELETES
+		"content":            ICEBERG_DATA_FILE_CONTENT_DATA, // 0: DATA, 1: POSITION DELETES, 2: EQUALITY DELETES
 		"file_path":          fileSystemPrefix + parquetFile.Path,
 		"file_format":        "PARQUET",
 		"partition":          map[string]interface{}{},
@@ -500,6 +1228,36 @@ func (storage *StorageUtils) WriteManifestFile(fileSystemPrefix string, filePath
 		"sort_order_id": nil,
 	}
 
+	if parquetFile.SortOrderId != ICEBERG_SORT_ORDER_ID_UNSORTED {
+		dataFile["sort_order_id"] = map[string]interface{}{"int": parquetFile.SortOrderId}
+	}
+	manifestContent := "data"
+	if parquetFile.PositionDeletes {
+		dataFile["content"] = ICEBERG_DATA_FILE_CONTENT_POSITION_DELETES
+		manifestContent = "deletes"
+	}
+
+	partitionFieldSummaries := []ManifestPartitionFieldSummary{}
+	if partitionSpec.IsPartitioned() {
+		dataFile["partition"], err = partitionSpec.ToAvroValue(parquetFile.PartitionValues)
+		if err != nil {
+			return ManifestFile{}, fmt.Errorf("failed to write partition values: %v", err)
+		}
+
+		for i, field := range partitionSpec.Fields {
+			if parquetFile.PartitionValues[i] == nil {
+				partitionFieldSummaries = append(partitionFieldSummaries, ManifestPartitionFieldSummary{ContainsNull: true})
+				continue
+			}
+
+			bound, err := field.BinaryValue(*parquetFile.PartitionValues[i])
+			if err != nil {
+				return ManifestFile{}, fmt.Errorf("failed to write partition values: %v", err)
+			}
+			partitionFieldSummaries = append(partitionFieldSummaries, ManifestPartitionFieldSummary{LowerBound: bound, UpperBound: bound})
+		}
+	}
+
 	manifestEntry := map[string]interface{}{
 		"status":               ICEBERG_MANIFEST_STATUS_ADDED,
 		"snapshot_id":          map[string]interface{}{"long": snapshotId},
@@ -514,10 +1272,21 @@ func (storage *StorageUtils) WriteManifestFile(fileSystemPrefix string, filePath
 	}
 	defer avroFile.Close()
 
+	partitionSpecJson, err := json.Marshal(partitionSpec.metadataFields())
+	if err != nil {
+		return ManifestFile{}, fmt.Errorf("failed to serialize partition spec: %v", err)
+	}
+
 	ocfWriter, err := goavro.NewOCFWriter(goavro.OCFConfig{
 		W:      avroFile,
 		Codec:  codec,
-		Schema: MANIFEST_SCHEMA,
+		Schema: manifestSchema,
+		MetaData: map[string][]byte{
+			"format-version":    []byte("2"),
+			"content":           []byte(manifestContent),
+			"partition-spec-id": []byte(IntToString(partitionSpec.SpecId)),
+			"partition-spec":    partitionSpecJson,
+		},
 	})
 	if err != nil {
 		return ManifestFile{}, fmt.Errorf("failed to create Avro OCF writer: %v", err)
@@ -535,11 +1304,14 @@ func (storage *StorageUtils) WriteManifestFile(fileSystemPrefix string, filePath
 	fileSize := fileInfo.Size()
 
 	return ManifestFile{
-		SnapshotId:   snapshotId,
-		Path:         filePath,
-		Size:         fileSize,
-		RecordCount:  parquetFile.RecordCount,
-		DataFileSize: parquetFile.Size,
+		PositionDeletes:         parquetFile.PositionDeletes,
+		SnapshotId:              snapshotId,
+		Path:                    filePath,
+		Size:                    fileSize,
+		RecordCount:             parquetFile.RecordCount,
+		DataFileSize:            parquetFile.Size,
+		PartitionSpecId:         partitionSpec.SpecId,
+		PartitionFieldSummaries: partitionFieldSummaries,
 	}, nil
 }
 
@@ -566,15 +1338,18 @@ func (storage *StorageUtils) WriteDeletedRecordsManifestFile(fileSystemPrefix st
 	}
 	defer avroFile.Close()
 
-	codec, err := goavro.NewCodec(MANIFEST_SCHEMA)
-	if err != nil {
-		return ManifestFile{}, fmt.Errorf("failed to create Avro codec: %v", err)
+	// Keep the schema and metadata of the existing manifest, which depend on the partition spec
+	metadata := map[string][]byte{}
+	for key, value := range ocfReader.MetaData() {
+		if !strings.HasPrefix(key, "avro.") {
+			metadata[key] = value
+		}
 	}
 
 	ocfWriter, err := goavro.NewOCFWriter(goavro.OCFConfig{
-		W:      avroFile,
-		Codec:  codec,
-		Schema: MANIFEST_SCHEMA,
+		W:        avroFile,
+		Codec:    ocfReader.Codec(),
+		MetaData: metadata,
 	})
 	if err != nil {
 		return ManifestFile{}, fmt.Errorf("failed to create Avro OCF writer: %v", err)
@@ -591,13 +1366,22 @@ func (storage *StorageUtils) WriteDeletedRecordsManifestFile(fileSystemPrefix st
 	}
 	fileSize := fileInfo.Size()
 
+	partitionSpecId := 0
+	if len(metadata["partition-spec-id"]) > 0 {
+		partitionSpecId, err = StringToInt(string(metadata["partition-spec-id"]))
+		if err != nil {
+			return ManifestFile{}, fmt.Errorf("failed to parse manifest partition spec ID: %v", err)
+		}
+	}
+
 	return ManifestFile{
-		RecordsDeleted: true,
-		SnapshotId:     recordMap["snapshot_id"].(map[string]interface{})["long"].(int64),
-		Path:           filePath,
-		Size:           fileSize,
-		RecordCount:    recordMap["data_file"].(map[string]interface{})["record_count"].(int64),
-		DataFileSize:   recordMap["data_file"].(map[string]interface{})["file_size_in_bytes"].(int64),
+		RecordsDeleted:  true,
+		SnapshotId:      recordMap["snapshot_id"].(map[string]interface{})["long"].(int64),
+		Path:            filePath,
+		Size:            fileSize,
+		RecordCount:     recordMap["data_file"].(map[string]interface{})["record_count"].(int64),
+		DataFileSize:    recordMap["data_file"].(map[string]interface{})["file_size_in_bytes"].(int64),
+		PartitionSpecId: partitionSpecId,
 	}, nil
 }
 
@@ -621,17 +1405,22 @@ func (storage *StorageUtils) WriteManifestListFile(fileSystemPrefix string, file
 			"manifest_path":        fileSystemPrefix + manifestFile.Path,
 			"min_sequence_number":  sequenceNumber,
 			"sequence_number":      sequenceNumber,
-			"content":              0,
+			"content":              ICEBERG_MANIFEST_CONTENT_DATA,
 			"deleted_files_count":  0,
 			"deleted_rows_count":   0,
 			"existing_files_count": 0,
 			"existing_rows_count":  0,
 			"key_metadata":         nil,
-			"partition_spec_id":    0,
-			"partitions":           map[string]interface{}{"array": []string{}},
+			"partition_spec_id":    manifestFile.PartitionSpecId,
+			"partitions":           map[string]interface{}{"array": storage.manifestListPartitions(manifestFile)},
 		}
 
-		if manifestFile.RecordsDeleted {
+		if manifestFile.PositionDeletes {
+			// Delete files aren't counted as data files, their snapshot summary counts are set by the caller
+			manifestListRecord["content"] = ICEBERG_MANIFEST_CONTENT_DELETES
+			manifestListRecord["added_files_count"] = 1
+			manifestListRecord["added_rows_count"] = manifestFile.RecordCount
+		} else if manifestFile.RecordsDeleted {
 			manifestListRecord["added_files_count"] = 0
 			manifestListRecord["added_rows_count"] = 0
 			manifestListRecord["deleted_files_count"] = 1
@@ -712,49 +1501,89 @@ func (storage *StorageUtils) WriteManifestListFile(fileSystemPrefix string, file
 	return manifestListFile, nil
 }
 
-func (storage *StorageUtils) WriteMetadataFile(fileSystemPrefix string, filePath string, pgSchemaColumns []PgSchemaColumn, manifestListFilesSortedAsc []ManifestListFile) (err error) {
-	tableUuid := uuid.New().String()
-	lastColumnID := 3
+// The schemas list of the table metadata
+func (storage *StorageUtils) MetadataSchemas(schemasSortedAsc []IcebergSchema) []interface{} {
+	schemas := make([]interface{}, len(schemasSortedAsc))
+	for i, schema := range schemasSortedAsc {
+		identifierFieldIds := schema.IdentifierFieldIds
+		if identifierFieldIds == nil {
+			identifierFieldIds = []int{}
+		}
+		schemas[i] = map[string]interface{}{
+			"type":                 "struct",
+			"schema-id":            schema.SchemaId,
+			"fields":               schema.Fields,
+			"identifier-field-ids": identifierFieldIds,
+		}
+	}
 
-	icebergSchemaFields := make([]interface{}, len(pgSchemaColumns))
-	for i, pgSchemaColumn := range pgSchemaColumns {
-		icebergSchemaFields[i] = pgSchemaColumn.ToIcebergSchemaFieldMap()
+	return schemas
+}
+
+// The properties of the table metadata with the attnums of the current schema, see AssignIcebergFieldIds
+func (storage *StorageUtils) MetadataProperties(schemasSortedAsc []IcebergSchema) map[string]string {
+	properties := map[string]string{}
+	fieldIdsByAttnum := schemasSortedAsc[len(schemasSortedAsc)-1].FieldIdsByAttnum
+	if len(fieldIdsByAttnum) > 0 {
+		fieldIdsByAttnumJson, err := json.Marshal(fieldIdsByAttnum)
+		PanicIfError(err, storage.config)
+		properties[ICEBERG_PROPERTY_FIELD_IDS_BY_ATTNUM] = string(fieldIdsByAttnumJson)
 	}
+	return properties
+}
 
-	snapshots := make([]map[string]interface{}, len(manifestListFilesSortedAsc))
-	snapshotLog := make([]map[string]interface{}, len(manifestListFilesSortedAsc))
+// The snapshots and snapshot-log lists of the table metadata with the running totals in the snapshot summaries
+func (storage *StorageUtils) MetadataSnapshots(fileSystemPrefix string, manifestListFilesSortedAsc []ManifestListFile) (snapshots []map[string]interface{}, snapshotLog []map[string]interface{}) {
+	snapshots = make([]map[string]interface{}, len(manifestListFilesSortedAsc))
+	snapshotLog = make([]map[string]interface{}, len(manifestListFilesSortedAsc))
 
-	var totalDataFiles, totalFilesSize, totalRecords int64
+	var totalDataFiles, totalFilesSize, totalRecords, totalDeleteFiles, totalPositionDeletes int64
+	if firstManifestListFile := manifestListFilesSortedAsc[0]; firstManifestListFile.ParentSnapshotId != 0 {
+		// Previous snapshots were expired, start from the totals before the first remaining snapshot
+		totalDataFiles = firstManifestListFile.TotalDataFiles - firstManifestListFile.AddedDataFiles + firstManifestListFile.DeletedDataFiles
+		totalFilesSize = firstManifestListFile.TotalFilesSize - firstManifestListFile.AddedFilesSize + firstManifestListFile.RemovedFilesSize
+		totalRecords = firstManifestListFile.TotalRecords - firstManifestListFile.AddedRecords + firstManifestListFile.DeletedRecords
+		totalDeleteFiles = firstManifestListFile.TotalDeleteFiles - firstManifestListFile.AddedDeleteFiles + firstManifestListFile.RemovedDeleteFiles
+		totalPositionDeletes = firstManifestListFile.TotalPositionDeletes - firstManifestListFile.AddedPositionDeletes + firstManifestListFile.RemovedPositionDeletes
+	}
 
 	for i, manifestListFile := range manifestListFilesSortedAsc {
 		totalDataFiles += manifestListFile.AddedDataFiles - manifestListFile.DeletedDataFiles
 		totalFilesSize += manifestListFile.AddedFilesSize - manifestListFile.RemovedFilesSize
 		totalRecords += manifestListFile.AddedRecords - manifestListFile.DeletedRecords
+		totalDeleteFiles += manifestListFile.AddedDeleteFiles - manifestListFile.RemovedDeleteFiles
+		totalPositionDeletes += manifestListFile.AddedPositionDeletes - manifestListFile.RemovedPositionDeletes
 
 		snapshot := map[string]interface{}{
-			"schema-id":       0,
+			"schema-id":       manifestListFile.SchemaId,
 			"snapshot-id":     manifestListFile.SnapshotId,
 			"sequence-number": manifestListFile.SequenceNumber,
 			"timestamp-ms":    manifestListFile.TimestampMs,
 			"manifest-list":   fileSystemPrefix + manifestListFile.Path,
 			"summary": map[string]interface{}{
-				"operation":              manifestListFile.Operation,
-				"added-data-files":       Int64ToString(manifestListFile.AddedDataFiles),
-				"added-files-size":       Int64ToString(manifestListFile.AddedFilesSize),
-				"added-records":          Int64ToString(manifestListFile.AddedRecords),
-				"deleted-data-files":     Int64ToString(manifestListFile.DeletedDataFiles),
-				"deleted-records":        Int64ToString(manifestListFile.DeletedRecords),
-				"removed-files-size":     Int64ToString(manifestListFile.RemovedFilesSize),
-				"total-data-files":       Int64ToString(totalDataFiles),
-				"total-files-size":       Int64ToString(totalFilesSize),
-				"total-records":          Int64ToString(totalRecords),
-				"total-delete-files":     "0",
-				"total-equality-deletes": "0",
-				"total-position-deletes": "0",
+				"operation":                manifestListFile.Operation,
+				"added-data-files":         Int64ToString(manifestListFile.AddedDataFiles),
+				"added-files-size":         Int64ToString(manifestListFile.AddedFilesSize),
+				"added-records":            Int64ToString(manifestListFile.AddedRecords),
+				"deleted-data-files":       Int64ToString(manifestListFile.DeletedDataFiles),
+				"deleted-records":          Int64ToString(manifestListFile.DeletedRecords),
+				"removed-files-size":       Int64ToString(manifestListFile.RemovedFilesSize),
+				"total-data-files":         Int64ToString(totalDataFiles),
+				"total-files-size":         Int64ToString(totalFilesSize),
+				"total-records":            Int64ToString(totalRecords),
+				"added-delete-files":       Int64ToString(manifestListFile.AddedDeleteFiles),
+				"added-position-deletes":   Int64ToString(manifestListFile.AddedPositionDeletes),
+				"removed-delete-files":     Int64ToString(manifestListFile.RemovedDeleteFiles),
+				"removed-position-deletes": Int64ToString(manifestListFile.RemovedPositionDeletes),
+				"total-delete-files":       Int64ToString(totalDeleteFiles),
+				"total-equality-deletes":   "0",
+				"total-position-deletes":   Int64ToString(totalPositionDeletes),
 			},
 		}
 		if i != 0 {
 			snapshot["parent-snapshot-id"] = manifestListFilesSortedAsc[i-1].SnapshotId
+		} else if manifestListFile.ParentSnapshotId != 0 {
+			snapshot["parent-snapshot-id"] = manifestListFile.ParentSnapshotId // Expired snapshot
 		}
 		snapshots[i] = snapshot
 
@@ -764,50 +1593,63 @@ func (storage *StorageUtils) WriteMetadataFile(fileSystemPrefix string, filePath
 		}
 	}
 
+	return snapshots, snapshotLog
+}
+
+// The refs of the table metadata with the main branch pointing to the last snapshot
+func (storage *StorageUtils) MetadataRefs(manifestListFilesSortedAsc []ManifestListFile) map[string]interface{} {
 	lastManifestListFile := manifestListFilesSortedAsc[len(manifestListFilesSortedAsc)-1]
-	metadata := map[string]interface{}{
-		"format-version":       2,
-		"table-uuid":           tableUuid,
-		"statistics":           []interface{}{},
-		"location":             fileSystemPrefix + filePath,
-		"last-sequence-number": lastManifestListFile.SequenceNumber,
-		"last-updated-ms":      lastManifestListFile.TimestampMs,
-		"last-column-id":       lastColumnID,
-		"schemas": []interface{}{
-			map[string]interface{}{
-				"type":                 "struct",
-				"schema-id":            0,
-				"fields":               icebergSchemaFields,
-				"identifier-field-ids": []interface{}{},
-			},
-		},
-		"current-schema-id": 0,
-		"partition-specs": []interface{}{
-			map[string]interface{}{
-				"spec-id": 0,
-				"fields":  []interface{}{},
-			},
+	refs := map[string]interface{}{
+		ICEBERG_SNAPSHOT_REF_MAIN: map[string]interface{}{
+			"snapshot-id": lastManifestListFile.SnapshotId,
+			"type":        ICEBERG_SNAPSHOT_REF_BRANCH,
 		},
-		"default-spec-id":       0,
-		"default-sort-order-id": 0,
-		"last-partition-id":     999, // Assuming no partitions; set to a placeholder
-		"properties":            map[string]string{},
+	}
+	for _, manifestListFile := range manifestListFilesSortedAsc {
+		for _, ref := range manifestListFile.Refs {
+			refs[ref.Name] = map[string]interface{}{
+				"snapshot-id": manifestListFile.SnapshotId,
+				"type":        ref.Type,
+			}
+		}
+	}
+
+	return refs
+}
+
+func (storage *StorageUtils) WriteMetadataFile(fileSystemPrefix string, filePath string, metadataDirPath string, tableUuid string, metadataLog []MetadataLogEntry, schemasSortedAsc []IcebergSchema, partitionSpecsSortedAsc []IcebergPartitionSpec, partitionSpec IcebergPartitionSpec, sortOrder IcebergSortOrder, manifestListFilesSortedAsc []ManifestListFile) (err error) {
+	schemas := storage.MetadataSchemas(schemasSortedAsc)
+	snapshots, snapshotLog := storage.MetadataSnapshots(fileSystemPrefix, manifestListFilesSortedAsc)
+
+	partitionSpecs := []interface{}{}
+	lastPartitionId := ICEBERG_PARTITION_FIELD_ID_START - 1
+	for _, spec := range partitionSpecsSortedAsc {
+		partitionSpecs = append(partitionSpecs, spec.ToMetadataMap())
+		lastPartitionId = max(lastPartitionId, spec.LastPartitionId())
+	}
+
+	lastManifestListFile := manifestListFilesSortedAsc[len(manifestListFilesSortedAsc)-1]
+	metadata := map[string]interface{}{
+		"format-version":        2,
+		"table-uuid":            tableUuid,
+		"statistics":            []interface{}{},
+		"location":              fileSystemPrefix + strings.TrimSuffix(metadataDirPath, "/metadata"),
+		"last-sequence-number":  lastManifestListFile.SequenceNumber,
+		"last-updated-ms":       lastManifestListFile.TimestampMs,
+		"last-column-id":        LastIcebergColumnId(schemasSortedAsc),
+		"schemas":               schemas,
+		"current-schema-id":     schemasSortedAsc[len(schemasSortedAsc)-1].SchemaId,
+		"partition-specs":       partitionSpecs,
+		"default-spec-id":       partitionSpec.SpecId,
+		"default-sort-order-id": sortOrder.OrderId,
+		"last-partition-id":     lastPartitionId,
+		"properties":            storage.MetadataProperties(schemasSortedAsc),
 		"current-snapshot-id":   lastManifestListFile.SnapshotId,
-		"refs": map[string]interface{}{
-			"main": map[string]interface{}{
-				"snapshot-id": lastManifestListFile.SnapshotId,
-				"type":        "branch",
-			},
-		},
-		"snapshots":    snapshots,
-		"snapshot-log": snapshotLog,
-		"metadata-log": []interface{}{},
-		"sort-orders": []interface{}{
-			map[string]interface{}{
-				"order-id": 0,
-				"fields":   []interface{}{},
-			},
-		},
+		"refs":                  storage.MetadataRefs(manifestListFilesSortedAsc),
+		"snapshots":             snapshots,
+		"snapshot-log":          snapshotLog,
+		"metadata-log":          metadataLog,
+		"sort-orders":           sortOrder.ToMetadataMaps(),
 	}
 
 	file, err := os.Create(filePath)
@@ -862,8 +1704,167 @@ func (storage *StorageUtils) WriteInternalTableMetadataFile(filePath string, int
 
 }
 
+func (storage *StorageUtils) WriteSyncJournalFile(filePath string, syncJournal SyncJournal) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create sync journal file: %v", err)
+	}
+	defer file.Close()
+
+	jsonData, err := json.Marshal(syncJournal)
+	if err != nil {
+		return fmt.Errorf("failed to serialize sync journal to JSON: %v", err)
+	}
+
+	_, err = file.Write(jsonData)
+	if err != nil {
+		return fmt.Errorf("failed to write sync journal to file: %v", err)
+	}
+
+	return nil
+}
+
+func (storage *StorageUtils) WriteSyncStatusFile(filePath string, syncStatus SyncStatus) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create sync status file: %v", err)
+	}
+	defer file.Close()
+
+	jsonData, err := json.Marshal(syncStatus)
+	if err != nil {
+		return fmt.Errorf("failed to serialize sync status to JSON: %v", err)
+	}
+
+	_, err = file.Write(jsonData)
+	if err != nil {
+		return fmt.Errorf("failed to write sync status to file: %v", err)
+	}
+
+	return nil
+}
+
 // ---------------------------------------------------------------------------------------------------------------------
 
+// Adds the partition tuple to data files in manifest entries of partitioned tables
+func (storage *StorageUtils) manifestSchema(partitionSpec IcebergPartitionSpec) (string, error) {
+	if !partitionSpec.IsPartitioned() {
+		return MANIFEST_SCHEMA, nil
+	}
+
+	var schema map[string]interface{}
+	err := json.Unmarshal([]byte(MANIFEST_SCHEMA), &schema)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse manifest schema: %v", err)
+	}
+
+	for _, field := range schema["fields"].([]interface{}) {
+		fieldMap := field.(map[string]interface{})
+		if fieldMap["name"] != "data_file" {
+			continue
+		}
+
+		dataFileType := fieldMap["type"].(map[string]interface{})
+		dataFileFields := dataFileType["fields"].([]interface{})
+		dataFileType["fields"] = append([]interface{}{partitionSpec.ToAvroSchemaField()}, dataFileFields...)
+	}
+
+	schemaJson, err := json.Marshal(schema)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize manifest schema: %v", err)
+	}
+	return string(schemaJson), nil
+}
+
+func (storage *StorageUtils) manifestListPartitions(manifestFile ManifestFile) []interface{} {
+	partitions := []interface{}{}
+	for _, summary := range manifestFile.PartitionFieldSummaries {
+		partition := map[string]interface{}{
+			"contains_null": summary.ContainsNull,
+			"contains_nan":  nil,
+			"lower_bound":   nil,
+			"upper_bound":   nil,
+		}
+		if summary.LowerBound != nil {
+			partition["lower_bound"] = map[string]interface{}{"bytes": summary.LowerBound}
+			partition["upper_bound"] = map[string]interface{}{"bytes": summary.UpperBound}
+		}
+		partitions = append(partitions, partition)
+	}
+	return partitions
+}
+
+// Existing Parquet files can be written with an older schema, so their columns are matched by field ID and renamed to the current names
+func (storage *StorageUtils) existingParquetSelectList(duckdb *Duckdb, parquetPath string, pgSchemaColumns []PgSchemaColumn) (string, error) {
+	existingColumnNames, err := storage.existingParquetColumnNames(duckdb, parquetPath)
+	if err != nil {
+		return "", err
+	}
+
+	selectExpressions := []string{}
+	for _, pgSchemaColumn := range pgSchemaColumns {
+		existingColumnName, found := existingColumnNames[pgSchemaColumn.FieldId]
+		if found {
+			selectExpressions = append(selectExpressions, storage.quoteIdentifier(existingColumnName)+" AS "+storage.quoteIdentifier(pgSchemaColumn.NormalizedColumnName()))
+		}
+	}
+	if len(selectExpressions) == 0 {
+		return "", errors.New("existing Parquet file has none of the current columns")
+	}
+	return strings.Join(selectExpressions, ", "), nil
+}
+
+// Returns the column names of a Parquet file by field ID
+func (storage *StorageUtils) existingParquetColumnNames(duckdb *Duckdb, parquetPath string) (map[int]string, error) {
+	rows, err := duckdb.QueryContext(context.Background(), "SELECT name, field_id FROM parquet_schema('"+strings.ReplaceAll(parquetPath, "'", "''")+"') WHERE field_id IS NOT NULL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Parquet schema: %v", err)
+	}
+	defer rows.Close()
+
+	existingColumnNames := make(map[int]string)
+	for rows.Next() {
+		var columnName string
+		var fieldId int
+		if err = rows.Scan(&columnName, &fieldId); err != nil {
+			return nil, fmt.Errorf("failed to scan Parquet schema: %v", err)
+		}
+		if _, found := existingColumnNames[fieldId]; !found {
+			existingColumnNames[fieldId] = columnName
+		}
+	}
+	return existingColumnNames, rows.Err()
+}
+
+// Converts a value read by DuckDB back to the value written by the Parquet JSON writer, e.g. a date to days since the epoch
+func (storage *StorageUtils) parquetValueSql(pgSchemaColumn PgSchemaColumn, valueSql string) string {
+	primitiveValueSql := func(valueSql string) string {
+		_, primitiveConvertedType := pgSchemaColumn.parquetPrimitiveTypes()
+		switch primitiveConvertedType {
+		case "DATE":
+			return "(" + valueSql + " - DATE '1970-01-01')"
+		case "TIMESTAMP_MICROS":
+			return "epoch_us(" + valueSql + ")"
+		case "TIMESTAMP_MILLIS":
+			return "epoch_ms(" + valueSql + ")"
+		case "TIME_MICROS":
+			return "epoch_us(DATE '1970-01-01' + " + valueSql + ")"
+		case "TIME_MILLIS":
+			return "epoch_ms(DATE '1970-01-01' + " + valueSql + ")"
+		}
+		return valueSql
+	}
+
+	if pgSchemaColumn.DataType == PG_DATA_TYPE_ARRAY {
+		return "list_transform(" + valueSql + ", element -> " + primitiveValueSql("element") + ")"
+	}
+	return primitiveValueSql(valueSql)
+}
+
+func (storage *StorageUtils) quoteIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
 func (storage *StorageUtils) hasOverlappingRows(pkColumnNames []string, duckdb *Duckdb) (bool, error) {
 	sql := "SELECT 1 FROM existing_parquet JOIN new_parquet USING (" + strings.Join(pkColumnNames, ", ") + ") LIMIT 1"
 	if len(pkColumnNames) == 0 {
@@ -898,6 +1899,23 @@ func (storage *StorageUtils) selectNonOverlappingRowsSql(columnNames []string, p
 	return "SELECT to_json(struct_pack(" + strings.Join(selectExpressions, ", ") + ")) FROM existing_parquet WHERE NOT EXISTS (SELECT 1 FROM new_parquet WHERE " + strings.Join(whereConditions, " AND ") + ")"
 }
 
+// Columns of position delete files, with reserved field IDs
+func (storage *StorageUtils) PositionDeletesPgSchemaColumns() []PgSchemaColumn {
+	return []PgSchemaColumn{
+		{ColumnName: "file_path", DataType: "text", UdtName: "text", IsNullable: "NO", Namespace: PG_SCHEMA_PG_CATALOG, FieldId: ICEBERG_POSITION_DELETES_FILE_PATH_FIELD_ID, config: storage.config},
+		{ColumnName: "pos", DataType: "bigint", UdtName: "int8", IsNullable: "NO", Namespace: PG_SCHEMA_PG_CATALOG, FieldId: ICEBERG_POSITION_DELETES_POS_FIELD_ID, config: storage.config},
+	}
+}
+
+// Returns a DuckDB list of quoted Parquet file paths
+func (storage *StorageUtils) parquetPathsSql(fileSystemPrefix string, parquetFilePaths []string) string {
+	quotedParquetPaths := []string{}
+	for _, parquetFilePath := range parquetFilePaths {
+		quotedParquetPaths = append(quotedParquetPaths, "'"+strings.ReplaceAll(fileSystemPrefix+parquetFilePath, "'", "''")+"'")
+	}
+	return "[" + strings.Join(quotedParquetPaths, ", ") + "]"
+}
+
 func (storage *StorageUtils) buildSchemaJson(pgSchemaColumns []PgSchemaColumn) string {
 	schemaMap := map[string]interface{}{
 		"Tag":    "name=root",
@@ -913,11 +1931,32 @@ func (storage *StorageUtils) buildSchemaJson(pgSchemaColumns []PgSchemaColumn) s
 	return string(schemaJson)
 }
 
+// Maps dot-separated column paths (without the root) to field IDs, e.g. "tags.list.element" for an array element
 func (storage *StorageUtils) buildFieldIDMap(schemaHandler *schema.SchemaHandler) map[string]int {
 	fieldIDMap := make(map[string]int)
-	for _, schema := range schemaHandler.SchemaElements {
-		if schema.FieldID != nil {
-			fieldIDMap[schema.Name] = int(*schema.FieldID)
+	if len(schemaHandler.SchemaElements) == 0 {
+		return fieldIDMap
+	}
+
+	parentPaths := []string{}
+	remainingChildren := []int32{schemaHandler.SchemaElements[0].GetNumChildren()}
+	for _, schemaElement := range schemaHandler.SchemaElements[1:] {
+		for remainingChildren[len(remainingChildren)-1] == 0 {
+			remainingChildren = remainingChildren[:len(remainingChildren)-1]
+			parentPaths = parentPaths[:len(parentPaths)-1]
+		}
+		remainingChildren[len(remainingChildren)-1]--
+
+		path := schemaElement.Name
+		if len(parentPaths) > 0 {
+			path = parentPaths[len(parentPaths)-1] + "." + path
+		}
+		if schemaElement.FieldID != nil {
+			fieldIDMap[path] = int(*schemaElement.FieldID)
+		}
+		if schemaElement.GetNumChildren() > 0 {
+			parentPaths = append(parentPaths, path)
+			remainingChildren = append(remainingChildren, schemaElement.GetNumChildren())
 		}
 	}
 	return fieldIDMap
@@ -5,19 +5,28 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"runtime"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 const (
 	MAX_IN_MEMORY_BUFFER_SIZE = 128 * 1024 * 1024 // 128 MB (expands to ~160 MB memory usage)
 	MAX_PG_ROWS_BATCH_SIZE    = 1 * 1024 * 1024   // 1 MB
 	PING_PG_INTERVAL_SECONDS  = 24
+
+	MIN_PG_VERSION_NUM_TID_RANGE_SCAN = 140000 // Postgres 14
+
+	MAX_SYNC_TABLE_ATTEMPTS  = 3
+	SYNC_TABLE_RETRY_BACKOFF = 5 * time.Second // Doubled after each failed attempt
 )
 
 type Syncer struct {
@@ -25,7 +34,76 @@ type Syncer struct {
 	icebergWriter            *IcebergWriter
 	icebergReader            *IcebergReader
 	syncerFullRefresh        *SyncerFullRefresh
+	syncerPartitions         *SyncerPartitions
 	syncerIncrementalRefresh *SyncerIncrementalRefresh
+	syncerCompaction         *SyncerCompaction
+	syncerScheduler          *SyncerScheduler
+}
+
+type SyncTableFailure struct {
+	PgSchemaTable PgSchemaTable
+	Err           error
+}
+
+// Returned after syncing the other tables if some tables kept failing
+type SyncError struct {
+	SyncTableFailures []SyncTableFailure
+}
+
+func (syncErr *SyncError) Error() string {
+	lines := []string{"failed to sync " + IntToString(len(syncErr.SyncTableFailures)) + " table(s):"}
+	for _, syncTableFailure := range syncErr.SyncTableFailures {
+		lines = append(lines, "- "+syncTableFailure.PgSchemaTable.String()+": "+syncTableFailure.Err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Returned before syncing any table if a Postgres schema maps to the schema of bemidb.sync_status and bemidb.sync_runs
+type ReservedPgSchemaError struct {
+	PgSchema string
+}
+
+func (reservedErr *ReservedPgSchemaError) Error() string {
+	return "Postgres schema \"" + reservedErr.PgSchema + "\" would be synced into the \"" + BEMIDB_SCHEMA + "\" schema reserved by BemiDB. " +
+		"Set --pg-schema-prefix to sync it under a different name, or exclude its tables with --pg-exclude-tables"
+}
+
+// Connection resets, serialization failures, lock timeouts, S3 5xx responses, and concurrent metadata commits can succeed when retried
+func IsTransientSyncError(err error) bool {
+	var conflictErr *MetadataCommitConflictError
+	if errors.As(err, &conflictErr) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"55P03", // lock_not_available
+			"57P01", // admin_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		return strings.HasPrefix(pgErr.Code, "08") // connection_exception
+	}
+
+	var httpResponseErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &httpResponseErr) {
+		return httpResponseErr.HTTPStatusCode() >= 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return pgconn.SafeToRetry(err) ||
+		pgconn.Timeout(err) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
 }
 
 func NewSyncer(config *Config) *Syncer {
@@ -35,68 +113,372 @@ func NewSyncer(config *Config) *Syncer {
 
 	icebergWriter := NewIcebergWriter(config)
 	icebergReader := NewIcebergReader(config)
+	syncerFullRefresh := NewSyncerFullRefresh(config, icebergWriter)
 	return &Syncer{
 		config:                   config,
 		icebergWriter:            icebergWriter,
 		icebergReader:            icebergReader,
-		syncerFullRefresh:        NewSyncerFullRefresh(config, icebergWriter),
+		syncerFullRefresh:        syncerFullRefresh,
+		syncerPartitions:         NewSyncerPartitions(config, icebergWriter, syncerFullRefresh),
 		syncerIncrementalRefresh: NewSyncerIncrementalRefresh(config, icebergWriter),
+		syncerCompaction:         NewSyncerCompaction(config, icebergWriter, syncerFullRefresh),
+		syncerScheduler:          NewSyncerScheduler(config),
 	}
 }
 
-func (syncer *Syncer) SyncFromPostgres() {
+func (syncer *Syncer) SyncFromPostgres() (err error) {
+	syncerStatus := NewSyncerStatus(syncer.config, syncer.icebergWriter)
+	defer func() {
+		// Record the sync run after converting panics to err below
+		finishErr := syncerStatus.Finish(err)
+		if err == nil {
+			err = finishErr
+		}
+
+		// Failed tables are reported once their retries are exhausted, and transient errors are retried by the next sync run
+		var syncErr *SyncError
+		var reservedErr *ReservedPgSchemaError
+		if err != nil && !errors.As(err, &syncErr) && !errors.As(err, &reservedErr) && !IsTransientSyncError(err) {
+			sendAnonymousErrorReport(syncer.config, err)
+		}
+	}()
+	defer RecoverError(&err)
+
 	ctx := context.Background()
 	databaseUrl := syncer.urlEncodePassword(syncer.config.Pg.DatabaseUrl)
 	syncer.sendAnonymousAnalytics(databaseUrl)
 
 	icebergSchemaTables, icebergSchemaTablesErr := syncer.icebergReader.SchemaTables()
 
-	structureConn := syncer.newConnection(ctx, databaseUrl)
-	defer structureConn.Close(ctx)
+	structureConn, copyConn, err := syncer.newConnections(ctx, databaseUrl)
+	if err != nil {
+		return err
+	}
+	// Connections are reopened after failed table syncs
+	defer func() {
+		if structureConn != nil {
+			structureConn.Close(ctx)
+			copyConn.Close(ctx)
+		}
+	}()
 
-	copyConn := syncer.newConnection(ctx, databaseUrl)
-	defer copyConn.Close(ctx)
+	err = syncerStatus.Start()
+	if err != nil {
+		return err
+	}
+
+	syncerJournal := NewSyncerJournal(syncer.config, syncer.icebergWriter)
+	err = syncerJournal.Start()
+	if err != nil {
+		return err
+	}
 
+	pgSchemas, err := syncer.listPgSchemas(structureConn)
+	if err != nil {
+		return err
+	}
+
+	// Keep previously synced data of tables that aren't due or failed as well
 	syncedPgSchemaTables := []PgSchemaTable{}
+	for _, schema := range pgSchemas {
+		pgSchemaTables, err := syncer.listPgSchemaTables(structureConn, schema)
+		if err != nil {
+			return err
+		}
 
-	for _, schema := range syncer.listPgSchemas(structureConn) {
-		for _, pgSchemaTable := range syncer.listPgSchemaTables(structureConn, schema) {
+		for _, pgSchemaTable := range pgSchemaTables {
 			if syncer.shouldSyncTable(pgSchemaTable) {
-				// Identify the batch size dynamically based on the table stats
-				rowCountPerBatch := syncer.calculateRowCountPerBatch(pgSchemaTable, structureConn)
-				LogDebug(syncer.config, "Row count per batch:", rowCountPerBatch)
-
-				syncedPreviously := icebergSchemaTablesErr == nil && icebergSchemaTables.Contains(pgSchemaTable.ToIcebergSchemaTable())
-				incrementalRefreshEnabled := syncer.config.Pg.IncrementallyRefreshedTables != nil && HasExactOrWildcardMatch(syncer.config.Pg.IncrementallyRefreshedTables, pgSchemaTable.ToConfigArg())
-
-				var internalTableMetadata InternalTableMetadata
-				var err error
-				// Read internal table metadata if it exists
-				if syncedPreviously && incrementalRefreshEnabled {
-					internalTableMetadata, err = syncer.icebergReader.storage.InternalTableMetadata(pgSchemaTable)
-					PanicIfError(err, syncer.config)
-					LogDebug(syncer.config, "Read internal table metadata to sync incrementally:", internalTableMetadata.String())
-				}
+				syncedPgSchemaTables = append(syncedPgSchemaTables, pgSchemaTable)
+			}
+		}
+	}
+
+	// Fail before writing or deleting anything instead of overwriting the bemidb tables
+	err = syncer.checkReservedPgSchema(syncedPgSchemaTables)
+	if err != nil {
+		return err
+	}
 
-				// Sync the table
-				if internalTableMetadata.XminMax != nil && internalTableMetadata.XminMin != nil {
-					syncer.syncerIncrementalRefresh.SyncPgTable(pgSchemaTable, internalTableMetadata, rowCountPerBatch, structureConn, copyConn)
-				} else {
-					syncer.syncerFullRefresh.SyncPgTable(pgSchemaTable, rowCountPerBatch, structureConn, copyConn)
+	syncer.syncerScheduler.StartRun()
+	syncer.syncerScheduler.SortByPriority(syncedPgSchemaTables)
+	runStartedAt := time.Now()
+	syncTableFailures := []SyncTableFailure{}
+
+	for _, pgSchemaTable := range syncedPgSchemaTables {
+		if !syncer.syncerScheduler.IsDue(pgSchemaTable, syncerStatus.LastAttemptAt(pgSchemaTable), runStartedAt) {
+			LogDebug(syncer.config, "Skipping", pgSchemaTable.String(), "that isn't due yet")
+			continue
+		}
+
+		if syncerJournal.IsTableCompleted(pgSchemaTable) {
+			syncer.syncerScheduler.RecordAttempt(pgSchemaTable, syncerStatus.LastAttemptAt(pgSchemaTable))
+			LogInfo(syncer.config, "Skipping", pgSchemaTable.String(), "synced before the interruption")
+			continue
+		}
+
+		syncedPreviously := icebergSchemaTablesErr == nil && icebergSchemaTables.Contains(pgSchemaTable.ToIcebergSchemaTable())
+
+		for attempt := 1; ; attempt++ {
+			attemptStartedAt := time.Now()
+			if attempt == 1 {
+				syncer.syncerScheduler.RecordAttempt(pgSchemaTable, attemptStartedAt)
+			}
+			var syncTableStats SyncTableStats
+			syncTableStats, err = syncer.syncPgTable(ctx, databaseUrl, pgSchemaTable, syncedPreviously, structureConn, copyConn, syncerJournal)
+			if err == nil {
+				err = syncerStatus.RecordTable(pgSchemaTable, syncTableStats, attemptStartedAt, nil)
+				if err != nil {
+					return err
 				}
 
-				LogDebug(syncer.config, "Writing internal metadata to Iceberg...")
-				syncer.writeInternalMetadata(pgSchemaTable, structureConn)
+				tagErr := syncer.tagRestorePoint(pgSchemaTable, runStartedAt)
+				if tagErr != nil {
+					LogError(syncer.config, "Failed to tag a restore point of", pgSchemaTable.String()+":", tagErr)
+				}
 
 				LogInfo(syncer.config, "Finished writing to Iceberg\n")
-				syncedPgSchemaTables = append(syncedPgSchemaTables, pgSchemaTable)
+				break
+			}
+			LogError(syncer.config, "Failed to sync", pgSchemaTable.String()+":", err)
+
+			retry := attempt < MAX_SYNC_TABLE_ATTEMPTS && IsTransientSyncError(err)
+			if !retry {
+				abandonErr := syncerJournal.AbandonTable(pgSchemaTable)
+				if abandonErr != nil {
+					return abandonErr
+				}
+
+				recordErr := syncerStatus.RecordTable(pgSchemaTable, syncTableStats, attemptStartedAt, err)
+				if recordErr != nil {
+					return recordErr
+				}
+
+				sendAnonymousErrorReport(syncer.config, err)
+				syncTableFailures = append(syncTableFailures, SyncTableFailure{PgSchemaTable: pgSchemaTable, Err: err})
+				LogError(syncer.config, "Skipping", pgSchemaTable.String(), "after", attempt, "attempt(s)\n")
+			} else {
+				// The next attempt continues from the completed primary key ranges or copies the table from scratch in a new Postgres snapshot
+				cleanUpErr := syncerJournal.CleanUpTable(pgSchemaTable)
+				if cleanUpErr != nil {
+					return cleanUpErr
+				}
+
+				backoff := SYNC_TABLE_RETRY_BACKOFF * time.Duration(1<<(attempt-1))
+				LogWarn(syncer.config, "Retrying", pgSchemaTable.String(), "in", backoff, "...")
+				time.Sleep(backoff)
+			}
+
+			// A failed statement aborts the snapshot transactions, so the next attempt or table uses new connections
+			structureConn.Close(ctx)
+			copyConn.Close(ctx)
+			var reconnectErr error
+			structureConn, copyConn, reconnectErr = syncer.newConnections(ctx, databaseUrl)
+			if reconnectErr != nil {
+				return reconnectErr
+			}
+
+			if !retry {
+				break
 			}
 		}
 	}
 
 	if syncer.config.Pg.SchemaPrefix == "" {
-		syncer.deleteOldIcebergSchemaTables(syncedPgSchemaTables)
+		err = syncer.deleteOldIcebergSchemaTables(syncedPgSchemaTables)
+		if err != nil {
+			return err
+		}
+	}
+	syncerStatus.KeepTables(syncedPgSchemaTables)
+
+	err = syncerJournal.Finish()
+	if err != nil {
+		return err
+	}
+
+	if len(syncTableFailures) > 0 {
+		return &SyncError{SyncTableFailures: syncTableFailures}
+	}
+
+	return nil
+}
+
+// Compacts previously synced tables without syncing them
+func (syncer *Syncer) CompactIcebergTables() (err error) {
+	defer RecoverError(&err)
+
+	ctx := context.Background()
+	databaseUrl := syncer.urlEncodePassword(syncer.config.Pg.DatabaseUrl)
+
+	icebergSchemaTables, err := syncer.icebergReader.SchemaTables()
+	if err != nil {
+		return err
+	}
+
+	structureConn, err := syncer.newConnection(ctx, databaseUrl)
+	if err != nil {
+		return err
+	}
+	defer structureConn.Close(ctx)
+
+	pgSchemas, err := syncer.listPgSchemas(structureConn)
+	if err != nil {
+		return err
+	}
+
+	compactedTableCount := 0
+	for _, schema := range pgSchemas {
+		pgSchemaTables, err := syncer.listPgSchemaTables(structureConn, schema)
+		if err != nil {
+			return err
+		}
+
+		for _, pgSchemaTable := range pgSchemaTables {
+			if !syncer.shouldSyncTable(pgSchemaTable) || !icebergSchemaTables.Contains(pgSchemaTable.ToIcebergSchemaTable()) {
+				continue
+			}
+
+			compactedParquetFiles, err := syncer.syncerCompaction.CompactPgTable(pgSchemaTable, structureConn)
+			if err != nil {
+				return err
+			}
+			if len(compactedParquetFiles) > 0 {
+				compactedTableCount++
+			}
+		}
+	}
+
+	LogInfo(syncer.config, "Compacted", compactedTableCount, "table(s)")
+	return nil
+}
+
+func (syncer *Syncer) syncPgTable(ctx context.Context, databaseUrl string, pgSchemaTable PgSchemaTable, syncedPreviously bool, structureConn *pgx.Conn, copyConn *pgx.Conn, syncerJournal *SyncerJournal) (syncTableStats SyncTableStats, err error) {
+	// Errors in the Iceberg writer and storage are raised with PanicIfError
+	defer RecoverError(&err)
+
+	// Identify the batch size dynamically based on the table stats
+	rowCountPerBatch, err := syncer.calculateRowCountPerBatch(pgSchemaTable, structureConn)
+	if err != nil {
+		return syncTableStats, err
+	}
+	LogDebug(syncer.config, "Row count per batch:", rowCountPerBatch)
+
+	// Partitions are compared and written with their own internal metadata
+	if pgSchemaTable.Kind == PG_RELKIND_PARTITIONED_TABLE {
+		syncTableStats, err = syncer.syncerPartitions.SyncPgTable(pgSchemaTable, syncedPreviously, rowCountPerBatch, structureConn, copyConn)
+		if err != nil {
+			return syncTableStats, err
+		}
+		return syncTableStats, syncerJournal.CompleteTable(pgSchemaTable)
+	}
+
+	incrementalRefreshEnabled := syncer.isIncrementalRefreshEnabled(pgSchemaTable)
+
+	var internalTableMetadata InternalTableMetadata
+	// Read internal table metadata if it exists
+	// Continue an interrupted full refresh instead of switching to an incremental refresh
+	if syncedPreviously && incrementalRefreshEnabled && !syncerJournal.IsFullRefreshInProgress(pgSchemaTable) {
+		internalTableMetadata, err = syncer.icebergReader.storage.InternalTableMetadata(pgSchemaTable)
+		if err != nil {
+			return syncTableStats, err
+		}
+		LogDebug(syncer.config, "Read internal table metadata to sync incrementally:", internalTableMetadata.String())
+
+		// Rows synced with a different row filter can be missing or shouldn't be kept
+		if internalTableMetadata.RowFilter != syncer.config.Pg.RowFilter(pgSchemaTable) {
+			LogInfo(syncer.config, "Row filter changed, performing a full refresh of", pgSchemaTable.String())
+			internalTableMetadata = InternalTableMetadata{}
+		}
+		// Sorted data files must all be sorted by the same sort order
+		if internalTableMetadata.SortOrder != syncer.sortOrder(pgSchemaTable) {
+			LogInfo(syncer.config, "Sort order changed, performing a full refresh of", pgSchemaTable.String())
+			internalTableMetadata = InternalTableMetadata{}
+		}
+		// Data files written incrementally must have the partition values of the current partition spec
+		if internalTableMetadata.PartitionSpec != syncer.partitionSpec(pgSchemaTable) {
+			LogInfo(syncer.config, "Partitioning changed, performing a full refresh of", pgSchemaTable.String())
+			internalTableMetadata = InternalTableMetadata{}
+		}
+		// Rows synced incrementally must have the same excluded and transformed columns as the existing rows
+		if internalTableMetadata.ColumnRules != NewSyncerColumnRules(syncer.config, pgSchemaTable).Fingerprint() {
+			LogInfo(syncer.config, "Column rules changed, performing a full refresh of", pgSchemaTable.String())
+			internalTableMetadata = InternalTableMetadata{}
+		}
+	}
+
+	// Existing data files can't be kept if the columns changed in a way that Iceberg schemas can't evolve
+	if internalTableMetadata.XminMax != nil && internalTableMetadata.XminMin != nil {
+		schemaEvolutionErr, err := syncer.schemaEvolutionError(pgSchemaTable, structureConn)
+		if err != nil {
+			return syncTableStats, err
+		}
+		if schemaEvolutionErr != nil {
+			LogInfo(syncer.config, "Performing a full refresh of", pgSchemaTable.String()+":", schemaEvolutionErr.Error())
+			internalTableMetadata = InternalTableMetadata{}
+		}
+	}
+
+	syncMode := SYNC_MODE_FULL_REFRESH
+	if internalTableMetadata.XminMax != nil && internalTableMetadata.XminMin != nil {
+		syncMode = SYNC_MODE_INCREMENTAL
+	}
+
+	// Sync the table
+	if syncMode == SYNC_MODE_INCREMENTAL {
+		syncTableStats, err = syncer.syncerIncrementalRefresh.SyncPgTable(pgSchemaTable, internalTableMetadata, rowCountPerBatch, structureConn, copyConn)
+	} else {
+		var copyConns []*pgx.Conn
+		copyConns, err = syncer.newParallelCopyConnections(ctx, databaseUrl, pgSchemaTable, structureConn, copyConn)
+		if err == nil {
+			defer func() {
+				for _, parallelCopyConn := range copyConns[1:] {
+					parallelCopyConn.Close(ctx)
+				}
+			}()
+
+			syncTableStats, err = syncer.syncerFullRefresh.SyncPgTable(pgSchemaTable, rowCountPerBatch, structureConn, copyConns, syncerJournal)
+		}
+	}
+	syncTableStats.Mode = syncMode
+	if err != nil {
+		return syncTableStats, err
+	}
+
+	LogDebug(syncer.config, "Writing internal metadata to Iceberg...")
+	err = syncer.writeInternalMetadata(pgSchemaTable, structureConn, syncerJournal.InProgressTable(pgSchemaTable))
+	if err != nil {
+		return syncTableStats, err
 	}
+
+	return syncTableStats, syncerJournal.CompleteTable(pgSchemaTable)
+}
+
+// Returns an error describing column changes that require rewriting existing data files, e.g. from text to integer
+func (syncer *Syncer) schemaEvolutionError(pgSchemaTable PgSchemaTable, conn *pgx.Conn) (schemaEvolutionErr error, err error) {
+	columnRules := NewSyncerColumnRules(syncer.config, pgSchemaTable)
+	columnNames, err := columnRules.ColumnNames(conn)
+	if err != nil {
+		return nil, err
+	}
+	pgSchemaColumns, err := syncer.syncerFullRefresh.pgTableSchemaColumns(conn, pgSchemaTable, columnNames)
+	if err != nil {
+		return nil, err
+	}
+	columnRules.TransformSchemaColumns(pgSchemaColumns)
+
+	return syncer.icebergWriter.ValidateSchemaEvolution(pgSchemaTable.ToIcebergSchemaTable(), pgSchemaColumns), nil
+}
+
+// Tags the snapshot committed by the sync run as a restore point, named after the run start time to share it across tables
+func (syncer *Syncer) tagRestorePoint(pgSchemaTable PgSchemaTable, runStartedAt time.Time) (err error) {
+	if syncer.config.Pg.SyncRestorePoints == 0 {
+		return nil
+	}
+	defer RecoverError(&err)
+
+	name := ICEBERG_RESTORE_POINT_TAG_PREFIX + runStartedAt.UTC().Format("20060102T150405Z")
+	syncer.icebergWriter.TagRestorePoint(pgSchemaTable.ToIcebergSchemaTable(), name, syncer.config.Pg.SyncRestorePoints)
+	return nil
 }
 
 // Example:
@@ -132,11 +514,37 @@ func (syncer *Syncer) urlEncodePassword(databaseUrl string) string {
 	return strings.Replace(databaseUrl, ":"+password+"@", ":"+url.QueryEscape(password)+"@", 1)
 }
 
+func (syncer *Syncer) checkReservedPgSchema(pgSchemaTables []PgSchemaTable) error {
+	for _, pgSchemaTable := range pgSchemaTables {
+		if syncer.config.Pg.SchemaPrefix+pgSchemaTable.Schema == BEMIDB_SCHEMA {
+			return &ReservedPgSchemaError{PgSchema: pgSchemaTable.Schema}
+		}
+	}
+	return nil
+}
+
 func (syncer *Syncer) shouldSyncTable(pgSchemaTable PgSchemaTable) bool {
 	if syncer.config.Pg.ExcludeTables != nil && HasExactOrWildcardMatch(syncer.config.Pg.ExcludeTables, pgSchemaTable.ToConfigArg()) {
 		return false
 	}
 
+	// Partitions of merged partitioned tables are synced as part of them
+	if pgSchemaTable.PartitionRoot != "" && syncer.config.Pg.IsMergedPartitionedTable(pgSchemaTable.PartitionRoot) {
+		return false
+	}
+
+	// Partitioned tables are synced only if merged, and sub-partitioned tables only as part of their root
+	if pgSchemaTable.Kind == PG_RELKIND_PARTITIONED_TABLE {
+		if pgSchemaTable.PartitionRoot != "" || !syncer.config.Pg.IsMergedPartitionedTable(pgSchemaTable.ToConfigArg()) {
+			return false
+		}
+	}
+
+	// Views are synced only if included explicitly
+	if pgSchemaTable.IsView() {
+		return syncer.config.Pg.IncludeViews != nil && HasExactOrWildcardMatch(syncer.config.Pg.IncludeViews, pgSchemaTable.ToConfigArg())
+	}
+
 	if syncer.config.Pg.IncludeTables != nil {
 		return HasExactOrWildcardMatch(syncer.config.Pg.IncludeTables, pgSchemaTable.ToConfigArg())
 	}
@@ -144,100 +552,274 @@ func (syncer *Syncer) shouldSyncTable(pgSchemaTable PgSchemaTable) bool {
 	return true
 }
 
-func (syncer *Syncer) listPgSchemas(conn *pgx.Conn) []string {
+// The mode in the sync config file takes precedence over --pg-incrementally-refreshed-tables
+func (syncer *Syncer) isIncrementalRefreshEnabled(pgSchemaTable PgSchemaTable) bool {
+	// Views don't have xmin, and refreshed materialized views have all rows rewritten
+	if pgSchemaTable.IsView() {
+		return false
+	}
+
+	syncTableConfig := syncer.config.Pg.SyncTableConfig(pgSchemaTable)
+	if syncTableConfig != nil && syncTableConfig.Mode != "" {
+		return syncTableConfig.Mode == SYNC_MODE_INCREMENTAL
+	}
+
+	return syncer.config.Pg.IncrementallyRefreshedTables != nil && HasExactOrWildcardMatch(syncer.config.Pg.IncrementallyRefreshedTables, pgSchemaTable.ToConfigArg())
+}
+
+// Returns false if no table has to be synced again
+func (syncer *Syncer) NextSyncAt() (time.Time, bool) {
+	return syncer.syncerScheduler.NextSyncAt()
+}
+
+func (syncer *Syncer) listPgSchemas(conn *pgx.Conn) ([]string, error) {
 	var schemas []string
 
 	schemasRows, err := conn.Query(
 		context.Background(),
 		"SELECT schema_name FROM information_schema.schemata WHERE schema_name NOT IN ('pg_catalog', 'pg_toast', 'information_schema')",
 	)
-	PanicIfError(err, syncer.config)
+	if err != nil {
+		return nil, err
+	}
 	defer schemasRows.Close()
 
 	for schemasRows.Next() {
 		var schema string
 		err = schemasRows.Scan(&schema)
-		PanicIfError(err, syncer.config)
+		if err != nil {
+			return nil, err
+		}
 		schemas = append(schemas, schema)
 	}
 
-	return schemas
+	return schemas, schemasRows.Err()
 }
 
-func (syncer *Syncer) listPgSchemaTables(conn *pgx.Conn, schema string) []PgSchemaTable {
+func (syncer *Syncer) listPgSchemaTables(conn *pgx.Conn, schema string) ([]PgSchemaTable, error) {
 	var pgSchemaTables []PgSchemaTable
 
 	tablesRows, err := conn.Query(
 		context.Background(),
 		`
-		SELECT pg_class.relname AS table, COALESCE(parent.relname, '') AS parent_partitioned_table
+		SELECT
+			pg_class.relname AS table,
+			COALESCE(parent.relname, '') AS parent_partitioned_table,
+			COALESCE(root_namespace.nspname || '.' || root.relname, '') AS partition_root,
+			pg_class.relkind::text
 		FROM pg_class
 		JOIN pg_namespace ON pg_namespace.oid = pg_class.relnamespace
 		LEFT JOIN pg_inherits ON pg_inherits.inhrelid = pg_class.oid
 		LEFT JOIN pg_class AS parent ON pg_inherits.inhparent = parent.oid
-		WHERE pg_namespace.nspname = $1 AND pg_class.relkind = 'r';
+		LEFT JOIN pg_class AS root ON pg_class.relispartition AND root.oid = pg_partition_root(pg_class.oid)
+		LEFT JOIN pg_namespace AS root_namespace ON root_namespace.oid = root.relnamespace
+		WHERE pg_namespace.nspname = $1 AND pg_class.relkind IN ('r', 'v', 'm', 'p');
 		`,
 		schema,
 	)
-	PanicIfError(err, syncer.config)
+	if err != nil {
+		return nil, err
+	}
 	defer tablesRows.Close()
 
 	for tablesRows.Next() {
 		pgSchemaTable := PgSchemaTable{Schema: schema}
-		err = tablesRows.Scan(&pgSchemaTable.Table, &pgSchemaTable.ParentPartitionedTable)
-		PanicIfError(err, syncer.config)
+		err = tablesRows.Scan(&pgSchemaTable.Table, &pgSchemaTable.ParentPartitionedTable, &pgSchemaTable.PartitionRoot, &pgSchemaTable.Kind)
+		if err != nil {
+			return nil, err
+		}
 		pgSchemaTables = append(pgSchemaTables, pgSchemaTable)
 	}
 
-	return pgSchemaTables
+	return pgSchemaTables, tablesRows.Err()
 }
 
-func (syncer *Syncer) calculateRowCountPerBatch(pgSchemaTable PgSchemaTable, conn *pgx.Conn) int {
+func (syncer *Syncer) calculateRowCountPerBatch(pgSchemaTable PgSchemaTable, conn *pgx.Conn) (int, error) {
 	var tableSize int64
 	var rowCount int64
 
-	err := conn.QueryRow(
-		context.Background(),
-		`
-		SELECT
-			pg_total_relation_size(c.oid) AS table_size,
-			CASE
-				WHEN c.reltuples >= 0 THEN c.reltuples::bigint
-				ELSE (SELECT count(*) FROM `+pgSchemaTable.String()+`)
-			END AS row_count
-		FROM pg_class c
-		JOIN pg_namespace n ON n.oid = c.relnamespace
-		WHERE n.nspname = $1 AND c.relname = $2 AND c.relkind = 'r'`,
-		pgSchemaTable.Schema,
-		pgSchemaTable.Table,
-	).Scan(&tableSize, &rowCount)
-	PanicIfError(err, syncer.config)
+	// Views don't have storage, so estimate the row size from a sample
+	if pgSchemaTable.Kind == PG_RELKIND_VIEW {
+		var rowSize *float64
+		err := conn.QueryRow(
+			context.Background(),
+			"SELECT avg(pg_column_size(sample.*)) FROM (SELECT * FROM "+pgSchemaTable.String()+" LIMIT 1000) sample",
+		).Scan(&rowSize)
+		if err != nil {
+			return 0, err
+		}
+		LogDebug(syncer.config, "Sampled row size:", rowSize)
+
+		if rowSize == nil || *rowSize < 1 {
+			return 1, nil
+		}
+		return max(int(MAX_PG_ROWS_BATCH_SIZE / *rowSize), 1), nil
+	}
+
+	// Partitioned tables don't have storage, so sum up their partitions
+	if pgSchemaTable.Kind == PG_RELKIND_PARTITIONED_TABLE {
+		err := conn.QueryRow(
+			context.Background(),
+			`
+			SELECT COALESCE(sum(pg_total_relation_size(tree.relid)), 0)::bigint, (SELECT count(*) FROM `+pgSchemaTable.String()+`)
+			FROM pg_partition_tree($1::regclass) AS tree
+			WHERE tree.isleaf`,
+			pgSchemaTable.String(),
+		).Scan(&tableSize, &rowCount)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		err := conn.QueryRow(
+			context.Background(),
+			`
+			SELECT
+				pg_total_relation_size(c.oid) AS table_size,
+				CASE
+					WHEN c.reltuples >= 0 THEN c.reltuples::bigint
+					ELSE (SELECT count(*) FROM `+pgSchemaTable.String()+`)
+				END AS row_count
+			FROM pg_class c
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = $1 AND c.relname = $2 AND c.relkind IN ('r', 'm')`,
+			pgSchemaTable.Schema,
+			pgSchemaTable.Table,
+		).Scan(&tableSize, &rowCount)
+		if err != nil {
+			return 0, err
+		}
+	}
 	LogDebug(syncer.config, "Table size:", tableSize, "Row count:", rowCount)
 
 	if tableSize == 0 || rowCount == 0 {
-		return 1
+		return 1, nil
 	}
 
 	rowSize := tableSize / rowCount
 	rowCountPerBatch := int(MAX_PG_ROWS_BATCH_SIZE / rowSize)
 	if rowCountPerBatch == 0 {
-		return 1
+		return 1, nil
 	}
 
-	return rowCountPerBatch
+	return rowCountPerBatch, nil
 }
 
-func (syncer *Syncer) newConnection(ctx context.Context, databaseUrl string) *pgx.Conn {
+func (syncer *Syncer) newConnection(ctx context.Context, databaseUrl string) (*pgx.Conn, error) {
 	conn, err := pgx.Connect(ctx, databaseUrl)
-	PanicIfError(err, syncer.config)
+	if err != nil {
+		return nil, err
+	}
 
 	_, err = conn.Exec(ctx, "BEGIN TRANSACTION ISOLATION LEVEL SERIALIZABLE READ ONLY DEFERRABLE")
-	PanicIfError(err, syncer.config)
+	if err != nil {
+		conn.Close(ctx)
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (syncer *Syncer) newConnections(ctx context.Context, databaseUrl string) (structureConn *pgx.Conn, copyConn *pgx.Conn, err error) {
+	structureConn, err = syncer.newConnection(ctx, databaseUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	copyConn, err = syncer.newConnection(ctx, databaseUrl)
+	if err != nil {
+		structureConn.Close(ctx)
+		return nil, nil, err
+	}
+
+	return structureConn, copyConn, nil
+}
+
+// Returns copyConn followed by extra connections sharing its snapshot, so a large table can be copied in parallel consistently
+func (syncer *Syncer) newParallelCopyConnections(ctx context.Context, databaseUrl string, pgSchemaTable PgSchemaTable, structureConn *pgx.Conn, copyConn *pgx.Conn) ([]*pgx.Conn, error) {
+	copyConns := []*pgx.Conn{copyConn}
+
+	workerCount, err := syncer.calculateParallelCopyWorkerCount(pgSchemaTable, structureConn)
+	if err != nil {
+		return nil, err
+	}
+	if workerCount <= 1 {
+		return copyConns, nil
+	}
+	LogDebug(syncer.config, "Parallel COPY workers:", workerCount)
+
+	var snapshotId string
+	err = copyConn.QueryRow(ctx, "SELECT pg_export_snapshot()").Scan(&snapshotId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 1; i < workerCount; i++ {
+		conn, err := syncer.newSnapshotConnection(ctx, databaseUrl, snapshotId)
+		if err != nil {
+			for _, parallelCopyConn := range copyConns[1:] {
+				parallelCopyConn.Close(ctx)
+			}
+			return nil, err
+		}
+
+		copyConns = append(copyConns, conn)
+	}
+
+	return copyConns, nil
+}
+
+func (syncer *Syncer) newSnapshotConnection(ctx context.Context, databaseUrl string, snapshotId string) (*pgx.Conn, error) {
+	conn, err := pgx.Connect(ctx, databaseUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = conn.Exec(ctx, "BEGIN TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY")
+	if err == nil {
+		_, err = conn.Exec(ctx, "SET TRANSACTION SNAPSHOT '"+snapshotId+"'")
+	}
+	if err != nil {
+		conn.Close(ctx)
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (syncer *Syncer) calculateParallelCopyWorkerCount(pgSchemaTable PgSchemaTable, conn *pgx.Conn) (int, error) {
+	if syncer.config.Pg.ParallelCopyWorkers <= 1 {
+		return 1, nil
+	}
+
+	var tableSize int64
+	var serverVersionNum int
+	err := conn.QueryRow(
+		context.Background(),
+		"SELECT pg_relation_size($1::regclass), current_setting('server_version_num')::int",
+		pgSchemaTable.String(),
+	).Scan(&tableSize, &serverVersionNum)
+	if err != nil {
+		return 0, err
+	}
+
+	// Without TID range scans, each worker would scan the whole table
+	if serverVersionNum < MIN_PG_VERSION_NUM_TID_RANGE_SCAN {
+		LogInfo(syncer.config, "Copying", pgSchemaTable.String(), "with a single COPY since parallel COPY workers require Postgres 14 or later")
+		return 1, nil
+	}
+
+	workerCount := int(tableSize / CTID_RANGE_SIZE)
+	if workerCount > syncer.config.Pg.ParallelCopyWorkers {
+		return syncer.config.Pg.ParallelCopyWorkers, nil
+	}
+	if workerCount < 1 {
+		return 1, nil
+	}
 
-	return conn
+	return workerCount, nil
 }
 
-func (syncer *Syncer) deleteOldIcebergSchemaTables(pgSchemaTables []PgSchemaTable) {
+func (syncer *Syncer) deleteOldIcebergSchemaTables(pgSchemaTables []PgSchemaTable) error {
 	var prefixedPgSchemaTables []PgSchemaTable
 	for _, pgSchemaTable := range pgSchemaTables {
 		prefixedPgSchemaTables = append(
@@ -247,7 +829,9 @@ func (syncer *Syncer) deleteOldIcebergSchemaTables(pgSchemaTables []PgSchemaTabl
 	}
 
 	icebergSchemas, err := syncer.icebergReader.Schemas()
-	PanicIfError(err, syncer.config)
+	if err != nil {
+		return err
+	}
 
 	for _, icebergSchema := range icebergSchemas {
 		found := false
@@ -265,7 +849,9 @@ func (syncer *Syncer) deleteOldIcebergSchemaTables(pgSchemaTables []PgSchemaTabl
 	}
 
 	icebergSchemaTables, err := syncer.icebergReader.SchemaTables()
-	PanicIfError(err, syncer.config)
+	if err != nil {
+		return err
+	}
 
 	for _, icebergSchemaTable := range icebergSchemaTables.Values() {
 		found := false
@@ -281,35 +867,52 @@ func (syncer *Syncer) deleteOldIcebergSchemaTables(pgSchemaTables []PgSchemaTabl
 			syncer.icebergWriter.DeleteSchemaTable(icebergSchemaTable)
 		}
 	}
-}
 
-func (syncer *Syncer) writeInternalMetadata(pgSchemaTable PgSchemaTable, conn *pgx.Conn) {
-	var xminMax *uint32
-	var xminMin *uint32
+	return nil
+}
 
-	err := conn.QueryRow(
-		context.Background(),
-		"SELECT xmin FROM "+pgSchemaTable.String()+" ORDER BY age(xmin) ASC LIMIT 1",
-	).Scan(&xminMax)
-	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
-		PanicIfError(err, syncer.config)
+func (syncer *Syncer) writeInternalMetadata(pgSchemaTable PgSchemaTable, conn *pgx.Conn, syncJournalTable *SyncJournalTable) error {
+	// Views don't have xmin and are always fully refreshed
+	if pgSchemaTable.Kind == PG_RELKIND_VIEW {
+		return syncer.icebergWriter.storage.WriteInternalTableMetadata(pgSchemaTable, InternalTableMetadata{
+			LastSyncedAt:  time.Now().Unix(),
+			RowFilter:     syncer.config.Pg.RowFilter(pgSchemaTable),
+			SortOrder:     syncer.sortOrder(pgSchemaTable),
+			PartitionSpec: syncer.partitionSpec(pgSchemaTable),
+			ColumnRules:   NewSyncerColumnRules(syncer.config, pgSchemaTable).Fingerprint(),
+		})
 	}
 
-	err = conn.QueryRow(
-		context.Background(),
-		"SELECT xmin FROM "+pgSchemaTable.String()+" ORDER BY age(xmin) DESC LIMIT 1",
-	).Scan(&xminMin)
-	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
-		PanicIfError(err, syncer.config)
+	xminMax, xminMin, err := syncer.syncerFullRefresh.xminRange(pgSchemaTable, conn)
+	if err != nil {
+		return err
+	}
+	// Rows copied by primary key ranges before an interruption can be outdated, so rows modified since the table started syncing are synced again incrementally
+	if syncJournalTable != nil && len(syncJournalTable.PrimaryKeyRanges) > 0 {
+		xminMax = syncJournalTable.XminMax
+		xminMin = syncJournalTable.XminMin
 	}
 
 	metadata := InternalTableMetadata{
-		LastSyncedAt: time.Now().Unix(),
-		XminMax:      xminMax,
-		XminMin:      xminMin,
+		LastSyncedAt:  time.Now().Unix(),
+		XminMax:       xminMax,
+		XminMin:       xminMin,
+		RowFilter:     syncer.config.Pg.RowFilter(pgSchemaTable),
+		SortOrder:     syncer.sortOrder(pgSchemaTable),
+		PartitionSpec: syncer.partitionSpec(pgSchemaTable),
+		ColumnRules:   NewSyncerColumnRules(syncer.config, pgSchemaTable).Fingerprint(),
 	}
-	err = syncer.icebergWriter.storage.WriteInternalTableMetadata(pgSchemaTable, metadata)
-	PanicIfError(err, syncer.config)
+	return syncer.icebergWriter.storage.WriteInternalTableMetadata(pgSchemaTable, metadata)
+}
+
+// Sort expressions from the sync config file, e.g. "tenant_id, created_at DESC"
+func (syncer *Syncer) sortOrder(pgSchemaTable PgSchemaTable) string {
+	return strings.Join(syncer.config.Pg.SortBy(pgSchemaTable), ", ")
+}
+
+// Partition expressions from the sync config file, e.g. "day(created_at), bucket[16](user_id)"
+func (syncer *Syncer) partitionSpec(pgSchemaTable PgSchemaTable) string {
+	return strings.Join(syncer.config.Pg.PartitionBy(pgSchemaTable), ", ")
 }
 
 type AnonymousAnalyticsData struct {
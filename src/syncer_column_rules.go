@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Applies column rules from the sync config file in Postgres, so excluded columns and raw values of transformed columns never leave the database
+type SyncerColumnRules struct {
+	config        *Config
+	pgSchemaTable PgSchemaTable
+	columnConfigs map[string]SyncColumnConfig
+}
+
+func NewSyncerColumnRules(config *Config, pgSchemaTable PgSchemaTable) *SyncerColumnRules {
+	columnConfigs := make(map[string]SyncColumnConfig)
+
+	syncTableConfig := config.Pg.SyncTableConfig(pgSchemaTable)
+	if syncTableConfig != nil {
+		for _, columnConfig := range syncTableConfig.Columns {
+			columnConfigs[columnConfig.Column] = columnConfig
+		}
+	}
+
+	return &SyncerColumnRules{
+		config:        config,
+		pgSchemaTable: pgSchemaTable,
+		columnConfigs: columnConfigs,
+	}
+}
+
+// Returns the names of the table columns without excluded columns
+func (rules *SyncerColumnRules) ColumnNames(conn *pgx.Conn) ([]string, error) {
+	rows, err := conn.Query(context.Background(), "SELECT * FROM "+rules.pgSchemaTable.String()+" LIMIT 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columnNames []string
+	for _, fieldDescription := range rows.FieldDescriptions() {
+		if rules.columnConfigs[fieldDescription.Name].Action == SYNC_COLUMN_ACTION_EXCLUDE {
+			continue
+		}
+		columnNames = append(columnNames, fieldDescription.Name)
+	}
+
+	if len(columnNames) == 0 {
+		return nil, errors.New("couldn't read data from " + rules.pgSchemaTable.String() + " without excluded columns")
+	}
+
+	return columnNames, nil
+}
+
+// Returns the SELECT list to COPY the columns with transformations, or * if there are no column rules
+func (rules *SyncerColumnRules) SelectList(columnNames []string) string {
+	if len(rules.columnConfigs) == 0 {
+		return "*"
+	}
+
+	return rules.ColumnList(columnNames)
+}
+
+// Returns the SELECT list with transformations listing all columns by name
+func (rules *SyncerColumnRules) ColumnList(columnNames []string) string {
+	selectExpressions := make([]string, len(columnNames))
+	for i, columnName := range columnNames {
+		quotedColumnName := `"` + strings.ReplaceAll(columnName, `"`, `""`) + `"`
+
+		switch rules.columnConfigs[columnName].Action {
+		case SYNC_COLUMN_ACTION_HASH:
+			selectExpressions[i] = "encode(sha256(convert_to(" + rules.quoteString(rules.config.Pg.ColumnHashSalt) + " || " + quotedColumnName + "::text, 'UTF8')), 'hex') AS " + quotedColumnName
+		case SYNC_COLUMN_ACTION_REDACT:
+			selectExpressions[i] = "CASE WHEN " + quotedColumnName + " IS NULL THEN NULL ELSE 'REDACTED' END AS " + quotedColumnName
+		case SYNC_COLUMN_ACTION_TRUNCATE:
+			selectExpressions[i] = "left(" + quotedColumnName + "::text, " + IntToString(rules.columnConfigs[columnName].Length) + ") AS " + quotedColumnName
+		case SYNC_COLUMN_ACTION_EMAIL_DOMAIN:
+			selectExpressions[i] = "split_part(" + quotedColumnName + "::text, '@', 2) AS " + quotedColumnName
+		default:
+			selectExpressions[i] = quotedColumnName
+		}
+	}
+
+	return strings.Join(selectExpressions, ", ")
+}
+
+// Returns a hash of the column rules and the hash salt, or an empty string if there are no column rules
+func (rules *SyncerColumnRules) Fingerprint() string {
+	if len(rules.columnConfigs) == 0 {
+		return ""
+	}
+
+	columnNames := make([]string, 0, len(rules.columnConfigs))
+	for columnName := range rules.columnConfigs {
+		columnNames = append(columnNames, columnName)
+	}
+	sort.Strings(columnNames)
+
+	var fingerprintParts []string
+	for _, columnName := range columnNames {
+		columnConfig := rules.columnConfigs[columnName]
+		fingerprintParts = append(fingerprintParts, columnName, columnConfig.Action, IntToString(columnConfig.Length))
+		if columnConfig.Action == SYNC_COLUMN_ACTION_HASH {
+			fingerprintParts = append(fingerprintParts, rules.config.Pg.ColumnHashSalt)
+		}
+	}
+
+	return fmt.Sprintf("%x", sha256Hash([]byte(strings.Join(fingerprintParts, "\x00"))))
+}
+
+func (rules *SyncerColumnRules) IsTransformed(columnName string) bool {
+	action := rules.columnConfigs[columnName].Action
+	return action != "" && action != SYNC_COLUMN_ACTION_EXCLUDE
+}
+
+// Transformed columns are synced as text
+func (rules *SyncerColumnRules) TransformSchemaColumns(pgSchemaColumns []PgSchemaColumn) {
+	for i, pgSchemaColumn := range pgSchemaColumns {
+		switch rules.columnConfigs[pgSchemaColumn.ColumnName].Action {
+		case SYNC_COLUMN_ACTION_HASH, SYNC_COLUMN_ACTION_REDACT, SYNC_COLUMN_ACTION_TRUNCATE, SYNC_COLUMN_ACTION_EMAIL_DOMAIN:
+			pgSchemaColumns[i].DataType = "text"
+			pgSchemaColumns[i].UdtName = "text"
+			pgSchemaColumns[i].CharacterMaximumLength = "0"
+			pgSchemaColumns[i].NumericPrecision = "0"
+			pgSchemaColumns[i].NumericScale = "0"
+			pgSchemaColumns[i].DatetimePrecision = "0"
+			pgSchemaColumns[i].Namespace = PG_SCHEMA_PG_CATALOG
+		}
+	}
+}
+
+func (rules *SyncerColumnRules) quoteString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Combines small data files of synced tables, e.g. written by frequent incremental syncs, into larger ones and applies merge-on-read delete files
+type SyncerCompaction struct {
+	config            *Config
+	icebergWriter     *IcebergWriter
+	syncerFullRefresh *SyncerFullRefresh
+}
+
+func NewSyncerCompaction(config *Config, icebergWriter *IcebergWriter, syncerFullRefresh *SyncerFullRefresh) *SyncerCompaction {
+	return &SyncerCompaction{
+		config:            config,
+		icebergWriter:     icebergWriter,
+		syncerFullRefresh: syncerFullRefresh,
+	}
+}
+
+// Compacts a previously synced table with the columns it was synced with. Returns the compacted Parquet files
+func (syncer *SyncerCompaction) CompactPgTable(pgSchemaTable PgSchemaTable, structureConn *pgx.Conn) ([]ParquetFile, error) {
+	// Merged partitioned tables keep Parquet files per partition, and data files of partitioned Iceberg tables can't be combined across partitions
+	if pgSchemaTable.Kind == PG_RELKIND_PARTITIONED_TABLE || len(syncer.config.Pg.PartitionBy(pgSchemaTable)) > 0 {
+		LogDebug(syncer.config, "Skipping compaction of partitioned", pgSchemaTable.String())
+		return []ParquetFile{}, nil
+	}
+
+	// Compacted rows are sorted by the sort order the table was synced with
+	internalTableMetadata, err := syncer.icebergWriter.storage.InternalTableMetadata(pgSchemaTable)
+	if err != nil {
+		return nil, err
+	}
+	if internalTableMetadata.SortOrder != strings.Join(syncer.config.Pg.SortBy(pgSchemaTable), ", ") {
+		LogInfo(syncer.config, "Skipping compaction of", pgSchemaTable.String(), "with the sort order changed since the last sync")
+		return []ParquetFile{}, nil
+	}
+
+	columnRules := NewSyncerColumnRules(syncer.config, pgSchemaTable)
+	columnNames, err := columnRules.ColumnNames(structureConn)
+	if err != nil {
+		return nil, err
+	}
+	var pgSchemaColumns []PgSchemaColumn
+	if pgSchemaTable.IsView() {
+		pgSchemaColumns, err = syncer.syncerFullRefresh.pgViewSchemaColumns(structureConn, pgSchemaTable, columnNames)
+	} else {
+		pgSchemaColumns, err = syncer.syncerFullRefresh.pgTableSchemaColumns(structureConn, pgSchemaTable, columnNames)
+	}
+	if err != nil {
+		return nil, err
+	}
+	columnRules.TransformSchemaColumns(pgSchemaColumns)
+	sortOrder, err := NewIcebergSortOrder(syncer.config.Pg.SortBy(pgSchemaTable), pgSchemaColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	return syncer.icebergWriter.Compact(pgSchemaTable.ToIcebergSchemaTable(), pgSchemaColumns, sortOrder, syncer.config.Compaction.TargetFileSize, 2, syncer.config.Compaction.MaxDeleteFileCount), nil
+}
@@ -3,10 +3,14 @@ package main
 import (
 	"context"
 	"encoding/csv"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -14,6 +18,7 @@ import (
 
 const (
 	MAX_WRITE_PARQUET_PAYLOAD_SIZE = 4 * 1024 * 1024 * 1024 // 4 GB (compressed to ~512 MB Parquet)
+	CTID_RANGE_SIZE                = 1 * 1024 * 1024 * 1024 // 1 GB of table heap copied by a parallel COPY worker at once
 )
 
 type SyncerFullRefresh struct {
@@ -28,17 +33,62 @@ func NewSyncerFullRefresh(config *Config, icebergWriter *IcebergWriter) *SyncerF
 	}
 }
 
-func (syncer *SyncerFullRefresh) SyncPgTable(pgSchemaTable PgSchemaTable, rowCountPerBatch int, structureConn *pgx.Conn, copyConn *pgx.Conn) {
-	// Create a capped buffer read and written in parallel
-	cappedBuffer := NewCappedBuffer(MAX_IN_MEMORY_BUFFER_SIZE, syncer.config)
+func (syncer *SyncerFullRefresh) SyncPgTable(pgSchemaTable PgSchemaTable, rowCountPerBatch int, structureConn *pgx.Conn, copyConns []*pgx.Conn, syncerJournal *SyncerJournal) (SyncTableStats, error) {
+	schemaTable := pgSchemaTable.ToIcebergSchemaTable()
+	columnRules := NewSyncerColumnRules(syncer.config, pgSchemaTable)
+	columnNames, err := columnRules.ColumnNames(structureConn)
+	if err != nil {
+		return SyncTableStats{}, err
+	}
+	var pgSchemaColumns []PgSchemaColumn
+	if pgSchemaTable.IsView() {
+		pgSchemaColumns, err = syncer.pgViewSchemaColumns(structureConn, pgSchemaTable, columnNames)
+	} else {
+		pgSchemaColumns, err = syncer.pgTableSchemaColumns(structureConn, pgSchemaTable, columnNames)
+	}
+	if err != nil {
+		return SyncTableStats{}, err
+	}
+	columnRules.TransformSchemaColumns(pgSchemaColumns)
+	selectList := columnRules.SelectList(columnNames)
+	rowFilter := syncer.config.Pg.RowFilter(pgSchemaTable)
+	partitionSpec, err := NewIcebergPartitionSpec(syncer.config.Pg.PartitionBy(pgSchemaTable), pgSchemaColumns)
+	if err != nil {
+		return SyncTableStats{}, err
+	}
+	if partitionSpec.IsPartitioned() {
+		LogInfo(syncer.config, "Partitioning by", partitionSpec.String())
+	}
+	sortOrder, err := NewIcebergSortOrder(syncer.config.Pg.SortBy(pgSchemaTable), pgSchemaColumns)
+	if err != nil {
+		return SyncTableStats{}, err
+	}
 
-	var waitGroup sync.WaitGroup
+	var primaryKeyColumns []PgPrimaryKeyColumn
+	if !pgSchemaTable.IsView() {
+		primaryKeyColumns, err = syncer.pgPrimaryKeyColumns(structureConn, pgSchemaTable)
+		if err != nil {
+			return SyncTableStats{}, err
+		}
+	}
+	fingerprint, err := syncer.fingerprint(pgSchemaColumns, primaryKeyColumns, partitionSpec, sortOrder, selectList, rowFilter)
+	if err != nil {
+		return SyncTableStats{}, err
+	}
+	syncJournalTable, err := syncerJournal.ResumeTable(pgSchemaTable, fingerprint)
+	if err != nil {
+		return SyncTableStats{}, err
+	}
+	if syncJournalTable != nil {
+		LogInfo(syncer.config, "Resuming from primary key range", syncJournalTable.CompletedPrimaryKeyRanges+1, "of", len(syncJournalTable.PrimaryKeyRanges), "with", len(syncJournalTable.ParquetFiles), "written Parquet file(s)...")
+	} else {
+		syncJournalTable, err = syncer.startJournalTable(pgSchemaTable, primaryKeyColumns, fingerprint, structureConn, syncerJournal)
+		if err != nil {
+			return SyncTableStats{}, err
+		}
+	}
 
-	// Copy from PG to cappedBuffer in a separate goroutine in parallel
-	waitGroup.Add(1)
-	go func() {
-		syncer.copyFromPgTable(pgSchemaTable, copyConn, cappedBuffer, &waitGroup)
-	}()
+	var waitGroup sync.WaitGroup
 
 	// Ping PG using structureConn in a separate goroutine in parallel to keep the connection alive
 	waitGroup.Add(1)
@@ -46,56 +96,322 @@ func (syncer *SyncerFullRefresh) SyncPgTable(pgSchemaTable PgSchemaTable, rowCou
 	go func() {
 		syncer.pingPg(structureConn, &stopPingChannel, &waitGroup)
 	}()
+	defer func() {
+		close(stopPingChannel) // Stop the pingPg goroutine
+		waitGroup.Wait()       // Wait for the pingPg goroutine to finish
+	}()
 
-	// Read the header to get the column names
-	csvReader := csv.NewReader(cappedBuffer)
-	csvHeader, err := csvReader.Read()
-	PanicIfError(err, syncer.config)
-
-	schemaTable := pgSchemaTable.ToIcebergSchemaTable()
-	pgSchemaColumns := syncer.pgTableSchemaColumns(structureConn, pgSchemaTable, csvHeader)
-	reachedEnd := false
-	totalRowCount := 0
-
-	// Write to Iceberg in a separate goroutine in parallel
 	LogInfo(syncer.config, "Writing to Iceberg...")
-	syncer.icebergWriter.Write(schemaTable, pgSchemaColumns, MAX_WRITE_PARQUET_PAYLOAD_SIZE, func() [][]string {
-		if reachedEnd {
-			return [][]string{}
-		}
+	var parquetFiles []ParquetFile
+	if len(syncJournalTable.PrimaryKeyRanges) > 0 {
+		// Copy primary key ranges in batches, one range per copy connection, and checkpoint written Parquet files after each batch
+		for syncJournalTable.CompletedPrimaryKeyRanges < len(syncJournalTable.PrimaryKeyRanges) {
+			batchEndIndex := min(syncJournalTable.CompletedPrimaryKeyRanges+len(copyConns), len(syncJournalTable.PrimaryKeyRanges))
+			copyRanges := []CopyRange{}
+			for _, primaryKeyRange := range syncJournalTable.PrimaryKeyRanges[syncJournalTable.CompletedPrimaryKeyRanges:batchEndIndex] {
+				copyRanges = append(copyRanges, primaryKeyRange)
+			}
 
-		var rows [][]string
-		for {
-			row, err := csvReader.Read()
+			batchParquetFiles, err := syncer.syncCopyRanges(pgSchemaTable, schemaTable, pgSchemaColumns, partitionSpec, sortOrder, selectList, rowFilter, copyRanges, rowCountPerBatch, copyConns)
+			if err != nil {
+				return SyncTableStats{}, err
+			}
+			err = syncerJournal.CompletePrimaryKeyRanges(len(copyRanges), batchParquetFiles)
+			if err != nil {
+				return SyncTableStats{}, err
+			}
+		}
+		parquetFiles = syncJournalTable.ParquetFiles
+	} else {
+		ctidRanges, err := syncer.ctidRanges(pgSchemaTable, structureConn, len(copyConns))
+		if err != nil {
+			return SyncTableStats{}, err
+		}
 
-			if err == io.EOF {
-				reachedEnd = true
-				break
+		// Copy ctid ranges in batches, one range per copy connection
+		for batchStartIndex := 0; batchStartIndex < len(ctidRanges); batchStartIndex += len(copyConns) {
+			batchEndIndex := min(batchStartIndex+len(copyConns), len(ctidRanges))
+			copyRanges := []CopyRange{}
+			for _, ctidRange := range ctidRanges[batchStartIndex:batchEndIndex] {
+				copyRanges = append(copyRanges, ctidRange)
 			}
+
+			batchParquetFiles, err := syncer.syncCopyRanges(pgSchemaTable, schemaTable, pgSchemaColumns, partitionSpec, sortOrder, selectList, rowFilter, copyRanges, rowCountPerBatch, copyConns)
 			if err != nil {
-				PanicIfError(err, syncer.config)
+				return SyncTableStats{}, err
 			}
+			parquetFiles = append(parquetFiles, batchParquetFiles...)
+		}
+	}
+
+	LogDebug(syncer.config, "Committing", len(parquetFiles), "Parquet file(s)...")
+	syncer.icebergWriter.CommitParquetFiles(schemaTable, pgSchemaColumns, partitionSpec, sortOrder, parquetFiles)
+
+	var rowCount int64
+	for _, parquetFile := range parquetFiles {
+		rowCount += parquetFile.RecordCount
+	}
+	return NewSyncTableStats(rowCount, parquetFiles), nil
+}
+
+// Copies from pgSchemaTable and writes Parquet files into schemaTable, which differ for partitions of merged partitioned tables
+func (syncer *SyncerFullRefresh) syncCopyRanges(pgSchemaTable PgSchemaTable, schemaTable IcebergSchemaTable, pgSchemaColumns []PgSchemaColumn, partitionSpec IcebergPartitionSpec, sortOrder IcebergSortOrder, selectList string, rowFilter string, copyRanges []CopyRange, rowCountPerBatch int, copyConns []*pgx.Conn) (parquetFiles []ParquetFile, err error) {
+	cappedBuffers := make([]*CappedBuffer, len(copyRanges))
+	csvReaders := make([]*csv.Reader, len(copyRanges))
+	copyErrs := make([]error, len(copyRanges))
+
+	var waitGroup sync.WaitGroup
+
+	// Unblock the Read goroutines if writing stopped early, wait for them, and return their errors as well
+	defer func() {
+		for _, cappedBuffer := range cappedBuffers {
+			cappedBuffer.Close()
+		}
+		waitGroup.Wait()
+		err = errors.Join(err, errors.Join(copyErrs...))
+	}()
+
+	// Copy from PG to cappedBuffers in separate goroutines in parallel, one per copy range
+	for i, copyRange := range copyRanges {
+		// Create a capped buffer read and written in parallel
+		cappedBuffers[i] = NewCappedBuffer(MAX_IN_MEMORY_BUFFER_SIZE, syncer.config)
+		csvReaders[i] = csv.NewReader(cappedBuffers[i])
+
+		waitGroup.Add(1)
+		go func() {
+			copyErrs[i] = syncer.copyFromPgTable(pgSchemaTable, selectList, rowFilter, copyRange, copyConns[i], cappedBuffers[i], &waitGroup)
+		}()
+	}
+
+	// Skip the headers, the column names are already known
+	for _, csvReader := range csvReaders {
+		_, err := csvReader.Read()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var totalRowCount atomic.Int64
+
+	loadRowsFuncs := make([]func() [][]string, len(csvReaders))
+	for i, csvReader := range csvReaders {
+		reachedEnd := false
 
-			rows = append(rows, row)
-			if len(rows) >= rowCountPerBatch {
-				break
+		loadRowsFuncs[i] = func() [][]string {
+			if reachedEnd {
+				return [][]string{}
 			}
+
+			var rows [][]string
+			for {
+				row, err := csvReader.Read()
+
+				if err == io.EOF {
+					reachedEnd = true
+					break
+				}
+				if err != nil {
+					PanicIfError(err, syncer.config)
+				}
+
+				rows = append(rows, row)
+				if len(rows) >= rowCountPerBatch {
+					break
+				}
+			}
+
+			LogDebug(syncer.config, "Writing", totalRowCount.Add(int64(len(rows))), "rows to Parquet...")
+			runtime.GC() // To reduce Parquet Go memory leakage
+
+			return rows
+		}
+	}
+
+	// Write to Parquet in separate goroutines in parallel
+	return syncer.icebergWriter.WriteParquetFiles(schemaTable, pgSchemaColumns, partitionSpec, sortOrder, MAX_WRITE_PARQUET_PAYLOAD_SIZE, loadRowsFuncs...), nil
+}
+
+// Splits the table heap into contiguous page ranges that can be copied in parallel, or copies it with a single COPY without parallel workers
+func (syncer *SyncerFullRefresh) ctidRanges(pgSchemaTable PgSchemaTable, conn *pgx.Conn, copyWorkerCount int) ([]CtidRange, error) {
+	if copyWorkerCount <= 1 {
+		return []CtidRange{{}}, nil
+	}
+
+	var pageCount int64
+	var blockSize int64
+	err := conn.QueryRow(
+		context.Background(),
+		"SELECT (pg_relation_size($1::regclass) / current_setting('block_size')::int)::bigint, current_setting('block_size')::bigint",
+		pgSchemaTable.String(),
+	).Scan(&pageCount, &blockSize)
+	if err != nil {
+		return nil, err
+	}
+	LogDebug(syncer.config, "Page count:", pageCount)
+
+	pageCountPerRange := CTID_RANGE_SIZE / blockSize
+	if pageCount <= pageCountPerRange {
+		return []CtidRange{{}}, nil
+	}
+
+	rangeCount := (pageCount + pageCountPerRange - 1) / pageCountPerRange
+	ctidRanges := make([]CtidRange, rangeCount)
+	for i := range ctidRanges {
+		ctidRanges[i] = CtidRange{StartPage: int64(i) * pageCountPerRange, EndPage: int64(i+1) * pageCountPerRange}
+	}
+	ctidRanges[rangeCount-1].EndPage = 0 // The last range is open-ended
+
+	return ctidRanges, nil
+}
+
+// Tracks the table in the sync journal with primary key ranges to resume it from if it's large enough to be copied in multiple ranges
+func (syncer *SyncerFullRefresh) startJournalTable(pgSchemaTable PgSchemaTable, primaryKeyColumns []PgPrimaryKeyColumn, fingerprint string, conn *pgx.Conn, syncerJournal *SyncerJournal) (*SyncJournalTable, error) {
+	syncJournalTable := SyncJournalTable{Schema: pgSchemaTable.Schema, Table: pgSchemaTable.Table, Fingerprint: fingerprint}
+
+	primaryKeyRanges, err := syncer.primaryKeyRanges(pgSchemaTable, primaryKeyColumns, conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(primaryKeyRanges) > 0 {
+		xminMax, xminMin, err := syncer.xminRange(pgSchemaTable, conn)
+		if err != nil {
+			return nil, err
+		}
+		syncJournalTable.PrimaryKeyRanges = primaryKeyRanges
+		syncJournalTable.XminMax = xminMax
+		syncJournalTable.XminMin = xminMin
+	}
+
+	return syncerJournal.StartTable(syncJournalTable)
+}
+
+// Splits a table with a primary key into ranges with about CTID_RANGE_SIZE of table heap, which are copied and checkpointed separately.
+// Unlike ctid ranges, rows stay in their primary key range when they're updated, so a resumed table has neither missing nor duplicate rows
+func (syncer *SyncerFullRefresh) primaryKeyRanges(pgSchemaTable PgSchemaTable, primaryKeyColumns []PgPrimaryKeyColumn, conn *pgx.Conn) ([]PrimaryKeyRange, error) {
+	if len(primaryKeyColumns) == 0 {
+		return nil, nil
+	}
+
+	var tableSize int64
+	var estimatedRowCount int64
+	err := conn.QueryRow(
+		context.Background(),
+		"SELECT pg_relation_size(oid), GREATEST(reltuples, 0)::bigint FROM pg_class WHERE oid = $1::regclass",
+		pgSchemaTable.String(),
+	).Scan(&tableSize, &estimatedRowCount)
+	if err != nil {
+		return nil, err
+	}
+	if tableSize <= CTID_RANGE_SIZE || estimatedRowCount == 0 {
+		return nil, nil
+	}
+	rowCountPerRange := max(int64(float64(estimatedRowCount)*CTID_RANGE_SIZE/float64(tableSize)), 1)
+
+	quotedColumnNames := make([]string, len(primaryKeyColumns))
+	textColumns := make([]string, len(primaryKeyColumns))
+	for i, primaryKeyColumn := range primaryKeyColumns {
+		quotedColumnNames[i] = primaryKeyColumn.QuotedName()
+		textColumns[i] = primaryKeyColumn.QuotedName() + "::text"
+	}
+	orderBy := strings.Join(quotedColumnNames, ", ")
+
+	rows, err := conn.Query(
+		context.Background(),
+		"SELECT ARRAY["+strings.Join(textColumns, ", ")+"] FROM (SELECT "+orderBy+", row_number() OVER (ORDER BY "+orderBy+") AS row_number FROM "+pgSchemaTable.String()+") primary_keys WHERE row_number % $1 = 0 ORDER BY "+orderBy,
+		rowCountPerRange,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	primaryKeyRanges := []PrimaryKeyRange{{Columns: primaryKeyColumns}}
+	for rows.Next() {
+		var boundary []string
+		err = rows.Scan(&boundary)
+		if err != nil {
+			return nil, err
+		}
+		primaryKeyRanges[len(primaryKeyRanges)-1].End = boundary
+		primaryKeyRanges = append(primaryKeyRanges, PrimaryKeyRange{Columns: primaryKeyColumns, Start: boundary})
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	LogDebug(syncer.config, "Primary key ranges:", len(primaryKeyRanges))
+
+	return primaryKeyRanges, nil
+}
+
+func (syncer *SyncerFullRefresh) pgPrimaryKeyColumns(conn *pgx.Conn, pgSchemaTable PgSchemaTable) ([]PgPrimaryKeyColumn, error) {
+	rows, err := conn.Query(
+		context.Background(),
+		`SELECT pg_attribute.attname, format_type(pg_attribute.atttypid, pg_attribute.atttypmod)
+		FROM pg_index
+		JOIN pg_attribute ON pg_attribute.attrelid = pg_index.indrelid AND pg_attribute.attnum = ANY(pg_index.indkey)
+		WHERE pg_index.indrelid = $1::regclass AND pg_index.indisprimary
+		ORDER BY array_position(pg_index.indkey::int2[], pg_attribute.attnum)`,
+		pgSchemaTable.String(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var primaryKeyColumns []PgPrimaryKeyColumn
+	for rows.Next() {
+		var primaryKeyColumn PgPrimaryKeyColumn
+		err = rows.Scan(&primaryKeyColumn.Name, &primaryKeyColumn.Type)
+		if err != nil {
+			return nil, err
 		}
+		primaryKeyColumns = append(primaryKeyColumns, primaryKeyColumn)
+	}
+
+	return primaryKeyColumns, rows.Err()
+}
+
+// Returns the xmin of the most and least recently modified rows, nil for an empty table
+func (syncer *SyncerFullRefresh) xminRange(pgSchemaTable PgSchemaTable, conn *pgx.Conn) (xminMax *uint32, xminMin *uint32, err error) {
+	err = conn.QueryRow(
+		context.Background(),
+		"SELECT xmin FROM "+pgSchemaTable.String()+" ORDER BY age(xmin) ASC LIMIT 1",
+	).Scan(&xminMax)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, err
+	}
+
+	err = conn.QueryRow(
+		context.Background(),
+		"SELECT xmin FROM "+pgSchemaTable.String()+" ORDER BY age(xmin) DESC LIMIT 1",
+	).Scan(&xminMin)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, err
+	}
 
-		totalRowCount += len(rows)
-		LogDebug(syncer.config, "Writing", totalRowCount, "rows to Parquet...")
-		runtime.GC() // To reduce Parquet Go memory leakage
+	return xminMax, xminMin, nil
+}
 
-		return rows
-	})
+// Parquet files written before an interruption can be reused only with the same columns and sync options
+func (syncer *SyncerFullRefresh) fingerprint(pgSchemaColumns []PgSchemaColumn, primaryKeyColumns []PgPrimaryKeyColumn, partitionSpec IcebergPartitionSpec, sortOrder IcebergSortOrder, selectList string, rowFilter string) (string, error) {
+	pgSchemaColumnsJson, err := json.Marshal(pgSchemaColumns)
+	if err != nil {
+		return "", err
+	}
+	primaryKeyColumnsJson, err := json.Marshal(primaryKeyColumns)
+	if err != nil {
+		return "", err
+	}
 
-	close(stopPingChannel) // Stop the pingPg goroutine
-	waitGroup.Wait()       // Wait for the Read goroutine to finish
+	// Hashed since the SELECT list can contain the column hash salt
+	fingerprintParts := []string{string(pgSchemaColumnsJson), string(primaryKeyColumnsJson), partitionSpec.String(), sortOrder.String(), selectList, rowFilter}
+	return fmt.Sprintf("%x", sha256Hash([]byte(strings.Join(fingerprintParts, "\n")))), nil
 }
 
-func (syncer *SyncerFullRefresh) pgTableSchemaColumns(conn *pgx.Conn, pgSchemaTable PgSchemaTable, csvHeader []string) []PgSchemaColumn {
-	if len(csvHeader) == 0 {
-		PanicIfError(errors.New("couldn't read data from "+pgSchemaTable.String()), syncer.config)
+func (syncer *SyncerFullRefresh) pgTableSchemaColumns(conn *pgx.Conn, pgSchemaTable PgSchemaTable, columnNames []string) ([]PgSchemaColumn, error) {
+	if len(columnNames) == 0 {
+		return nil, errors.New("couldn't read data from " + pgSchemaTable.String())
 	}
 
 	var pgSchemaColumns []PgSchemaColumn
@@ -103,26 +419,42 @@ func (syncer *SyncerFullRefresh) pgTableSchemaColumns(conn *pgx.Conn, pgSchemaTa
 	rows, err := conn.Query(
 		context.Background(),
 		`SELECT
-			column_name,
-			data_type,
-			udt_name,
-			is_nullable,
-			ordinal_position,
-			COALESCE(character_maximum_length, 0),
-			COALESCE(numeric_precision, 0),
-			COALESCE(numeric_scale, 0),
-			COALESCE(datetime_precision, 0),
-			pg_namespace.nspname
+			columns.column_name,
+			columns.data_type,
+			columns.udt_name,
+			columns.is_nullable,
+			columns.ordinal_position,
+			COALESCE(columns.character_maximum_length, 0),
+			COALESCE(columns.numeric_precision, 0),
+			COALESCE(columns.numeric_scale, 0),
+			COALESCE(columns.datetime_precision, 0),
+			pg_namespace.nspname,
+			CASE WHEN pk.constraint_name IS NOT NULL THEN true ELSE false END
 		FROM information_schema.columns
-		JOIN pg_type ON pg_type.typname = udt_name
+		JOIN pg_type ON pg_type.typname = columns.udt_name
 		JOIN pg_namespace ON pg_namespace.oid = pg_type.typnamespace
-		WHERE table_schema = $1 AND table_name = $2
-		ORDER BY array_position($3, column_name)`,
+		LEFT JOIN (
+			SELECT
+				tc.constraint_name,
+				kcu.column_name,
+				kcu.table_schema,
+				kcu.table_name
+			FROM information_schema.table_constraints tc
+			JOIN information_schema.key_column_usage kcu
+				ON tc.constraint_name = kcu.constraint_name
+				AND tc.table_schema = kcu.table_schema
+				AND tc.table_name = kcu.table_name
+			WHERE tc.constraint_type = 'PRIMARY KEY'
+		) pk ON pk.column_name = columns.column_name AND pk.table_schema = columns.table_schema AND pk.table_name = columns.table_name
+		WHERE columns.table_schema = $1 AND columns.table_name = $2
+		ORDER BY array_position($3, columns.column_name)`,
 		pgSchemaTable.Schema,
 		pgSchemaTable.Table,
-		csvHeader,
+		columnNames,
 	)
-	PanicIfError(err, syncer.config)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	for rows.Next() {
@@ -138,26 +470,121 @@ func (syncer *SyncerFullRefresh) pgTableSchemaColumns(conn *pgx.Conn, pgSchemaTa
 			&pgSchemaColumn.NumericScale,
 			&pgSchemaColumn.DatetimePrecision,
 			&pgSchemaColumn.Namespace,
+			&pgSchemaColumn.PartOfPrimaryKey,
 		)
-		PanicIfError(err, syncer.config)
+		if err != nil {
+			return nil, err
+		}
 		pgSchemaColumns = append(pgSchemaColumns, *pgSchemaColumn)
 	}
 
-	return pgSchemaColumns
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	// Field IDs of existing columns are matched by attnums, so renamed columns keep reading their existing data
+	return syncer.icebergWriter.AssignFieldIds(pgSchemaTable.ToIcebergSchemaTable(), pgSchemaColumns), nil
 }
 
-func (syncer *SyncerFullRefresh) copyFromPgTable(pgSchemaTable PgSchemaTable, copyConn *pgx.Conn, cappedBuffer *CappedBuffer, waitGroup *sync.WaitGroup) {
-	LogInfo(syncer.config, "Reading from Postgres:", pgSchemaTable.String()+"...")
+// information_schema.columns doesn't include materialized views, so read the same values from pg_attribute
+func (syncer *SyncerFullRefresh) pgViewSchemaColumns(conn *pgx.Conn, pgSchemaTable PgSchemaTable, columnNames []string) ([]PgSchemaColumn, error) {
+	if len(columnNames) == 0 {
+		return nil, errors.New("couldn't read data from " + pgSchemaTable.String())
+	}
+
+	var pgSchemaColumns []PgSchemaColumn
+
+	rows, err := conn.Query(
+		context.Background(),
+		`SELECT
+			pg_attribute.attname,
+			CASE WHEN pg_type.typcategory = 'A' THEN 'ARRAY' ELSE format_type(pg_attribute.atttypid, NULL) END,
+			pg_type.typname,
+			CASE WHEN pg_attribute.attnotnull THEN 'NO' ELSE 'YES' END,
+			pg_attribute.attnum::text,
+			CASE WHEN pg_type.typname IN ('varchar', 'bpchar') AND pg_attribute.atttypmod > 0 THEN pg_attribute.atttypmod - 4 ELSE 0 END::text,
+			CASE WHEN pg_type.typname = 'numeric' AND pg_attribute.atttypmod > 0 THEN ((pg_attribute.atttypmod - 4) >> 16) & 65535 ELSE 0 END::text,
+			CASE WHEN pg_type.typname = 'numeric' AND pg_attribute.atttypmod > 0 THEN (pg_attribute.atttypmod - 4) & 65535 ELSE 0 END::text,
+			CASE
+				WHEN pg_type.typname IN ('timestamp', 'timestamptz', 'time', 'timetz') THEN CASE WHEN pg_attribute.atttypmod < 0 THEN 6 ELSE pg_attribute.atttypmod END
+				ELSE 0
+			END::text,
+			pg_namespace.nspname
+		FROM pg_attribute
+		JOIN pg_type ON pg_type.oid = pg_attribute.atttypid
+		JOIN pg_namespace ON pg_namespace.oid = pg_type.typnamespace
+		WHERE pg_attribute.attrelid = $1::regclass AND pg_attribute.attnum > 0 AND NOT pg_attribute.attisdropped AND pg_attribute.attname = ANY($2)
+		ORDER BY array_position($2, pg_attribute.attname::text)`,
+		pgSchemaTable.String(),
+		columnNames,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		pgSchemaColumn := NewPgSchemaColumn(syncer.config)
+		err = rows.Scan(
+			&pgSchemaColumn.ColumnName,
+			&pgSchemaColumn.DataType,
+			&pgSchemaColumn.UdtName,
+			&pgSchemaColumn.IsNullable,
+			&pgSchemaColumn.OrdinalPosition,
+			&pgSchemaColumn.CharacterMaximumLength,
+			&pgSchemaColumn.NumericPrecision,
+			&pgSchemaColumn.NumericScale,
+			&pgSchemaColumn.DatetimePrecision,
+			&pgSchemaColumn.Namespace,
+		)
+		if err != nil {
+			return nil, err
+		}
+		pgSchemaColumns = append(pgSchemaColumns, *pgSchemaColumn)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return syncer.icebergWriter.AssignFieldIds(pgSchemaTable.ToIcebergSchemaTable(), pgSchemaColumns), nil
+}
+
+func (syncer *SyncerFullRefresh) copyFromPgTable(pgSchemaTable PgSchemaTable, selectList string, rowFilter string, copyRange CopyRange, copyConn *pgx.Conn, cappedBuffer *CappedBuffer, waitGroup *sync.WaitGroup) error {
+	defer waitGroup.Done()
+	defer cappedBuffer.Close()
+
+	var conditions []string
+	if rowFilter != "" {
+		conditions = append(conditions, "("+rowFilter+")")
+	}
+	if copyRange.IsBounded() {
+		conditions = append(conditions, copyRange.WhereCondition())
+	}
+
+	copySource := pgSchemaTable.String()
+	if selectList != "*" || len(conditions) > 0 {
+		copySource = "(SELECT " + selectList + " FROM " + pgSchemaTable.String()
+		if len(conditions) > 0 {
+			copySource += " WHERE " + strings.Join(conditions, " AND ")
+		}
+		copySource += ")"
+	}
+
+	LogInfo(syncer.config, "Reading from Postgres:", pgSchemaTable.String()+copyRange.String()+"...")
 	result, err := copyConn.PgConn().CopyTo(
 		context.Background(),
 		cappedBuffer,
-		"COPY "+pgSchemaTable.String()+" TO STDOUT WITH CSV HEADER NULL '"+PG_NULL_STRING+"'",
+		"COPY "+copySource+" TO STDOUT WITH CSV HEADER NULL '"+PG_NULL_STRING+"'",
 	)
-	PanicIfError(err, syncer.config)
+	if err != nil {
+		return err
+	}
 	LogInfo(syncer.config, "Copied", result.RowsAffected(), "row(s)...")
 
-	cappedBuffer.Close()
-	waitGroup.Done()
+	return nil
 }
 
 func (syncer *SyncerFullRefresh) pingPg(conn *pgx.Conn, stopPingChannel *chan struct{}, waitGroup *sync.WaitGroup) {
@@ -173,7 +600,107 @@ func (syncer *SyncerFullRefresh) pingPg(conn *pgx.Conn, stopPingChannel *chan st
 		case <-ticker.C:
 			LogDebug(syncer.config, "Pinging the database...")
 			_, err := conn.Exec(context.Background(), "SELECT 1")
-			PanicIfError(err, syncer.config)
+			if err != nil {
+				// The broken connection fails the next query on it
+				LogWarn(syncer.config, "Failed to ping the database:", err)
+				<-*stopPingChannel
+				waitGroup.Done()
+				ticker.Stop()
+				return
+			}
 		}
 	}
 }
+
+// Range of heap pages [StartPage, EndPage) scanned with a TID range scan. EndPage 0 means unbounded
+type CtidRange struct {
+	StartPage int64 `json:"start-page"`
+	EndPage   int64 `json:"end-page"`
+}
+
+func (ctidRange CtidRange) IsBounded() bool {
+	return ctidRange.StartPage > 0 || ctidRange.EndPage > 0
+}
+
+func (ctidRange CtidRange) WhereCondition() string {
+	condition := "ctid >= '(" + Int64ToString(ctidRange.StartPage) + ",0)'::tid"
+	if ctidRange.EndPage > 0 {
+		condition += " AND ctid < '(" + Int64ToString(ctidRange.EndPage) + ",0)'::tid"
+	}
+	return condition
+}
+
+func (ctidRange CtidRange) String() string {
+	if !ctidRange.IsBounded() {
+		return ""
+	}
+	if ctidRange.EndPage == 0 {
+		return " (pages " + Int64ToString(ctidRange.StartPage) + "+)"
+	}
+	return " (pages " + Int64ToString(ctidRange.StartPage) + "-" + Int64ToString(ctidRange.EndPage-1) + ")"
+}
+
+// Part of a table copied with a single COPY
+type CopyRange interface {
+	IsBounded() bool
+	WhereCondition() string
+	String() string
+}
+
+type PgPrimaryKeyColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // Formatted with typmod, e.g. "character varying(255)"
+}
+
+func (primaryKeyColumn PgPrimaryKeyColumn) QuotedName() string {
+	return `"` + strings.ReplaceAll(primaryKeyColumn.Name, `"`, `""`) + `"`
+}
+
+// Rows with primary key values from Start (inclusive) to End (exclusive) as text. Nil Start or End means unbounded
+type PrimaryKeyRange struct {
+	Columns []PgPrimaryKeyColumn `json:"columns"`
+	Start   []string             `json:"start"`
+	End     []string             `json:"end"`
+}
+
+func (primaryKeyRange PrimaryKeyRange) IsBounded() bool {
+	return primaryKeyRange.Start != nil || primaryKeyRange.End != nil
+}
+
+func (primaryKeyRange PrimaryKeyRange) WhereCondition() string {
+	quotedColumnNames := make([]string, len(primaryKeyRange.Columns))
+	for i, primaryKeyColumn := range primaryKeyRange.Columns {
+		quotedColumnNames[i] = primaryKeyColumn.QuotedName()
+	}
+	columnsRow := "(" + strings.Join(quotedColumnNames, ", ") + ")"
+
+	var conditions []string
+	if primaryKeyRange.Start != nil {
+		conditions = append(conditions, columnsRow+" >= "+primaryKeyRange.valuesRow(primaryKeyRange.Start))
+	}
+	if primaryKeyRange.End != nil {
+		conditions = append(conditions, columnsRow+" < "+primaryKeyRange.valuesRow(primaryKeyRange.End))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+func (primaryKeyRange PrimaryKeyRange) String() string {
+	if !primaryKeyRange.IsBounded() {
+		return ""
+	}
+	if primaryKeyRange.Start == nil {
+		return " (primary keys < (" + strings.Join(primaryKeyRange.End, ", ") + "))"
+	}
+	if primaryKeyRange.End == nil {
+		return " (primary keys >= (" + strings.Join(primaryKeyRange.Start, ", ") + "))"
+	}
+	return " (primary keys (" + strings.Join(primaryKeyRange.Start, ", ") + ") to (" + strings.Join(primaryKeyRange.End, ", ") + "))"
+}
+
+func (primaryKeyRange PrimaryKeyRange) valuesRow(values []string) string {
+	castValues := make([]string, len(values))
+	for i, value := range values {
+		castValues[i] = "'" + strings.ReplaceAll(value, "'", "''") + "'::" + primaryKeyRange.Columns[i].Type
+	}
+	return "(" + strings.Join(castValues, ", ") + ")"
+}
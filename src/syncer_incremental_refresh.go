@@ -24,16 +24,27 @@ func NewSyncerIncrementalRefresh(config *Config, icebergWriter *IcebergWriter) *
 	}
 }
 
-func (syncer *SyncerIncrementalRefresh) SyncPgTable(pgSchemaTable PgSchemaTable, internalTableMetadata InternalTableMetadata, rowCountPerBatch int, structureConn *pgx.Conn, copyConn *pgx.Conn) {
+func (syncer *SyncerIncrementalRefresh) SyncPgTable(pgSchemaTable PgSchemaTable, internalTableMetadata InternalTableMetadata, rowCountPerBatch int, structureConn *pgx.Conn, copyConn *pgx.Conn) (syncTableStats SyncTableStats, err error) {
+	columnRules := NewSyncerColumnRules(syncer.config, pgSchemaTable)
+	columnNames, err := columnRules.ColumnNames(structureConn)
+	if err != nil {
+		return SyncTableStats{}, err
+	}
+	selectList := columnRules.SelectList(columnNames)
+	rowFilter := syncer.config.Pg.RowFilter(pgSchemaTable)
+
 	// Create a capped buffer read and written in parallel
 	cappedBuffer := NewCappedBuffer(MAX_IN_MEMORY_BUFFER_SIZE, syncer.config)
 
 	var waitGroup sync.WaitGroup
+	var copyErr error
+	copyDoneChannel := make(chan struct{})
 
 	// Copy from PG to cappedBuffer in a separate goroutine in parallel
 	waitGroup.Add(1)
 	go func() {
-		syncer.copyFromPgTable(pgSchemaTable, internalTableMetadata, copyConn, cappedBuffer, &waitGroup)
+		copyErr = syncer.copyFromPgTable(pgSchemaTable, selectList, rowFilter, internalTableMetadata, copyConn, cappedBuffer, &waitGroup)
+		close(copyDoneChannel)
 	}()
 
 	// Ping PG using structureConn in a separate goroutine in parallel to keep the connection alive
@@ -43,19 +54,37 @@ func (syncer *SyncerIncrementalRefresh) SyncPgTable(pgSchemaTable PgSchemaTable,
 		syncer.pingPg(structureConn, &stopPingChannel, &waitGroup)
 	}()
 
+	// Unblock the Read goroutine if writing stopped early, wait for the goroutines, and return the COPY error as well
+	defer func() {
+		cappedBuffer.Close()
+		close(stopPingChannel) // Stop the pingPg goroutine
+		waitGroup.Wait()       // Wait for the Read goroutine to finish
+		err = errors.Join(err, copyErr)
+	}()
+
 	// Read the header to get the column names
 	csvReader := csv.NewReader(cappedBuffer)
 	csvHeader, err := csvReader.Read()
-	PanicIfError(err, syncer.config)
+	if err != nil {
+		return SyncTableStats{}, err
+	}
 
 	schemaTable := pgSchemaTable.ToIcebergSchemaTable()
-	pgSchemaColumns := syncer.pgTableSchemaColumns(structureConn, pgSchemaTable, csvHeader)
+	pgSchemaColumns, err := syncer.pgTableSchemaColumns(structureConn, pgSchemaTable, csvHeader)
+	if err != nil {
+		return SyncTableStats{}, err
+	}
+	columnRules.TransformSchemaColumns(pgSchemaColumns)
+	sortOrder, err := NewIcebergSortOrder(syncer.config.Pg.SortBy(pgSchemaTable), pgSchemaColumns)
+	if err != nil {
+		return SyncTableStats{}, err
+	}
 	reachedEnd := false
 	totalRowCount := 0
 
 	// Write to Iceberg in a separate goroutine in parallel
 	LogInfo(syncer.config, "Writing incrementally to Iceberg...")
-	syncer.icebergWriter.WriteIncrementally(schemaTable, pgSchemaColumns, rowCountPerBatch, func() [][]string {
+	writtenParquetFiles := syncer.icebergWriter.WriteIncrementally(schemaTable, pgSchemaColumns, sortOrder, rowCountPerBatch, func() [][]string {
 		if reachedEnd {
 			return [][]string{}
 		}
@@ -65,6 +94,10 @@ func (syncer *SyncerIncrementalRefresh) SyncPgTable(pgSchemaTable PgSchemaTable,
 			row, err := csvReader.Read()
 
 			if err == io.EOF {
+				// Don't commit partially copied rows
+				<-copyDoneChannel
+				PanicIfError(copyErr, syncer.config)
+
 				reachedEnd = true
 				break
 			}
@@ -85,13 +118,22 @@ func (syncer *SyncerIncrementalRefresh) SyncPgTable(pgSchemaTable PgSchemaTable,
 		return rows
 	})
 
-	close(stopPingChannel) // Stop the pingPg goroutine
-	waitGroup.Wait()       // Wait for the Read goroutine to finish
+	// Combine small data files written by incremental syncs. Delete files are applied by the compact command, so a sync's cost scales with the changed rows
+	if syncer.compactsAfterSync(writtenParquetFiles) {
+		compactedParquetFiles := syncer.icebergWriter.Compact(schemaTable, pgSchemaColumns, sortOrder, syncer.config.Compaction.TargetFileSize, syncer.config.Compaction.MinFileCount, 0)
+		writtenParquetFiles = append(writtenParquetFiles, compactedParquetFiles...)
+	}
+
+	return NewSyncTableStats(int64(totalRowCount), writtenParquetFiles), nil
+}
+
+func (syncer *SyncerIncrementalRefresh) compactsAfterSync(writtenParquetFiles []ParquetFile) bool {
+	return syncer.config.Compaction.MinFileCount > 0 && len(writtenParquetFiles) > 0
 }
 
-func (syncer *SyncerIncrementalRefresh) pgTableSchemaColumns(conn *pgx.Conn, pgSchemaTable PgSchemaTable, csvHeader []string) []PgSchemaColumn {
+func (syncer *SyncerIncrementalRefresh) pgTableSchemaColumns(conn *pgx.Conn, pgSchemaTable PgSchemaTable, csvHeader []string) ([]PgSchemaColumn, error) {
 	if len(csvHeader) == 0 {
-		PanicIfError(errors.New("couldn't read data from "+pgSchemaTable.String()), syncer.config)
+		return nil, errors.New("couldn't read data from " + pgSchemaTable.String())
 	}
 
 	var pgSchemaColumns []PgSchemaColumn
@@ -132,7 +174,9 @@ func (syncer *SyncerIncrementalRefresh) pgTableSchemaColumns(conn *pgx.Conn, pgS
 		pgSchemaTable.Table,
 		csvHeader,
 	)
-	PanicIfError(err, syncer.config)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	for rows.Next() {
@@ -150,25 +194,41 @@ func (syncer *SyncerIncrementalRefresh) pgTableSchemaColumns(conn *pgx.Conn, pgS
 			&pgSchemaColumn.Namespace,
 			&pgSchemaColumn.PartOfPrimaryKey,
 		)
-		PanicIfError(err, syncer.config)
+		if err != nil {
+			return nil, err
+		}
 		pgSchemaColumns = append(pgSchemaColumns, *pgSchemaColumn)
 	}
 
-	return pgSchemaColumns
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return syncer.icebergWriter.AssignFieldIds(pgSchemaTable.ToIcebergSchemaTable(), pgSchemaColumns), nil
 }
 
-func (syncer *SyncerIncrementalRefresh) copyFromPgTable(pgSchemaTable PgSchemaTable, internalTableMetadata InternalTableMetadata, copyConn *pgx.Conn, cappedBuffer *CappedBuffer, waitGroup *sync.WaitGroup) {
+func (syncer *SyncerIncrementalRefresh) copyFromPgTable(pgSchemaTable PgSchemaTable, selectList string, rowFilter string, internalTableMetadata InternalTableMetadata, copyConn *pgx.Conn, cappedBuffer *CappedBuffer, waitGroup *sync.WaitGroup) error {
+	defer waitGroup.Done()
+	defer cappedBuffer.Close()
+
+	condition := "(xmin::text::bigint > " + internalTableMetadata.XminMaxString() + " OR xmin::text::bigint < " + internalTableMetadata.XminMinString() + ")"
+	if rowFilter != "" {
+		condition = "(" + rowFilter + ") AND " + condition
+	}
+
 	LogInfo(syncer.config, "Reading from Postgres:", pgSchemaTable.String()+"...")
 	result, err := copyConn.PgConn().CopyTo(
 		context.Background(),
 		cappedBuffer,
-		"COPY (SELECT * FROM "+pgSchemaTable.String()+" WHERE xmin::text::bigint > "+internalTableMetadata.XminMaxString()+" OR xmin::text::bigint < "+internalTableMetadata.XminMinString()+") TO STDOUT WITH CSV HEADER NULL '"+PG_NULL_STRING+"'",
+		"COPY (SELECT "+selectList+" FROM "+pgSchemaTable.String()+" WHERE "+condition+") TO STDOUT WITH CSV HEADER NULL '"+PG_NULL_STRING+"'",
 	)
-	PanicIfError(err, syncer.config)
+	if err != nil {
+		return err
+	}
 	LogInfo(syncer.config, "Copied", result.RowsAffected(), "row(s)...")
 
-	cappedBuffer.Close()
-	waitGroup.Done()
+	return nil
 }
 
 func (syncer *SyncerIncrementalRefresh) pingPg(conn *pgx.Conn, stopPingChannel *chan struct{}, waitGroup *sync.WaitGroup) {
@@ -184,7 +244,14 @@ func (syncer *SyncerIncrementalRefresh) pingPg(conn *pgx.Conn, stopPingChannel *
 		case <-ticker.C:
 			LogDebug(syncer.config, "Pinging the database...")
 			_, err := conn.Exec(context.Background(), "SELECT 1")
-			PanicIfError(err, syncer.config)
+			if err != nil {
+				// The broken connection fails the next query on it
+				LogWarn(syncer.config, "Failed to ping the database:", err)
+				<-*stopPingChannel
+				waitGroup.Done()
+				ticker.Stop()
+				return
+			}
 		}
 	}
 }
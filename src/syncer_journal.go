@@ -0,0 +1,164 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+// Persists the progress of a sync run in the storage to resume it after a crash
+type SyncerJournal struct {
+	config        *Config
+	icebergWriter *IcebergWriter
+	syncJournal   SyncJournal
+}
+
+func NewSyncerJournal(config *Config, icebergWriter *IcebergWriter) *SyncerJournal {
+	return &SyncerJournal{
+		config:        config,
+		icebergWriter: icebergWriter,
+	}
+}
+
+// Loads the journal left by an interrupted sync run or starts a new one
+func (journal *SyncerJournal) Start() error {
+	existingSyncJournal, err := journal.icebergWriter.storage.SyncJournal()
+	if err != nil {
+		return err
+	}
+
+	if existingSyncJournal == nil {
+		journal.syncJournal = SyncJournal{StartedAt: time.Now().Unix(), CompletedTables: []string{}}
+		return journal.write()
+	}
+
+	journal.syncJournal = *existingSyncJournal
+	LogInfo(journal.config, "Resuming an interrupted sync started at", time.Unix(journal.syncJournal.StartedAt, 0).UTC().Format(time.RFC3339), "with", len(journal.syncJournal.CompletedTables), "completed table(s)")
+
+	inProgressTable := journal.syncJournal.InProgressTable
+	if inProgressTable == nil {
+		return nil
+	}
+
+	// Rows of a table copied by ctid before the interruption can be updated and moved to ranges copied after it, so they could be missing or duplicated
+	if len(inProgressTable.PrimaryKeyRanges) == 0 {
+		LogInfo(journal.config, "Restarting", inProgressTable.PgSchemaTable().String(), "interrupted during the previous sync")
+		return journal.AbandonTable(inProgressTable.PgSchemaTable())
+	}
+
+	return journal.CleanUpTable(inProgressTable.PgSchemaTable())
+}
+
+// Deletes the partial output of a table interrupted or failed during syncing, keeping Parquet files of completed primary key ranges
+func (journal *SyncerJournal) CleanUpTable(pgSchemaTable PgSchemaTable) (err error) {
+	defer RecoverError(&err)
+
+	keptFilePaths := []string{}
+	if journal.isTableInProgress(pgSchemaTable) {
+		for _, parquetFile := range journal.syncJournal.InProgressTable.ParquetFiles {
+			keptFilePaths = append(keptFilePaths, parquetFile.Path)
+		}
+	}
+
+	LogInfo(journal.config, "Cleaning up partial output for", pgSchemaTable.String()+"...")
+	journal.icebergWriter.DeleteUnreferencedFiles(pgSchemaTable.ToIcebergSchemaTable(), keptFilePaths)
+	return nil
+}
+
+// Stops tracking a table that was interrupted or kept failing and deletes all of its partial output
+func (journal *SyncerJournal) AbandonTable(pgSchemaTable PgSchemaTable) error {
+	if journal.isTableInProgress(pgSchemaTable) {
+		journal.syncJournal.InProgressTable = nil
+		err := journal.write()
+		if err != nil {
+			return err
+		}
+	}
+
+	return journal.CleanUpTable(pgSchemaTable)
+}
+
+func (journal *SyncerJournal) IsTableCompleted(pgSchemaTable PgSchemaTable) bool {
+	return slices.Contains(journal.syncJournal.CompletedTables, pgSchemaTable.ToConfigArg())
+}
+
+func (journal *SyncerJournal) IsFullRefreshInProgress(pgSchemaTable PgSchemaTable) bool {
+	return journal.isTableInProgress(pgSchemaTable) && len(journal.syncJournal.InProgressTable.PrimaryKeyRanges) > 0
+}
+
+// Returns the progress of the table if it was interrupted with the same fingerprint, otherwise deletes its partial output and returns nil
+func (journal *SyncerJournal) ResumeTable(pgSchemaTable PgSchemaTable, fingerprint string) (*SyncJournalTable, error) {
+	inProgressTable := journal.syncJournal.InProgressTable
+	if inProgressTable == nil {
+		return nil, nil
+	}
+
+	// The journal tracks a single table, so an interrupted table that isn't synced first is restarted
+	if !journal.isTableInProgress(pgSchemaTable) {
+		LogInfo(journal.config, "Restarting", inProgressTable.PgSchemaTable().String(), "interrupted during the previous sync")
+		return nil, journal.AbandonTable(inProgressTable.PgSchemaTable())
+	}
+	if len(inProgressTable.PrimaryKeyRanges) == 0 {
+		return nil, nil
+	}
+	if inProgressTable.Fingerprint != fingerprint {
+		LogInfo(journal.config, "Restarting", pgSchemaTable.String(), "since its columns or sync options changed after the interruption")
+		return nil, journal.AbandonTable(pgSchemaTable)
+	}
+
+	return inProgressTable, nil
+}
+
+// Tracks the table to resume it from its completed primary key ranges or clean up its partial output if the sync run is interrupted
+func (journal *SyncerJournal) StartTable(syncJournalTable SyncJournalTable) (*SyncJournalTable, error) {
+	if syncJournalTable.ParquetFiles == nil {
+		syncJournalTable.ParquetFiles = []ParquetFile{}
+	}
+	journal.syncJournal.InProgressTable = &syncJournalTable
+	return journal.syncJournal.InProgressTable, journal.write()
+}
+
+// Checkpoints the primary key ranges copied into parquetFiles
+func (journal *SyncerJournal) CompletePrimaryKeyRanges(primaryKeyRangeCount int, parquetFiles []ParquetFile) error {
+	inProgressTable := journal.syncJournal.InProgressTable
+	inProgressTable.CompletedPrimaryKeyRanges += primaryKeyRangeCount
+	inProgressTable.ParquetFiles = append(inProgressTable.ParquetFiles, parquetFiles...)
+	return journal.write()
+}
+
+// Returns the progress of the table if it's being synced, or nil
+func (journal *SyncerJournal) InProgressTable(pgSchemaTable PgSchemaTable) *SyncJournalTable {
+	if !journal.isTableInProgress(pgSchemaTable) {
+		return nil
+	}
+	return journal.syncJournal.InProgressTable
+}
+
+func (journal *SyncerJournal) CompleteTable(pgSchemaTable PgSchemaTable) error {
+	journal.syncJournal.CompletedTables = append(journal.syncJournal.CompletedTables, pgSchemaTable.ToConfigArg())
+	if journal.isTableInProgress(pgSchemaTable) {
+		journal.syncJournal.InProgressTable = nil
+	}
+	return journal.write()
+}
+
+// Deletes the journal after the sync run has completed and the partial output of an interrupted table that wasn't synced again
+func (journal *SyncerJournal) Finish() error {
+	inProgressTable := journal.syncJournal.InProgressTable
+	if inProgressTable != nil {
+		err := journal.AbandonTable(inProgressTable.PgSchemaTable())
+		if err != nil {
+			return err
+		}
+	}
+
+	return journal.icebergWriter.storage.DeleteSyncJournal()
+}
+
+func (journal *SyncerJournal) isTableInProgress(pgSchemaTable PgSchemaTable) bool {
+	inProgressTable := journal.syncJournal.InProgressTable
+	return inProgressTable != nil && inProgressTable.Schema == pgSchemaTable.Schema && inProgressTable.Table == pgSchemaTable.Table
+}
+
+func (journal *SyncerJournal) write() error {
+	return journal.icebergWriter.storage.WriteSyncJournal(journal.syncJournal)
+}
@@ -0,0 +1,603 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	PG_PARTITION_STRATEGY_LIST  = "LIST"
+	PG_PARTITION_STRATEGY_RANGE = "RANGE"
+)
+
+// Leaf partition of a partitioned table
+type PgPartition struct {
+	PgSchemaTable PgSchemaTable
+	Bound         string // Example: FOR VALUES FROM ('2024-01-01') TO ('2024-02-01')
+	Level         int    // 1 for partitions attached directly to the partitioned table
+}
+
+// Literal in a partition bound. MINVALUE, MAXVALUE and NULL aren't quoted
+type PgPartitionBoundLiteral struct {
+	Value  string
+	Quoted bool
+}
+
+func (literal PgPartitionBoundLiteral) IsKeyword(keyword string) bool {
+	return !literal.Quoted && strings.EqualFold(literal.Value, keyword)
+}
+
+// Syncs the leaf partitions of a partitioned table into a single Iceberg table named after it.
+// Partitions that haven't changed since the last sync keep their Parquet files, so attaching or detaching a partition only adds or drops its own files
+type SyncerPartitions struct {
+	config            *Config
+	icebergWriter     *IcebergWriter
+	syncerFullRefresh *SyncerFullRefresh
+}
+
+func NewSyncerPartitions(config *Config, icebergWriter *IcebergWriter, syncerFullRefresh *SyncerFullRefresh) *SyncerPartitions {
+	return &SyncerPartitions{
+		config:            config,
+		icebergWriter:     icebergWriter,
+		syncerFullRefresh: syncerFullRefresh,
+	}
+}
+
+func (syncer *SyncerPartitions) SyncPgTable(pgSchemaTable PgSchemaTable, syncedPreviously bool, rowCountPerBatch int, structureConn *pgx.Conn, copyConn *pgx.Conn) (SyncTableStats, error) {
+	schemaTable := pgSchemaTable.ToIcebergSchemaTable()
+	columnRules := NewSyncerColumnRules(syncer.config, pgSchemaTable)
+	columnNames, err := columnRules.ColumnNames(structureConn)
+	if err != nil {
+		return SyncTableStats{}, err
+	}
+	pgSchemaColumns, err := syncer.syncerFullRefresh.pgTableSchemaColumns(structureConn, pgSchemaTable, columnNames)
+	if err != nil {
+		return SyncTableStats{}, err
+	}
+	columnRules.TransformSchemaColumns(pgSchemaColumns)
+	selectList := columnRules.ColumnList(columnNames) // Columns of a partition can be in a different order
+	rowFilter := syncer.config.Pg.RowFilter(pgSchemaTable)
+
+	partitionKey, err := syncer.partitionKey(structureConn, pgSchemaTable)
+	if err != nil {
+		return SyncTableStats{}, err
+	}
+	pgPartitions, err := syncer.listPgPartitions(structureConn, pgSchemaTable)
+	if err != nil {
+		return SyncTableStats{}, err
+	}
+	partitionSpec, partitionValues := syncer.icebergPartitioning(partitionKey, pgSchemaColumns, columnRules, pgPartitions)
+	if partitionSpec.IsPartitioned() {
+		LogDebug(syncer.config, "Partition spec:", partitionSpec.String())
+	} else {
+		LogInfo(syncer.config, "Partition key", partitionKey, "of", pgSchemaTable.String(), "doesn't map to an Iceberg partition spec, writing the table unpartitioned")
+	}
+	sortOrder, err := NewIcebergSortOrder(syncer.config.Pg.SortBy(pgSchemaTable), pgSchemaColumns)
+	if err != nil {
+		return SyncTableStats{}, err
+	}
+	partitionedSchema, err := syncer.partitionedSchema(partitionKey, partitionSpec, sortOrder, pgSchemaColumns, selectList, rowFilter)
+	if err != nil {
+		return SyncTableStats{}, err
+	}
+
+	previousPartitions, currentParquetFiles, err := syncer.previousPartitions(pgSchemaTable, syncedPreviously, partitionedSchema)
+	if err != nil {
+		return SyncTableStats{}, err
+	}
+
+	var waitGroup sync.WaitGroup
+
+	// Ping PG using structureConn in a separate goroutine in parallel to keep the connection alive
+	waitGroup.Add(1)
+	stopPingChannel := make(chan struct{})
+	go func() {
+		syncer.syncerFullRefresh.pingPg(structureConn, &stopPingChannel, &waitGroup)
+	}()
+	defer func() {
+		close(stopPingChannel) // Stop the pingPg goroutine
+		waitGroup.Wait()       // Wait for the pingPg goroutine to finish
+	}()
+
+	// Compare and copy partitions using copyConn to see them in the same snapshot
+	partitions := []InternalPartitionMetadata{}
+	partitionParquetFiles := [][]ParquetFile{}
+	writtenParquetFiles := []ParquetFile{}
+	keptPartitionCount := 0
+	var copiedRowCount int64
+	for i, pgPartition := range pgPartitions {
+		partition := InternalPartitionMetadata{Schema: pgPartition.PgSchemaTable.Schema, Table: pgPartition.PgSchemaTable.Table, Bound: pgPartition.Bound, PartitionValues: partitionValues[i]}
+		partition.RowCount, partition.XminMax, err = syncer.partitionVersion(copyConn, pgPartition.PgSchemaTable)
+		if err != nil {
+			return SyncTableStats{}, err
+		}
+
+		previousPartition, found := previousPartitions[pgPartition.PgSchemaTable.ToConfigArg()]
+		if found && previousPartition.Bound == partition.Bound && previousPartition.RowCount == partition.RowCount && syncer.equalXmins(previousPartition.XminMax, partition.XminMax) {
+			keptParquetFiles, kept := syncer.keptParquetFiles(previousPartition, currentParquetFiles)
+			if kept {
+				LogDebug(syncer.config, "Keeping unchanged partition", pgPartition.PgSchemaTable.String())
+				partitions = append(partitions, previousPartition)
+				partitionParquetFiles = append(partitionParquetFiles, keptParquetFiles)
+				keptPartitionCount++
+				continue
+			}
+		}
+
+		LogInfo(syncer.config, "Writing partition", pgPartition.PgSchemaTable.String(), "to Iceberg...")
+		parquetFiles, err := syncer.syncerFullRefresh.syncCopyRanges(pgPartition.PgSchemaTable, schemaTable, pgSchemaColumns, IcebergPartitionSpec{}, sortOrder, selectList, rowFilter, []CopyRange{CtidRange{}}, rowCountPerBatch, []*pgx.Conn{copyConn})
+		if err != nil {
+			return SyncTableStats{}, err
+		}
+		for j := range parquetFiles {
+			parquetFiles[j].PartitionValues = partitionValues[i]
+			copiedRowCount += parquetFiles[j].RecordCount
+		}
+
+		partitions = append(partitions, partition)
+		partitionParquetFiles = append(partitionParquetFiles, parquetFiles)
+		writtenParquetFiles = append(writtenParquetFiles, parquetFiles...)
+	}
+
+	syncTableStats := NewSyncTableStats(copiedRowCount, writtenParquetFiles)
+	syncTableStats.Mode = SYNC_MODE_FULL_REFRESH
+	if keptPartitionCount > 0 {
+		syncTableStats.Mode = SYNC_MODE_INCREMENTAL
+	}
+
+	if len(previousPartitions) > 0 && keptPartitionCount == len(pgPartitions) && keptPartitionCount == len(previousPartitions) {
+		LogInfo(syncer.config, "No partitions of", pgSchemaTable.String(), "changed since the last sync")
+		return syncTableStats, nil
+	}
+
+	committedParquetFiles := []ParquetFile{}
+	for _, parquetFiles := range partitionParquetFiles {
+		committedParquetFiles = append(committedParquetFiles, parquetFiles...)
+	}
+	if len(committedParquetFiles) == 0 {
+		// A table needs at least one Parquet file even without partitions
+		committedParquetFiles = syncer.icebergWriter.WriteParquetFiles(schemaTable, pgSchemaColumns, IcebergPartitionSpec{}, sortOrder, MAX_WRITE_PARQUET_PAYLOAD_SIZE, func() [][]string { return [][]string{} })
+		for i := range committedParquetFiles {
+			committedParquetFiles[i].PartitionValues = make([]*string, len(partitionSpec.Fields))
+		}
+	}
+
+	LogDebug(syncer.config, "Committing", len(committedParquetFiles), "Parquet file(s) of", len(partitions), "partition(s)...")
+	committedParquetFiles = syncer.icebergWriter.CommitParquetFiles(schemaTable, pgSchemaColumns, partitionSpec, sortOrder, committedParquetFiles)
+
+	// Empty Parquet files aren't committed
+	committedFilePaths := make(Set[string])
+	for _, parquetFile := range committedParquetFiles {
+		committedFilePaths.Add(parquetFile.Path)
+	}
+	for i := range partitions {
+		partitions[i].ParquetFilePaths = []string{}
+		for _, parquetFile := range partitionParquetFiles[i] {
+			if committedFilePaths.Contains(parquetFile.Path) {
+				partitions[i].ParquetFilePaths = append(partitions[i].ParquetFilePaths, parquetFile.Path)
+			}
+		}
+	}
+
+	snapshotId, err := syncer.currentSnapshotId(schemaTable)
+	if err != nil {
+		return SyncTableStats{}, err
+	}
+	err = syncer.icebergWriter.storage.WriteInternalTableMetadata(pgSchemaTable, InternalTableMetadata{
+		LastSyncedAt:      time.Now().Unix(),
+		RowFilter:         rowFilter,
+		PartitionedSchema: partitionedSchema,
+		SnapshotId:        snapshotId,
+		Partitions:        partitions,
+	})
+	if err != nil {
+		return SyncTableStats{}, err
+	}
+
+	// Drop the files of changed and detached partitions after the internal metadata stops referencing them
+	syncer.icebergWriter.DeleteUnreferencedFiles(schemaTable, []string{})
+
+	return syncTableStats, nil
+}
+
+// Maps the partition key to an Iceberg partition spec if every partition has a single partition value:
+// - LIST partitions with one value each to the identity transform
+// - RANGE partitions of a date or timestamp column to the finest of the hour, day, month and year transforms
+// - RANGE partitions of an integer column with one value each to the identity transform
+// Other partitions, e.g. HASH, DEFAULT or sub-partitioned ones, leave the table unpartitioned
+func (syncer *SyncerPartitions) icebergPartitioning(partitionKey string, pgSchemaColumns []PgSchemaColumn, columnRules *SyncerColumnRules, pgPartitions []PgPartition) (IcebergPartitionSpec, [][]*string) {
+	unpartitionedValues := make([][]*string, len(pgPartitions))
+
+	strategy, keyColumnName, ok := syncer.parsePartitionKey(partitionKey)
+	if !ok || columnRules.IsTransformed(keyColumnName) {
+		return IcebergPartitionSpec{}, unpartitionedValues
+	}
+
+	keyColumnIndex := slices.IndexFunc(pgSchemaColumns, func(pgSchemaColumn PgSchemaColumn) bool {
+		return pgSchemaColumn.ColumnName == keyColumnName
+	})
+	if keyColumnIndex == -1 || pgSchemaColumns[keyColumnIndex].DataType == PG_DATA_TYPE_ARRAY {
+		return IcebergPartitionSpec{}, unpartitionedValues
+	}
+	keyColumn := pgSchemaColumns[keyColumnIndex]
+	sourceId := keyColumn.FieldId
+	sourceType := keyColumn.icebergPrimitiveType()
+	if sourceType == "string" && keyColumn.UdtName != "varchar" && keyColumn.UdtName != "text" {
+		return IcebergPartitionSpec{}, unpartitionedValues // Other types are synced as strings in a different format
+	}
+
+	lowerLiterals := make([]PgPartitionBoundLiteral, len(pgPartitions))
+	upperLiterals := make([]PgPartitionBoundLiteral, len(pgPartitions))
+	for i, pgPartition := range pgPartitions {
+		if pgPartition.Level != 1 {
+			return IcebergPartitionSpec{}, unpartitionedValues
+		}
+
+		var literals []PgPartitionBoundLiteral
+		switch strategy {
+		case PG_PARTITION_STRATEGY_LIST:
+			literals, ok = syncer.parsePartitionBoundLiterals(pgPartition.Bound, "FOR VALUES IN (", ")")
+			if !ok || len(literals) != 1 {
+				return IcebergPartitionSpec{}, unpartitionedValues
+			}
+			lowerLiterals[i] = literals[0]
+		case PG_PARTITION_STRATEGY_RANGE:
+			lowerBound, upperBound, found := strings.Cut(pgPartition.Bound, ") TO (")
+			literals, ok = syncer.parsePartitionBoundLiterals(lowerBound, "FOR VALUES FROM (", "")
+			if !found || !ok || len(literals) != 1 || literals[0].IsKeyword("MINVALUE") || literals[0].IsKeyword("NULL") {
+				return IcebergPartitionSpec{}, unpartitionedValues
+			}
+			lowerLiterals[i] = literals[0]
+
+			literals, ok = syncer.parsePartitionBoundLiterals(upperBound, "", ")")
+			if !ok || len(literals) != 1 || literals[0].IsKeyword("MAXVALUE") || literals[0].IsKeyword("NULL") {
+				return IcebergPartitionSpec{}, unpartitionedValues
+			}
+			upperLiterals[i] = literals[0]
+		}
+	}
+
+	identityField := IcebergPartitionField{
+		SourceId:   sourceId,
+		FieldId:    ICEBERG_PARTITION_FIELD_ID_START,
+		Name:       keyColumn.NormalizedColumnName(),
+		Transform:  ICEBERG_PARTITION_TRANSFORM_IDENTITY,
+		ResultType: sourceType,
+	}
+
+	if strategy == PG_PARTITION_STRATEGY_LIST {
+		partitionValues := make([][]*string, len(pgPartitions))
+		for i, literal := range lowerLiterals {
+			if literal.IsKeyword("NULL") {
+				partitionValues[i] = []*string{nil}
+				continue
+			}
+
+			value, ok := syncer.identityPartitionValue(sourceType, literal.Value)
+			if !ok {
+				return IcebergPartitionSpec{}, unpartitionedValues
+			}
+			partitionValues[i] = []*string{&value}
+		}
+		return IcebergPartitionSpec{Fields: []IcebergPartitionField{identityField}}, partitionValues
+	}
+
+	switch sourceType {
+	case "int", "long":
+		partitionValues := make([][]*string, len(pgPartitions))
+		for i := range pgPartitions {
+			lowerValue, lowerErr := strconv.ParseInt(lowerLiterals[i].Value, 10, 64)
+			upperValue, upperErr := strconv.ParseInt(upperLiterals[i].Value, 10, 64)
+			if lowerErr != nil || upperErr != nil || upperValue != lowerValue+1 {
+				return IcebergPartitionSpec{}, unpartitionedValues
+			}
+
+			value := strconv.FormatInt(lowerValue, 10)
+			partitionValues[i] = []*string{&value}
+		}
+		return IcebergPartitionSpec{Fields: []IcebergPartitionField{identityField}}, partitionValues
+	case "date", "timestamp":
+		lowerTimes := make([]time.Time, len(pgPartitions))
+		lastTimes := make([]time.Time, len(pgPartitions)) // Upper bounds are exclusive
+		for i := range pgPartitions {
+			lowerTime, lowerOk := syncer.parsePartitionBoundTime(lowerLiterals[i].Value)
+			upperTime, upperOk := syncer.parsePartitionBoundTime(upperLiterals[i].Value)
+			if !lowerOk || !upperOk || !upperTime.After(lowerTime) {
+				return IcebergPartitionSpec{}, unpartitionedValues
+			}
+
+			lowerTimes[i] = lowerTime
+			if sourceType == "date" {
+				lastTimes[i] = upperTime.AddDate(0, 0, -1)
+			} else {
+				lastTimes[i] = upperTime.Add(-time.Microsecond)
+			}
+		}
+
+		for _, transform := range ICEBERG_TIME_PARTITION_TRANSFORMS {
+			if sourceType == "date" && transform == ICEBERG_PARTITION_TRANSFORM_HOUR {
+				continue
+			}
+
+			partitionValues := make([][]*string, len(pgPartitions))
+			for i := range pgPartitions {
+				lowerValue := IcebergTimePartitionValue(transform, lowerTimes[i])
+				if IcebergTimePartitionValue(transform, lastTimes[i]) != lowerValue {
+					partitionValues = nil
+					break
+				}
+
+				value := strconv.FormatInt(lowerValue, 10)
+				partitionValues[i] = []*string{&value}
+			}
+
+			if partitionValues != nil {
+				return IcebergPartitionSpec{Fields: []IcebergPartitionField{{
+					SourceId:   sourceId,
+					FieldId:    ICEBERG_PARTITION_FIELD_ID_START,
+					Name:       keyColumn.NormalizedColumnName() + "_" + transform,
+					Transform:  transform,
+					ResultType: "int",
+				}}}, partitionValues
+			}
+		}
+	}
+
+	return IcebergPartitionSpec{}, unpartitionedValues
+}
+
+// Example: RANGE (created_at) -> RANGE, created_at
+func (syncer *SyncerPartitions) parsePartitionKey(partitionKey string) (strategy string, columnName string, ok bool) {
+	strategy, columns, found := strings.Cut(partitionKey, " (")
+	if !found || !strings.HasSuffix(columns, ")") {
+		return "", "", false
+	}
+	if strategy != PG_PARTITION_STRATEGY_LIST && strategy != PG_PARTITION_STRATEGY_RANGE {
+		return "", "", false
+	}
+
+	columnName = strings.TrimSuffix(columns, ")")
+	if strings.HasPrefix(columnName, `"`) && strings.HasSuffix(columnName, `"`) && len(columnName) > 1 {
+		columnName = strings.ReplaceAll(columnName[1:len(columnName)-1], `""`, `"`)
+	} else if strings.ContainsAny(columnName, ",() ") {
+		return "", "", false // Multiple columns or expressions
+	}
+
+	return strategy, columnName, true
+}
+
+// Example: ('us', NULL) -> us, NULL
+func (syncer *SyncerPartitions) parsePartitionBoundLiterals(bound string, prefix string, suffix string) ([]PgPartitionBoundLiteral, bool) {
+	if !strings.HasPrefix(bound, prefix) || !strings.HasSuffix(bound, suffix) {
+		return nil, false
+	}
+	list := strings.TrimSuffix(strings.TrimPrefix(bound, prefix), suffix)
+
+	literals := []PgPartitionBoundLiteral{}
+	for len(list) > 0 {
+		list = strings.TrimLeft(list, " ")
+
+		var literal PgPartitionBoundLiteral
+		if strings.HasPrefix(list, "'") {
+			var value strings.Builder
+			closed := false
+			i := 1
+			for i < len(list) {
+				if list[i] == '\'' {
+					if i+1 < len(list) && list[i+1] == '\'' {
+						value.WriteByte('\'')
+						i += 2
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				value.WriteByte(list[i])
+				i++
+			}
+			if !closed {
+				return nil, false
+			}
+			literal = PgPartitionBoundLiteral{Value: value.String(), Quoted: true}
+			list = list[i:]
+		} else {
+			value, _, _ := strings.Cut(list, ",")
+			literal = PgPartitionBoundLiteral{Value: strings.TrimSpace(value)}
+			list = list[len(value):]
+		}
+		literals = append(literals, literal)
+
+		list = strings.TrimLeft(list, " ")
+		if list != "" {
+			if !strings.HasPrefix(list, ",") {
+				return nil, false
+			}
+			list = list[1:]
+		}
+	}
+
+	return literals, true
+}
+
+// Dates and timestamps are printed in the session time zone, timestamps without time zone are treated as UTC
+func (syncer *SyncerPartitions) parsePartitionBoundTime(value string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999-07:00", "2006-01-02 15:04:05.999999-07", "2006-01-02 15:04:05.999999", "2006-01-02"} {
+		parsedTime, err := time.Parse(layout, value)
+		if err == nil {
+			return parsedTime, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (syncer *SyncerPartitions) identityPartitionValue(sourceType string, value string) (string, bool) {
+	switch sourceType {
+	case "int", "long":
+		intValue, err := strconv.ParseInt(value, 10, 64)
+		return strconv.FormatInt(intValue, 10), err == nil
+	case "boolean":
+		boolValue, err := strconv.ParseBool(value)
+		return strconv.FormatBool(boolValue), err == nil
+	case "string":
+		return value, true
+	case "date":
+		parsedTime, err := StringDateToTime(value)
+		return Int64ToString(IcebergTimePartitionValue(ICEBERG_PARTITION_TRANSFORM_DAY, parsedTime)), err == nil
+	}
+	return "", false
+}
+
+// Changing the partition key, partition spec, sort order, columns, column rules or row filter rewrites all partitions
+func (syncer *SyncerPartitions) partitionedSchema(partitionKey string, partitionSpec IcebergPartitionSpec, sortOrder IcebergSortOrder, pgSchemaColumns []PgSchemaColumn, selectList string, rowFilter string) (string, error) {
+	icebergSchemaFieldsJson, err := json.Marshal(NewIcebergSchemaFields(pgSchemaColumns))
+	if err != nil {
+		return "", err
+	}
+
+	// Hashed since the SELECT list can contain the column hash salt
+	partitionedSchemaParts := []string{partitionKey, partitionSpec.String(), string(icebergSchemaFieldsJson), selectList, rowFilter}
+	if sortOrder.IsSorted() {
+		partitionedSchemaParts = append(partitionedSchemaParts, sortOrder.String())
+	}
+	partitionedSchema := strings.Join(partitionedSchemaParts, "\n")
+	return fmt.Sprintf("%x", sha256Hash([]byte(partitionedSchema))), nil
+}
+
+// Returns the partitions recorded in the last sync by schema.table with the data files of the current snapshot by path, or none if they have to be rewritten
+func (syncer *SyncerPartitions) previousPartitions(pgSchemaTable PgSchemaTable, syncedPreviously bool, partitionedSchema string) (map[string]InternalPartitionMetadata, map[string]ParquetFile, error) {
+	previousPartitions := make(map[string]InternalPartitionMetadata)
+	currentParquetFiles := make(map[string]ParquetFile)
+	if !syncedPreviously {
+		return previousPartitions, currentParquetFiles, nil
+	}
+
+	internalTableMetadata, err := syncer.icebergWriter.storage.InternalTableMetadata(pgSchemaTable)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if internalTableMetadata.PartitionedSchema != partitionedSchema {
+		LogInfo(syncer.config, "Partitioning or columns of", pgSchemaTable.String(), "changed, rewriting all partitions")
+		return previousPartitions, currentParquetFiles, nil
+	}
+
+	// The Parquet files of the partitions may have been deleted if syncing stopped between committing and writing the internal metadata
+	metadataDirPath := syncer.icebergWriter.storage.CreateMetadataDir(pgSchemaTable.ToIcebergSchemaTable())
+	manifestListFilesSortedAsc, err := syncer.icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(manifestListFilesSortedAsc) == 0 || internalTableMetadata.SnapshotId != manifestListFilesSortedAsc[len(manifestListFilesSortedAsc)-1].SnapshotId {
+		LogInfo(syncer.config, "Internal metadata of", pgSchemaTable.String(), "is outdated, rewriting all partitions")
+		return previousPartitions, currentParquetFiles, nil
+	}
+
+	manifestListItems, err := syncer.icebergWriter.storage.ExistingManifestListItems(manifestListFilesSortedAsc[len(manifestListFilesSortedAsc)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, manifestListItem := range manifestListItems {
+		parquetFile, err := syncer.icebergWriter.storage.ExistingParquetFile(manifestListItem.ManifestFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		currentParquetFiles[parquetFile.Path] = parquetFile
+	}
+
+	for _, partition := range internalTableMetadata.Partitions {
+		previousPartitions[partition.Schema+"."+partition.Table] = partition
+	}
+	return previousPartitions, currentParquetFiles, nil
+}
+
+// Returns the Parquet files of an unchanged partition with their stats from the manifests, or false if any of them isn't in the current snapshot
+func (syncer *SyncerPartitions) keptParquetFiles(previousPartition InternalPartitionMetadata, currentParquetFiles map[string]ParquetFile) ([]ParquetFile, bool) {
+	// Internal metadata written by older versions has no Parquet file paths
+	if previousPartition.ParquetFilePaths == nil {
+		return nil, false
+	}
+
+	parquetFiles := []ParquetFile{}
+	for _, parquetFilePath := range previousPartition.ParquetFilePaths {
+		parquetFile, found := currentParquetFiles[parquetFilePath]
+		if !found {
+			return nil, false
+		}
+		parquetFile.PartitionValues = previousPartition.PartitionValues
+		parquetFiles = append(parquetFiles, parquetFile)
+	}
+	return parquetFiles, true
+}
+
+func (syncer *SyncerPartitions) currentSnapshotId(schemaTable IcebergSchemaTable) (int64, error) {
+	metadataDirPath := syncer.icebergWriter.storage.CreateMetadataDir(schemaTable)
+	manifestListFilesSortedAsc, err := syncer.icebergWriter.storage.ExistingManifestListFiles(metadataDirPath)
+	if err != nil {
+		return 0, err
+	}
+	if len(manifestListFilesSortedAsc) == 0 {
+		return 0, nil
+	}
+	return manifestListFilesSortedAsc[len(manifestListFilesSortedAsc)-1].SnapshotId, nil
+}
+
+// Inserts and updates change the newest xmin, and deletes change the row count
+func (syncer *SyncerPartitions) partitionVersion(conn *pgx.Conn, pgSchemaTable PgSchemaTable) (rowCount int64, xminMax *uint32, err error) {
+	err = conn.QueryRow(
+		context.Background(),
+		"SELECT count(*), (SELECT xmin FROM "+pgSchemaTable.String()+" ORDER BY age(xmin) ASC LIMIT 1) FROM "+pgSchemaTable.String(),
+	).Scan(&rowCount, &xminMax)
+	return rowCount, xminMax, err
+}
+
+func (syncer *SyncerPartitions) equalXmins(xmin1 *uint32, xmin2 *uint32) bool {
+	if xmin1 == nil || xmin2 == nil {
+		return xmin1 == xmin2
+	}
+	return *xmin1 == *xmin2
+}
+
+func (syncer *SyncerPartitions) partitionKey(conn *pgx.Conn, pgSchemaTable PgSchemaTable) (string, error) {
+	var partitionKey string
+	err := conn.QueryRow(context.Background(), "SELECT pg_get_partkeydef($1::regclass)", pgSchemaTable.String()).Scan(&partitionKey)
+	return partitionKey, err
+}
+
+// Foreign table partitions aren't synced
+func (syncer *SyncerPartitions) listPgPartitions(conn *pgx.Conn, pgSchemaTable PgSchemaTable) ([]PgPartition, error) {
+	rows, err := conn.Query(
+		context.Background(),
+		`SELECT leaf_namespace.nspname, leaf.relname, pg_get_expr(leaf.relpartbound, leaf.oid), tree.level
+		FROM pg_partition_tree($1::regclass) AS tree
+		JOIN pg_class AS leaf ON leaf.oid = tree.relid
+		JOIN pg_namespace AS leaf_namespace ON leaf_namespace.oid = leaf.relnamespace
+		WHERE tree.isleaf AND leaf.relkind = 'r'
+		ORDER BY leaf_namespace.nspname, leaf.relname`,
+		pgSchemaTable.String(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pgPartitions []PgPartition
+	for rows.Next() {
+		pgPartition := PgPartition{PgSchemaTable: PgSchemaTable{Kind: PG_RELKIND_TABLE}}
+		err = rows.Scan(&pgPartition.PgSchemaTable.Schema, &pgPartition.PgSchemaTable.Table, &pgPartition.Bound, &pgPartition.Level)
+		if err != nil {
+			return nil, err
+		}
+		pgPartitions = append(pgPartitions, pgPartition)
+	}
+
+	return pgPartitions, rows.Err()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	SYNC_RETRY_INTERVAL = 1 * time.Minute // If a scheduled sync fails before reaching the tables
+)
+
+// Decides which tables are due based on their schedules in the sync config file or --pg-sync-interval
+type SyncerScheduler struct {
+	config       *Config
+	syncInterval time.Duration // 0 if --pg-sync-interval isn't set
+	startedAt    time.Time
+	nextSyncAt   time.Time // Earliest next sync of the tables seen in the current run
+}
+
+func NewSyncerScheduler(config *Config) *SyncerScheduler {
+	var syncInterval time.Duration
+	if config.Pg.SyncInterval != "" {
+		var err error
+		syncInterval, err = time.ParseDuration(config.Pg.SyncInterval)
+		PanicIfError(err, config)
+	}
+
+	return &SyncerScheduler{
+		config:       config,
+		syncInterval: syncInterval,
+		startedAt:    time.Now(),
+	}
+}
+
+// Syncs tables with higher priority first
+func (scheduler *SyncerScheduler) SortByPriority(pgSchemaTables []PgSchemaTable) {
+	slices.SortStableFunc(pgSchemaTables, func(a, b PgSchemaTable) int {
+		return scheduler.priority(b) - scheduler.priority(a)
+	})
+}
+
+func (scheduler *SyncerScheduler) StartRun() {
+	scheduler.nextSyncAt = time.Time{}
+}
+
+func (scheduler *SyncerScheduler) IsDue(pgSchemaTable PgSchemaTable, lastAttemptAt time.Time, now time.Time) bool {
+	tableNextSyncAt := scheduler.tableNextSyncAt(pgSchemaTable, lastAttemptAt, now)
+	if tableNextSyncAt.IsZero() {
+		return false
+	}
+	if tableNextSyncAt.After(now) {
+		scheduler.trackNextSyncAt(tableNextSyncAt)
+		return false
+	}
+	return true
+}
+
+func (scheduler *SyncerScheduler) RecordAttempt(pgSchemaTable PgSchemaTable, attemptedAt time.Time) {
+	scheduler.trackNextSyncAt(scheduler.tableNextSyncAt(pgSchemaTable, attemptedAt, time.Now()))
+}
+
+// Returns false if no table has to be synced again
+func (scheduler *SyncerScheduler) NextSyncAt() (time.Time, bool) {
+	return scheduler.nextSyncAt, !scheduler.nextSyncAt.IsZero()
+}
+
+// Tables without a schedule fall back to --pg-sync-interval or are synced once after starting
+func (scheduler *SyncerScheduler) tableNextSyncAt(pgSchemaTable PgSchemaTable, lastAttemptAt time.Time, now time.Time) time.Time {
+	syncTableConfig := scheduler.config.Pg.SyncTableConfig(pgSchemaTable)
+
+	var tableNextSyncAt time.Time
+	switch {
+	case lastAttemptAt.IsZero():
+		tableNextSyncAt = now
+	case syncTableConfig != nil && syncTableConfig.HasSchedule():
+		tableNextSyncAt = syncTableConfig.NextSyncAt(lastAttemptAt)
+	case scheduler.syncInterval > 0:
+		tableNextSyncAt = lastAttemptAt.Add(scheduler.syncInterval)
+	case lastAttemptAt.Before(scheduler.startedAt):
+		tableNextSyncAt = now
+	default:
+		return time.Time{}
+	}
+
+	if tableNextSyncAt.Before(now) {
+		tableNextSyncAt = now
+	}
+	if syncTableConfig != nil && syncTableConfig.maintenanceWindow != nil {
+		tableNextSyncAt = syncTableConfig.maintenanceWindow.NextOpenAt(tableNextSyncAt)
+	}
+	return tableNextSyncAt
+}
+
+func (scheduler *SyncerScheduler) trackNextSyncAt(tableNextSyncAt time.Time) {
+	if tableNextSyncAt.IsZero() {
+		return
+	}
+	if scheduler.nextSyncAt.IsZero() || tableNextSyncAt.Before(scheduler.nextSyncAt) {
+		scheduler.nextSyncAt = tableNextSyncAt
+	}
+}
+
+func (scheduler *SyncerScheduler) priority(pgSchemaTable PgSchemaTable) int {
+	syncTableConfig := scheduler.config.Pg.SyncTableConfig(pgSchemaTable)
+	if syncTableConfig == nil {
+		return 0
+	}
+	return syncTableConfig.Priority
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	SYNC_MODE_FULL_REFRESH = "full"
+	SYNC_MODE_INCREMENTAL  = "incremental"
+	SYNC_MODE_CDC          = "cdc" // Not supported, rejected by the sync config
+
+	MAX_SYNC_RUNS = 100
+)
+
+// Rows copied from Postgres and bytes written to the storage while syncing a table
+type SyncTableStats struct {
+	Mode         string
+	RowCount     int64
+	BytesWritten int64
+}
+
+func NewSyncTableStats(rowCount int64, writtenParquetFiles []ParquetFile) SyncTableStats {
+	syncTableStats := SyncTableStats{RowCount: rowCount}
+	for _, parquetFile := range writtenParquetFiles {
+		syncTableStats.BytesWritten += parquetFile.Size
+	}
+	return syncTableStats
+}
+
+// Records sync outcomes in the storage to expose them as bemidb.sync_status and bemidb.sync_runs
+type SyncerStatus struct {
+	config        *Config
+	icebergWriter *IcebergWriter
+	syncStatus    SyncStatus
+	syncRun       SyncRun
+	startedAt     time.Time
+}
+
+func NewSyncerStatus(config *Config, icebergWriter *IcebergWriter) *SyncerStatus {
+	return &SyncerStatus{
+		config:        config,
+		icebergWriter: icebergWriter,
+	}
+}
+
+func (status *SyncerStatus) Start() (err error) {
+	status.syncStatus, err = status.icebergWriter.storage.SyncStatus()
+	if err != nil {
+		return err
+	}
+
+	status.startedAt = time.Now()
+	status.syncRun = SyncRun{StartedAt: status.startedAt.Unix()}
+	return nil
+}
+
+// Records the last sync attempt of a table, keeping the time of its last successful sync if the attempt failed
+func (status *SyncerStatus) RecordTable(pgSchemaTable PgSchemaTable, syncTableStats SyncTableStats, attemptStartedAt time.Time, syncErr error) error {
+	icebergSchemaTable := status.prefixedIcebergSchemaTable(pgSchemaTable)
+	syncTableStatus := SyncTableStatus{
+		Schema:        icebergSchemaTable.Schema,
+		Table:         icebergSchemaTable.Table,
+		Mode:          syncTableStats.Mode,
+		LastAttemptAt: attemptStartedAt.Unix(),
+		RowCount:      syncTableStats.RowCount,
+		BytesWritten:  syncTableStats.BytesWritten,
+		DurationMs:    time.Since(attemptStartedAt).Milliseconds(),
+	}
+
+	if syncErr == nil {
+		syncTableStatus.LastSuccessAt = time.Now().Unix()
+		status.syncRun.SyncedTableCount++
+		status.syncRun.RowCount += syncTableStats.RowCount
+		status.syncRun.BytesWritten += syncTableStats.BytesWritten
+	} else {
+		syncTableStatus.Error = syncErr.Error()
+		status.syncRun.FailedTableCount++
+	}
+
+	for i, existingSyncTableStatus := range status.syncStatus.Tables {
+		if existingSyncTableStatus.Schema == syncTableStatus.Schema && existingSyncTableStatus.Table == syncTableStatus.Table {
+			if syncErr != nil {
+				syncTableStatus.LastSuccessAt = existingSyncTableStatus.LastSuccessAt
+			}
+			status.syncStatus.Tables[i] = syncTableStatus
+			return status.write()
+		}
+	}
+
+	status.syncStatus.Tables = append(status.syncStatus.Tables, syncTableStatus)
+	return status.write()
+}
+
+// Returns a zero time if the table has never been synced
+func (status *SyncerStatus) LastAttemptAt(pgSchemaTable PgSchemaTable) time.Time {
+	icebergSchemaTable := status.prefixedIcebergSchemaTable(pgSchemaTable)
+	for _, syncTableStatus := range status.syncStatus.Tables {
+		if syncTableStatus.Schema == icebergSchemaTable.Schema && syncTableStatus.Table == icebergSchemaTable.Table {
+			return time.Unix(syncTableStatus.LastAttemptAt, 0)
+		}
+	}
+	return time.Time{}
+}
+
+// Forgets tables that are no longer synced
+func (status *SyncerStatus) KeepTables(pgSchemaTables []PgSchemaTable) {
+	icebergSchemaTables := make(Set[IcebergSchemaTable])
+	for _, pgSchemaTable := range pgSchemaTables {
+		icebergSchemaTables.Add(status.prefixedIcebergSchemaTable(pgSchemaTable))
+	}
+
+	keptSyncTableStatuses := []SyncTableStatus{}
+	for _, syncTableStatus := range status.syncStatus.Tables {
+		if icebergSchemaTables.Contains(IcebergSchemaTable{Schema: syncTableStatus.Schema, Table: syncTableStatus.Table}) {
+			keptSyncTableStatuses = append(keptSyncTableStatuses, syncTableStatus)
+		}
+	}
+	status.syncStatus.Tables = keptSyncTableStatuses
+}
+
+// Appends the sync run to the history, keeping the last MAX_SYNC_RUNS runs
+func (status *SyncerStatus) Finish(syncErr error) error {
+	if status.syncRun.StartedAt == 0 {
+		return nil // Not started
+	}
+
+	status.syncRun.FinishedAt = time.Now().Unix()
+	status.syncRun.DurationMs = time.Since(status.startedAt).Milliseconds()
+	if syncErr != nil {
+		status.syncRun.Error = syncErr.Error()
+	}
+
+	status.syncStatus.Runs = append(status.syncStatus.Runs, status.syncRun)
+	if len(status.syncStatus.Runs) > MAX_SYNC_RUNS {
+		status.syncStatus.Runs = status.syncStatus.Runs[len(status.syncStatus.Runs)-MAX_SYNC_RUNS:]
+	}
+
+	return status.write()
+}
+
+func (status *SyncerStatus) prefixedIcebergSchemaTable(pgSchemaTable PgSchemaTable) IcebergSchemaTable {
+	return IcebergSchemaTable{Schema: status.config.Pg.SchemaPrefix + pgSchemaTable.Schema, Table: pgSchemaTable.Table}
+}
+
+func (status *SyncerStatus) write() error {
+	return status.icebergWriter.storage.WriteSyncStatus(status.syncStatus)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	TABLE_COMMAND_ROLLBACK   = "rollback"
+	TABLE_COMMAND_TAG        = "tag"
+	TABLE_COMMAND_BRANCH     = "branch"
+	TABLE_COMMAND_REMOVE_REF = "remove-ref"
+)
+
+var TABLE_COMMANDS = []string{TABLE_COMMAND_ROLLBACK, TABLE_COMMAND_TAG, TABLE_COMMAND_BRANCH, TABLE_COMMAND_REMOVE_REF}
+
+// Restores previous states of synced tables and names snapshots with tags and branches
+type TableHistory struct {
+	config        *Config
+	icebergReader *IcebergReader
+	icebergWriter *IcebergWriter
+}
+
+func NewTableHistory(config *Config) *TableHistory {
+	return &TableHistory{
+		config:        config,
+		icebergReader: NewIcebergReader(config),
+		icebergWriter: NewIcebergWriter(config),
+	}
+}
+
+func (tableHistory *TableHistory) Rollback(tableCommandConfig *TableCommandConfig) (err error) {
+	defer RecoverError(&err)
+
+	snapshot, err := tableHistory.icebergReader.Snapshot(tableCommandConfig.SchemaTable, tableCommandConfig.SnapshotId, tableCommandConfig.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	manifestListFile := tableHistory.icebergWriter.Rollback(tableCommandConfig.SchemaTable, snapshot.SnapshotId)
+
+	// The next sync can't continue incrementally from the rows synced after the snapshot
+	pgSchemaTable := PgSchemaTable{Schema: tableCommandConfig.SchemaTable.Schema, Table: tableCommandConfig.SchemaTable.Table}
+	internalTableMetadata, err := tableHistory.icebergWriter.storage.InternalTableMetadata(pgSchemaTable)
+	if err != nil {
+		LogDebug(tableHistory.config, "Skipping the internal table metadata of", tableCommandConfig.SchemaTable.String()+":", err)
+	} else {
+		internalTableMetadata.XminMax = nil
+		internalTableMetadata.XminMin = nil
+		err = tableHistory.icebergWriter.storage.WriteInternalTableMetadata(pgSchemaTable, internalTableMetadata)
+		PanicIfError(err, tableHistory.config)
+	}
+
+	LogInfo(tableHistory.config, "Rolled back", tableCommandConfig.SchemaTable.String(), "to snapshot", snapshot.SnapshotId, "with snapshot", manifestListFile.SnapshotId)
+	return nil
+}
+
+func (tableHistory *TableHistory) Tag(tableCommandConfig *TableCommandConfig) (err error) {
+	return tableHistory.setRef(tableCommandConfig, ICEBERG_SNAPSHOT_REF_TAG)
+}
+
+func (tableHistory *TableHistory) Branch(tableCommandConfig *TableCommandConfig) (err error) {
+	return tableHistory.setRef(tableCommandConfig, ICEBERG_SNAPSHOT_REF_BRANCH)
+}
+
+func (tableHistory *TableHistory) RemoveRef(tableCommandConfig *TableCommandConfig) (err error) {
+	defer RecoverError(&err)
+
+	if !tableHistory.icebergWriter.RemoveSnapshotRef(tableCommandConfig.SchemaTable, tableCommandConfig.RefName) {
+		return fmt.Errorf("tag or branch %s of %s doesn't exist", tableCommandConfig.RefName, tableCommandConfig.SchemaTable.String())
+	}
+
+	LogInfo(tableHistory.config, "Removed", tableCommandConfig.RefName, "from", tableCommandConfig.SchemaTable.String())
+	return nil
+}
+
+func (tableHistory *TableHistory) setRef(tableCommandConfig *TableCommandConfig, refType string) (err error) {
+	defer RecoverError(&err)
+
+	if tableCommandConfig.RefName == ICEBERG_SNAPSHOT_REF_MAIN || strings.TrimSpace(tableCommandConfig.RefName) == "" {
+		return fmt.Errorf("invalid %s name \"%s\"", refType, tableCommandConfig.RefName)
+	}
+
+	snapshotId := tableCommandConfig.SnapshotId
+	if snapshotId == 0 {
+		manifestListFiles, err := tableHistory.icebergReader.ManifestListFiles(tableCommandConfig.SchemaTable)
+		if err != nil {
+			return err
+		}
+		if len(manifestListFiles) == 0 {
+			return fmt.Errorf("table %s doesn't exist", tableCommandConfig.SchemaTable.String())
+		}
+		snapshotId = manifestListFiles[len(manifestListFiles)-1].SnapshotId
+	}
+
+	tableHistory.icebergWriter.SetSnapshotRef(tableCommandConfig.SchemaTable, snapshotId, SnapshotRef{Name: tableCommandConfig.RefName, Type: refType})
+
+	LogInfo(tableHistory.config, "Set", refType, tableCommandConfig.RefName, "of", tableCommandConfig.SchemaTable.String(), "to snapshot", snapshotId)
+	return nil
+}
@@ -29,17 +29,60 @@ type AnonymousErrorData struct {
 	StackTrace string `json:"stackTrace"`
 }
 
+// Panic value raised by PanicIfError, turned back into an error by RecoverError
+type RecoverableError struct {
+	Err        error
+	config     *Config // nil skips the anonymous error report if the panic isn't recovered
+	stackTrace string
+}
+
+func NewRecoverableError(err error, config *Config) *RecoverableError {
+	return &RecoverableError{Err: err, config: config, stackTrace: currentStackTrace()}
+}
+
+func (recoverableError *RecoverableError) Error() string {
+	return recoverableError.Err.Error()
+}
+
+func (recoverableError *RecoverableError) Unwrap() error {
+	return recoverableError.Err
+}
+
 func PanicIfError(err error, config *Config, message ...string) {
 	if err != nil {
-		if config != nil {
-			sendAnonymousErrorReport(config, err)
-		}
 		if len(message) == 1 {
-			panic(fmt.Errorf(message[0]+": %w", err))
+			err = fmt.Errorf(message[0]+": %w", err)
 		}
 
-		panic(err)
+		panic(NewRecoverableError(err, config))
+	}
+}
+
+// Must be deferred directly. Turns a panic raised by PanicIfError into an error returned through err and re-raises other panics
+func RecoverError(err *error) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+
+	recoverableError, ok := recovered.(*RecoverableError)
+	if !ok {
+		panic(recovered)
+	}
+	*err = recoverableError
+}
+
+// Must be deferred directly. Sends an anonymous error report for a panic raised by PanicIfError that wasn't recovered and re-raises it
+func ReportPanic() {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+
+	if recoverableError, ok := recovered.(*RecoverableError); ok && recoverableError.config != nil {
+		sendAnonymousErrorReport(recoverableError.config, recoverableError)
 	}
+	panic(recovered)
 }
 
 func IntToString(i int) string {
@@ -156,9 +199,12 @@ func sendAnonymousErrorReport(config *Config, err error) {
 		return
 	}
 
-	stack := make([]byte, 4096)
-	n := runtime.Stack(stack, false)
-	stackTrace := string(stack[:n])
+	// Report where the error was raised rather than where it was reported
+	stackTrace := currentStackTrace()
+	var recoverableError *RecoverableError
+	if errors.As(err, &recoverableError) {
+		stackTrace = recoverableError.stackTrace
+	}
 
 	var dbHost string
 	if config != nil && config.Pg.DatabaseUrl != "" {
@@ -192,3 +238,9 @@ func sendAnonymousErrorReport(config *Config, err error) {
 	client := http.Client{Timeout: 5 * time.Second}
 	_, _ = client.Post("https://api.bemidb.com/api/errors", "application/json", bytes.NewBuffer(jsonData))
 }
+
+func currentStackTrace() string {
+	stack := make([]byte, 4096)
+	n := runtime.Stack(stack, false)
+	return string(stack[:n])
+}